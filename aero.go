@@ -0,0 +1,121 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// Aero is a ForceGenerator for an aerodynamic surface such as a wing, fin or
+// sail. The Tensor turns the velocity of the air over the surface, in Body
+// Space, into the lift and drag forces, also in Body Space. A tensor with
+// negative values on its diagonal gives plain drag and off-diagonal values
+// turn airflow along one axis into lift along another.
+type Aero struct {
+	// Tensor converts the Body Space airflow into the Body Space force.
+	Tensor m.Matrix3
+
+	// Position is the point, in Body Space, where the force is applied.
+	Position m.Vector3
+
+	// Wind is the velocity of the air in World Space.
+	// Defaults to still air.
+	Wind m.Vector3
+}
+
+// NewAero creates a new Aero surface at the point on the body.
+func NewAero(tensor *m.Matrix3, position *m.Vector3) *Aero {
+	a := new(Aero)
+	a.Tensor = *tensor
+	a.Position = *position
+	return a
+}
+
+// UpdateForce adds the aerodynamic force of the surface to the body.
+func (a *Aero) UpdateForce(body *RigidBody, duration m.Real) {
+	addAeroForce(body, &a.Tensor, &a.Position, &a.Wind)
+}
+
+// AeroControl is an Aero surface that can be moved by the player or an AI,
+// like the ailerons, elevators and rudder of a plane. Its tensor is blended
+// between MinTensor and MaxTensor by the ControlSetting.
+type AeroControl struct {
+	Aero
+
+	// MinTensor is the tensor used when the ControlSetting is -1.
+	MinTensor m.Matrix3
+
+	// MaxTensor is the tensor used when the ControlSetting is 1.
+	MaxTensor m.Matrix3
+
+	// ControlSetting is the position of the control surface from -1 to 1.
+	// Defaults to 0.0, which uses the Tensor of the Aero.
+	ControlSetting m.Real
+}
+
+// NewAeroControl creates a new AeroControl at the point on the body. The base
+// tensor is used at rest and the min and max tensors at either extreme.
+func NewAeroControl(base *m.Matrix3, min *m.Matrix3, max *m.Matrix3, position *m.Vector3) *AeroControl {
+	a := new(AeroControl)
+	a.Tensor = *base
+	a.MinTensor = *min
+	a.MaxTensor = *max
+	a.Position = *position
+	return a
+}
+
+// SetControl sets the position of the control surface, clamped to -1 to 1.
+func (a *AeroControl) SetControl(value m.Real) {
+	if value < -1.0 {
+		value = -1.0
+	} else if value > 1.0 {
+		value = 1.0
+	}
+	a.ControlSetting = value
+}
+
+// GetTensor returns the tensor for the current ControlSetting.
+func (a *AeroControl) GetTensor() m.Matrix3 {
+	setting := a.ControlSetting
+	if setting == 0.0 {
+		return a.Tensor
+	}
+
+	extreme := &a.MaxTensor
+	if setting < 0.0 {
+		extreme = &a.MinTensor
+		setting = -setting
+	}
+	if setting > 1.0 {
+		setting = 1.0
+	}
+	var tensor m.Matrix3
+	for i := range tensor {
+		tensor[i] = a.Tensor[i]*(1.0-setting) + extreme[i]*setting
+	}
+	return tensor
+}
+
+// UpdateForce adds the aerodynamic force of the control surface to the body.
+func (a *AeroControl) UpdateForce(body *RigidBody, duration m.Real) {
+	tensor := a.GetTensor()
+	addAeroForce(body, &tensor, &a.Position, &a.Wind)
+}
+
+// addAeroForce adds the force from the tensor for the airflow over the point
+// on the body, given in Body Space.
+func addAeroForce(body *RigidBody, tensor *m.Matrix3, position *m.Vector3, wind *m.Vector3) {
+	// the velocity of the surface through the air
+	point := body.transform.MulVector3(position)
+	airflow := body.GetVelocityAtPoint(&point)
+	airflow.Sub(wind)
+	if airflow.SquareMagnitude() == 0.0 {
+		return
+	}
+
+	bodyAirflow := body.transform.TransformInverseDirection(&airflow)
+	bodyForce := tensor.MulVector3(&bodyAirflow)
+	force := body.transform.TransformDirection(&bodyForce)
+	body.AddForceAtPoint(&force, &point)
+}
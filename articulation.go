@@ -0,0 +1,522 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// ArticulationJointType is the kind of joint that links an ArticulationLink
+// to its parent.
+type ArticulationJointType uint8
+
+const (
+	// ArticulationFixed welds the link to its parent.
+	ArticulationFixed ArticulationJointType = iota
+
+	// ArticulationRevolute lets the link turn about an axis through the anchor.
+	ArticulationRevolute
+
+	// ArticulationPrismatic lets the link slide along an axis.
+	ArticulationPrismatic
+)
+
+// ArticulationLink is one body of an Articulation along with the joint that
+// links it to its parent. The joint coordinate is the angle in radians of a
+// revolute joint or the offset of a prismatic one, starting from zero where
+// the link was added.
+type ArticulationLink struct {
+	// Body is the body of the link. It must have finite mass unless it's the
+	// root of an articulation with a fixed base.
+	Body *RigidBody
+
+	// Parent is the index of the parent link, or -1 for the root.
+	Parent int
+
+	// Type is the kind of joint between the link and its parent.
+	Type ArticulationJointType
+
+	// Position is the joint coordinate.
+	Position m.Real
+
+	// Velocity is how fast the joint coordinate is changing.
+	Velocity m.Real
+
+	// Force is the torque, or force for a prismatic joint, that an actuator
+	// applies to the joint each step. It's left as it is between steps.
+	// Defaults to 0.0.
+	Force m.Real
+
+	// Damping is the friction in the joint, as the force that resists each
+	// unit of its Velocity.
+	// Defaults to 0.0.
+	Damping m.Real
+
+	// anchors holds the anchor of the joint in the Body Space of the parent
+	// and of the link.
+	anchors [2]m.Vector3
+
+	// axis is the axis of the joint in the Body Space of the parent.
+	axis m.Vector3
+
+	// rest is the orientation of the link relative to its parent when the
+	// joint coordinate is zero.
+	rest m.Quat
+
+	// motion is the spatial motion of the link for each unit of Velocity.
+	motion spatialVector
+
+	// velocity is the spatial velocity of the link.
+	velocity spatialVector
+
+	// bias is the spatial acceleration the link picks up from the joint
+	// turning with the parent.
+	bias spatialVector
+
+	// inertia and force are the articulated inertia and bias force of the
+	// link and the links beyond it.
+	inertia spatialMatrix
+	force   spatialVector
+
+	// projected, stiffness and effort are the inertia, D and u terms of the
+	// algorithm for the joint.
+	projected spatialVector
+	stiffness m.Real
+	effort    m.Real
+
+	// acceleration is the spatial acceleration of the link and
+	// jointAcceleration that of the joint coordinate.
+	acceleration      spatialVector
+	jointAcceleration m.Real
+
+	// before holds the state of the body before the contacts were resolved.
+	before articulationState
+}
+
+// articulationState is the state of a body that the contacts can change.
+type articulationState struct {
+	position    m.Vector3
+	orientation m.Quat
+	velocity    m.Vector3
+	rotation    m.Vector3
+}
+
+// Articulation is a tree of bodies linked by joints that is simulated in
+// joint coordinates with Featherstone's articulated-body algorithm, for robots,
+// ragdolls and other long chains. Unlike the contact-based joints its joints
+// can't drift apart or stretch under load. Contacts with the rest of the world
+// are resolved against the bodies as usual and the resulting impulses are then
+// passed back through the joints, which is only approximate for links with
+// many contacts.
+type Articulation struct {
+	// Links holds the links of the articulation, each after its parent. The
+	// first link is the root.
+	Links []*ArticulationLink
+
+	// FixedBase keeps the root where it is instead of letting it move freely
+	// as the links push on it.
+	FixedBase bool
+
+	// world is the world the articulation was added to.
+	world *World
+
+	// filter stops links from colliding with their parents.
+	filter *jointFilter
+}
+
+// NewArticulation creates a new Articulation with the body given as its root.
+func NewArticulation(root *RigidBody, fixedBase bool) *Articulation {
+	a := new(Articulation)
+	a.FixedBase = fixedBase
+	a.Links = append(a.Links, &ArticulationLink{Body: root, Parent: -1})
+	a.filter = newJointFilter()
+	return a
+}
+
+// AddRevolute adds the body as a link of the parent given that turns about
+// the axis through the anchor, both in World Space, and returns the index of
+// the new link. The derived data of the bodies must be up to date and the
+// articulation must not be in a world.
+func (a *Articulation) AddRevolute(parent int, body *RigidBody, anchor m.Vector3, axis m.Vector3) int {
+	return a.addLink(parent, body, ArticulationRevolute, anchor, axis)
+}
+
+// AddPrismatic adds the body as a link of the parent given that slides along
+// the axis, in World Space, and returns the index of the new link. The
+// derived data of the bodies must be up to date and the articulation must not
+// be in a world.
+func (a *Articulation) AddPrismatic(parent int, body *RigidBody, axis m.Vector3) int {
+	return a.addLink(parent, body, ArticulationPrismatic, body.GetCenterOfMassWorld(), axis)
+}
+
+// AddFixed adds the body as a link welded to the parent given and returns the
+// index of the new link. The derived data of the bodies must be up to date and
+// the articulation must not be in a world.
+func (a *Articulation) AddFixed(parent int, body *RigidBody) int {
+	return a.addLink(parent, body, ArticulationFixed, body.GetCenterOfMassWorld(), m.Vector3{})
+}
+
+// addLink adds a link of the type given with the joint at the anchor.
+func (a *Articulation) addLink(parent int, body *RigidBody, jointType ArticulationJointType, anchor m.Vector3, axis m.Vector3) int {
+	parentBody := a.Links[parent].Body
+	l := &ArticulationLink{Body: body, Parent: parent, Type: jointType}
+	l.anchors[0] = worldPointToBody(parentBody, &anchor)
+	l.anchors[1] = worldPointToBody(body, &anchor)
+	axis.Normalize()
+	l.axis = worldDirectionToBody(parentBody, &axis)
+	l.rest = parentBody.Orientation.Conjugated()
+	l.rest.Mul(&body.Orientation)
+
+	a.Links = append(a.Links, l)
+	a.filter.join(body, parentBody)
+	return len(a.Links) - 1
+}
+
+// AddArticulation adds the bodies of the articulation to the world, where
+// they're moved by the articulation instead of being integrated on their own.
+// Their colliders have to be added separately; contacts between a link and
+// its parent are ignored.
+func (w *World) AddArticulation(a *Articulation) {
+	if a.world != nil {
+		return
+	}
+	a.world = w
+	for _, l := range a.Links {
+		l.Body.articulated = true
+		l.Body.CanSleep = false
+		l.Body.SetAwake(true)
+		w.AddBody(l.Body)
+	}
+	w.Articulations = append(w.Articulations, a)
+	w.setCollisionFilter(a.filter, a.bodies())
+	a.updateLinks()
+}
+
+// RemoveArticulation removes the articulation and the bodies of its links
+// from the world.
+func (w *World) RemoveArticulation(a *Articulation) {
+	if a.world != w {
+		return
+	}
+	a.world = nil
+	w.clearCollisionFilter(a.filter, a.bodies())
+	for _, l := range a.Links {
+		l.Body.articulated = false
+		w.RemoveBody(l.Body)
+	}
+	for i, existing := range w.Articulations {
+		if existing == a {
+			w.Articulations = append(w.Articulations[:i], w.Articulations[i+1:]...)
+			break
+		}
+	}
+}
+
+// bodies returns the bodies of the links.
+func (a *Articulation) bodies() []*RigidBody {
+	bodies := make([]*RigidBody, 0, len(a.Links))
+	for _, l := range a.Links {
+		bodies = append(bodies, l.Body)
+	}
+	return bodies
+}
+
+// stepArticulations advances the articulations in the world by the duration given.
+func (w *World) stepArticulations(duration m.Real) {
+	for _, a := range w.Articulations {
+		a.step(duration)
+	}
+}
+
+// syncArticulations passes the changes the contacts made to the bodies of the
+// articulations back through their joints.
+func (w *World) syncArticulations() {
+	for _, a := range w.Articulations {
+		a.sync()
+	}
+}
+
+// updateLinks places the bodies of the links from the pose of the root and
+// the joint coordinates, and updates their velocities and spatial motions.
+func (a *Articulation) updateLinks() {
+	root := a.Links[0]
+	root.Body.CalculateDerivedData()
+	if a.FixedBase {
+		root.Body.Velocity.Clear()
+		root.Body.Rotation.Clear()
+	}
+	root.velocity = bodySpatialVelocity(root.Body)
+
+	for _, l := range a.Links[1:] {
+		parent := a.Links[l.Parent]
+		body, parentBody := l.Body, parent.Body
+
+		// place the link so that its anchor meets the anchor on the parent
+		anchor := l.anchors[0]
+		body.Orientation = parentBody.Orientation
+		switch l.Type {
+		case ArticulationRevolute:
+			turn := m.QuatFromAxis(l.Position, l.axis[0], l.axis[1], l.axis[2])
+			body.Orientation.Mul(&turn)
+		case ArticulationPrismatic:
+			anchor.AddScaled(&l.axis, l.Position)
+		}
+		body.Orientation.Mul(&l.rest)
+		body.Orientation.Normalize()
+		worldAnchor := parentBody.transform.MulVector3(&anchor)
+		offset := body.Orientation.Rotate(&l.anchors[1])
+		body.Position = worldAnchor
+		body.Position.Sub(&offset)
+		body.CalculateDerivedData()
+
+		// the spatial motion of the joint
+		axis := parentBody.transform.TransformDirection(&l.axis)
+		switch l.Type {
+		case ArticulationRevolute:
+			moment := worldAnchor.Cross(&axis)
+			l.motion = newSpatialVector(&axis, &moment)
+		case ArticulationPrismatic:
+			l.motion = newSpatialVector(&m.Vector3{}, &axis)
+		default:
+			l.motion = spatialVector{}
+		}
+
+		l.velocity = parent.velocity
+		l.velocity.addScaled(&l.motion, l.Velocity)
+
+		// the velocity of the center of mass from the spatial velocity
+		body.Rotation = l.velocity.angular()
+		com := body.GetCenterOfMassWorld()
+		body.Velocity = body.Rotation.Cross(&com)
+		linear := l.velocity.linear()
+		body.Velocity.Add(&linear)
+	}
+}
+
+// solve runs the articulated-body algorithm with the inertia, bias force,
+// bias acceleration and joint effort already set on each link, leaving the
+// acceleration of each joint in the links and returning that of the root.
+func (a *Articulation) solve() spatialVector {
+	// pass the inertia and forces of each link back towards the root
+	for i := len(a.Links) - 1; i > 0; i-- {
+		l := a.Links[i]
+		parent := a.Links[l.Parent]
+		inertia := l.inertia
+		force := l.force
+
+		if l.Type != ArticulationFixed {
+			l.projected = l.inertia.mulVector(&l.motion)
+			l.stiffness = l.motion.dot(&l.projected)
+			l.effort -= l.motion.dot(&l.force)
+			if l.stiffness > m.Epsilon {
+				inertia.subOuter(&l.projected, 1.0/l.stiffness)
+				force.addScaled(&l.projected, l.effort/l.stiffness)
+			}
+		}
+		biasForce := inertia.mulVector(&l.bias)
+		force.addScaled(&biasForce, 1.0)
+
+		parent.inertia.add(&inertia)
+		parent.force.addScaled(&force, 1.0)
+	}
+
+	// then work out the accelerations out from the root
+	root := a.Links[0]
+	root.acceleration = spatialVector{}
+	if !a.FixedBase {
+		var pull spatialVector
+		pull.addScaled(&root.force, -1.0)
+		root.acceleration, _ = root.inertia.solve(pull)
+	}
+	for _, l := range a.Links[1:] {
+		l.acceleration = a.Links[l.Parent].acceleration
+		l.acceleration.addScaled(&l.bias, 1.0)
+		l.jointAcceleration = 0.0
+		if l.Type != ArticulationFixed && l.stiffness > m.Epsilon {
+			l.jointAcceleration = (l.effort - l.projected.dot(&l.acceleration)) / l.stiffness
+			l.acceleration.addScaled(&l.motion, l.jointAcceleration)
+		}
+	}
+	return root.acceleration
+}
+
+// step works out the accelerations of the joints under gravity, the forces
+// on the bodies and the actuators, and then integrates the joint coordinates
+// and the root with semi-implicit Euler integration.
+func (a *Articulation) step(duration m.Real) {
+	a.updateLinks()
+	for i, l := range a.Links {
+		body := l.Body
+		if i == 0 && a.FixedBase {
+			l.inertia = spatialMatrix{}
+			l.force = spatialVector{}
+			continue
+		}
+
+		// the bias force is the gyroscopic force less the external forces
+		l.inertia = bodySpatialInertia(body)
+		momentum := l.inertia.mulVector(&l.velocity)
+		l.force = l.velocity.crossForce(&momentum)
+
+		com := body.GetCenterOfMassWorld()
+		push := body.GetGravity()
+		push.MulWith(body.GetMass())
+		push.Add(&body.forceAccum)
+		torque := com.Cross(&push)
+		torque.Add(&body.torqueAccum)
+		external := newSpatialVector(&torque, &push)
+		l.force.addScaled(&external, -1.0)
+
+		l.bias = spatialVector{}
+		l.effort = 0.0
+		if i > 0 {
+			var jointVelocity spatialVector
+			jointVelocity.addScaled(&l.motion, l.Velocity)
+			l.bias = l.velocity.crossMotion(&jointVelocity)
+			l.effort = l.Force - l.Damping*l.Velocity
+		}
+	}
+	rootAcceleration := a.solve()
+
+	for _, l := range a.Links {
+		l.Body.prevPosition = l.Body.Position
+		l.Body.prevOrientation = l.Body.Orientation
+		l.Body.prevVelocity = l.Body.Velocity
+		l.Body.lastDuration = duration
+		l.Body.ClearAccumulators()
+	}
+
+	for _, l := range a.Links[1:] {
+		if l.Type != ArticulationFixed {
+			l.Velocity += l.jointAcceleration * duration
+			l.Position += l.Velocity * duration
+		}
+	}
+	if !a.FixedBase {
+		root := a.Links[0]
+		root.velocity.addScaled(&rootAcceleration, duration)
+		moveRoot(root.Body, &root.velocity, duration)
+	}
+
+	a.updateLinks()
+	for _, l := range a.Links {
+		l.before = articulationState{l.Body.Position, l.Body.Orientation, l.Body.Velocity, l.Body.Rotation}
+	}
+}
+
+// moveRoot moves the body along by the spatial velocity given for the
+// duration given and then sets its velocity to match at the new pose.
+func moveRoot(body *RigidBody, velocity *spatialVector, duration m.Real) {
+	setSpatialVelocity(body, velocity)
+	com := body.GetCenterOfMassWorld()
+	com.AddScaled(&body.Velocity, duration)
+	body.Orientation.AddScaledVector(&body.Rotation, duration)
+	body.Orientation.Normalize()
+	body.setCenterOfMassWorld(&com)
+
+	// the center of mass moved, so its velocity changes with the same
+	// spatial velocity
+	setSpatialVelocity(body, velocity)
+}
+
+// sync takes the impulses and displacements the contacts gave each body
+// since the step and passes them through the joints, so that pushing on one
+// link moves the whole articulation.
+func (a *Articulation) sync() {
+	changed := false
+	for _, l := range a.Links {
+		if l.Body.Velocity != l.before.velocity || l.Body.Rotation != l.before.rotation ||
+			l.Body.Position != l.before.position || l.Body.Orientation != l.before.orientation {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+
+	// work out the changes of each body as spatial motions and put the
+	// bodies back to how they were before the contacts
+	velocityChanges := make([]spatialVector, len(a.Links))
+	displacements := make([]spatialVector, len(a.Links))
+	for i, l := range a.Links {
+		body := l.Body
+		velocityChanges[i] = bodySpatialVelocity(body)
+		com := body.GetCenterOfMassWorld()
+		orientation := body.Orientation
+
+		body.Position, body.Orientation = l.before.position, l.before.orientation
+		body.Velocity, body.Rotation = l.before.velocity, l.before.rotation
+		body.CalculateDerivedData()
+		beforeVelocity := bodySpatialVelocity(body)
+		velocityChanges[i].addScaled(&beforeVelocity, -1.0)
+
+		// the displacement is the small rotation and the movement of the
+		// point of the body at the origin
+		beforeCom := body.GetCenterOfMassWorld()
+		inverse := body.Orientation.Conjugated()
+		orientation.Mul(&inverse)
+		turn := rotationVector(&orientation)
+		com.Sub(&beforeCom)
+		moved := beforeCom.Cross(&turn)
+		moved.Add(&com)
+		displacements[i] = newSpatialVector(&turn, &moved)
+	}
+	if a.FixedBase {
+		velocityChanges[0] = spatialVector{}
+		displacements[0] = spatialVector{}
+	}
+
+	// the same change through the joints is found with the algorithm, where
+	// the impulse on each body is its inertia times its change
+	rootVelocity := a.applyChanges(velocityChanges)
+	for _, l := range a.Links[1:] {
+		l.Velocity += l.jointAcceleration
+	}
+	rootDisplacement := a.applyChanges(displacements)
+	for _, l := range a.Links[1:] {
+		l.Position += l.jointAcceleration
+	}
+
+	if !a.FixedBase {
+		root := a.Links[0]
+		velocity := bodySpatialVelocity(root.Body)
+		velocity.addScaled(&rootVelocity, 1.0)
+		moveRoot(root.Body, &rootDisplacement, 1.0)
+		setSpatialVelocity(root.Body, &velocity)
+	}
+	a.updateLinks()
+	for _, l := range a.Links {
+		l.before = articulationState{l.Body.Position, l.Body.Orientation, l.Body.Velocity, l.Body.Rotation}
+	}
+}
+
+// applyChanges runs the algorithm with the impulses that would give each body
+// the change in velocity given, leaving the change of each joint in the links
+// and returning the change of the root.
+func (a *Articulation) applyChanges(changes []spatialVector) spatialVector {
+	for i, l := range a.Links {
+		l.bias = spatialVector{}
+		l.effort = 0.0
+		if i == 0 && a.FixedBase {
+			l.inertia = spatialMatrix{}
+			l.force = spatialVector{}
+			continue
+		}
+		l.inertia = bodySpatialInertia(l.Body)
+		impulse := l.inertia.mulVector(&changes[i])
+		l.force = spatialVector{}
+		l.force.addScaled(&impulse, -1.0)
+	}
+	return a.solve()
+}
+
+// setSpatialVelocity sets the velocity of the body from the spatial velocity given.
+func setSpatialVelocity(body *RigidBody, velocity *spatialVector) {
+	body.Rotation = velocity.angular()
+	com := body.GetCenterOfMassWorld()
+	body.Velocity = body.Rotation.Cross(&com)
+	linear := velocity.linear()
+	body.Velocity.Add(&linear)
+}
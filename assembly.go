@@ -0,0 +1,321 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// Assembly is a group of bodies held together by joints, such as a ragdoll or
+// a vehicle. A World can freeze an assembly into a single proxy body when it's
+// asleep or far away, which removes all of its joints from the simulation,
+// and thaw it back into its parts when it's needed again.
+type Assembly struct {
+	// Bodies holds the bodies that make up the assembly.
+	Bodies []*RigidBody
+
+	// Joints holds the joints that hold the bodies together.
+	Joints []*Joint
+
+	// Constraints holds the hinges, sliders and other constraints that hold
+	// the bodies together, which are taken out of the world along with the
+	// joints while the assembly is frozen.
+	Constraints []Constraint
+
+	// proxy is the body that stands in for the assembly while it's frozen.
+	proxy *RigidBody
+
+	// parts holds the transform of each body relative to the proxy while
+	// the assembly is frozen.
+	parts []assemblyPart
+}
+
+// assemblyPart holds the transform of a body relative to its assembly's proxy.
+type assemblyPart struct {
+	position    m.Vector3
+	orientation m.Quat
+}
+
+// AssemblyLOD holds the settings used by World.UpdateAssemblyLOD to decide when
+// assemblies are frozen and thawed.
+type AssemblyLOD struct {
+	// FreezeDistance is the distance from the viewer beyond which an assembly
+	// is frozen. A value of zero or less disables freezing by distance.
+	FreezeDistance m.Real
+
+	// ThawDistance is the distance from the viewer within which a frozen
+	// assembly is thawed. It should be less than FreezeDistance so that
+	// assemblies don't flip back and forth at the boundary.
+	ThawDistance m.Real
+
+	// FreezeWhenAsleep freezes assemblies whose bodies are all asleep.
+	FreezeWhenAsleep bool
+
+	// Static freezes assemblies into a static proxy that doesn't move at all
+	// instead of a rigid proxy that keeps moving as a single body.
+	Static bool
+}
+
+// NewAssembly creates a new Assembly of the bodies and joints given.
+func NewAssembly(bodies []*RigidBody, joints []*Joint) *Assembly {
+	a := new(Assembly)
+	a.Bodies = bodies
+	a.Joints = joints
+	return a
+}
+
+// IsFrozen returns true if the assembly has been collapsed into a proxy.
+func (a *Assembly) IsFrozen() bool {
+	return a.proxy != nil
+}
+
+// GetProxy returns the body that stands in for the assembly while it's frozen,
+// or nil if it's not frozen.
+func (a *Assembly) GetProxy() *RigidBody {
+	return a.proxy
+}
+
+// IsAsleep returns true if all of the bodies of the assembly are asleep.
+func (a *Assembly) IsAsleep() bool {
+	for _, body := range a.Bodies {
+		if body.IsAwake {
+			return false
+		}
+	}
+	return true
+}
+
+// GetCenterOfMass returns the World Space center of mass of the assembly.
+func (a *Assembly) GetCenterOfMass() m.Vector3 {
+	if a.proxy != nil {
+		return a.proxy.Position
+	}
+
+	var com m.Vector3
+	var total m.Real
+	for _, body := range a.Bodies {
+		mass := body.GetMass()
+		if !body.HasFiniteMass() {
+			mass = 1.0
+		}
+		bodyCOM := body.GetCenterOfMassWorld()
+		com.AddScaled(&bodyCOM, mass)
+		total += mass
+	}
+	if total > 0.0 {
+		com.MulWith(1.0 / total)
+	}
+	return com
+}
+
+// buildProxy creates the proxy body with the combined mass properties and
+// momentum of the bodies and records each body's transform relative to it.
+func (a *Assembly) buildProxy(static bool) {
+	proxy := NewRigidBody()
+	proxy.Position = a.GetCenterOfMass()
+	proxy.CanSleep = true
+
+	var inertia m.Matrix3
+	var momentum, angularMomentum m.Vector3
+	var total m.Real
+	finite := true
+	for _, body := range a.Bodies {
+		if !body.HasFiniteMass() {
+			finite = false
+			continue
+		}
+		mass := body.GetMass()
+		total += mass
+
+		// rotate the body's inertia tensor into World Space
+		bodyInverseInertia := body.GetInverseInertiaTensorWorld()
+		bodyInertia := bodyInverseInertia.Invert()
+		inertia.Add(&bodyInertia)
+
+		// and move it to be about the proxy with the parallel axis theorem
+		d := body.GetCenterOfMassWorld()
+		d.Sub(&proxy.Position)
+		dd := d.SquareMagnitude()
+		var shift m.Matrix3
+		shift.SetInertiaTensorCoeffs(mass*(dd-d[0]*d[0]), mass*(dd-d[1]*d[1]), mass*(dd-d[2]*d[2]),
+			mass*d[0]*d[1], mass*d[0]*d[2], mass*d[1]*d[2])
+		inertia.Add(&shift)
+
+		// sum up the linear and angular momentum about the proxy
+		momentum.AddScaled(&body.Velocity, mass)
+		spin := bodyInertia.MulVector3(&body.Rotation)
+		angularMomentum.Add(&spin)
+		orbit := d.Cross(&body.Velocity)
+		angularMomentum.AddScaled(&orbit, mass)
+	}
+
+	if static || !finite || total <= 0.0 {
+		proxy.SetInfiniteMass()
+		proxy.InverseInertiaTensor = m.Matrix3{}
+	} else {
+		proxy.SetMass(total)
+		proxy.InverseInertiaTensor = inertia.Invert()
+		proxy.Velocity = momentum
+		proxy.Velocity.MulWith(1.0 / total)
+		proxy.Rotation = proxy.InverseInertiaTensor.MulVector3(&angularMomentum)
+		proxy.SetAwake(!a.IsAsleep())
+	}
+	proxy.CalculateDerivedData()
+
+	a.parts = make([]assemblyPart, len(a.Bodies))
+	for i, body := range a.Bodies {
+		a.parts[i].position = body.Position
+		a.parts[i].position.Sub(&proxy.Position)
+		a.parts[i].orientation = body.Orientation
+	}
+	a.proxy = proxy
+}
+
+// syncParts moves the bodies of a frozen assembly to follow its proxy.
+func (a *Assembly) syncParts() {
+	for i, body := range a.Bodies {
+		offset := a.proxy.Orientation.Rotate(&a.parts[i].position)
+		body.Position = a.proxy.Position
+		body.Position.Add(&offset)
+		body.Orientation = a.proxy.Orientation
+		body.Orientation.Mul(&a.parts[i].orientation)
+		body.CalculateDerivedData()
+	}
+}
+
+// FreezeAssembly collapses the assembly into a single proxy body. Its bodies,
+// joints and constraints are removed from the simulation and the bodies follow the proxy.
+// Their colliders stay in the world and their contacts are applied to the
+// proxy. If static is true, the proxy doesn't move at all.
+func (w *World) FreezeAssembly(a *Assembly, static bool) {
+	if a.IsFrozen() {
+		return
+	}
+	a.buildProxy(static)
+
+	if w.proxies == nil {
+		w.proxies = make(map[*RigidBody]*RigidBody)
+	}
+	for _, body := range a.Bodies {
+		w.RemoveBody(body)
+		w.proxies[body] = a.proxy
+	}
+	for _, j := range a.Joints {
+		w.RemoveJoint(j)
+	}
+	for _, c := range a.Constraints {
+		w.RemoveConstraint(c)
+	}
+	if a.proxy.HasFiniteMass() {
+		w.AddBody(a.proxy)
+	}
+	w.frozen = append(w.frozen, a)
+}
+
+// ThawAssembly expands a frozen assembly back into its bodies, joints and
+// constraints, giving each body the motion it has as part of the proxy.
+func (w *World) ThawAssembly(a *Assembly) {
+	if !a.IsFrozen() {
+		return
+	}
+	a.syncParts()
+
+	proxy := a.proxy
+	for _, body := range a.Bodies {
+		delete(w.proxies, body)
+		if proxy.HasFiniteMass() {
+			arm := body.GetCenterOfMassWorld()
+			arm.Sub(&proxy.Position)
+			body.Velocity = proxy.Rotation.Cross(&arm)
+			body.Velocity.Add(&proxy.Velocity)
+			body.Rotation = proxy.Rotation
+		}
+		body.SetTransform(&body.Position, &body.Orientation)
+		body.SetAwake(proxy.IsAwake)
+		w.AddBody(body)
+	}
+	for _, j := range a.Joints {
+		w.AddJoint(j)
+	}
+	for _, c := range a.Constraints {
+		w.AddConstraint(c)
+	}
+	w.RemoveBody(proxy)
+
+	for i, frozen := range w.frozen {
+		if frozen == a {
+			w.frozen = append(w.frozen[:i], w.frozen[i+1:]...)
+			break
+		}
+	}
+	a.proxy = nil
+	a.parts = nil
+}
+
+// UpdateAssemblyLOD freezes and thaws the assemblies given based on their
+// distance from the viewer and whether they're asleep.
+func (w *World) UpdateAssemblyLOD(assemblies []*Assembly, viewer *m.Vector3, lod *AssemblyLOD) {
+	for _, a := range assemblies {
+		toViewer := a.GetCenterOfMass()
+		toViewer.Sub(viewer)
+		distance := toViewer.Magnitude()
+
+		far := lod.FreezeDistance > 0.0 && distance > lod.FreezeDistance
+		if a.IsFrozen() {
+			// thaw when the viewer comes close or when something wakes up
+			// an assembly that was only frozen because it was asleep
+			near := lod.FreezeDistance > 0.0 && distance < lod.ThawDistance
+			woken := lod.FreezeWhenAsleep && a.proxy.IsAwake && a.proxy.HasFiniteMass()
+			if near || (woken && !far) {
+				w.ThawAssembly(a)
+			}
+			continue
+		}
+
+		if far || (lod.FreezeWhenAsleep && a.IsAsleep()) {
+			w.FreezeAssembly(a, lod.Static)
+		}
+	}
+}
+
+// proxyOf returns the proxy standing in for the body if it's part of a frozen
+// assembly, or the body itself otherwise.
+func (w *World) proxyOf(body *RigidBody) *RigidBody {
+	if body == nil || w.proxies == nil {
+		return body
+	}
+	if proxy, ok := w.proxies[body]; ok {
+		return proxy
+	}
+	return body
+}
+
+// syncFrozenAssemblies moves the bodies of all frozen assemblies to follow
+// their proxies.
+func (w *World) syncFrozenAssemblies() {
+	for _, a := range w.frozen {
+		a.syncParts()
+	}
+}
+
+// remapContactsToProxies replaces the bodies of frozen assemblies in the
+// contacts with their proxies, dropping any contacts that end up between two
+// immovable bodies.
+func (w *World) remapContactsToProxies(contacts []*Contact) []*Contact {
+	kept := contacts[:0]
+	for _, c := range contacts {
+		c.Bodies[0] = w.proxyOf(c.Bodies[0])
+		c.Bodies[1] = w.proxyOf(c.Bodies[1])
+		if !isMovable(c.Bodies[0]) && !isMovable(c.Bodies[1]) {
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+// isMovable returns true if the body exists and has finite mass.
+func isMovable(body *RigidBody) bool {
+	return body != nil && body.HasFiniteMass()
+}
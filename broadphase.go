@@ -0,0 +1,525 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"sort"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// Bounds is an axis aligned bounding box in World Space.
+type Bounds = m.AABB
+
+// InfiniteBounds returns Bounds that cover all of space, such as for a plane.
+func InfiniteBounds() Bounds {
+	return Bounds{
+		Min: m.Vector3{-m.MaxValue, -m.MaxValue, -m.MaxValue},
+		Max: m.Vector3{m.MaxValue, m.MaxValue, m.MaxValue},
+	}
+}
+
+// ColliderBounds returns the Bounds of the collider using its current derived data.
+func ColliderBounds(c Collider) Bounds {
+	var b Bounds
+	switch shape := c.(type) {
+	case *CollisionSphere:
+		center := shape.transform.GetAxis(3)
+		b = m.AABBAround(&center, shape.Radius)
+	case *CollisionCube:
+		local := Bounds{Max: shape.HalfSize}
+		local.Min.Sub(&shape.HalfSize)
+		b = local.Transform(&shape.transform)
+	case *CollisionCapsule:
+		bottom, top := shape.Segment()
+		b = m.AABBAround(&bottom, shape.Radius)
+		end := m.AABBAround(&top, shape.Radius)
+		b.Merge(&end)
+	case *CollisionHeightfield:
+		b.Min = shape.Position
+		b.Max = shape.Position
+		b.Max[0] += m.Real(shape.Columns-1) * shape.CellSize
+		b.Max[2] += m.Real(shape.Rows-1) * shape.CellSize
+		for i, height := range shape.Heights {
+			if i == 0 || shape.Position[1]+height < b.Min[1] {
+				b.Min[1] = shape.Position[1] + height
+			}
+			if i == 0 || shape.Position[1]+height > b.Max[1] {
+				b.Max[1] = shape.Position[1] + height
+			}
+		}
+	case *CollisionTriangleMesh:
+		b = shape.BVH.Bounds()
+		b.Min.Add(&shape.Position)
+		b.Max.Add(&shape.Position)
+	case *wrapGhost:
+		b = shape.bounds
+	default:
+		b = InfiniteBounds()
+	}
+	return b
+}
+
+// ColliderBoundingSphere returns a sphere that bounds the collider using its
+// current derived data, which is cheaper to test against than its Bounds when
+// rejecting far away colliders. Planes get an infinite radius.
+func ColliderBoundingSphere(c Collider) m.Sphere {
+	switch shape := c.(type) {
+	case *CollisionSphere:
+		return m.Sphere{Center: shape.transform.GetAxis(3), Radius: shape.Radius}
+	case *CollisionCube:
+		return m.Sphere{Center: shape.transform.GetAxis(3), Radius: shape.HalfSize.Magnitude()}
+	case *CollisionCapsule:
+		return m.Sphere{Center: shape.transform.GetAxis(3), Radius: shape.HalfHeight + shape.Radius}
+	case *CollisionHeightfield, *CollisionTriangleMesh:
+		b := ColliderBounds(c)
+		return m.SphereAroundAABB(&b)
+	}
+	return m.Sphere{Radius: m.InfPos}
+}
+
+// ColliderPair is a pair of colliders whose bounds overlap.
+type ColliderPair struct {
+	One Collider
+	Two Collider
+}
+
+// Broadphase finds the pairs of colliders that might be touching so that only
+// those get checked for contacts. Implementations may report pairs whose bounds
+// don't overlap, but must report every pair whose ColliderBounds do overlap,
+// each pair only once and never a collider paired with itself.
+// CheckBroadphase can be used to test an implementation.
+type Broadphase interface {
+	// Insert adds the collider. Inserting a collider that's already in the
+	// broadphase does nothing.
+	Insert(c Collider)
+
+	// Remove removes the collider. Removing a collider that isn't in the
+	// broadphase does nothing.
+	Remove(c Collider)
+
+	// Update refreshes the bounds of the collider after it has moved.
+	Update(c Collider)
+
+	// QueryPairs appends the pairs of colliders that might be touching to the
+	// slice given and returns it.
+	QueryPairs(pairs []ColliderPair) []ColliderPair
+
+	// Raycast appends the colliders that a normalized ray might hit within
+	// the distance given to the slice given and returns it.
+	Raycast(origin *m.Vector3, direction *m.Vector3, maxDistance m.Real, candidates []Collider) []Collider
+
+	// QueryBounds appends the colliders that might overlap the bounds to the
+	// slice given and returns it.
+	QueryBounds(bounds *Bounds, candidates []Collider) []Collider
+}
+
+// IncrementalBroadphase is a Broadphase that keeps the pairs it found between
+// queries, so that it can report which pairs started and stopped overlapping.
+type IncrementalBroadphase interface {
+	Broadphase
+
+	// PairChanges appends the pairs that started overlapping in the last
+	// call to QueryPairs to added, and the pairs that stopped overlapping,
+	// or lost a collider to Remove, to removed, and returns them both.
+	PairChanges(added []ColliderPair, removed []ColliderPair) ([]ColliderPair, []ColliderPair)
+}
+
+// broadphaseEntry is a collider along with its bounds.
+type broadphaseEntry struct {
+	collider Collider
+	bounds   Bounds
+
+	// seq is the order the entry was inserted in, used to give the
+	// colliders of a pair the same order each time it's found.
+	seq uint64
+
+	// moved is set by the SweepAndPruneBroadphase when the bounds changed
+	// since its last query.
+	moved bool
+}
+
+// BruteForceBroadphase checks the bounds of every collider against every
+// other collider. It's simple and fine for small worlds.
+type BruteForceBroadphase struct {
+	entries []broadphaseEntry
+}
+
+// NewBruteForceBroadphase creates a new, empty BruteForceBroadphase.
+func NewBruteForceBroadphase() *BruteForceBroadphase {
+	bp := new(BruteForceBroadphase)
+	return bp
+}
+
+// Insert adds the collider.
+func (bp *BruteForceBroadphase) Insert(c Collider) {
+	bp.entries = insertEntry(bp.entries, c)
+}
+
+// Remove removes the collider.
+func (bp *BruteForceBroadphase) Remove(c Collider) {
+	bp.entries = removeEntry(bp.entries, c)
+}
+
+// Update refreshes the bounds of the collider.
+func (bp *BruteForceBroadphase) Update(c Collider) {
+	updateEntry(bp.entries, c)
+}
+
+// QueryPairs appends the pairs of colliders with overlapping bounds.
+func (bp *BruteForceBroadphase) QueryPairs(pairs []ColliderPair) []ColliderPair {
+	for i := range bp.entries {
+		one := &bp.entries[i]
+		for j := i + 1; j < len(bp.entries); j++ {
+			two := &bp.entries[j]
+			if one.bounds.Overlaps(&two.bounds) {
+				pairs = append(pairs, ColliderPair{one.collider, two.collider})
+			}
+		}
+	}
+	return pairs
+}
+
+// Raycast appends the colliders whose bounds are hit by the ray.
+func (bp *BruteForceBroadphase) Raycast(origin *m.Vector3, direction *m.Vector3, maxDistance m.Real, candidates []Collider) []Collider {
+	return raycastEntries(bp.entries, origin, direction, maxDistance, candidates)
+}
+
+// QueryBounds appends the colliders whose bounds overlap the bounds given.
+func (bp *BruteForceBroadphase) QueryBounds(bounds *Bounds, candidates []Collider) []Collider {
+	return queryEntries(bp.entries, bounds, candidates)
+}
+
+// SweepAndPruneBroadphase keeps the colliders sorted along the X axis so that
+// only colliders that overlap along it need their bounds checked. Since the
+// order changes little between steps, sorting stays cheap. The pairs found
+// are kept, so that a query only looks for the pairs of the colliders that
+// moved since the last one, and so that it's an IncrementalBroadphase.
+type SweepAndPruneBroadphase struct {
+	entries []broadphaseEntry
+	nextSeq uint64
+
+	// index maps each collider to its entry.
+	index map[Collider]int
+
+	// moved counts the entries whose bounds changed since the last query,
+	// and resweep is set when a collider was inserted or removed, so the
+	// next query sorts and sweeps every entry again.
+	moved   int
+	resweep bool
+
+	// pairs holds the pairs found by the last query.
+	pairs []ColliderPair
+
+	// before holds the pairs the last query looked for again as they were
+	// before it, and after the ones it found in their place; the pairs that
+	// started and stopped overlapping are the difference between them.
+	before []ColliderPair
+	after  []ColliderPair
+
+	// overlapping is kept to work out the difference in.
+	overlapping map[ColliderPair]struct{}
+
+	// wide is kept to hold the entries too wide to be found by looking back
+	// along the axis from a moved entry.
+	wide []int
+}
+
+// NewSweepAndPruneBroadphase creates a new, empty SweepAndPruneBroadphase.
+func NewSweepAndPruneBroadphase() *SweepAndPruneBroadphase {
+	bp := new(SweepAndPruneBroadphase)
+	bp.index = make(map[Collider]int)
+	return bp
+}
+
+// Insert adds the collider.
+func (bp *SweepAndPruneBroadphase) Insert(c Collider) {
+	if _, ok := bp.index[c]; ok {
+		return
+	}
+	bp.index[c] = len(bp.entries)
+	bp.entries = append(bp.entries, broadphaseEntry{collider: c, bounds: ColliderBounds(c), seq: bp.nextSeq})
+	bp.nextSeq++
+	bp.resweep = true
+}
+
+// Remove removes the collider.
+func (bp *SweepAndPruneBroadphase) Remove(c Collider) {
+	i, ok := bp.index[c]
+	if !ok {
+		return
+	}
+	if bp.entries[i].moved {
+		bp.moved--
+	}
+	delete(bp.index, c)
+	bp.entries = append(bp.entries[:i], bp.entries[i+1:]...)
+	for j := i; j < len(bp.entries); j++ {
+		bp.index[bp.entries[j].collider] = j
+	}
+	bp.resweep = true
+}
+
+// Update refreshes the bounds of the collider.
+func (bp *SweepAndPruneBroadphase) Update(c Collider) {
+	i, ok := bp.index[c]
+	if !ok {
+		return
+	}
+	e := &bp.entries[i]
+	bounds := ColliderBounds(c)
+	if bounds == e.bounds {
+		return
+	}
+	e.bounds = bounds
+	if !e.moved {
+		e.moved = true
+		bp.moved++
+	}
+}
+
+// QueryPairs appends the pairs with overlapping bounds. Only the pairs of the
+// colliders that moved since the last query are looked for, unless colliders
+// were inserted or removed or more than a quarter of them moved, which sorts
+// and sweeps them all again. If nothing has changed since the last query, the
+// pairs it found are given again.
+func (bp *SweepAndPruneBroadphase) QueryPairs(pairs []ColliderPair) []ColliderPair {
+	bp.before = bp.before[:0]
+	bp.after = bp.after[:0]
+	switch {
+	case bp.resweep || bp.moved*4 > len(bp.entries):
+		bp.sweep()
+	case bp.moved > 0:
+		bp.sweepMoved()
+	}
+	for i := range bp.entries {
+		bp.entries[i].moved = false
+	}
+	bp.moved = 0
+	bp.resweep = false
+	return append(pairs, bp.pairs...)
+}
+
+// sweep sorts every entry along the X axis and finds all of the pairs again.
+func (bp *SweepAndPruneBroadphase) sweep() {
+	sort.Stable((*entriesAlongX)(&bp.entries))
+	for i := range bp.entries {
+		bp.index[bp.entries[i].collider] = i
+	}
+
+	bp.before = append(bp.before, bp.pairs...)
+	bp.pairs = bp.pairs[:0]
+	for i := range bp.entries {
+		one := &bp.entries[i]
+		for j := i + 1; j < len(bp.entries); j++ {
+			two := &bp.entries[j]
+			if two.bounds.Min[0] > one.bounds.Max[0] {
+				// nothing further along the axis can overlap this one
+				break
+			}
+			if one.bounds.Overlaps(&two.bounds) {
+				bp.pairs = append(bp.pairs, newEntryPair(one, two))
+			}
+		}
+	}
+	bp.after = append(bp.after, bp.pairs...)
+}
+
+// sweepMoved moves the entries that moved back into order along the X axis,
+// keeps the pairs between entries that didn't move, and finds the pairs of
+// the ones that did.
+func (bp *SweepAndPruneBroadphase) sweepMoved() {
+	// the entries are nearly in order, so an insertion sort only shifts the
+	// ones that moved past their neighbours
+	entries := bp.entries
+	for i := 1; i < len(entries); i++ {
+		for j := i; j > 0 && entries[j].bounds.Min[0] < entries[j-1].bounds.Min[0]; j-- {
+			entries[j], entries[j-1] = entries[j-1], entries[j]
+			bp.index[entries[j].collider] = j
+			bp.index[entries[j-1].collider] = j - 1
+		}
+	}
+
+	kept := bp.pairs[:0]
+	for _, p := range bp.pairs {
+		if entries[bp.index[p.One]].moved || entries[bp.index[p.Two]].moved {
+			bp.before = append(bp.before, p)
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	bp.pairs = kept
+
+	// an entry that starts further back along the axis than the reach can't
+	// overlap a moved entry unless it's one of the wide ones, such as planes
+	// and terrain, which are checked on their own. Any reach finds the same
+	// pairs; twice the average width keeps both lists short.
+	var total m.Real
+	for i := range entries {
+		if width := entries[i].bounds.Max[0] - entries[i].bounds.Min[0]; width <= m.MaxValue {
+			total += width
+		}
+	}
+	reach := 2.0 * total / m.Real(len(entries))
+	bp.wide = bp.wide[:0]
+	for i := range entries {
+		if !(entries[i].bounds.Max[0]-entries[i].bounds.Min[0] <= reach) {
+			bp.wide = append(bp.wide, i)
+		}
+	}
+
+	for k := range entries {
+		one := &entries[k]
+		if !one.moved {
+			continue
+		}
+		for j := k + 1; j < len(entries) && entries[j].bounds.Min[0] <= one.bounds.Max[0]; j++ {
+			bp.addMovedPair(one, &entries[j])
+		}
+		for j := k - 1; j >= 0 && entries[j].bounds.Min[0] >= one.bounds.Min[0]-reach; j-- {
+			if entries[j].bounds.Max[0]-entries[j].bounds.Min[0] <= reach {
+				bp.addMovedPair(one, &entries[j])
+			}
+		}
+		for _, j := range bp.wide {
+			if j < k {
+				bp.addMovedPair(one, &entries[j])
+			}
+		}
+	}
+}
+
+// addMovedPair adds the pair of a moved entry and another one if their bounds
+// overlap. A pair of two moved entries is only added from the one inserted
+// first, so that it isn't added twice.
+func (bp *SweepAndPruneBroadphase) addMovedPair(moved *broadphaseEntry, other *broadphaseEntry) {
+	if other.moved && other.seq < moved.seq {
+		return
+	}
+	if !moved.bounds.Overlaps(&other.bounds) {
+		return
+	}
+	p := newEntryPair(moved, other)
+	bp.pairs = append(bp.pairs, p)
+	bp.after = append(bp.after, p)
+}
+
+// newEntryPair returns the pair of the colliders of the entries, with the one
+// inserted first as One so that a pair is the same each time it's found.
+func newEntryPair(one *broadphaseEntry, two *broadphaseEntry) ColliderPair {
+	if two.seq < one.seq {
+		return ColliderPair{two.collider, one.collider}
+	}
+	return ColliderPair{one.collider, two.collider}
+}
+
+// PairChanges appends the pairs that started and stopped overlapping in the
+// last query. They're only worked out when asked for, so a world with no
+// subscribers to the pair events doesn't pay for them.
+func (bp *SweepAndPruneBroadphase) PairChanges(added []ColliderPair, removed []ColliderPair) ([]ColliderPair, []ColliderPair) {
+	if len(bp.before) == 0 && len(bp.after) == 0 {
+		return added, removed
+	}
+	if bp.overlapping == nil {
+		bp.overlapping = make(map[ColliderPair]struct{})
+	}
+	for p := range bp.overlapping {
+		delete(bp.overlapping, p)
+	}
+	for _, p := range bp.before {
+		bp.overlapping[p] = struct{}{}
+	}
+	for _, p := range bp.after {
+		if _, ok := bp.overlapping[p]; !ok {
+			added = append(added, p)
+		}
+	}
+
+	for p := range bp.overlapping {
+		delete(bp.overlapping, p)
+	}
+	for _, p := range bp.after {
+		bp.overlapping[p] = struct{}{}
+	}
+	for _, p := range bp.before {
+		if _, ok := bp.overlapping[p]; !ok {
+			removed = append(removed, p)
+		}
+	}
+	return added, removed
+}
+
+// Raycast appends the colliders whose bounds are hit by the ray.
+func (bp *SweepAndPruneBroadphase) Raycast(origin *m.Vector3, direction *m.Vector3, maxDistance m.Real, candidates []Collider) []Collider {
+	return raycastEntries(bp.entries, origin, direction, maxDistance, candidates)
+}
+
+// QueryBounds appends the colliders whose bounds overlap the bounds given.
+func (bp *SweepAndPruneBroadphase) QueryBounds(bounds *Bounds, candidates []Collider) []Collider {
+	return queryEntries(bp.entries, bounds, candidates)
+}
+
+// entriesAlongX sorts entries by the start of their bounds along the X axis.
+// Its methods take a pointer so that sorting doesn't allocate.
+type entriesAlongX []broadphaseEntry
+
+func (e *entriesAlongX) Len() int           { return len(*e) }
+func (e *entriesAlongX) Less(i, j int) bool { return (*e)[i].bounds.Min[0] < (*e)[j].bounds.Min[0] }
+func (e *entriesAlongX) Swap(i, j int)      { (*e)[i], (*e)[j] = (*e)[j], (*e)[i] }
+
+// insertEntry appends an entry for the collider if there isn't one already.
+func insertEntry(entries []broadphaseEntry, c Collider) []broadphaseEntry {
+	for _, e := range entries {
+		if e.collider == c {
+			return entries
+		}
+	}
+	return append(entries, broadphaseEntry{collider: c, bounds: ColliderBounds(c)})
+}
+
+// removeEntry removes the entry for the collider, keeping the order of the rest.
+func removeEntry(entries []broadphaseEntry, c Collider) []broadphaseEntry {
+	for i, e := range entries {
+		if e.collider == c {
+			return append(entries[:i], entries[i+1:]...)
+		}
+	}
+	return entries
+}
+
+// updateEntry recalculates the bounds in the entry for the collider and
+// returns true if they changed.
+func updateEntry(entries []broadphaseEntry, c Collider) bool {
+	for i := range entries {
+		if entries[i].collider == c {
+			bounds := ColliderBounds(c)
+			if bounds == entries[i].bounds {
+				return false
+			}
+			entries[i].bounds = bounds
+			return true
+		}
+	}
+	return false
+}
+
+// raycastEntries appends the colliders whose bounds are hit by the ray.
+func raycastEntries(entries []broadphaseEntry, origin *m.Vector3, direction *m.Vector3, maxDistance m.Real, candidates []Collider) []Collider {
+	for i := range entries {
+		if entries[i].bounds.IntersectsRay(origin, direction, maxDistance) {
+			candidates = append(candidates, entries[i].collider)
+		}
+	}
+	return candidates
+}
+
+// queryEntries appends the colliders whose bounds overlap the bounds given.
+func queryEntries(entries []broadphaseEntry, bounds *Bounds, candidates []Collider) []Collider {
+	for i := range entries {
+		if entries[i].bounds.Overlaps(bounds) {
+			candidates = append(candidates, entries[i].collider)
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,239 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"fmt"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// CheckBroadphase runs a set of conformance checks against the Broadphase
+// implementations made by the function given and returns an error describing
+// the first failure. Call it from a test to check a custom broadphase.
+func CheckBroadphase(newBroadphase func() Broadphase) error {
+	checks := []struct {
+		name  string
+		check func(bp Broadphase) error
+	}{
+		{"pairs", checkBroadphasePairs},
+		{"update", checkBroadphaseUpdate},
+		{"remove", checkBroadphaseRemove},
+		{"raycast", checkBroadphaseRaycast},
+		{"bounds", checkBroadphaseBounds},
+		{"changes", checkBroadphaseChanges},
+	}
+	for _, c := range checks {
+		if err := c.check(newBroadphase()); err != nil {
+			return fmt.Errorf("broadphase %s check failed: %v", c.name, err)
+		}
+	}
+	return nil
+}
+
+// hasPair returns true if the pair, in either order, is in the pairs.
+func hasPair(pairs []ColliderPair, one Collider, two Collider) bool {
+	for _, p := range pairs {
+		if (p.One == one && p.Two == two) || (p.One == two && p.Two == one) {
+			return true
+		}
+	}
+	return false
+}
+
+// newConformanceSpheres makes spheres of radius one in a row along the X axis,
+// spaced so that each one only overlaps its neighbours.
+func newConformanceSpheres(count int) []Collider {
+	var spheres []Collider
+	for i := 0; i < count; i++ {
+		body := NewRigidBody()
+		body.Position = m.Vector3{m.Real(i) * 1.5, 0.0, 0.0}
+		body.CalculateDerivedData()
+		s := NewCollisionSphere(body, 1.0)
+		s.CalculateDerivedData()
+		spheres = append(spheres, s)
+	}
+	return spheres
+}
+
+// verifyPairs checks that the pairs reported by the broadphase include all of the
+// colliders with overlapping bounds, without duplicates or self pairs.
+func verifyPairs(bp Broadphase, colliders []Collider, removed []Collider) error {
+	pairs := bp.QueryPairs(nil)
+	seen := make(map[ColliderPair]bool)
+	for _, p := range pairs {
+		if p.One == p.Two {
+			return fmt.Errorf("a collider was paired with itself")
+		}
+		for _, r := range removed {
+			if p.One == r || p.Two == r {
+				return fmt.Errorf("a removed collider was paired")
+			}
+		}
+		if seen[p] || seen[ColliderPair{p.Two, p.One}] {
+			return fmt.Errorf("a pair was reported more than once")
+		}
+		seen[p] = true
+	}
+
+	for i, one := range colliders {
+		for _, two := range colliders[i+1:] {
+			b1, b2 := ColliderBounds(one), ColliderBounds(two)
+			if b1.Overlaps(&b2) && !seen[ColliderPair{one, two}] && !seen[ColliderPair{two, one}] {
+				return fmt.Errorf("an overlapping pair was missed")
+			}
+		}
+	}
+	return nil
+}
+
+func checkBroadphasePairs(bp Broadphase) error {
+	spheres := newConformanceSpheres(8)
+	for _, s := range spheres {
+		bp.Insert(s)
+	}
+	// inserting again must not duplicate anything
+	bp.Insert(spheres[0])
+	return verifyPairs(bp, spheres, nil)
+}
+
+func checkBroadphaseUpdate(bp Broadphase) error {
+	spheres := newConformanceSpheres(8)
+	for _, s := range spheres {
+		bp.Insert(s)
+	}
+	bp.QueryPairs(nil)
+
+	// move the last sphere on top of the first
+	last := spheres[len(spheres)-1].(*CollisionSphere)
+	last.Body.Position = m.Vector3{0.5, 0.5, 0.0}
+	last.Body.CalculateDerivedData()
+	last.CalculateDerivedData()
+	bp.Update(last)
+	return verifyPairs(bp, spheres, nil)
+}
+
+func checkBroadphaseRemove(bp Broadphase) error {
+	spheres := newConformanceSpheres(8)
+	for _, s := range spheres {
+		bp.Insert(s)
+	}
+	bp.QueryPairs(nil)
+
+	removed := []Collider{spheres[2], spheres[5]}
+	for _, r := range removed {
+		bp.Remove(r)
+	}
+	// removing again must be harmless
+	bp.Remove(spheres[2])
+
+	var remaining []Collider
+	for i, s := range spheres {
+		if i != 2 && i != 5 {
+			remaining = append(remaining, s)
+		}
+	}
+	return verifyPairs(bp, remaining, removed)
+}
+
+func checkBroadphaseRaycast(bp Broadphase) error {
+	spheres := newConformanceSpheres(8)
+	for _, s := range spheres {
+		bp.Insert(s)
+	}
+	bp.Remove(spheres[3])
+
+	origin := m.Vector3{-5.0, 0.0, 0.0}
+	direction := m.Vector3{1.0, 0.0, 0.0}
+	hits := bp.Raycast(&origin, &direction, 10.0, nil)
+	found := make(map[Collider]bool)
+	for _, h := range hits {
+		if h == spheres[3] {
+			return fmt.Errorf("a removed collider was returned by a raycast")
+		}
+		found[h] = true
+	}
+	for i, s := range spheres {
+		b := ColliderBounds(s)
+		if i != 3 && b.IntersectsRay(&origin, &direction, 10.0) && !found[s] {
+			return fmt.Errorf("a collider in the path of the ray was missed")
+		}
+	}
+
+	return nil
+}
+
+func checkBroadphaseBounds(bp Broadphase) error {
+	spheres := newConformanceSpheres(8)
+	for _, s := range spheres {
+		bp.Insert(s)
+	}
+	bp.Remove(spheres[4])
+
+	query := Bounds{Min: m.Vector3{2.0, -0.5, -0.5}, Max: m.Vector3{6.0, 0.5, 0.5}}
+	found := make(map[Collider]bool)
+	for _, c := range bp.QueryBounds(&query, nil) {
+		if c == spheres[4] {
+			return fmt.Errorf("a removed collider was returned by a bounds query")
+		}
+		if found[c] {
+			return fmt.Errorf("a collider was returned more than once by a bounds query")
+		}
+		found[c] = true
+	}
+	for i, s := range spheres {
+		b := ColliderBounds(s)
+		if i != 4 && b.Overlaps(&query) && !found[s] {
+			return fmt.Errorf("a collider overlapping the bounds was missed")
+		}
+	}
+
+	return nil
+}
+
+func checkBroadphaseChanges(bp Broadphase) error {
+	ib, ok := bp.(IncrementalBroadphase)
+	if !ok {
+		return nil
+	}
+	spheres := newConformanceSpheres(8)
+	for _, s := range spheres {
+		bp.Insert(s)
+	}
+	bp.QueryPairs(nil)
+	added, removed := ib.PairChanges(nil, nil)
+	if len(added) != len(spheres)-1 || len(removed) != 0 {
+		return fmt.Errorf("the first query should add every overlapping pair")
+	}
+
+	// nothing changed, so nothing starts or stops
+	bp.QueryPairs(nil)
+	added, removed = ib.PairChanges(nil, nil)
+	if len(added) != 0 || len(removed) != 0 {
+		return fmt.Errorf("a query without changes reported changed pairs")
+	}
+
+	// move the last sphere on top of the first
+	last := spheres[len(spheres)-1].(*CollisionSphere)
+	last.Body.Position = m.Vector3{0.5, 0.5, 0.0}
+	last.Body.CalculateDerivedData()
+	last.CalculateDerivedData()
+	bp.Update(last)
+	bp.QueryPairs(nil)
+	added, removed = ib.PairChanges(nil, nil)
+	if !hasPair(added, last, spheres[0]) || hasPair(added, spheres[0], spheres[1]) {
+		return fmt.Errorf("the pairs a moved collider started were wrong")
+	}
+	if len(removed) != 1 || !hasPair(removed, last, spheres[len(spheres)-2]) {
+		return fmt.Errorf("the pairs a moved collider ended were wrong")
+	}
+
+	bp.Remove(spheres[3])
+	bp.QueryPairs(nil)
+	added, removed = ib.PairChanges(nil, nil)
+	if len(added) != 0 || len(removed) != 2 || !hasPair(removed, spheres[2], spheres[3]) || !hasPair(removed, spheres[3], spheres[4]) {
+		return fmt.Errorf("the pairs of a removed collider weren't ended")
+	}
+	return nil
+}
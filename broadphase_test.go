@@ -0,0 +1,290 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"testing"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+func TestBroadphaseConformance(t *testing.T) {
+	if err := CheckBroadphase(func() Broadphase { return NewBruteForceBroadphase() }); err != nil {
+		t.Errorf("BruteForceBroadphase: %v", err)
+	}
+	if err := CheckBroadphase(func() Broadphase { return NewSweepAndPruneBroadphase() }); err != nil {
+		t.Errorf("SweepAndPruneBroadphase: %v", err)
+	}
+}
+
+func TestWorldBroadphaseMatchesAllPairs(t *testing.T) {
+	reference, _ := newTestPile()
+	pruned, _ := newTestPile()
+	pruned.SetBroadphase(NewSweepAndPruneBroadphase())
+
+	for step := 0; step < 120; step++ {
+		reference.Step(0.01)
+		pruned.Step(0.01)
+		for i, body := range reference.Bodies {
+			if body.Position != pruned.Bodies[i].Position {
+				t.Fatalf("step %d: body %d is at %v with the broadphase and %v without", step, i, pruned.Bodies[i].Position, body.Position)
+			}
+		}
+	}
+}
+
+func TestWorldBroadphaseSeesResizedColliders(t *testing.T) {
+	// two resting shapes that start apart and are then grown into each
+	// other without either of them moving
+	newPair := func(broadphase Broadphase) (*World, *CollisionSphere, *CollisionCube) {
+		w := NewWorld()
+		if broadphase != nil {
+			w.SetBroadphase(broadphase)
+		}
+		sphere := newTestSphere(m.Vector3{0.0, 0.0, 0.0})
+		cube := NewCollisionCube(nil, m.Vector3{0.5, 0.5, 0.5})
+		cube.Body.Position = m.Vector3{3.0, 0.0, 0.0}
+		cube.Body.CalculateDerivedData()
+		cube.CalculateDerivedData()
+		for _, body := range []*RigidBody{sphere.Body, cube.Body} {
+			body.GravityScale = 0.0
+			body.SetAwake(false)
+		}
+		w.AddCollider(sphere)
+		w.AddCollider(cube)
+		return w, sphere, cube
+	}
+
+	for _, grow := range []string{"sphere", "cube"} {
+		reference, refSphere, refCube := newPair(nil)
+		pruned, sphere, cube := newPair(NewSweepAndPruneBroadphase())
+		if contacts := pruned.Step(0.01); len(contacts) != 0 {
+			t.Fatalf("The shapes touched before the %s was grown", grow)
+		}
+		reference.Step(0.01)
+
+		if grow == "sphere" {
+			refSphere.Radius, sphere.Radius = 2.8, 2.8
+		} else {
+			refCube.HalfSize[0], cube.HalfSize[0] = 3.0, 3.0
+		}
+		expected := len(reference.Step(0.01))
+		if got := len(pruned.Step(0.01)); expected == 0 || got != expected {
+			t.Errorf("Growing the %s gave %d contacts with the broadphase and %d without", grow, got, expected)
+		}
+	}
+}
+
+func TestWorldParallelNarrowphase(t *testing.T) {
+	// a pile big enough that its pairs are split across the workers
+	newPile := func() *World {
+		w := NewWorld()
+		w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+		for i := 0; i < 75; i++ {
+			w.AddCollider(newTestSphere(m.Vector3{m.Real(i%5) * 0.9, 0.6 + m.Real(i/25)*0.9, m.Real(i/5%5) * 0.9}))
+		}
+		w.SetBroadphase(NewSweepAndPruneBroadphase())
+		return w
+	}
+	reference := newPile()
+	parallel := newPile()
+	parallel.Workers = 4
+
+	for step := 0; step < 60; step++ {
+		reference.Step(0.01)
+		parallel.Step(0.01)
+		for i, body := range reference.Bodies {
+			if body.Position != parallel.Bodies[i].Position {
+				t.Fatalf("step %d: body %d is at %v with 4 workers and %v with one", step, i, parallel.Bodies[i].Position, body.Position)
+			}
+		}
+	}
+	if len(parallel.workerPools) < 2 {
+		t.Errorf("The narrowphase didn't run across the workers")
+	}
+}
+
+func TestColliderBoundingSphere(t *testing.T) {
+	cube := NewCollisionCube(nil, m.Vector3{1.0, 2.0, 0.5})
+	cube.Body.Position = m.Vector3{3.0, 1.0, -2.0}
+	cube.Body.Orientation = m.QuatFromAxis(0.7, 1.0, 1.0, 0.0)
+	cube.Body.CalculateDerivedData()
+	cube.CalculateDerivedData()
+
+	sphere := ColliderBoundingSphere(cube)
+	for i := 0; i < 8; i++ {
+		corner := cube.HalfSize
+		for axis := 0; axis < 3; axis++ {
+			if i&(1<<uint(axis)) != 0 {
+				corner[axis] = -corner[axis]
+			}
+		}
+		corner = cube.transform.MulVector3(&corner)
+		offset := corner
+		offset.Sub(&sphere.Center)
+		if offset.Magnitude() > sphere.Radius+1e-9 {
+			t.Errorf("Corner %v is outside the bounding sphere %v", corner, sphere)
+		}
+	}
+
+	plane := NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0)
+	if sphere := ColliderBoundingSphere(plane); sphere.Radius != m.InfPos {
+		t.Errorf("A plane should have an infinite bounding sphere but had %v", sphere)
+	}
+}
+
+func TestWorldSkipsUnmovedColliders(t *testing.T) {
+	w := NewWorld()
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	resting := newTestSphere(m.Vector3{-3.0, 0.5, 0.0})
+	resting.Body.SetAwake(false)
+	w.AddCollider(resting)
+	falling := newTestSphere(m.Vector3{0.0, 2.0, 0.0})
+	w.AddCollider(falling)
+	w.SetBroadphase(NewSweepAndPruneBroadphase())
+
+	restingVersion := resting.derivedVersion()
+	fallingVersion := falling.derivedVersion()
+	for step := 0; step < 30; step++ {
+		w.Step(0.01)
+	}
+	if resting.derivedVersion() != restingVersion {
+		t.Errorf("A sleeping collider had its derived data recalculated")
+	}
+	if falling.derivedVersion() == fallingVersion {
+		t.Errorf("A moving collider didn't have its derived data recalculated")
+	}
+
+	// moving the sleeping body by hand still reaches the broadphase
+	resting.Body.Position = falling.Body.Position
+	resting.Body.Position[0] += 0.5
+	resting.Body.CalculateDerivedData()
+	w.Step(0.01)
+	if resting.derivedVersion() == restingVersion {
+		t.Errorf("A collider moved by hand wasn't recalculated")
+	}
+	found := false
+	for _, c := range w.GetContacts() {
+		if (c.Bodies[0] == resting.Body || c.Bodies[1] == resting.Body) && (c.Bodies[0] == falling.Body || c.Bodies[1] == falling.Body) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("The broadphase missed the collider moved into the other")
+	}
+}
+
+func TestWorldPairEvents(t *testing.T) {
+	w, spheres := newTestPile()
+	bp := NewSweepAndPruneBroadphase()
+	w.SetBroadphase(bp)
+
+	overlapping := make(map[ColliderPair]bool)
+	w.Events.Subscribe(EventPairBegin, 0, func(e *Event) {
+		p := ColliderPair{e.Colliders[0], e.Colliders[1]}
+		if overlapping[p] {
+			t.Errorf("A pair began twice")
+		}
+		overlapping[p] = true
+	})
+	w.Events.Subscribe(EventPairEnd, 0, func(e *Event) {
+		p := ColliderPair{e.Colliders[0], e.Colliders[1]}
+		if !overlapping[p] {
+			t.Errorf("A pair ended that hadn't begun")
+		}
+		delete(overlapping, p)
+	})
+
+	for step := 0; step < 120; step++ {
+		w.Step(0.01)
+		pairs := bp.QueryPairs(nil)
+		if len(pairs) != len(overlapping) {
+			t.Fatalf("step %d: %d pairs overlap but the events left %d", step, len(pairs), len(overlapping))
+		}
+	}
+
+	// removing a collider ends its pairs
+	w.RemoveCollider(spheres[0])
+	w.Step(0.01)
+	for p := range overlapping {
+		if p.One == spheres[0] || p.Two == spheres[0] {
+			t.Errorf("A pair with a removed collider didn't end")
+		}
+	}
+}
+
+func TestSweepAndPruneMovedPairs(t *testing.T) {
+	// spheres scattered over terrain wider than all of them, with a plane
+	bp := NewSweepAndPruneBroadphase()
+	quiet := NewSweepAndPruneBroadphase()
+	reference := NewBruteForceBroadphase()
+	ground := NewCollisionCube(nil, m.Vector3{40.0, 1.0, 40.0})
+	ground.Body.Position = m.Vector3{0.0, -1.0, 0.0}
+	ground.Body.CalculateDerivedData()
+	ground.CalculateDerivedData()
+	colliders := []Collider{NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, -2.0), ground}
+	var spheres []*CollisionSphere
+	for i := 0; i < 40; i++ {
+		s := newTestSphere(m.Vector3{m.Real((i*7)%23) - 11.0, m.Real(i%3) * 0.8, m.Real((i*5)%11) - 5.0})
+		s.Radius = 0.5 + m.Real(i%4)*0.3
+		s.CalculateDerivedData()
+		spheres = append(spheres, s)
+		colliders = append(colliders, s)
+	}
+	for _, c := range colliders {
+		bp.Insert(c)
+		quiet.Insert(c)
+		reference.Insert(c)
+	}
+
+	overlapping := make(map[ColliderPair]bool)
+	for round := 0; round < 60; round++ {
+		// move a few of the spheres, sometimes past many others
+		for i := 0; i < 1+round%4; i++ {
+			s := spheres[(round*13+i*7)%len(spheres)]
+			s.Body.Position[0] += m.Real((round*17+i*5)%9) - 4.0
+			s.Body.Position[2] += m.Real((round*11+i*3)%5) - 2.0
+			s.Body.CalculateDerivedData()
+			s.CalculateDerivedData()
+			bp.Update(s)
+			quiet.Update(s)
+			reference.Update(s)
+		}
+
+		pairs := bp.QueryPairs(nil)
+		expected := reference.QueryPairs(nil)
+		if len(pairs) != len(expected) {
+			t.Fatalf("Round %d found %d pairs; expected %d", round, len(pairs), len(expected))
+		}
+		for _, p := range expected {
+			if !hasPair(pairs, p.One, p.Two) {
+				t.Fatalf("Round %d missed a pair", round)
+			}
+		}
+		if quietPairs := quiet.QueryPairs(nil); len(quietPairs) != len(expected) {
+			t.Fatalf("Round %d found %d pairs without asking for changes; expected %d", round, len(quietPairs), len(expected))
+		}
+
+		// the changes keep track of the same pairs
+		added, removed := bp.PairChanges(nil, nil)
+		for _, p := range removed {
+			if !overlapping[p] {
+				t.Fatalf("Round %d ended a pair that hadn't begun", round)
+			}
+			delete(overlapping, p)
+		}
+		for _, p := range added {
+			if overlapping[p] {
+				t.Fatalf("Round %d began a pair twice", round)
+			}
+			overlapping[p] = true
+		}
+		if len(overlapping) != len(pairs) {
+			t.Fatalf("Round %d had %d pairs but the changes left %d", round, len(pairs), len(overlapping))
+		}
+	}
+	if quiet.overlapping != nil {
+		t.Errorf("The pair changes were worked out without being asked for")
+	}
+}
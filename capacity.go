@@ -0,0 +1,131 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+// WorldConfig holds capacity hints for a World, so that the slices, pools and
+// maps it uses each step are allocated once up front rather than growing
+// while the world runs. They're only hints: a world that outgrows them keeps
+// working and allocates what more it needs.
+type WorldConfig struct {
+	// MaxBodies is the number of bodies the world is expected to hold.
+	MaxBodies int
+
+	// MaxColliders is the number of colliders the world is expected to hold.
+	// Defaults to MaxBodies.
+	MaxColliders int
+
+	// MaxContacts is the number of contacts expected in a step.
+	MaxContacts int
+
+	// MaxPairs is the number of pairs of colliders the broadphase is
+	// expected to find in a step.
+	MaxPairs int
+}
+
+// broadphaseReserver is a Broadphase that can allocate room for the
+// colliders and pairs given up front.
+type broadphaseReserver interface {
+	reserve(colliders int, pairs int)
+}
+
+// NewWorldWithConfig creates a new World like NewWorld with room reserved for
+// the capacities in the config.
+func NewWorldWithConfig(config *WorldConfig) *World {
+	w := NewWorld()
+	w.Reserve(config)
+	return w
+}
+
+// Reserve allocates room for the capacities in the config, including in the
+// broadphase and in any broadphase set later. Room that's already been
+// allocated is never given back, so it can be called again to grow it.
+func (w *World) Reserve(config *WorldConfig) {
+	w.capacity = *config
+	if w.capacity.MaxColliders == 0 {
+		w.capacity.MaxColliders = w.capacity.MaxBodies
+	}
+	bodies, colliders := w.capacity.MaxBodies, w.capacity.MaxColliders
+	contacts, pairs := w.capacity.MaxContacts, w.capacity.MaxPairs
+
+	if cap(w.Bodies) < bodies {
+		w.Bodies = append(make([]*RigidBody, 0, bodies), w.Bodies...)
+	}
+	if w.bodyIDs == nil {
+		w.bodyIDs = make(map[*RigidBody]BodyID, bodies)
+	}
+	w.bodyHandles.reserve(bodies)
+
+	if cap(w.Colliders) < colliders {
+		w.Colliders = append(make([]Collider, 0, colliders), w.Colliders...)
+	}
+	if w.colliderIDs == nil {
+		w.colliderIDs = make(map[Collider]ColliderID, colliders)
+	}
+	if w.boundsVersions == nil {
+		w.boundsVersions = make(map[Collider]uint64, colliders)
+	}
+	if w.colliderOrder == nil {
+		w.colliderOrder = make(map[Collider]int, colliders)
+	}
+	w.colliderHandles.reserve(colliders)
+
+	w.contactPool.reserve(contacts)
+	if cap(w.contactOrder) < contacts {
+		w.contactOrder = make(contactOrder, 0, contacts)
+	}
+
+	if cap(w.pairs) < pairs {
+		w.pairs = make([]ColliderPair, 0, pairs)
+	}
+	if r, ok := w.broadphase.(broadphaseReserver); ok {
+		r.reserve(colliders, pairs)
+	}
+}
+
+// reserve allocates room for the number of slots given.
+func (t *handleTable) reserve(count int) {
+	if cap(t.slots) < count {
+		t.slots = append(make([]handleSlot, 0, count), t.slots...)
+	}
+}
+
+// reserve allocates the contacts given in one block, along with room for the
+// list of them, so the pool doesn't allocate until it has handed them all out.
+func (p *contactPool) reserve(count int) {
+	if len(p.contacts) >= count {
+		return
+	}
+	block := make([]Contact, count-len(p.contacts))
+	contacts := append(make([]*Contact, 0, count), p.contacts...)
+	for i := range block {
+		contacts = append(contacts, &block[i])
+	}
+	p.contacts = contacts
+	if cap(p.buffer) < count {
+		p.buffer = append(make([]*Contact, 0, count), p.buffer...)
+	}
+}
+
+// reserve allocates room for the colliders given.
+func (bp *BruteForceBroadphase) reserve(colliders int, pairs int) {
+	if cap(bp.entries) < colliders {
+		bp.entries = append(make([]broadphaseEntry, 0, colliders), bp.entries...)
+	}
+}
+
+// reserve allocates room for the colliders and pairs given.
+func (bp *SweepAndPruneBroadphase) reserve(colliders int, pairs int) {
+	if cap(bp.entries) < colliders {
+		bp.entries = append(make([]broadphaseEntry, 0, colliders), bp.entries...)
+	}
+	if cap(bp.pairs) < pairs {
+		bp.pairs = append(make([]ColliderPair, 0, pairs), bp.pairs...)
+	}
+	if cap(bp.before) < pairs {
+		bp.before = append(make([]ColliderPair, 0, pairs), bp.before...)
+	}
+	if cap(bp.after) < pairs {
+		bp.after = append(make([]ColliderPair, 0, pairs), bp.after...)
+	}
+}
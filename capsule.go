@@ -0,0 +1,463 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// maxCapsuleSamples is the most points along the segment of a capsule
+	// that are checked against the triangles of a heightfield or a mesh.
+	maxCapsuleSamples = 8
+)
+
+// CollisionCapsule is a rigid body that can be considered a capsule for
+// collision detection: the points within Radius of a segment that runs along
+// the local Y axis of the capsule from -HalfHeight to HalfHeight.
+type CollisionCapsule struct {
+	// Body is the RigidBody that is represented by this collision object.
+	Body *RigidBody
+
+	// Offset is the matrix that gives the offset of this primitive from Body.
+	Offset m.Matrix3x4
+
+	// transform is calculated by combining the Offset of the primitive with
+	// the transform of the Body.
+	// NOTE: this is calculated by calling CalculateDerivedData().
+	transform m.Matrix3x4
+
+	// derivedFrom, derivedOffset, derivedRadius and derivedHalfHeight hold
+	// the transform of the Body, the Offset and the size the derived data
+	// was last calculated from, and derivedCount counts the times it has
+	// been calculated.
+	derivedFrom       m.Matrix3x4
+	derivedOffset     m.Matrix3x4
+	derivedRadius     m.Real
+	derivedHalfHeight m.Real
+	derivedCount      uint64
+
+	// Radius is the radius of the capsule and of its rounded ends.
+	Radius m.Real
+
+	// HalfHeight is half the length of the segment between the centers of
+	// the rounded ends, so the capsule is 2*(HalfHeight+Radius) long.
+	HalfHeight m.Real
+
+	// Material holds the surface properties of the capsule. If nil, the
+	// DefaultMaterial is used.
+	Material *Material
+
+	// SurfaceVelocity is the velocity the surface of the capsule moves at
+	// across itself on top of the motion of its body. It's given in the
+	// local space of the capsule.
+	// Defaults to no motion.
+	SurfaceVelocity m.Vector3
+}
+
+/*
+==================================================================================================
+  COLLISION CAPSULE
+==================================================================================================
+*/
+
+// NewCollisionCapsule creates a new CollisionCapsule object with the radius
+// and half height specified for a given RigidBody. If a RigidBody is not
+// specified, then a new RigidBody object is created for the new collider
+// object.
+func NewCollisionCapsule(optBody *RigidBody, radius m.Real, halfHeight m.Real) *CollisionCapsule {
+	c := new(CollisionCapsule)
+	c.Offset.SetIdentity()
+	c.Radius = radius
+	c.HalfHeight = halfHeight
+	c.Body = optBody
+	if c.Body == nil {
+		c.Body = NewRigidBody()
+	}
+	return c
+}
+
+// Clone makes a new copy of the CollisionCapsule object
+func (c *CollisionCapsule) Clone() Collider {
+	var bClone *RigidBody
+	if c.Body != nil {
+		bClone = c.Body.Clone()
+	}
+	newCapsule := NewCollisionCapsule(bClone, c.Radius, c.HalfHeight)
+	newCapsule.Offset = c.Offset
+	newCapsule.transform = c.transform
+	newCapsule.Material = c.Material
+	newCapsule.SurfaceVelocity = c.SurfaceVelocity
+	return newCapsule
+}
+
+// GetTransform returns a copy of the transform matrix for the collider object.
+func (c *CollisionCapsule) GetTransform() m.Matrix3x4 {
+	return c.transform
+}
+
+// GetBody returns the rigid body associated with the capsule.
+func (c *CollisionCapsule) GetBody() *RigidBody {
+	return c.Body
+}
+
+// CalculateDerivedData internal data from public data members.
+//
+// Constructs a transform matrix based on the RigidBody's transform and the
+// collision object's offset.
+func (c *CollisionCapsule) CalculateDerivedData() {
+	c.derivedFrom = c.Body.GetTransform()
+	c.derivedOffset = c.Offset
+	c.derivedRadius = c.Radius
+	c.derivedHalfHeight = c.HalfHeight
+	c.derivedCount++
+	c.transform = c.derivedFrom.MulMatrix3x4(&c.Offset)
+}
+
+// refreshDerivedData calculates the derived data if the body, the Offset or
+// the size have changed since it was last calculated.
+func (c *CollisionCapsule) refreshDerivedData() {
+	if c.derivedCount == 0 || c.Body.transform != c.derivedFrom || c.Offset != c.derivedOffset ||
+		c.Radius != c.derivedRadius || c.HalfHeight != c.derivedHalfHeight {
+		c.CalculateDerivedData()
+	}
+}
+
+// derivedVersion returns a number that changes whenever the derived data is
+// calculated.
+func (c *CollisionCapsule) derivedVersion() uint64 {
+	return c.derivedCount
+}
+
+// GetMaterial returns the surface material of the capsule.
+func (c *CollisionCapsule) GetMaterial() *Material {
+	return materialOrDefault(c.Material)
+}
+
+// SetDensity sets the mass, center of mass and inertia tensor of the
+// capsule's RigidBody based on the volume of the capsule, placed at its
+// Offset, and the density given. For bodies with several colliders, the mass
+// of each collider SetDensity is called on is added up.
+func (c *CollisionCapsule) SetDensity(density m.Real) {
+	if c.Body == nil {
+		return
+	}
+	volume := math.Pi * c.Radius * c.Radius * (2.0*c.HalfHeight + 4.0/3.0*c.Radius)
+	var tensor m.Matrix3
+	tensor.SetCapsuleInertiaTensor(c.Radius, c.HalfHeight, volume*density)
+	props := offsetMassProperties(volume, density, &tensor, &c.Offset)
+	c.Body.setShapeMass(c, &props)
+}
+
+// Segment returns the centers of the two rounded ends of the capsule in World
+// Space, the bottom one first.
+func (c *CollisionCapsule) Segment() (m.Vector3, m.Vector3) {
+	center := c.transform.GetAxis(3)
+	axis := c.transform.GetAxis(1)
+	bottom, top := center, center
+	bottom.AddScaled(&axis, -c.HalfHeight)
+	top.AddScaled(&axis, c.HalfHeight)
+	return bottom, top
+}
+
+// sphereAt returns a sphere the size of the capsule's ends, on its body,
+// centered on a point of its segment, so that the sphere checks can be used
+// for the capsule.
+func (c *CollisionCapsule) sphereAt(center *m.Vector3) CollisionSphere {
+	var s CollisionSphere
+	s.Body = c.Body
+	s.Radius = c.Radius
+	s.Material = c.Material
+	s.transform = c.transform
+	s.transform[9], s.transform[10], s.transform[11] = center[0], center[1], center[2]
+	return s
+}
+
+// samples returns points spread along the segment of the capsule, no further
+// apart than its diameter where there are few enough of them, for checking
+// against surfaces that aren't flat.
+func (c *CollisionCapsule) samples(points []m.Vector3) []m.Vector3 {
+	bottom, top := c.Segment()
+	if c.HalfHeight <= m.Epsilon {
+		return append(points, lerpVector(&bottom, &top, 0.5))
+	}
+	count := 1
+	if c.Radius > 0.0 {
+		count = int(math.Ceil(float64(c.HalfHeight / c.Radius)))
+	}
+	if count < 1 {
+		count = 1
+	} else if count > maxCapsuleSamples-1 {
+		count = maxCapsuleSamples - 1
+	}
+	for i := 0; i <= count; i++ {
+		points = append(points, lerpVector(&bottom, &top, m.Real(i)/m.Real(count)))
+	}
+	return points
+}
+
+// CheckAgainstHalfSpace does a collision test on a collision capsule and a
+// plane representing a half-space, generating a contact for each end of the
+// capsule that is through the plane.
+func (c *CollisionCapsule) CheckAgainstHalfSpace(plane *CollisionPlane, existingContacts []*Contact) (bool, []*Contact) {
+	return c.checkHalfSpace(plane, existingContacts, nil)
+}
+
+// checkHalfSpace is CheckAgainstHalfSpace, taking the contacts from the pool.
+func (c *CollisionCapsule) checkHalfSpace(plane *CollisionPlane, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
+	bottom, top := c.Segment()
+	ends := [2]m.Vector3{bottom, top}
+	count := 2
+	if c.HalfHeight <= m.Epsilon {
+		count = 1
+	}
+
+	found := false
+	contacts := existingContacts
+	for i := 0; i < count; i++ {
+		s := c.sphereAt(&ends[i])
+		var hit bool
+		hit, contacts = s.checkHalfSpace(plane, contacts, pool)
+		found = found || hit
+	}
+	return found, contacts
+}
+
+// CheckAgainstSphere checks the capsule against collision with a sphere.
+func (c *CollisionCapsule) CheckAgainstSphere(sphere *CollisionSphere, existingContacts []*Contact) (bool, []*Contact) {
+	return c.checkSphere(sphere, existingContacts, nil)
+}
+
+// checkSphere is CheckAgainstSphere, taking the contacts from the pool.
+func (c *CollisionCapsule) checkSphere(sphere *CollisionSphere, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
+	bottom, top := c.Segment()
+	center := sphere.transform.GetAxis(3)
+	closest := closestPointOnSegment(&center, &bottom, &top)
+	s := c.sphereAt(&closest)
+	return s.checkSphere(sphere, existingContacts, pool)
+}
+
+// CheckAgainstCube checks the capsule against collision with a cube,
+// generating a contact for the deepest point of the segment in the cube and
+// for each end of the capsule that is touching it away from that point.
+func (c *CollisionCapsule) CheckAgainstCube(cube *CollisionCube, existingContacts []*Contact) (bool, []*Contact) {
+	return c.checkCube(cube, existingContacts, nil)
+}
+
+// checkCube is CheckAgainstCube, taking the contacts from the pool.
+func (c *CollisionCapsule) checkCube(cube *CollisionCube, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
+	bottom, top := c.Segment()
+	localBottom := cube.transform.TransformInverse(&bottom)
+	localTop := cube.transform.TransformInverse(&top)
+
+	// the signed distance to the cube is convex along the segment, so the
+	// deepest point can be narrowed down by thirds
+	low, high := m.Real(0.0), m.Real(1.0)
+	for i := 0; i < capsuleSearchSteps; i++ {
+		one := low + (high-low)/3.0
+		two := high - (high-low)/3.0
+		pointOne := lerpVector(&localBottom, &localTop, one)
+		pointTwo := lerpVector(&localBottom, &localTop, two)
+		distanceOne, _ := boxSignedDistance(&pointOne, &cube.HalfSize)
+		distanceTwo, _ := boxSignedDistance(&pointTwo, &cube.HalfSize)
+		if distanceOne < distanceTwo {
+			high = two
+		} else {
+			low = one
+		}
+	}
+	deepest := (low + high) * 0.5
+
+	// the ends keep a capsule lying on the cube from rocking about the
+	// deepest point, unless they're too close to it to make a difference
+	found := false
+	contacts := existingContacts
+	length := 2.0 * c.HalfHeight
+	for _, t := range [3]m.Real{deepest, 0.0, 1.0} {
+		if t != deepest && m.RealAbs(t-deepest)*length <= c.Radius {
+			continue
+		}
+		local := lerpVector(&localBottom, &localTop, t)
+		distance, localNormal := boxSignedDistance(&local, &cube.HalfSize)
+		if distance >= c.Radius {
+			continue
+		}
+
+		// the contact is on the surface of the cube under the point
+		point := local
+		point.AddScaled(&localNormal, -distance)
+
+		contact := pool.get()
+		contact.ContactPoint = cube.transform.MulVector3(&point)
+		contact.ContactNormal = cube.transform.TransformDirection(&localNormal)
+		contact.Penetration = c.Radius - distance
+		contact.Bodies[0] = c.Body
+		contact.Bodies[1] = cube.Body
+		contact.SetMaterials(c.GetMaterial(), cube.GetMaterial())
+		contacts = append(contacts, contact)
+		found = true
+	}
+	return found, contacts
+}
+
+// CheckAgainstCapsule checks the capsule against collision with another
+// capsule, generating a contact between the closest points of their segments
+// and for each end of this capsule touching the other away from them.
+func (c *CollisionCapsule) CheckAgainstCapsule(other *CollisionCapsule, existingContacts []*Contact) (bool, []*Contact) {
+	return c.checkCapsule(other, existingContacts, nil)
+}
+
+// checkCapsule is CheckAgainstCapsule, taking the contacts from the pool.
+func (c *CollisionCapsule) checkCapsule(other *CollisionCapsule, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
+	bottom, top := c.Segment()
+	otherBottom, otherTop := other.Segment()
+	closest, otherClosest := closestPointsOnSegments(&bottom, &top, &otherBottom, &otherTop)
+
+	found := false
+	contacts := existingContacts
+	for i, point := range [3]m.Vector3{closest, bottom, top} {
+		onOther := otherClosest
+		if i > 0 {
+			// capsules lying alongside each other need the ends to stay
+			// parallel, unless they're at the closest points already
+			apart := point
+			apart.Sub(&closest)
+			if apart.Magnitude() <= c.Radius {
+				continue
+			}
+			onOther = closestPointOnSegment(&point, &otherBottom, &otherTop)
+		}
+		s := c.sphereAt(&point)
+		otherSphere := other.sphereAt(&onOther)
+		var hit bool
+		hit, contacts = s.checkSphere(&otherSphere, contacts, pool)
+		found = found || hit
+	}
+	return found, contacts
+}
+
+// CheckAgainstHeightfield checks points along the capsule against the
+// triangles of a heightfield, generating a contact for each one touching it.
+func (c *CollisionCapsule) CheckAgainstHeightfield(hf *CollisionHeightfield, existingContacts []*Contact) (bool, []*Contact) {
+	return c.checkHeightfield(hf, existingContacts, nil)
+}
+
+// checkHeightfield is CheckAgainstHeightfield, taking the contacts from the pool.
+func (c *CollisionCapsule) checkHeightfield(hf *CollisionHeightfield, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
+	var buffer [maxCapsuleSamples]m.Vector3
+	found := false
+	contacts := existingContacts
+	for _, point := range c.samples(buffer[:0]) {
+		s := c.sphereAt(&point)
+		var hit bool
+		hit, contacts = s.checkHeightfield(hf, contacts, pool)
+		found = found || hit
+	}
+	return found, contacts
+}
+
+// CheckAgainstTriangleMesh checks points along the capsule against the
+// triangles of a mesh, generating a contact for each one touching it.
+func (c *CollisionCapsule) CheckAgainstTriangleMesh(mesh *CollisionTriangleMesh, existingContacts []*Contact) (bool, []*Contact) {
+	return c.checkTriangleMesh(mesh, existingContacts, nil)
+}
+
+// checkTriangleMesh is CheckAgainstTriangleMesh, taking the contacts from the pool.
+func (c *CollisionCapsule) checkTriangleMesh(mesh *CollisionTriangleMesh, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
+	var buffer [maxCapsuleSamples]m.Vector3
+	found := false
+	contacts := existingContacts
+	for _, point := range c.samples(buffer[:0]) {
+		s := c.sphereAt(&point)
+		var hit bool
+		hit, contacts = s.checkTriangleMesh(mesh, contacts, pool)
+		found = found || hit
+	}
+	return found, contacts
+}
+
+// CheckAgainstCapsule checks for collisions against a capsule.
+func (p *CollisionPlane) CheckAgainstCapsule(capsule *CollisionCapsule, existingContacts []*Contact) (bool, []*Contact) {
+	// use the capsule's implementation of the check
+	return capsule.CheckAgainstHalfSpace(p, existingContacts)
+}
+
+// CheckAgainstCapsule checks the sphere against collision with a capsule.
+func (s *CollisionSphere) CheckAgainstCapsule(capsule *CollisionCapsule, existingContacts []*Contact) (bool, []*Contact) {
+	// use the capsule's implementation of the check
+	return capsule.CheckAgainstSphere(s, existingContacts)
+}
+
+// CheckAgainstCapsule checks the cube against collision with a capsule.
+func (cube *CollisionCube) CheckAgainstCapsule(capsule *CollisionCapsule, existingContacts []*Contact) (bool, []*Contact) {
+	// use the capsule's implementation of the check
+	return capsule.CheckAgainstCube(cube, existingContacts)
+}
+
+// CheckAgainstCapsule checks for collisions against a capsule.
+func (hf *CollisionHeightfield) CheckAgainstCapsule(capsule *CollisionCapsule, existingContacts []*Contact) (bool, []*Contact) {
+	// use the capsule's implementation of the check
+	return capsule.CheckAgainstHeightfield(hf, existingContacts)
+}
+
+// CheckAgainstCapsule checks for collisions against a capsule.
+func (mesh *CollisionTriangleMesh) CheckAgainstCapsule(capsule *CollisionCapsule, existingContacts []*Contact) (bool, []*Contact) {
+	// use the capsule's implementation of the check
+	return capsule.CheckAgainstTriangleMesh(mesh, existingContacts)
+}
+
+// closestPointsOnSegments returns the points on two segments, the first
+// between start and end and the second between otherStart and otherEnd,
+// that are closest to each other.
+func closestPointsOnSegments(start, end, otherStart, otherEnd *m.Vector3) (m.Vector3, m.Vector3) {
+	d1 := *end
+	d1.Sub(start)
+	d2 := *otherEnd
+	d2.Sub(otherStart)
+	r := *start
+	r.Sub(otherStart)
+	a := d1.SquareMagnitude()
+	e := d2.SquareMagnitude()
+	f := d2.Dot(&r)
+
+	clamp := func(v m.Real) m.Real {
+		if v < 0.0 {
+			return 0.0
+		} else if v > 1.0 {
+			return 1.0
+		}
+		return v
+	}
+
+	var s, t m.Real
+	if a <= m.Epsilon && e <= m.Epsilon {
+		return *start, *otherStart
+	}
+	if a <= m.Epsilon {
+		t = clamp(f / e)
+	} else {
+		c := d1.Dot(&r)
+		if e <= m.Epsilon {
+			s = clamp(-c / a)
+		} else {
+			b := d1.Dot(&d2)
+			denom := a*e - b*b
+			// parallel segments can use any point, so start with the first
+			if denom > m.Epsilon {
+				s = clamp((b*f - c*e) / denom)
+			}
+			t = (b*s + f) / e
+			if t < 0.0 {
+				t = 0.0
+				s = clamp(-c / a)
+			} else if t > 1.0 {
+				t = 1.0
+				s = clamp((b - c) / a)
+			}
+		}
+	}
+	return lerpVector(start, end, s), lerpVector(otherStart, otherEnd, t)
+}
@@ -0,0 +1,380 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	defaultCharacterStepHeight = 0.3
+	defaultCharacterMaxSlope   = math.Pi / 4.0
+	defaultCharacterSkinWidth  = 0.01
+	defaultCharacterMass       = 80.0
+
+	// characterSlideIterations is the number of times a move can be turned
+	// along the surfaces it runs into.
+	characterSlideIterations = 4
+
+	// characterDepenetrations is the number of times the capsule is pushed
+	// out of the deepest collider it overlaps after each piece of a move.
+	characterDepenetrations = 8
+)
+
+// CharacterController moves a capsule through the world for a player or an
+// NPC without it being simulated: it goes where it's told to unless something
+// is in the way, sliding along walls, walking up slopes that aren't too steep
+// and stepping up onto ledges that aren't too high. It isn't a body, so it's
+// never knocked over or bounced around by the solver, but it pushes the
+// dynamic bodies it walks into.
+type CharacterController struct {
+	// Position is the center of the capsule in World Space.
+	Position m.Vector3
+
+	// Radius is the radius of the capsule.
+	Radius m.Real
+
+	// HalfHeight is half the distance between the centers of the spheres at
+	// the ends of the capsule.
+	HalfHeight m.Real
+
+	// Up is the direction the capsule stands along, opposite to gravity.
+	// Defaults to the Y axis.
+	Up m.Vector3
+
+	// StepHeight is the highest ledge the character steps up onto as it
+	// walks.
+	// Defaults to 0.3.
+	StepHeight m.Real
+
+	// MaxSlope is the steepest slope, in radians, the character can stand on
+	// and walk up.
+	// Defaults to 45 degrees.
+	MaxSlope m.Real
+
+	// SkinWidth is how close the capsule has to be to the ground to be
+	// standing on it.
+	// Defaults to 0.01.
+	SkinWidth m.Real
+
+	// Mass is the mass the character pushes dynamic bodies with.
+	// Defaults to 80.0.
+	Mass m.Real
+
+	// onGround is true if the character was standing on the ground at the
+	// end of the last move.
+	onGround bool
+
+	// ground holds the ground the character was standing on.
+	ground Overlap
+}
+
+// NewCharacterController creates a new CharacterController with its capsule
+// centered on the position given. The height is the full height of the
+// capsule, from the bottom of one end to the top of the other.
+func NewCharacterController(position m.Vector3, radius m.Real, height m.Real) *CharacterController {
+	cc := new(CharacterController)
+	cc.Position = position
+	cc.Radius = radius
+	cc.HalfHeight = height*0.5 - radius
+	if cc.HalfHeight < 0.0 {
+		cc.HalfHeight = 0.0
+	}
+	cc.Up = m.Vector3{0.0, 1.0, 0.0}
+	cc.StepHeight = defaultCharacterStepHeight
+	cc.MaxSlope = defaultCharacterMaxSlope
+	cc.SkinWidth = defaultCharacterSkinWidth
+	cc.Mass = defaultCharacterMass
+	return cc
+}
+
+// IsOnGround returns true if the character was standing on the ground at the
+// end of the last move.
+func (cc *CharacterController) IsOnGround() bool {
+	return cc.onGround
+}
+
+// GetGround returns the ground the character was standing on at the end of
+// the last move. Its Body is the body the character stands on, if any, such
+// as a moving platform.
+func (cc *CharacterController) GetGround() Overlap {
+	return cc.ground
+}
+
+// Overlaps returns the colliders in the world the capsule overlaps where it
+// is now.
+func (cc *CharacterController) Overlaps(w *World) []Overlap {
+	return cc.overlapsAt(w, &cc.Position, 0.0)
+}
+
+// overlapsAt returns the colliders the capsule would overlap at the position
+// given, with its radius grown by the margin given.
+func (cc *CharacterController) overlapsAt(w *World, position *m.Vector3, margin m.Real) []Overlap {
+	up := cc.up()
+	start, end := *position, *position
+	start.AddScaled(&up, -cc.HalfHeight)
+	end.AddScaled(&up, cc.HalfHeight)
+	return w.OverlapCapsule(&start, &end, cc.Radius+margin)
+}
+
+// up returns the normalized up direction.
+func (cc *CharacterController) up() m.Vector3 {
+	up := cc.Up
+	up.Normalize()
+	return up
+}
+
+// isWalkable returns true if the character can stand on a surface with the
+// normal given.
+func (cc *CharacterController) isWalkable(normal *m.Vector3) bool {
+	up := cc.up()
+	return normal.Dot(&up) >= m.Real(math.Cos(float64(cc.MaxSlope)))
+}
+
+// isGround returns true if the character can stand on what it overlaps. When
+// the capsule rests on an edge, such as the edge of a step, the direction out
+// of it doesn't say how steep the surface is, so the surface just past the
+// edge is checked with a short ray as well.
+func (cc *CharacterController) isGround(overlap *Overlap) bool {
+	if cc.isWalkable(&overlap.Normal) {
+		return true
+	}
+	up := cc.up()
+	if overlap.Normal.Dot(&up) <= 0.0 {
+		return false
+	}
+
+	// step just past the edge and look down at the surface there; a ray that
+	// starts inside the collider was cast from within a wall
+	across := overlap.Normal
+	across.AddScaled(&up, -across.Dot(&up))
+	across.Normalize()
+	origin := overlap.Point
+	origin.AddScaled(&across, -cc.SkinWidth)
+	origin.AddScaled(&up, cc.SkinWidth*2.0)
+	down := up
+	down.MulWith(-1.0)
+	hit, result := RaycastCollider(overlap.Collider, &origin, &down)
+	return hit && result.Distance > 0.0 && result.Distance <= cc.SkinWidth*3.0 && cc.isWalkable(&result.Normal)
+}
+
+// Move moves the character by the displacement given, which is the distance
+// it should travel in a step of the duration given, including any falling.
+// It slides along what it runs into, steps up onto low ledges, keeps to the
+// ground when walking down slopes and stairs and pushes the dynamic bodies it
+// walks into. It returns how far the character actually moved.
+func (cc *CharacterController) Move(w *World, displacement m.Vector3, duration m.Real) m.Vector3 {
+	start := cc.Position
+	up := cc.up()
+	vertical := up
+	vertical.MulWith(displacement.Dot(&up))
+	horizontal := displacement
+	horizontal.Sub(&vertical)
+	wasOnGround := cc.onGround
+
+	if horizontal.SquareMagnitude() > m.Epsilon {
+		from := cc.Position
+		cc.slide(w, &horizontal, duration, true)
+
+		// if the way was blocked, try stepping up over it and back down,
+		// keeping the step if it gets further and ends up higher
+		wanted := horizontal.Magnitude()
+		if progress := horizontalDistance(&from, &cc.Position, &up); wasOnGround && cc.StepHeight > 0.0 && progress < wanted*0.9 {
+			walked := cc.Position
+			cc.Position = from
+			lift := up
+			lift.MulWith(cc.StepHeight)
+			cc.slide(w, &lift, duration, false)
+			cc.slide(w, &horizontal, duration, false)
+			lift.MulWith(-1.0)
+			cc.slide(w, &lift, duration, false)
+
+			climbed := cc.Position
+			climbed.Sub(&from)
+			if climbed.Dot(&up) <= m.Epsilon || horizontalDistance(&from, &cc.Position, &up) <= progress+m.Epsilon {
+				cc.Position = walked
+			}
+		}
+	}
+
+	cc.slide(w, &vertical, duration, true)
+
+	// keep to the ground over the top of slopes and down stairs unless the
+	// character is jumping
+	probe := cc.SkinWidth * 2.0
+	if wasOnGround && vertical.Dot(&up) <= 0.0 {
+		probe += cc.StepHeight
+	}
+	cc.findGround(w, probe)
+
+	moved := cc.Position
+	moved.Sub(&start)
+	return moved
+}
+
+// horizontalDistance returns how far apart two points are across the up
+// direction.
+func horizontalDistance(from *m.Vector3, to *m.Vector3, up *m.Vector3) m.Real {
+	offset := *to
+	offset.Sub(from)
+	offset.AddScaled(up, -offset.Dot(up))
+	return offset.Magnitude()
+}
+
+// slide moves the capsule along the motion given in pieces no longer than half
+// its radius, pushing it out of what it runs into and turning what's left of
+// the motion along the surfaces. It returns true if the capsule ended up
+// resting on walkable ground.
+func (cc *CharacterController) slide(w *World, motion *m.Vector3, duration m.Real, push bool) bool {
+	up := cc.up()
+	remaining := *motion
+	falling := remaining.Dot(&up) < 0.0 && horizontalDistance(&m.Vector3{}, &remaining, &up) <= m.Epsilon
+	landed := false
+
+	for iteration := 0; iteration < characterSlideIterations; iteration++ {
+		distance := remaining.Magnitude()
+		if distance <= m.Epsilon {
+			break
+		}
+		pieces := int(math.Ceil(float64(distance / (cc.Radius * 0.5))))
+		if pieces < 1 {
+			pieces = 1
+		}
+		piece := remaining
+		piece.MulWith(1.0 / m.Real(pieces))
+
+		blocked := false
+		for i := 0; i < pieces && !blocked; i++ {
+			cc.Position.Add(&piece)
+			for _, overlap := range cc.depenetrate(w, &piece, duration, push, falling) {
+				ground := cc.isGround(&overlap)
+				if ground {
+					landed = true
+				}
+				normal := cc.slideNormal(&overlap.Normal, falling || ground)
+				if piece.Dot(&normal) >= -m.Epsilon {
+					continue
+				}
+
+				// turn what's left of the motion along the surface
+				blocked = true
+				remaining = piece
+				remaining.MulWith(m.Real(pieces - i - 1))
+				if falling && ground {
+					remaining.Clear()
+				} else {
+					remaining.AddScaled(&normal, -remaining.Dot(&normal))
+				}
+			}
+		}
+		if !blocked {
+			break
+		}
+	}
+	return landed
+}
+
+// slideNormal returns the normal of a surface that the motion is turned along.
+// Slopes that are too steep to walk up are treated as walls when the
+// character walks into them, so that it can't climb them; the full normal is
+// used for the ground and when the character is falling.
+func (cc *CharacterController) slideNormal(normal *m.Vector3, full bool) m.Vector3 {
+	result := *normal
+	up := cc.up()
+	if full || normal.Dot(&up) <= 0.0 {
+		return result
+	}
+	result.AddScaled(&up, -result.Dot(&up))
+	if result.SquareMagnitude() <= m.Epsilon {
+		return *normal
+	}
+	result.Normalize()
+	return result
+}
+
+// depenetrate pushes the capsule out of the colliders it overlaps, deepest
+// first, and returns what it was pushed out of. If push is true the dynamic
+// bodies that the piece of motion given runs into are pushed as well. If
+// falling is true the capsule is pushed straight up out of the ground so that
+// it comes to rest on the edges of ledges instead of sliding off them.
+func (cc *CharacterController) depenetrate(w *World, piece *m.Vector3, duration m.Real, push bool, falling bool) []Overlap {
+	up := cc.up()
+	var touched []Overlap
+	for i := 0; i < characterDepenetrations; i++ {
+		overlaps := cc.overlapsAt(w, &cc.Position, 0.0)
+		if len(overlaps) == 0 {
+			break
+		}
+		deepest := 0
+		for j := range overlaps {
+			if overlaps[j].Depth > overlaps[deepest].Depth {
+				deepest = j
+			}
+		}
+		overlap := overlaps[deepest]
+		if falling && cc.isGround(&overlap) {
+			cc.Position.AddScaled(&up, overlap.Depth/overlap.Normal.Dot(&up))
+		} else {
+			cc.Position.AddScaled(&overlap.Normal, overlap.Depth)
+		}
+		touched = append(touched, overlap)
+		if push {
+			cc.pushBody(&overlap, piece, duration)
+		}
+	}
+	return touched
+}
+
+// pushBody gives a dynamic body that the character runs into the impulse of an
+// inelastic collision with something of the character's Mass moving at the
+// speed of the piece of motion given.
+func (cc *CharacterController) pushBody(overlap *Overlap, piece *m.Vector3, duration m.Real) {
+	if overlap.Body == nil || !overlap.Body.HasFiniteMass() || cc.Mass <= 0.0 || duration <= 0.0 {
+		return
+	}
+
+	// the speed the character moves into the body faster than the body moves
+	// out of the way
+	bodies := [2]*RigidBody{nil, overlap.Body}
+	points := [2]m.Vector3{overlap.Point, overlap.Point}
+	inverseMass, speed := pointResponse(&bodies, &points, &overlap.Normal)
+	closing := -piece.Dot(&overlap.Normal)/duration + speed
+	if closing <= 0.0 {
+		return
+	}
+	applyPointImpulses(&bodies, &points, &overlap.Normal, -closing/(1.0/cc.Mass+inverseMass), nil)
+}
+
+// findGround looks for walkable ground within the distance given below the
+// capsule, and if the distance is more than the skin width the capsule is
+// moved down onto it.
+func (cc *CharacterController) findGround(w *World, distance m.Real) {
+	cc.onGround = false
+	cc.ground = Overlap{}
+	up := cc.up()
+	probe := cc.Position
+	probe.AddScaled(&up, -distance)
+
+	found := false
+	for _, overlap := range cc.overlapsAt(w, &probe, 0.0) {
+		if cc.isGround(&overlap) && (!found || overlap.Normal.Dot(&up) > cc.ground.Normal.Dot(&up)) {
+			cc.ground = overlap
+			found = true
+		}
+	}
+	if !found {
+		return
+	}
+
+	if distance > cc.SkinWidth*2.0 {
+		drop := up
+		drop.MulWith(-distance)
+		if !cc.slide(w, &drop, 0.0, false) {
+			return
+		}
+	}
+	cc.onGround = true
+}
@@ -0,0 +1,294 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	defaultClothDrag   = 1.0
+	defaultClothPasses = 10
+	defaultClothMass   = 1.0
+)
+
+// Cloth is a grid of particles held together by links, for flags, capes,
+// curtains and sails. Neighbouring particles are linked by cables across and
+// down the grid and across each square to stop the cloth stretching or
+// shearing, while letting it fold; springs between every other particle make
+// it resist bending. Particles can be pinned in place or to a point on a
+// body, the wind pushes on the cloth, and when it's added to a World it
+// collides with the colliders there.
+type Cloth struct {
+	*ParticleSystem
+
+	// Columns and Rows are the size of the grid of particles. The particle
+	// in a column and row is at column+row*Columns in Particles.
+	Columns, Rows int
+
+	// Structural holds the cables along the rows and columns of the grid.
+	Structural []*ParticleCable
+
+	// Shear holds the cables across the diagonals of each square of the grid.
+	Shear []*ParticleCable
+
+	// Bend holds the springs between every other particle along the rows and
+	// columns. It's empty unless SetBendingStiffness is called.
+	Bend []*ParticleSpring
+
+	// Wind is the velocity of the air the cloth is in.
+	// Defaults to no wind.
+	Wind m.Vector3
+
+	// Passes is the number of times the cables are swept each step to pull
+	// the particles back together. More passes make the cloth stretch less.
+	// Defaults to 10.
+	Passes int
+
+	// Drag is how hard the air pushes on the cloth for each unit of area and
+	// of the speed it moves through the air at, across its surface.
+	// Defaults to 1.0.
+	Drag m.Real
+
+	// pins holds the particles that are pinned to a body.
+	pins []clothPin
+
+	// duration is the duration of the step in progress.
+	duration m.Real
+
+	// masses holds the masses of the particles so that they can be unpinned.
+	masses []m.Real
+}
+
+// clothPin holds a particle of a Cloth to a point on a body.
+type clothPin struct {
+	index int
+	body  *RigidBody
+	point m.Vector3
+}
+
+// NewCloth creates a new Cloth with its first particle at the corner given,
+// its rows running along the across vector and its columns along the down
+// vector, both in World Space. The mass is spread evenly over the particles,
+// which collide as spheres of the thickness given. A mass of zero or less
+// gives the cloth a mass of 1.0.
+func NewCloth(corner m.Vector3, across m.Vector3, down m.Vector3, columns int, rows int, mass m.Real, thickness m.Real) *Cloth {
+	c := new(Cloth)
+	c.ParticleSystem = NewParticleSystem()
+	if columns < 2 {
+		columns = 2
+	}
+	if rows < 2 {
+		rows = 2
+	}
+	c.Columns, c.Rows = columns, rows
+	c.Drag = defaultClothDrag
+	c.Passes = defaultClothPasses
+	if mass <= 0.0 {
+		mass = defaultClothMass
+	}
+
+	stepAcross := across
+	stepAcross.MulWith(1.0 / m.Real(columns-1))
+	stepDown := down
+	stepDown.MulWith(1.0 / m.Real(rows-1))
+	particleMass := mass / m.Real(columns*rows)
+
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			position := corner
+			position.AddScaled(&stepAcross, m.Real(column))
+			position.AddScaled(&stepDown, m.Real(row))
+			p := NewParticle(position)
+			p.SetMass(particleMass)
+			p.Radius = thickness
+			c.AddParticle(p)
+			c.masses = append(c.masses, particleMass)
+		}
+	}
+
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			if column+1 < columns {
+				c.Structural = append(c.Structural, c.link(column, row, column+1, row))
+			}
+			if row+1 < rows {
+				c.Structural = append(c.Structural, c.link(column, row, column, row+1))
+			}
+			if column+1 < columns && row+1 < rows {
+				c.Shear = append(c.Shear, c.link(column, row, column+1, row+1))
+				c.Shear = append(c.Shear, c.link(column+1, row, column, row+1))
+			}
+		}
+	}
+	c.Generators = append(c.Generators, c)
+	c.Links = append(c.Links, c)
+	return c
+}
+
+// Particle returns the particle at the column and row given.
+func (c *Cloth) Particle(column int, row int) *Particle {
+	return c.Particles[column+row*c.Columns]
+}
+
+// link creates a cable between two particles of the grid at their current
+// distance apart.
+func (c *Cloth) link(columnOne, rowOne, columnTwo, rowTwo int) *ParticleCable {
+	one, two := c.Particle(columnOne, rowOne), c.Particle(columnTwo, rowTwo)
+	return NewParticleCable(one, two, particleDistance(&[2]*Particle{one, two}), 0.0)
+}
+
+// SetBendingStiffness adds springs of the stiffness given between every
+// other particle along the rows and columns. A stiffness of zero or less
+// leaves the cloth free to bend.
+func (c *Cloth) SetBendingStiffness(springConstant m.Real) {
+	for _, spring := range c.Bend {
+		c.removeGenerator(spring)
+	}
+	c.Bend = nil
+	if springConstant <= 0.0 {
+		return
+	}
+
+	for row := 0; row < c.Rows; row++ {
+		for column := 0; column < c.Columns; column++ {
+			if column+2 < c.Columns {
+				c.Bend = append(c.Bend, c.spring(column, row, column+2, row, springConstant))
+			}
+			if row+2 < c.Rows {
+				c.Bend = append(c.Bend, c.spring(column, row, column, row+2, springConstant))
+			}
+		}
+	}
+	for _, spring := range c.Bend {
+		c.Generators = append(c.Generators, spring)
+	}
+}
+
+// spring creates a spring between two particles of the grid that is at rest
+// at their current distance apart.
+func (c *Cloth) spring(columnOne, rowOne, columnTwo, rowTwo int, springConstant m.Real) *ParticleSpring {
+	one, two := c.Particle(columnOne, rowOne), c.Particle(columnTwo, rowTwo)
+	return NewParticleSpring(one, two, springConstant, particleDistance(&[2]*Particle{one, two}))
+}
+
+// removeGenerator removes a force generator from the particle system.
+func (c *Cloth) removeGenerator(g ParticleForceGenerator) {
+	for i, existing := range c.Generators {
+		if existing == g {
+			c.Generators = append(c.Generators[:i], c.Generators[i+1:]...)
+			return
+		}
+	}
+}
+
+// Pin fixes the particle at the column and row given where it is.
+func (c *Cloth) Pin(column int, row int) {
+	c.Unpin(column, row)
+	c.Particle(column, row).SetInfiniteMass()
+}
+
+// PinToBody fixes the particle at the column and row given to the point on
+// the body, in Body Space, so that it's carried along as the body moves.
+func (c *Cloth) PinToBody(column int, row int, body *RigidBody, point m.Vector3) {
+	c.Pin(column, row)
+	c.pins = append(c.pins, clothPin{column + row*c.Columns, body, point})
+}
+
+// Unpin frees the particle at the column and row given.
+func (c *Cloth) Unpin(column int, row int) {
+	index := column + row*c.Columns
+	c.Particles[index].SetMass(c.masses[index])
+	for i, pin := range c.pins {
+		if pin.index == index {
+			c.pins = append(c.pins[:i], c.pins[i+1:]...)
+			break
+		}
+	}
+}
+
+// UpdateForces moves the particles that are pinned to bodies and adds the
+// push of the wind on each triangle of the grid to its corners.
+func (c *Cloth) UpdateForces(duration m.Real) {
+	c.duration = duration
+	for _, pin := range c.pins {
+		p := c.Particles[pin.index]
+		p.Position = bodyPointToWorld(pin.body, &pin.point)
+		p.Velocity = pin.body.GetVelocityAtPoint(&p.Position)
+	}
+
+	if c.Drag <= 0.0 {
+		return
+	}
+	for row := 0; row+1 < c.Rows; row++ {
+		for column := 0; column+1 < c.Columns; column++ {
+			a, b := c.Particle(column, row), c.Particle(column+1, row)
+			d, e := c.Particle(column, row+1), c.Particle(column+1, row+1)
+			c.addWindForce(a, b, e)
+			c.addWindForce(a, e, d)
+		}
+	}
+}
+
+// addWindForce adds the push of the air on a triangle of the cloth, which is
+// along its normal and grows with its area and the speed the air crosses it at.
+func (c *Cloth) addWindForce(one *Particle, two *Particle, three *Particle) {
+	edgeOne := two.Position
+	edgeOne.Sub(&one.Position)
+	edgeTwo := three.Position
+	edgeTwo.Sub(&one.Position)
+
+	// the cross product is along the normal with twice the area as its length
+	normal := edgeOne.Cross(&edgeTwo)
+	doubleArea := normal.Magnitude()
+	if doubleArea <= m.Epsilon {
+		return
+	}
+	normal.MulWith(1.0 / doubleArea)
+
+	relative := c.Wind
+	for _, p := range [3]*Particle{one, two, three} {
+		relative.AddScaled(&p.Velocity, -1.0/3.0)
+	}
+
+	force := normal
+	force.MulWith(c.Drag * normal.Dot(&relative) * doubleArea * 0.5 / 3.0)
+	one.AddForce(&force)
+	two.AddForce(&force)
+	three.AddForce(&force)
+}
+
+// AddContacts sweeps over the cables a number of times, resolving each one
+// that is stretched as it goes, which pulls a large grid together far faster
+// than resolving them one at a time with the other contacts of the system.
+// It leaves no contacts behind for the system to resolve.
+func (c *Cloth) AddContacts(existingContacts []*ParticleContact) (bool, []*ParticleContact) {
+	var contact ParticleContact
+	for pass := 0; pass < c.Passes; pass++ {
+		for _, cables := range [2][]*ParticleCable{c.Structural, c.Shear} {
+			for _, cable := range cables {
+				length := cable.CurrentLength()
+				if length <= cable.MaxLength {
+					continue
+				}
+				contact = *newParticleLinkContact(&cable.Particles, length-cable.MaxLength)
+				contact.Restitution = cable.Restitution
+				contact.resolveVelocity(c.duration)
+				contact.resolveInterpenetration()
+			}
+		}
+	}
+	return false, existingContacts
+}
+
+// AddCloth adds the particles of the cloth to the world, where they're stepped
+// after the rigid bodies and collide with the colliders.
+func (w *World) AddCloth(c *Cloth) {
+	w.AddParticleSystem(c.ParticleSystem)
+}
+
+// RemoveCloth removes the cloth from the world.
+func (w *World) RemoveCloth(c *Cloth) {
+	w.RemoveParticleSystem(c.ParticleSystem)
+}
@@ -0,0 +1,250 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// cubezsim runs a simulation without any graphics: it loads a scene, or a
+// world saved as JSON, runs it for a number of fixed steps and writes what
+// happened to stdout or a file. It's meant for batch experiments, reproducing
+// bug reports and benchmarking on servers.
+//
+// The -format flag picks what's written:
+//
+//	trajectories  the pose of each moving body after every step as CSV, in
+//	              the layout read by cubez.ReadTrajectories
+//	csv, json     the bodies and contacts of every step from cubez.StepDump
+//	final         the world as JSON after the last step
+//
+// Usage:
+//
+//	cubezsim -scene stack.json [-steps 600] [-timestep 0.01] [-format trajectories] [-out result.csv] [-final world.json]
+//	cubezsim -world saved.json -steps 600 [...]
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/harbdog/cubez"
+	m "github.com/harbdog/cubez/math"
+)
+
+// defaultTimestep is the duration of each step for a saved world, which
+// doesn't record one.
+const defaultTimestep = 1.0 / 60.0
+
+// options holds the command line flags.
+type options struct {
+	scenePath string
+	worldPath string
+	steps     int
+	timestep  float64
+	format    string
+	outPath   string
+	finalPath string
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.scenePath, "scene", "", "the JSON scene to run")
+	flag.StringVar(&opts.worldPath, "world", "", "a world saved as JSON to run instead of a scene")
+	flag.IntVar(&opts.steps, "steps", 0, "the number of steps to run; defaults to the scene's")
+	flag.Float64Var(&opts.timestep, "timestep", 0.0, "the duration of each step; defaults to the scene's or 1/60")
+	flag.StringVar(&opts.format, "format", "trajectories", "what to write: trajectories, csv, json or final")
+	flag.StringVar(&opts.outPath, "out", "-", "where to write the output, - for stdout")
+	flag.StringVar(&opts.finalPath, "final", "", "optional file to write the world to as JSON after the last step")
+	flag.Parse()
+
+	if (opts.scenePath == "") == (opts.worldPath == "") {
+		fmt.Fprintln(os.Stderr, "exactly one of -scene and -world is needed")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(&opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// track is a body whose trajectory is written.
+type track struct {
+	name string
+	body *cubez.RigidBody
+}
+
+func run(opts *options) error {
+	w, tracks, steps, timestep, err := load(opts)
+	if err != nil {
+		return err
+	}
+	if opts.steps > 0 {
+		steps = opts.steps
+	}
+	if opts.timestep > 0.0 {
+		timestep = m.Real(opts.timestep)
+	}
+	if steps <= 0 {
+		return fmt.Errorf("the number of steps must be positive; set it with -steps")
+	}
+
+	var out io.Writer = os.Stdout
+	if opts.outPath != "-" {
+		f, err := os.Create(opts.outPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	buffered := bufio.NewWriter(out)
+
+	// pick what's written after each step
+	var afterStep func(step int) error
+	var finish func() error
+	switch opts.format {
+	case "trajectories":
+		writer := csv.NewWriter(buffered)
+		if err := writer.Write([]string{"step", "time", "body", "px", "py", "pz", "qw", "qx", "qy", "qz"}); err != nil {
+			return err
+		}
+		afterStep = func(step int) error {
+			return writeTrajectories(writer, step, m.Real(step)*timestep, tracks)
+		}
+		finish = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	case "csv", "json":
+		format := cubez.DumpCSV
+		if opts.format == "json" {
+			format = cubez.DumpJSON
+		}
+		w.Dump = cubez.NewStepDump(buffered, format)
+		afterStep = func(step int) error {
+			return w.Dump.Err()
+		}
+		finish = w.Dump.Flush
+	case "final":
+		afterStep = func(step int) error {
+			return nil
+		}
+		finish = func() error {
+			return writeWorld(buffered, w)
+		}
+	default:
+		return fmt.Errorf("unknown format %q", opts.format)
+	}
+
+	start := time.Now()
+	for step := 1; step <= steps; step++ {
+		w.Step(timestep)
+		if err := afterStep(step); err != nil {
+			return err
+		}
+	}
+	elapsed := time.Since(start)
+
+	if err := finish(); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	if opts.finalPath != "" {
+		if err := saveWorld(opts.finalPath, w); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "ran %d steps of %d bodies in %v (%.1f steps/s)\n",
+		steps, len(w.Bodies), elapsed, float64(steps)/elapsed.Seconds())
+	return nil
+}
+
+// load reads the scene or world from the options, returning it along with
+// the bodies to track and its number of steps and timestep.
+func load(opts *options) (*cubez.World, []track, int, m.Real, error) {
+	if opts.worldPath != "" {
+		data, err := os.ReadFile(opts.worldPath)
+		if err != nil {
+			return nil, nil, 0, 0.0, err
+		}
+		w := cubez.NewWorld()
+		if err := json.Unmarshal(data, w); err != nil {
+			return nil, nil, 0, 0.0, fmt.Errorf("failed to load the world: %v", err)
+		}
+
+		// saved worlds don't have names, so the bodies go by their index
+		var tracks []track
+		for i, body := range w.Bodies {
+			if body.HasFiniteMass() {
+				tracks = append(tracks, track{"body" + strconv.Itoa(i), body})
+			}
+		}
+		return w, tracks, 0, defaultTimestep, nil
+	}
+
+	f, err := os.Open(opts.scenePath)
+	if err != nil {
+		return nil, nil, 0, 0.0, err
+	}
+	scene, err := cubez.LoadScene(f)
+	f.Close()
+	if err != nil {
+		return nil, nil, 0, 0.0, err
+	}
+	w, sceneTracks, err := scene.Build()
+	if err != nil {
+		return nil, nil, 0, 0.0, err
+	}
+	tracks := make([]track, len(sceneTracks))
+	for i, t := range sceneTracks {
+		tracks[i] = track{t.Name, t.Body}
+	}
+	return w, tracks, scene.Steps, scene.Timestep, nil
+}
+
+// writeTrajectories writes the pose of each tracked body as a CSV row.
+func writeTrajectories(writer *csv.Writer, step int, t m.Real, tracks []track) error {
+	format := func(v m.Real) string {
+		return strconv.FormatFloat(float64(v), 'g', -1, 64)
+	}
+	for _, tr := range tracks {
+		p, q := tr.body.Position, tr.body.Orientation
+		record := []string{strconv.Itoa(step), format(t), tr.name,
+			format(p[0]), format(p[1]), format(p[2]),
+			format(q[0]), format(q[1]), format(q[2]), format(q[3]),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeWorld writes the world as JSON.
+func writeWorld(out io.Writer, w *cubez.World) error {
+	data, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(append(data, '\n'))
+	return err
+}
+
+// saveWorld writes the world as JSON to the file.
+func saveWorld(path string, w *cubez.World) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeWorld(f, w); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,90 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// scenebench runs a JSON scene through cubez and writes the trajectories of
+// its bodies as CSV. Given reference trajectories exported from another engine
+// in the same layout, it also reports how far cubez strayed from them.
+//
+// Usage:
+//
+//	scenebench -scene stack.json -out cubez.csv [-reference bullet.csv]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/harbdog/cubez"
+)
+
+func main() {
+	scenePath := flag.String("scene", "", "the JSON scene to run")
+	outPath := flag.String("out", "trajectories.csv", "where to write the trajectories")
+	referencePath := flag.String("reference", "", "optional reference trajectories to compare against")
+	flag.Parse()
+
+	if *scenePath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*scenePath, *outPath, *referencePath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(scenePath string, outPath string, referencePath string) error {
+	sceneFile, err := os.Open(scenePath)
+	if err != nil {
+		return err
+	}
+	scene, err := cubez.LoadScene(sceneFile)
+	sceneFile.Close()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = cubez.RunScene(scene, out)
+	elapsed := time.Since(start)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ran %d steps in %v and wrote %s\n", scene.Steps, elapsed, outPath)
+
+	if referencePath == "" {
+		return nil
+	}
+	reference, err := readTrajectories(referencePath)
+	if err != nil {
+		return err
+	}
+	actual, err := readTrajectories(outPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-20s %8s %12s %12s %12s\n", "body", "samples", "max pos", "rms pos", "max angle")
+	for _, e := range cubez.CompareTrajectories(reference, actual) {
+		fmt.Printf("%-20s %8d %12.6f %12.6f %12.6f\n", e.Body, e.Samples, e.MaxPosition, e.RMSPosition, e.MaxAngle)
+	}
+	return nil
+}
+
+func readTrajectories(path string) ([]cubez.TrajectorySample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return cubez.ReadTrajectories(f)
+}
@@ -4,6 +4,9 @@
 package cubez
 
 import (
+	"math"
+	"sync"
+
 	m "github.com/harbdog/cubez/math"
 )
 
@@ -17,9 +20,36 @@ type Collider interface {
 	CalculateDerivedData()
 	GetBody() *RigidBody
 	GetTransform() m.Matrix3x4
+	GetMaterial() *Material
+	SetDensity(density m.Real)
 	CheckAgainstHalfSpace(plane *CollisionPlane, existingContacts []*Contact) (bool, []*Contact)
 	CheckAgainstSphere(sphere *CollisionSphere, existingContacts []*Contact) (bool, []*Contact)
 	CheckAgainstCube(secondCube *CollisionCube, existingContacts []*Contact) (bool, []*Contact)
+	CheckAgainstHeightfield(hf *CollisionHeightfield, existingContacts []*Contact) (bool, []*Contact)
+	CheckAgainstTriangleMesh(mesh *CollisionTriangleMesh, existingContacts []*Contact) (bool, []*Contact)
+	CheckAgainstCapsule(capsule *CollisionCapsule, existingContacts []*Contact) (bool, []*Contact)
+}
+
+// derivedCollider is a Collider that can tell when its derived data is out
+// of date, so that a World can skip the colliders that haven't moved.
+type derivedCollider interface {
+	// refreshDerivedData calculates the derived data only if what it's
+	// calculated from has changed.
+	refreshDerivedData()
+
+	// derivedVersion returns a number that changes whenever the derived
+	// data, and so the bounds, of the collider change.
+	derivedVersion() uint64
+}
+
+// refreshDerivedData calculates the derived data of the collider if it's out
+// of date, or always for colliders that can't tell.
+func refreshDerivedData(c Collider) {
+	if dc, ok := c.(derivedCollider); ok {
+		dc.refreshDerivedData()
+	} else {
+		c.CalculateDerivedData()
+	}
 }
 
 // CollisionPlane represents a plane in space for collisions but doesn't
@@ -27,11 +57,18 @@ type Collider interface {
 // It's primarily useful for rerepresenting immovable world geometry like
 // a giant ground plane.
 type CollisionPlane struct {
-	// Normal is the plane's normal vector
-	Normal m.Vector3
-
-	// Offset is the distance of the plane from the origin
-	Offset m.Real
+	// Plane holds the plane's normal vector and its distance from the origin.
+	m.Plane
+
+	// Material holds the surface properties of the plane. If nil, the
+	// DefaultMaterial is used.
+	Material *Material
+
+	// SurfaceVelocity is the velocity the surface of the plane moves at
+	// across itself, like a conveyor belt, without the plane moving. It's
+	// given in World Space.
+	// Defaults to no motion.
+	SurfaceVelocity m.Vector3
 }
 
 // CollisionCube is a rigid body that can be considered an axis-alligned cube
@@ -48,8 +85,27 @@ type CollisionCube struct {
 	// NOTE: this is calculated by calling CalculateDerivedData().
 	transform m.Matrix3x4
 
+	// derivedFrom, derivedOffset and derivedHalfSize hold the transform of
+	// the Body, the Offset and the HalfSize the derived data was last
+	// calculated from, and derivedCount counts the times it has been
+	// calculated.
+	derivedFrom     m.Matrix3x4
+	derivedOffset   m.Matrix3x4
+	derivedHalfSize m.Vector3
+	derivedCount    uint64
+
 	// Halfsize holds the cube's half-sizes along each of its local axes.
 	HalfSize m.Vector3
+
+	// Material holds the surface properties of the cube. If nil, the
+	// DefaultMaterial is used.
+	Material *Material
+
+	// SurfaceVelocity is the velocity the surface of the cube moves at
+	// across itself, like a conveyor belt or treadmill, on top of the motion
+	// of its body. It's given in the local space of the cube.
+	// Defaults to no motion.
+	SurfaceVelocity m.Vector3
 }
 
 // CollisionSphere is a rigid body that can be considered a sphere
@@ -66,8 +122,30 @@ type CollisionSphere struct {
 	// NOTE: this is calculated by calling CalculateDerivedData().
 	transform m.Matrix3x4
 
+	// derivedFrom, derivedOffset and derivedRadius hold the transform of the
+	// Body, the Offset and the Radius the derived data was last calculated
+	// from, and derivedCount counts the times it has been calculated.
+	derivedFrom   m.Matrix3x4
+	derivedOffset m.Matrix3x4
+	derivedRadius m.Real
+	derivedCount  uint64
+
 	// Radius is the radius of the sphere.
 	Radius m.Real
+
+	// Material holds the surface properties of the sphere. If nil, the
+	// DefaultMaterial is used.
+	Material *Material
+
+	// SurfaceVelocity is the velocity the surface of the sphere moves at
+	// across itself on top of the motion of its body. It's given in the
+	// local space of the sphere.
+	// Defaults to no motion.
+	SurfaceVelocity m.Vector3
+
+	// Rolling, if not nil, makes the sphere roll over surfaces without
+	// slipping when it's stepped by a World.
+	Rolling *RollingConstraint
 }
 
 /*
@@ -88,6 +166,8 @@ func NewCollisionPlane(n m.Vector3, o m.Real) *CollisionPlane {
 // Clone makes a new copy of the CollisionPlane object
 func (p *CollisionPlane) Clone() Collider {
 	newPlane := NewCollisionPlane(p.Normal, p.Offset)
+	newPlane.Material = p.Material
+	newPlane.SurfaceVelocity = p.SurfaceVelocity
 	return newPlane
 }
 
@@ -95,6 +175,15 @@ func (p *CollisionPlane) Clone() Collider {
 func (p *CollisionPlane) CalculateDerivedData() {
 }
 
+// refreshDerivedData doesn't do anything since planes don't have derived data.
+func (p *CollisionPlane) refreshDerivedData() {
+}
+
+// derivedVersion always returns one since the bounds of a plane never change.
+func (p *CollisionPlane) derivedVersion() uint64 {
+	return 1
+}
+
 // GetTransform returns an identity transform since the collision plane doesn't use transform matrixes.
 func (p *CollisionPlane) GetTransform() m.Matrix3x4 {
 	var m m.Matrix3x4
@@ -107,6 +196,15 @@ func (p *CollisionPlane) GetBody() *RigidBody {
 	return nil
 }
 
+// GetMaterial returns the surface material of the plane.
+func (p *CollisionPlane) GetMaterial() *Material {
+	return materialOrDefault(p.Material)
+}
+
+// SetDensity doesn't do anything for planes since they don't have a rigid body.
+func (p *CollisionPlane) SetDensity(density m.Real) {
+}
+
 // CheckAgainstHalfSpace doesn't return collisions against another plane, so this implementation is empty.
 func (p *CollisionPlane) CheckAgainstHalfSpace(plane *CollisionPlane, existingContacts []*Contact) (bool, []*Contact) {
 	return false, existingContacts
@@ -153,6 +251,9 @@ func (s *CollisionSphere) Clone() Collider {
 	newSphere := NewCollisionSphere(bClone, s.Radius)
 	newSphere.Offset = s.Offset
 	newSphere.transform = s.transform
+	newSphere.Material = s.Material
+	newSphere.SurfaceVelocity = s.SurfaceVelocity
+	newSphere.Rolling = s.Rolling
 	return newSphere
 }
 
@@ -171,23 +272,102 @@ func (s *CollisionSphere) GetBody() *RigidBody {
 // Constructs a transform matrix based on the RigidBody's transform and the
 // collision object's offset.
 func (s *CollisionSphere) CalculateDerivedData() {
-	transform := s.Body.GetTransform()
-	s.transform = transform.MulMatrix3x4(&s.Offset)
+	s.derivedFrom = s.Body.GetTransform()
+	s.derivedOffset = s.Offset
+	s.derivedRadius = s.Radius
+	s.derivedCount++
+	s.transform = s.derivedFrom.MulMatrix3x4(&s.Offset)
+}
+
+// refreshDerivedData calculates the derived data if the body, the Offset or
+// the Radius have changed since it was last calculated, so that a resized
+// sphere gets new bounds even if it isn't moving.
+func (s *CollisionSphere) refreshDerivedData() {
+	if s.derivedCount == 0 || s.Body.transform != s.derivedFrom || s.Offset != s.derivedOffset ||
+		s.Radius != s.derivedRadius {
+		s.CalculateDerivedData()
+	}
+}
+
+// derivedVersion returns a number that changes whenever the derived data is
+// calculated.
+func (s *CollisionSphere) derivedVersion() uint64 {
+	return s.derivedCount
+}
+
+// GetMaterial returns the surface material of the sphere.
+func (s *CollisionSphere) GetMaterial() *Material {
+	return materialOrDefault(s.Material)
+}
+
+// SetDensity sets the mass, center of mass and inertia tensor of the sphere's
+// RigidBody based on the volume of the sphere, placed at its Offset, and the
+// density given. For bodies with several colliders, the mass of each collider
+// SetDensity is called on is added up.
+func (s *CollisionSphere) SetDensity(density m.Real) {
+	if s.Body == nil {
+		return
+	}
+	volume := 4.0 / 3.0 * math.Pi * s.Radius * s.Radius * s.Radius
+	var tensor m.Matrix3
+	tensor.SetSphereInertiaTensor(s.Radius, volume*density)
+	props := offsetMassProperties(volume, density, &tensor, &s.Offset)
+	s.Body.setShapeMass(s, &props)
+}
+
+// SubmergedVolume calculates how much of the sphere is below the surface of a
+// liquid, given as a plane whose normal points out of the liquid. It returns the
+// submerged volume and the center of buoyancy (the centroid of the submerged
+// volume) in World Space.
+func (s *CollisionSphere) SubmergedVolume(surface *CollisionPlane) (m.Real, m.Vector3) {
+	center := s.transform.GetAxis(3)
+
+	// work out the depth of the spherical cap that is submerged
+	height := surface.Distance(&center)
+	depth := s.Radius - height
+	if depth <= 0.0 {
+		return 0.0, center
+	}
+	if depth > 2.0*s.Radius {
+		depth = 2.0 * s.Radius
+	}
+
+	volume := math.Pi * depth * depth * (3.0*s.Radius - depth) / 3.0
+
+	// the centroid of the cap lies along the normal, below the center of the sphere
+	offset := 2.0*s.Radius - depth
+	capCentroid := 3.0 * offset * offset / (4.0 * (3.0*s.Radius - depth))
+	centerOfBuoyancy := center
+	centerOfBuoyancy.AddScaled(&surface.Normal, -capCentroid)
+
+	return volume, centerOfBuoyancy
 }
 
 // CheckAgainstHalfSpace does a collision test on a collision sphere and a plane representing
 // a half-space (i.e. the normal of the plane points out of the half-space).
 func (s *CollisionSphere) CheckAgainstHalfSpace(plane *CollisionPlane, existingContacts []*Contact) (bool, []*Contact) {
+	return s.checkHalfSpace(plane, existingContacts, nil)
+}
+
+// checkHalfSpace is CheckAgainstHalfSpace, taking the contacts from the pool.
+func (s *CollisionSphere) checkHalfSpace(plane *CollisionPlane, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
 	// work out the distance from the origin
 	positionAxis := s.transform.GetAxis(3)
 	distance := plane.Normal.Dot(&positionAxis) - s.Radius
 
+	// check to see if the sphere moved all the way through the plane this step
+	if s.Body != nil && s.Body.ContinuousCollision {
+		if c := sweptHalfSpaceContact(s.Body, s.GetMaterial(), &positionAxis, s.Radius, plane, pool); c != nil {
+			return true, append(existingContacts, c)
+		}
+	}
+
 	// check for intersection
 	if distance <= plane.Offset == false {
 		return false, existingContacts
 	}
 
-	c := NewContact()
+	c := pool.get()
 	c.ContactPoint = plane.Normal
 	c.ContactPoint.MulWith(distance + s.Radius*-1.0)
 	c.ContactPoint.Add(&positionAxis)
@@ -196,10 +376,7 @@ func (s *CollisionSphere) CheckAgainstHalfSpace(plane *CollisionPlane, existingC
 	c.Bodies[0] = s.Body
 	c.Bodies[1] = nil
 
-	// FIXME:
-	// TODO: c.Friction and c.Restitution set here are test constants
-	c.Friction = 0.9
-	c.Restitution = 0.1
+	c.SetMaterials(s.GetMaterial(), plane.GetMaterial())
 
 	contacts := append(existingContacts, c)
 
@@ -214,6 +391,11 @@ func (s *CollisionSphere) CheckAgainstCube(cube *CollisionCube, existingContacts
 
 // CheckAgainstSphere checks the sphere against collision with another sphere.
 func (s *CollisionSphere) CheckAgainstSphere(secondSphere *CollisionSphere, existingContacts []*Contact) (bool, []*Contact) {
+	return s.checkSphere(secondSphere, existingContacts, nil)
+}
+
+// checkSphere is CheckAgainstSphere, taking the contacts from the pool.
+func (s *CollisionSphere) checkSphere(secondSphere *CollisionSphere, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
 	// cache the sphere positions
 	positionOne := s.transform.GetAxis(3)
 	positionTwo := secondSphere.transform.GetAxis(3)
@@ -229,7 +411,7 @@ func (s *CollisionSphere) CheckAgainstSphere(secondSphere *CollisionSphere, exis
 	}
 
 	// we have contact
-	c := NewContact()
+	c := pool.get()
 
 	c.ContactPoint = midline
 	c.ContactPoint.MulWith(0.5)
@@ -243,10 +425,7 @@ func (s *CollisionSphere) CheckAgainstSphere(secondSphere *CollisionSphere, exis
 	c.Bodies[0] = s.Body
 	c.Bodies[1] = secondSphere.Body
 
-	// FIXME:
-	// TODO: c.Friction and c.Restitution set here are test constants
-	c.Friction = 0.9
-	c.Restitution = 0.1
+	c.SetMaterials(s.GetMaterial(), secondSphere.GetMaterial())
 
 	contacts := append(existingContacts, c)
 
@@ -282,6 +461,8 @@ func (cube *CollisionCube) Clone() Collider {
 	newCube := NewCollisionCube(bClone, cube.HalfSize)
 	newCube.Offset = cube.Offset
 	newCube.transform = cube.transform
+	newCube.Material = cube.Material
+	newCube.SurfaceVelocity = cube.SurfaceVelocity
 	return newCube
 }
 
@@ -300,17 +481,225 @@ func (cube *CollisionCube) GetBody() *RigidBody {
 // Constructs a transform matrix based on the RigidBody's transform and the
 // collision object's offset.
 func (cube *CollisionCube) CalculateDerivedData() {
+	cube.derivedFrom = cube.Body.transform
+	cube.derivedOffset = cube.Offset
+	cube.derivedHalfSize = cube.HalfSize
+	cube.derivedCount++
 	cube.transform = cube.Body.transform.MulMatrix3x4(&cube.Offset)
 }
 
+// refreshDerivedData calculates the derived data if the body, the Offset or
+// the HalfSize have changed since it was last calculated, so that a resized
+// cube gets new bounds even if it isn't moving.
+func (cube *CollisionCube) refreshDerivedData() {
+	if cube.derivedCount == 0 || cube.Body.transform != cube.derivedFrom || cube.Offset != cube.derivedOffset ||
+		cube.HalfSize != cube.derivedHalfSize {
+		cube.CalculateDerivedData()
+	}
+}
+
+// derivedVersion returns a number that changes whenever the derived data is
+// calculated.
+func (cube *CollisionCube) derivedVersion() uint64 {
+	return cube.derivedCount
+}
+
+// GetMaterial returns the surface material of the cube.
+func (cube *CollisionCube) GetMaterial() *Material {
+	return materialOrDefault(cube.Material)
+}
+
+// SetDensity sets the mass, center of mass and inertia tensor of the cube's
+// RigidBody based on the volume of the cube, placed at its Offset, and the
+// density given. For bodies with several colliders, the mass of each collider
+// SetDensity is called on is added up.
+func (cube *CollisionCube) SetDensity(density m.Real) {
+	if cube.Body == nil {
+		return
+	}
+	volume := 8.0 * cube.HalfSize[0] * cube.HalfSize[1] * cube.HalfSize[2]
+	var tensor m.Matrix3
+	tensor.SetBlockInertiaTensor(&cube.HalfSize, volume*density)
+	props := offsetMassProperties(volume, density, &tensor, &cube.Offset)
+	cube.Body.setShapeMass(cube, &props)
+}
+
+// SubmergedVolume calculates how much of the cube is below the surface of a
+// liquid, given as a plane whose normal points out of the liquid. It returns the
+// submerged volume and the center of buoyancy (the centroid of the submerged
+// volume) in World Space.
+//
+// The cube is split into six tetrahedrons that are each clipped exactly by the
+// surface, so the result is exact for any orientation.
+func (cube *CollisionCube) SubmergedVolume(surface *CollisionPlane) (m.Real, m.Vector3) {
+	center := cube.transform.GetAxis(3)
+
+	// the corners of the cube, where bit i of the index picks the side along axis i
+	var corners [8]m.Vector3
+	var heights [8]m.Real
+	anyAbove, anyBelow := false, false
+	for i := 0; i < 8; i++ {
+		local := cube.HalfSize
+		for axis := 0; axis < 3; axis++ {
+			if i&(1<<uint(axis)) == 0 {
+				local[axis] = -local[axis]
+			}
+		}
+		corners[i] = cube.transform.MulVector3(&local)
+		heights[i] = surface.Distance(&corners[i])
+		if heights[i] > 0.0 {
+			anyAbove = true
+		} else {
+			anyBelow = true
+		}
+	}
+	if !anyBelow {
+		return 0.0, center
+	}
+	if !anyAbove {
+		return 8.0 * cube.HalfSize[0] * cube.HalfSize[1] * cube.HalfSize[2], center
+	}
+
+	// walk each path along the edges from corner 0 to corner 7 to split
+	// the cube into six tetrahedrons around that diagonal
+	var volume m.Real
+	var centroid m.Vector3
+	axes := [6][3]int{{0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0}}
+	for _, order := range axes {
+		b := 1 << uint(order[0])
+		c := b | 1<<uint(order[1])
+		tet := [4]int{0, b, c, 7}
+
+		var points [4]m.Vector3
+		var h [4]m.Real
+		for i, corner := range tet {
+			points[i] = corners[corner]
+			h[i] = heights[corner]
+		}
+		v, c2 := submergedTetrahedron(&points, &h)
+		volume += v
+		centroid.AddScaled(&c2, v)
+	}
+
+	if volume <= 0.0 {
+		return 0.0, center
+	}
+	centroid.MulWith(1.0 / volume)
+	return volume, centroid
+}
+
+// tetrahedronVolume returns the volume and centroid of a tetrahedron.
+func tetrahedronVolume(a, b, c, d *m.Vector3) (m.Real, m.Vector3) {
+	ab, ac, ad := *b, *c, *d
+	ab.Sub(a)
+	ac.Sub(a)
+	ad.Sub(a)
+	cross := ac.Cross(&ad)
+	volume := m.RealAbs(ab.Dot(&cross)) / 6.0
+
+	centroid := *a
+	centroid.Add(b)
+	centroid.Add(c)
+	centroid.Add(d)
+	centroid.MulWith(0.25)
+	return volume, centroid
+}
+
+// submergedTetrahedron returns the volume and centroid of the part of the
+// tetrahedron below a surface, given the height of each point above it.
+func submergedTetrahedron(points *[4]m.Vector3, heights *[4]m.Real) (m.Real, m.Vector3) {
+	var below, above []int
+	for i := 0; i < 4; i++ {
+		if heights[i] <= 0.0 {
+			below = append(below, i)
+		} else {
+			above = append(above, i)
+		}
+	}
+
+	// crossing returns the point where the edge from i to j crosses the surface
+	crossing := func(i, j int) m.Vector3 {
+		t := heights[i] / (heights[i] - heights[j])
+		p := points[j]
+		p.Sub(&points[i])
+		p.MulWith(t)
+		p.Add(&points[i])
+		return p
+	}
+
+	switch len(below) {
+	case 0:
+		return 0.0, m.Vector3{}
+	case 4:
+		return tetrahedronVolume(&points[0], &points[1], &points[2], &points[3])
+	case 1:
+		// a small tetrahedron at the one point below the surface
+		a := below[0]
+		ab, ac, ad := crossing(a, above[0]), crossing(a, above[1]), crossing(a, above[2])
+		return tetrahedronVolume(&points[a], &ab, &ac, &ad)
+	case 3:
+		// the whole thing minus the small tetrahedron above the surface
+		d := above[0]
+		da, db, dc := crossing(d, below[0]), crossing(d, below[1]), crossing(d, below[2])
+		whole, wholeCenter := tetrahedronVolume(&points[0], &points[1], &points[2], &points[3])
+		tip, tipCenter := tetrahedronVolume(&points[d], &da, &db, &dc)
+		volume := whole - tip
+		if volume <= 0.0 {
+			return 0.0, wholeCenter
+		}
+		centroid := wholeCenter
+		centroid.MulWith(whole)
+		centroid.AddScaled(&tipCenter, -tip)
+		centroid.MulWith(1.0 / volume)
+		return volume, centroid
+	}
+
+	// two points below make a prism between the triangles at each of them
+	a, b := below[0], below[1]
+	ac, ad := crossing(a, above[0]), crossing(a, above[1])
+	bc, bd := crossing(b, above[0]), crossing(b, above[1])
+	prism := [3][4]*m.Vector3{
+		{&points[a], &ac, &ad, &points[b]},
+		{&ac, &ad, &points[b], &bc},
+		{&ad, &points[b], &bc, &bd},
+	}
+	var volume m.Real
+	var centroid m.Vector3
+	for _, tet := range prism {
+		v, c := tetrahedronVolume(tet[0], tet[1], tet[2], tet[3])
+		volume += v
+		centroid.AddScaled(&c, v)
+	}
+	if volume > 0.0 {
+		centroid.MulWith(1.0 / volume)
+	}
+	return volume, centroid
+}
+
 // CheckAgainstHalfSpace does a collision test on a collision box and a plane representing
 // a half-space (i.e. the normal of the plane points out of the half-space).
 func (cube *CollisionCube) CheckAgainstHalfSpace(plane *CollisionPlane, existingContacts []*Contact) (bool, []*Contact) {
+	return cube.checkHalfSpace(plane, existingContacts, nil)
+}
+
+// checkHalfSpace is CheckAgainstHalfSpace, taking the contacts from the pool.
+func (cube *CollisionCube) checkHalfSpace(plane *CollisionPlane, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
 	// check for an intersection -- if there is none, then we can return
 	if !intersectCubeAndHalfSpace(cube, plane) {
 		return false, existingContacts
 	}
 
+	// check to see if the cube moved all the way through the plane this step,
+	// in which case a single contact at the crossing point is generated instead
+	// of the vertex contacts.
+	if cube.Body != nil && cube.Body.ContinuousCollision {
+		positionAxis := cube.transform.GetAxis(3)
+		projectedRadius := transformToAxis(cube, &plane.Normal)
+		if c := sweptHalfSpaceContact(cube.Body, cube.GetMaterial(), &positionAxis, projectedRadius, plane, pool); c != nil {
+			return true, append(existingContacts, c)
+		}
+	}
+
 	// Now that we have an intersection, find the points of intersection. This can be
 	// done by checking the eight vertices of the cube. If the cube is resting on a plane
 	// or and edge it will be reported as four or two contact points.
@@ -339,7 +728,7 @@ func (cube *CollisionCube) CheckAgainstHalfSpace(plane *CollisionPlane, existing
 		// compare it to the plane's distance
 		if vertexDistance <= plane.Offset {
 			// we have contact
-			c := NewContact()
+			c := pool.get()
 
 			// the contact point is halfway between the vertex and the plane --
 			// we multiply the direction by half the separation distance and
@@ -355,10 +744,7 @@ func (cube *CollisionCube) CheckAgainstHalfSpace(plane *CollisionPlane, existing
 			contacts = append(contacts, c)
 			contactDetected = true
 
-			// FIXME:
-			// TODO: c.Friction and c.Restitution set here are test constants
-			c.Friction = 0.9
-			c.Restitution = 0.1
+			c.SetMaterials(cube.GetMaterial(), plane.GetMaterial())
 		}
 	}
 
@@ -367,6 +753,11 @@ func (cube *CollisionCube) CheckAgainstHalfSpace(plane *CollisionPlane, existing
 
 // CheckAgainstSphere checks the cube against a sphere to see if there's a collision.
 func (cube *CollisionCube) CheckAgainstSphere(sphere *CollisionSphere, existingContacts []*Contact) (bool, []*Contact) {
+	return cube.checkSphere(sphere, existingContacts, nil)
+}
+
+// checkSphere is CheckAgainstSphere, taking the contacts from the pool.
+func (cube *CollisionCube) checkSphere(sphere *CollisionSphere, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
 	// transform the center of the sphere into cube coordinates
 	position := sphere.transform.GetAxis(3)
 	relCenter := cube.transform.TransformInverse(&position)
@@ -402,7 +793,7 @@ func (cube *CollisionCube) CheckAgainstSphere(sphere *CollisionSphere, existingC
 	closestPointWorld := cube.transform.MulVector3(&closestPoint)
 
 	// we have contact
-	c := NewContact()
+	c := pool.get()
 	c.ContactPoint = closestPointWorld
 	c.ContactNormal = closestPointWorld
 	c.ContactNormal.Sub(&position)
@@ -432,10 +823,7 @@ func (cube *CollisionCube) CheckAgainstSphere(sphere *CollisionSphere, existingC
 
 	contacts := append(existingContacts, c)
 
-	// FIXME:
-	// TODO: c.Friction and c.Restitution set here are test constants
-	c.Friction = 0.9
-	c.Restitution = 0.1
+	c.SetMaterials(cube.GetMaterial(), sphere.GetMaterial())
 
 	return true, contacts
 }
@@ -479,7 +867,7 @@ func tryAxis(one *CollisionCube, two *CollisionCube, axis m.Vector3, toCenter *m
 // fillPointFaceBoxBox is called when we know that a vertex from
 // box two is in contact with box one.
 func fillPointFaceBoxBox(one *CollisionCube, two *CollisionCube, toCenter *m.Vector3,
-	best int, pen m.Real, existingContacts []*Contact) []*Contact {
+	best int, pen m.Real, existingContacts []*Contact, pool *contactPool) []*Contact {
 	// We know which axis the collision is on (i.e. best),
 	// but we need to work out which of the two faces on this axis.
 	normal := one.transform.GetAxis(best)
@@ -499,17 +887,14 @@ func fillPointFaceBoxBox(one *CollisionCube, two *CollisionCube, toCenter *m.Vec
 		v[2] = -v[2]
 	}
 
-	c := NewContact()
+	c := pool.get()
 	c.ContactNormal = normal
 	c.Penetration = pen
 	c.ContactPoint = two.transform.MulVector3(&v)
 	c.Bodies[0] = one.Body
 	c.Bodies[1] = two.Body
 
-	// FIXME:
-	// TODO: c.Friction and c.Restitution set here are test constants
-	c.Friction = 0.9
-	c.Restitution = 0.1
+	c.SetMaterials(one.GetMaterial(), two.GetMaterial())
 
 	contacts := append(existingContacts, c)
 
@@ -574,6 +959,11 @@ func contactPoint(pOne *m.Vector3, dOne *m.Vector3, oneSize m.Real,
 
 // CheckAgainstCube checks for collisions against another cube.
 func (cube *CollisionCube) CheckAgainstCube(secondCube *CollisionCube, existingContacts []*Contact) (bool, []*Contact) {
+	return cube.checkCube(secondCube, existingContacts, nil)
+}
+
+// checkCube is CheckAgainstCube, taking the contacts from the pool.
+func (cube *CollisionCube) checkCube(secondCube *CollisionCube, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
 	// find the vector between two vectors
 	toCenter := secondCube.transform.GetAxis(3)
 	oneAxis3 := cube.transform.GetAxis(3)
@@ -630,7 +1020,7 @@ func (cube *CollisionCube) CheckAgainstCube(secondCube *CollisionCube, existingC
 	// depending on the case.
 	if best < 3 {
 		// We've got a vertex of box two on a face of box one.
-		return true, fillPointFaceBoxBox(cube, secondCube, &toCenter, best, pen, existingContacts)
+		return true, fillPointFaceBoxBox(cube, secondCube, &toCenter, best, pen, existingContacts, pool)
 	} else if best < 6 {
 		// We've got a vertex of box one on a face of box two.
 		// We use the same algorithm as above, but swap around
@@ -638,7 +1028,7 @@ func (cube *CollisionCube) CheckAgainstCube(secondCube *CollisionCube, existingC
 		// centres).
 		newCenter := toCenter
 		newCenter.MulWith(-1.0)
-		return true, fillPointFaceBoxBox(secondCube, cube, &newCenter, best-3, pen, existingContacts)
+		return true, fillPointFaceBoxBox(secondCube, cube, &newCenter, best-3, pen, existingContacts, pool)
 	} else {
 		// We've got an edge-edge contact. Find out which axes
 		best -= 6
@@ -692,17 +1082,14 @@ func (cube *CollisionCube) CheckAgainstCube(secondCube *CollisionCube, existingC
 			&ptOnTwoEdge, &twoAxis, secondCube.HalfSize[twoAxisIndex], useOne)
 
 		// finally ... create a new contact
-		c := NewContact()
+		c := pool.get()
 		c.ContactNormal = axis
 		c.Penetration = pen
 		c.ContactPoint = contactVertex
 		c.Bodies[0] = cube.Body
 		c.Bodies[1] = secondCube.Body
 
-		// FIXME:
-		// TODO: c.Friction and c.Restitution set here are test constants
-		c.Friction = 0.9
-		c.Restitution = 0.1
+		c.SetMaterials(cube.GetMaterial(), secondCube.GetMaterial())
 
 		contacts := append(existingContacts, c)
 		return true, contacts
@@ -739,6 +1126,27 @@ func CheckForCollisions(one Collider, two Collider, existingContacts []*Contact)
 			return one.CheckAgainstHalfSpace(otherPlane, existingContacts)
 		}
 		return false, existingContacts
+
+	case *CollisionHeightfield:
+		otherHF, ok := two.(*CollisionHeightfield)
+		if ok {
+			return one.CheckAgainstHeightfield(otherHF, existingContacts)
+		}
+		return false, existingContacts
+
+	case *CollisionTriangleMesh:
+		otherMesh, ok := two.(*CollisionTriangleMesh)
+		if ok {
+			return one.CheckAgainstTriangleMesh(otherMesh, existingContacts)
+		}
+		return false, existingContacts
+
+	case *CollisionCapsule:
+		otherCapsule, ok := two.(*CollisionCapsule)
+		if ok {
+			return one.CheckAgainstCapsule(otherCapsule, existingContacts)
+		}
+		return false, existingContacts
 	}
 
 	// this is reached if we dont have a supported Check* function in the interface
@@ -746,6 +1154,127 @@ func CheckForCollisions(one Collider, two Collider, existingContacts []*Contact)
 	return false, existingContacts
 }
 
+// collectors holds the ContactCollectors used by AppendContacts.
+var collectors = sync.Pool{
+	New: func() interface{} { return NewContactCollector() },
+}
+
+// AppendContacts checks one collider primitive against another like
+// CheckForCollisions, but appends copies of the contacts to the slice given,
+// so that a caller can reuse the same buffer every frame.
+func AppendContacts(one Collider, two Collider, existingContacts []Contact) (bool, []Contact) {
+	cc := collectors.Get().(*ContactCollector)
+	cc.Reset()
+	found := cc.Collect(one, two)
+	for _, c := range cc.Contacts {
+		existingContacts = append(existingContacts, *c)
+	}
+	collectors.Put(cc)
+	return found, existingContacts
+}
+
+// checkForCollisions is CheckForCollisions, taking the contacts from the pool
+// for the built in colliders. The pairs are checked the same way round as
+// CheckForCollisions checks them, so the contacts are the same.
+func checkForCollisions(one Collider, two Collider, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
+	switch a := one.(type) {
+	case *CollisionSphere:
+		switch b := two.(type) {
+		case *CollisionSphere:
+			return a.checkSphere(b, existingContacts, pool)
+		case *CollisionCube:
+			return b.checkSphere(a, existingContacts, pool)
+		case *CollisionPlane:
+			return a.checkHalfSpace(b, existingContacts, pool)
+		case *CollisionHeightfield:
+			return a.checkHeightfield(b, existingContacts, pool)
+		case *CollisionTriangleMesh:
+			return a.checkTriangleMesh(b, existingContacts, pool)
+		case *CollisionCapsule:
+			return b.checkSphere(a, existingContacts, pool)
+		}
+	case *CollisionCube:
+		switch b := two.(type) {
+		case *CollisionSphere:
+			return a.checkSphere(b, existingContacts, pool)
+		case *CollisionCube:
+			return a.checkCube(b, existingContacts, pool)
+		case *CollisionPlane:
+			return a.checkHalfSpace(b, existingContacts, pool)
+		case *CollisionHeightfield:
+			return a.checkHeightfield(b, existingContacts, pool)
+		case *CollisionTriangleMesh:
+			return a.checkTriangleMesh(b, existingContacts, pool)
+		case *CollisionCapsule:
+			return b.checkCube(a, existingContacts, pool)
+		}
+	case *CollisionCapsule:
+		switch b := two.(type) {
+		case *CollisionSphere:
+			return a.checkSphere(b, existingContacts, pool)
+		case *CollisionCube:
+			return a.checkCube(b, existingContacts, pool)
+		case *CollisionCapsule:
+			return a.checkCapsule(b, existingContacts, pool)
+		case *CollisionPlane:
+			return a.checkHalfSpace(b, existingContacts, pool)
+		case *CollisionHeightfield:
+			return a.checkHeightfield(b, existingContacts, pool)
+		case *CollisionTriangleMesh:
+			return a.checkTriangleMesh(b, existingContacts, pool)
+		}
+	case *CollisionPlane:
+		switch b := two.(type) {
+		case *CollisionSphere:
+			return b.checkHalfSpace(a, existingContacts, pool)
+		case *CollisionCube:
+			return b.checkHalfSpace(a, existingContacts, pool)
+		case *CollisionCapsule:
+			return b.checkHalfSpace(a, existingContacts, pool)
+		}
+	case *CollisionHeightfield:
+		switch b := two.(type) {
+		case *CollisionSphere:
+			return b.checkHeightfield(a, existingContacts, pool)
+		case *CollisionCube:
+			return b.checkHeightfield(a, existingContacts, pool)
+		case *CollisionCapsule:
+			return b.checkHeightfield(a, existingContacts, pool)
+		}
+	case *CollisionTriangleMesh:
+		switch b := two.(type) {
+		case *CollisionSphere:
+			return b.checkTriangleMesh(a, existingContacts, pool)
+		case *CollisionCube:
+			return b.checkTriangleMesh(a, existingContacts, pool)
+		case *CollisionCapsule:
+			return b.checkTriangleMesh(a, existingContacts, pool)
+		}
+	}
+	return CheckForCollisions(one, two, existingContacts)
+}
+
+// surfaceVelocity returns the SurfaceVelocity of the collider in World Space.
+func surfaceVelocity(c Collider) m.Vector3 {
+	var velocity m.Vector3
+	switch shape := c.(type) {
+	case *CollisionPlane:
+		return shape.SurfaceVelocity
+	case *CollisionSphere:
+		velocity = shape.SurfaceVelocity
+	case *CollisionCube:
+		velocity = shape.SurfaceVelocity
+	case *CollisionCapsule:
+		velocity = shape.SurfaceVelocity
+	case *CollisionHeightfield:
+		return shape.SurfaceVelocity
+	default:
+		return velocity
+	}
+	transform := c.GetTransform()
+	return transform.TransformDirection(&velocity)
+}
+
 // intersectCubeAndHalfSpace tests to see if a cube and plane intersect
 func intersectCubeAndHalfSpace(cube *CollisionCube, plane *CollisionPlane) bool {
 	// work out the projected radius of the cube onto the plane normal
@@ -759,6 +1288,45 @@ func intersectCubeAndHalfSpace(cube *CollisionCube, plane *CollisionPlane) bool
 	return cubeDistance <= plane.Offset
 }
 
+// sweptHalfSpaceContact tests the segment travelled by the center of a primitive
+// during the last integration against the plane. If the primitive started clear of
+// the plane and ended up entirely on the other side of it, a contact is returned
+// at the point where the primitive first touched the plane; otherwise nil is returned.
+// The radius is the extent of the primitive projected onto the plane normal.
+func sweptHalfSpaceContact(body *RigidBody, material *Material, center *m.Vector3, radius m.Real, plane *CollisionPlane, pool *contactPool) *Contact {
+	// work out where the center was before the last integration
+	prevCenter := *center
+	prevCenter.Sub(&body.Position)
+	prevCenter.Add(&body.prevPosition)
+
+	prevDistance := plane.Distance(&prevCenter)
+	currDistance := plane.Distance(center)
+
+	// only handle the case where the primitive was clear of the plane and
+	// has now completely passed through it
+	if prevDistance < radius || currDistance+radius > 0.0 {
+		return nil
+	}
+
+	// find the fraction of the step at which the surface touched the plane
+	t := (prevDistance - radius) / (prevDistance - currDistance)
+	travel := *center
+	travel.Sub(&prevCenter)
+
+	c := pool.get()
+	c.ContactPoint = prevCenter
+	c.ContactPoint.AddScaled(&travel, t)
+	c.ContactPoint.AddScaled(&plane.Normal, -radius)
+	c.ContactNormal = plane.Normal
+	c.Penetration = radius - currDistance
+	c.Bodies[0] = body
+	c.Bodies[1] = nil
+
+	c.SetMaterials(material, plane.GetMaterial())
+
+	return c
+}
+
 func transformToAxis(cube *CollisionCube, axis *m.Vector3) m.Real {
 	cubeAxisX := cube.transform.GetAxis(0)
 	cubeAxisY := cube.transform.GetAxis(1)
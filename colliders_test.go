@@ -0,0 +1,252 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"math"
+	"testing"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// expectInertia fails the test if the inertia tensor of the body, found from
+// its inverse, isn't close to the one given.
+func expectInertia(t *testing.T, name string, body *RigidBody, expected *m.Matrix3) {
+	t.Helper()
+	inertia := body.InverseInertiaTensor.Invert()
+	for i := range inertia {
+		if m.RealAbs(inertia[i]-expected[i]) > 1e-3*(1.0+m.RealAbs(expected[i])) {
+			t.Errorf("%s had an inertia tensor of %v; expected %v", name, inertia, *expected)
+			return
+		}
+	}
+}
+
+func TestNewColliderKeepsInertia(t *testing.T) {
+	// a body given to a constructor keeps the inertia tensor it was set up with
+	var expected m.Matrix3
+	expected.SetInertiaTensorCoeffs(1.0, 2.0, 3.0, 0.0, 0.0, 0.0)
+	body := NewRigidBody()
+	body.SetMass(5.0)
+	body.SetInertiaTensor(&expected)
+	NewCollisionSphere(body, 0.5)
+	expectInertia(t, "The body given to a sphere", body, &expected)
+	NewCollisionCube(body, m.Vector3{0.5, 1.0, 1.5})
+	expectInertia(t, "The body given to a cube", body, &expected)
+	NewCollisionCapsule(body, 0.25, 0.5)
+	expectInertia(t, "The body given to a capsule", body, &expected)
+}
+
+func TestSetDensityCompound(t *testing.T) {
+	// a dumbbell of two spheres on either side of the body
+	body := NewRigidBody()
+	left := NewCollisionSphere(body, 0.5)
+	left.Offset.SetAsTransform(&m.Vector3{-1.0, 0.0, 0.0}, &m.Quat{1.0, 0.0, 0.0, 0.0})
+	right := NewCollisionSphere(body, 0.5)
+	right.Offset.SetAsTransform(&m.Vector3{1.0, 0.0, 0.0}, &m.Quat{1.0, 0.0, 0.0, 0.0})
+	left.SetDensity(2.0)
+	right.SetDensity(2.0)
+
+	sphereMass := m.Real(4.0/3.0*math.Pi*0.125) * 2.0
+	if mass := body.GetMass(); m.RealAbs(mass-2.0*sphereMass) > 1e-4 {
+		t.Errorf("The dumbbell had a mass of %v; expected %v", mass, 2.0*sphereMass)
+	}
+	if body.CenterOfMass.Magnitude() > 1e-5 {
+		t.Errorf("The dumbbell had its center of mass at %v", body.CenterOfMass)
+	}
+
+	// each sphere spins about its own center around X, and is swung around
+	// the center of the body around Y and Z
+	own := 2.0 / 5.0 * sphereMass * 0.25
+	var expected m.Matrix3
+	expected.SetInertiaTensorCoeffs(2.0*own, 2.0*(own+sphereMass), 2.0*(own+sphereMass), 0.0, 0.0, 0.0)
+	expectInertia(t, "The dumbbell", body, &expected)
+
+	// setting the density of a collider again replaces its share
+	left.SetDensity(2.0)
+	if mass := body.GetMass(); m.RealAbs(mass-2.0*sphereMass) > 1e-4 {
+		t.Errorf("Setting the density again made a mass of %v; expected %v", mass, 2.0*sphereMass)
+	}
+
+	// a heavier sphere on one side pulls the center of mass over
+	right.SetDensity(6.0)
+	expectedCenter := (-sphereMass + 3.0*sphereMass) / (4.0 * sphereMass)
+	if c := body.CenterOfMass; m.RealAbs(c[0]-expectedCenter) > 1e-5 || m.RealAbs(c[1]) > 1e-5 {
+		t.Errorf("The uneven dumbbell had its center of mass at %v; expected %v along X", c, expectedCenter)
+	}
+
+	// an explicit mass starts over, so only the collider set afterwards counts
+	body.SetMass(10.0)
+	left.SetDensity(2.0)
+	if mass := body.GetMass(); m.RealAbs(mass-sphereMass) > 1e-4 {
+		t.Errorf("After SetMass, the mass was %v; expected %v", mass, sphereMass)
+	}
+}
+
+func TestSetDensityOffset(t *testing.T) {
+	// a long cube turned on its side and lifted off the body
+	cube := NewCollisionCube(nil, m.Vector3{2.0, 0.5, 0.5})
+	turn := m.QuatFromAxis(math.Pi/2.0, 0.0, 0.0, 1.0)
+	cube.Offset.SetAsTransform(&m.Vector3{0.0, 3.0, 0.0}, &turn)
+	cube.SetDensity(1.0)
+
+	if mass := cube.Body.GetMass(); m.RealAbs(mass-4.0) > 1e-4 {
+		t.Errorf("The cube had a mass of %v; expected 4", mass)
+	}
+	if c := cube.Body.CenterOfMass; m.RealAbs(c[1]-3.0) > 1e-5 || m.RealAbs(c[0]) > 1e-5 {
+		t.Errorf("The cube had its center of mass at %v; expected it at the offset", c)
+	}
+
+	// the inertia is that of the cube stood up along Y
+	var expected m.Matrix3
+	expected.SetBlockInertiaTensor(&m.Vector3{0.5, 2.0, 0.5}, 4.0)
+	expectInertia(t, "The turned cube", cube.Body, &expected)
+
+	// a cube without a body is left alone
+	cube.Body = nil
+	cube.SetDensity(1.0)
+}
+
+func TestSetDensityClone(t *testing.T) {
+	s := newTestSphere(m.Vector3{})
+	clone := s.Clone().(*CollisionSphere)
+	clone.SetDensity(1.0)
+	if mass := clone.Body.GetMass(); m.RealAbs(mass-s.Body.GetMass()) > 1e-5 {
+		t.Errorf("The clone had a mass of %v after SetDensity; expected %v", mass, s.Body.GetMass())
+	}
+}
+
+// newTestCapsule returns a capsule with a density of 1, lying along the axis
+// given at the position given.
+func newTestCapsule(pos m.Vector3, axis m.Vector3) *CollisionCapsule {
+	c := NewCollisionCapsule(nil, 0.25, 0.5)
+	c.SetDensity(1.0)
+	up := m.Vector3{0.0, 1.0, 0.0}
+	c.Body.Orientation = m.QuatBetweenVectors(&up, &axis)
+	c.Body.Position = pos
+	c.Body.CalculateDerivedData()
+	c.CalculateDerivedData()
+	return c
+}
+
+func TestCapsuleContacts(t *testing.T) {
+	across := m.Vector3{1.0, 0.0, 0.0}
+	ground := NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0)
+
+	// lying on the ground it touches at both ends, standing up at one
+	lying := newTestCapsule(m.Vector3{0.0, 0.2, 0.0}, across)
+	found, contacts := CheckForCollisions(lying, ground, nil)
+	if !found || len(contacts) != 2 {
+		t.Fatalf("The lying capsule made %d contacts with the ground; expected 2", len(contacts))
+	}
+	for _, c := range contacts {
+		if m.RealAbs(c.Penetration-0.05) > 1e-5 || c.ContactNormal != ground.Normal || c.Bodies[0] != lying.Body {
+			t.Errorf("The lying capsule made a contact %v deep along %v; expected 0.05 along the normal", c.Penetration, c.ContactNormal)
+		}
+	}
+	standing := newTestCapsule(m.Vector3{0.0, 0.7, 0.0}, m.Vector3{0.0, 1.0, 0.0})
+	if _, contacts := CheckForCollisions(ground, standing, nil); len(contacts) != 1 || m.RealAbs(contacts[0].Penetration-0.05) > 1e-5 {
+		t.Errorf("The standing capsule made contacts %v with the ground; expected one 0.05 deep", contacts)
+	}
+
+	// a sphere is pushed away from the closest point of the segment
+	sphere := newTestSphere(m.Vector3{0.3, 0.9, 0.0})
+	found, contacts = CheckForCollisions(lying, sphere, nil)
+	if !found || len(contacts) != 1 {
+		t.Fatalf("The capsule made %d contacts with the sphere; expected 1", len(contacts))
+	}
+	if c := contacts[0]; m.RealAbs(c.Penetration-0.05) > 1e-5 || m.RealAbs(c.ContactNormal[1]+1.0) > 1e-5 {
+		t.Errorf("The capsule made a contact with the sphere %v deep along %v; expected 0.05 down", c.Penetration, c.ContactNormal)
+	}
+
+	// lying across the top of a cube it rests on both sides of the middle
+	cube := NewCollisionCube(nil, m.Vector3{0.5, 0.5, 0.5})
+	cube.Body.CalculateDerivedData()
+	cube.CalculateDerivedData()
+	onCube := newTestCapsule(m.Vector3{0.0, 0.7, 0.0}, across)
+	found, contacts = CheckForCollisions(cube, onCube, nil)
+	if !found || len(contacts) < 2 {
+		t.Fatalf("The capsule made %d contacts with the cube; expected at least 2", len(contacts))
+	}
+	for _, c := range contacts {
+		if m.RealAbs(c.Penetration-0.05) > 1e-5 || m.RealAbs(c.ContactNormal[1]-1.0) > 1e-5 || c.Bodies[0] != onCube.Body {
+			t.Errorf("The capsule made a contact with the cube %v deep along %v; expected 0.05 up", c.Penetration, c.ContactNormal)
+		}
+	}
+
+	// crossed capsules touch at one point and parallel ones along their length
+	crossed := newTestCapsule(m.Vector3{0.0, 0.6, 0.0}, m.Vector3{0.0, 0.0, 1.0})
+	if _, contacts := CheckForCollisions(lying, crossed, nil); len(contacts) != 1 || m.RealAbs(contacts[0].Penetration-0.1) > 1e-5 {
+		t.Errorf("The crossed capsules made contacts %v; expected one 0.1 deep", contacts)
+	}
+	parallel := newTestCapsule(m.Vector3{0.2, 0.6, 0.0}, across)
+	if _, contacts := CheckForCollisions(lying, parallel, nil); len(contacts) != 2 {
+		t.Errorf("The parallel capsules made %d contacts; expected 2", len(contacts))
+	}
+	apart := newTestCapsule(m.Vector3{0.0, 0.8, 0.0}, across)
+	if found, _ := CheckForCollisions(lying, apart, nil); found {
+		t.Error("Capsules apart from each other made contacts")
+	}
+}
+
+func TestCapsuleRests(t *testing.T) {
+	// dropped lying down and standing up, a capsule comes to rest on its side
+	// or its end
+	w := NewWorld()
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	lying := newTestCapsule(m.Vector3{0.0, 1.0, 0.0}, m.Vector3{1.0, 0.0, 0.0})
+	standing := newTestCapsule(m.Vector3{2.0, 1.0, 0.0}, m.Vector3{0.0, 1.0, 0.0})
+	w.AddCollider(lying)
+	w.AddCollider(standing)
+	for i := 0; i < 120; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	if y := lying.Body.Position[1]; m.RealAbs(y-0.25) > 0.01 {
+		t.Errorf("The lying capsule came to rest at a height of %v; expected 0.25", y)
+	}
+	if y := standing.Body.Position[1]; m.RealAbs(y-0.75) > 0.01 {
+		t.Errorf("The standing capsule came to rest at a height of %v; expected 0.75", y)
+	}
+}
+
+func TestCapsuleRaycast(t *testing.T) {
+	lying := newTestCapsule(m.Vector3{0.0, 1.0, 0.0}, m.Vector3{1.0, 0.0, 0.0})
+	down := m.Vector3{0.0, -1.0, 0.0}
+
+	// the side and the rounded ends are hit from above
+	for _, x := range []m.Real{0.0, 0.4, 0.6} {
+		from := m.Vector3{x, 5.0, 0.0}
+		found, hit := RaycastCollider(lying, &from, &down)
+		top := 1.25
+		if x > 0.5 {
+			top = 1.0 + math.Sqrt(0.25*0.25-0.1*0.1)
+		}
+		if !found || m.RealAbs(hit.Point[1]-m.Real(top)) > 1e-4 || hit.Normal[1] <= 0.0 {
+			t.Errorf("A ray down at %v hit %v at %v; expected a height of %v", x, found, hit.Point, top)
+		}
+	}
+	from := m.Vector3{0.8, 5.0, 0.0}
+	if found, _ := RaycastCollider(lying, &from, &down); found {
+		t.Error("A ray past the end of the capsule hit it")
+	}
+
+	// along the capsule it hits the end
+	from = m.Vector3{-5.0, 1.0, 0.0}
+	found, hit := RaycastCollider(lying, &from, &m.Vector3{1.0, 0.0, 0.0})
+	if !found || m.RealAbs(hit.Point[0]+0.75) > 1e-4 || m.RealAbs(hit.Normal[0]+1.0) > 1e-4 {
+		t.Errorf("A ray along the capsule hit %v at %v along %v", found, hit.Point, hit.Normal)
+	}
+}
+
+func TestSetDensityCapsule(t *testing.T) {
+	c := NewCollisionCapsule(nil, 0.5, 1.0)
+	c.SetDensity(2.0)
+	volume := m.Real(math.Pi*0.25*2.0 + 4.0/3.0*math.Pi*0.125)
+	if mass := c.Body.GetMass(); m.RealAbs(mass-2.0*volume) > 1e-4 {
+		t.Errorf("The capsule had a mass of %v; expected %v", mass, 2.0*volume)
+	}
+	var expected m.Matrix3
+	expected.SetCapsuleInertiaTensor(0.5, 1.0, 2.0*volume)
+	expectInertia(t, "The capsule", c.Body, &expected)
+}
@@ -0,0 +1,72 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+// collisionFilter is a structure made of several bodies, such as a Ragdoll
+// or a Rope, that stops some pairs of its own bodies from colliding.
+type collisionFilter interface {
+	// ignoresCollision returns true if the two bodies, which are both parts
+	// of the structure, shouldn't collide.
+	ignoresCollision(one *RigidBody, two *RigidBody) bool
+}
+
+// jointFilter stops the bodies of a tree, such as the limbs of a Ragdoll,
+// from colliding with the parents they're joined to, which they touch at the
+// joint.
+type jointFilter struct {
+	// parents maps each body to the body of its parent.
+	parents map[*RigidBody]*RigidBody
+}
+
+// newJointFilter creates a new jointFilter without any joined bodies.
+func newJointFilter() *jointFilter {
+	f := new(jointFilter)
+	f.parents = make(map[*RigidBody]*RigidBody)
+	return f
+}
+
+// join stops the body from colliding with its parent.
+func (f *jointFilter) join(body *RigidBody, parent *RigidBody) {
+	f.parents[body] = parent
+}
+
+// ignoresCollision returns true if one of the bodies is the parent of the other.
+func (f *jointFilter) ignoresCollision(one *RigidBody, two *RigidBody) bool {
+	return f.parents[one] == two || f.parents[two] == one
+}
+
+// setCollisionFilter makes the filter decide which pairs of the bodies given
+// collide with each other.
+func (w *World) setCollisionFilter(filter collisionFilter, bodies []*RigidBody) {
+	if w.collisionFilters == nil {
+		w.collisionFilters = make(map[*RigidBody]collisionFilter)
+	}
+	for _, body := range bodies {
+		w.collisionFilters[body] = filter
+	}
+}
+
+// clearCollisionFilter lets the bodies given collide with each other again,
+// unless they have since been given to another filter.
+func (w *World) clearCollisionFilter(filter collisionFilter, bodies []*RigidBody) {
+	for _, body := range bodies {
+		if w.collisionFilters[body] == filter {
+			delete(w.collisionFilters, body)
+		}
+	}
+}
+
+// ignoresCollision returns true if the two bodies are parts of the same
+// structure and it stops them from colliding. It only reads the filters, so
+// it's safe to call from the narrowphase workers.
+func (w *World) ignoresCollision(one *RigidBody, two *RigidBody) bool {
+	if one == nil || two == nil || w.collisionFilters == nil {
+		return false
+	}
+	filter, ok := w.collisionFilters[one]
+	if !ok || w.collisionFilters[two] != filter {
+		return false
+	}
+	return filter.ignoresCollision(one, two)
+}
@@ -0,0 +1,269 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// Constraint is a joint that holds bodies in place relative to each other,
+// such as a HingeJoint or a SliderJoint. Like Joint, it's enforced by the
+// contacts it generates each step whenever it has been violated.
+type Constraint interface {
+	// AddContacts generates the contacts for any part of the constraint that
+	// has been violated and appends them to the existing contacts.
+	AddContacts(existingContacts []*Contact) (bool, []*Contact)
+
+	// GetBodies returns the two bodies of the constraint; the second can be nil.
+	GetBodies() [2]*RigidBody
+}
+
+// impulseConstraint is a Constraint that also applies impulses directly each
+// step before the contacts are resolved, such as to drive a motor or to pull
+// a soft spring.
+type impulseConstraint interface {
+	applyImpulses(duration m.Real, feedback *JointFeedback)
+}
+
+// statefulConstraint is a Constraint that carries state from one step to the
+// next, such as the last force of a motor, which World.SaveState captures.
+type statefulConstraint interface {
+	saveState(state *constraintState)
+	loadState(state *constraintState)
+}
+
+// constraintState holds the state a statefulConstraint carries between steps.
+type constraintState struct {
+	// motor holds the last force or torque of the motor or spring.
+	motor m.Vector3
+
+	// saturatedSteps and stalled hold the state of the StallDetector.
+	saturatedSteps int
+	stalled        bool
+}
+
+// saveStall copies the state of the stall detector, if there is one.
+func (state *constraintState) saveStall(sd *StallDetector) {
+	if sd != nil {
+		state.saturatedSteps = sd.saturatedSteps
+		state.stalled = sd.stalled
+	}
+}
+
+// loadStall sets the state of the stall detector, if there is one.
+func (state *constraintState) loadStall(sd *StallDetector) {
+	if sd != nil {
+		sd.saturatedSteps = state.saturatedSteps
+		sd.stalled = state.stalled
+	}
+}
+
+// AddConstraint adds the constraint to the world so that it generates
+// contacts each step.
+func (w *World) AddConstraint(c Constraint) {
+	for _, existing := range w.Constraints {
+		if existing == c {
+			return
+		}
+	}
+	w.Constraints = append(w.Constraints, c)
+}
+
+// RemoveConstraint removes the constraint from the world.
+func (w *World) RemoveConstraint(c Constraint) {
+	for i, existing := range w.Constraints {
+		if existing == c {
+			w.Constraints = append(w.Constraints[:i], w.Constraints[i+1:]...)
+			return
+		}
+	}
+}
+
+// applyConstraintImpulses applies the impulses of the constraints in the world
+// that drive motors or pull springs.
+func (w *World) applyConstraintImpulses(duration m.Real) {
+	for _, c := range w.Constraints {
+		if ic, ok := c.(impulseConstraint); ok {
+			ic.applyImpulses(duration, w.feedback[c])
+		}
+	}
+}
+
+// perpendicularTo returns a unit vector perpendicular to the unit vector given.
+func perpendicularTo(v *m.Vector3) m.Vector3 {
+	other := m.Vector3{1.0, 0.0, 0.0}
+	if m.RealAbs(v[0]) > 0.9 {
+		other = m.Vector3{0.0, 1.0, 0.0}
+	}
+	perpendicular := v.Cross(&other)
+	perpendicular.Normalize()
+	return perpendicular
+}
+
+// springImpulse returns the impulse a damped spring with the frequency, in
+// hertz, and damping ratio given applies over the step to pull the offset from
+// its rest back to zero. The inverse mass is the change in speed from a unit
+// impulse. The spring is treated implicitly so that it can't overshoot however
+// stiff it is.
+func springImpulse(inverseMass, offset, speed, frequency, dampingRatio, duration m.Real) m.Real {
+	if duration <= 0.0 || frequency <= 0.0 || inverseMass <= m.Epsilon {
+		return 0.0
+	}
+
+	// turn the frequency and damping ratio into a stiffness and damping for
+	// the effective mass, and from those the softness of the constraint
+	mass := 1.0 / inverseMass
+	omega := 2.0 * m.Real(math.Pi) * frequency
+	stiffness := mass * omega * omega
+	damping := 2.0 * mass * dampingRatio * omega
+	gamma := duration * (damping + duration*stiffness)
+	if gamma <= m.Epsilon {
+		return 0.0
+	}
+	gamma = 1.0 / gamma
+	bias := offset * duration * stiffness * gamma
+
+	return -(speed + bias) / (inverseMass + gamma)
+}
+
+// pointResponse returns the change in the speed that a point on each body
+// separates at along the direction from a unit impulse, and that speed. The
+// points are in World Space.
+func pointResponse(bodies *[2]*RigidBody, points *[2]m.Vector3, direction *m.Vector3) (inverseMass m.Real, speed m.Real) {
+	for i, body := range bodies {
+		if body == nil || !body.HasFiniteMass() {
+			continue
+		}
+		relative := points[i]
+		com := body.GetCenterOfMassWorld()
+		relative.Sub(&com)
+		response := contactImpulseMatrix(body, &relative)
+		change := response.MulVector3(direction)
+		inverseMass += direction.Dot(&change)
+
+		velocity := body.GetVelocityAtPoint(&points[i])
+		if i == 0 {
+			speed -= direction.Dot(&velocity)
+		} else {
+			speed += direction.Dot(&velocity)
+		}
+	}
+	return inverseMass, speed
+}
+
+// applyPointImpulses applies an impulse of the magnitude given along the
+// direction at the point of the second body, and the opposite at the point
+// of the first, which pushes the points apart for a positive magnitude. The
+// impulses are added to the feedback if it isn't nil.
+func applyPointImpulses(bodies *[2]*RigidBody, points *[2]m.Vector3, direction *m.Vector3, magnitude m.Real, feedback *JointFeedback) {
+	if magnitude == 0.0 {
+		return
+	}
+	impulse := *direction
+	impulse.MulWith(magnitude)
+	feedback.addImpulse(1, &impulse, &points[1])
+	if bodies[1] != nil && bodies[1].HasFiniteMass() {
+		bodies[1].ApplyImpulseAtPoint(&impulse, &points[1])
+	}
+	impulse.MulWith(-1.0)
+	feedback.addImpulse(0, &impulse, &points[0])
+	if bodies[0] != nil && bodies[0].HasFiniteMass() {
+		bodies[0].ApplyImpulseAtPoint(&impulse, &points[0])
+	}
+}
+
+// angularResponse returns the change in the speed the second body turns
+// relative to the first about the axis from a unit angular impulse, and that
+// speed.
+func angularResponse(bodies *[2]*RigidBody, axis *m.Vector3) (inverseInertia m.Real, speed m.Real) {
+	for i, body := range bodies {
+		if body == nil || !body.HasFiniteMass() {
+			continue
+		}
+		turn := body.inverseInertiaWorld().MulVector3(axis)
+		inverseInertia += turn.Dot(axis)
+		if i == 0 {
+			speed -= body.Rotation.Dot(axis)
+		} else {
+			speed += body.Rotation.Dot(axis)
+		}
+	}
+	return inverseInertia, speed
+}
+
+// applyAngularImpulses turns the second body about the axis by an angular
+// impulse of the magnitude given, and the first body the opposite way. The
+// impulses are added to the feedback if it isn't nil.
+func applyAngularImpulses(bodies *[2]*RigidBody, axis *m.Vector3, magnitude m.Real, feedback *JointFeedback) {
+	if magnitude == 0.0 {
+		return
+	}
+	impulse := *axis
+	impulse.MulWith(magnitude)
+	feedback.addAngularImpulse(1, &impulse)
+	if bodies[1] != nil && bodies[1].HasFiniteMass() {
+		bodies[1].ApplyAngularImpulse(&impulse)
+	}
+	impulse.MulWith(-1.0)
+	feedback.addAngularImpulse(0, &impulse)
+	if bodies[0] != nil && bodies[0].HasFiniteMass() {
+		bodies[0].ApplyAngularImpulse(&impulse)
+	}
+}
+
+// ConstraintSoftness tunes how rigidly a joint is enforced, from perfectly
+// rigid to soft and energy absorbing, in the spirit of the ERP and CFM
+// parameters of other engines. The joints embed it so that it can be set on
+// each one.
+type ConstraintSoftness struct {
+	// ErrorReduction is the fraction of any drift of the joint that is
+	// corrected each step. A value of zero or less corrects all of it.
+	// Defaults to 1.0.
+	ErrorReduction m.Real
+
+	// Compliance is the fraction of the speed the joint is being pulled apart
+	// at that it lets through each step, from 0.0 for a rigid joint towards
+	// 1.0 for a soft one.
+	// Defaults to 0.0.
+	Compliance m.Real
+}
+
+// softenedConstraint is a Constraint with a ConstraintSoftness.
+type softenedConstraint interface {
+	soften(contacts []*Contact)
+}
+
+// soften applies the softness to the contacts generated by the joint.
+func (s *ConstraintSoftness) soften(contacts []*Contact) {
+	if s.ErrorReduction > 0.0 && s.ErrorReduction < 1.0 {
+		for _, c := range contacts {
+			c.Penetration *= s.ErrorReduction
+		}
+	}
+	if s.Compliance > 0.0 {
+		for _, c := range contacts {
+			c.Compliance = s.Compliance
+		}
+	}
+}
+
+// rotationVector returns the rotation the quaternion represents as its axis
+// scaled by the angle in radians, taking the shortest way around.
+func rotationVector(q *m.Quat) m.Vector3 {
+	w := q[0]
+	vector := m.Vector3{q[1], q[2], q[3]}
+	if w < 0.0 {
+		w = -w
+		vector.MulWith(-1.0)
+	}
+	sine := vector.Magnitude()
+	if sine <= m.Epsilon {
+		return m.Vector3{}
+	}
+	angle := 2.0 * m.Real(math.Atan2(float64(sine), float64(w)))
+	vector.MulWith(angle / sine)
+	return vector
+}
@@ -24,6 +24,10 @@ type Contact struct {
 	// Restitution holdes the normal restitution coefficient at the contact
 	Restitution m.Real
 
+	// Materials holds the surface materials of the colliders that generated
+	// the contact, in the same order as the colliders were checked.
+	Materials [2]*Material
+
 	// ContactPoint is the position of the contact in World Space
 	ContactPoint m.Vector3
 
@@ -35,6 +39,12 @@ type Contact struct {
 	// inter-penetrating points.
 	Penetration m.Real
 
+	// Compliance is the fraction of the closing velocity at the contact
+	// that is left after it's resolved, from 0.0 for a rigid contact
+	// towards 1.0 for a soft one.
+	// Defaults to 0.0.
+	Compliance m.Real
+
 	// contactToWorld is a transform matrix that converts coordiantes in the contact's
 	// frame of reference to World coordinates. The columns are orthornomal vectors.
 	contactToWorld m.Matrix3
@@ -43,11 +53,31 @@ type Contact struct {
 	// relative to the center of each Body.
 	relativeContactPosition [2]m.Vector3
 
+	// offsets holds the translation of each body to the image of it that
+	// the contact was generated against in a wrapping world.
+	offsets [2]m.Vector3
+
 	// contactVelocity holds the closing velocity at the point of contact.
 	contactVelocity m.Vector3
 
 	// desiredDeltaVelocity holds the required change in velocity for this contact to be resolved.
 	desiredDeltaVelocity m.Real
+
+	// impulse holds the total impulse, in World Space, applied to the first
+	// body while resolving the velocity of this contact.
+	impulse m.Vector3
+
+	// compliantVelocity holds the closing velocity that is left by a
+	// compliant contact once it's resolved.
+	compliantVelocity m.Real
+
+	// surfaceVelocities holds the World Space velocity the surface of the
+	// collider of each body moves at on top of the body.
+	surfaceVelocities [2]m.Vector3
+
+	// colliders holds the collider of each body that generated the contact
+	// in a World, or nil if the contact didn't come from a collider check.
+	colliders [2]Collider
 }
 
 // NewContact returns a new Contact object.
@@ -56,30 +86,143 @@ func NewContact() *Contact {
 	return c
 }
 
+// contactPool holds the contacts a world generates between its colliders so
+// that they can be reused the next step. Once it has grown to hold the
+// contacts of the busiest step no more are allocated.
+type contactPool struct {
+	// contacts holds every contact the pool has made, of which the first
+	// used have been handed out since the last reset.
+	contacts []*Contact
+	used     int
+
+	// buffer is kept between steps to hold the world's list of contacts so
+	// that the list doesn't have to grow again each step.
+	buffer []*Contact
+}
+
+// get returns a cleared contact from the pool, or a new one if the pool is nil.
+func (p *contactPool) get() *Contact {
+	if p == nil {
+		return NewContact()
+	}
+	if p.used == len(p.contacts) {
+		p.contacts = append(p.contacts, NewContact())
+	}
+	c := p.contacts[p.used]
+	p.used++
+	*c = Contact{}
+	return c
+}
+
+// reset hands all of the contacts back to the pool, to be reused, and
+// returns an empty list to collect the step's contacts in.
+func (p *contactPool) reset() []*Contact {
+	p.used = 0
+	return p.buffer[:0]
+}
+
+// ContactCollector gathers the contacts between pairs of colliders, reusing
+// the same contacts each time it's Reset so that checking colliders every
+// frame doesn't allocate once it has enough for the busiest frame.
+type ContactCollector struct {
+	// Contacts holds the contacts collected since the last Reset. They're
+	// reused after the next Reset, so copy any that need to be kept.
+	Contacts []*Contact
+
+	// pool holds the contacts that are handed out.
+	pool contactPool
+}
+
+// NewContactCollector creates a new, empty ContactCollector.
+func NewContactCollector() *ContactCollector {
+	cc := new(ContactCollector)
+	return cc
+}
+
+// Reset empties the collector, handing back its contacts to be reused.
+func (cc *ContactCollector) Reset() {
+	cc.Contacts = cc.pool.reset()
+}
+
+// Collect checks the two colliders against each other like
+// CheckForCollisions, adding any contacts to Contacts, and returns true if
+// there were any.
+func (cc *ContactCollector) Collect(one Collider, two Collider) bool {
+	found, contacts := checkForCollisions(one, two, cc.Contacts, &cc.pool)
+	cc.Contacts = contacts
+	cc.pool.buffer = contacts
+	return found
+}
+
+// SetMaterials stores the materials of the two colliders in contact and sets
+// the Friction and Restitution of the contact by combining them.
+func (c *Contact) SetMaterials(one *Material, two *Material) {
+	one = materialOrDefault(one)
+	two = materialOrDefault(two)
+	c.Materials[0] = one
+	c.Materials[1] = two
+	c.Friction = CombineFriction(one, two)
+	c.Restitution = CombineRestitution(one, two)
+}
+
+// GetImpulse returns the total impulse, in World Space, that was applied to
+// the first body while resolving the velocity of the contact; the second body
+// got the opposite impulse.
+func (c *Contact) GetImpulse() m.Vector3 {
+	return c.impulse
+}
+
 func (c *Contact) calculateInternals(duration m.Real) {
 	// make sure that if there's only one body that it's in the first spot
 	if c.Bodies[0] == nil {
 		c.ContactNormal.MulWith(-1.0)
 		c.Bodies[0] = c.Bodies[1]
 		c.Bodies[1] = nil
+		c.Materials[0], c.Materials[1] = c.Materials[1], c.Materials[0]
+		c.offsets[0], c.offsets[1] = c.offsets[1], c.offsets[0]
+		c.surfaceVelocities[0], c.surfaceVelocities[1] = c.surfaceVelocities[1], c.surfaceVelocities[0]
 	}
 
 	// make the set of axis at the contact point
 	c.calculateContactBasis()
 
 	// store the relative position of the contact to each body
+	com := c.Bodies[0].GetCenterOfMassWorld()
+	com.Add(&c.offsets[0])
 	c.relativeContactPosition[0].Set(&c.ContactPoint)
-	c.relativeContactPosition[0].Sub(&c.Bodies[0].Position)
+	c.relativeContactPosition[0].Sub(&com)
 	c.contactVelocity = c.calculateLocalVelocity(0, duration)
 
 	if c.Bodies[1] != nil {
+		com = c.Bodies[1].GetCenterOfMassWorld()
+		com.Add(&c.offsets[1])
 		c.relativeContactPosition[1].Set(&c.ContactPoint)
-		c.relativeContactPosition[1].Sub(&c.Bodies[1].Position)
+		c.relativeContactPosition[1].Sub(&com)
 
 		contactVelocity1 := c.calculateLocalVelocity(1, duration)
 		c.contactVelocity.Sub(&contactVelocity1)
 	}
 
+	// moving surfaces slide across each other as if the bodies did, so that
+	// friction drags what rests on them along
+	surface := c.surfaceVelocities[0]
+	surface.Sub(&c.surfaceVelocities[1])
+	if surface.SquareMagnitude() > 0.0 {
+		surface = c.contactToWorld.TransformTranspose(&surface)
+		surface[0] = 0.0
+		c.contactVelocity.Add(&surface)
+	}
+
+	// a compliant contact lets some of the closing velocity through
+	c.compliantVelocity = 0.0
+	if c.Compliance > 0.0 && c.contactVelocity[0] < 0.0 {
+		compliance := c.Compliance
+		if compliance > 1.0 {
+			compliance = 1.0
+		}
+		c.compliantVelocity = c.contactVelocity[0] * compliance
+	}
+
 	// calculate the desired change in velocity for resolution
 	c.calculateDesiredDeltaVelocity(duration)
 }
@@ -108,7 +251,7 @@ func (c *Contact) calculateDesiredDeltaVelocity(duration m.Real) {
 	}
 
 	// combine the bounce velocity with the removed acceleration velocity
-	c.desiredDeltaVelocity = -c.contactVelocity[0] - restitution*(c.contactVelocity[0]-velocityFromAcc)
+	c.desiredDeltaVelocity = c.compliantVelocity - c.contactVelocity[0] - restitution*(c.contactVelocity[0]-velocityFromAcc)
 }
 
 // Constructs an arbitrary orthonormal basis for the contact. It's stored
@@ -160,16 +303,17 @@ func (c *Contact) calculateLocalVelocity(bodyIndex int, duration m.Real) m.Vecto
 	body := c.Bodies[bodyIndex]
 
 	// work out the velocity of the contact point
-	velocity := body.Rotation.Cross(&c.relativeContactPosition[bodyIndex])
+	var velocity, contactVelocity m.Vector3
+	body.Rotation.CrossInto(&velocity, &c.relativeContactPosition[bodyIndex])
 	velocity.Add(&body.Velocity)
 
 	// turn the velocity into contact coordinates
-	contactVelocity := c.contactToWorld.TransformTranspose(&velocity)
+	c.contactToWorld.TransformTransposeInto(&contactVelocity, &velocity)
 
 	// calculate the amount of velocity that is due to forces without reactions
 	accVelocity := body.GetLastFrameAccelleration()
 	accVelocity.MulWith(duration)
-	accVelocity = c.contactToWorld.TransformTranspose(&accVelocity)
+	c.contactToWorld.TransformTransposeInto(&accVelocity, &accVelocity)
 
 	// we ignore any component of acceleration in the contact normal direction
 	accVelocity[0] = 0.0
@@ -206,19 +350,49 @@ func (c *Contact) matchAwakeState() {
 // NOTE: Contacts that cannot interact with each other should be passed to
 // separate calls of ResolveContacts for performance reasons.
 func ResolveContacts(maxIterations int, contacts []*Contact, duration m.Real) {
+	ResolveContactsTraced(maxIterations, contacts, duration, nil)
+}
+
+// ResolveContactsTraced resolves the contacts like ResolveContacts, passing
+// a trace of the resolver to the tracer if it's not nil.
+func ResolveContactsTraced(maxIterations int, contacts []*Contact, duration m.Real, tracer SolverTracer) {
+	resolveContacts(maxIterations, contacts, duration, positionEpsilon, velocityEpsilon, tracer)
+}
+
+// resolveContacts resolves the contacts until their penetration and desired
+// change in velocity are within the tolerances or each pass has used
+// maxIterations, returning the number of iterations each pass used.
+func resolveContacts(maxIterations int, contacts []*Contact, duration m.Real,
+	positionTolerance m.Real, velocityTolerance m.Real, tracer SolverTracer) (iterations [2]int) {
 	// start off with some sanity checks
 	if duration <= 0.0 || contacts == nil || len(contacts) == 0 {
-		return
+		return iterations
 	}
 
 	// prepares the contacts for processing
 	prepareContacts(contacts, duration)
 
+	var summary *SolverSummary
+	if tracer != nil {
+		summary = &SolverSummary{Contacts: len(contacts), MaxIterations: maxIterations}
+		for _, c := range contacts {
+			if summary.WorstContact == nil || c.Penetration > summary.WorstPenetration {
+				summary.WorstContact = c
+				summary.WorstPenetration = c.Penetration
+			}
+		}
+	}
+
 	// resolve the interpenetration problems with the contacts
-	adjustPositions(maxIterations, contacts, duration)
+	iterations[PassPosition] = adjustPositions(maxIterations, contacts, duration, positionTolerance, tracer, summary)
 
 	// resolve the velocity problems with the contacts
-	adjustVelocities(maxIterations, contacts, duration)
+	iterations[PassVelocity] = adjustVelocities(maxIterations, contacts, duration, velocityTolerance, tracer, summary)
+
+	if tracer != nil {
+		tracer.TraceSolve(summary)
+	}
+	return iterations
 }
 
 // prepareContacts sets up contacts for processing by calculating internal data.
@@ -229,13 +403,15 @@ func prepareContacts(contacts []*Contact, duration m.Real) {
 }
 
 // adjustPositions resolves the positional issues with the given array of
-// constraints using the given number of iterations.
-func adjustPositions(maxIterations int, contacts []*Contact, duration m.Real) {
+// constraints using the given number of iterations, stopping once every
+// penetration is within the tolerance, and returns the iterations used. The
+// summary is only filled in when there's a tracer.
+func adjustPositions(maxIterations int, contacts []*Contact, duration m.Real, tolerance m.Real, tracer SolverTracer, summary *SolverSummary) int {
 	// iteratively resolve interpenetrations in order of severity
 	iterationsUsed := 0
 	for iterationsUsed < maxIterations {
 		// find the biggest penetration
-		max := positionEpsilon
+		max := tolerance
 		index := len(contacts)
 		for i, c := range contacts {
 			if c.Penetration > max {
@@ -253,6 +429,15 @@ func adjustPositions(maxIterations int, contacts []*Contact, duration m.Real) {
 
 		// resolve the penetration
 		linearChange, angularChange := contact.applyPositionChange(max)
+		if tracer != nil {
+			tracer.TraceIteration(&SolverIteration{
+				Pass:      PassPosition,
+				Iteration: iterationsUsed,
+				Contact:   contact,
+				Residual:  max,
+				Change:    linearChange[0].Magnitude() + linearChange[1].Magnitude(),
+			})
+		}
 
 		// again this action may have changed the penetration of other bodies,
 		// so we update contacts
@@ -262,7 +447,8 @@ func adjustPositions(maxIterations int, contacts []*Contact, duration m.Real) {
 					// check for a match with each body in the newly resolved contact
 					for d := 0; d < 2; d++ {
 						if c.Bodies[b] == contact.Bodies[d] {
-							deltaPosition := angularChange[d].Cross(&c.relativeContactPosition[b])
+							var deltaPosition m.Vector3
+							angularChange[d].CrossInto(&deltaPosition, &c.relativeContactPosition[b])
 							deltaPosition.Add(&linearChange[d])
 
 							// the sign of the change is positive if we're dealing with the second body
@@ -280,6 +466,16 @@ func adjustPositions(maxIterations int, contacts []*Contact, duration m.Real) {
 
 		iterationsUsed++
 	}
+
+	if tracer != nil {
+		summary.Iterations[PassPosition] = iterationsUsed
+		for _, c := range contacts {
+			if c.Penetration > summary.Residuals[PassPosition] {
+				summary.Residuals[PassPosition] = c.Penetration
+			}
+		}
+	}
+	return iterationsUsed
 }
 
 // applyPositionChange performs an inertia weighted penetration resolution of this contact alone.
@@ -296,17 +492,18 @@ func (c *Contact) applyPositionChange(penetration m.Real) (linearChange, angular
 			continue
 		}
 
-		inverseInertiaTensor := body.GetInverseInertiaTensorWorld()
+		inverseInertiaTensor := *body.inverseInertiaWorld()
 
 		// use the same procedure as for calculating frictionless velocity
 		// change to work out the angular inertia
-		angularInertiaWorld := c.relativeContactPosition[i].Cross(&c.ContactNormal)
-		angularInertiaWorld = inverseInertiaTensor.MulVector3(&angularInertiaWorld)
-		angularInertiaWorld = angularInertiaWorld.Cross(&c.relativeContactPosition[i])
+		var angularInertiaWorld m.Vector3
+		c.relativeContactPosition[i].CrossInto(&angularInertiaWorld, &c.ContactNormal)
+		inverseInertiaTensor.MulVector3Into(&angularInertiaWorld, &angularInertiaWorld)
+		angularInertiaWorld.CrossInto(&angularInertiaWorld, &c.relativeContactPosition[i])
 		angularInertia[i] = angularInertiaWorld.Dot(&c.ContactNormal)
 
 		// the linear component is simply the inverse mass
-		linearInertia[i] = body.GetInverseMass()
+		linearInertia[i] = body.getInverseMassAlong(&c.ContactNormal)
 
 		// keep track of the total inertia from all component-wise
 		totalInertia += linearInertia[i] + angularInertia[i]
@@ -356,22 +553,31 @@ func (c *Contact) applyPositionChange(penetration m.Real) (linearChange, angular
 			angularChange[i].Clear()
 		} else {
 			// work out the direction we'd like to rotate in
-			targetAngularDirection := c.relativeContactPosition[i].Cross(&c.ContactNormal)
-			inverseInertiaTensor := body.GetInverseInertiaTensorWorld()
-			angularChange[i] = inverseInertiaTensor.MulVector3(&targetAngularDirection)
+			var targetAngularDirection m.Vector3
+			c.relativeContactPosition[i].CrossInto(&targetAngularDirection, &c.ContactNormal)
+			inverseInertiaTensor := *body.inverseInertiaWorld()
+			inverseInertiaTensor.MulVector3Into(&angularChange[i], &targetAngularDirection)
 			angularChange[i].MulWith(angularMove[i] / angularInertia[i])
 		}
 
 		// velocity change is easier -- it's just the linear movement along ContactNormal
 		linearChange[i] = c.ContactNormal
 		linearChange[i].MulWith(linearMove[i])
+		body.applyLinearLocks(&linearChange[i])
 
 		// now we can start to apply the  values we've calculated, starting with linear movement
-		body.Position.AddScaled(&c.ContactNormal, linearMove[i])
+		body.Position.Add(&linearChange[i])
 
-		// now we do the change in orientation
+		// now we do the change in orientation, which rotates the body about
+		// its center of mass
+		com := body.GetCenterOfMassWorld()
 		body.Orientation.AddScaledVector(&angularChange[i], 1.0)
 		body.Orientation.Normalize()
+		if body.hasCenterOfMassOffset() {
+			offset := body.Orientation.Rotate(&body.CenterOfMass)
+			body.Position = com
+			body.Position.Sub(&offset)
+		}
 
 		// we need to calculate the derived data for body that is asleep, so that
 		// the changes are reflected in the object's data. otherwise the resolution
@@ -386,12 +592,13 @@ func (c *Contact) applyPositionChange(penetration m.Real) (linearChange, angular
 }
 
 // adjustVelocities resolves the velocity issues with the given array of constraints,
-// using the given number of iterations.
-func adjustVelocities(maxIterations int, contacts []*Contact, duration m.Real) {
+// using the given number of iterations, stopping once every desired change in
+// velocity is within the tolerance, and returns the iterations used.
+func adjustVelocities(maxIterations int, contacts []*Contact, duration m.Real, tolerance m.Real, tracer SolverTracer, summary *SolverSummary) int {
 	// iteratively handle impacts in order of severity
 	iterationsUsed := 0
 	for iterationsUsed < maxIterations {
-		max := velocityEpsilon
+		max := tolerance
 		index := len(contacts)
 		for i, c := range contacts {
 
@@ -409,7 +616,21 @@ func adjustVelocities(maxIterations int, contacts []*Contact, duration m.Real) {
 		contact.matchAwakeState()
 
 		// do the resolution on the contact that came out on top
+		before := contact.impulse
 		velocityChange, rotationChange := contact.applyVelocityChange()
+		if tracer != nil {
+			applied := contact.impulse
+			applied.Sub(&before)
+			impulse := applied.Magnitude()
+			summary.TotalImpulse += impulse
+			tracer.TraceIteration(&SolverIteration{
+				Pass:      PassVelocity,
+				Iteration: iterationsUsed,
+				Contact:   contact,
+				Residual:  max,
+				Change:    impulse,
+			})
+		}
 
 		// with the change in velocity of the two bodies, the update of contact
 		// velocities means that some of the relative closing velocities need recomputing.
@@ -422,7 +643,8 @@ func adjustVelocities(maxIterations int, contacts []*Contact, duration m.Real) {
 				// check for a match with each body in the newly resolved contact
 				for d := 0; d < 2; d++ {
 					if c2.Bodies[b] == contact.Bodies[d] {
-						deltaVel := rotationChange[d].Cross(&c2.relativeContactPosition[b])
+						var deltaVel m.Vector3
+						rotationChange[d].CrossInto(&deltaVel, &c2.relativeContactPosition[b])
 						deltaVel.Add(&velocityChange[d])
 
 						// the sign of the change is negative if we're dealing with
@@ -432,9 +654,8 @@ func adjustVelocities(maxIterations int, contacts []*Contact, duration m.Real) {
 							sign = -1.0
 						}
 
-						tmpContactVelocity := c2.contactToWorld.TransformTranspose(&deltaVel)
-						tmpContactVelocity.MulWith(sign)
-						c2.contactVelocity.Add(&tmpContactVelocity)
+						c2.contactToWorld.TransformTransposeInto(&deltaVel, &deltaVel)
+						c2.contactVelocity.AddScaled(&deltaVel, sign)
 						c2.calculateDesiredDeltaVelocity(duration)
 					}
 				} // d
@@ -442,15 +663,25 @@ func adjustVelocities(maxIterations int, contacts []*Contact, duration m.Real) {
 		} // c2
 		iterationsUsed++
 	}
+
+	if tracer != nil {
+		summary.Iterations[PassVelocity] = iterationsUsed
+		for _, c := range contacts {
+			if c.desiredDeltaVelocity > summary.Residuals[PassVelocity] {
+				summary.Residuals[PassVelocity] = c.desiredDeltaVelocity
+			}
+		}
+	}
+	return iterationsUsed
 }
 
 // applyVelocityChange performs an inertia-weighted impulse based resolution of this contact alone
 func (c *Contact) applyVelocityChange() (velocityChange, rotationChange [2]m.Vector3) {
 	// get hold of the inverse mass and inverse inertia tensor, both in World Space
 	var inverseInertiaTensors [2]m.Matrix3
-	inverseInertiaTensors[0] = c.Bodies[0].GetInverseInertiaTensorWorld()
+	inverseInertiaTensors[0] = *c.Bodies[0].inverseInertiaWorld()
 	if c.Bodies[1] != nil {
-		inverseInertiaTensors[1] = c.Bodies[1].GetInverseInertiaTensorWorld()
+		inverseInertiaTensors[1] = *c.Bodies[1].inverseInertiaWorld()
 	}
 
 	// we will calculate the impulse for each contact axis
@@ -466,13 +697,16 @@ func (c *Contact) applyVelocityChange() (velocityChange, rotationChange [2]m.Vec
 	}
 
 	// convert impulse to world coordinates
-	impulse := c.contactToWorld.MulVector3(&impulseContact)
+	var impulse, impulsiveTorque m.Vector3
+	c.contactToWorld.MulVector3Into(&impulse, &impulseContact)
+	c.impulse.Add(&impulse)
 
 	// split in the impulse into linear and rotation component-wise
-	impulsiveTorque := c.relativeContactPosition[0].Cross(&impulse)
-	rotationChange[0] = inverseInertiaTensors[0].MulVector3(&impulsiveTorque)
+	c.relativeContactPosition[0].CrossInto(&impulsiveTorque, &impulse)
+	inverseInertiaTensors[0].MulVector3Into(&rotationChange[0], &impulsiveTorque)
 	velocityChange[0].Clear()
 	velocityChange[0].AddScaled(&impulse, c.Bodies[0].GetInverseMass())
+	c.Bodies[0].applyLinearLocks(&velocityChange[0])
 
 	// apply the changes
 	c.Bodies[0].AddVelocity(&velocityChange[0])
@@ -480,10 +714,11 @@ func (c *Contact) applyVelocityChange() (velocityChange, rotationChange [2]m.Vec
 
 	if c.Bodies[1] != nil {
 		// work out the second body's linear and angular changes
-		impulsiveTorque = impulse.Cross(&c.relativeContactPosition[1])
-		rotationChange[1] = inverseInertiaTensors[1].MulVector3(&impulsiveTorque)
+		impulse.CrossInto(&impulsiveTorque, &c.relativeContactPosition[1])
+		inverseInertiaTensors[1].MulVector3Into(&rotationChange[1], &impulsiveTorque)
 		velocityChange[1].Clear()
 		velocityChange[1].AddScaled(&impulse, -c.Bodies[1].GetInverseMass())
+		c.Bodies[1].applyLinearLocks(&velocityChange[1])
 
 		// apply the changes
 		c.Bodies[1].AddVelocity(&velocityChange[1])
@@ -498,29 +733,30 @@ func (c *Contact) applyVelocityChange() (velocityChange, rotationChange [2]m.Vec
 func (c *Contact) calculateFrictionlessImpulse(inverseInertiaTensors [2]m.Matrix3) (impulseContact m.Vector3) {
 	// build a vector that shows the change in velocity in World Space for
 	// a unit impulse in the direction of the contact normal
-	deltaVelWorld := c.relativeContactPosition[0].Cross(&c.ContactNormal)
-	deltaVelWorld = inverseInertiaTensors[0].MulVector3(&deltaVelWorld)
-	deltaVelWorld = deltaVelWorld.Cross(&c.relativeContactPosition[0])
+	var deltaVelWorld m.Vector3
+	c.relativeContactPosition[0].CrossInto(&deltaVelWorld, &c.ContactNormal)
+	inverseInertiaTensors[0].MulVector3Into(&deltaVelWorld, &deltaVelWorld)
+	deltaVelWorld.CrossInto(&deltaVelWorld, &c.relativeContactPosition[0])
 
 	// work out the change in velocity in contact coordinates
 	deltaVelocity := deltaVelWorld.Dot(&c.ContactNormal)
 
 	// add the linear component of velocity change
-	deltaVelocity += c.Bodies[0].GetInverseMass()
+	deltaVelocity += c.Bodies[0].getInverseMassAlong(&c.ContactNormal)
 
 	// check if we need to process the second body's data
-	if c.Bodies[1] == nil {
+	if c.Bodies[1] != nil {
 		// go through the same transformation sequence again
-		deltaVelWorld = c.relativeContactPosition[1].Cross(&c.ContactNormal)
-		deltaVelWorld = inverseInertiaTensors[1].MulVector3(&deltaVelWorld)
-		deltaVelWorld = deltaVelWorld.Cross(&c.relativeContactPosition[1])
+		c.relativeContactPosition[1].CrossInto(&deltaVelWorld, &c.ContactNormal)
+		inverseInertiaTensors[1].MulVector3Into(&deltaVelWorld, &deltaVelWorld)
+		deltaVelWorld.CrossInto(&deltaVelWorld, &c.relativeContactPosition[1])
 
 		// work out the change in velocity in contact coordinates
 		// NOTE: should this be a +=?
 		deltaVelocity += deltaVelWorld.Dot(&c.ContactNormal)
 
 		// add the linear component of velocity change
-		deltaVelocity += c.Bodies[1].GetInverseMass()
+		deltaVelocity += c.Bodies[1].getInverseMassAlong(&c.ContactNormal)
 	}
 
 	// calculate the required size of the impulse
@@ -543,8 +779,9 @@ func (c *Contact) calculateFrictionImpulse(inverseInertiaTensors [2]m.Matrix3) (
 
 	// build the matrix to convert contact impulse to change in velocity in
 	// world coordinates
-	deltaVelWorld := impulseToTorque.MulMatrix3(&inverseInertiaTensors[0])
-	deltaVelWorld = deltaVelWorld.MulMatrix3(&impulseToTorque)
+	var deltaVelWorld m.Matrix3
+	impulseToTorque.MulMatrix3Into(&deltaVelWorld, &inverseInertiaTensors[0])
+	deltaVelWorld.MulMatrix3Into(&deltaVelWorld, &impulseToTorque)
 	deltaVelWorld.MulWith(-1.0)
 
 	// check to see if we need to add the second body's data
@@ -553,8 +790,9 @@ func (c *Contact) calculateFrictionImpulse(inverseInertiaTensors [2]m.Matrix3) (
 		setSkewSymmetric(&impulseToTorque, &c.relativeContactPosition[1])
 
 		// calculate the velocity change matrix
-		deltaVelWorld2 := impulseToTorque.MulMatrix3(&inverseInertiaTensors[1])
-		deltaVelWorld2 = deltaVelWorld2.MulMatrix3(&impulseToTorque)
+		var deltaVelWorld2 m.Matrix3
+		impulseToTorque.MulMatrix3Into(&deltaVelWorld2, &inverseInertiaTensors[1])
+		deltaVelWorld2.MulMatrix3Into(&deltaVelWorld2, &impulseToTorque)
 		deltaVelWorld2.MulWith(-1.0)
 
 		// add to the total delta velocity
@@ -564,10 +802,21 @@ func (c *Contact) calculateFrictionImpulse(inverseInertiaTensors [2]m.Matrix3) (
 		inverseMass += c.Bodies[1].GetInverseMass()
 	}
 
+	// bodies with locked linear axes only move along their free axes, so their
+	// linear velocity change is added in World Space before the change of basis.
+	if c.Bodies[0].AxisLocks != 0 || (c.Bodies[1] != nil && c.Bodies[1].AxisLocks != 0) {
+		linearWorld := c.Bodies[0].getInverseMassMatrix()
+		if c.Bodies[1] != nil {
+			linearWorld2 := c.Bodies[1].getInverseMassMatrix()
+			linearWorld.Add(&linearWorld2)
+		}
+		deltaVelWorld.Add(&linearWorld)
+		inverseMass = 0.0
+	}
+
 	// do a change of basis to convert into contact coordinates
-	deltaVelocity := c.contactToWorld.Transpose()
-	deltaVelocity = deltaVelocity.MulMatrix3(&deltaVelWorld)
-	deltaVelocity = deltaVelocity.MulMatrix3(&c.contactToWorld)
+	deltaVelocity := c.contactToWorld.TransposeMulMatrix3(&deltaVelWorld)
+	deltaVelocity.MulMatrix3Into(&deltaVelocity, &c.contactToWorld)
 
 	// add in the linear velocity change
 	deltaVelocity[0] += inverseMass
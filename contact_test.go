@@ -0,0 +1,45 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"testing"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+func TestFrictionlessImpulseSharesMass(t *testing.T) {
+	// two equal spheres meeting head on with a perfectly bouncy, frictionless
+	// material swap their velocities, which only happens when the impulse
+	// accounts for the mass of both of them
+	bouncy := NewMaterial(0.0, 1.0)
+	newSphere := func(x m.Real, velocity m.Real) *CollisionSphere {
+		s := NewCollisionSphere(nil, 0.5)
+		s.Material = bouncy
+		s.Body.SetMass(1.0)
+		var tensor m.Matrix3
+		tensor.SetSphereInertiaTensor(0.5, 1.0)
+		s.Body.SetInertiaTensor(&tensor)
+		s.Body.Position = m.Vector3{x, 0.0, 0.0}
+		s.Body.Velocity = m.Vector3{velocity, 0.0, 0.0}
+		s.Body.CalculateDerivedData()
+		s.CalculateDerivedData()
+		return s
+	}
+	one := newSphere(0.0, 2.0)
+	two := newSphere(0.95, 0.0)
+
+	found, contacts := CheckForCollisions(one, two, nil)
+	if !found {
+		t.Fatal("The spheres didn't touch")
+	}
+	ResolveContacts(len(contacts)*8, contacts, 1.0/60.0)
+
+	if v := one.Body.Velocity[0]; v < -0.01 || v > 0.01 {
+		t.Errorf("The first sphere was left moving at %f; expected it to stop", v)
+	}
+	if v := two.Body.Velocity[0]; v < 1.99 || v > 2.01 {
+		t.Errorf("The second sphere was sent off at %f; expected 2", v)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"sort"
+)
+
+// noBodyIndex is the index given to a missing body, or one that isn't in the
+// world, so that its contacts sort after the rest.
+const noBodyIndex = ^uint32(0)
+
+// orderedContact is a contact along with the key it's sorted by.
+type orderedContact struct {
+	key     uint64
+	contact *Contact
+}
+
+// contactOrder sorts contacts by their keys. Its methods take a pointer so
+// that sorting doesn't allocate.
+type contactOrder []orderedContact
+
+func (o *contactOrder) Len() int           { return len(*o) }
+func (o *contactOrder) Less(i, j int) bool { return (*o)[i].key < (*o)[j].key }
+func (o *contactOrder) Swap(i, j int)      { (*o)[i], (*o)[j] = (*o)[j], (*o)[i] }
+
+// bodyIndex returns the index of the body's handle in the world.
+func (w *World) bodyIndex(body *RigidBody) uint32 {
+	if body == nil {
+		return noBodyIndex
+	}
+	id, ok := w.bodyIDs[body]
+	if !ok {
+		return noBodyIndex
+	}
+	return id.index
+}
+
+// sortContacts puts the contacts of the step in the order of the indexes of
+// their bodies, lower index first, so that the resolver works through the
+// bodies in the order they were added. The sort is stable and the order
+// buffer is kept between steps, so contacts generated in the same order as
+// the last step are cheap to sort again.
+func (w *World) sortContacts() {
+	order := w.contactOrder[:0]
+	for _, c := range w.contacts {
+		lo, hi := w.bodyIndex(c.Bodies[0]), w.bodyIndex(c.Bodies[1])
+		if hi < lo {
+			lo, hi = hi, lo
+		}
+		order = append(order, orderedContact{key: uint64(lo)<<32 | uint64(hi), contact: c})
+	}
+	w.contactOrder = order
+	sort.Stable(&w.contactOrder)
+
+	moved := 0
+	for i := range order {
+		if w.contacts[i] != order[i].contact {
+			w.contacts[i] = order[i].contact
+			moved++
+		}
+	}
+	w.contactsMoved = moved
+}
+
+// GetContactsReordered returns the number of contacts that SortContacts moved
+// from the order they were generated in during the last step.
+func (w *World) GetContactsReordered() int {
+	return w.contactsMoved
+}
@@ -0,0 +1,241 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// AxisConvention identifies which axis points up in a right-handed coordinate
+// system. Cubez defaults to Y-up, but content authored in tools such as Blender
+// or most CAD packages is Z-up.
+type AxisConvention uint8
+
+const (
+	// YUp has the Y axis pointing up and -Z pointing forward.
+	YUp AxisConvention = iota
+
+	// ZUp has the Z axis pointing up and Y pointing forward.
+	ZUp
+)
+
+// Up returns the unit vector that points up in the convention.
+func (c AxisConvention) Up() m.Vector3 {
+	if c == ZUp {
+		return m.Vector3{0.0, 0.0, 1.0}
+	}
+	return m.Vector3{0.0, 1.0, 0.0}
+}
+
+// Gravity returns the default acceleration due to gravity in the convention.
+func (c AxisConvention) Gravity() m.Vector3 {
+	gravity := c.Up()
+	gravity.MulWith(defaultAcceleration[1])
+	return gravity
+}
+
+// ConvertVector converts a point or direction from one convention to another.
+func ConvertVector(v *m.Vector3, from AxisConvention, to AxisConvention) m.Vector3 {
+	switch {
+	case from == ZUp && to == YUp:
+		return m.Vector3{v[0], v[2], -v[1]}
+	case from == YUp && to == ZUp:
+		return m.Vector3{v[0], -v[2], v[1]}
+	}
+	return *v
+}
+
+// ConvertQuat converts an orientation from one convention to another.
+func ConvertQuat(q *m.Quat, from AxisConvention, to AxisConvention) m.Quat {
+	axis := m.Vector3{q[1], q[2], q[3]}
+	axis = ConvertVector(&axis, from, to)
+	return m.Quat{q[0], axis[0], axis[1], axis[2]}
+}
+
+// ConvertBody converts the position, orientation, velocities and gravity of
+// the RigidBody from one convention to another. The default Acceleration set
+// by NewRigidBody is always Y-up, so a body that still has it is given the
+// default gravity of the target convention instead.
+func ConvertBody(body *RigidBody, from AxisConvention, to AxisConvention) {
+	body.Position = ConvertVector(&body.Position, from, to)
+	body.Orientation = ConvertQuat(&body.Orientation, from, to)
+	body.Velocity = ConvertVector(&body.Velocity, from, to)
+	body.Rotation = ConvertVector(&body.Rotation, from, to)
+	body.CenterOfMass = ConvertVector(&body.CenterOfMass, from, to)
+	body.InverseInertiaTensor = convertTensor(&body.InverseInertiaTensor, func(v *m.Vector3) m.Vector3 {
+		return ConvertVector(v, from, to)
+	})
+	if body.Acceleration == defaultAcceleration {
+		body.Acceleration = to.Gravity()
+	} else {
+		body.Acceleration = ConvertVector(&body.Acceleration, from, to)
+	}
+	if body.GravityOverride != nil {
+		gravity := ConvertVector(body.GravityOverride, from, to)
+		body.GravityOverride = &gravity
+	}
+	body.CalculateDerivedData()
+}
+
+// ConvertCollider converts the collider, and its RigidBody if it has one, from
+// one convention to another. The colliders of a body with more than one must
+// be converted together with ConvertColliders so that the body is only
+// converted once.
+func ConvertCollider(c Collider, from AxisConvention, to AxisConvention) {
+	ConvertColliders([]Collider{c}, from, to)
+}
+
+// ConvertColliders converts the colliders, and each RigidBody they're attached
+// to exactly once, from one convention to another.
+func ConvertColliders(colliders []Collider, from AxisConvention, to AxisConvention) {
+	converted := make(map[*RigidBody]bool)
+	for _, c := range colliders {
+		convertShape(c, from, to)
+		if body := c.GetBody(); body != nil && !converted[body] {
+			ConvertBody(body, from, to)
+			converted[body] = true
+		}
+	}
+	for _, c := range colliders {
+		c.CalculateDerivedData()
+	}
+}
+
+// convertShape converts the shape of the collider and its offset from the
+// body, but not the body itself.
+func convertShape(c Collider, from AxisConvention, to AxisConvention) {
+	switch shape := c.(type) {
+	case *CollisionPlane:
+		shape.Normal = ConvertVector(&shape.Normal, from, to)
+	case *CollisionSphere:
+		shape.Offset = convertOffset(&shape.Offset, from, to)
+	case *CollisionCapsule:
+		// the capsule runs along its local Y axis, so it's turned to where
+		// that axis ends up, like the half sizes of a cube
+		up := m.Vector3{0.0, 1.0, 0.0}
+		axis := ConvertVector(&up, from, to)
+		q := m.QuatBetweenVectors(&up, &axis)
+		var turn m.Matrix3x4
+		turn.SetAsTransform(&m.Vector3{}, &q)
+		offset := convertOffset(&shape.Offset, from, to)
+		shape.Offset = offset.MulMatrix3x4(&turn)
+	case *CollisionCube:
+		shape.Offset = convertOffset(&shape.Offset, from, to)
+		shape.HalfSize = ConvertVector(&shape.HalfSize, from, to)
+		for i := 0; i < 3; i++ {
+			shape.HalfSize[i] = m.RealAbs(shape.HalfSize[i])
+		}
+	}
+}
+
+// convertOffset converts the rotation and translation of a collider's offset
+// from its body from one convention to another.
+func convertOffset(offset *m.Matrix3x4, from AxisConvention, to AxisConvention) m.Matrix3x4 {
+	rotation := offset.GetMatrix3()
+	rotation = convertTensor(&rotation, func(v *m.Vector3) m.Vector3 {
+		return ConvertVector(v, from, to)
+	})
+	position := offset.GetPosition()
+	position = ConvertVector(&position, from, to)
+
+	var result m.Matrix3x4
+	result.SetMatrix3(&rotation)
+	result[9], result[10], result[11] = position[0], position[1], position[2]
+	return result
+}
+
+// Handedness identifies whether a coordinate system is right-handed or
+// left-handed. Cubez is always right-handed; these values describe the
+// convention of data coming from, or going to, other engines.
+type Handedness uint8
+
+const (
+	// RightHanded is the handedness used by Cubez, OpenGL and Blender.
+	RightHanded Handedness = iota
+
+	// LeftHanded is the handedness used by engines such as Unity and Unreal.
+	LeftHanded
+)
+
+// mirrorAxis returns the index of the forward axis of the convention, which
+// is the axis that is flipped to change handedness.
+func (c AxisConvention) mirrorAxis() int {
+	if c == ZUp {
+		return 1
+	}
+	return 2
+}
+
+// ConvertHandedVector converts a point or direction between handedness by
+// flipping the forward axis of the axis convention given.
+func ConvertHandedVector(v *m.Vector3, from Handedness, to Handedness, c AxisConvention) m.Vector3 {
+	result := *v
+	if from != to {
+		result[c.mirrorAxis()] = -result[c.mirrorAxis()]
+	}
+	return result
+}
+
+// ConvertHandedAngularVelocity converts an angular velocity, or any other axial
+// vector such as a torque, between handedness. Mirroring reverses the sense of
+// rotation, so this is the negation of ConvertHandedVector.
+func ConvertHandedAngularVelocity(v *m.Vector3, from Handedness, to Handedness, c AxisConvention) m.Vector3 {
+	result := *v
+	if from != to {
+		result = ConvertHandedVector(v, from, to, c)
+		result.MulWith(-1.0)
+	}
+	return result
+}
+
+// ConvertHandedQuat converts an orientation between handedness.
+func ConvertHandedQuat(q *m.Quat, from Handedness, to Handedness, c AxisConvention) m.Quat {
+	axis := m.Vector3{q[1], q[2], q[3]}
+	axis = ConvertHandedAngularVelocity(&axis, from, to, c)
+	return m.Quat{q[0], axis[0], axis[1], axis[2]}
+}
+
+// ConvertHandedWinding reverses the winding of the triangles in the index
+// list, in place, if the handedness changes so that mesh colliders still face
+// outwards. Every three indices form a triangle.
+func ConvertHandedWinding(indices []int, from Handedness, to Handedness) {
+	if from == to {
+		return
+	}
+	for i := 0; i+2 < len(indices); i += 3 {
+		indices[i+1], indices[i+2] = indices[i+2], indices[i+1]
+	}
+}
+
+// ConvertBodyHandedness converts the position, orientation, velocities and
+// gravity of the RigidBody between handedness.
+func ConvertBodyHandedness(body *RigidBody, from Handedness, to Handedness, c AxisConvention) {
+	body.Position = ConvertHandedVector(&body.Position, from, to, c)
+	body.Orientation = ConvertHandedQuat(&body.Orientation, from, to, c)
+	body.Velocity = ConvertHandedVector(&body.Velocity, from, to, c)
+	body.Rotation = ConvertHandedAngularVelocity(&body.Rotation, from, to, c)
+	body.Acceleration = ConvertHandedVector(&body.Acceleration, from, to, c)
+	body.CenterOfMass = ConvertHandedVector(&body.CenterOfMass, from, to, c)
+	body.InverseInertiaTensor = convertTensor(&body.InverseInertiaTensor, func(v *m.Vector3) m.Vector3 {
+		return ConvertHandedVector(v, from, to, c)
+	})
+	if body.GravityOverride != nil {
+		gravity := ConvertHandedVector(body.GravityOverride, from, to, c)
+		body.GravityOverride = &gravity
+	}
+	body.CalculateDerivedData()
+}
+
+// convertTensor changes the basis of a tensor, such as an inertia tensor, with
+// the linear conversion of vectors given.
+func convertTensor(tensor *m.Matrix3, convert func(v *m.Vector3) m.Vector3) m.Matrix3 {
+	x := convert(&m.Vector3{1.0, 0.0, 0.0})
+	y := convert(&m.Vector3{0.0, 1.0, 0.0})
+	z := convert(&m.Vector3{0.0, 0.0, 1.0})
+
+	var basis m.Matrix3
+	basis.SetComponents(&x, &y, &z)
+	result := basis.MulMatrix3(tensor)
+	return result.MulTransposeMatrix3(&basis)
+}
@@ -0,0 +1,127 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"fmt"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// CookFunc does the work of cooking shape data, such as computing the mass
+// properties or convex hull of a mesh, and returns the result.
+type CookFunc func() (interface{}, error)
+
+// CookHandle is a handle to shape data that is being cooked on a background
+// goroutine so that level streaming doesn't stall the simulation while it's
+// being built. It's safe to use from multiple goroutines.
+type CookHandle struct {
+	// done is closed once the cooking has finished.
+	done chan struct{}
+
+	// result holds the cooked data once done is closed.
+	result interface{}
+
+	// err holds the error from cooking, if any, once done is closed.
+	err error
+}
+
+// CookAsync starts running the cook function on a new goroutine and returns a
+// handle that can be used to get the result once it's ready. Any data used by
+// the cook function must not be modified until it has finished.
+func CookAsync(cook CookFunc) *CookHandle {
+	h := new(CookHandle)
+	h.done = make(chan struct{})
+	go func() {
+		h.result, h.err = cook()
+		close(h.done)
+	}()
+	return h
+}
+
+// Done returns a channel that is closed once the cooking has finished, which
+// is useful in select statements.
+func (h *CookHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// IsDone returns true if the cooking has finished without blocking.
+func (h *CookHandle) IsDone() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait blocks until the cooking has finished and returns the result, which is
+// nil if cooking failed.
+func (h *CookHandle) Wait() (interface{}, error) {
+	<-h.done
+	return h.result, h.err
+}
+
+// CookMeshMassPropertiesAsync computes the mass properties of a closed triangle
+// mesh on a background goroutine. The vertices and indices are copied so they
+// can be reused right away. The result of the handle is a MassProperties value.
+func CookMeshMassPropertiesAsync(vertices []m.Vector3, indices []int, density m.Real) *CookHandle {
+	verts := append([]m.Vector3(nil), vertices...)
+	inds := append([]int(nil), indices...)
+	return CookAsync(func() (interface{}, error) {
+		props, err := ComputeMeshMassProperties(verts, inds, density)
+		if err != nil {
+			return nil, err
+		}
+		return props, nil
+	})
+}
+
+// CookConvexHullAsync computes the convex hull of the points on a background
+// goroutine. The points are copied so they can be reused right away. The
+// result of the handle is a *ConvexHull.
+func CookConvexHullAsync(points []m.Vector3) *CookHandle {
+	pts := append([]m.Vector3(nil), points...)
+	return CookAsync(func() (interface{}, error) {
+		hull, err := ComputeConvexHull(pts)
+		if err != nil {
+			return nil, err
+		}
+		return hull, nil
+	})
+}
+
+// CookConvexDecompositionAsync splits a closed triangle mesh into convex hulls
+// with ComputeConvexDecomposition on a background goroutine. The vertices and
+// indices are copied so they can be reused right away. The result of the
+// handle is a []*ConvexHull.
+func CookConvexDecompositionAsync(vertices []m.Vector3, indices []int, maxConcavity m.Real, maxHulls int) *CookHandle {
+	verts := append([]m.Vector3(nil), vertices...)
+	inds := append([]int(nil), indices...)
+	return CookAsync(func() (interface{}, error) {
+		hulls, err := ComputeConvexDecomposition(verts, inds, maxConcavity, maxHulls)
+		if err != nil {
+			return nil, err
+		}
+		return hulls, nil
+	})
+}
+
+// CookTriangleMeshAsync builds a CollisionTriangleMesh, along with its BVH,
+// on a background goroutine so that large level geometry can be streamed in.
+// The vertices and indices are copied so they can be reused right away. The
+// result of the handle is a *CollisionTriangleMesh, which can be added to a
+// World once it's ready.
+func CookTriangleMeshAsync(vertices []m.Vector3, indices []int) *CookHandle {
+	verts := append([]m.Vector3(nil), vertices...)
+	inds := append([]int(nil), indices...)
+	return CookAsync(func() (interface{}, error) {
+		for i, index := range inds {
+			if index < 0 || index >= len(verts) {
+				return nil, fmt.Errorf("index %d at position %d is out of range", index, i)
+			}
+		}
+		return NewCollisionTriangleMesh(verts, inds), nil
+	})
+}
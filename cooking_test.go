@@ -0,0 +1,254 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// testBoxMesh appends the corners and the triangles, wound counter-clockwise
+// from outside, of a box between the corners given.
+func testBoxMesh(min m.Vector3, max m.Vector3, vertices []m.Vector3, indices []int) ([]m.Vector3, []int) {
+	base := len(vertices)
+	for i := 0; i < 8; i++ {
+		corner := min
+		for axis := 0; axis < 3; axis++ {
+			if i&(1<<uint(axis)) != 0 {
+				corner[axis] = max[axis]
+			}
+		}
+		vertices = append(vertices, corner)
+	}
+	faces := [6][4]int{
+		{0, 4, 6, 2}, {1, 3, 7, 5}, // -x, +x
+		{0, 1, 5, 4}, {2, 6, 7, 3}, // -y, +y
+		{0, 2, 3, 1}, {4, 5, 7, 6}, // -z, +z
+	}
+	for _, f := range faces {
+		indices = append(indices, base+f[0], base+f[1], base+f[2], base+f[0], base+f[2], base+f[3])
+	}
+	return vertices, indices
+}
+
+func TestConvexHullOfBox(t *testing.T) {
+	vertices, _ := testBoxMesh(m.Vector3{0.0, 0.0, 0.0}, m.Vector3{1.0, 1.0, 1.0}, nil, nil)
+
+	// points inside and on the faces of the box don't become corners
+	points := append([]m.Vector3(nil), vertices...)
+	for i := 0; i < 50; i++ {
+		f := m.Real(i) / 50.0
+		points = append(points, m.Vector3{f, 1.0 - f, 0.5}, m.Vector3{0.5, f, 0.0})
+	}
+
+	hull, err := ComputeConvexHull(points)
+	if err != nil {
+		t.Fatalf("ComputeConvexHull failed: %v", err)
+	}
+	if len(hull.Vertices) != 8 || len(hull.Indices) != 36 {
+		t.Errorf("The hull had %d corners and %d indices; expected 8 and 36", len(hull.Vertices), len(hull.Indices))
+	}
+	props, err := ComputeMeshMassProperties(hull.Vertices, hull.Indices, 1.0)
+	if err != nil {
+		t.Fatalf("The hull wasn't a closed mesh: %v", err)
+	}
+	offCenter := props.CenterOfMass
+	offCenter.Sub(&m.Vector3{0.5, 0.5, 0.5})
+	if m.RealAbs(props.Volume-1.0) > 1e-4 || offCenter.Magnitude() > 1e-4 {
+		t.Errorf("The hull had a volume of %v around %v", props.Volume, props.CenterOfMass)
+	}
+}
+
+func TestConvexHullOfSphere(t *testing.T) {
+	// points spread over a sphere are all corners of the hull
+	const count = 200
+	var points []m.Vector3
+	for i := 0; i < count; i++ {
+		y := 1.0 - 2.0*(float64(i)+0.5)/count
+		r := math.Sqrt(1.0 - y*y)
+		angle := float64(i) * math.Pi * (3.0 - math.Sqrt(5.0))
+		points = append(points, m.Vector3{m.Real(r * math.Cos(angle)), m.Real(y), m.Real(r * math.Sin(angle))})
+	}
+
+	hull, err := ComputeConvexHull(points)
+	if err != nil {
+		t.Fatalf("ComputeConvexHull failed: %v", err)
+	}
+	if len(hull.Vertices) != count || len(hull.Indices) != 3*(2*count-4) {
+		t.Errorf("The hull had %d corners and %d indices; expected %d and %d", len(hull.Vertices), len(hull.Indices), count, 3*(2*count-4))
+	}
+
+	// every point is behind every face
+	for i := 0; i < len(hull.Indices); i += 3 {
+		tri := [3]m.Vector3{hull.Vertices[hull.Indices[i]], hull.Vertices[hull.Indices[i+1]], hull.Vertices[hull.Indices[i+2]]}
+		normal, ok := faceNormal(&tri)
+		if !ok {
+			t.Fatalf("Triangle %d of the hull has no area", i/3)
+		}
+		for _, p := range points {
+			toPoint := p
+			toPoint.Sub(&tri[0])
+			if d := toPoint.Dot(&normal); d > 1e-2 {
+				t.Fatalf("A point is %v in front of triangle %d of the hull", d, i/3)
+			}
+		}
+	}
+	props, err := ComputeMeshMassProperties(hull.Vertices, hull.Indices, 1.0)
+	if err != nil || props.Volume > 4.0/3.0*math.Pi || props.Volume < 4.0 {
+		t.Errorf("The hull had a volume of %v: %v", props.Volume, err)
+	}
+}
+
+func TestConvexHullDegenerate(t *testing.T) {
+	flat := []m.Vector3{{0.0, 0.0, 0.0}, {1.0, 0.0, 0.0}, {0.0, 0.0, 1.0}, {1.0, 0.0, 1.0}, {0.5, 0.0, 0.5}}
+	if _, err := ComputeConvexHull(flat); err == nil {
+		t.Error("A hull was made from points in a plane")
+	}
+	if _, err := ComputeConvexHull(flat[:3]); err == nil {
+		t.Error("A hull was made from three points")
+	}
+}
+
+func TestTriangleBVHQuery(t *testing.T) {
+	// a bumpy grid of ground
+	const size = 20
+	var vertices []m.Vector3
+	var indices []int
+	for z := 0; z <= size; z++ {
+		for x := 0; x <= size; x++ {
+			vertices = append(vertices, m.Vector3{m.Real(x), m.Real(math.Sin(float64(x * z))), m.Real(z)})
+		}
+	}
+	for z := 0; z < size; z++ {
+		for x := 0; x < size; x++ {
+			corner := z*(size+1) + x
+			indices = append(indices, corner, corner+size+1, corner+size+2, corner, corner+size+2, corner+1)
+		}
+	}
+	mesh := NewCollisionTriangleMesh(vertices, indices)
+	if b := mesh.BVH.Bounds(); b.Min[0] != 0.0 || b.Max[0] != size || b.Max[2] != size {
+		t.Errorf("The BVH had bounds %v", b)
+	}
+
+	// the BVH finds exactly the triangles whose bounds overlap
+	for i := 0; i < 50; i++ {
+		center := m.Vector3{m.Real(i%10) * 2.3, m.Real(i%3) - 1.0, m.Real(i/10) * 4.1}
+		query := m.AABBAround(&center, m.Real(i%4)*0.7)
+		var found []int
+		mesh.overlapping(&query, func(index int) {
+			found = append(found, index)
+		})
+		sort.Ints(found)
+
+		var expected []int
+		for tri := 0; tri < mesh.TriangleCount(); tri++ {
+			corners := mesh.triangle(tri)
+			b := Bounds{Min: corners[0], Max: corners[0]}
+			b.MergePoint(&corners[1])
+			b.MergePoint(&corners[2])
+			if b.Overlaps(&query) {
+				expected = append(expected, tri)
+			}
+		}
+		if len(found) != len(expected) {
+			t.Fatalf("Query %d found triangles %v; expected %v", i, found, expected)
+		}
+		for j := range found {
+			if found[j] != expected[j] {
+				t.Fatalf("Query %d found triangles %v; expected %v", i, found, expected)
+			}
+		}
+	}
+}
+
+func TestConvexDecomposition(t *testing.T) {
+	// an L made of two boxes
+	vertices, indices := testBoxMesh(m.Vector3{0.0, 0.0, 0.0}, m.Vector3{2.0, 1.0, 1.0}, nil, nil)
+	vertices, indices = testBoxMesh(m.Vector3{0.0, 1.0, 0.0}, m.Vector3{1.0, 2.0, 1.0}, vertices, indices)
+
+	// a single hull fills in the corner of the L
+	hulls, err := ComputeConvexDecomposition(vertices, indices, 0.5, 8)
+	if err != nil {
+		t.Fatalf("ComputeConvexDecomposition failed: %v", err)
+	}
+	if len(hulls) != 1 {
+		t.Errorf("A concavity of 0.5 made %d hulls; expected 1", len(hulls))
+	}
+
+	hulls, err = ComputeConvexDecomposition(vertices, indices, 0.05, 8)
+	if err != nil {
+		t.Fatalf("ComputeConvexDecomposition failed: %v", err)
+	}
+	if len(hulls) != 2 {
+		t.Fatalf("The L was split into %d hulls; expected 2", len(hulls))
+	}
+	var total m.Real
+	for _, hull := range hulls {
+		props, err := ComputeMeshMassProperties(hull.Vertices, hull.Indices, 1.0)
+		if err != nil {
+			t.Fatalf("A hull wasn't a closed mesh: %v", err)
+		}
+		total += props.Volume
+	}
+	if m.RealAbs(total-3.0) > 1e-3 {
+		t.Errorf("The hulls had a volume of %v; expected 3", total)
+	}
+
+	if _, err := ComputeConvexDecomposition(vertices, indices[:5], 0.05, 8); err == nil {
+		t.Error("A mesh with a partial triangle was decomposed")
+	}
+}
+
+func TestCookAsync(t *testing.T) {
+	vertices, indices := testBoxMesh(m.Vector3{-5.0, -1.0, -5.0}, m.Vector3{5.0, 0.0, 5.0}, nil, nil)
+	meshHandle := CookTriangleMeshAsync(vertices, indices)
+	hullHandle := CookConvexHullAsync(vertices)
+	pieceHandle := CookConvexDecompositionAsync(vertices, indices, 0.05, 4)
+
+	// the inputs were copied, so changing them doesn't change the results
+	vertices[0] = m.Vector3{-100.0, -100.0, -100.0}
+
+	result, err := meshHandle.Wait()
+	mesh, ok := result.(*CollisionTriangleMesh)
+	if err != nil || !ok || mesh.TriangleCount() != 12 || mesh.BVH == nil {
+		t.Fatalf("Cooking a mesh gave %#v: %v", result, err)
+	}
+	w := NewWorld()
+	w.AddCollider(mesh)
+	sphere := newTestSphere(m.Vector3{0.0, 1.0, 0.0})
+	w.AddCollider(sphere)
+	for i := 0; i < 120; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	if y := sphere.Body.Position[1]; m.RealAbs(y-0.5) > 0.05 {
+		t.Errorf("The sphere settled at a height of %v on the cooked mesh; expected 0.5", y)
+	}
+
+	result, err = hullHandle.Wait()
+	if hull, ok := result.(*ConvexHull); err != nil || !ok || len(hull.Vertices) != 8 {
+		t.Errorf("Cooking a hull gave %#v: %v", result, err)
+	}
+	result, err = pieceHandle.Wait()
+	if hulls, ok := result.([]*ConvexHull); err != nil || !ok || len(hulls) != 1 {
+		t.Errorf("Cooking a decomposition gave %#v: %v", result, err)
+	}
+
+	if _, err := CookTriangleMeshAsync(vertices, []int{0, 1, 8}).Wait(); err == nil {
+		t.Error("A mesh with an index out of range was cooked")
+	}
+
+	// a failed cook gives a nil result rather than a nil pointer of its type
+	if result, err := CookConvexHullAsync(vertices[:2]).Wait(); err == nil || result != nil {
+		t.Errorf("Cooking a hull of two points gave %#v: %v", result, err)
+	}
+	if result, err := CookConvexDecompositionAsync(vertices, indices[:5], 0.05, 4).Wait(); err == nil || result != nil {
+		t.Errorf("Cooking a decomposition of a partial triangle gave %#v: %v", result, err)
+	}
+	if result, err := CookMeshMassPropertiesAsync(vertices, indices[:5], 1.0).Wait(); err == nil || result != nil {
+		t.Errorf("Cooking the mass of a partial triangle gave %#v: %v", result, err)
+	}
+}
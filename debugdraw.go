@@ -0,0 +1,319 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// debugCircleSegments is the number of lines drawn for each circle of a
+	// sphere's wireframe.
+	debugCircleSegments = 16
+
+	// debugPlaneExtent is the half-width of the grid drawn for a plane, and
+	// debugPlaneLines the number of lines across it in each direction.
+	debugPlaneExtent = 10.0
+	debugPlaneLines  = 10
+
+	// debugNormalLength is the length of the lines drawn for contact normals
+	// and joint axes.
+	debugNormalLength = 0.25
+
+	// debugPointSize is the size of the points drawn for contacts and anchors.
+	debugPointSize = 0.05
+)
+
+// DebugCategory says what a piece of debug drawing shows, so that a
+// DebugDrawer can pick a color for it.
+type DebugCategory int
+
+const (
+	// DebugColliders is the wireframe of the collision shapes of bodies that
+	// are awake.
+	DebugColliders DebugCategory = 1 << iota
+
+	// DebugBounds is the axis aligned bounding box of each collider.
+	DebugBounds
+
+	// DebugContacts is the point and normal of each contact from the last step.
+	DebugContacts
+
+	// DebugJoints is the anchors and axes of the joints and constraints.
+	DebugJoints
+
+	// DebugSleep is the wireframe of the collision shapes of bodies that are
+	// asleep, along with a label.
+	DebugSleep
+
+	// DebugHeatmap is the box of each cell of the world's Heatmap that has
+	// had contacts, labelled with the average number of contacts per step.
+	DebugHeatmap
+
+	// DebugAll turns on every category.
+	DebugAll = DebugColliders | DebugBounds | DebugContacts | DebugJoints | DebugSleep | DebugHeatmap
+)
+
+// DebugDrawer draws the debug visualization of a world in a renderer. The
+// World calls it at the end of each step with the parts turned on by its
+// DebugDraw flags, so a drawer would typically batch them up until the next
+// frame is rendered. Positions are in World Space.
+type DebugDrawer interface {
+	// DrawLine draws a line between two points.
+	DrawLine(from *m.Vector3, to *m.Vector3, category DebugCategory)
+
+	// DrawPoint draws a point of the size given.
+	DrawPoint(point *m.Vector3, size m.Real, category DebugCategory)
+
+	// DrawText draws a label at the position.
+	DrawText(position *m.Vector3, text string, category DebugCategory)
+}
+
+// DrawDebug passes the parts of the world in the categories given to the
+// drawer. Step calls it with the world's DebugDrawer and DebugDraw flags, but
+// it can be called at any time.
+func (w *World) DrawDebug(d DebugDrawer, categories DebugCategory) {
+	if categories&(DebugColliders|DebugSleep|DebugBounds) != 0 {
+		infinite := InfiniteBounds()
+		for _, c := range w.Colliders {
+			category := DebugColliders
+			if body := c.GetBody(); body != nil && !body.IsAwake {
+				category = DebugSleep
+			}
+			if categories&category != 0 {
+				drawDebugCollider(d, c, category)
+			}
+			if categories&DebugBounds != 0 {
+				if bounds := ColliderBounds(c); bounds != infinite {
+					drawDebugBox(d, &bounds, DebugBounds)
+				}
+			}
+		}
+	}
+	if categories&DebugSleep != 0 {
+		for _, body := range w.Bodies {
+			if !body.IsAwake && body.HasFiniteMass() {
+				d.DrawText(&body.Position, "asleep", DebugSleep)
+			}
+		}
+	}
+
+	if categories&DebugContacts != 0 {
+		for _, contact := range w.contacts {
+			end := contact.ContactPoint
+			end.AddScaled(&contact.ContactNormal, debugNormalLength)
+			d.DrawPoint(&contact.ContactPoint, debugPointSize, DebugContacts)
+			d.DrawLine(&contact.ContactPoint, &end, DebugContacts)
+		}
+	}
+
+	if categories&DebugHeatmap != 0 && w.Heatmap != nil {
+		w.Heatmap.drawDebug(d)
+	}
+
+	if categories&DebugJoints != 0 {
+		for _, j := range w.Joints {
+			drawDebugAnchors(d, j.Bodies, j.Positions)
+		}
+		for _, c := range w.Constraints {
+			switch joint := c.(type) {
+			case *HingeJoint:
+				drawDebugAnchors(d, joint.Bodies, joint.Anchors)
+				drawDebugAxis(d, joint.Bodies[0], &joint.Anchors[0], &joint.Axes[0])
+			case *SliderJoint:
+				drawDebugAnchors(d, joint.Bodies, joint.Anchors)
+				drawDebugAxis(d, joint.Bodies[0], &joint.Anchors[0], &joint.Axes[0])
+			case *WeldJoint:
+				for _, points := range joint.Points {
+					drawDebugAnchors(d, joint.Bodies, points)
+				}
+			default:
+				// other constraints are drawn as a line between their bodies,
+				// or a point on a body that's held to the world
+				bodies := c.GetBodies()
+				if bodies[1] == nil {
+					d.DrawPoint(&bodies[0].Position, debugPointSize, DebugJoints)
+				} else {
+					drawDebugAnchors(d, bodies, [2]m.Vector3{})
+				}
+			}
+		}
+	}
+}
+
+// drawDebugAnchors draws the point on each body, in its Body Space or World
+// Space if it has no body, and the line between them.
+func drawDebugAnchors(d DebugDrawer, bodies [2]*RigidBody, points [2]m.Vector3) {
+	one := bodyPointToWorld(bodies[0], &points[0])
+	two := bodyPointToWorld(bodies[1], &points[1])
+	d.DrawPoint(&one, debugPointSize, DebugJoints)
+	d.DrawPoint(&two, debugPointSize, DebugJoints)
+	d.DrawLine(&one, &two, DebugJoints)
+}
+
+// drawDebugAxis draws the axis through the anchor, both in the Body Space of
+// the body.
+func drawDebugAxis(d DebugDrawer, body *RigidBody, anchor *m.Vector3, axis *m.Vector3) {
+	center := bodyPointToWorld(body, anchor)
+	direction := bodyDirectionToWorld(body, axis)
+	direction.Normalize()
+	from, to := center, center
+	from.AddScaled(&direction, -debugNormalLength)
+	to.AddScaled(&direction, debugNormalLength)
+	d.DrawLine(&from, &to, DebugJoints)
+}
+
+// drawDebugSphere draws a circle about each of the axes of the transform
+// around the center given.
+func drawDebugSphere(d DebugDrawer, transform *m.Matrix3x4, center *m.Vector3, radius m.Real, category DebugCategory) {
+	for axis := 0; axis < 3; axis++ {
+		u := transform.GetAxis((axis + 1) % 3)
+		v := transform.GetAxis((axis + 2) % 3)
+		var previous m.Vector3
+		for i := 0; i <= debugCircleSegments; i++ {
+			angle := m.Real(i) * 2.0 * math.Pi / debugCircleSegments
+			point := *center
+			point.AddScaled(&u, radius*m.RealCos(angle))
+			point.AddScaled(&v, radius*m.RealSin(angle))
+			if i > 0 {
+				d.DrawLine(&previous, &point, category)
+			}
+			previous = point
+		}
+	}
+}
+
+// drawDebugCollider draws the wireframe of the collider.
+func drawDebugCollider(d DebugDrawer, c Collider, category DebugCategory) {
+	transform := c.GetTransform()
+	switch shape := c.(type) {
+	case *CollisionCube:
+		var corners [8]m.Vector3
+		for i := range corners {
+			local := shape.HalfSize
+			for axis := 0; axis < 3; axis++ {
+				if i&(1<<uint(axis)) == 0 {
+					local[axis] = -local[axis]
+				}
+			}
+			corners[i] = transform.MulVector3(&local)
+		}
+		drawDebugEdges(d, &corners, category)
+
+	case *CollisionSphere:
+		center := transform.GetAxis(3)
+		drawDebugSphere(d, &transform, &center, shape.Radius, category)
+
+	case *CollisionCapsule:
+		// the spheres of the ends joined by lines down the sides
+		bottom, top := shape.Segment()
+		drawDebugSphere(d, &transform, &bottom, shape.Radius, category)
+		drawDebugSphere(d, &transform, &top, shape.Radius, category)
+		for _, axis := range [2]int{0, 2} {
+			side := transform.GetAxis(axis)
+			for _, sign := range [2]m.Real{-1.0, 1.0} {
+				from, to := bottom, top
+				from.AddScaled(&side, sign*shape.Radius)
+				to.AddScaled(&side, sign*shape.Radius)
+				d.DrawLine(&from, &to, category)
+			}
+		}
+
+	case *CollisionPlane:
+		// a grid around the point of the plane closest to the origin
+		normal := shape.Normal
+		normal.Normalize()
+		origin := normal
+		origin.MulWith(shape.Offset)
+		u := perpendicularTo(&normal)
+		v := normal.Cross(&u)
+		for i := -debugPlaneLines; i <= debugPlaneLines; i++ {
+			across := m.Real(i) * debugPlaneExtent / debugPlaneLines
+			for _, dirs := range [2][2]*m.Vector3{{&u, &v}, {&v, &u}} {
+				from, to := origin, origin
+				from.AddScaled(dirs[0], across)
+				to.AddScaled(dirs[0], across)
+				from.AddScaled(dirs[1], -debugPlaneExtent)
+				to.AddScaled(dirs[1], debugPlaneExtent)
+				d.DrawLine(&from, &to, category)
+			}
+		}
+		end := origin
+		end.AddScaled(&normal, debugNormalLength)
+		d.DrawLine(&origin, &end, category)
+
+	case *CollisionHeightfield:
+		// the edges along each row and column, skipping the holes
+		sample := func(column int, row int) m.Vector3 {
+			return m.Vector3{
+				shape.Position[0] + m.Real(column)*shape.CellSize,
+				shape.Position[1] + shape.GetHeight(column, row),
+				shape.Position[2] + m.Real(row)*shape.CellSize,
+			}
+		}
+		for row := 0; row < shape.Rows; row++ {
+			for column := 0; column < shape.Columns; column++ {
+				here := sample(column, row)
+				if column+1 < shape.Columns && !debugHeightfieldHoles(shape, column, row-1, column, row) {
+					next := sample(column+1, row)
+					d.DrawLine(&here, &next, category)
+				}
+				if row+1 < shape.Rows && !debugHeightfieldHoles(shape, column-1, row, column, row) {
+					next := sample(column, row+1)
+					d.DrawLine(&here, &next, category)
+				}
+			}
+		}
+
+	case *CollisionTriangleMesh:
+		for i := 0; i < shape.TriangleCount(); i++ {
+			tri := shape.triangle(i)
+			for corner := range tri {
+				tri[corner].Add(&shape.Position)
+			}
+			d.DrawLine(&tri[0], &tri[1], category)
+			d.DrawLine(&tri[1], &tri[2], category)
+			d.DrawLine(&tri[2], &tri[0], category)
+		}
+	}
+}
+
+// debugHeightfieldHoles returns true if both of the cells on either side of
+// an edge are holes or outside of the heightfield.
+func debugHeightfieldHoles(hf *CollisionHeightfield, column1 int, row1 int, column2 int, row2 int) bool {
+	solid := func(column int, row int) bool {
+		return column >= 0 && row >= 0 && column < hf.Columns-1 && row < hf.Rows-1 && !hf.IsHole(column, row)
+	}
+	return !solid(column1, row1) && !solid(column2, row2)
+}
+
+// drawDebugBox draws the edges of the bounding box.
+func drawDebugBox(d DebugDrawer, bounds *Bounds, category DebugCategory) {
+	var corners [8]m.Vector3
+	for i := range corners {
+		for axis := 0; axis < 3; axis++ {
+			if i&(1<<uint(axis)) == 0 {
+				corners[i][axis] = bounds.Min[axis]
+			} else {
+				corners[i][axis] = bounds.Max[axis]
+			}
+		}
+	}
+	drawDebugEdges(d, &corners, category)
+}
+
+// drawDebugEdges draws the twelve edges of a box from its corners, where the
+// bits of the index of each corner say which side of each axis it's on.
+func drawDebugEdges(d DebugDrawer, corners *[8]m.Vector3, category DebugCategory) {
+	for i := range corners {
+		for axis := 0; axis < 3; axis++ {
+			bit := 1 << uint(axis)
+			if i&bit == 0 {
+				d.DrawLine(&corners[i], &corners[i|bit], category)
+			}
+		}
+	}
+}
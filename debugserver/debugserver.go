@@ -0,0 +1,260 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The debugserver module streams the state of a cubez World over WebSocket so
+that a simulation running headless on a server can be watched from another
+tool. After each step the world is published to the server, which sends every
+connected client a Frame as a JSON text message holding the inspect Report of
+the world along with the transform and bounds of each collider and the
+contacts of the step.
+
+The server only sends; messages from clients are ignored. A client that falls
+behind has frames dropped rather than slowing the simulation down. Web pages
+can only connect from the server's own host or the Server's AllowedOrigins.
+
+*/
+
+package debugserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/harbdog/cubez"
+	"github.com/harbdog/cubez/inspect"
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// defaultEvery is the default number of steps between frames.
+	defaultEvery = 1
+
+	// clientBacklog is the number of frames queued for a client before
+	// newer ones are dropped.
+	clientBacklog = 4
+)
+
+// Frame is the state of the world after a step, sent to the clients as JSON.
+type Frame struct {
+	// Step is the number of steps published before this one.
+	Step uint64 `json:"step"`
+
+	// Report describes the bodies, colliders, joints and settings of the world.
+	*inspect.Report
+
+	// Shapes holds where each collider is, in the same order as the Colliders.
+	Shapes []Shape `json:"shapes"`
+
+	// ContactPoints holds the contacts generated in the step.
+	ContactPoints []Contact `json:"contactPoints"`
+}
+
+// Shape holds where a collider is in World Space.
+type Shape struct {
+	// Transform is the transform of the collider as a 3x4 matrix stored by
+	// columns, the last column being its position.
+	Transform m.Matrix3x4 `json:"transform"`
+
+	// Bounds is the bounding box of the collider. It's nil for planes, which
+	// have no bounds.
+	Bounds *cubez.Bounds `json:"bounds,omitempty"`
+}
+
+// Contact describes a contact between two bodies.
+type Contact struct {
+	Point       m.Vector3 `json:"point"`
+	Normal      m.Vector3 `json:"normal"`
+	Penetration m.Real    `json:"penetration"`
+	Bodies      [2]int    `json:"bodies"`
+}
+
+// Server is an http.Handler that streams frames of a world to the WebSocket
+// clients connected to it. It's safe to use from multiple goroutines.
+type Server struct {
+	// Every is the number of steps between the frames that are sent, so that
+	// a fast simulation doesn't flood the clients. It must be set before the
+	// server is used.
+	// Defaults to 1.
+	Every int
+
+	// AllowedOrigins holds the origins, like "http://localhost:3000", of the
+	// web pages other than the server's own that may connect, since any page
+	// open in the developer's browser could otherwise read the world. An
+	// entry of "*" allows every origin. Clients that send no Origin, like
+	// command line tools, are allowed, since browsers always send one.
+	// Defaults to none, so only pages served from the same host connect.
+	AllowedOrigins []string
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	step    uint64
+}
+
+// client is a connected WebSocket client.
+type client struct {
+	// frames holds the frames waiting to be written to the client.
+	frames chan []byte
+
+	// done is closed when the client has gone.
+	done chan struct{}
+	once sync.Once
+}
+
+// close marks the client as gone.
+func (c *client) close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+// NewServer creates a new Server with no clients.
+func NewServer() *Server {
+	s := new(Server)
+	s.Every = defaultEvery
+	s.clients = make(map[*client]struct{})
+	return s
+}
+
+// Clients returns the number of clients connected.
+func (s *Server) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// Publish sends the state of the world to the clients, and should be called
+// after each step from the goroutine that steps the world. Nothing is built
+// when there are no clients connected.
+func (s *Server) Publish(w *cubez.World) error {
+	s.mu.Lock()
+	step := s.step
+	s.step++
+	every := uint64(s.Every)
+	if every < 1 {
+		every = 1
+	}
+	idle := len(s.clients) == 0
+	s.mu.Unlock()
+	if idle || step%every != 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(NewFrame(w, step))
+	if err != nil {
+		return err
+	}
+	message := frame(opText, data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		select {
+		case c.frames <- message:
+		default:
+			// the client is behind, so it misses this frame
+		}
+	}
+	return nil
+}
+
+// NewFrame builds the Frame for the world as it is now.
+func NewFrame(w *cubez.World, step uint64) *Frame {
+	f := &Frame{Step: step, Report: inspect.Inspect(w)}
+	infinite := cubez.InfiniteBounds()
+	f.Shapes = make([]Shape, len(w.Colliders))
+	for i, c := range w.Colliders {
+		f.Shapes[i].Transform = c.GetTransform()
+		if bounds := cubez.ColliderBounds(c); bounds != infinite {
+			f.Shapes[i].Bounds = &bounds
+		}
+	}
+
+	indexes := make(map[*cubez.RigidBody]int, len(w.Bodies))
+	for i, body := range w.Bodies {
+		indexes[body] = i
+	}
+	contacts := w.GetContacts()
+	f.ContactPoints = make([]Contact, len(contacts))
+	for i, contact := range contacts {
+		info := &f.ContactPoints[i]
+		info.Point = contact.ContactPoint
+		info.Normal = contact.ContactNormal
+		info.Penetration = contact.Penetration
+		for j, body := range contact.Bodies {
+			info.Bodies[j] = -1
+			if index, ok := indexes[body]; ok && body != nil {
+				info.Bodies[j] = index
+			}
+		}
+	}
+	return f
+}
+
+// ServeHTTP upgrades the request to a WebSocket connection and streams the
+// frames to it until it's closed.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isWebSocketRequest(r) {
+		http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+		return
+	}
+	if !isAllowedOrigin(r, s.AllowedOrigins) {
+		http.Error(w, "the origin isn't allowed to connect", http.StatusForbidden)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "the connection can't be upgraded", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if err := writeHandshake(conn, r.Header.Get("Sec-WebSocket-Key")); err != nil {
+		return
+	}
+
+	c := &client{frames: make(chan []byte, clientBacklog), done: make(chan struct{})}
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+	}()
+
+	go s.readClient(rw.Reader, c)
+	for {
+		select {
+		case message := <-c.frames:
+			if _, err := conn.Write(message); err != nil {
+				return
+			}
+		case <-c.done:
+			conn.Write(frame(opClose, nil))
+			return
+		}
+	}
+}
+
+// readClient reads the frames sent by the client until it closes, answering
+// pings.
+func (s *Server) readClient(r *bufio.Reader, c *client) {
+	defer c.close()
+	for {
+		opcode, payload, err := readFrame(r)
+		if err != nil || opcode == opClose {
+			return
+		}
+		if opcode == opPing {
+			select {
+			case c.frames <- frame(opPong, payload):
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package debugserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harbdog/cubez"
+	m "github.com/harbdog/cubez/math"
+)
+
+// handshakeKey is the example key from RFC 6455 and handshakeAccept the
+// answer to it.
+const (
+	handshakeKey    = "dGhlIHNhbXBsZSBub25jZQ=="
+	handshakeAccept = "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+)
+
+// dial connects to the server and sends the WebSocket handshake with the
+// origin given, if any, returning the connection and the response.
+func dial(t *testing.T, server *httptest.Server, origin string) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	request := "GET / HTTP/1.1\r\nHost: " + server.Listener.Addr().String() + "\r\n" +
+		"Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: " + handshakeKey + "\r\n"
+	if origin != "" {
+		request += "Origin: " + origin + "\r\n"
+	}
+	if _, err := conn.Write([]byte(request + "\r\n")); err != nil {
+		t.Fatalf("Failed to send the handshake: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Failed to read the handshake response: %v", err)
+	}
+	return conn, reader, response
+}
+
+// maskedFrame returns the payload in a frame masked the way a client sends it.
+func maskedFrame(opcode byte, payload []byte) []byte {
+	mask := [4]byte{1, 2, 3, 4}
+	f := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	f = append(f, mask[:]...)
+	for i, b := range payload {
+		f = append(f, b^mask[i%4])
+	}
+	return f
+}
+
+func TestHandshakeOrigins(t *testing.T) {
+	s := NewServer()
+	s.AllowedOrigins = []string{"http://tools.example:3000"}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"", true},
+		{"http://" + server.Listener.Addr().String(), true},
+		{"http://tools.example:3000", true},
+		{"http://evil.example", false},
+		{"http://tools.example:3001", false},
+		{"null", false},
+	}
+	for _, test := range tests {
+		conn, _, response := dial(t, server, test.origin)
+		conn.Close()
+		if test.allowed {
+			if response.StatusCode != http.StatusSwitchingProtocols {
+				t.Errorf("Origin %q got status %d; expected the upgrade", test.origin, response.StatusCode)
+			} else if accept := response.Header.Get("Sec-WebSocket-Accept"); accept != handshakeAccept {
+				t.Errorf("Origin %q was accepted with %q; expected %q", test.origin, accept, handshakeAccept)
+			}
+		} else if response.StatusCode != http.StatusForbidden {
+			t.Errorf("Origin %q got status %d; expected it to be refused", test.origin, response.StatusCode)
+		}
+	}
+
+	// an allow-all entry lets any page in
+	s.AllowedOrigins = []string{"*"}
+	conn, _, response := dial(t, server, "http://evil.example")
+	conn.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("A wildcard origin got status %d", response.StatusCode)
+	}
+}
+
+func TestStreamFrames(t *testing.T) {
+	s := NewServer()
+	server := httptest.NewServer(s)
+	defer server.Close()
+	conn, reader, response := dial(t, server, "")
+	defer conn.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("The upgrade failed with status %d", response.StatusCode)
+	}
+	for i := 0; i < 100 && s.Clients() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w := cubez.NewWorld()
+	sphere := cubez.NewCollisionSphere(nil, 0.5)
+	sphere.Body.Position = m.Vector3{0.0, 2.0, 0.0}
+	sphere.Body.CalculateDerivedData()
+	sphere.CalculateDerivedData()
+	w.AddCollider(sphere)
+	w.Step(1.0 / 60.0)
+	if err := s.Publish(w); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	// frames from the server are unmasked text frames holding the JSON
+	header := make([]byte, 2)
+	if _, err := reader.Read(header[:1]); err != nil {
+		t.Fatalf("Failed to read a frame: %v", err)
+	}
+	header[1], _ = reader.ReadByte()
+	if header[0] != 0x80|opText || header[1]&0x80 != 0 {
+		t.Fatalf("The frame started with %x", header)
+	}
+	length := int(header[1])
+	if length == 126 {
+		var extended [2]byte
+		reader.Read(extended[:])
+		length = int(binary.BigEndian.Uint16(extended[:]))
+	}
+	payload := make([]byte, length)
+	for read := 0; read < length; {
+		n, err := reader.Read(payload[read:])
+		if err != nil {
+			t.Fatalf("Failed to read the payload: %v", err)
+		}
+		read += n
+	}
+	var f Frame
+	if err := json.Unmarshal(payload, &f); err != nil {
+		t.Fatalf("The frame wasn't a JSON Frame: %v", err)
+	}
+	if f.Step != 0 || len(f.Shapes) != 1 || f.Shapes[0].Transform[10] != sphere.GetTransform()[10] {
+		t.Errorf("The frame didn't describe the world: %+v", f.Shapes)
+	}
+
+	// a ping is answered with a pong holding the same payload
+	if _, err := conn.Write(maskedFrame(opPing, []byte("hi"))); err != nil {
+		t.Fatalf("Failed to send a ping: %v", err)
+	}
+	pong := make([]byte, 4)
+	for read := 0; read < len(pong); {
+		n, err := reader.Read(pong[read:])
+		if err != nil {
+			t.Fatalf("Failed to read the pong: %v", err)
+		}
+		read += n
+	}
+	if !bytes.Equal(pong, []byte{0x80 | opPong, 2, 'h', 'i'}) {
+		t.Errorf("The ping was answered with %x", pong)
+	}
+}
+
+func TestFrameLengths(t *testing.T) {
+	tests := []struct {
+		length int
+		header []byte
+	}{
+		{0, []byte{0x81, 0}},
+		{125, []byte{0x81, 125}},
+		{126, []byte{0x81, 126, 0, 126}},
+		{70000, []byte{0x81, 127, 0, 0, 0, 0, 0, 1, 0x11, 0x70}},
+	}
+	for _, test := range tests {
+		f := frame(opText, make([]byte, test.length))
+		if !bytes.HasPrefix(f, test.header) || len(f) != len(test.header)+test.length {
+			t.Errorf("A payload of %d bytes was framed as %x... (%d bytes)", test.length, f[:len(test.header)], len(f))
+		}
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	read := func(data []byte) (byte, []byte, error) {
+		return readFrame(bufio.NewReader(bytes.NewReader(data)))
+	}
+
+	opcode, payload, err := read(maskedFrame(opPing, []byte("ping")))
+	if err != nil || opcode != opPing || string(payload) != "ping" {
+		t.Errorf("A masked ping read as %x %q %v", opcode, payload, err)
+	}
+
+	// the payload of data frames is skipped
+	opcode, payload, err = read(maskedFrame(opText, []byte("ignored")))
+	if err != nil || opcode != opText || payload != nil {
+		t.Errorf("A text frame read as %x %q %v", opcode, payload, err)
+	}
+
+	if _, _, err := read(frame(opPing, []byte("x"))); err == nil {
+		t.Error("An unmasked frame from a client was accepted")
+	}
+	long := append([]byte{0x80 | opPing, 0x80 | 126, 0, 200}, make([]byte, 204)...)
+	if _, _, err := read(long); err == nil || !strings.Contains(err.Error(), "control frame") {
+		t.Errorf("A control frame of 200 bytes was accepted: %v", err)
+	}
+	if _, _, err := read([]byte{0x81}); err == nil {
+		t.Error("A truncated frame was accepted")
+	}
+}
+
+func TestIsWebSocketRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if isWebSocketRequest(r) {
+		t.Error("A plain request was taken as an upgrade")
+	}
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "WebSocket")
+	r.Header.Set("Sec-WebSocket-Key", handshakeKey)
+	if !isWebSocketRequest(r) {
+		t.Error("An upgrade request wasn't recognized")
+	}
+	if acceptKey(handshakeKey) != handshakeAccept {
+		t.Errorf("The accept key was %q", acceptKey(handshakeKey))
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"fmt"
+)
+
+// deltaCountBits is the number of bits used for the number of bodies in a delta.
+const deltaCountBits = 32
+
+// EncodeDelta packs the bodies of the current snapshots that look different
+// from the baseline once they're quantized, so that a server only sends the
+// bodies that moved. The baseline should be the state the receiver already
+// has, such as the result of the last delta it acknowledged, and the bodies
+// must be in the same order in both; bodies past the end of the baseline are
+// always sent. The delta holds the number of bodies, a bit for each body that
+// is set if it changed and then the quantized values of the changed bodies.
+func (config *QuantizeConfig) EncodeDelta(baseline []BodySnapshot, current []BodySnapshot) []byte {
+	var w bitWriter
+	w.write(uint64(len(current)), deltaCountBits)
+
+	changed := make([]quantizedBody, 0, len(current))
+	for i := range current {
+		q := config.quantize(&current[i])
+		if i < len(baseline) && config.quantize(&baseline[i]) == q {
+			w.write(0, 1)
+			continue
+		}
+		w.write(1, 1)
+		changed = append(changed, q)
+	}
+	for i := range changed {
+		config.writeBody(&w, &changed[i])
+	}
+	return w.bytes()
+}
+
+// ApplyDelta returns the snapshots made by applying a delta created by
+// EncodeDelta with the same configuration to the baseline it was made
+// against. The baseline isn't changed.
+func (config *QuantizeConfig) ApplyDelta(baseline []BodySnapshot, data []byte) ([]BodySnapshot, error) {
+	if uint(len(data))*8 < deltaCountBits {
+		return nil, fmt.Errorf("%d bytes is too short for a delta", len(data))
+	}
+	r := bitReader{data: data}
+	count := int(r.read(deltaCountBits))
+	if uint(len(data))*8 < deltaCountBits+uint(count) {
+		return nil, fmt.Errorf("%d bytes is too short for a delta of %d bodies", len(data), count)
+	}
+
+	changed := make([]bool, count)
+	var changes uint
+	for i := range changed {
+		changed[i] = r.read(1) == 1
+		if changed[i] {
+			changes++
+		} else if i >= len(baseline) {
+			return nil, fmt.Errorf("the delta keeps body %d but the baseline only has %d bodies", i, len(baseline))
+		}
+	}
+	needed := (deltaCountBits + uint(count) + changes*config.BitsPerBody() + 7) / 8
+	if uint(len(data)) < needed {
+		return nil, fmt.Errorf("%d bytes is too short for a delta with %d changed bodies; need %d", len(data), changes, needed)
+	}
+
+	snapshots := make([]BodySnapshot, count)
+	for i := range snapshots {
+		if !changed[i] {
+			snapshots[i] = baseline[i]
+			continue
+		}
+		q := config.readBody(&r)
+		snapshots[i] = config.dequantize(&q)
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// DistanceJoint keeps a point on each of two bodies between a minimum and a
+// maximum distance apart. With both the same it's a rigid rod, and with no
+// minimum it's a cable that goes slack when the points come together. It's
+// the cheap workhorse for chains, rods and tethers.
+type DistanceJoint struct {
+	ConstraintSoftness
+
+	// Bodies holds the two bodies that are joined. The second body can be nil,
+	// in which case the first is tethered to a fixed point in the world.
+	Bodies [2]*RigidBody
+
+	// Positions holds the joined point on each body in Body Space. If the
+	// second body is nil, its position is in World Space.
+	Positions [2]m.Vector3
+
+	// MinLength is the closest the points can come together.
+	MinLength m.Real
+
+	// MaxLength is the furthest the points can move apart.
+	MaxLength m.Real
+
+	// Restitution is how much the bodies bounce back when the joint goes taut.
+	// Defaults to 0.0.
+	Restitution m.Real
+}
+
+// NewRod creates a new DistanceJoint that holds the points given on each body
+// exactly the length given apart.
+func NewRod(one *RigidBody, positionOne m.Vector3, two *RigidBody, positionTwo m.Vector3, length m.Real) *DistanceJoint {
+	d := new(DistanceJoint)
+	d.Bodies[0] = one
+	d.Bodies[1] = two
+	d.Positions[0] = positionOne
+	d.Positions[1] = positionTwo
+	d.MinLength = length
+	d.MaxLength = length
+	return d
+}
+
+// NewCable creates a new DistanceJoint that stops the points given on each
+// body moving more than the length given apart, bouncing back with the
+// restitution given.
+func NewCable(one *RigidBody, positionOne m.Vector3, two *RigidBody, positionTwo m.Vector3, length m.Real, restitution m.Real) *DistanceJoint {
+	d := NewRod(one, positionOne, two, positionTwo, length)
+	d.MinLength = 0.0
+	d.Restitution = restitution
+	return d
+}
+
+// GetBodies returns the two bodies of the joint.
+func (d *DistanceJoint) GetBodies() [2]*RigidBody {
+	return d.Bodies
+}
+
+// CurrentLength returns the distance between the points of the joint.
+func (d *DistanceJoint) CurrentLength() m.Real {
+	posOne := bodyPointToWorld(d.Bodies[0], &d.Positions[0])
+	posTwo := bodyPointToWorld(d.Bodies[1], &d.Positions[1])
+	posTwo.Sub(&posOne)
+	return posTwo.Magnitude()
+}
+
+// AddContacts generates a contact if the points are too close together or too
+// far apart and appends it to the existing contacts.
+func (d *DistanceJoint) AddContacts(existingContacts []*Contact) (bool, []*Contact) {
+	posOne := bodyPointToWorld(d.Bodies[0], &d.Positions[0])
+	posTwo := bodyPointToWorld(d.Bodies[1], &d.Positions[1])
+	oneToTwo := posTwo
+	oneToTwo.Sub(&posOne)
+	length := oneToTwo.Magnitude()
+
+	var penetration m.Real
+	switch {
+	case length > d.MaxLength:
+		// pull the points together
+		penetration = length - d.MaxLength
+	case length < d.MinLength:
+		// push the points apart
+		penetration = d.MinLength - length
+		oneToTwo.MulWith(-1.0)
+	default:
+		return false, existingContacts
+	}
+	if length <= m.Epsilon {
+		// there's no telling which way to push points that are on top of each other
+		return false, existingContacts
+	}
+
+	c := NewContact()
+	c.Bodies = d.Bodies
+	c.ContactNormal = oneToTwo
+	c.ContactNormal.MulWith(1.0 / length)
+	c.ContactPoint = posOne
+	c.ContactPoint.Add(&posTwo)
+	c.ContactPoint.MulWith(0.5)
+	c.Penetration = penetration
+	c.Friction = 0.0
+	c.Restitution = d.Restitution
+	return true, append(existingContacts, c)
+}
+
+// SpringJoint is a soft DistanceJoint that pulls a point on each of two bodies
+// towards the rest length apart like a damped spring. Unlike the Spring force
+// generator, the spring is solved as an impulse each step using the frequency
+// and damping ratio, so it stays stable even when it is very stiff, which
+// makes it suited to suspension and other springy connections.
+type SpringJoint struct {
+	// Bodies holds the two bodies that are joined. The second body can be nil,
+	// in which case the first is tethered to a fixed point in the world.
+	Bodies [2]*RigidBody
+
+	// Positions holds the joined point on each body in Body Space. If the
+	// second body is nil, its position is in World Space.
+	Positions [2]m.Vector3
+
+	// RestLength is the distance the spring pulls the points towards.
+	RestLength m.Real
+
+	// Frequency is the natural frequency of the spring in hertz; the higher
+	// it is the stiffer the spring.
+	Frequency m.Real
+
+	// DampingRatio is how strongly the oscillation of the spring is damped,
+	// where 1.0 is critically damped and 0.0 is not damped at all.
+	DampingRatio m.Real
+}
+
+// NewSpringJoint creates a new SpringJoint between the points given on each
+// body with the rest length, frequency and damping ratio given.
+func NewSpringJoint(one *RigidBody, positionOne m.Vector3, two *RigidBody, positionTwo m.Vector3, restLength m.Real, frequency m.Real, dampingRatio m.Real) *SpringJoint {
+	s := new(SpringJoint)
+	s.Bodies[0] = one
+	s.Bodies[1] = two
+	s.Positions[0] = positionOne
+	s.Positions[1] = positionTwo
+	s.RestLength = restLength
+	s.Frequency = frequency
+	s.DampingRatio = dampingRatio
+	return s
+}
+
+// GetBodies returns the two bodies of the joint.
+func (s *SpringJoint) GetBodies() [2]*RigidBody {
+	return s.Bodies
+}
+
+// CurrentLength returns the distance between the points of the joint.
+func (s *SpringJoint) CurrentLength() m.Real {
+	posOne := bodyPointToWorld(s.Bodies[0], &s.Positions[0])
+	posTwo := bodyPointToWorld(s.Bodies[1], &s.Positions[1])
+	posTwo.Sub(&posOne)
+	return posTwo.Magnitude()
+}
+
+// AddContacts doesn't generate any contacts since the spring is applied as an
+// impulse instead; it's here to satisfy the Constraint interface.
+func (s *SpringJoint) AddContacts(existingContacts []*Contact) (bool, []*Contact) {
+	return false, existingContacts
+}
+
+// applyImpulses applies the impulse along the line between the points that a
+// damped spring with the Frequency and DampingRatio would give over the step.
+func (s *SpringJoint) applyImpulses(duration m.Real, feedback *JointFeedback) {
+	if duration <= 0.0 || s.Frequency <= 0.0 {
+		return
+	}
+
+	posOne := bodyPointToWorld(s.Bodies[0], &s.Positions[0])
+	posTwo := bodyPointToWorld(s.Bodies[1], &s.Positions[1])
+	normal := posTwo
+	normal.Sub(&posOne)
+	length := normal.Magnitude()
+	if length <= m.Epsilon {
+		return
+	}
+	normal.MulWith(1.0 / length)
+
+	points := [2]m.Vector3{posOne, posTwo}
+	inverseMass, speed := pointResponse(&s.Bodies, &points, &normal)
+	magnitude := springImpulse(inverseMass, length-s.RestLength, speed, s.Frequency, s.DampingRatio, duration)
+	applyPointImpulses(&s.Bodies, &points, &normal, magnitude, feedback)
+}
@@ -0,0 +1,215 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// DumpFormat is the layout a StepDump writes in.
+type DumpFormat int
+
+const (
+	// DumpCSV writes a CSV row for each body and each contact.
+	DumpCSV DumpFormat = iota
+
+	// DumpJSON writes a line of JSON for each step.
+	DumpJSON
+)
+
+var (
+	// dumpBodyHeader and dumpContactHeader are the first two rows of a CSV dump.
+	dumpBodyHeader    = []string{"step", "time", "kind", "index", "px", "py", "pz", "qw", "qx", "qy", "qz", "vx", "vy", "vz", "rx", "ry", "rz", "awake"}
+	dumpContactHeader = []string{"step", "time", "kind", "index", "px", "py", "pz", "nx", "ny", "nz", "penetration", "body1", "body2", "ix", "iy", "iz"}
+)
+
+// DumpStep is the state of a world after a step, as written in JSON.
+type DumpStep struct {
+	Step     int           `json:"step"`
+	Time     m.Real        `json:"time"`
+	Bodies   []DumpBody    `json:"bodies"`
+	Contacts []DumpContact `json:"contacts"`
+}
+
+// DumpBody is the state of a body after a step.
+type DumpBody struct {
+	Position    m.Vector3 `json:"position"`
+	Orientation m.Quat    `json:"orientation"`
+	Velocity    m.Vector3 `json:"velocity"`
+	Rotation    m.Vector3 `json:"rotation"`
+	Awake       bool      `json:"awake"`
+}
+
+// DumpContact is a contact that was resolved in a step. Bodies holds the index
+// of each body in the world, or -1 for none.
+type DumpContact struct {
+	Point       m.Vector3 `json:"point"`
+	Normal      m.Vector3 `json:"normal"`
+	Penetration m.Real    `json:"penetration"`
+	Bodies      [2]int    `json:"bodies"`
+	Impulse     m.Vector3 `json:"impulse"`
+}
+
+// StepDump writes the state of the bodies and the contacts of a world after
+// each step, so that the behavior of two versions of the engine can be
+// diffed numerically or attached to a bug report. Bodies are written in the
+// order of the world's Bodies and referred to by their index in it. The
+// numbers are written with as many digits as it takes to read them back
+// exactly.
+//
+// In CSV the first two rows are the headers of the body rows and the contact
+// rows, which are told apart by their kind column. In JSON each step is a
+// DumpStep on a line of its own.
+type StepDump struct {
+	// Format is the layout the steps are written in.
+	Format DumpFormat
+
+	out     io.Writer
+	csv     *csv.Writer
+	json    *json.Encoder
+	step    int
+	time    m.Real
+	started bool
+	err     error
+}
+
+// NewStepDump creates a new StepDump that writes to the stream in the format
+// given.
+func NewStepDump(out io.Writer, format DumpFormat) *StepDump {
+	d := new(StepDump)
+	d.Format = format
+	d.out = out
+	return d
+}
+
+// Err returns the first error hit while writing, after which nothing more is
+// written.
+func (d *StepDump) Err() error {
+	return d.err
+}
+
+// Flush writes out anything that's buffered and returns the first error hit
+// while writing.
+func (d *StepDump) Flush() error {
+	if d.csv != nil && d.err == nil {
+		d.csv.Flush()
+		d.err = d.csv.Error()
+	}
+	return d.err
+}
+
+// Write writes the state of the world as the next step, which took the
+// duration given. A world with a Dump calls this at the end of each step.
+func (d *StepDump) Write(w *World, duration m.Real) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.step++
+	d.time += duration
+	if d.Format == DumpJSON {
+		d.err = d.writeJSON(w)
+	} else {
+		d.err = d.writeCSV(w)
+	}
+	return d.err
+}
+
+// dumpBodyIndexes maps the bodies of the world to their indexes.
+func dumpBodyIndexes(w *World) map[*RigidBody]int {
+	indexes := make(map[*RigidBody]int, len(w.Bodies))
+	for i, body := range w.Bodies {
+		indexes[body] = i
+	}
+	return indexes
+}
+
+// dumpContactBodies returns the indexes of the bodies of the contact, or -1
+// for a body that's missing or not in the world.
+func dumpContactBodies(c *Contact, indexes map[*RigidBody]int) [2]int {
+	bodies := [2]int{-1, -1}
+	for i, body := range c.Bodies {
+		if index, ok := indexes[body]; ok && body != nil {
+			bodies[i] = index
+		}
+	}
+	return bodies
+}
+
+// writeJSON writes the step as a line of JSON.
+func (d *StepDump) writeJSON(w *World) error {
+	if d.json == nil {
+		d.json = json.NewEncoder(d.out)
+	}
+	step := DumpStep{
+		Step:     d.step,
+		Time:     d.time,
+		Bodies:   make([]DumpBody, len(w.Bodies)),
+		Contacts: make([]DumpContact, len(w.contacts)),
+	}
+	for i, body := range w.Bodies {
+		step.Bodies[i] = DumpBody{body.Position, body.Orientation, body.Velocity, body.Rotation, body.IsAwake}
+	}
+	indexes := dumpBodyIndexes(w)
+	for i, c := range w.contacts {
+		step.Contacts[i] = DumpContact{c.ContactPoint, c.ContactNormal, c.Penetration, dumpContactBodies(c, indexes), c.impulse}
+	}
+	return d.json.Encode(&step)
+}
+
+// writeCSV writes a row for each body and contact of the step.
+func (d *StepDump) writeCSV(w *World) error {
+	if d.csv == nil {
+		d.csv = csv.NewWriter(d.out)
+	}
+	if !d.started {
+		d.started = true
+		if err := d.csv.Write(dumpBodyHeader); err != nil {
+			return err
+		}
+		if err := d.csv.Write(dumpContactHeader); err != nil {
+			return err
+		}
+	}
+
+	format := func(v m.Real) string {
+		return strconv.FormatFloat(float64(v), 'g', -1, 64)
+	}
+	step, time := strconv.Itoa(d.step), format(d.time)
+	for i, body := range w.Bodies {
+		awake := "0"
+		if body.IsAwake {
+			awake = "1"
+		}
+		record := []string{step, time, "body", strconv.Itoa(i),
+			format(body.Position[0]), format(body.Position[1]), format(body.Position[2]),
+			format(body.Orientation[0]), format(body.Orientation[1]), format(body.Orientation[2]), format(body.Orientation[3]),
+			format(body.Velocity[0]), format(body.Velocity[1]), format(body.Velocity[2]),
+			format(body.Rotation[0]), format(body.Rotation[1]), format(body.Rotation[2]),
+			awake,
+		}
+		if err := d.csv.Write(record); err != nil {
+			return err
+		}
+	}
+
+	indexes := dumpBodyIndexes(w)
+	for i, c := range w.contacts {
+		bodies := dumpContactBodies(c, indexes)
+		record := []string{step, time, "contact", strconv.Itoa(i),
+			format(c.ContactPoint[0]), format(c.ContactPoint[1]), format(c.ContactPoint[2]),
+			format(c.ContactNormal[0]), format(c.ContactNormal[1]), format(c.ContactNormal[2]),
+			format(c.Penetration), strconv.Itoa(bodies[0]), strconv.Itoa(bodies[1]),
+			format(c.impulse[0]), format(c.impulse[1]), format(c.impulse[2]),
+		}
+		if err := d.csv.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// defaultEnergyTolerance is the default fraction of the kinetic energy of
+	// a world that its energy can grow by in a step before OnEnergyGrowth is
+	// called.
+	defaultEnergyTolerance = 0.01
+)
+
+// Energy holds the kinetic energy and momentum of a group of bodies. Bodies
+// with infinite mass aren't counted.
+type Energy struct {
+	// Kinetic is the sum of the linear and rotational kinetic energy.
+	Kinetic m.Real
+
+	// Potential is the energy due to gravity, taking the gravity acting on
+	// each body to be the same everywhere and zero at the origin.
+	Potential m.Real
+
+	// LinearMomentum is the sum of the mass times the velocity.
+	LinearMomentum m.Vector3
+
+	// AngularMomentum is the sum of the spin of each body and its orbit
+	// about the origin of World Space.
+	AngularMomentum m.Vector3
+}
+
+// Total returns the kinetic energy plus the potential energy.
+func (e *Energy) Total() m.Real {
+	return e.Kinetic + e.Potential
+}
+
+// AddBody adds the energy and momentum of the body.
+func (e *Energy) AddBody(body *RigidBody) {
+	if !body.HasFiniteMass() {
+		return
+	}
+	mass := body.GetMass()
+	inverseInertia := body.GetInverseInertiaTensorWorld()
+	inertia := inverseInertia.Invert()
+	spin := inertia.MulVector3(&body.Rotation)
+
+	e.Kinetic += 0.5 * (mass*body.Velocity.SquareMagnitude() + body.Rotation.Dot(&spin))
+	center := body.GetCenterOfMassWorld()
+	gravity := body.GetGravity()
+	e.Potential -= mass * gravity.Dot(&center)
+
+	e.LinearMomentum.AddScaled(&body.Velocity, mass)
+	e.AngularMomentum.Add(&spin)
+	orbit := center.Cross(&body.Velocity)
+	e.AngularMomentum.AddScaled(&orbit, mass)
+}
+
+// IslandEnergy is the energy of a group of bodies that are joined or were in
+// contact in the last step.
+type IslandEnergy struct {
+	Energy
+
+	// Bodies holds the bodies of the island in the order of the world.
+	Bodies []*RigidBody
+}
+
+// EnergyReport holds the energy and momentum of a world after a step.
+type EnergyReport struct {
+	// World is the energy of all of the bodies.
+	World Energy
+
+	// Islands breaks the energy down by island, so that the group of bodies
+	// causing a problem can be found.
+	Islands []IslandEnergy
+}
+
+// EnergyFunc is called with the reports from the step before and the step
+// just taken.
+type EnergyFunc func(previous *EnergyReport, current *EnergyReport)
+
+// MeasureEnergy adds up the energy and momentum of the bodies in the world
+// and of each island of bodies.
+func (w *World) MeasureEnergy() *EnergyReport {
+	r := new(EnergyReport)
+	for _, island := range w.findIslands() {
+		e := IslandEnergy{Bodies: island}
+		for _, body := range island {
+			e.AddBody(body)
+			r.World.AddBody(body)
+		}
+		r.Islands = append(r.Islands, e)
+	}
+	return r
+}
+
+// GetEnergy returns the energy measured at the end of the last step, or nil
+// if Diagnostics isn't set.
+func (w *World) GetEnergy() *EnergyReport {
+	return w.energy
+}
+
+// measureStepEnergy measures the energy after a step and calls OnEnergyGrowth
+// if it grew by more than the tolerance since the step before.
+func (w *World) measureStepEnergy() {
+	previous := w.energy
+	w.energy = w.MeasureEnergy()
+	if previous == nil || w.OnEnergyGrowth == nil {
+		return
+	}
+	limit := w.EnergyTolerance
+	if previous.World.Kinetic > 1.0 {
+		limit *= previous.World.Kinetic
+	}
+	if w.energy.World.Total()-previous.World.Total() > limit {
+		w.OnEnergyGrowth(previous, w.energy)
+	}
+}
+
+// findIslands groups the bodies with finite mass that are joined or were in
+// contact in the last step. The islands are in the order of their first body.
+func (w *World) findIslands() [][]*RigidBody {
+	indexes := make(map[*RigidBody]int, len(w.Bodies))
+	parents := make([]int, len(w.Bodies))
+	for i, body := range w.Bodies {
+		indexes[body] = i
+		parents[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+	join := func(one *RigidBody, two *RigidBody) {
+		a, okA := indexes[one]
+		b, okB := indexes[two]
+		if !okA || !okB || !one.HasFiniteMass() || !two.HasFiniteMass() {
+			return
+		}
+		a, b = find(a), find(b)
+		if a < b {
+			parents[b] = a
+		} else {
+			parents[a] = b
+		}
+	}
+
+	for _, j := range w.Joints {
+		join(j.Bodies[0], j.Bodies[1])
+	}
+	for _, c := range w.Constraints {
+		bodies := c.GetBodies()
+		join(bodies[0], bodies[1])
+	}
+	for _, c := range w.contacts {
+		join(c.Bodies[0], c.Bodies[1])
+	}
+
+	// the root of each island is its first body, so they come out in order
+	var islands [][]*RigidBody
+	islandOf := make(map[int]int)
+	for i, body := range w.Bodies {
+		if !body.HasFiniteMass() {
+			continue
+		}
+		root := find(i)
+		index, found := islandOf[root]
+		if !found {
+			index = len(islands)
+			islandOf[root] = index
+			islands = append(islands, nil)
+		}
+		islands[index] = append(islands[index], body)
+	}
+	return islands
+}
@@ -0,0 +1,223 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"sort"
+)
+
+// EventType identifies the kind of an Event.
+type EventType int
+
+const (
+	// EventCollision is sent for each contact generated between two colliders.
+	EventCollision EventType = iota
+
+	// EventSensorEnter is sent when a body enters a sensor.
+	EventSensorEnter
+
+	// EventSensorExit is sent when a body exits a sensor.
+	EventSensorExit
+
+	// EventJoint is sent for each contact generated to hold a joint together.
+	EventJoint
+
+	// EventContactSolved is sent for each contact between two colliders once
+	// the contacts have been resolved, at the end of World.Step, so that the
+	// impulse the contact applied can be read with GetImpulse. Handlers can add
+	// and remove bodies and colliders. Cancelling it has no effect.
+	EventContactSolved
+
+	// EventPairBegin is sent when the bounds of two colliders start to
+	// overlap, if the world's broadphase is an IncrementalBroadphase. It's
+	// sent before their contacts are generated, so handlers must not add or
+	// remove colliders. Cancelling it has no effect.
+	EventPairBegin
+
+	// EventPairEnd is sent like EventPairBegin when the bounds of two
+	// colliders stop overlapping, or one of them was removed from the world.
+	EventPairEnd
+
+	// EventImpact is sent once for each pair of bodies whose contacts were
+	// resolved in the step with an impulse above World.MinImpactImpulse, at
+	// the end of World.Step just before EventContactSolved. Joint contacts
+	// aren't included. Handlers can add and remove bodies and colliders.
+	// Cancelling it has no effect.
+	EventImpact
+)
+
+// Event describes something that happened during World.Step. An Event is only
+// valid for the duration of the call to the handler.
+type Event struct {
+	// Type is the kind of event.
+	Type EventType
+
+	// Bodies holds the bodies involved in the event; the second body is nil
+	// for sensor events and for contacts against static geometry.
+	Bodies [2]*RigidBody
+
+	// Contact is the contact for collision, joint and contact solved events.
+	Contact *Contact
+
+	// Sensor is the sensor for sensor events.
+	Sensor *Sensor
+
+	// Joint is the joint for joint events from a Joint.
+	Joint *Joint
+
+	// Constraint is the constraint for joint events from a Constraint.
+	Constraint Constraint
+
+	// Colliders holds the colliders for pair events.
+	Colliders [2]Collider
+
+	// Impact is the impact for impact events.
+	Impact *Impact
+
+	consumed  bool
+	cancelled bool
+}
+
+// Consume stops the event from being passed on to any handlers with a lower
+// priority than the one that's handling it.
+func (e *Event) Consume() {
+	e.consumed = true
+}
+
+// Cancel consumes the event and cancels its effect. For collision and joint
+// events the contact is dropped before it's resolved, and for sensor events
+// the sensor's own OnEnter or OnExit callback is not called.
+func (e *Event) Cancel() {
+	e.consumed = true
+	e.cancelled = true
+}
+
+// IsConsumed returns true if a handler has consumed or cancelled the event.
+func (e *Event) IsConsumed() bool {
+	return e.consumed
+}
+
+// IsCancelled returns true if a handler has cancelled the event.
+func (e *Event) IsCancelled() bool {
+	return e.cancelled
+}
+
+// EventHandler is called with each event a subscriber has asked for.
+type EventHandler func(e *Event)
+
+// SubscriptionID identifies a subscription so that it can be removed.
+type SubscriptionID int
+
+// subscription is a handler waiting for one type of event.
+type subscription struct {
+	id       SubscriptionID
+	event    EventType
+	priority int
+	handler  EventHandler
+}
+
+// EventDispatcher passes the events of a World on to its subscribers. Handlers
+// with a higher priority are called first and handlers with the same priority
+// are called in the order they subscribed. Handlers can subscribe and
+// unsubscribe while an event is being dispatched; the changes take effect from
+// the next event.
+type EventDispatcher struct {
+	// subscriptions holds the subscriptions sorted by priority. It's replaced
+	// rather than changed in place so that a dispatch can keep going over the
+	// subscriptions it started with.
+	subscriptions []subscription
+	nextID        SubscriptionID
+}
+
+// NewEventDispatcher creates a new EventDispatcher without any subscribers.
+func NewEventDispatcher() *EventDispatcher {
+	d := new(EventDispatcher)
+	return d
+}
+
+// Subscribe adds a handler for the event type with the given priority and
+// returns the id that removes it again with Unsubscribe.
+func (d *EventDispatcher) Subscribe(event EventType, priority int, handler EventHandler) SubscriptionID {
+	d.nextID++
+	subs := make([]subscription, len(d.subscriptions), len(d.subscriptions)+1)
+	copy(subs, d.subscriptions)
+	subs = append(subs, subscription{d.nextID, event, priority, handler})
+	sort.SliceStable(subs, func(i, j int) bool {
+		return subs[i].priority > subs[j].priority
+	})
+	d.subscriptions = subs
+	return d.nextID
+}
+
+// Unsubscribe removes the handler with the id given.
+func (d *EventDispatcher) Unsubscribe(id SubscriptionID) {
+	for i, s := range d.subscriptions {
+		if s.id == id {
+			subs := make([]subscription, 0, len(d.subscriptions)-1)
+			subs = append(subs, d.subscriptions[:i]...)
+			d.subscriptions = append(subs, d.subscriptions[i+1:]...)
+			return
+		}
+	}
+}
+
+// HasSubscribers returns true if there's a handler for the event type.
+func (d *EventDispatcher) HasSubscribers(event EventType) bool {
+	if d == nil {
+		return false
+	}
+	for _, s := range d.subscriptions {
+		if s.event == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatch passes the event to the handlers for its type until one of them
+// consumes it and returns false if the event was cancelled.
+func (d *EventDispatcher) Dispatch(e *Event) bool {
+	if d == nil {
+		return true
+	}
+	// handlers can subscribe and unsubscribe, but that replaces the slice
+	// rather than changing the one being ranged over
+	for _, s := range d.subscriptions {
+		if s.event != e.Type {
+			continue
+		}
+		s.handler(e)
+		if e.consumed {
+			break
+		}
+	}
+	return !e.cancelled
+}
+
+// dispatchContacts sends an event for each of the contacts, based on the
+// template given, returning the contacts that weren't cancelled.
+func (d *EventDispatcher) dispatchContacts(template Event, contacts []*Contact) []*Contact {
+	if !d.HasSubscribers(template.Type) {
+		return contacts
+	}
+	kept := contacts[:0]
+	for _, c := range contacts {
+		e := template
+		e.Bodies = c.Bodies
+		e.Contact = c
+		if d.Dispatch(&e) {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// dispatchPairs sends an event of the type given for each of the pairs.
+func (d *EventDispatcher) dispatchPairs(event EventType, pairs []ColliderPair) {
+	for _, p := range pairs {
+		e := Event{Type: event, Colliders: [2]Collider{p.One, p.Two}}
+		e.Bodies = [2]*RigidBody{p.One.GetBody(), p.Two.GetBody()}
+		d.Dispatch(&e)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEventPriority(t *testing.T) {
+	// higher priorities go first and equal ones in the order they subscribed
+	d := NewEventDispatcher()
+	var calls []string
+	subscribe := func(name string, priority int) SubscriptionID {
+		return d.Subscribe(EventCollision, priority, func(e *Event) {
+			calls = append(calls, name)
+		})
+	}
+	subscribe("low", -1)
+	subscribe("first", 0)
+	subscribe("high", 5)
+	subscribe("second", 0)
+	d.Subscribe(EventJoint, 10, func(e *Event) {
+		calls = append(calls, "joint")
+	})
+	if !d.Dispatch(&Event{Type: EventCollision}) {
+		t.Error("An event nobody cancelled was reported as cancelled")
+	}
+	if expected := []string{"high", "first", "second", "low"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("The handlers were called in the order %v; expected %v", calls, expected)
+	}
+}
+
+func TestEventConsumeCancel(t *testing.T) {
+	d := NewEventDispatcher()
+	var calls []string
+	var action func(e *Event)
+	d.Subscribe(EventCollision, 1, func(e *Event) {
+		calls = append(calls, "high")
+		if action != nil {
+			action(e)
+		}
+	})
+	d.Subscribe(EventCollision, 0, func(e *Event) {
+		calls = append(calls, "low")
+	})
+
+	// a consumed event stops at the handler that consumed it but goes ahead
+	action = (*Event).Consume
+	e := Event{Type: EventCollision}
+	if !d.Dispatch(&e) || !e.IsConsumed() || e.IsCancelled() {
+		t.Errorf("A consumed event was dispatched as consumed %v and cancelled %v", e.IsConsumed(), e.IsCancelled())
+	}
+	if len(calls) != 1 {
+		t.Errorf("A consumed event was passed to %v; expected only the first handler", calls)
+	}
+
+	// a cancelled event stops too and reports that it was cancelled
+	calls = nil
+	action = (*Event).Cancel
+	e = Event{Type: EventCollision}
+	if d.Dispatch(&e) || !e.IsConsumed() || !e.IsCancelled() {
+		t.Errorf("A cancelled event was dispatched as consumed %v and cancelled %v", e.IsConsumed(), e.IsCancelled())
+	}
+	if len(calls) != 1 {
+		t.Errorf("A cancelled event was passed to %v; expected only the first handler", calls)
+	}
+}
+
+func TestEventSubscribeDuringDispatch(t *testing.T) {
+	d := NewEventDispatcher()
+	var calls []string
+	var second SubscriptionID
+	d.Subscribe(EventCollision, 0, func(e *Event) {
+		calls = append(calls, "first")
+		if second != 0 {
+			// unsubscribing the next handler and subscribing one ahead of
+			// all the others only counts from the next event
+			d.Unsubscribe(second)
+			second = 0
+			d.Subscribe(EventCollision, 10, func(e *Event) {
+				calls = append(calls, "new")
+			})
+		}
+	})
+	second = d.Subscribe(EventCollision, 0, func(e *Event) {
+		calls = append(calls, "second")
+	})
+	d.Subscribe(EventCollision, 0, func(e *Event) {
+		calls = append(calls, "third")
+	})
+
+	d.Dispatch(&Event{Type: EventCollision})
+	if expected := []string{"first", "second", "third"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("The handlers were called in the order %v while changing them; expected %v", calls, expected)
+	}
+	calls = nil
+	d.Dispatch(&Event{Type: EventCollision})
+	if expected := []string{"new", "first", "third"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("The handlers were called in the order %v after changing them; expected %v", calls, expected)
+	}
+}
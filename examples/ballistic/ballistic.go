@@ -8,20 +8,21 @@ import (
 	glfw "github.com/go-gl/glfw/v3.1/glfw"
 	mgl "github.com/go-gl/mathgl/mgl32"
 	"github.com/harbdog/cubez"
-	ex "github.com/harbdog/cubez/examples"
+	"github.com/harbdog/cubez/examples/renderer"
 	m "github.com/harbdog/cubez/math"
 )
 
 var (
-	app *ex.ExampleApp
+	app *renderer.ExampleApp
 
-	cube      *ex.Entity
-	backboard *ex.Entity
-	bullets   []*ex.Entity
+	cube      *renderer.Entity
+	backboard *renderer.Entity
+	bullets   []*renderer.Entity
 
 	colorShader uint32
+	shotType    = &cubez.ProjectilePistol
 	groundPlane *cubez.CollisionPlane
-	ground      *ex.Renderable
+	ground      *renderer.Renderable
 )
 
 // update object locations
@@ -31,16 +32,18 @@ func updateObjects(delta float64) {
 	body.Integrate(m.Real(delta))
 	cube.Collider.CalculateDerivedData()
 
-	// for now we hack in the position and rotation of the collider into the renderable
-	ex.SetGlVector3(&cube.Node.Location, &body.Position)
-	ex.SetGlQuat(&cube.Node.LocalRotation, &body.Orientation)
-
 	for _, bullet := range bullets {
-		bulletBody := bullet.Collider.GetBody()
-		bulletBody.Integrate(m.Real(delta))
+		bullet.Collider.GetBody().Integrate(m.Real(delta))
 		bullet.Collider.CalculateDerivedData()
-		ex.SetGlVector3(&bullet.Node.Location, &bulletBody.Position)
-		ex.SetGlQuat(&bullet.Node.LocalRotation, &bulletBody.Orientation)
+	}
+}
+
+// notifyObjects moves the renderables to where their bodies ended up. The
+// bodies aren't stepped by a World, which would do this itself.
+func notifyObjects() {
+	cube.Collider.GetBody().NotifyTransform()
+	for _, bullet := range bullets {
+		bullet.Collider.GetBody().NotifyTransform()
 	}
 }
 
@@ -103,6 +106,7 @@ func updateCallback(delta float64) {
 	if foundContacts {
 		cubez.ResolveContacts(len(contacts)*8, contacts, m.Real(delta))
 	}
+	notifyObjects()
 }
 
 func renderCallback(delta float64) {
@@ -133,7 +137,7 @@ func renderCallback(delta float64) {
 }
 
 func main() {
-	app = ex.NewApp()
+	app = renderer.NewApp()
 	app.InitGraphics("Ballistic", 800, 600)
 	app.SetKeyCallback(keyCallback)
 	app.OnRender = renderCallback
@@ -142,7 +146,7 @@ func main() {
 
 	// compile the shaders
 	var err error
-	colorShader, err = ex.LoadShaderProgram(ex.DiffuseColorVertShader, ex.DiffuseColorFragShader)
+	colorShader, err = renderer.LoadShaderProgram(renderer.DiffuseColorVertShader, renderer.DiffuseColorFragShader)
 	if err != nil {
 		panic("Failed to compile the shader! " + err.Error())
 	}
@@ -151,12 +155,12 @@ func main() {
 	groundPlane = cubez.NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0)
 
 	// make a ground plane to draw
-	ground = ex.CreatePlaneXZ(-500.0, 500.0, 500.0, -500.0, 1.0)
+	ground = renderer.CreatePlaneXZ(-500.0, 500.0, 500.0, -500.0, 1.0)
 	ground.Shader = colorShader
 	ground.Color = mgl.Vec4{0.6, 0.6, 0.6, 1.0}
 
 	// create a test cube to render
-	cubeNode := ex.CreateCube(-1.0, -1.0, -1.0, 1.0, 1.0, 1.0)
+	cubeNode := renderer.CreateCube(-1.0, -1.0, -1.0, 1.0, 1.0, 1.0)
 	cubeNode.Shader = colorShader
 	cubeNode.Color = mgl.Vec4{1.0, 0.0, 0.0, 1.0}
 
@@ -171,14 +175,16 @@ func main() {
 	cubeCollider.Body.CalculateDerivedData()
 	cubeCollider.CalculateDerivedData()
 
-	// make the entity out of the renerable and collider
-	cube = ex.NewEntity(cubeNode, cubeCollider)
+	// make the entity out of the renerable and collider, with the renderable
+	// following the body as it moves
+	cube = renderer.NewEntity(cubeNode, cubeCollider)
+	renderer.AttachBody(cubeNode, cubeCollider.Body)
 
 	// make a slice of entities for bullets
-	bullets = make([]*ex.Entity, 0, 16)
+	bullets = make([]*renderer.Entity, 0, 16)
 
 	// make the backboard to bound the bullets off of
-	backboardNode := ex.CreateCube(-0.5, -2.0, -0.25, 0.5, 2.0, 0.25)
+	backboardNode := renderer.CreateCube(-0.5, -2.0, -0.25, 0.5, 2.0, 0.25)
 	backboardNode.Shader = colorShader
 	backboardNode.Color = mgl.Vec4{0.25, 0.2, 0.2, 1.0}
 	backboardCollider := cubez.NewCollisionCube(nil, m.Vector3{0.5, 2.0, 0.25})
@@ -186,48 +192,29 @@ func main() {
 	backboardCollider.Body.SetInfiniteMass()
 	backboardCollider.Body.CalculateDerivedData()
 	backboardCollider.CalculateDerivedData()
-	ex.SetGlVector3(&backboardNode.Location, &backboardCollider.Body.Position)
+	backboardNode.Location = renderer.Vec3ToMgl32(&backboardCollider.Body.Position)
 
 	// make the backboard entity
-	backboard = ex.NewEntity(backboardNode, backboardCollider)
+	backboard = renderer.NewEntity(backboardNode, backboardCollider)
 
 	// setup the camera
-	app.CameraPos = mgl.Vec3{-3.0, 3.0, 15.0}
-	app.CameraRotation = mgl.QuatLookAtV(
-		mgl.Vec3{-3.0, 3.0, 15.0},
-		mgl.Vec3{0.0, 1.0, 0.0},
-		mgl.Vec3{0.0, 1.0, 0.0})
+	app.EnableCamera(mgl.Vec3{-3.0, 3.0, 15.0}, mgl.Vec3{0.0, 1.0, 0.0})
 
 	gl.Enable(gl.DEPTH_TEST)
 	app.RenderLoop()
 }
 
 func fire() {
-	var mass m.Real = 1.5
-	var radius m.Real = 0.2
+	// create the collision sphere for the bullet from the current shot type
+	bulletCollider := shotType.NewProjectile(m.Vector3{0.0, 1.5, 20.0}, m.Vector3{0.0, 0.0, -1.0})
 
 	// create a test sphere to render
-	bullet := ex.CreateSphere(float32(radius), 16, 16)
+	bullet := renderer.CreateSphere(float32(bulletCollider.Radius), 16, 16)
 	bullet.Shader = colorShader
 	bullet.Color = mgl.Vec4{0.2, 0.2, 1.0, 1.0}
 
-	// create the collision box for the the bullet
-	bulletCollider := cubez.NewCollisionSphere(nil, radius)
-	bulletCollider.Body.Position = m.Vector3{0.0, 1.5, 20.0}
-
-	var cubeInertia m.Matrix3
-	var coeff m.Real = 0.4 * mass * radius * radius
-	cubeInertia.SetInertiaTensorCoeffs(coeff, coeff, coeff, 0.0, 0.0, 0.0)
-	bulletCollider.GetBody().SetInertiaTensor(&cubeInertia)
-
-	bulletCollider.Body.SetMass(mass)
-	bulletCollider.Body.Velocity = m.Vector3{0.0, 0.0, -40.0}
-	bulletCollider.Body.Acceleration = m.Vector3{0.0, -2.5, 0.0}
-
-	bulletCollider.Body.CalculateDerivedData()
-	bulletCollider.CalculateDerivedData()
-
-	e := ex.NewEntity(bullet, bulletCollider)
+	e := renderer.NewEntity(bullet, bulletCollider)
+	renderer.AttachBody(bullet, bulletCollider.Body)
 	bullets = append(bullets, e)
 }
 
@@ -239,4 +226,18 @@ func keyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action,
 	if key == glfw.KeySpace && action == glfw.Press {
 		fire()
 	}
+
+	// Keys 1-4 pick the type of shot
+	if action == glfw.Press {
+		switch key {
+		case glfw.Key1:
+			shotType = &cubez.ProjectilePistol
+		case glfw.Key2:
+			shotType = &cubez.ProjectileArtillery
+		case glfw.Key3:
+			shotType = &cubez.ProjectileFireball
+		case glfw.Key4:
+			shotType = &cubez.ProjectileLaser
+		}
+	}
 }
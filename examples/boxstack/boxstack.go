@@ -0,0 +1,219 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// boxstack drops a tower of boxes ten high and a pyramid of boxes onto the
+// ground, which makes it the scene to check how well stacks stay up. The
+// world uses the sweep and prune broadphase, sorted contacts and the adaptive
+// solver, and the window title shows how high the top of the tower is.
+//
+// Space pauses, R drops the boxes again, F throws a ball at the stacks and
+// S toggles dropping the boxes already asleep, so they only move once they're
+// hit.
+package main
+
+import (
+	"fmt"
+
+	gl "github.com/go-gl/gl/v3.3-core/gl"
+	glfw "github.com/go-gl/glfw/v3.1/glfw"
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/harbdog/cubez"
+	"github.com/harbdog/cubez/examples/renderer"
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// towerHeight is the number of boxes in the tower.
+	towerHeight = 10
+
+	// pyramidBase is the number of boxes along the bottom row of the pyramid.
+	pyramidBase = 6
+
+	// boxMass is the mass of each box.
+	boxMass = 8.0
+
+	// timestep is the duration of each step of the world.
+	timestep = 1.0 / 60.0
+)
+
+var (
+	colorShader uint32
+	app         *renderer.ExampleApp
+	ground      *renderer.Renderable
+	world       *cubez.World
+	paused      bool
+	asleep      bool
+	sinceStep   float64
+
+	// boxHalfSize is the half-size of every box.
+	boxHalfSize = m.Vector3{0.5, 0.5, 0.5}
+
+	// tower holds the boxes of the tower from the bottom up.
+	tower []*cubez.CollisionCube
+
+	// entities holds the renderable for each box and ball.
+	entities []*renderer.Entity
+)
+
+// newBox makes a box resting at the position given and adds it to the world.
+func newBox(position m.Vector3) *cubez.CollisionCube {
+	box := cubez.NewCollisionCube(nil, boxHalfSize)
+	box.Body.Position = position
+	box.Body.SetMass(boxMass)
+	var inertia m.Matrix3
+	inertia.SetBlockInertiaTensor(&box.HalfSize, boxMass)
+	box.Body.SetInertiaTensor(&inertia)
+	box.Body.CalculateDerivedData()
+	box.CalculateDerivedData()
+	if asleep {
+		box.Body.SetAwake(false)
+	}
+	world.AddCollider(box)
+
+	node := renderer.CreateCube(-0.5, -0.5, -0.5, 0.5, 0.5, 0.5)
+	node.Shader = colorShader
+	node.Color = mgl.Vec4{0.8, 0.5, 0.2, 1.0}
+	entities = append(entities, renderer.NewEntity(node, box))
+	return box
+}
+
+// buildScene makes a new world with the tower and the pyramid, each box
+// starting just above the one below it so they settle as they land.
+func buildScene() {
+	config := cubez.WorldConfig{
+		MaxBodies:   towerHeight + pyramidBase*(pyramidBase+1)/2 + 32,
+		MaxContacts: 1024,
+		MaxPairs:    512,
+	}
+	world = cubez.NewWorldWithConfig(&config)
+	world.SetBroadphase(cubez.NewSweepAndPruneBroadphase())
+	world.SortContacts = true
+	world.AdaptiveSolver = true
+	world.AddCollider(cubez.NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+
+	entities = entities[:0]
+	tower = tower[:0]
+	for i := 0; i < towerHeight; i++ {
+		tower = append(tower, newBox(m.Vector3{-4.0, 0.55 + m.Real(i)*1.05, 0.0}))
+	}
+	for row := 0; row < pyramidBase; row++ {
+		for i := 0; i < pyramidBase-row; i++ {
+			x := 1.0 + m.Real(i)*1.05 + m.Real(row)*0.525
+			newBox(m.Vector3{x, 0.55 + m.Real(row)*1.05, 0.0})
+		}
+	}
+	if app.Debug != nil {
+		app.Debug.World = world
+	}
+	if app.Stats != nil {
+		app.Stats.World = world
+	}
+	sinceStep = 0.0
+}
+
+// throwBall throws a heavy ball from the camera at the middle of the scene.
+func throwBall() {
+	ball := cubez.NewCollisionSphere(nil, 0.4)
+	ball.Body.Position = renderer.Vec3FromMgl32(app.CameraPos)
+	ball.Body.SetMass(40.0)
+	var inertia m.Matrix3
+	inertia.SetSphereInertiaTensor(ball.Radius, 40.0)
+	ball.Body.SetInertiaTensor(&inertia)
+	ball.Body.Velocity = m.Vector3{0.0, 3.0, 0.0}
+	ball.Body.Velocity.Sub(&ball.Body.Position)
+	ball.Body.Velocity.Normalize()
+	ball.Body.Velocity.MulWith(20.0)
+	ball.Body.CalculateDerivedData()
+	ball.CalculateDerivedData()
+	world.AddCollider(ball)
+
+	node := renderer.CreateSphere(float32(ball.Radius), 16, 16)
+	node.Shader = colorShader
+	node.Color = mgl.Vec4{0.2, 0.2, 1.0, 1.0}
+	entities = append(entities, renderer.NewEntity(node, ball))
+}
+
+func updateCallback(delta float64) {
+	if paused {
+		return
+	}
+	sinceStep += delta
+	for sinceStep >= timestep {
+		world.Step(timestep)
+		sinceStep -= timestep
+	}
+
+	top := tower[len(tower)-1].Body.Position
+	app.MainWindow.SetTitle(fmt.Sprintf("Box Stacking - tower top at %.2f of %.2f, %d contacts",
+		top[1], 0.5+m.Real(towerHeight-1), len(world.GetContacts())))
+}
+
+func renderCallback(delta float64) {
+	gl.Viewport(0, 0, int32(app.Width), int32(app.Height))
+	gl.ClearColor(0.196078, 0.6, 0.8, 1.0) // some pov-ray sky blue
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	// make the projection and view matrixes
+	projection := mgl.Perspective(mgl.DegToRad(60.0), float32(app.Width)/float32(app.Height), 1.0, 200.0)
+	view := app.CameraRotation.Mat4()
+	view = view.Mul4(mgl.Translate3D(-app.CameraPos[0], -app.CameraPos[1], -app.CameraPos[2]))
+
+	// draw the boxes and balls where the world has them
+	for _, e := range entities {
+		renderer.SyncFromCollider(e.Node, e.Collider)
+		e.Node.Draw(projection, view)
+	}
+
+	ground.Draw(projection, view)
+}
+
+func main() {
+	app = renderer.NewApp()
+	app.InitGraphics("Box Stacking", 800, 600)
+	app.SetKeyCallback(keyCallback)
+	app.OnRender = renderCallback
+	app.OnUpdate = updateCallback
+	defer app.Terminate()
+
+	var err error
+	colorShader, err = renderer.LoadShaderProgram(renderer.DiffuseColorVertShader, renderer.DiffuseColorFragShader)
+	if err != nil {
+		panic("Failed to compile the diffuse shader! " + err.Error())
+	}
+
+	ground = renderer.CreatePlaneXZ(-500.0, 500.0, 500.0, -500.0, 1.0)
+	ground.Shader = colorShader
+	ground.Color = mgl.Vec4{0.3, 0.6, 0.3, 1.0}
+
+	// setup the camera
+	app.EnableCamera(mgl.Vec3{0.0, 6.0, 16.0}, mgl.Vec3{0.0, 3.0, 0.0})
+
+	buildScene()
+
+	// the debug drawing is toggled with the keys in renderer.DebugKeys and the
+	// stats with renderer.StatsKey
+	app.EnableDebugDraw(world).Categories = 0
+	app.EnableStats(world)
+
+	gl.Enable(gl.DEPTH_TEST)
+	app.RenderLoop()
+}
+
+func keyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if action != glfw.Press {
+		return
+	}
+	switch key {
+	case glfw.KeyEscape:
+		w.SetShouldClose(true)
+	case glfw.KeySpace:
+		paused = !paused
+	case glfw.KeyR:
+		buildScene()
+	case glfw.KeyF:
+		throwBall()
+	case glfw.KeyS:
+		asleep = !asleep
+		buildScene()
+	}
+}
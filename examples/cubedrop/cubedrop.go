@@ -8,7 +8,7 @@ import (
 	glfw "github.com/go-gl/glfw/v3.1/glfw"
 	mgl "github.com/go-gl/mathgl/mgl32"
 	"github.com/harbdog/cubez"
-	ex "github.com/harbdog/cubez/examples"
+	"github.com/harbdog/cubez/examples/renderer"
 	m "github.com/harbdog/cubez/math"
 )
 
@@ -19,11 +19,15 @@ const (
 
 var (
 	diffuseShader uint32
-	app           *ex.ExampleApp
-	cubes         []*ex.Entity
+	app           *renderer.ExampleApp
+	cubes         []*renderer.Entity
 	groundPlane   *cubez.CollisionPlane
-	ground        *ex.Renderable
+	ground        *renderer.Renderable
 	crateTexture  uint32
+
+	// collector holds the contacts found each frame, reusing them from one
+	// frame to the next.
+	collector = cubez.NewContactCollector()
 )
 
 // update object locations
@@ -32,23 +36,18 @@ func updateObjects(delta float64) {
 		body := cube.Collider.GetBody()
 		body.Integrate(m.Real(delta))
 		cube.Collider.CalculateDerivedData()
-
-		// for now we hack in the position and rotation of the collider into the renderable
-		ex.SetGlVector3(&cube.Node.Location, &body.Position)
-		ex.SetGlQuat(&cube.Node.LocalRotation, &body.Orientation)
+		renderer.SyncFromCollider(cube.Node, cube.Collider)
 	}
 }
 
 // see if any of the rigid bodys contact
 func generateContacts(delta float64) (bool, []*cubez.Contact) {
 	var returnFound bool
-	var found bool
-	var contacts []*cubez.Contact
+	collector.Reset()
 
 	for _, cube := range cubes {
 		// see if we have a collision with the ground
-		found, contacts = cube.Collider.CheckAgainstHalfSpace(groundPlane, contacts)
-		if found == true {
+		if collector.Collect(cube.Collider, groundPlane) {
 			returnFound = true
 		}
 
@@ -57,14 +56,13 @@ func generateContacts(delta float64) (bool, []*cubez.Contact) {
 			if cube == otherCube {
 				continue
 			}
-			found, contacts = cubez.CheckForCollisions(cube.Collider, otherCube.Collider, contacts)
-			if found == true {
+			if collector.Collect(cube.Collider, otherCube.Collider) {
 				returnFound = true
 			}
 		}
 	}
 
-	return returnFound, contacts
+	return returnFound, collector.Contacts
 }
 
 func updateCallback(delta float64) {
@@ -95,7 +93,7 @@ func renderCallback(delta float64) {
 }
 
 func main() {
-	app = ex.NewApp()
+	app = renderer.NewApp()
 	app.InitGraphics("Cube Drop", 800, 600)
 	app.SetKeyCallback(keyCallback)
 	app.OnRender = renderCallback
@@ -104,22 +102,22 @@ func main() {
 
 	// compile the shaders
 	var err error
-	diffuseShader, err = ex.LoadShaderProgram(ex.DiffuseTextureVertShader, ex.DiffuseTextureFragShader)
+	diffuseShader, err = renderer.LoadShaderProgram(renderer.DiffuseTextureVertShader, renderer.DiffuseTextureFragShader)
 	if err != nil {
 		panic("Failed to compile the diffuse shader! " + err.Error())
 	}
 
 	// setup the slice of cubes to render
-	cubes = make([]*ex.Entity, 0, 128)
+	cubes = make([]*renderer.Entity, 0, 128)
 
 	// load the grass texture for the ground
-	grassTex, err := ex.LoadImageToTexture(grassTexturePath)
+	grassTex, err := renderer.LoadImageToTexture(grassTexturePath)
 	if err != nil {
 		panic("Failed to load the grass texture! " + err.Error())
 	}
 
 	// load the crate texture for the cubes
-	crateTexture, err = ex.LoadImageToTexture(crateTexturePath)
+	crateTexture, err = renderer.LoadImageToTexture(crateTexturePath)
 	if err != nil {
 		panic("Failed to load the crate texture! " + err.Error())
 	}
@@ -128,17 +126,13 @@ func main() {
 	groundPlane = cubez.NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0)
 
 	// make a ground plane to draw
-	ground = ex.CreatePlaneXZ(-500.0, 500.0, 500.0, -500.0, 64.0)
+	ground = renderer.CreatePlaneXZ(-500.0, 500.0, 500.0, -500.0, 64.0)
 	ground.Shader = diffuseShader
 	ground.Color = mgl.Vec4{1.0, 1.0, 1.0, 1.0}
 	ground.Tex0 = grassTex
 
 	// setup the camera
-	app.CameraPos = mgl.Vec3{0.0, 3.0, 10.0}
-	app.CameraRotation = mgl.QuatLookAtV(
-		mgl.Vec3{0.0, 5.0, 10.0},
-		mgl.Vec3{0.0, 0.0, 0.0},
-		mgl.Vec3{0.0, 1.0, 0.0})
+	app.EnableCamera(mgl.Vec3{0.0, 3.0, 10.0}, mgl.Vec3{0.0, 0.0, 0.0})
 
 	gl.Enable(gl.DEPTH_TEST)
 	app.RenderLoop()
@@ -152,8 +146,8 @@ func fire() {
 	}
 
 	for i := 0; i < cubesToMake; i++ {
-		e := new(ex.Entity)
-		e.Node = ex.CreateCube(-0.5, -0.5, -0.5, 0.5, 0.5, 0.5)
+		e := new(renderer.Entity)
+		e.Node = renderer.CreateCube(-0.5, -0.5, -0.5, 0.5, 0.5, 0.5)
 		e.Node.Shader = diffuseShader
 		e.Node.Color = mgl.Vec4{1.0, 1.0, 1.0, 1.0}
 		e.Node.Location = mgl.Vec3{float32(i*2.0-cubesToMake/2) - 0.5 + offset, 10.0, 0.0}
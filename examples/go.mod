@@ -7,6 +7,7 @@ require (
 	github.com/go-gl/glfw v0.0.0-20220320163800-277f93cfa958
 	github.com/go-gl/mathgl v1.0.0
 	github.com/harbdog/cubez v0.1.1
+	golang.org/x/image v0.0.0-20190321063152-3fc05d484e9f
 )
 
-require golang.org/x/image v0.0.0-20190321063152-3fc05d484e9f // indirect
+replace github.com/harbdog/cubez => ../
@@ -0,0 +1,345 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// joints shows each of the joints in use: a wrecking ball hanging from a
+// chain of spheres held together by rods swings into a wall of boxes, a
+// bridge of planks joined by hinges sags between two pillars, and the sails
+// of a windmill are turned by the motor of a hinge.
+//
+// B drops a box onto the bridge, M turns the motor of the windmill on and
+// off, R starts over and Space pauses. F4 draws the joints.
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	gl "github.com/go-gl/gl/v3.3-core/gl"
+	glfw "github.com/go-gl/glfw/v3.1/glfw"
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/harbdog/cubez"
+	"github.com/harbdog/cubez/examples/renderer"
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// timestep is the duration of each step of the world. The hinges of the
+	// bridge pull apart under its weight unless the steps are short.
+	timestep = 1.0 / 240.0
+
+	// chainLinks is the number of spheres in the chain of the wrecking ball,
+	// each linkRadius in size and linkSpacing apart.
+	chainLinks  = 14
+	linkRadius  = 0.08
+	linkSpacing = 0.3
+	linkMass    = 2.0
+
+	// ballRadius and ballMass are the size and mass of the wrecking ball.
+	ballRadius = 0.6
+	ballMass   = 60.0
+
+	// swingAngle is how far from hanging straight down the wrecking ball
+	// starts, in radians.
+	swingAngle = math.Pi / 3.0
+
+	// plankCount is the number of planks in the bridge, plankLength long and
+	// plankGap apart. bridgeSag is the angle, in radians, the arc of the
+	// bridge turns through from the middle out to each end.
+	plankCount  = 6
+	plankLength = 1.0
+	plankGap    = 0.05
+	plankMass   = 5.0
+	bridgeSag   = 0.3
+
+	// motorSpeed and motorTorque drive the sails of the windmill.
+	motorSpeed  = 1.0
+	motorTorque = 2000.0
+)
+
+var (
+	colorShader uint32
+	app         *renderer.ExampleApp
+	ground      *renderer.Renderable
+	world       *cubez.World
+	paused      bool
+	sinceStep   float64
+
+	// pivot is where the chain of the wrecking ball hangs from, bridgeTop is
+	// the middle of the line between the ends of the bridge and hub is the
+	// center of the sails of the windmill.
+	pivot     = m.Vector3{-8.0, 7.0, 0.0}
+	bridgeTop = m.Vector3{0.0, 3.0, 0.0}
+	hub       = m.Vector3{8.0, 5.0, 0.5}
+
+	// entities holds the renderable for each collider in the world.
+	entities []*renderer.Entity
+
+	// windmill is the hinge that turns the sails.
+	windmill *cubez.HingeJoint
+)
+
+// newBody makes a body at the position given with the mass and inertia given,
+// or a static body that doesn't move if the mass is zero.
+func newBody(position m.Vector3, mass m.Real, inertia *m.Matrix3) *cubez.RigidBody {
+	body := cubez.NewRigidBody()
+	body.Position = position
+	if mass == 0.0 {
+		// static bodies don't fall and start asleep, since an awake body
+		// wakes up any sleeping body it touches
+		body.SetInfiniteMass()
+		body.GravityScale = 0.0
+		body.SetAwake(false)
+	} else {
+		body.SetMass(mass)
+		body.SetInertiaTensor(inertia)
+	}
+	body.CalculateDerivedData()
+	return body
+}
+
+// addBox adds a box with the half-size, mass and color given at the position
+// given, or a static box if the mass is zero.
+func addBox(position m.Vector3, halfSize m.Vector3, mass m.Real, color mgl.Vec4) *cubez.CollisionCube {
+	var inertia m.Matrix3
+	inertia.SetBlockInertiaTensor(&halfSize, mass)
+	box := cubez.NewCollisionCube(newBody(position, mass, &inertia), halfSize)
+	box.CalculateDerivedData()
+	world.AddCollider(box)
+
+	node := renderer.NewColliderRenderable(box)
+	node.Shader = colorShader
+	node.Color = color
+	entities = append(entities, renderer.NewEntity(node, box))
+	return box
+}
+
+// addSphere adds a sphere with the radius, mass and color given at the
+// position given.
+func addSphere(position m.Vector3, radius m.Real, mass m.Real, color mgl.Vec4) *cubez.CollisionSphere {
+	var inertia m.Matrix3
+	inertia.SetSphereInertiaTensor(radius, mass)
+	sphere := cubez.NewCollisionSphere(newBody(position, mass, &inertia), radius)
+	sphere.CalculateDerivedData()
+	world.AddCollider(sphere)
+
+	node := renderer.NewColliderRenderable(sphere)
+	node.Shader = colorShader
+	node.Color = color
+	entities = append(entities, renderer.NewEntity(node, sphere))
+	return sphere
+}
+
+// buildWreckingBall hangs the wrecking ball from the pivot, pulled back to the
+// swing angle with its chain straight, and builds the wall it swings into.
+// Each link of the chain is held to the next by a rod between their centers,
+// and the links are spaced apart so that they don't collide.
+func buildWreckingBall() {
+	down := m.Vector3{-m.Real(math.Sin(swingAngle)), -m.Real(math.Cos(swingAngle)), 0.0}
+	var above *cubez.RigidBody
+	for i := 0; i < chainLinks; i++ {
+		position := pivot
+		position.AddScaled(&down, linkSpacing*m.Real(i+1))
+		link := addSphere(position, linkRadius, linkMass, mgl.Vec4{0.3, 0.3, 0.3, 1.0})
+		if above == nil {
+			// the top link hangs from a point in the world
+			world.AddConstraint(cubez.NewRod(link.Body, m.Vector3{}, nil, pivot, linkSpacing))
+		} else {
+			world.AddConstraint(cubez.NewRod(link.Body, m.Vector3{}, above, m.Vector3{}, linkSpacing))
+		}
+		above = link.Body
+	}
+
+	length := m.Real(linkSpacing + ballRadius)
+	position := above.Position
+	position.AddScaled(&down, length)
+	ball := addSphere(position, ballRadius, ballMass, mgl.Vec4{0.2, 0.2, 0.2, 1.0})
+	world.AddConstraint(cubez.NewRod(ball.Body, m.Vector3{}, above, m.Vector3{}, length))
+
+	// the wall stands just past the bottom of the swing, two boxes high
+	halfSize := m.Vector3{0.4, 0.4, 0.4}
+	for row := 0; row < 2; row++ {
+		for i := -1; i <= 1; i++ {
+			position := m.Vector3{pivot[0] + 1.4, 0.4 + 0.8*m.Real(row), 0.8 * m.Real(i)}
+			addBox(position, halfSize, 8.0, mgl.Vec4{0.8, 0.5, 0.2, 1.0})
+		}
+	}
+}
+
+// buildBridge hangs the planks of the bridge in an arc between two pillars.
+// Neighbouring planks are joined by a hinge across the bridge at the middle
+// of the gap between them, and the planks at each end are hinged to a point
+// in the world at the top of their pillar.
+func buildBridge() {
+	// the hinges lie on a circle whose chords are the length of a plank and
+	// a gap, so that the bridge starts out already sagging
+	chord := m.Real(plankLength + plankGap)
+	step := m.Real(2.0 * bridgeSag / plankCount)
+	radius := chord / (2.0 * m.Real(math.Sin(float64(step)/2.0)))
+	span := 2.0 * radius * m.Real(math.Sin(bridgeSag))
+	center := bridgeTop
+	center[1] += radius * m.Real(math.Cos(bridgeSag))
+	hinge := func(i int) m.Vector3 {
+		angle := -bridgeSag + step*m.Real(i)
+		return m.Vector3{
+			center[0] + radius*m.Real(math.Sin(float64(angle))),
+			center[1] - radius*m.Real(math.Cos(float64(angle))),
+			center[2],
+		}
+	}
+
+	pillarSize := m.Vector3{0.5, bridgeTop[1] / 2.0, 1.0}
+	for _, side := range []m.Real{-1.0, 1.0} {
+		position := m.Vector3{side * (span/2.0 + pillarSize[0] + plankGap), bridgeTop[1] / 2.0, 0.0}
+		addBox(position, pillarSize, 0.0, mgl.Vec4{0.6, 0.6, 0.6, 1.0})
+	}
+
+	axis := m.Vector3{0.0, 0.0, 1.0}
+	halfSize := m.Vector3{plankLength / 2.0, 0.05, 0.8}
+	var last *cubez.RigidBody
+	for i := 0; i < plankCount; i++ {
+		start, end := hinge(i), hinge(i+1)
+		position := start
+		position.Add(&end)
+		position.MulWith(0.5)
+		plank := addBox(position, halfSize, plankMass, mgl.Vec4{0.6, 0.4, 0.2, 1.0})
+		angle := -bridgeSag + step*(m.Real(i)+0.5)
+		plank.Body.Orientation = m.QuatFromAxis(angle, 0.0, 0.0, 1.0)
+		plank.Body.CalculateDerivedData()
+		plank.CalculateDerivedData()
+
+		world.AddConstraint(cubez.NewHingeJoint(plank.Body, last, start, axis, 0.0))
+		last = plank.Body
+	}
+	world.AddConstraint(cubez.NewHingeJoint(last, nil, hinge(plankCount), axis, 0.0))
+}
+
+// buildWindmill builds the tower of the windmill and its sails, which are two
+// crossed boards on one body that's hinged to the world at the hub and turned
+// by the motor of the hinge.
+func buildWindmill() {
+	tower := m.Vector3{0.4, hub[1] / 2.0, 0.4}
+	addBox(m.Vector3{hub[0], tower[1], 0.0}, tower, 0.0, mgl.Vec4{0.6, 0.6, 0.6, 1.0})
+
+	const mass = 20.0
+	blade := m.Vector3{2.5, 0.2, 0.05}
+	var inertia m.Matrix3
+	inertia.SetBlockInertiaTensor(&m.Vector3{2.5, 2.5, 0.05}, mass)
+	sails := newBody(hub, mass, &inertia)
+	for _, halfSize := range []m.Vector3{blade, {blade[1], blade[0], blade[2]}} {
+		board := cubez.NewCollisionCube(sails, halfSize)
+		board.CalculateDerivedData()
+		world.AddCollider(board)
+
+		node := renderer.NewColliderRenderable(board)
+		node.Shader = colorShader
+		node.Color = mgl.Vec4{0.9, 0.9, 0.8, 1.0}
+		entities = append(entities, renderer.NewEntity(node, board))
+	}
+
+	windmill = cubez.NewHingeJoint(sails, nil, hub, m.Vector3{0.0, 0.0, 1.0}, 0.0)
+	windmill.SetMotor(motorSpeed, motorTorque)
+	world.AddConstraint(windmill)
+}
+
+// dropBox drops a box onto the bridge from a random spot above it.
+func dropBox() {
+	position := bridgeTop
+	position[0] += m.Real(rand.Float64()*4.0 - 2.0)
+	position[1] += 3.0
+	addBox(position, m.Vector3{0.3, 0.3, 0.3}, 4.0, mgl.Vec4{0.2, 0.2, 1.0, 1.0})
+}
+
+// buildScene makes a new world with the wrecking ball, the bridge and the
+// windmill.
+func buildScene() {
+	world = cubez.NewWorld()
+	world.AddCollider(cubez.NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	entities = entities[:0]
+	buildWreckingBall()
+	buildBridge()
+	buildWindmill()
+	if app.Debug != nil {
+		app.Debug.World = world
+	}
+	if app.Stats != nil {
+		app.Stats.World = world
+	}
+	sinceStep = 0.0
+}
+
+func updateCallback(delta float64) {
+	if paused {
+		return
+	}
+	sinceStep += delta
+	for sinceStep >= timestep {
+		world.Step(timestep)
+		sinceStep -= timestep
+	}
+}
+
+func renderCallback(delta float64) {
+	gl.Viewport(0, 0, int32(app.Width), int32(app.Height))
+	gl.ClearColor(0.196078, 0.6, 0.8, 1.0) // some pov-ray sky blue
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	projection := app.Projection()
+	view := app.View()
+
+	for _, e := range entities {
+		renderer.SyncFromCollider(e.Node, e.Collider)
+		e.Node.Draw(projection, view)
+	}
+
+	ground.Draw(projection, view)
+}
+
+func main() {
+	app = renderer.NewApp()
+	app.InitGraphics("Joints", 800, 600)
+	app.SetKeyCallback(keyCallback)
+	app.OnRender = renderCallback
+	app.OnUpdate = updateCallback
+	defer app.Terminate()
+
+	var err error
+	colorShader, err = renderer.LoadShaderProgram(renderer.DiffuseColorVertShader, renderer.DiffuseColorFragShader)
+	if err != nil {
+		panic("Failed to compile the diffuse shader! " + err.Error())
+	}
+
+	ground = renderer.CreatePlaneXZ(-500.0, 500.0, 500.0, -500.0, 1.0)
+	ground.Shader = colorShader
+	ground.Color = mgl.Vec4{0.3, 0.6, 0.3, 1.0}
+
+	// look at all three from the front
+	app.EnableCamera(mgl.Vec3{0.0, 6.0, 18.0}, mgl.Vec3{0.0, 3.0, 0.0})
+
+	buildScene()
+
+	// the debug drawing is toggled with the keys in renderer.DebugKeys and the
+	// stats with renderer.StatsKey
+	app.EnableDebugDraw(world).Categories = 0
+	app.EnableStats(world)
+
+	gl.Enable(gl.DEPTH_TEST)
+	app.RenderLoop()
+}
+
+func keyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if action != glfw.Press {
+		return
+	}
+	switch key {
+	case glfw.KeyEscape:
+		w.SetShouldClose(true)
+	case glfw.KeySpace:
+		paused = !paused
+	case glfw.KeyR:
+		buildScene()
+	case glfw.KeyB:
+		dropBox()
+	case glfw.KeyM:
+		windmill.MotorEnabled = !windmill.MotorEnabled
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// netclient is a thin client for the netserver example. It doesn't simulate
+// anything itself; it draws the bodies from the snapshots sent by the server,
+// interpolating between the last two so that the motion is smooth even though
+// the server only sends them at a fixed rate.
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	gl "github.com/go-gl/gl/v3.3-core/gl"
+	glfw "github.com/go-gl/glfw/v3.1/glfw"
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/harbdog/cubez"
+	"github.com/harbdog/cubez/examples/netdemo"
+	"github.com/harbdog/cubez/examples/renderer"
+)
+
+// snapshotBuffer holds the last two snapshots received from the server.
+type snapshotBuffer struct {
+	sync.Mutex
+	previous   []cubez.BodySnapshot
+	latest     []cubez.BodySnapshot
+	latestTick uint32
+	receivedAt time.Time
+}
+
+var (
+	colorShader uint32
+	app         *renderer.ExampleApp
+	cubes       []*renderer.Renderable
+	ground      *renderer.Renderable
+	buffer      snapshotBuffer
+)
+
+// receive reads the snapshots sent by the server, dropping any that arrive
+// out of order.
+func receive(conn *net.UDPConn) {
+	config := netdemo.NewQuantizeConfig()
+	packet := make([]byte, 64*1024)
+	for {
+		n, err := conn.Read(packet)
+		if err != nil {
+			fmt.Printf("failed to read from the server: %v\n", err)
+			return
+		}
+		tick, snapshots, err := netdemo.DecodePacket(config, packet[:n])
+		if err != nil {
+			fmt.Printf("failed to decode a snapshot: %v\n", err)
+			continue
+		}
+
+		buffer.Lock()
+		if buffer.latest == nil || tick > buffer.latestTick {
+			buffer.previous = buffer.latest
+			buffer.latest = snapshots
+			buffer.latestTick = tick
+			buffer.receivedAt = time.Now()
+		}
+		buffer.Unlock()
+	}
+}
+
+// updateCallback places the cubes between the last two snapshots. Rendering a
+// tick behind the server means there's always a pair to blend between.
+func updateCallback(delta float64) {
+	buffer.Lock()
+	defer buffer.Unlock()
+	if buffer.previous == nil || len(buffer.previous) != len(buffer.latest) {
+		return
+	}
+
+	tickDuration := time.Second / netdemo.TickRate
+	alpha := float32(time.Since(buffer.receivedAt)) / float32(tickDuration)
+	if alpha > 1.0 {
+		alpha = 1.0
+	}
+
+	for i, cube := range cubes {
+		if i >= len(buffer.latest) {
+			break
+		}
+		from, to := renderer.Vec3ToMgl32(&buffer.previous[i].Position), renderer.Vec3ToMgl32(&buffer.latest[i].Position)
+		fromRot, toRot := renderer.QuatToMgl32(&buffer.previous[i].Orientation), renderer.QuatToMgl32(&buffer.latest[i].Orientation)
+
+		cube.Location = from.Add(to.Sub(from).Mul(alpha))
+		cube.LocalRotation = mgl.QuatNlerp(fromRot, toRot, alpha)
+	}
+}
+
+func renderCallback(delta float64) {
+	gl.Viewport(0, 0, int32(app.Width), int32(app.Height))
+	gl.ClearColor(0.196078, 0.6, 0.8, 1.0) // some pov-ray sky blue
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	// make the projection and view matrixes
+	projection := mgl.Perspective(mgl.DegToRad(60.0), float32(app.Width)/float32(app.Height), 1.0, 200.0)
+	view := app.CameraRotation.Mat4()
+	view = view.Mul4(mgl.Translate3D(-app.CameraPos[0], -app.CameraPos[1], -app.CameraPos[2]))
+
+	for _, cube := range cubes {
+		cube.Draw(projection, view)
+	}
+	ground.Draw(projection, view)
+}
+
+func main() {
+	// say hello to the server so that it starts sending snapshots
+	addr, err := net.ResolveUDPAddr("udp", netdemo.DefaultAddress)
+	if err != nil {
+		panic("Failed to resolve the server address! " + err.Error())
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		panic("Failed to connect to the server! " + err.Error())
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte(netdemo.Hello)); err != nil {
+		panic("Failed to say hello to the server! " + err.Error())
+	}
+	go receive(conn)
+
+	app = renderer.NewApp()
+	app.InitGraphics("Net Client", 800, 600)
+	app.SetKeyCallback(keyCallback)
+	app.OnRender = renderCallback
+	app.OnUpdate = updateCallback
+	defer app.Terminate()
+
+	colorShader, err = renderer.LoadShaderProgram(renderer.DiffuseColorVertShader, renderer.DiffuseColorFragShader)
+	if err != nil {
+		panic("Failed to compile the diffuse shader! " + err.Error())
+	}
+
+	// build the same scene as the server to know what to draw for each body
+	_, colliders := netdemo.NewScene()
+	for _, c := range colliders {
+		cube := renderer.NewColliderRenderable(c)
+		cube.Shader = colorShader
+		cube.Color = mgl.Vec4{0.8, 0.5, 0.2, 1.0}
+		renderer.SyncFromBody(cube, c.Body)
+		cubes = append(cubes, cube)
+	}
+
+	ground = renderer.CreatePlaneXZ(-500.0, 500.0, 500.0, -500.0, 1.0)
+	ground.Shader = colorShader
+	ground.Color = mgl.Vec4{0.3, 0.6, 0.3, 1.0}
+
+	// setup the camera
+	app.EnableCamera(mgl.Vec3{0.0, 4.0, 12.0}, mgl.Vec3{0.0, 1.0, 0.0})
+
+	gl.Enable(gl.DEPTH_TEST)
+	app.RenderLoop()
+}
+
+func keyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if key == glfw.KeyEscape && action == glfw.Press {
+		w.SetShouldClose(true)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// Package netdemo holds the scene and the wire format shared by the netserver
+// and netclient examples.
+package netdemo
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/harbdog/cubez"
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// TickRate is the number of times per second the server steps the world.
+	TickRate = 30
+
+	// DefaultAddress is the UDP address the server listens on.
+	DefaultAddress = "127.0.0.1:7777"
+
+	// Hello is the packet a client sends to start receiving snapshots.
+	Hello = "HELLO"
+
+	// headerSize is the size of the tick and body count at the start of a packet.
+	headerSize = 6
+)
+
+// CubeHalfSize is the half-size of every cube in the scene.
+var CubeHalfSize = m.Vector3{0.5, 0.5, 0.5}
+
+// NewScene builds the world simulated by the server. The client builds the
+// same scene so that it knows the shape of each body in the snapshots, which
+// are in the order of the world's Bodies.
+func NewScene() (*cubez.World, []*cubez.CollisionCube) {
+	world := cubez.NewWorld()
+	world.AddCollider(cubez.NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+
+	var cubes []*cubez.CollisionCube
+	for layer := 0; layer < 4; layer++ {
+		for i := 0; i < 4; i++ {
+			cube := cubez.NewCollisionCube(nil, CubeHalfSize)
+			cube.Body.Position = m.Vector3{m.Real(i)*1.2 - 1.8, 0.5 + m.Real(layer)*1.1, 0.0}
+			cube.Body.SetMass(8.0)
+			var inertia m.Matrix3
+			inertia.SetBlockInertiaTensor(&cube.HalfSize, 8.0)
+			cube.Body.SetInertiaTensor(&inertia)
+			cube.Body.CalculateDerivedData()
+			cube.CalculateDerivedData()
+
+			world.AddCollider(cube)
+			cubes = append(cubes, cube)
+		}
+	}
+	return world, cubes
+}
+
+// NewQuantizeConfig returns the quantization used to pack the snapshots.
+func NewQuantizeConfig() *cubez.QuantizeConfig {
+	return cubez.NewQuantizeConfig(m.Vector3{-32.0, -1.0, -32.0}, m.Vector3{32.0, 31.0, 32.0})
+}
+
+// EncodePacket packs the snapshots of a tick into a packet.
+func EncodePacket(config *cubez.QuantizeConfig, tick uint32, snapshots []cubez.BodySnapshot) []byte {
+	packet := make([]byte, headerSize)
+	binary.BigEndian.PutUint32(packet[0:4], tick)
+	binary.BigEndian.PutUint16(packet[4:6], uint16(len(snapshots)))
+	return append(packet, config.Encode(snapshots)...)
+}
+
+// DecodePacket unpacks a packet made by EncodePacket.
+func DecodePacket(config *cubez.QuantizeConfig, packet []byte) (uint32, []cubez.BodySnapshot, error) {
+	if len(packet) < headerSize {
+		return 0, nil, fmt.Errorf("the packet is too short to hold a header: %d bytes", len(packet))
+	}
+	tick := binary.BigEndian.Uint32(packet[0:4])
+	count := int(binary.BigEndian.Uint16(packet[4:6]))
+	snapshots, err := config.Decode(packet[headerSize:], count)
+	return tick, snapshots, err
+}
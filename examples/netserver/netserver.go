@@ -0,0 +1,102 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// netserver is a headless, authoritative server that steps a World at a fixed
+// rate and broadcasts quantized snapshots of it over UDP to every client that
+// has said hello. Run the netclient example to watch it.
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/harbdog/cubez"
+	"github.com/harbdog/cubez/examples/netdemo"
+	m "github.com/harbdog/cubez/math"
+)
+
+// clientList is the set of addresses that snapshots are sent to.
+type clientList struct {
+	sync.Mutex
+	addrs map[string]*net.UDPAddr
+}
+
+// add puts the address in the list if it's not already there.
+func (cl *clientList) add(addr *net.UDPAddr) {
+	cl.Lock()
+	defer cl.Unlock()
+	if _, ok := cl.addrs[addr.String()]; !ok {
+		fmt.Printf("client connected from %v\n", addr)
+		cl.addrs[addr.String()] = addr
+	}
+}
+
+// list returns a copy of the addresses.
+func (cl *clientList) list() []*net.UDPAddr {
+	cl.Lock()
+	defer cl.Unlock()
+	addrs := make([]*net.UDPAddr, 0, len(cl.addrs))
+	for _, addr := range cl.addrs {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// listen waits for clients to say hello.
+func listen(conn *net.UDPConn, clients *clientList) {
+	buffer := make([]byte, 64)
+	for {
+		n, addr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			fmt.Printf("failed to read from the socket: %v\n", err)
+			return
+		}
+		if string(buffer[:n]) == netdemo.Hello {
+			clients.add(addr)
+		}
+	}
+}
+
+func main() {
+	addr, err := net.ResolveUDPAddr("udp", netdemo.DefaultAddress)
+	if err != nil {
+		panic("Failed to resolve the server address! " + err.Error())
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		panic("Failed to listen for clients! " + err.Error())
+	}
+	defer conn.Close()
+
+	clients := &clientList{addrs: make(map[string]*net.UDPAddr)}
+	go listen(conn, clients)
+
+	world, _ := netdemo.NewScene()
+	config := netdemo.NewQuantizeConfig()
+	fmt.Printf("serving %d bodies at %d Hz on %v\n", len(world.Bodies), netdemo.TickRate, addr)
+
+	// the world is always stepped by the same amount so every run is the same
+	const duration = m.Real(1.0 / netdemo.TickRate)
+	ticker := time.NewTicker(time.Second / netdemo.TickRate)
+	defer ticker.Stop()
+
+	var tick uint32
+	for range ticker.C {
+		// knock the stack over every ten seconds so there's something to see
+		if tick%(10*netdemo.TickRate) == netdemo.TickRate {
+			world.ApplyExplosion(&m.Vector3{0.3, 0.2, 1.5}, 8.0, 150.0, cubez.FalloffLinear)
+		}
+
+		world.Step(duration)
+		tick++
+
+		packet := netdemo.EncodePacket(config, tick, world.Snapshot())
+		for _, client := range clients.list() {
+			if _, err := conn.WriteToUDP(packet, client); err != nil {
+				fmt.Printf("failed to send a snapshot to %v: %v\n", client, err)
+			}
+		}
+	}
+}
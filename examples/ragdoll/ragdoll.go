@@ -0,0 +1,314 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// ragdoll drops ragdolls onto a flight of stairs made of static boxes. Each
+// ragdoll is built with cubez.NewRagdoll from the bones of a standing pose,
+// so its elbows and knees are hinges and its other joints are cones that
+// swing and twist, and it tumbles down the stairs until it settles and goes
+// to sleep.
+//
+// N drops another ragdoll at the top of the stairs, E sets off an explosion
+// at the bottom of them, R starts over and Space pauses. F4 draws the joints
+// and F5 the sleeping bodies.
+package main
+
+import (
+	"fmt"
+	"math"
+
+	gl "github.com/go-gl/gl/v3.3-core/gl"
+	glfw "github.com/go-gl/glfw/v3.1/glfw"
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/harbdog/cubez"
+	"github.com/harbdog/cubez/examples/renderer"
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// timestep is the duration of each step of the world. The joints of a
+	// ragdoll tangle up as it lands unless the steps are short.
+	timestep = 1.0 / 240.0
+
+	// stairCount is the number of stairs and stairRise and stairRun are the
+	// height and depth of each.
+	stairCount = 8
+	stairRise  = 0.3
+	stairRun   = 0.6
+
+	// ragdollMass is the mass of each ragdoll and pushSpeed is how fast it's
+	// pushed towards the bottom of the stairs when it's dropped.
+	ragdollMass = 70.0
+	pushSpeed   = 3.0
+
+	// restDrift is how far every limb of a ragdoll has to stay within for
+	// restTime seconds before the ragdoll is put to sleep.
+	restDrift = 0.1
+	restTime  = 1.0
+
+	// blastRadius and blastImpulse are the size and strength of the explosion.
+	blastRadius  = 6.0
+	blastImpulse = 40.0
+)
+
+var (
+	colorShader uint32
+	app         *renderer.ExampleApp
+	ground      *renderer.Renderable
+	world       *cubez.World
+	paused      bool
+	sinceStep   float64
+
+	// entities holds the renderable for each stair and limb.
+	entities []*renderer.Entity
+
+	// dolls holds the ragdolls in the world.
+	dolls []*doll
+)
+
+// doll is a ragdoll in the world along with how long it's been at rest and
+// where its limbs were when it came to rest.
+type doll struct {
+	ragdoll *cubez.Ragdoll
+	rest    m.Real
+	anchors [cubez.RagdollBoneCount]m.Vector3
+}
+
+// standingPose returns the bones of a ragdoll standing with its feet at the
+// position given, facing along the Z axis with its left along X.
+func standingPose(feet m.Vector3) []cubez.RagdollBoneTransform {
+	var up m.Quat
+	up.SetIdentity()
+	down := m.QuatFromAxis(math.Pi, 1.0, 0.0, 0.0)
+
+	bone := func(x, y m.Real, orientation m.Quat, length, radius m.Real) cubez.RagdollBoneTransform {
+		position := feet
+		position.Add(&m.Vector3{x, y, 0.0})
+		return cubez.RagdollBoneTransform{Position: position, Orientation: orientation, Length: length, Radius: radius}
+	}
+	return []cubez.RagdollBoneTransform{
+		cubez.BonePelvis:        bone(0.0, 0.95, up, 0.2, 0.15),
+		cubez.BoneChest:         bone(0.0, 1.15, up, 0.4, 0.17),
+		cubez.BoneHead:          bone(0.0, 1.55, up, 0.25, 0.11),
+		cubez.BoneUpperArmLeft:  bone(0.25, 1.5, down, 0.3, 0.06),
+		cubez.BoneLowerArmLeft:  bone(0.25, 1.2, down, 0.28, 0.05),
+		cubez.BoneUpperArmRight: bone(-0.25, 1.5, down, 0.3, 0.06),
+		cubez.BoneLowerArmRight: bone(-0.25, 1.2, down, 0.28, 0.05),
+		cubez.BoneUpperLegLeft:  bone(0.1, 0.95, down, 0.45, 0.08),
+		cubez.BoneLowerLegLeft:  bone(0.1, 0.5, down, 0.45, 0.07),
+		cubez.BoneUpperLegRight: bone(-0.1, 0.95, down, 0.45, 0.08),
+		cubez.BoneLowerLegRight: bone(-0.1, 0.5, down, 0.45, 0.07),
+	}
+}
+
+// addEntity adds a renderable the shape of the collider to be drawn with it.
+func addEntity(c cubez.Collider, color mgl.Vec4) {
+	node := renderer.NewColliderRenderable(c)
+	node.Shader = colorShader
+	node.Color = color
+	entities = append(entities, renderer.NewEntity(node, c))
+}
+
+// buildStairs adds the stairs, climbing away from the camera, each a static
+// box from the ground up to its tread.
+func buildStairs() {
+	for i := 0; i < stairCount; i++ {
+		height := stairRise * m.Real(i+1)
+		stair := cubez.NewCollisionCube(nil, m.Vector3{2.0, height / 2.0, stairRun / 2.0})
+		stair.Body.Position = m.Vector3{0.0, height / 2.0, -stairRun * m.Real(i)}
+		stair.Body.SetInfiniteMass()
+
+		// static bodies don't fall and start asleep, since an awake body
+		// wakes up any sleeping body it touches
+		stair.Body.GravityScale = 0.0
+		stair.Body.SetAwake(false)
+		stair.Body.CalculateDerivedData()
+		stair.CalculateDerivedData()
+		world.AddCollider(stair)
+		addEntity(stair, mgl.Vec4{0.6, 0.6, 0.6, 1.0})
+	}
+}
+
+// dropRagdoll adds a ragdoll standing in the air above the top of the stairs,
+// each one a little to the side of the last.
+func dropRagdoll() {
+	x := m.Real(len(dolls)%3-1) * 1.2
+	top := m.Vector3{x, stairRise*stairCount + 1.0, -stairRun * (stairCount - 1)}
+	r, err := cubez.NewRagdoll(standingPose(top), ragdollMass)
+	if err != nil {
+		panic("Failed to build the ragdoll! " + err.Error())
+	}
+
+	// the ragdoll is put to sleep as a whole by settle, rather than limb by
+	// limb, and the extra damping calms the limbs as they come to rest
+	for _, limb := range r.Limbs {
+		limb.Body.CanSleep = false
+		limb.Body.AngularDamping = 0.3
+		limb.Body.Velocity = m.Vector3{0.0, 0.0, pushSpeed}
+		addEntity(limb, mgl.Vec4{0.9, 0.7, 0.5, 1.0})
+	}
+	world.AddRagdoll(r)
+	d := &doll{ragdoll: r}
+	d.restart()
+	dolls = append(dolls, d)
+}
+
+// settle puts each ragdoll to sleep once none of its limbs have moved far
+// for long enough, and wakes all of it if any limb has been woken up. Limbs
+// sleeping on their own would be dragged along by the joints of those still
+// moving, and the limbs of a ragdoll lying in a heap keep jittering in place,
+// so it's how far they go rather than how fast that says they're at rest.
+func settle(duration m.Real) {
+	for _, d := range dolls {
+		awake, drifted := 0, false
+		for i, limb := range d.ragdoll.Limbs {
+			if limb.Body.IsAwake {
+				awake++
+			}
+			drift := limb.Body.Position
+			drift.Sub(&d.anchors[i])
+			if drift.Magnitude() > restDrift {
+				drifted = true
+			}
+		}
+
+		switch {
+		case awake == 0:
+			continue
+		case awake < len(d.ragdoll.Limbs):
+			for _, limb := range d.ragdoll.Limbs {
+				limb.Body.SetAwake(true)
+			}
+			d.restart()
+		case drifted:
+			d.restart()
+		default:
+			d.rest += duration
+			if d.rest >= restTime {
+				for _, limb := range d.ragdoll.Limbs {
+					limb.Body.SetAwake(false)
+				}
+			}
+		}
+	}
+}
+
+// restart starts timing how long the ragdoll has been at rest over again
+// from where its limbs are now.
+func (d *doll) restart() {
+	d.rest = 0.0
+	for i, limb := range d.ragdoll.Limbs {
+		d.anchors[i] = limb.Body.Position
+	}
+}
+
+// explode sets off an explosion at the bottom of the stairs that throws
+// everything near it up into the air.
+func explode() {
+	blast := cubez.NewExplosion(m.Vector3{0.0, 0.2, 1.0}, blastRadius, blastImpulse)
+	blast.UpwardBias = 1.0
+	world.Explode(blast)
+}
+
+// buildScene makes a new world with the stairs and three ragdolls.
+func buildScene() {
+	world = cubez.NewWorld()
+	world.AddCollider(cubez.NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	entities = entities[:0]
+	dolls = dolls[:0]
+	buildStairs()
+	for i := 0; i < 3; i++ {
+		dropRagdoll()
+	}
+	if app.Debug != nil {
+		app.Debug.World = world
+	}
+	if app.Stats != nil {
+		app.Stats.World = world
+	}
+	sinceStep = 0.0
+}
+
+func updateCallback(delta float64) {
+	if !paused {
+		sinceStep += delta
+		for sinceStep >= timestep {
+			world.Step(timestep)
+			settle(timestep)
+			sinceStep -= timestep
+		}
+	}
+
+	asleep := 0
+	for _, d := range dolls {
+		if !d.ragdoll.Limbs[cubez.BonePelvis].Body.IsAwake {
+			asleep++
+		}
+	}
+	app.MainWindow.SetTitle(fmt.Sprintf("Ragdolls - %d of %d asleep", asleep, len(dolls)))
+}
+
+func renderCallback(delta float64) {
+	gl.Viewport(0, 0, int32(app.Width), int32(app.Height))
+	gl.ClearColor(0.196078, 0.6, 0.8, 1.0) // some pov-ray sky blue
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	projection := app.Projection()
+	view := app.View()
+
+	for _, e := range entities {
+		renderer.SyncFromCollider(e.Node, e.Collider)
+		e.Node.Draw(projection, view)
+	}
+
+	ground.Draw(projection, view)
+}
+
+func main() {
+	app = renderer.NewApp()
+	app.InitGraphics("Ragdolls", 800, 600)
+	app.SetKeyCallback(keyCallback)
+	app.OnRender = renderCallback
+	app.OnUpdate = updateCallback
+	defer app.Terminate()
+
+	var err error
+	colorShader, err = renderer.LoadShaderProgram(renderer.DiffuseColorVertShader, renderer.DiffuseColorFragShader)
+	if err != nil {
+		panic("Failed to compile the diffuse shader! " + err.Error())
+	}
+
+	ground = renderer.CreatePlaneXZ(-500.0, 500.0, 500.0, -500.0, 1.0)
+	ground.Shader = colorShader
+	ground.Color = mgl.Vec4{0.3, 0.6, 0.3, 1.0}
+
+	// look at the stairs from the front and off to the side
+	app.EnableCamera(mgl.Vec3{6.0, 4.0, 6.0}, mgl.Vec3{0.0, 1.0, -2.0})
+
+	buildScene()
+
+	// the debug drawing is toggled with the keys in renderer.DebugKeys and the
+	// stats with renderer.StatsKey
+	app.EnableDebugDraw(world).Categories = 0
+	app.EnableStats(world)
+
+	gl.Enable(gl.DEPTH_TEST)
+	app.RenderLoop()
+}
+
+func keyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if action != glfw.Press {
+		return
+	}
+	switch key {
+	case glfw.KeyEscape:
+		w.SetShouldClose(true)
+	case glfw.KeySpace:
+		paused = !paused
+	case glfw.KeyR:
+		buildScene()
+	case glfw.KeyN:
+		dropRagdoll()
+	case glfw.KeyE:
+		explode()
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"math"
+
+	glfw "github.com/go-gl/glfw/v3.1/glfw"
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+var (
+	// CameraKey is the key that switches the camera between orbiting its
+	// target and flying.
+	CameraKey = glfw.KeyF7
+
+	// cameraFlyKeys are the keys that move the camera while it's flying,
+	// which are kept from the application while it is.
+	cameraFlyKeys = []glfw.Key{glfw.KeyW, glfw.KeyA, glfw.KeyS, glfw.KeyD, glfw.KeyQ, glfw.KeyE}
+)
+
+const (
+	// maxCameraPitch keeps the camera from going over the top of its target,
+	// where looking at it would flip the view over.
+	maxCameraPitch = math.Pi/2.0 - 0.01
+)
+
+// Camera moves the view of the scene with the mouse and keyboard. It either
+// orbits a target, turning around it as the mouse is dragged and zooming in
+// and out with the scroll wheel, or flies freely, moving with WASD, down and
+// up with Q and E, and turning in place as the mouse is dragged.
+type Camera struct {
+	// Target is the point the camera orbits around and looks at.
+	Target mgl.Vec3
+
+	// Distance is how far the camera orbits from Target.
+	Distance float32
+
+	// Yaw is the angle in radians of the camera around the Y axis of Target,
+	// where zero is on the +Z side of it looking towards -Z, and Pitch is
+	// the angle in radians of the camera above it.
+	Yaw   float32
+	Pitch float32
+
+	// Flying moves the camera with the keyboard rather than orbiting Target.
+	// When it stops flying it orbits the point Distance in front of it.
+	Flying bool
+
+	// Button is the mouse button held down to drag the camera around.
+	// Defaults to the right mouse button.
+	Button glfw.MouseButton
+
+	// TurnSpeed is how far in radians the camera turns for each pixel the
+	// mouse is dragged.
+	// Defaults to 0.005.
+	TurnSpeed float32
+
+	// FlySpeed is how fast the camera flies in units per second.
+	// Defaults to 8.
+	FlySpeed float32
+
+	// ZoomStep is the part of Distance the camera zooms in or out by for each
+	// click of the scroll wheel, or the part of it the camera moves forwards
+	// or back while flying.
+	// Defaults to 0.1.
+	ZoomStep float32
+
+	// MinDistance and MaxDistance limit how far the camera zooms in and out.
+	// Default to 1 and 150.
+	MinDistance float32
+	MaxDistance float32
+
+	// position is where the camera is
+	position mgl.Vec3
+
+	// dragging is true while Button is held down and lastX and lastY are
+	// where the cursor was in the last frame of the drag
+	dragging bool
+	lastX    float64
+	lastY    float64
+}
+
+// NewCamera creates a new Camera at the eye position orbiting the target.
+func NewCamera(eye, target mgl.Vec3) *Camera {
+	c := new(Camera)
+	c.Button = glfw.MouseButtonRight
+	c.TurnSpeed = 0.005
+	c.FlySpeed = 8.0
+	c.ZoomStep = 0.1
+	c.MinDistance = 1.0
+	c.MaxDistance = 150.0
+	c.LookAt(eye, target)
+	return c
+}
+
+// LookAt moves the camera to the eye position and turns it to orbit the
+// target from there.
+func (c *Camera) LookAt(eye, target mgl.Vec3) {
+	offset := eye.Sub(target)
+	c.Target = target
+	c.Distance = offset.Len()
+	if c.Distance > 0.0 {
+		c.Yaw = float32(math.Atan2(float64(offset[0]), float64(offset[2])))
+		c.Pitch = float32(math.Asin(float64(offset[1] / c.Distance)))
+	}
+	c.position = eye
+}
+
+// Position returns where the camera is.
+func (c *Camera) Position() mgl.Vec3 {
+	return c.position
+}
+
+// Forward returns the direction the camera is looking in.
+func (c *Camera) Forward() mgl.Vec3 {
+	sinYaw, cosYaw := math.Sincos(float64(c.Yaw))
+	sinPitch, cosPitch := math.Sincos(float64(c.Pitch))
+	return mgl.Vec3{
+		float32(-cosPitch * sinYaw),
+		float32(-sinPitch),
+		float32(-cosPitch * cosYaw),
+	}
+}
+
+// Rotation returns the rotation of the view for the camera, as used by
+// ExampleApp.CameraRotation.
+func (c *Camera) Rotation() mgl.Quat {
+	return mgl.QuatLookAtV(c.position, c.position.Add(c.Forward()), mgl.Vec3{0.0, 1.0, 0.0})
+}
+
+// HandleKey switches the camera between orbiting and flying when CameraKey
+// is pressed. It returns true if the key was used by the camera, which the
+// keys that move it are while it's flying.
+func (c *Camera) HandleKey(key glfw.Key, action glfw.Action) bool {
+	if key == CameraKey {
+		if action == glfw.Press {
+			c.Flying = !c.Flying
+			if !c.Flying {
+				c.Target = c.position.Add(c.Forward().Mul(c.Distance))
+			}
+		}
+		return true
+	}
+	if c.Flying {
+		for _, k := range cameraFlyKeys {
+			if key == k {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HandleScroll zooms the camera in and out for the scroll wheel having moved
+// by the clicks given, or moves it forwards and back while it's flying.
+func (c *Camera) HandleScroll(clicks float64) {
+	step := float32(clicks) * c.ZoomStep
+	if c.Flying {
+		c.position = c.position.Add(c.Forward().Mul(step * c.Distance))
+		return
+	}
+	c.Distance = mgl.Clamp(c.Distance*(1.0-step), c.MinDistance, c.MaxDistance)
+}
+
+// Update turns the camera for any drag of the mouse and moves it for the
+// keys held down in the window while it's flying, over the time given in
+// seconds.
+func (c *Camera) Update(window *glfw.Window, delta float64) {
+	if window.GetMouseButton(c.Button) == glfw.Press {
+		x, y := window.GetCursorPos()
+		if c.dragging {
+			c.Yaw -= float32(x-c.lastX) * c.TurnSpeed
+			c.Pitch += float32(y-c.lastY) * c.TurnSpeed
+			c.Pitch = mgl.Clamp(c.Pitch, -maxCameraPitch, maxCameraPitch)
+		}
+		c.dragging = true
+		c.lastX, c.lastY = x, y
+	} else {
+		c.dragging = false
+	}
+
+	if !c.Flying {
+		c.position = c.Target.Sub(c.Forward().Mul(c.Distance))
+		return
+	}
+
+	forward := c.Forward()
+	up := mgl.Vec3{0.0, 1.0, 0.0}
+	right := forward.Cross(up).Normalize()
+	held := func(key glfw.Key) bool {
+		return window.GetKey(key) == glfw.Press
+	}
+
+	var move mgl.Vec3
+	if held(glfw.KeyW) {
+		move = move.Add(forward)
+	}
+	if held(glfw.KeyS) {
+		move = move.Sub(forward)
+	}
+	if held(glfw.KeyD) {
+		move = move.Add(right)
+	}
+	if held(glfw.KeyA) {
+		move = move.Sub(right)
+	}
+	if held(glfw.KeyE) {
+		move = move.Add(up)
+	}
+	if held(glfw.KeyQ) {
+		move = move.Sub(up)
+	}
+	if move.Len() > 0.0 {
+		c.position = c.position.Add(move.Normalize().Mul(c.FlySpeed * float32(delta)))
+	}
+}
+
+// EnableCamera creates a Camera at the eye position orbiting the target and
+// has the app move its view with it each frame. The OpenGL window needs to be
+// created first.
+func (app *ExampleApp) EnableCamera(eye, target mgl.Vec3) *Camera {
+	c := NewCamera(eye, target)
+	app.Camera = c
+	app.CameraPos = c.Position()
+	app.CameraRotation = c.Rotation()
+	app.MainWindow.SetKeyCallback(app.handleKey)
+	app.MainWindow.SetScrollCallback(app.handleScroll)
+	return c
+}
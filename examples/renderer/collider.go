@@ -0,0 +1,115 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/harbdog/cubez"
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// colliderSphereDetail is the number of rings and sectors of the meshes
+	// made for spheres.
+	colliderSphereDetail = 16
+
+	// colliderPlaneSize is the width of the square meshes made for planes,
+	// which have no edges of their own.
+	colliderPlaneSize = 1000.0
+)
+
+// NewColliderRenderable makes a Renderable with the shape of the collider,
+// for a Shader to be set on. Cubes, spheres, capsules, planes and
+// heightfields are supported and nil is returned for any other collider. Planes and
+// heightfields are placed where they are, while the others are moved to
+// their collider with SyncFromCollider.
+func NewColliderRenderable(c cubez.Collider) *Renderable {
+	switch shape := c.(type) {
+	case *cubez.CollisionCube:
+		hs := shape.HalfSize
+		return CreateCube(float32(-hs[0]), float32(-hs[1]), float32(-hs[2]),
+			float32(hs[0]), float32(hs[1]), float32(hs[2]))
+	case *cubez.CollisionSphere:
+		return CreateSphere(float32(shape.Radius), colliderSphereDetail, colliderSphereDetail)
+	case *cubez.CollisionCapsule:
+		return CreateCapsule(float32(shape.Radius), float32(shape.HalfHeight), colliderSphereDetail, colliderSphereDetail)
+	case *cubez.CollisionPlane:
+		// planes don't move, so the mesh is turned to face along the normal
+		// and moved out to the plane here once
+		const half = colliderPlaneSize / 2.0
+		r := CreatePlaneXZ(-half, half, half, -half, 1.0)
+		normal := Vec3ToMgl32(&shape.Normal)
+		r.LocalRotation = mgl.QuatBetweenVectors(mgl.Vec3{0.0, 1.0, 0.0}, normal)
+		r.Location = normal.Mul(float32(shape.Offset))
+		return r
+	case *cubez.CollisionHeightfield:
+		return CreateHeightfield(shape)
+	default:
+		return nil
+	}
+}
+
+// SyncFromCollider moves the Renderable to where the collider is, including
+// any offset it has from its body. Colliders without a body, like planes and
+// heightfields, don't move and are left alone.
+func SyncFromCollider(r *Renderable, c cubez.Collider) {
+	if c.GetBody() == nil {
+		return
+	}
+	transform := c.GetTransform()
+	mat := Matrix3x4ToMgl32Mat4(&transform)
+	r.Location = mat.Col(3).Vec3()
+	r.LocalRotation = mgl.Mat4ToQuat(mat)
+}
+
+// SyncFromBody moves the Renderable to where the body is, for meshes drawn
+// for a whole body rather than for one of its colliders.
+func SyncFromBody(r *Renderable, body *cubez.RigidBody) {
+	r.Location = mgl.Vec3(body.Position32())
+	q := body.Orientation32()
+	r.LocalRotation = mgl.Quat{W: q[0], V: mgl.Vec3{q[1], q[2], q[3]}}
+}
+
+// AttachBody sets the body's OnTransform to move the Renderable along with
+// it, so that it follows the body after each step without being synced by
+// hand. It replaces any OnTransform the body already had.
+func AttachBody(r *Renderable, body *cubez.RigidBody) {
+	body.OnTransform = func(position m.Vector3, orientation m.Quat) {
+		SetGlVector3(&r.Location, &position)
+		SetGlQuat(&r.LocalRotation, &orientation)
+	}
+}
+
+// CreateHeightfield makes a Renderable of the heightfield's surface in World
+// Space, with its cells split into triangles along the same diagonal the
+// heightfield uses. It needs to be made again if the heights change.
+func CreateHeightfield(hf *cubez.CollisionHeightfield) *Renderable {
+	verts := make([]float32, 0, hf.Columns*hf.Rows*3)
+	normals := make([]float32, 0, hf.Columns*hf.Rows*3)
+	uvs := make([]float32, 0, hf.Columns*hf.Rows*2)
+	for row := 0; row < hf.Rows; row++ {
+		for column := 0; column < hf.Columns; column++ {
+			x := hf.Position[0] + m.Real(column)*hf.CellSize
+			z := hf.Position[2] + m.Real(row)*hf.CellSize
+			_, height := hf.HeightAt(x, z)
+			_, normal := hf.NormalAt(x, z)
+			verts = append(verts, float32(x), float32(height), float32(z))
+			normals = append(normals, float32(normal[0]), float32(normal[1]), float32(normal[2]))
+			uvs = append(uvs, float32(column), float32(row))
+		}
+	}
+
+	indexes := make([]uint32, 0, (hf.Columns-1)*(hf.Rows-1)*6)
+	for row := 0; row < hf.Rows-1; row++ {
+		for column := 0; column < hf.Columns-1; column++ {
+			corner := uint32(row*hf.Columns + column)
+			below := corner + uint32(hf.Columns)
+			indexes = append(indexes,
+				corner, below, below+1,
+				corner, below+1, corner+1)
+		}
+	}
+
+	return createMesh(verts, uvs, normals, indexes)
+}
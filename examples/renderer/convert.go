@@ -0,0 +1,107 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	mgl64 "github.com/go-gl/mathgl/mgl64"
+	m "github.com/harbdog/cubez/math"
+)
+
+// The cubez math package doesn't depend on mathgl, so the conversions between
+// the two live here with the rest of the rendering code. Matrix3x4 is a 4x4
+// matrix with an implied last row of {0, 0, 0, 1}, and both libraries store
+// matrixes in column major order.
+
+// SetGlVector3 copies the values from one math library vector to another.
+func SetGlVector3(dst *mgl.Vec3, src *m.Vector3) {
+	*dst = Vec3ToMgl32(src)
+}
+
+// SetGlQuat copies the values from one math library quaternion to another.
+func SetGlQuat(dst *mgl.Quat, src *m.Quat) {
+	*dst = QuatToMgl32(src)
+}
+
+// Vec3ToMgl32 converts a cubez vector to a mgl32 vector.
+func Vec3ToMgl32(v *m.Vector3) mgl.Vec3 {
+	return mgl.Vec3{float32(v[0]), float32(v[1]), float32(v[2])}
+}
+
+// Vec3FromMgl32 converts a mgl32 vector to a cubez vector.
+func Vec3FromMgl32(v mgl.Vec3) m.Vector3 {
+	return m.Vector3{m.Real(v[0]), m.Real(v[1]), m.Real(v[2])}
+}
+
+// QuatToMgl32 converts a cubez quaternion to a mgl32 quaternion.
+func QuatToMgl32(q *m.Quat) mgl.Quat {
+	return mgl.Quat{W: float32(q[0]), V: mgl.Vec3{float32(q[1]), float32(q[2]), float32(q[3])}}
+}
+
+// QuatFromMgl32 converts a mgl32 quaternion to a cubez quaternion.
+func QuatFromMgl32(q mgl.Quat) m.Quat {
+	return m.Quat{m.Real(q.W), m.Real(q.V[0]), m.Real(q.V[1]), m.Real(q.V[2])}
+}
+
+// Matrix3x4ToMgl32Mat4 converts a cubez transform to a mgl32 4x4 matrix.
+func Matrix3x4ToMgl32Mat4(t *m.Matrix3x4) mgl.Mat4 {
+	return mgl.Mat4{
+		float32(t[0]), float32(t[1]), float32(t[2]), 0.0,
+		float32(t[3]), float32(t[4]), float32(t[5]), 0.0,
+		float32(t[6]), float32(t[7]), float32(t[8]), 0.0,
+		float32(t[9]), float32(t[10]), float32(t[11]), 1.0,
+	}
+}
+
+// Matrix3x4FromMgl32Mat4 converts a mgl32 4x4 matrix to a cubez transform,
+// dropping the last row.
+func Matrix3x4FromMgl32Mat4(t mgl.Mat4) m.Matrix3x4 {
+	return m.Matrix3x4{
+		m.Real(t[0]), m.Real(t[1]), m.Real(t[2]),
+		m.Real(t[4]), m.Real(t[5]), m.Real(t[6]),
+		m.Real(t[8]), m.Real(t[9]), m.Real(t[10]),
+		m.Real(t[12]), m.Real(t[13]), m.Real(t[14]),
+	}
+}
+
+// Vec3ToMgl64 converts a cubez vector to a mgl64 vector.
+func Vec3ToMgl64(v *m.Vector3) mgl64.Vec3 {
+	return mgl64.Vec3{float64(v[0]), float64(v[1]), float64(v[2])}
+}
+
+// Vec3FromMgl64 converts a mgl64 vector to a cubez vector.
+func Vec3FromMgl64(v mgl64.Vec3) m.Vector3 {
+	return m.Vector3{m.Real(v[0]), m.Real(v[1]), m.Real(v[2])}
+}
+
+// QuatToMgl64 converts a cubez quaternion to a mgl64 quaternion.
+func QuatToMgl64(q *m.Quat) mgl64.Quat {
+	return mgl64.Quat{W: float64(q[0]), V: mgl64.Vec3{float64(q[1]), float64(q[2]), float64(q[3])}}
+}
+
+// QuatFromMgl64 converts a mgl64 quaternion to a cubez quaternion.
+func QuatFromMgl64(q mgl64.Quat) m.Quat {
+	return m.Quat{m.Real(q.W), m.Real(q.V[0]), m.Real(q.V[1]), m.Real(q.V[2])}
+}
+
+// Matrix3x4ToMgl64Mat4 converts a cubez transform to a mgl64 4x4 matrix.
+func Matrix3x4ToMgl64Mat4(t *m.Matrix3x4) mgl64.Mat4 {
+	return mgl64.Mat4{
+		float64(t[0]), float64(t[1]), float64(t[2]), 0.0,
+		float64(t[3]), float64(t[4]), float64(t[5]), 0.0,
+		float64(t[6]), float64(t[7]), float64(t[8]), 0.0,
+		float64(t[9]), float64(t[10]), float64(t[11]), 1.0,
+	}
+}
+
+// Matrix3x4FromMgl64Mat4 converts a mgl64 4x4 matrix to a cubez transform,
+// dropping the last row.
+func Matrix3x4FromMgl64Mat4(t mgl64.Mat4) m.Matrix3x4 {
+	return m.Matrix3x4{
+		m.Real(t[0]), m.Real(t[1]), m.Real(t[2]),
+		m.Real(t[4]), m.Real(t[5]), m.Real(t[6]),
+		m.Real(t[8]), m.Real(t[9]), m.Real(t[10]),
+		m.Real(t[12]), m.Real(t[13]), m.Real(t[14]),
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	gl "github.com/go-gl/gl/v3.3-core/gl"
+	glfw "github.com/go-gl/glfw/v3.1/glfw"
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/harbdog/cubez"
+	m "github.com/harbdog/cubez/math"
+)
+
+var (
+	// DebugLineVertShader is a vertex shader for lines colored per vertex
+	DebugLineVertShader = `#version 330
+	uniform mat4 MVP_MATRIX;
+	in vec3 VERTEX_POSITION;
+	in vec4 VERTEX_COLOR;
+	out vec4 vs_color;
+
+	void main()
+	{
+		vs_color = VERTEX_COLOR;
+		gl_Position = MVP_MATRIX * vec4(VERTEX_POSITION, 1.0);
+	}`
+
+	// DebugLineFragShader is a fragment shader for lines colored per vertex
+	DebugLineFragShader = `#version 330
+	in vec4 vs_color;
+	out vec4 colourOut;
+
+	void main()
+	{
+		colourOut = vs_color;
+	}`
+
+	// DebugColors is the color each category of debug drawing is drawn with.
+	DebugColors = map[cubez.DebugCategory]mgl.Vec4{
+		cubez.DebugColliders: {1.0, 0.67, 0.27, 1.0},
+		cubez.DebugBounds:    {0.3, 1.0, 0.3, 1.0},
+		cubez.DebugContacts:  {1.0, 0.13, 0.13, 1.0},
+		cubez.DebugJoints:    {1.0, 1.0, 0.0, 1.0},
+		cubez.DebugSleep:     {0.53, 0.53, 0.53, 1.0},
+		cubez.DebugHeatmap:   {1.0, 0.4, 0.8, 1.0},
+	}
+
+	// DebugKeys is the key that toggles each category of debug drawing. The
+	// grave accent key toggles all of them.
+	DebugKeys = map[glfw.Key]cubez.DebugCategory{
+		glfw.KeyGraveAccent: cubez.DebugAll,
+		glfw.KeyF1:          cubez.DebugColliders,
+		glfw.KeyF2:          cubez.DebugBounds,
+		glfw.KeyF3:          cubez.DebugContacts,
+		glfw.KeyF4:          cubez.DebugJoints,
+		glfw.KeyF5:          cubez.DebugSleep,
+		glfw.KeyF6:          cubez.DebugHeatmap,
+	}
+)
+
+const (
+	// debugVertexSize is the number of floats for each vertex of a line: the
+	// position followed by the color.
+	debugVertexSize = 7
+
+	// debugTextSize is the size of the marker drawn in place of a label.
+	debugTextSize = 0.1
+)
+
+// DebugRenderer is a cubez.DebugDrawer that batches up the lines it's given
+// and draws them all at once with OpenGL.
+type DebugRenderer struct {
+	// World is the world drawn each frame. It can be nil if the lines are
+	// only coming from the world's DebugDrawer.
+	World *cubez.World
+
+	// Categories is the parts of the World that are drawn.
+	// Defaults to cubez.DebugAll.
+	Categories cubez.DebugCategory
+
+	// Shader is the shader program used to draw the lines.
+	Shader uint32
+
+	// Vao is the VAO object used to draw the lines
+	Vao uint32
+
+	// LinesVBO is the VBO that the lines are copied to when drawn
+	LinesVBO uint32
+
+	// lines holds the vertices of the lines batched since the last draw
+	lines []float32
+
+	// shader locations
+	mvpLocation      int32
+	positionLocation int32
+	colorLocation    int32
+}
+
+// NewDebugRenderer creates a new DebugRenderer that draws the world, which
+// may be nil. The OpenGL context needs to be initialized first.
+func NewDebugRenderer(w *cubez.World) (*DebugRenderer, error) {
+	shader, err := LoadShaderProgram(DebugLineVertShader, DebugLineFragShader)
+	if err != nil {
+		return nil, err
+	}
+
+	r := new(DebugRenderer)
+	r.World = w
+	r.Categories = cubez.DebugAll
+	r.Shader = shader
+
+	// the locations are looked up here instead of through the cache since
+	// it's keyed by name and not by shader program
+	r.mvpLocation = gl.GetUniformLocation(shader, gl.Str("MVP_MATRIX\x00"))
+	r.positionLocation = gl.GetAttribLocation(shader, gl.Str("VERTEX_POSITION\x00"))
+	r.colorLocation = gl.GetAttribLocation(shader, gl.Str("VERTEX_COLOR\x00"))
+
+	gl.GenVertexArrays(1, &r.Vao)
+	gl.GenBuffers(1, &r.LinesVBO)
+	return r, nil
+}
+
+// Toggle flips the categories given on or off. If any of them are off they
+// are all turned on.
+func (r *DebugRenderer) Toggle(categories cubez.DebugCategory) {
+	if r.Categories&categories == categories {
+		r.Categories &^= categories
+	} else {
+		r.Categories |= categories
+	}
+}
+
+// HandleKey toggles the categories for the key from DebugKeys when it's
+// pressed, returning true if the key was used.
+func (r *DebugRenderer) HandleKey(key glfw.Key, action glfw.Action) bool {
+	categories, found := DebugKeys[key]
+	if !found {
+		return false
+	}
+	if action == glfw.Press {
+		r.Toggle(categories)
+	}
+	return true
+}
+
+// DrawLine adds a line to the batch.
+func (r *DebugRenderer) DrawLine(from *m.Vector3, to *m.Vector3, category cubez.DebugCategory) {
+	r.addVertex(from, category)
+	r.addVertex(to, category)
+}
+
+// DrawPoint adds a point to the batch as a small cross of lines.
+func (r *DebugRenderer) DrawPoint(point *m.Vector3, size m.Real, category cubez.DebugCategory) {
+	for axis := 0; axis < 3; axis++ {
+		from, to := *point, *point
+		from[axis] -= size
+		to[axis] += size
+		r.DrawLine(&from, &to, category)
+	}
+}
+
+// DrawText adds a marker to the batch above the position. The examples have
+// no text rendering, so the text itself isn't drawn.
+func (r *DebugRenderer) DrawText(position *m.Vector3, text string, category cubez.DebugCategory) {
+	top := *position
+	top[1] += debugTextSize * 4.0
+	r.DrawPoint(&top, debugTextSize, category)
+}
+
+// addVertex adds the position and the color of the category to the batch.
+func (r *DebugRenderer) addVertex(v *m.Vector3, category cubez.DebugCategory) {
+	color := DebugColors[category]
+	r.lines = append(r.lines,
+		float32(v[0]), float32(v[1]), float32(v[2]),
+		color[0], color[1], color[2], color[3])
+}
+
+// Draw adds the World to the batch, if there is one, and then draws every
+// line batched since the last call.
+func (r *DebugRenderer) Draw(perspective mgl.Mat4, view mgl.Mat4) {
+	if r.World != nil && r.Categories != 0 {
+		r.World.DrawDebug(r, r.Categories)
+	}
+	if len(r.lines) == 0 {
+		return
+	}
+
+	const floatSize = 4
+	const stride = floatSize * debugVertexSize
+
+	gl.UseProgram(r.Shader)
+	gl.BindVertexArray(r.Vao)
+
+	mvp := perspective.Mul4(view)
+	gl.UniformMatrix4fv(r.mvpLocation, 1, false, &mvp[0])
+
+	// the lines change every frame so the buffer is filled again each time
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.LinesVBO)
+	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(r.lines), gl.Ptr(&r.lines[0]), gl.STREAM_DRAW)
+	gl.EnableVertexAttribArray(uint32(r.positionLocation))
+	gl.VertexAttribPointer(uint32(r.positionLocation), 3, gl.FLOAT, false, stride, gl.PtrOffset(0))
+	gl.EnableVertexAttribArray(uint32(r.colorLocation))
+	gl.VertexAttribPointer(uint32(r.colorLocation), 4, gl.FLOAT, false, stride, gl.PtrOffset(3*floatSize))
+
+	gl.DrawArrays(gl.LINES, 0, int32(len(r.lines)/debugVertexSize))
+	gl.BindVertexArray(0)
+
+	r.lines = r.lines[:0]
+}
+
+// EnableDebugDraw turns on the debug drawing of the world, which is drawn
+// after OnRender at the end of each frame with the app's camera. The keys in
+// DebugKeys toggle what's drawn.
+func (app *ExampleApp) EnableDebugDraw(w *cubez.World) *DebugRenderer {
+	r, err := NewDebugRenderer(w)
+	if err != nil {
+		panic("Failed to compile the debug shader! " + err.Error())
+	}
+	app.Debug = r
+	app.MainWindow.SetKeyCallback(app.handleKey)
+	return r
+}
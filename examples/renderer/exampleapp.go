@@ -1,12 +1,21 @@
 // Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
 // See the LICENSE file for more details.
 
-package examples
+/*
+
+The renderer module is the small OpenGL framework the examples are drawn with.
+It opens a window with a camera that orbits or flies around the scene, loads
+shaders and makes meshes in the shapes of the cubez colliders, which
+SyncFromCollider keeps where the world has them from one frame to the next.
+It can also draw the debug lines and stats of a World over the scene.
+
+*/
+
+package renderer
 
 import (
 	"errors"
 	"fmt"
-	"math"
 	"runtime"
 	"strings"
 	"time"
@@ -143,21 +152,6 @@ func init() {
 	runtime.LockOSThread()
 }
 
-// SetGlVector3 copies the values from one math library vector to another.
-func SetGlVector3(dst *mgl.Vec3, src *m.Vector3) {
-	dst[0] = float32(src[0])
-	dst[1] = float32(src[1])
-	dst[2] = float32(src[2])
-}
-
-// SetGlQuat copies the values from one math library vector to another.
-func SetGlQuat(dst *mgl.Quat, src *m.Quat) {
-	dst.W = float32(src[0])
-	dst.V[0] = float32(src[1])
-	dst.V[1] = float32(src[2])
-	dst.V[2] = float32(src[3])
-}
-
 type Entity struct {
 	Node     *Renderable
 	Collider cubez.Collider
@@ -191,6 +185,10 @@ type ExampleApp struct {
 	// the camera is looking.
 	CameraRotation mgl.Quat
 
+	// Camera moves CameraPos and CameraRotation with the mouse and keyboard
+	// at the start of each frame if set. See EnableCamera.
+	Camera *Camera
+
 	// OnUpdate is called just prior to OnRender and can be used to update
 	// the application data.
 	OnUpdate RenderLoopCallback
@@ -198,6 +196,17 @@ type ExampleApp struct {
 	// OnRender is called at the end of the render loop and is meant to be
 	// the spot where the application renders the objects to OpenGL.
 	OnRender RenderLoopCallback
+
+	// Debug draws the physics debug visualization after OnRender if set.
+	// See EnableDebugDraw.
+	Debug *DebugRenderer
+
+	// Stats draws the frame rate and the stats of the world over the top of
+	// everything if set. See EnableStats.
+	Stats *StatsOverlay
+
+	// keyCallback is the key handler set by the application
+	keyCallback glfw.KeyCallback
 }
 
 // NewApp returns a new ExampleApp object to control the display of the example app.
@@ -253,7 +262,71 @@ func (app *ExampleApp) Terminate() {
 
 // SetKeyCallback sets a key handler for the main window.
 func (app *ExampleApp) SetKeyCallback(cb glfw.KeyCallback) {
-	app.MainWindow.SetKeyCallback(cb)
+	app.keyCallback = cb
+	app.MainWindow.SetKeyCallback(app.handleKey)
+}
+
+// handleKey gives the debug renderer, the stats and the camera the first
+// look at a key before passing it on to the application's key handler.
+func (app *ExampleApp) handleKey(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if app.Debug != nil && app.Debug.HandleKey(key, action) {
+		return
+	}
+	if app.Stats != nil && app.Stats.HandleKey(key, action) {
+		return
+	}
+	if app.Camera != nil && app.Camera.HandleKey(key, action) {
+		return
+	}
+	if app.keyCallback != nil {
+		app.keyCallback(w, key, scancode, action, mods)
+	}
+}
+
+// handleScroll zooms the camera with the scroll wheel.
+func (app *ExampleApp) handleScroll(w *glfw.Window, xoff float64, yoff float64) {
+	if app.Camera != nil {
+		app.Camera.HandleScroll(yoff)
+	}
+}
+
+// Projection returns the perspective projection matrix for the window.
+func (app *ExampleApp) Projection() mgl.Mat4 {
+	return mgl.Perspective(mgl.DegToRad(60.0), float32(app.Width)/float32(app.Height), 1.0, 200.0)
+}
+
+// View returns the view matrix for the camera.
+func (app *ExampleApp) View() mgl.Mat4 {
+	view := app.CameraRotation.Mat4()
+	return view.Mul4(mgl.Translate3D(-app.CameraPos[0], -app.CameraPos[1], -app.CameraPos[2]))
+}
+
+// ScreenRay returns the ray in World Space from the camera through the point
+// of the window given, in pixels from its top left corner.
+func (app *ExampleApp) ScreenRay(x float64, y float64) (origin m.Vector3, direction m.Vector3) {
+	view, projection := app.View(), app.Projection()
+	window := mgl.Vec3{float32(x), float32(app.Height) - float32(y), 0.0}
+	near, err := mgl.UnProject(window, view, projection, 0, 0, app.Width, app.Height)
+	if err != nil {
+		return
+	}
+	window[2] = 1.0
+	far, err := mgl.UnProject(window, view, projection, 0, 0, app.Width, app.Height)
+	if err != nil {
+		return
+	}
+
+	origin = Vec3FromMgl32(near)
+	direction = Vec3FromMgl32(far.Sub(near))
+	direction.Normalize()
+	return
+}
+
+// CursorRay returns the ray in World Space from the camera through the mouse
+// cursor.
+func (app *ExampleApp) CursorRay() (m.Vector3, m.Vector3) {
+	x, y := app.MainWindow.GetCursorPos()
+	return app.ScreenRay(x, y)
 }
 
 var (
@@ -271,6 +344,13 @@ func (app *ExampleApp) RenderLoop() {
 		deltaNano := loopTime.Sub(lastRenderTime).Nanoseconds()
 		deltaF := float64(deltaNano) * (1.0 / float64(time.Second))
 
+		// move the camera before anything uses it
+		if app.Camera != nil {
+			app.Camera.Update(app.MainWindow, deltaF)
+			app.CameraPos = app.Camera.Position()
+			app.CameraRotation = app.Camera.Rotation()
+		}
+
 		// call the Update callback
 		if app.OnUpdate != nil {
 			app.OnUpdate(deltaF)
@@ -281,6 +361,16 @@ func (app *ExampleApp) RenderLoop() {
 			app.OnRender(deltaF)
 		}
 
+		// draw the physics debug visualization over the top
+		if app.Debug != nil {
+			app.Debug.Draw(app.Projection(), app.View())
+		}
+
+		// and the stats over everything
+		if app.Stats != nil {
+			app.Stats.Draw(deltaF, app.Width, app.Height)
+		}
+
 		// draw the screen and get any input
 		app.MainWindow.SwapBuffers()
 		glfw.PollEvents()
@@ -575,218 +665,3 @@ func LoadImageToTexture(filePath string) (glTex uint32, e error) {
 	gl.GenerateMipmap(gl.TEXTURE_2D)
 	return glTex, nil
 }
-
-// CreateCube makes a new Renderable object with the specified dimensions for the cube.
-func CreateCube(xmin, ymin, zmin, xmax, ymax, zmax float32) *Renderable {
-	/* Cube vertices are layed out like this:
-
-	  +--------+           6          5
-	/ |       /|
-	+--------+ |        1          0        +Y
-	| |      | |                            |___ +X
-	| +------|-+           7          4    /
-	|/       |/                           +Z
-	+--------+          2          3
-
-	*/
-
-	verts := [...]float32{
-		xmax, ymax, zmax, xmin, ymax, zmax, xmin, ymin, zmax, xmax, ymin, zmax, // v0,v1,v2,v3 (front)
-		xmax, ymax, zmin, xmax, ymax, zmax, xmax, ymin, zmax, xmax, ymin, zmin, // v5,v0,v3,v4 (right)
-		xmax, ymax, zmin, xmin, ymax, zmin, xmin, ymax, zmax, xmax, ymax, zmax, // v5,v6,v1,v0 (top)
-		xmin, ymax, zmax, xmin, ymax, zmin, xmin, ymin, zmin, xmin, ymin, zmax, // v1,v6,v7,v2 (left)
-		xmax, ymin, zmax, xmin, ymin, zmax, xmin, ymin, zmin, xmax, ymin, zmin, // v3,v2,v7,v4 (bottom)
-		xmin, ymax, zmin, xmax, ymax, zmin, xmax, ymin, zmin, xmin, ymin, zmin, // v6,v5,v4,v7 (back)
-	}
-	indexes := [...]uint32{
-		0, 1, 2, 2, 3, 0,
-		4, 5, 6, 6, 7, 4,
-		8, 9, 10, 10, 11, 8,
-		12, 13, 14, 14, 15, 12,
-		16, 17, 18, 18, 19, 16,
-		20, 21, 22, 22, 23, 20,
-	}
-	uvs := [...]float32{
-		1.0, 1.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0,
-		1.0, 1.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0,
-		1.0, 1.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0,
-		1.0, 1.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0,
-		1.0, 1.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0,
-		1.0, 1.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0,
-	}
-	normals := [...]float32{
-		0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, // v0,v1,v2,v3 (front)
-		1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, // v5,v0,v3,v4 (right)
-		0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0, // v5,v6,v1,v0 (top)
-		-1, 0, 0, -1, 0, 0, -1, 0, 0, -1, 0, 0, // v1,v6,v7,v2 (left)
-		0, -1, 0, 0, -1, 0, 0, -1, 0, 0, -1, 0, // v3,v2,v7,v4 (bottom)
-		0, 0, -1, 0, 0, -1, 0, 0, -1, 0, 0, -1, // v6,v5,v4,v7 (back)
-	}
-
-	r := NewRenderable()
-	gl.GenVertexArrays(1, &r.Vao)
-	r.FaceCount = 12
-
-	const floatSize = 4
-	const uintSize = 4
-
-	// create a VBO to hold the vertex data
-	gl.GenBuffers(1, &r.VertVBO)
-	gl.BindBuffer(gl.ARRAY_BUFFER, r.VertVBO)
-	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(verts), gl.Ptr(&verts[0]), gl.STATIC_DRAW)
-
-	// create a VBO to hold the uv data
-	gl.GenBuffers(1, &r.UvVBO)
-	gl.BindBuffer(gl.ARRAY_BUFFER, r.UvVBO)
-	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(uvs), gl.Ptr(&uvs[0]), gl.STATIC_DRAW)
-
-	// create a VBO to hold the normals data
-	gl.GenBuffers(1, &r.NormsVBO)
-	gl.BindBuffer(gl.ARRAY_BUFFER, r.NormsVBO)
-	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(normals), gl.Ptr(&normals[0]), gl.STATIC_DRAW)
-
-	// create a VBO to hold the face indexes
-	gl.GenBuffers(1, &r.ElementsVBO)
-	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, r.ElementsVBO)
-	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, uintSize*len(indexes), gl.Ptr(&indexes[0]), gl.STATIC_DRAW)
-
-	return r
-}
-
-// CreateSphere generates a 3d uv-sphere with the given radius and returns a Renderable.
-func CreateSphere(radius float32, rings int, sectors int) *Renderable {
-	// nothing to create
-	if rings < 2 || sectors < 2 {
-		return nil
-	}
-
-	const piDiv2 = math.Pi / 2.0
-
-	verts := make([]float32, 0, rings*sectors)
-	indexes := make([]uint32, 0, rings*sectors)
-	uvs := make([]float32, 0, rings*sectors)
-	normals := make([]float32, 0, rings*sectors)
-
-	R := float64(1.0 / float32(rings-1))
-	S := float64(1.0 / float32(sectors-1))
-
-	for ri := 0; ri < int(rings); ri++ {
-		for si := 0; si < int(sectors); si++ {
-			y := float32(math.Sin(-piDiv2 + math.Pi*float64(ri)*R))
-			x := float32(math.Cos(2.0*math.Pi*float64(si)*S) * math.Sin(math.Pi*float64(ri)*R))
-			z := float32(math.Sin(2.0*math.Pi*float64(si)*S) * math.Sin(math.Pi*float64(ri)*R))
-
-			uvs = append(uvs, float32(si)*float32(S))
-			uvs = append(uvs, float32(ri)*float32(R))
-
-			verts = append(verts, x*radius)
-			verts = append(verts, y*radius)
-			verts = append(verts, z*radius)
-
-			normals = append(normals, x)
-			normals = append(normals, y)
-			normals = append(normals, z)
-
-			currentRow := ri * sectors
-			nextRow := (ri + 1) * sectors
-
-			indexes = append(indexes, uint32(currentRow+si))
-			indexes = append(indexes, uint32(nextRow+si))
-			indexes = append(indexes, uint32(nextRow+si+1))
-
-			indexes = append(indexes, uint32(currentRow+si))
-			indexes = append(indexes, uint32(nextRow+si+1))
-			indexes = append(indexes, uint32(currentRow+si+1))
-		}
-	}
-
-	r := NewRenderable()
-	gl.GenVertexArrays(1, &r.Vao)
-	r.FaceCount = rings * sectors * 2
-
-	const floatSize = 4
-	const uintSize = 4
-
-	// create a VBO to hold the vertex data
-	gl.GenBuffers(1, &r.VertVBO)
-	gl.BindBuffer(gl.ARRAY_BUFFER, r.VertVBO)
-	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(verts), gl.Ptr(&verts[0]), gl.STATIC_DRAW)
-
-	// create a VBO to hold the uv data
-	gl.GenBuffers(1, &r.UvVBO)
-	gl.BindBuffer(gl.ARRAY_BUFFER, r.UvVBO)
-	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(uvs), gl.Ptr(&uvs[0]), gl.STATIC_DRAW)
-
-	// create a VBO to hold the normals data
-	gl.GenBuffers(1, &r.NormsVBO)
-	gl.BindBuffer(gl.ARRAY_BUFFER, r.NormsVBO)
-	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(normals), gl.Ptr(&normals[0]), gl.STATIC_DRAW)
-
-	// create a VBO to hold the face indexes
-	gl.GenBuffers(1, &r.ElementsVBO)
-	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, r.ElementsVBO)
-	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, uintSize*len(indexes), gl.Ptr(&indexes[0]), gl.STATIC_DRAW)
-
-	return r
-}
-
-// CreatePlaneXZ makes a 2d Renderable object on the XZ plane for the given size,
-// where (x0,z0) is the lower left and (x1, z1) is the upper right coordinate.
-func CreatePlaneXZ(x0, z0, x1, z1 float32, scaleUVs float32) *Renderable {
-	verts := [12]float32{
-		x0, 0.0, z0,
-		x1, 0.0, z0,
-		x0, 0.0, z1,
-		x1, 0.0, z1,
-	}
-	indexes := [6]uint32{
-		0, 1, 2,
-		1, 3, 2,
-	}
-	uvs := [8]float32{
-		0.0, 0.0,
-		scaleUVs, 0.0,
-		0.0, scaleUVs,
-		scaleUVs, scaleUVs,
-	}
-
-	normals := [12]float32{
-		0.0, 1.0, 0.0,
-		0.0, 1.0, 0.0,
-		0.0, 1.0, 0.0,
-		0.0, 1.0, 0.0,
-	}
-
-	return createPlane(x0, z0, x1, z1, verts, indexes, uvs, normals)
-}
-
-func createPlane(x0, y0, x1, y1 float32, verts [12]float32, indexes [6]uint32, uvs [8]float32, normals [12]float32) *Renderable {
-	const floatSize = 4
-	const uintSize = 4
-
-	r := NewRenderable()
-	gl.GenVertexArrays(1, &r.Vao)
-	r.FaceCount = 2
-
-	// create a VBO to hold the vertex data
-	gl.GenBuffers(1, &r.VertVBO)
-	gl.BindBuffer(gl.ARRAY_BUFFER, r.VertVBO)
-	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(verts), gl.Ptr(&verts[0]), gl.STATIC_DRAW)
-
-	// create a VBO to hold the uv data
-	gl.GenBuffers(1, &r.UvVBO)
-	gl.BindBuffer(gl.ARRAY_BUFFER, r.UvVBO)
-	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(uvs), gl.Ptr(&uvs[0]), gl.STATIC_DRAW)
-
-	// create a VBO to hold the normals data
-	gl.GenBuffers(1, &r.NormsVBO)
-	gl.BindBuffer(gl.ARRAY_BUFFER, r.NormsVBO)
-	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(normals), gl.Ptr(&normals[0]), gl.STATIC_DRAW)
-
-	// create a VBO to hold the face indexes
-	gl.GenBuffers(1, &r.ElementsVBO)
-	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, r.ElementsVBO)
-	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, uintSize*len(indexes), gl.Ptr(&indexes[0]), gl.STATIC_DRAW)
-
-	return r
-}
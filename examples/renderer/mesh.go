@@ -0,0 +1,242 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"math"
+
+	gl "github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// CreateCube makes a new Renderable object with the specified dimensions for the cube.
+func CreateCube(xmin, ymin, zmin, xmax, ymax, zmax float32) *Renderable {
+	/* Cube vertices are layed out like this:
+
+	  +--------+           6          5
+	/ |       /|
+	+--------+ |        1          0        +Y
+	| |      | |                            |___ +X
+	| +------|-+           7          4    /
+	|/       |/                           +Z
+	+--------+          2          3
+
+	*/
+
+	verts := [...]float32{
+		xmax, ymax, zmax, xmin, ymax, zmax, xmin, ymin, zmax, xmax, ymin, zmax, // v0,v1,v2,v3 (front)
+		xmax, ymax, zmin, xmax, ymax, zmax, xmax, ymin, zmax, xmax, ymin, zmin, // v5,v0,v3,v4 (right)
+		xmax, ymax, zmin, xmin, ymax, zmin, xmin, ymax, zmax, xmax, ymax, zmax, // v5,v6,v1,v0 (top)
+		xmin, ymax, zmax, xmin, ymax, zmin, xmin, ymin, zmin, xmin, ymin, zmax, // v1,v6,v7,v2 (left)
+		xmax, ymin, zmax, xmin, ymin, zmax, xmin, ymin, zmin, xmax, ymin, zmin, // v3,v2,v7,v4 (bottom)
+		xmin, ymax, zmin, xmax, ymax, zmin, xmax, ymin, zmin, xmin, ymin, zmin, // v6,v5,v4,v7 (back)
+	}
+	indexes := [...]uint32{
+		0, 1, 2, 2, 3, 0,
+		4, 5, 6, 6, 7, 4,
+		8, 9, 10, 10, 11, 8,
+		12, 13, 14, 14, 15, 12,
+		16, 17, 18, 18, 19, 16,
+		20, 21, 22, 22, 23, 20,
+	}
+	uvs := [...]float32{
+		1.0, 1.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0,
+		1.0, 1.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0,
+		1.0, 1.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0,
+		1.0, 1.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0,
+		1.0, 1.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0,
+		1.0, 1.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0,
+	}
+	normals := [...]float32{
+		0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, // v0,v1,v2,v3 (front)
+		1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, // v5,v0,v3,v4 (right)
+		0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 0, // v5,v6,v1,v0 (top)
+		-1, 0, 0, -1, 0, 0, -1, 0, 0, -1, 0, 0, // v1,v6,v7,v2 (left)
+		0, -1, 0, 0, -1, 0, 0, -1, 0, 0, -1, 0, // v3,v2,v7,v4 (bottom)
+		0, 0, -1, 0, 0, -1, 0, 0, -1, 0, 0, -1, // v6,v5,v4,v7 (back)
+	}
+
+	return createMesh(verts[:], uvs[:], normals[:], indexes[:])
+}
+
+// CreateSphere generates a 3d uv-sphere with the given radius and returns a Renderable.
+func CreateSphere(radius float32, rings int, sectors int) *Renderable {
+	// nothing to create
+	if rings < 2 || sectors < 2 {
+		return nil
+	}
+
+	const piDiv2 = math.Pi / 2.0
+
+	verts := make([]float32, 0, rings*sectors*3)
+	indexes := make([]uint32, 0, rings*sectors*6)
+	uvs := make([]float32, 0, rings*sectors*2)
+	normals := make([]float32, 0, rings*sectors*3)
+
+	R := float64(1.0 / float32(rings-1))
+	S := float64(1.0 / float32(sectors-1))
+
+	for ri := 0; ri < int(rings); ri++ {
+		for si := 0; si < int(sectors); si++ {
+			y := float32(math.Sin(-piDiv2 + math.Pi*float64(ri)*R))
+			x := float32(math.Cos(2.0*math.Pi*float64(si)*S) * math.Sin(math.Pi*float64(ri)*R))
+			z := float32(math.Sin(2.0*math.Pi*float64(si)*S) * math.Sin(math.Pi*float64(ri)*R))
+
+			uvs = append(uvs, float32(si)*float32(S))
+			uvs = append(uvs, float32(ri)*float32(R))
+
+			verts = append(verts, x*radius)
+			verts = append(verts, y*radius)
+			verts = append(verts, z*radius)
+
+			normals = append(normals, x)
+			normals = append(normals, y)
+			normals = append(normals, z)
+		}
+	}
+	indexes = appendGridIndexes(indexes, rings, sectors)
+
+	return createMesh(verts, uvs, normals, indexes)
+}
+
+// CreateCapsule generates a capsule along the Y axis with the given radius and
+// the given distance from its center to the center of each end cap, and
+// returns a Renderable. The end caps are half of a uv-sphere with the given
+// number of rings and sectors, and the side is left between them.
+func CreateCapsule(radius, halfHeight float32, rings int, sectors int) *Renderable {
+	// nothing to create
+	if rings < 2 || sectors < 2 {
+		return nil
+	}
+
+	// each cap gets half of the rings and ends at the equator, where the two
+	// caps are joined to make the side
+	capRings := rings/2 + 1
+	rows := capRings * 2
+
+	verts := make([]float32, 0, rows*sectors*3)
+	indexes := make([]uint32, 0, rows*sectors*6)
+	uvs := make([]float32, 0, rows*sectors*2)
+	normals := make([]float32, 0, rows*sectors*3)
+
+	R := 1.0 / float64(capRings-1)
+	S := 1.0 / float64(sectors-1)
+
+	for row := 0; row < rows; row++ {
+		// the bottom cap runs from its pole up to the equator and the top cap
+		// from the equator up to its pole
+		lat := -math.Pi / 2.0
+		offset := -halfHeight
+		ri := row
+		if row >= capRings {
+			lat = 0.0
+			offset = halfHeight
+			ri -= capRings
+		}
+		lat += math.Pi / 2.0 * float64(ri) * R
+
+		for si := 0; si < sectors; si++ {
+			y := float32(math.Sin(lat))
+			x := float32(math.Cos(2.0*math.Pi*float64(si)*S) * math.Cos(lat))
+			z := float32(math.Sin(2.0*math.Pi*float64(si)*S) * math.Cos(lat))
+
+			uvs = append(uvs, float32(float64(si)*S))
+			uvs = append(uvs, float32(row)/float32(rows-1))
+
+			verts = append(verts, x*radius)
+			verts = append(verts, y*radius+offset)
+			verts = append(verts, z*radius)
+
+			normals = append(normals, x)
+			normals = append(normals, y)
+			normals = append(normals, z)
+		}
+	}
+	indexes = appendGridIndexes(indexes, rows, sectors)
+
+	return createMesh(verts, uvs, normals, indexes)
+}
+
+// appendGridIndexes appends the faces joining each row of vertexes to the
+// next for a mesh made of the given number of rows, each with the given
+// number of vertexes running around the Y axis, and returns the indexes.
+func appendGridIndexes(indexes []uint32, rows int, sectors int) []uint32 {
+	for ri := 0; ri < rows-1; ri++ {
+		for si := 0; si < sectors-1; si++ {
+			currentRow := ri * sectors
+			nextRow := (ri + 1) * sectors
+
+			indexes = append(indexes, uint32(currentRow+si))
+			indexes = append(indexes, uint32(nextRow+si))
+			indexes = append(indexes, uint32(nextRow+si+1))
+
+			indexes = append(indexes, uint32(currentRow+si))
+			indexes = append(indexes, uint32(nextRow+si+1))
+			indexes = append(indexes, uint32(currentRow+si+1))
+		}
+	}
+	return indexes
+}
+
+// CreatePlaneXZ makes a 2d Renderable object on the XZ plane for the given size,
+// where (x0,z0) is the lower left and (x1, z1) is the upper right coordinate.
+func CreatePlaneXZ(x0, z0, x1, z1 float32, scaleUVs float32) *Renderable {
+	verts := [12]float32{
+		x0, 0.0, z0,
+		x1, 0.0, z0,
+		x0, 0.0, z1,
+		x1, 0.0, z1,
+	}
+	indexes := [6]uint32{
+		0, 1, 2,
+		1, 3, 2,
+	}
+	uvs := [8]float32{
+		0.0, 0.0,
+		scaleUVs, 0.0,
+		0.0, scaleUVs,
+		scaleUVs, scaleUVs,
+	}
+
+	normals := [12]float32{
+		0.0, 1.0, 0.0,
+		0.0, 1.0, 0.0,
+		0.0, 1.0, 0.0,
+		0.0, 1.0, 0.0,
+	}
+
+	return createMesh(verts[:], uvs[:], normals[:], indexes[:])
+}
+
+// createMesh makes a new Renderable object and uploads the vertex data and
+// face indexes to VBOs for it.
+func createMesh(verts, uvs, normals []float32, indexes []uint32) *Renderable {
+	const floatSize = 4
+	const uintSize = 4
+
+	r := NewRenderable()
+	gl.GenVertexArrays(1, &r.Vao)
+	r.FaceCount = len(indexes) / 3
+
+	// create a VBO to hold the vertex data
+	gl.GenBuffers(1, &r.VertVBO)
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.VertVBO)
+	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(verts), gl.Ptr(&verts[0]), gl.STATIC_DRAW)
+
+	// create a VBO to hold the uv data
+	gl.GenBuffers(1, &r.UvVBO)
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.UvVBO)
+	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(uvs), gl.Ptr(&uvs[0]), gl.STATIC_DRAW)
+
+	// create a VBO to hold the normals data
+	gl.GenBuffers(1, &r.NormsVBO)
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.NormsVBO)
+	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(normals), gl.Ptr(&normals[0]), gl.STATIC_DRAW)
+
+	// create a VBO to hold the face indexes
+	gl.GenBuffers(1, &r.ElementsVBO)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, r.ElementsVBO)
+	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, uintSize*len(indexes), gl.Ptr(&indexes[0]), gl.STATIC_DRAW)
+
+	return r
+}
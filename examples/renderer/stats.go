@@ -0,0 +1,256 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"time"
+
+	gl "github.com/go-gl/gl/v3.3-core/gl"
+	glfw "github.com/go-gl/glfw/v3.1/glfw"
+	"github.com/harbdog/cubez"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	// StatsVertShader is a vertex shader for a textured quad given in
+	// normalized device coordinates
+	StatsVertShader = `#version 330
+	in vec2 VERTEX_POSITION;
+	in vec2 VERTEX_UV_0;
+	out vec2 vs_uv_0;
+
+	void main()
+	{
+		vs_uv_0 = VERTEX_UV_0;
+		gl_Position = vec4(VERTEX_POSITION, 0.0, 1.0);
+	}`
+
+	// StatsFragShader is a fragment shader for a textured quad
+	StatsFragShader = `#version 330
+	uniform sampler2D MATERIAL_TEX_0;
+	in vec2 vs_uv_0;
+	out vec4 colourOut;
+
+	void main()
+	{
+		colourOut = texture(MATERIAL_TEX_0, vs_uv_0);
+	}`
+
+	// StatsKey is the key that shows and hides the stats overlay.
+	StatsKey = glfw.KeyF6
+)
+
+const (
+	// statsInterval is how often the text of the stats overlay is refreshed.
+	statsInterval = 500 * time.Millisecond
+
+	// statsMargin is the space in pixels around the text and between it and
+	// the corner of the window.
+	statsMargin = 6
+)
+
+// StatsOverlay draws the frame rate and the stats of the last step of a
+// world as text in the top left corner of the window, over the top of
+// everything else.
+type StatsOverlay struct {
+	// World is the world whose stats are shown. It can be nil, in which case
+	// only the frame rate is shown.
+	World *cubez.World
+
+	// Visible shows the overlay.
+	// Defaults to true.
+	Visible bool
+
+	// Shader is the shader program used to draw the text.
+	Shader uint32
+
+	// Vao is the VAO object used to draw the text
+	Vao uint32
+
+	// QuadVBO is the VBO the corners of the text are copied to when drawn
+	QuadVBO uint32
+
+	// Texture is the texture the text is drawn into.
+	Texture uint32
+
+	// lines holds the text shown, one line under another
+	lines []string
+
+	// frames and elapsed count the frames drawn since the text was last
+	// refreshed and how long they took, and stepTime adds up the duration
+	// of the last step of the world at each of them
+	frames   int
+	elapsed  time.Duration
+	stepTime time.Duration
+
+	// width and height are the size of the text in pixels
+	width  int
+	height int
+
+	// shader locations
+	positionLocation int32
+	uvLocation       int32
+	textureLocation  int32
+}
+
+// NewStatsOverlay creates a new StatsOverlay that shows the stats of the
+// world, which may be nil. The OpenGL context needs to be initialized first.
+func NewStatsOverlay(w *cubez.World) (*StatsOverlay, error) {
+	shader, err := LoadShaderProgram(StatsVertShader, StatsFragShader)
+	if err != nil {
+		return nil, err
+	}
+
+	s := new(StatsOverlay)
+	s.World = w
+	s.Visible = true
+	s.Shader = shader
+	s.positionLocation = gl.GetAttribLocation(shader, gl.Str("VERTEX_POSITION\x00"))
+	s.uvLocation = gl.GetAttribLocation(shader, gl.Str("VERTEX_UV_0\x00"))
+	s.textureLocation = gl.GetUniformLocation(shader, gl.Str("MATERIAL_TEX_0\x00"))
+
+	gl.GenVertexArrays(1, &s.Vao)
+	gl.GenBuffers(1, &s.QuadVBO)
+	gl.GenTextures(1, &s.Texture)
+	gl.BindTexture(gl.TEXTURE_2D, s.Texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	return s, nil
+}
+
+// HandleKey shows or hides the overlay when StatsKey is pressed, returning
+// true if the key was used.
+func (s *StatsOverlay) HandleKey(key glfw.Key, action glfw.Action) bool {
+	if key != StatsKey {
+		return false
+	}
+	if action == glfw.Press {
+		s.Visible = !s.Visible
+	}
+	return true
+}
+
+// Draw counts the frame, which took the delta given in seconds, refreshes
+// the text if it's due and then draws it in a window of the size given.
+func (s *StatsOverlay) Draw(delta float64, width int, height int) {
+	s.frames++
+	s.elapsed += time.Duration(delta * float64(time.Second))
+	if s.World != nil {
+		s.stepTime += s.World.GetStats().Duration
+	}
+	if s.elapsed >= statsInterval || s.lines == nil {
+		s.refresh()
+	}
+	if !s.Visible || s.width == 0 {
+		return
+	}
+
+	// the corners of the text in normalized device coordinates, kept to
+	// whole pixels so the font stays sharp
+	left := -1.0 + 2.0*float32(statsMargin)/float32(width)
+	right := left + 2.0*float32(s.width)/float32(width)
+	top := 1.0 - 2.0*float32(statsMargin)/float32(height)
+	bottom := top - 2.0*float32(s.height)/float32(height)
+	quad := []float32{
+		left, bottom, 0.0, 1.0,
+		right, bottom, 1.0, 1.0,
+		right, top, 1.0, 0.0,
+		left, bottom, 0.0, 1.0,
+		right, top, 1.0, 0.0,
+		left, top, 0.0, 0.0,
+	}
+
+	const floatSize = 4
+	const stride = floatSize * 4
+
+	gl.UseProgram(s.Shader)
+	gl.BindVertexArray(s.Vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, s.QuadVBO)
+	gl.BufferData(gl.ARRAY_BUFFER, floatSize*len(quad), gl.Ptr(&quad[0]), gl.STREAM_DRAW)
+	gl.EnableVertexAttribArray(uint32(s.positionLocation))
+	gl.VertexAttribPointer(uint32(s.positionLocation), 2, gl.FLOAT, false, stride, gl.PtrOffset(0))
+	gl.EnableVertexAttribArray(uint32(s.uvLocation))
+	gl.VertexAttribPointer(uint32(s.uvLocation), 2, gl.FLOAT, false, stride, gl.PtrOffset(2*floatSize))
+
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, s.Texture)
+	gl.Uniform1i(s.textureLocation, 0)
+
+	// the text goes over everything and its background is see-through
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DrawArrays(gl.TRIANGLES, 0, int32(len(quad)/4))
+	gl.Disable(gl.BLEND)
+	gl.Enable(gl.DEPTH_TEST)
+	gl.BindVertexArray(0)
+}
+
+// refresh makes the text from what's been counted since the last refresh and
+// draws it into the texture.
+func (s *StatsOverlay) refresh() {
+	s.lines = s.lines[:0]
+	if s.elapsed > 0 {
+		s.lines = append(s.lines, fmt.Sprintf("%.1f fps", float64(s.frames)/s.elapsed.Seconds()))
+	} else {
+		s.lines = append(s.lines, "- fps")
+	}
+	if s.World != nil {
+		stats := s.World.GetStats()
+		stepTime := s.stepTime / time.Duration(s.frames)
+		s.lines = append(s.lines,
+			fmt.Sprintf("step     %.3f ms", stepTime.Seconds()*1000.0),
+			fmt.Sprintf("bodies   %d (%d awake)", stats.Bodies, stats.AwakeBodies),
+			fmt.Sprintf("pairs    %d of %d colliders", stats.Pairs, stats.Colliders),
+			fmt.Sprintf("contacts %d, %d joints", stats.Contacts, stats.Constraints),
+			fmt.Sprintf("solver   %d position, %d velocity",
+				stats.SolverIterations[cubez.PassPosition], stats.SolverIterations[cubez.PassVelocity]))
+	}
+	s.frames, s.elapsed, s.stepTime = 0, 0, 0
+
+	face := basicfont.Face7x13
+	lineHeight := face.Metrics().Height.Ceil()
+	longest := 0
+	for _, line := range s.lines {
+		if w := font.MeasureString(face, line).Ceil(); w > longest {
+			longest = w
+		}
+	}
+	s.width = longest + 2*statsMargin
+	s.height = lineHeight*len(s.lines) + 2*statsMargin
+
+	// white text on a dark, half see-through background
+	rgba := image.NewRGBA(image.Rect(0, 0, s.width, s.height))
+	draw.Draw(rgba, rgba.Bounds(), image.NewUniform(color.RGBA{0, 0, 0, 160}), image.Point{}, draw.Src)
+	drawer := font.Drawer{Dst: rgba, Src: image.White, Face: face}
+	for i, line := range s.lines {
+		drawer.Dot = fixed.P(statsMargin, statsMargin+lineHeight*i+face.Metrics().Ascent.Ceil())
+		drawer.DrawString(line)
+	}
+
+	gl.BindTexture(gl.TEXTURE_2D, s.Texture)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(s.width), int32(s.height), 0,
+		gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(rgba.Pix))
+}
+
+// EnableStats turns on the stats overlay for the world, which is drawn over
+// everything else at the end of each frame. StatsKey shows and hides it.
+func (app *ExampleApp) EnableStats(w *cubez.World) *StatsOverlay {
+	s, err := NewStatsOverlay(w)
+	if err != nil {
+		panic("Failed to compile the stats shader! " + err.Error())
+	}
+	app.Stats = s
+	app.MainWindow.SetKeyCallback(app.handleKey)
+	return s
+}
@@ -0,0 +1,305 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// replayviewer plays back a simulation recorded with cubez.Recorder so that it
+// can be looked at frame by frame. Give it the path of a recording to view;
+// without one it records a pile of falling cubes to view instead.
+//
+// Space plays and pauses, the right and left arrows step forward and back a
+// frame while paused and Home goes back to the start. Contact points are drawn
+// as small red cubes.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	gl "github.com/go-gl/gl/v3.3-core/gl"
+	glfw "github.com/go-gl/glfw/v3.1/glfw"
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/harbdog/cubez"
+	"github.com/harbdog/cubez/examples/renderer"
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// contactSize is the half-size of the cubes drawn at the contact points.
+	contactSize = 0.05
+
+	// cameraSpeed is how fast the camera moves along its path when it's
+	// circling the scene.
+	cameraSpeed = 2.0
+)
+
+var (
+	colorShader uint32
+	app         *renderer.ExampleApp
+	ground      *renderer.Renderable
+	replayer    *cubez.Replayer
+	recording   *cubez.Recording
+	playing     bool
+	sinceStep   float64
+
+	// shapes holds the renderable made for each collider of the replayed
+	// world, by its index in the Colliders. Colliders are only ever added
+	// during a replay, so the indexes stay the same when it's rewound.
+	shapes []*renderer.Renderable
+
+	// contactPoints holds the contact points of the frame being shown and
+	// contactNodes the cubes drawn at them.
+	contactPoints []m.Vector3
+	contactNodes  []*renderer.Renderable
+
+	// cameraPath is the path the camera circles the scene along when
+	// circling is on, and cameraDistance how far along it the camera is.
+	cameraPath     *m.ArcLength
+	cameraDistance m.Real
+	circling       bool
+)
+
+// newCameraPath returns a path that winds around the scene at a few heights,
+// measured so the camera can move along it at a steady speed.
+func newCameraPath() *m.ArcLength {
+	spline := &m.CatmullRom{
+		Points: []m.Vector3{
+			{0.0, 4.0, 12.0},
+			{9.0, 6.0, 8.0},
+			{12.0, 3.0, 0.0},
+			{8.0, 7.0, -9.0},
+			{0.0, 5.0, -12.0},
+			{-9.0, 3.0, -8.0},
+			{-12.0, 6.0, 0.0},
+			{-8.0, 4.0, 9.0},
+		},
+		Closed: true,
+	}
+	return m.NewArcLength(spline, 256)
+}
+
+// moveCamera moves the camera along its path by the time given, looking at
+// the middle of the scene.
+func moveCamera(delta float64) {
+	cameraDistance += m.Real(delta) * cameraSpeed
+	if length := cameraPath.Length(); cameraDistance >= length {
+		cameraDistance -= length
+	}
+	eye, _ := cameraPath.PointAt(cameraDistance)
+	app.Camera.LookAt(renderer.Vec3ToMgl32(&eye), mgl.Vec3{0.0, 1.0, 0.0})
+}
+
+// recordDemo records a few seconds of cubes falling onto the ground.
+func recordDemo() *cubez.Recording {
+	world := cubez.NewWorld()
+	world.AddCollider(cubez.NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	recorder, err := cubez.NewRecorder(world)
+	if err != nil {
+		panic("Failed to start recording! " + err.Error())
+	}
+
+	const duration = 1.0 / 60.0
+	for step := 0; step < 300; step++ {
+		// drop a new cube every half a second, spinning a little
+		if step%30 == 0 && step < 240 {
+			cube := cubez.NewCollisionCube(nil, m.Vector3{0.5, 0.5, 0.5})
+			cube.Body.Position = m.Vector3{m.Real(step%90)/60.0 - 0.5, 6.0, m.Real(step%60) / 120.0}
+			cube.Body.SetMass(8.0)
+			var inertia m.Matrix3
+			inertia.SetBlockInertiaTensor(&cube.HalfSize, 8.0)
+			cube.Body.SetInertiaTensor(&inertia)
+			cube.Body.CalculateDerivedData()
+			cube.CalculateDerivedData()
+			if err := recorder.Spawn(cube); err != nil {
+				panic("Failed to record a cube! " + err.Error())
+			}
+			if err := recorder.Apply(cube.Body, cubez.InputAngularImpulse, m.Vector3{1.0, 0.0, 2.0}, m.Vector3{}); err != nil {
+				panic("Failed to record a spin! " + err.Error())
+			}
+		}
+		recorder.Step(duration)
+	}
+	return recorder.Recording()
+}
+
+// loadRecording reads the recording at the path given.
+func loadRecording(path string) *cubez.Recording {
+	file, err := os.Open(path)
+	if err != nil {
+		panic("Failed to open the recording! " + err.Error())
+	}
+	defer file.Close()
+	loaded, err := cubez.LoadRecording(file)
+	if err != nil {
+		panic("Failed to load the recording! " + err.Error())
+	}
+	return loaded
+}
+
+// showFrame moves the replay to the frame given, keeping the contact points
+// of the step that ended on it.
+func showFrame(frame int) {
+	if frame < 0 || frame > len(recording.Frames) {
+		return
+	}
+	contactPoints = contactPoints[:0]
+	if frame > 0 {
+		if err := replayer.Seek(frame - 1); err != nil {
+			fmt.Printf("%v\n", err)
+		}
+		contacts, err := replayer.Step()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+		}
+		for _, contact := range contacts {
+			contactPoints = append(contactPoints, contact.ContactPoint)
+		}
+	} else if err := replayer.Seek(0); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+	app.MainWindow.SetTitle(fmt.Sprintf("Replay Viewer - frame %d of %d", replayer.Frame(), len(recording.Frames)))
+}
+
+// shapeFor returns the renderable for the collider at the index given,
+// making it the first time the index is seen.
+func shapeFor(index int, c cubez.Collider) *renderer.Renderable {
+	if index < len(shapes) {
+		return shapes[index]
+	}
+	var node *renderer.Renderable
+	switch c.(type) {
+	case *cubez.CollisionCube:
+		node = renderer.NewColliderRenderable(c)
+		node.Color = mgl.Vec4{0.8, 0.5, 0.2, 1.0}
+	case *cubez.CollisionSphere:
+		node = renderer.NewColliderRenderable(c)
+		node.Color = mgl.Vec4{0.2, 0.2, 1.0, 1.0}
+	case *cubez.CollisionCapsule:
+		node = renderer.NewColliderRenderable(c)
+		node.Color = mgl.Vec4{0.9, 0.7, 0.5, 1.0}
+	}
+	if node != nil {
+		node.Shader = colorShader
+	}
+	shapes = append(shapes, node)
+	return node
+}
+
+func updateCallback(delta float64) {
+	if circling {
+		moveCamera(delta)
+	}
+	if !playing || replayer.Done() {
+		return
+	}
+	sinceStep += delta
+	if sinceStep < float64(recording.Frames[replayer.Frame()].Duration) {
+		return
+	}
+	sinceStep = 0.0
+	showFrame(replayer.Frame() + 1)
+}
+
+func renderCallback(delta float64) {
+	gl.Viewport(0, 0, int32(app.Width), int32(app.Height))
+	gl.ClearColor(0.196078, 0.6, 0.8, 1.0) // some pov-ray sky blue
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	// make the projection and view matrixes
+	projection := mgl.Perspective(mgl.DegToRad(60.0), float32(app.Width)/float32(app.Height), 1.0, 200.0)
+	view := app.CameraRotation.Mat4()
+	view = view.Mul4(mgl.Translate3D(-app.CameraPos[0], -app.CameraPos[1], -app.CameraPos[2]))
+
+	// draw the colliders where the replayed world has them
+	for i, c := range replayer.World.Colliders {
+		node := shapeFor(i, c)
+		if node == nil || c.GetBody() == nil {
+			continue
+		}
+		renderer.SyncFromCollider(node, c)
+		node.Draw(projection, view)
+	}
+
+	// draw the contact points of the frame
+	for i := range contactPoints {
+		if i >= len(contactNodes) {
+			node := renderer.CreateCube(-contactSize, -contactSize, -contactSize, contactSize, contactSize, contactSize)
+			node.Shader = colorShader
+			node.Color = mgl.Vec4{1.0, 0.0, 0.0, 1.0}
+			contactNodes = append(contactNodes, node)
+		}
+		renderer.SetGlVector3(&contactNodes[i].Location, &contactPoints[i])
+		contactNodes[i].Draw(projection, view)
+	}
+
+	ground.Draw(projection, view)
+
+	// rewinding builds a new world
+	app.Debug.World = replayer.World
+	app.Stats.World = replayer.World
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		recording = loadRecording(os.Args[1])
+	} else {
+		recording = recordDemo()
+	}
+	var err error
+	replayer, err = cubez.NewReplayer(recording)
+	if err != nil {
+		panic("Failed to start the replay! " + err.Error())
+	}
+
+	app = renderer.NewApp()
+	app.InitGraphics("Replay Viewer", 800, 600)
+	app.SetKeyCallback(keyCallback)
+	app.OnRender = renderCallback
+	app.OnUpdate = updateCallback
+	defer app.Terminate()
+
+	colorShader, err = renderer.LoadShaderProgram(renderer.DiffuseColorVertShader, renderer.DiffuseColorFragShader)
+	if err != nil {
+		panic("Failed to compile the diffuse shader! " + err.Error())
+	}
+
+	ground = renderer.CreatePlaneXZ(-500.0, 500.0, 500.0, -500.0, 1.0)
+	ground.Shader = colorShader
+	ground.Color = mgl.Vec4{0.3, 0.6, 0.3, 1.0}
+
+	// setup the camera
+	cameraPath = newCameraPath()
+	app.EnableCamera(mgl.Vec3{0.0, 4.0, 12.0}, mgl.Vec3{0.0, 1.0, 0.0})
+
+	// the debug drawing is toggled with the keys in renderer.DebugKeys and the
+	// stats with renderer.StatsKey
+	app.EnableDebugDraw(replayer.World).Categories = 0
+	app.EnableStats(replayer.World)
+
+	showFrame(0)
+	gl.Enable(gl.DEPTH_TEST)
+	app.RenderLoop()
+}
+
+func keyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if action != glfw.Press && action != glfw.Repeat {
+		return
+	}
+	switch key {
+	case glfw.KeyEscape:
+		w.SetShouldClose(true)
+	case glfw.KeySpace:
+		playing = !playing
+		sinceStep = 0.0
+	case glfw.KeyRight:
+		if !playing {
+			showFrame(replayer.Frame() + 1)
+		}
+	case glfw.KeyLeft:
+		if !playing {
+			showFrame(replayer.Frame() - 1)
+		}
+	case glfw.KeyHome:
+		showFrame(0)
+	case glfw.KeyC:
+		circling = !circling
+	}
+}
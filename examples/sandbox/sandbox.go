@@ -0,0 +1,284 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// sandbox is a scene to play with the engine in: shapes are spawned in front
+// of the camera and thrown into the scene, and bodies can be picked up and
+// dragged around with the mouse.
+//
+// 1 spawns a sphere, 2 a box and 3 a capsule, which is made of spheres along
+// a line since there isn't a capsule collider. Holding the left mouse button
+// on a body grabs it with a PickConstraint and drags it after the cursor.
+// The left and right arrows circle the camera around the scene, as does
+// dragging with the right mouse button, Space pauses and Backspace clears the
+// scene.
+package main
+
+import (
+	"math"
+
+	gl "github.com/go-gl/gl/v3.3-core/gl"
+	glfw "github.com/go-gl/glfw/v3.1/glfw"
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/harbdog/cubez"
+	"github.com/harbdog/cubez/examples/renderer"
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// timestep is the duration of each step of the world.
+	timestep = 1.0 / 60.0
+
+	// spawnDistance is how far in front of the camera shapes are spawned.
+	spawnDistance = 3.0
+
+	// spawnSpeed is the speed shapes are thrown into the scene at.
+	spawnSpeed = 8.0
+
+	// capsuleSpheres is the number of spheres a capsule is made from.
+	capsuleSpheres = 4
+
+	// pickFrequency and pickDamping set how tightly a picked body follows
+	// the cursor.
+	pickFrequency = 4.0
+	pickDamping   = 0.7
+
+	// cameraDistance and cameraHeight place the camera at the start, and
+	// cameraTurn is how far the arrows circle it around the scene.
+	cameraDistance = 14.0
+	cameraHeight   = 5.0
+	cameraTurn     = math.Pi / 12.0
+)
+
+var (
+	colorShader uint32
+	app         *renderer.ExampleApp
+	ground      *renderer.Renderable
+	world       *cubez.World
+	paused      bool
+	sinceStep   float64
+
+	// entities holds the renderable for each collider in the world, other
+	// than the spheres of the capsules.
+	entities []*renderer.Entity
+
+	// capsules holds the renderable for each capsule, which is drawn whole
+	// rather than sphere by sphere.
+	capsules []*capsule
+
+	// pick is the constraint dragging the picked body, or nil if nothing
+	// is picked.
+	pick *cubez.PickConstraint
+)
+
+// newWorld makes an empty world with just the ground.
+func newWorld() {
+	world = cubez.NewWorld()
+	world.SetBroadphase(cubez.NewSweepAndPruneBroadphase())
+	world.AddCollider(cubez.NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	entities = entities[:0]
+	capsules = capsules[:0]
+	pick = nil
+	if app.Debug != nil {
+		app.Debug.World = world
+	}
+	if app.Stats != nil {
+		app.Stats.World = world
+	}
+}
+
+// capsule is the renderable for a capsule and the body it's drawn at.
+type capsule struct {
+	node *renderer.Renderable
+	body *cubez.RigidBody
+}
+
+// newBody makes a body with the mass and inertia given, placed in front of
+// the camera and thrown away from it.
+func newBody(mass m.Real, inertia *m.Matrix3) *cubez.RigidBody {
+	origin, direction := app.ScreenRay(float64(app.Width)/2.0, float64(app.Height)/2.0)
+	body := cubez.NewRigidBody()
+	body.Position = origin
+	body.Position.AddScaled(&direction, spawnDistance)
+	body.Velocity = direction
+	body.Velocity.MulWith(spawnSpeed)
+	body.SetMass(mass)
+	body.SetInertiaTensor(inertia)
+	body.CalculateDerivedData()
+	return body
+}
+
+// addShape adds the collider to the world along with a node of its shape to
+// draw it.
+func addShape(c cubez.Collider, color mgl.Vec4) {
+	c.CalculateDerivedData()
+	world.AddCollider(c)
+	node := renderer.NewColliderRenderable(c)
+	node.Shader = colorShader
+	node.Color = color
+	entities = append(entities, renderer.NewEntity(node, c))
+}
+
+func spawnSphere() {
+	const radius, mass = 0.5, 4.0
+	var inertia m.Matrix3
+	inertia.SetSphereInertiaTensor(radius, mass)
+	sphere := cubez.NewCollisionSphere(newBody(mass, &inertia), radius)
+	addShape(sphere, mgl.Vec4{0.2, 0.2, 1.0, 1.0})
+}
+
+func spawnBox() {
+	const mass = 8.0
+	halfSize := m.Vector3{0.5, 0.4, 0.3}
+	var inertia m.Matrix3
+	inertia.SetBlockInertiaTensor(&halfSize, mass)
+	box := cubez.NewCollisionCube(newBody(mass, &inertia), halfSize)
+	addShape(box, mgl.Vec4{0.8, 0.5, 0.2, 1.0})
+}
+
+func spawnCapsule() {
+	const radius, halfHeight, mass = 0.3, 0.6, 5.0
+	var inertia m.Matrix3
+	inertia.SetCapsuleInertiaTensor(radius, halfHeight, mass)
+	body := newBody(mass, &inertia)
+
+	// spheres spaced evenly between the centers of the end caps
+	var identity m.Quat
+	identity.SetIdentity()
+	for i := 0; i < capsuleSpheres; i++ {
+		y := -halfHeight + 2.0*halfHeight*m.Real(i)/m.Real(capsuleSpheres-1)
+		sphere := cubez.NewCollisionSphere(body, radius)
+		sphere.Offset.SetAsTransform(&m.Vector3{0.0, y, 0.0}, &identity)
+		sphere.CalculateDerivedData()
+		world.AddCollider(sphere)
+	}
+
+	node := renderer.CreateCapsule(radius, halfHeight, 16, 16)
+	node.Shader = colorShader
+	node.Color = mgl.Vec4{0.3, 0.8, 0.3, 1.0}
+	capsules = append(capsules, &capsule{node: node, body: body})
+}
+
+// grab picks the body under the cursor, if there is one.
+func grab() {
+	origin, direction := app.CursorRay()
+	found, hit := world.Raycast(&origin, &direction, 200.0)
+	if !found || hit.Body == nil {
+		return
+	}
+	maxForce := 50.0 * hit.Body.GetMass()
+	pick = cubez.NewPickConstraint(hit, pickFrequency, pickDamping, maxForce)
+	world.AddConstraint(pick)
+}
+
+// drop lets go of the picked body.
+func drop() {
+	if pick == nil {
+		return
+	}
+	world.RemoveConstraint(pick)
+	pick.Release()
+	pick = nil
+}
+
+func updateCallback(delta float64) {
+	if pick != nil {
+		origin, direction := app.CursorRay()
+		pick.UpdateRay(&origin, &direction)
+	}
+	if paused {
+		return
+	}
+	sinceStep += delta
+	for sinceStep >= timestep {
+		world.Step(timestep)
+		sinceStep -= timestep
+	}
+}
+
+func renderCallback(delta float64) {
+	gl.Viewport(0, 0, int32(app.Width), int32(app.Height))
+	gl.ClearColor(0.196078, 0.6, 0.8, 1.0) // some pov-ray sky blue
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	projection := app.Projection()
+	view := app.View()
+
+	// draw each collider where the world has it and each capsule over the
+	// spheres it's made of
+	for _, e := range entities {
+		renderer.SyncFromCollider(e.Node, e.Collider)
+		e.Node.Draw(projection, view)
+	}
+	for _, c := range capsules {
+		renderer.SyncFromBody(c.node, c.body)
+		c.node.Draw(projection, view)
+	}
+
+	ground.Draw(projection, view)
+}
+
+func main() {
+	app = renderer.NewApp()
+	app.InitGraphics("Sandbox", 800, 600)
+	app.SetKeyCallback(keyCallback)
+	app.OnRender = renderCallback
+	app.OnUpdate = updateCallback
+	defer app.Terminate()
+
+	var err error
+	colorShader, err = renderer.LoadShaderProgram(renderer.DiffuseColorVertShader, renderer.DiffuseColorFragShader)
+	if err != nil {
+		panic("Failed to compile the diffuse shader! " + err.Error())
+	}
+
+	ground = renderer.CreatePlaneXZ(-500.0, 500.0, 500.0, -500.0, 1.0)
+	ground.Shader = colorShader
+	ground.Color = mgl.Vec4{0.3, 0.6, 0.3, 1.0}
+
+	app.EnableCamera(mgl.Vec3{0.0, cameraHeight, cameraDistance}, mgl.Vec3{0.0, 1.0, 0.0})
+	newWorld()
+
+	// the debug drawing is toggled with the keys in renderer.DebugKeys and the
+	// stats with renderer.StatsKey
+	app.EnableDebugDraw(world).Categories = 0
+	app.EnableStats(world)
+	app.MainWindow.SetMouseButtonCallback(mouseButtonCallback)
+
+	gl.Enable(gl.DEPTH_TEST)
+	app.RenderLoop()
+}
+
+func mouseButtonCallback(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+	if button != glfw.MouseButtonLeft {
+		return
+	}
+	if action == glfw.Press {
+		grab()
+	} else if action == glfw.Release {
+		drop()
+	}
+}
+
+func keyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if action != glfw.Press && action != glfw.Repeat {
+		return
+	}
+	switch key {
+	case glfw.KeyEscape:
+		w.SetShouldClose(true)
+	case glfw.KeySpace:
+		paused = !paused
+	case glfw.KeyBackspace:
+		newWorld()
+	case glfw.Key1:
+		spawnSphere()
+	case glfw.Key2:
+		spawnBox()
+	case glfw.Key3:
+		spawnCapsule()
+	case glfw.KeyLeft:
+		app.Camera.Yaw -= cameraTurn
+	case glfw.KeyRight:
+		app.Camera.Yaw += cameraTurn
+	}
+}
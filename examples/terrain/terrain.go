@@ -0,0 +1,261 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// terrain loads a PNG heightmap into a heightfield and drops spheres and boxes
+// onto the peak in the middle of it, where the spheres roll and the boxes
+// tumble down the slopes into the valley ringed by hills. Give it the path of
+// another grayscale PNG to use that instead of heightmap.png.
+//
+// 1 drops a sphere, 2 a box and A turns the drops every second on and off.
+// R starts over and Space pauses. F1 draws the colliders and F3 the contacts.
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"image"
+	"image/png"
+	"math/rand"
+	"os"
+
+	gl "github.com/go-gl/gl/v3.3-core/gl"
+	glfw "github.com/go-gl/glfw/v3.1/glfw"
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/harbdog/cubez"
+	"github.com/harbdog/cubez/examples/renderer"
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// timestep is the duration of each step of the world.
+	timestep = 1.0 / 60.0
+
+	// cellSize is the distance between the pixels of the heightmap in the
+	// world and peakHeight is the height of a white pixel.
+	cellSize   = 0.5
+	peakHeight = 12.0
+
+	// dropInterval is how often a shape is dropped while dropping is on,
+	// and maxShapes is the number of shapes before the oldest are taken
+	// away.
+	dropInterval = 1.0
+	maxShapes    = 40
+
+	// dropHeight is how far above the middle of the terrain shapes are
+	// dropped from, and dropSpread how far to either side of it.
+	dropHeight = 3.0
+	dropSpread = 1.5
+
+	// density is the density of every shape.
+	density = 100.0
+)
+
+//go:embed heightmap.png
+var defaultHeightmap []byte
+
+var (
+	colorShader uint32
+	app         *renderer.ExampleApp
+	terrainNode *renderer.Renderable
+	terrain     *cubez.CollisionHeightfield
+	world       *cubez.World
+	random      *rand.Rand
+	paused      bool
+	dropping    = true
+	sinceStep   float64
+	sinceDrop   float64
+
+	// entities holds the renderable for each shape, oldest first.
+	entities []*renderer.Entity
+)
+
+// loadTerrain decodes the heightmap and makes a heightfield from it centered
+// on the origin.
+func loadTerrain(heightmap []byte) (*cubez.CollisionHeightfield, error) {
+	img, err := png.Decode(bytes.NewReader(heightmap))
+	if err != nil {
+		return nil, err
+	}
+	return newTerrain(img), nil
+}
+
+// newTerrain makes a heightfield from the image centered on the origin.
+func newTerrain(img image.Image) *cubez.CollisionHeightfield {
+	hf := cubez.NewCollisionHeightfieldFromImage(img, cellSize, peakHeight)
+	hf.Position = m.Vector3{
+		-m.Real(hf.Columns-1) * cellSize / 2.0,
+		0.0,
+		-m.Real(hf.Rows-1) * cellSize / 2.0,
+	}
+	hf.CalculateDerivedData()
+	return hf
+}
+
+// buildScene makes a new world with just the terrain.
+func buildScene() {
+	world = cubez.NewWorld()
+	world.SetBroadphase(cubez.NewSweepAndPruneBroadphase())
+	world.AddCollider(terrain)
+	entities = entities[:0]
+	random = rand.New(rand.NewSource(1))
+	if app.Debug != nil {
+		app.Debug.World = world
+	}
+	if app.Stats != nil {
+		app.Stats.World = world
+	}
+	sinceStep = 0.0
+	sinceDrop = 0.0
+}
+
+// drop adds the collider above the middle of the terrain, a little to the
+// side and turned a random way, taking away the oldest shape if there are
+// too many.
+func drop(c cubez.Collider, color mgl.Vec4) {
+	if len(entities) >= maxShapes {
+		oldest := entities[0].Collider
+		world.RemoveCollider(oldest)
+		world.RemoveBody(oldest.GetBody())
+		entities = entities[1:]
+	}
+
+	spread := func() m.Real {
+		return m.Real(random.Float64()*2.0-1.0) * dropSpread
+	}
+	x, z := spread(), spread()
+	_, height := terrain.HeightAt(x, z)
+	body := c.GetBody()
+	body.Position = m.Vector3{x, height + dropHeight, z}
+	body.Orientation = m.QuatFromAxis(m.Real(random.Float64())*3.0, spread(), 1.0, spread())
+	body.Orientation.Normalize()
+	c.SetDensity(density)
+	body.CalculateDerivedData()
+	c.CalculateDerivedData()
+	world.AddCollider(c)
+
+	node := renderer.NewColliderRenderable(c)
+	node.Shader = colorShader
+	node.Color = color
+	entities = append(entities, renderer.NewEntity(node, c))
+}
+
+// dropSphere drops a sphere of a random size.
+func dropSphere() {
+	radius := m.Real(0.3 + random.Float64()*0.3)
+	drop(cubez.NewCollisionSphere(nil, radius), mgl.Vec4{0.2, 0.2, 1.0, 1.0})
+}
+
+// dropBox drops a box of a random size.
+func dropBox() {
+	size := func() m.Real {
+		return m.Real(0.25 + random.Float64()*0.35)
+	}
+	drop(cubez.NewCollisionCube(nil, m.Vector3{size(), size(), size()}), mgl.Vec4{0.8, 0.5, 0.2, 1.0})
+}
+
+func updateCallback(delta float64) {
+	if paused {
+		return
+	}
+	sinceStep += delta
+	for sinceStep >= timestep {
+		if dropping {
+			sinceDrop += timestep
+			if sinceDrop >= dropInterval {
+				if random.Intn(2) == 0 {
+					dropSphere()
+				} else {
+					dropBox()
+				}
+				sinceDrop -= dropInterval
+			}
+		}
+		world.Step(timestep)
+		sinceStep -= timestep
+	}
+	app.MainWindow.SetTitle(fmt.Sprintf("Terrain - %d shapes", len(entities)))
+}
+
+func renderCallback(delta float64) {
+	gl.Viewport(0, 0, int32(app.Width), int32(app.Height))
+	gl.ClearColor(0.196078, 0.6, 0.8, 1.0) // some pov-ray sky blue
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	projection := app.Projection()
+	view := app.View()
+
+	for _, e := range entities {
+		renderer.SyncFromCollider(e.Node, e.Collider)
+		e.Node.Draw(projection, view)
+	}
+
+	terrainNode.Draw(projection, view)
+}
+
+func main() {
+	heightmap := defaultHeightmap
+	if len(os.Args) > 1 {
+		var err error
+		heightmap, err = os.ReadFile(os.Args[1])
+		if err != nil {
+			fmt.Printf("Failed to read the heightmap: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var err error
+	terrain, err = loadTerrain(heightmap)
+	if err != nil {
+		fmt.Printf("Failed to load the heightmap: %v\n", err)
+		os.Exit(1)
+	}
+
+	app = renderer.NewApp()
+	app.InitGraphics("Terrain", 800, 600)
+	app.SetKeyCallback(keyCallback)
+	app.OnRender = renderCallback
+	app.OnUpdate = updateCallback
+	defer app.Terminate()
+
+	colorShader, err = renderer.LoadShaderProgram(renderer.DiffuseColorVertShader, renderer.DiffuseColorFragShader)
+	if err != nil {
+		panic("Failed to compile the diffuse shader! " + err.Error())
+	}
+
+	terrainNode = renderer.NewColliderRenderable(terrain)
+	terrainNode.Shader = colorShader
+	terrainNode.Color = mgl.Vec4{0.55, 0.5, 0.3, 1.0}
+
+	// look down on the peak from above the hills around it
+	app.EnableCamera(mgl.Vec3{0.0, 28.0, 36.0}, mgl.Vec3{0.0, 4.0, 0.0})
+
+	buildScene()
+
+	// the debug drawing is toggled with the keys in renderer.DebugKeys and the
+	// stats with renderer.StatsKey
+	app.EnableDebugDraw(world).Categories = 0
+	app.EnableStats(world)
+
+	gl.Enable(gl.DEPTH_TEST)
+	app.RenderLoop()
+}
+
+func keyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if action != glfw.Press {
+		return
+	}
+	switch key {
+	case glfw.KeyEscape:
+		w.SetShouldClose(true)
+	case glfw.KeySpace:
+		paused = !paused
+	case glfw.KeyR:
+		buildScene()
+	case glfw.KeyA:
+		dropping = !dropping
+	case glfw.Key1:
+		dropSphere()
+	case glfw.Key2:
+		dropBox()
+	}
+}
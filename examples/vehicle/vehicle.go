@@ -0,0 +1,565 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// vehicle drives a raycast vehicle over hilly heightfield terrain. The car is
+// a single box of a body held up by four wheels that aren't bodies at all:
+// each wheel casts a ray down from its mount on the chassis and pushes the
+// chassis up with a damped spring when the ray hits the ground, along with
+// the grip of the tire along and across the ground. The wheels are a
+// ForceGenerator in the world's Generators, so their forces are added at the
+// start of every step like any other.
+//
+// The up and down arrows drive and reverse, left and right steer and Space is
+// the handbrake. R puts the car back at the start, P pauses and V toggles
+// drawing the suspension, wheels and ground contacts as debug lines.
+package main
+
+import (
+	"fmt"
+	"math"
+
+	gl "github.com/go-gl/gl/v3.3-core/gl"
+	glfw "github.com/go-gl/glfw/v3.1/glfw"
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/harbdog/cubez"
+	"github.com/harbdog/cubez/examples/renderer"
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// timestep is the duration of each step of the world.
+	timestep = 1.0 / 60.0
+
+	// terrainSamples is the number of height samples along each side of the
+	// terrain and terrainCellSize is the distance between them.
+	terrainSamples  = 65
+	terrainCellSize = 2.0
+
+	// chassisMass is the mass of the car.
+	chassisMass = 200.0
+
+	// wheelRadius is the radius of each wheel and suspensionLength is how far
+	// its spring reaches below the mount when it's not compressed.
+	wheelRadius      = 0.4
+	suspensionLength = 0.5
+
+	// sag is the fraction of the suspension that's compressed by the weight
+	// of the car at rest, which sets the stiffness of the springs, and
+	// suspensionDamping is the fraction of critical damping they have.
+	sag               = 0.4
+	suspensionDamping = 0.3
+
+	// tireGrip is the friction coefficient of the tires on the ground, which
+	// limits the force each can push with to tireGrip times its load.
+	tireGrip = 1.2
+
+	// engineForce is the force the driven wheels push with at full throttle
+	// and brakeForce the force the handbrake stops the rear wheels with.
+	engineForce = 1600.0
+	brakeForce  = 3000.0
+
+	// rollInfluence is how much of the height of the center of mass above
+	// the ground the tire forces act at, where zero pushes at the ground and
+	// rolls the car over in tight turns.
+	rollInfluence = 0.6
+
+	// maxSteer is the largest steering angle in radians and steerSpeed is
+	// how fast the wheels turn towards it.
+	maxSteer   = 0.5
+	steerSpeed = 2.0
+
+	// cameraDistance and cameraHeight place the camera behind the car, and
+	// cameraLag is how quickly it catches up, with higher being tighter.
+	cameraDistance = 9.0
+	cameraHeight   = 3.5
+	cameraLag      = 3.0
+)
+
+var (
+	colorShader uint32
+	app         *renderer.ExampleApp
+	ground      *renderer.Renderable
+	terrainNode *renderer.Renderable
+	chassisNode *renderer.Renderable
+	wheelNodes  [4]*renderer.Renderable
+	world       *cubez.World
+	terrain     *cubez.CollisionHeightfield
+	groundPlane *cubez.CollisionPlane
+	car         *vehicle
+	paused      bool
+	showWheels  = true
+	sinceStep   float64
+
+	// start is where the car is placed on the terrain, in the middle of
+	// the flat ground.
+	start = m.Vector3{0.0, 0.0, 0.0}
+)
+
+// wheel is one of the wheels of a vehicle.
+type wheel struct {
+	// mount is where the top of the suspension is fixed to the chassis, in
+	// Body Space.
+	mount m.Vector3
+
+	// steers is set for the wheels turned by the steering and driven for the
+	// wheels the engine pushes with.
+	steers bool
+	driven bool
+
+	// the state of the wheel from the last time the forces were updated,
+	// kept for drawing: the World Space top of the suspension, the center of
+	// the wheel, and the point and normal of the ground under it if onGround
+	// is set.
+	top      m.Vector3
+	center   m.Vector3
+	point    m.Vector3
+	normal   m.Vector3
+	onGround bool
+}
+
+// vehicle is a raycast vehicle: a chassis held up by wheels that are rays
+// cast down from it rather than bodies of their own.
+type vehicle struct {
+	// chassis is the box the car is made of.
+	chassis *cubez.CollisionCube
+
+	// wheels holds the front left, front right, rear left and rear right
+	// wheels. The car faces along its Z axis, which puts its left along X.
+	wheels [4]wheel
+
+	// throttle runs from -1 for full reverse to 1 for full forward, and
+	// steer from -1 for full left to 1 for full right.
+	throttle m.Real
+	steer    m.Real
+
+	// handbrake locks the rear wheels.
+	handbrake bool
+
+	// stiffness and damping are the spring and damping constants of each
+	// wheel's suspension.
+	stiffness m.Real
+	damping   m.Real
+}
+
+// newVehicle makes a car resting at the position given and adds it to the world.
+func newVehicle(position m.Vector3) *vehicle {
+	v := new(vehicle)
+	v.chassis = cubez.NewCollisionCube(nil, m.Vector3{0.9, 0.35, 2.0})
+	body := v.chassis.Body
+	body.Position = position
+	body.SetMass(chassisMass)
+	var inertia m.Matrix3
+	inertia.SetBlockInertiaTensor(&v.chassis.HalfSize, chassisMass)
+	body.SetInertiaTensor(&inertia)
+	body.CanSleep = false
+	body.CalculateDerivedData()
+	v.chassis.CalculateDerivedData()
+	world.AddCollider(v.chassis)
+
+	v.wheels = [4]wheel{
+		{mount: m.Vector3{0.85, -0.2, 1.4}, steers: true},
+		{mount: m.Vector3{-0.85, -0.2, 1.4}, steers: true},
+		{mount: m.Vector3{0.85, -0.2, -1.4}, driven: true},
+		{mount: m.Vector3{-0.85, -0.2, -1.4}, driven: true},
+	}
+
+	// the springs hold up a quarter of the car each, sagging by the fraction
+	// of their length in sag when it's at rest
+	gravity := body.Acceleration.Magnitude()
+	load := chassisMass * gravity / 4.0
+	v.stiffness = load / (sag * suspensionLength)
+	v.damping = suspensionDamping * 2.0 * m.RealSqrt(v.stiffness*chassisMass/4.0)
+	return v
+}
+
+// UpdateForce adds the forces of the suspension and tires of each wheel to
+// the chassis.
+func (v *vehicle) UpdateForce(body *cubez.RigidBody, duration m.Real) {
+	transform := body.GetTransform()
+	up := transform.GetAxis(1)
+	down := up
+	down.MulWith(-1.0)
+	left := transform.GetAxis(0)
+	forward := transform.GetAxis(2)
+	com := body.GetCenterOfMassWorld()
+
+	steerAngle := v.steer * maxSteer
+	for i := range v.wheels {
+		w := &v.wheels[i]
+		w.top = transform.MulVector3(&w.mount)
+
+		reach := m.Real(suspensionLength + wheelRadius)
+		found, distance, point, normal := castWheel(&w.top, &down, reach)
+		w.onGround = found
+		if !found {
+			w.center = w.top
+			w.center.AddScaled(&down, suspensionLength)
+			continue
+		}
+		w.point, w.normal = point, normal
+		w.center = w.top
+		w.center.AddScaled(&down, distance-wheelRadius)
+
+		// the suspension pushes the chassis up along its own up axis
+		velocity := body.GetVelocityAtPoint(&point)
+		compression := reach - distance
+		closing := velocity.Dot(&down)
+		load := v.stiffness*compression + v.damping*closing
+		if load <= 0.0 {
+			continue
+		}
+		suspension := up
+		suspension.MulWith(load)
+		body.AddForceAtPoint(&suspension, &point)
+
+		// the direction the wheel rolls in and the one it slides in, both
+		// flat along the ground
+		heading := forward
+		if w.steers {
+			heading.MulWith(m.RealCos(steerAngle))
+			heading.AddScaled(&left, -m.RealSin(steerAngle))
+		}
+		side := normal.Cross(&heading)
+		side.Normalize()
+		rolling := side.Cross(&normal)
+		rolling.Normalize()
+
+		// the tire stops the wheel sliding sideways as much as its grip
+		// allows, pushing on a quarter of the car
+		quarter := m.Real(chassisMass / 4.0)
+		tire := side
+		tire.MulWith(-velocity.Dot(&side) * quarter / duration)
+
+		speed := velocity.Dot(&rolling)
+		var push m.Real
+		if w.driven {
+			push = v.throttle * engineForce / 2.0
+			if v.handbrake {
+				push = -speed * quarter / duration
+				if m.RealAbs(push) > brakeForce {
+					push = brakeForce * push / m.RealAbs(push)
+				}
+			}
+		}
+		tire.AddScaled(&rolling, push)
+
+		limit := tireGrip * load
+		if magnitude := tire.Magnitude(); magnitude > limit {
+			tire.MulWith(limit / magnitude)
+		}
+
+		// raise where the tire pushes towards the height of the center of
+		// mass so that it doesn't tip the car over as much
+		height := com
+		height.Sub(&point)
+		at := point
+		at.AddScaled(&up, height.Dot(&up)*rollInfluence)
+		body.AddForceAtPoint(&tire, &at)
+	}
+}
+
+// castWheel casts the suspension ray of a wheel down from its top and returns
+// the distance to the ground along with the point and normal hit, if the
+// ground is within reach. The terrain and the flat ground around it are the
+// only things the wheels drive on.
+func castWheel(origin *m.Vector3, direction *m.Vector3, reach m.Real) (bool, m.Real, m.Vector3, m.Vector3) {
+	found, distance, point, normal := castTerrain(origin, direction, reach)
+	if planeHit, hit := cubez.RaycastCollider(groundPlane, origin, direction); planeHit && hit.Distance <= reach {
+		if !found || hit.Distance < distance {
+			return true, hit.Distance, hit.Point, hit.Normal
+		}
+	}
+	return found, distance, point, normal
+}
+
+// castTerrain marches a ray along the terrain until it passes under the
+// surface and then narrows down where it crossed. There isn't a raycast
+// against heightfields, but the suspension rays are short enough that a few
+// samples per cell find the ground.
+func castTerrain(origin *m.Vector3, direction *m.Vector3, reach m.Real) (bool, m.Real, m.Vector3, m.Vector3) {
+	var point m.Vector3
+	below := func(t m.Real) bool {
+		point = *origin
+		point.AddScaled(direction, t)
+		ok, height := terrain.HeightAt(point[0], point[2])
+		return ok && point[1] <= height
+	}
+
+	step := m.Real(terrainCellSize / 8.0)
+	previous := m.Real(0.0)
+	if below(previous) {
+		_, normal := terrain.NormalAt(point[0], point[2])
+		return true, 0.0, point, normal
+	}
+	for t := step; previous < reach; t += step {
+		if t > reach {
+			t = reach
+		}
+		if !below(t) {
+			previous = t
+			continue
+		}
+
+		// the surface is between the last two samples
+		low, high := previous, t
+		for i := 0; i < 8; i++ {
+			middle := (low + high) / 2.0
+			if below(middle) {
+				high = middle
+			} else {
+				low = middle
+			}
+		}
+		below(high)
+		_, normal := terrain.NormalAt(point[0], point[2])
+		return true, high, point, normal
+	}
+	return false, 0.0, point, m.Vector3{}
+}
+
+// newTerrain makes rolling hills of terrain centered on the origin, flattened
+// around the start so the car sets off on level ground.
+func newTerrain() *cubez.CollisionHeightfield {
+	heights := make([]m.Real, terrainSamples*terrainSamples)
+	half := m.Real(terrainSamples-1) * terrainCellSize / 2.0
+	for row := 0; row < terrainSamples; row++ {
+		for column := 0; column < terrainSamples; column++ {
+			x := m.Real(column)*terrainCellSize - half
+			z := m.Real(row)*terrainCellSize - half
+			h := 2.5*m.RealSin(x*0.08)*m.RealCos(z*0.06) + 1.2*m.RealSin(x*0.21+z*0.17)
+
+			// blend from flat at the start out to the full hills
+			blend := m.RealSqrt(x*x+z*z) / 20.0
+			if blend > 1.0 {
+				blend = 1.0
+			}
+
+			// and slope down to the ground around the edges
+			edge := half - m.RealAbs(x)
+			if edgeZ := half - m.RealAbs(z); edgeZ < edge {
+				edge = edgeZ
+			}
+			slope := edge / 16.0
+			if slope > 1.0 {
+				slope = 1.0
+			}
+			heights[row*terrainSamples+column] = (3.7 + h*blend) * slope
+		}
+	}
+	hf := cubez.NewCollisionHeightfield(terrainSamples, terrainSamples, terrainCellSize, heights)
+	hf.Position = m.Vector3{-half, 0.0, -half}
+	hf.CalculateDerivedData()
+	return hf
+}
+
+// buildScene makes a new world with the terrain and the car at the start.
+func buildScene() {
+	world = cubez.NewWorld()
+	world.Generators = cubez.NewForceRegistry()
+	groundPlane = cubez.NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0)
+	world.AddCollider(groundPlane)
+	world.AddCollider(terrain)
+
+	_, height := terrain.HeightAt(start[0], start[2])
+	car = newVehicle(m.Vector3{start[0], height + 1.2, start[2]})
+	world.Generators.Add(car.chassis.Body, car)
+	if app.Debug != nil {
+		app.Debug.World = world
+	}
+	if app.Stats != nil {
+		app.Stats.World = world
+	}
+	sinceStep = 0.0
+}
+
+// readControls sets the throttle, steering and handbrake of the car from the
+// keys held down.
+func readControls(delta m.Real) {
+	held := func(key glfw.Key) bool {
+		return app.MainWindow.GetKey(key) == glfw.Press
+	}
+
+	car.throttle = 0.0
+	if held(glfw.KeyUp) {
+		car.throttle += 1.0
+	}
+	if held(glfw.KeyDown) {
+		car.throttle -= 1.0
+	}
+	car.handbrake = held(glfw.KeySpace)
+
+	// the wheels turn towards where they're steered rather than snapping there
+	target := m.Real(0.0)
+	if held(glfw.KeyLeft) {
+		target -= 1.0
+	}
+	if held(glfw.KeyRight) {
+		target += 1.0
+	}
+	turn := steerSpeed * delta
+	if car.steer < target-turn {
+		car.steer += turn
+	} else if car.steer > target+turn {
+		car.steer -= turn
+	} else {
+		car.steer = target
+	}
+}
+
+// followCamera moves the camera towards a spot behind and above the car,
+// looking at it.
+func followCamera(delta float64) {
+	body := car.chassis.Body
+	transform := body.GetTransform()
+	forward := transform.GetAxis(2)
+	forward[1] = 0.0
+	forward.Normalize()
+
+	target := renderer.Vec3ToMgl32(&body.Position)
+	behind := target.Sub(renderer.Vec3ToMgl32(&forward).Mul(cameraDistance)).Add(mgl.Vec3{0.0, cameraHeight, 0.0})
+	catchUp := float32(1.0 - 1.0/(1.0+cameraLag*delta))
+	app.CameraPos = app.CameraPos.Add(behind.Sub(app.CameraPos).Mul(catchUp))
+	app.CameraRotation = mgl.QuatLookAtV(app.CameraPos, target.Add(mgl.Vec3{0.0, 1.0, 0.0}), mgl.Vec3{0.0, 1.0, 0.0})
+}
+
+// drawWheels adds the suspension of each wheel, the wheel itself and the
+// ground under it to the debug lines.
+func drawWheels() {
+	transform := car.chassis.Body.GetTransform()
+	left := transform.GetAxis(0)
+	up := transform.GetAxis(1)
+	forward := transform.GetAxis(2)
+
+	const segments = 12
+	for i := range car.wheels {
+		w := &car.wheels[i]
+		app.Debug.DrawLine(&w.top, &w.center, cubez.DebugJoints)
+
+		// the rim of the wheel, in the plane of the chassis' up and forward
+		for s := 0; s < segments; s++ {
+			a := 2.0 * math.Pi * m.Real(s) / segments
+			b := 2.0 * math.Pi * m.Real(s+1) / segments
+			from, to := w.center, w.center
+			from.AddScaled(&up, wheelRadius*m.RealCos(a))
+			from.AddScaled(&forward, wheelRadius*m.RealSin(a))
+			to.AddScaled(&up, wheelRadius*m.RealCos(b))
+			to.AddScaled(&forward, wheelRadius*m.RealSin(b))
+			app.Debug.DrawLine(&from, &to, cubez.DebugColliders)
+		}
+		axle := w.center
+		axle.AddScaled(&left, 0.15)
+		app.Debug.DrawLine(&w.center, &axle, cubez.DebugColliders)
+
+		if w.onGround {
+			tip := w.point
+			tip.Add(&w.normal)
+			app.Debug.DrawPoint(&w.point, 0.1, cubez.DebugContacts)
+			app.Debug.DrawLine(&w.point, &tip, cubez.DebugContacts)
+		}
+	}
+}
+
+func updateCallback(delta float64) {
+	readControls(m.Real(delta))
+	if !paused {
+		sinceStep += delta
+		for sinceStep >= timestep {
+			world.Step(timestep)
+			sinceStep -= timestep
+		}
+	}
+	followCamera(delta)
+
+	onGround := 0
+	for i := range car.wheels {
+		if car.wheels[i].onGround {
+			onGround++
+		}
+	}
+	speed := car.chassis.Body.Velocity.Magnitude() * 3.6
+	app.MainWindow.SetTitle(fmt.Sprintf("Vehicle - %.0f km/h, %d wheels on the ground", speed, onGround))
+}
+
+func renderCallback(delta float64) {
+	gl.Viewport(0, 0, int32(app.Width), int32(app.Height))
+	gl.ClearColor(0.196078, 0.6, 0.8, 1.0) // some pov-ray sky blue
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	projection := app.Projection()
+	view := app.View()
+
+	renderer.SyncFromBody(chassisNode, car.chassis.Body)
+	chassisNode.Draw(projection, view)
+
+	for i, node := range wheelNodes {
+		renderer.SetGlVector3(&node.Location, &car.wheels[i].center)
+		node.Draw(projection, view)
+	}
+
+	terrainNode.Draw(projection, view)
+	ground.Draw(projection, view)
+
+	if showWheels {
+		drawWheels()
+	}
+}
+
+func main() {
+	app = renderer.NewApp()
+	app.InitGraphics("Vehicle", 800, 600)
+	app.SetKeyCallback(keyCallback)
+	app.OnRender = renderCallback
+	app.OnUpdate = updateCallback
+	defer app.Terminate()
+
+	var err error
+	colorShader, err = renderer.LoadShaderProgram(renderer.DiffuseColorVertShader, renderer.DiffuseColorFragShader)
+	if err != nil {
+		panic("Failed to compile the diffuse shader! " + err.Error())
+	}
+
+	ground = renderer.CreatePlaneXZ(-500.0, 500.0, 500.0, -500.0, 1.0)
+	ground.Shader = colorShader
+	ground.Color = mgl.Vec4{0.3, 0.6, 0.3, 1.0}
+
+	terrain = newTerrain()
+	terrainNode = renderer.CreateHeightfield(terrain)
+	terrainNode.Shader = colorShader
+	terrainNode.Color = mgl.Vec4{0.55, 0.5, 0.3, 1.0}
+
+	chassisNode = renderer.CreateCube(-0.9, -0.35, -2.0, 0.9, 0.35, 2.0)
+	chassisNode.Shader = colorShader
+	chassisNode.Color = mgl.Vec4{0.8, 0.1, 0.1, 1.0}
+	for i := range wheelNodes {
+		wheelNodes[i] = renderer.CreateSphere(wheelRadius, 12, 12)
+		wheelNodes[i].Shader = colorShader
+		wheelNodes[i].Color = mgl.Vec4{0.15, 0.15, 0.15, 1.0}
+	}
+
+	buildScene()
+	app.CameraPos = mgl.Vec3{0.0, 10.0, -12.0}
+
+	// the debug drawing is toggled with the keys in renderer.DebugKeys and the
+	// stats with renderer.StatsKey
+	app.EnableDebugDraw(world).Categories = 0
+	app.EnableStats(world)
+
+	gl.Enable(gl.DEPTH_TEST)
+	app.RenderLoop()
+}
+
+func keyCallback(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if action != glfw.Press {
+		return
+	}
+	switch key {
+	case glfw.KeyEscape:
+		w.SetShouldClose(true)
+	case glfw.KeyP:
+		paused = !paused
+	case glfw.KeyR:
+		buildScene()
+	case glfw.KeyV:
+		showWheels = !showWheels
+	}
+}
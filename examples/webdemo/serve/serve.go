@@ -0,0 +1,21 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// serve serves the webdemo folder over HTTP, since browsers won't load the
+// WebAssembly build of the demo from a file. Run it from the webdemo folder.
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// address is the address the folder is served on.
+const address = "127.0.0.1:8080"
+
+func main() {
+	fmt.Printf("open http://%s to run the demo\n", address)
+	if err := http.ListenAndServe(address, http.FileServer(http.Dir("."))); err != nil {
+		panic("Failed to serve the demo! " + err.Error())
+	}
+}
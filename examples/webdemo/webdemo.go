@@ -0,0 +1,185 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+//go:build js && wasm
+// +build js,wasm
+
+// webdemo runs a small scene in a browser: boxes and spheres rain down onto
+// the ground and pile up, with the oldest taken away to make room for new
+// ones. The engine is built to WebAssembly and steps the world, while
+// index.html draws it with WebGL. Click to set off an explosion in the middle
+// of the pile, drag to orbit and press R to start over.
+//
+// It's built and served from its folder with:
+//
+//	GOOS=js GOARCH=wasm go build -o webdemo.wasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+//	go run ./serve
+//
+// and then http://127.0.0.1:8080 is opened in a browser. Before Go 1.24,
+// wasm_exec.js is in misc/wasm rather than lib/wasm.
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"syscall/js"
+
+	"github.com/harbdog/cubez"
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// timestep is the duration of each step of the world.
+	timestep = 1.0 / 60.0
+
+	// maxSteps is the most steps taken for one frame of the page, so that the
+	// world doesn't fall far behind and then race to catch up when the page
+	// has been hidden for a while.
+	maxSteps = 4
+
+	// maxShapes is the number of shapes in the world before the oldest are
+	// taken away, and dropInterval is how often a new one is dropped.
+	maxShapes    = 60
+	dropInterval = 0.25
+
+	// density is the density of every shape.
+	density = 10.0
+
+	// blastRadius and blastImpulse are the size and strength of the explosion.
+	blastRadius  = 5.0
+	blastImpulse = 60.0
+
+	// shapeFloats is the number of values sent to the page for each shape:
+	// its kind, its size and the 12 values of its transform.
+	shapeFloats = 16
+)
+
+// the kinds of shapes sent to the page
+const (
+	shapeBox    = 0
+	shapeSphere = 1
+)
+
+var (
+	world     *cubez.World
+	shapes    []cubez.Collider
+	random    *rand.Rand
+	sinceStep float64
+	sinceDrop float64
+
+	// frame holds the shapes sent to the page as little endian float32s.
+	frame []byte
+)
+
+// reset makes a new world with just the ground.
+func reset() {
+	world = cubez.NewWorld()
+	world.AddCollider(cubez.NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	shapes = shapes[:0]
+	random = rand.New(rand.NewSource(1))
+	sinceStep = 0.0
+	sinceDrop = 0.0
+}
+
+// drop adds a box or a sphere of a random size and orientation above the
+// middle of the scene, taking away the oldest shape if there are too many.
+func drop() {
+	if len(shapes) >= maxShapes {
+		oldest := shapes[0]
+		world.RemoveCollider(oldest)
+		world.RemoveBody(oldest.GetBody())
+		shapes = shapes[1:]
+	}
+
+	size := func(lo, hi float64) m.Real {
+		return m.Real(lo + random.Float64()*(hi-lo))
+	}
+	body := cubez.NewRigidBody()
+	body.Position = m.Vector3{size(-1.5, 1.5), 8.0, size(-1.5, 1.5)}
+	body.Orientation = m.QuatFromAxis(size(0.0, math.Pi), size(-1.0, 1.0), 1.0, size(-1.0, 1.0))
+	body.Orientation.Normalize()
+
+	var c cubez.Collider
+	if random.Intn(2) == 0 {
+		c = cubez.NewCollisionCube(body, m.Vector3{size(0.2, 0.6), size(0.2, 0.6), size(0.2, 0.6)})
+	} else {
+		c = cubez.NewCollisionSphere(body, size(0.25, 0.5))
+	}
+	c.SetDensity(density)
+	body.CalculateDerivedData()
+	c.CalculateDerivedData()
+	world.AddCollider(c)
+	shapes = append(shapes, c)
+}
+
+// step advances the world by the time given in seconds, dropping shapes as
+// it goes, and returns the shapes to draw as a Uint8Array.
+func step(this js.Value, args []js.Value) interface{} {
+	delta := args[0].Float()
+	sinceStep = math.Min(sinceStep+delta, maxSteps*timestep)
+	for sinceStep >= timestep {
+		sinceDrop += timestep
+		if sinceDrop >= dropInterval {
+			drop()
+			sinceDrop -= dropInterval
+		}
+		world.Step(timestep)
+		sinceStep -= timestep
+	}
+
+	frame = frame[:0]
+	var word [4]byte
+	put := func(v float32) {
+		binary.LittleEndian.PutUint32(word[:], math.Float32bits(v))
+		frame = append(frame, word[:]...)
+	}
+	for _, c := range shapes {
+		switch shape := c.(type) {
+		case *cubez.CollisionCube:
+			put(shapeBox)
+			put(float32(shape.HalfSize[0]))
+			put(float32(shape.HalfSize[1]))
+			put(float32(shape.HalfSize[2]))
+		case *cubez.CollisionSphere:
+			put(shapeSphere)
+			put(float32(shape.Radius))
+			put(float32(shape.Radius))
+			put(float32(shape.Radius))
+		}
+		transform := c.GetTransform()
+		for _, v := range transform {
+			put(float32(v))
+		}
+	}
+
+	array := js.Global().Get("Uint8Array").New(len(frame))
+	js.CopyBytesToJS(array, frame)
+	return array
+}
+
+// explode sets off an explosion under the middle of the pile.
+func explode(this js.Value, args []js.Value) interface{} {
+	world.ApplyExplosion(&m.Vector3{0.0, 0.0, 0.0}, blastRadius, blastImpulse, cubez.FalloffLinear)
+	return nil
+}
+
+func main() {
+	reset()
+
+	// the page calls these through the cubez object once it's set
+	api := js.Global().Get("Object").New()
+	api.Set("shapeFloats", shapeFloats)
+	api.Set("step", js.FuncOf(step))
+	api.Set("explode", js.FuncOf(explode))
+	api.Set("reset", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		reset()
+		return nil
+	}))
+	js.Global().Set("cubez", api)
+	js.Global().Call("cubezReady")
+
+	// keep the program running for the page to call into
+	select {}
+}
@@ -0,0 +1,65 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+// webinspector steps the netdemo scene headless and streams it with the
+// debugserver package to a small web page that draws it on a canvas. Open
+// http://127.0.0.1:8080 in a browser once it's running: drag to orbit, scroll
+// to zoom and click a body to watch its mass and velocity.
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/harbdog/cubez"
+	"github.com/harbdog/cubez/debugserver"
+	"github.com/harbdog/cubez/examples/netdemo"
+	m "github.com/harbdog/cubez/math"
+)
+
+// address is the address the page and the stream are served on.
+const address = "127.0.0.1:8080"
+
+//go:embed inspector.html
+var page []byte
+
+func main() {
+	server := debugserver.NewServer()
+	// the page can't draw faster than the screen, so every other step is enough
+	server.Every = 2
+
+	http.Handle("/stream", server)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+	go func() {
+		if err := http.ListenAndServe(address, nil); err != nil {
+			panic("Failed to serve the inspector! " + err.Error())
+		}
+	}()
+
+	world, _ := netdemo.NewScene()
+	fmt.Printf("open http://%s to inspect %d bodies\n", address, len(world.Bodies))
+
+	const tickRate = 60
+	const duration = m.Real(1.0 / tickRate)
+	ticker := time.NewTicker(time.Second / tickRate)
+	defer ticker.Stop()
+
+	var tick int
+	for range ticker.C {
+		// knock the stack over every ten seconds so there's something to see
+		if tick%(10*tickRate) == tickRate {
+			world.ApplyExplosion(&m.Vector3{0.3, 0.2, 1.5}, 8.0, 150.0, cubez.FalloffLinear)
+		}
+		world.Step(duration)
+		tick++
+
+		if err := server.Publish(world); err != nil {
+			fmt.Printf("failed to publish the world: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// Falloff is the way the impulse of an Explosion weakens with distance.
+type Falloff uint8
+
+const (
+	// FalloffLinear weakens the impulse linearly to zero at the edge of the blast.
+	FalloffLinear Falloff = iota
+
+	// FalloffQuadratic weakens the impulse with the square of the linear
+	// falloff so that it drops quickly away from the center.
+	FalloffQuadratic
+
+	// FalloffNone applies the full impulse everywhere inside the blast.
+	FalloffNone
+)
+
+// Attenuation returns the fraction of the impulse that reaches the distance
+// given from the center of a blast with the radius given.
+func (f Falloff) Attenuation(distance m.Real, radius m.Real) m.Real {
+	if distance >= radius {
+		return 0.0
+	}
+	linear := 1.0 - distance/radius
+	if linear > 1.0 {
+		linear = 1.0
+	}
+	switch f {
+	case FalloffQuadratic:
+		return linear * linear
+	case FalloffNone:
+		return 1.0
+	default:
+		return linear
+	}
+}
+
+// Explosion applies an outward impulse to the bodies overlapping a sphere
+// around a point. The impulse on each body weakens with the distance from the
+// center to the nearest part of its colliders' bounds.
+type Explosion struct {
+	// Center is the World Space point the explosion starts from.
+	Center m.Vector3
+
+	// Radius is the distance from the center beyond which bodies are unaffected.
+	Radius m.Real
+
+	// Impulse is the magnitude of the impulse applied to a body at the center.
+	Impulse m.Real
+
+	// Falloff is how the impulse weakens towards the edge of the blast.
+	// Defaults to FalloffLinear.
+	Falloff Falloff
+
+	// UpwardBias tilts the impulse upwards, against the gravity of each body,
+	// so that things get thrown into the air instead of skidding along the
+	// ground. A value of one adds as much upwards as outwards.
+	// Defaults to 0.0.
+	UpwardBias m.Real
+
+	// Occlusion enables raycasts from the center to each body against the
+	// static geometry (colliders without a body or with infinite mass) so that
+	// explosions don't push objects through walls.
+	// Defaults to false.
+	Occlusion bool
+
+	// OcclusionAttenuation is the fraction of the impulse that still reaches a
+	// body that is blocked by static geometry. Zero skips blocked bodies.
+	// Defaults to 0.0.
+	OcclusionAttenuation m.Real
+}
+
+// NewExplosion creates a new Explosion at the center with the radius and impulse given.
+func NewExplosion(center m.Vector3, radius m.Real, impulse m.Real) *Explosion {
+	e := new(Explosion)
+	e.Center = center
+	e.Radius = radius
+	e.Impulse = impulse
+	return e
+}
+
+// Apply applies the impulse of the explosion to the bodies of the colliders
+// given and returns the bodies that were affected, in the order their
+// colliders were given.
+func (e *Explosion) Apply(colliders []Collider) []*RigidBody {
+	// gather the static geometry that can block the blast and the distance
+	// from the center to the nearest collider of each body
+	var occluders []Collider
+	var bodies []*RigidBody
+	distances := make(map[*RigidBody]m.Real)
+	for _, c := range colliders {
+		body := c.GetBody()
+		if body == nil || !body.HasFiniteMass() {
+			if e.Occlusion {
+				occluders = append(occluders, c)
+			}
+			continue
+		}
+
+		bounds := ColliderBounds(c)
+		nearest := bounds.ClosestPoint(&e.Center)
+		nearest.Sub(&e.Center)
+		distance := nearest.Magnitude()
+		if existing, ok := distances[body]; !ok {
+			bodies = append(bodies, body)
+		} else if existing < distance {
+			continue
+		}
+		distances[body] = distance
+	}
+
+	var affected []*RigidBody
+	for _, body := range bodies {
+		magnitude := e.Impulse * e.Falloff.Attenuation(distances[body], e.Radius)
+		if magnitude <= 0.0 {
+			continue
+		}
+
+		target := body.GetCenterOfMassWorld()
+		direction := target
+		direction.Sub(&e.Center)
+		distance := direction.Magnitude()
+		if distance > m.Epsilon {
+			direction.MulWith(1.0 / distance)
+		} else {
+			direction = m.Vector3{0.0, 1.0, 0.0}
+		}
+
+		if e.Occlusion && isOccluded(occluders, &e.Center, &direction, distance) {
+			magnitude *= e.OcclusionAttenuation
+			if magnitude <= 0.0 {
+				continue
+			}
+		}
+
+		if e.UpwardBias != 0.0 {
+			up := body.GetGravity()
+			if up.SquareMagnitude() > 0.0 {
+				up.Normalize()
+				up.MulWith(-1.0)
+			} else {
+				up = m.Vector3{0.0, 1.0, 0.0}
+			}
+			direction.AddScaled(&up, e.UpwardBias)
+			if direction.SquareMagnitude() > 0.0 {
+				direction.Normalize()
+			}
+		}
+
+		impulse := direction
+		impulse.MulWith(magnitude)
+		body.ApplyLinearImpulse(&impulse)
+		affected = append(affected, body)
+	}
+	return affected
+}
+
+// Bounds returns the Bounds of the sphere of the blast.
+func (e *Explosion) Bounds() Bounds {
+	return m.AABBAround(&e.Center, e.Radius)
+}
+
+// ApplyExplosion applies outward impulses to the bodies overlapping the
+// sphere of the radius around the center, weakened by the falloff with their
+// distance from it, and returns the bodies that were affected. Use Explode
+// for an upward bias or occlusion.
+func (w *World) ApplyExplosion(center *m.Vector3, radius m.Real, impulse m.Real, falloff Falloff) []*RigidBody {
+	e := NewExplosion(*center, radius, impulse)
+	e.Falloff = falloff
+	return w.Explode(e)
+}
+
+// Explode applies the explosion to the bodies in the world that overlap its
+// blast and returns the bodies that were affected.
+func (w *World) Explode(e *Explosion) []*RigidBody {
+	bounds := e.Bounds()
+	return e.Apply(w.QueryBounds(&bounds))
+}
+
+// isOccluded returns true if a ray from the origin along the direction hits
+// any of the occluders before travelling the distance given.
+func isOccluded(occluders []Collider, origin *m.Vector3, direction *m.Vector3, distance m.Real) bool {
+	for _, c := range occluders {
+		if hit, result := RaycastCollider(c, origin, direction); hit && result.Distance < distance {
+			return true
+		}
+	}
+	return false
+}
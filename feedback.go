@@ -0,0 +1,105 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// JointFeedback holds the load a joint put on its bodies in the last step,
+// from both the contacts that held it together and any motors or springs.
+// It can be used to measure the strain on a structure, play creaking sounds
+// or break joints that carry too much.
+type JointFeedback struct {
+	// Forces holds the force the joint applied to each body. If the second
+	// body is nil, its force is the one taken by the world.
+	Forces [2]m.Vector3
+
+	// Torques holds the torque the joint applied to each body about its
+	// center of mass. It's zero for a nil body.
+	Torques [2]m.Vector3
+
+	// bodies holds the bodies of the joint when the step started.
+	bodies [2]*RigidBody
+
+	// contacts holds the contacts the joint generated in the step.
+	contacts []*Contact
+}
+
+// GetJointFeedback returns the load the joint put on its bodies in the last step.
+func (w *World) GetJointFeedback(j *Joint) JointFeedback {
+	if f, ok := w.feedback[j]; ok {
+		return *f
+	}
+	return JointFeedback{}
+}
+
+// GetConstraintFeedback returns the load the constraint put on its bodies in
+// the last step.
+func (w *World) GetConstraintFeedback(c Constraint) JointFeedback {
+	if f, ok := w.feedback[c]; ok {
+		return *f
+	}
+	return JointFeedback{}
+}
+
+// trackFeedback starts the feedback for a joint in the step, keeping hold of
+// the contacts it generated.
+func (w *World) trackFeedback(joint interface{}, bodies [2]*RigidBody, contacts []*Contact) {
+	f := new(JointFeedback)
+	f.bodies = bodies
+	if len(contacts) > 0 {
+		f.contacts = append(f.contacts, contacts...)
+	}
+	w.feedback[joint] = f
+}
+
+// finishFeedback adds the impulses applied by the resolved contacts of each
+// joint to its feedback and turns the impulses into forces and torques.
+func (w *World) finishFeedback(duration m.Real) {
+	// each feedback only adds up its own contacts, so the order of the map
+	// doesn't change the results
+	for _, f := range w.feedback {
+		for _, c := range f.contacts {
+			f.addImpulse(0, &c.impulse, &c.ContactPoint)
+			reaction := c.impulse
+			reaction.MulWith(-1.0)
+			f.addImpulse(1, &reaction, &c.ContactPoint)
+		}
+		f.contacts = nil
+
+		if duration > 0.0 {
+			for i := range f.Forces {
+				f.Forces[i].MulWith(1.0 / duration)
+				f.Torques[i].MulWith(1.0 / duration)
+			}
+		}
+	}
+}
+
+// addImpulse adds an impulse applied to one of the bodies at the point given,
+// both in World Space. If the point is nil, the impulse goes through the
+// center of mass of the body.
+func (f *JointFeedback) addImpulse(index int, impulse *m.Vector3, point *m.Vector3) {
+	if f == nil {
+		return
+	}
+	f.Forces[index].Add(impulse)
+	if point != nil && f.bodies[index] != nil {
+		arm := *point
+		com := f.bodies[index].GetCenterOfMassWorld()
+		arm.Sub(&com)
+		torque := arm.Cross(impulse)
+		f.Torques[index].Add(&torque)
+	}
+}
+
+// addAngularImpulse adds an angular impulse, in World Space, applied to one
+// of the bodies.
+func (f *JointFeedback) addAngularImpulse(index int, impulse *m.Vector3) {
+	if f == nil || f.bodies[index] == nil {
+		return
+	}
+	f.Torques[index].Add(impulse)
+}
@@ -0,0 +1,592 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// ForceGenerator adds a force to a RigidBody each time it's updated.
+type ForceGenerator interface {
+	// UpdateForce calculates and adds the force acting on the body for a
+	// step of the given duration.
+	UpdateForce(body *RigidBody, duration m.Real)
+}
+
+// ForceRegistry keeps track of the force generators and the bodies they apply to.
+type ForceRegistry struct {
+	// bodies holds the bodies with generators in the order they were registered.
+	bodies []*RigidBody
+
+	// generators holds the generators registered for each body.
+	generators map[*RigidBody][]ForceGenerator
+}
+
+// NewForceRegistry creates a new, empty ForceRegistry.
+func NewForceRegistry() *ForceRegistry {
+	r := new(ForceRegistry)
+	r.generators = make(map[*RigidBody][]ForceGenerator)
+	return r
+}
+
+// Add registers the force generator to apply to the body.
+func (r *ForceRegistry) Add(body *RigidBody, fg ForceGenerator) {
+	if r.generators == nil {
+		r.generators = make(map[*RigidBody][]ForceGenerator)
+	}
+	if _, ok := r.generators[body]; !ok {
+		r.bodies = append(r.bodies, body)
+	}
+	r.generators[body] = append(r.generators[body], fg)
+}
+
+// Remove unregisters the force generator from the body. Nothing happens if
+// the pair isn't registered.
+func (r *ForceRegistry) Remove(body *RigidBody, fg ForceGenerator) {
+	generators := r.generators[body]
+	for i, existing := range generators {
+		if existing != fg {
+			continue
+		}
+		generators = append(generators[:i], generators[i+1:]...)
+		if len(generators) > 0 {
+			r.generators[body] = generators
+			return
+		}
+
+		// that was the last generator for the body
+		delete(r.generators, body)
+		for j, b := range r.bodies {
+			if b == body {
+				r.bodies = append(r.bodies[:j], r.bodies[j+1:]...)
+				break
+			}
+		}
+		return
+	}
+}
+
+// Clear removes all of the registrations.
+func (r *ForceRegistry) Clear() {
+	r.bodies = nil
+	r.generators = make(map[*RigidBody][]ForceGenerator)
+}
+
+// ApplyTo calls the generators registered for the body.
+func (r *ForceRegistry) ApplyTo(body *RigidBody, duration m.Real) {
+	for _, fg := range r.generators[body] {
+		fg.UpdateForce(body, duration)
+	}
+}
+
+// UpdateForces calls all of the generators for all of their bodies. This only
+// needs to be called when the registry isn't used by a World.
+func (r *ForceRegistry) UpdateForces(duration m.Real) {
+	for _, body := range r.bodies {
+		r.ApplyTo(body, duration)
+	}
+}
+
+// springForce returns the force of a damped spring pulling the point towards
+// the other end. The velocity is the velocity of the point relative to the
+// other end. A slack spring, like a bungee, has no force unless it's stretched
+// and never pushes, even while its damping resists the ends closing quickly.
+func springForce(point *m.Vector3, otherEnd *m.Vector3, velocity *m.Vector3,
+	springConstant m.Real, restLength m.Real, damping m.Real, slack bool) (bool, m.Vector3) {
+	direction := *point
+	direction.Sub(otherEnd)
+	length := direction.Magnitude()
+	if length < m.Epsilon || (slack && length <= restLength) {
+		return false, direction
+	}
+	direction.MulWith(1.0 / length)
+
+	magnitude := springConstant*(length-restLength) + damping*velocity.Dot(&direction)
+	if slack && magnitude < 0.0 {
+		magnitude = 0.0
+	}
+	direction.MulWith(-magnitude)
+	return true, direction
+}
+
+// Drag is a ForceGenerator that slows a body with a force made of a linear and
+// a quadratic part, k1*v + k2*v^2, against its velocity. It models air and
+// water resistance more faithfully than the exponential damping of the body.
+type Drag struct {
+	// K1 is the linear drag coefficient.
+	K1 m.Real
+
+	// K2 is the quadratic drag coefficient.
+	K2 m.Real
+
+	// AngularK1 is the linear drag coefficient for the spin of the body.
+	// Defaults to 0.0.
+	AngularK1 m.Real
+
+	// AngularK2 is the quadratic drag coefficient for the spin of the body.
+	// Defaults to 0.0.
+	AngularK2 m.Real
+}
+
+// NewDrag creates a new Drag with the linear and quadratic coefficients given.
+func NewDrag(k1 m.Real, k2 m.Real) *Drag {
+	d := new(Drag)
+	d.K1 = k1
+	d.K2 = k2
+	return d
+}
+
+// UpdateForce adds the drag force, and torque if there is angular drag, to the body.
+func (d *Drag) UpdateForce(body *RigidBody, duration m.Real) {
+	if speed := body.Velocity.Magnitude(); speed > 0.0 {
+		force := body.Velocity
+		force.MulWith(-(d.K1 + d.K2*speed))
+		body.AddForce(&force)
+	}
+	if d.AngularK1 == 0.0 && d.AngularK2 == 0.0 {
+		return
+	}
+	if spin := body.Rotation.Magnitude(); spin > 0.0 {
+		torque := body.Rotation
+		torque.MulWith(-(d.AngularK1 + d.AngularK2*spin))
+		body.AddTorque(&torque)
+	}
+}
+
+// AnchoredSpring is a ForceGenerator that connects a point on a body to a
+// fixed point in World Space with a damped spring.
+type AnchoredSpring struct {
+	// ConnectionPoint is the point on the body, in Body Space, that the spring is attached to.
+	ConnectionPoint m.Vector3
+
+	// Anchor is the fixed World Space point at the other end of the spring.
+	Anchor m.Vector3
+
+	// SpringConstant is the stiffness of the spring.
+	SpringConstant m.Real
+
+	// RestLength is the length of the spring when it applies no force.
+	RestLength m.Real
+
+	// Damping resists the motion of the connection point along the spring.
+	// Defaults to 0.0.
+	Damping m.Real
+}
+
+// NewAnchoredSpring creates a new AnchoredSpring between the point on a body and the anchor.
+func NewAnchoredSpring(connectionPoint *m.Vector3, anchor *m.Vector3, springConstant m.Real, restLength m.Real) *AnchoredSpring {
+	s := new(AnchoredSpring)
+	s.ConnectionPoint = *connectionPoint
+	s.Anchor = *anchor
+	s.SpringConstant = springConstant
+	s.RestLength = restLength
+	return s
+}
+
+// UpdateForce adds the spring force to the body at the connection point.
+func (s *AnchoredSpring) UpdateForce(body *RigidBody, duration m.Real) {
+	addAnchoredSpringForce(body, &s.ConnectionPoint, &s.Anchor, s.SpringConstant, s.RestLength, s.Damping, false)
+}
+
+// addAnchoredSpringForce adds the force of a spring between the point on the
+// body, in Body Space, and the anchor in World Space.
+func addAnchoredSpringForce(body *RigidBody, connectionPoint *m.Vector3, anchor *m.Vector3,
+	springConstant m.Real, restLength m.Real, damping m.Real, slack bool) {
+	point := body.transform.MulVector3(connectionPoint)
+	velocity := body.GetVelocityAtPoint(&point)
+	ok, force := springForce(&point, anchor, &velocity, springConstant, restLength, damping, slack)
+	if ok {
+		body.AddForceAtPoint(&force, &point)
+	}
+}
+
+// Spring is a ForceGenerator that connects points on two bodies with a damped
+// spring. It applies equal and opposite forces to the two bodies, so it has to
+// be registered for both of them, which Register does.
+type Spring struct {
+	// Bodies holds the two bodies connected by the spring.
+	Bodies [2]*RigidBody
+
+	// ConnectionPoints holds the points, in the Body Space of each body,
+	// that the ends of the spring are attached to.
+	ConnectionPoints [2]m.Vector3
+
+	// SpringConstant is the stiffness of the spring.
+	SpringConstant m.Real
+
+	// RestLength is the length of the spring when it applies no force.
+	RestLength m.Real
+
+	// Damping resists the motion of the two ends towards or away from each other.
+	// Defaults to 0.0.
+	Damping m.Real
+}
+
+// NewSpring creates a new Spring between a point on one body and a point on another.
+func NewSpring(one *RigidBody, pointOne *m.Vector3, two *RigidBody, pointTwo *m.Vector3, springConstant m.Real, restLength m.Real) *Spring {
+	s := new(Spring)
+	s.Bodies[0] = one
+	s.Bodies[1] = two
+	s.ConnectionPoints[0] = *pointOne
+	s.ConnectionPoints[1] = *pointTwo
+	s.SpringConstant = springConstant
+	s.RestLength = restLength
+	return s
+}
+
+// Register adds the spring to the registry for both of its bodies.
+func (s *Spring) Register(r *ForceRegistry) {
+	r.Add(s.Bodies[0], s)
+	r.Add(s.Bodies[1], s)
+}
+
+// Unregister removes the spring from the registry for both of its bodies.
+func (s *Spring) Unregister(r *ForceRegistry) {
+	r.Remove(s.Bodies[0], s)
+	r.Remove(s.Bodies[1], s)
+}
+
+// UpdateForce adds the spring force to whichever end of the spring the body is.
+func (s *Spring) UpdateForce(body *RigidBody, duration m.Real) {
+	addLinkedSpringForce(body, &s.Bodies, &s.ConnectionPoints, s.SpringConstant, s.RestLength, s.Damping, false)
+}
+
+// addLinkedSpringForce adds the force of a spring between points on two bodies
+// to whichever end of the spring the body is.
+func addLinkedSpringForce(body *RigidBody, bodies *[2]*RigidBody, connectionPoints *[2]m.Vector3,
+	springConstant m.Real, restLength m.Real, damping m.Real, slack bool) {
+	var this, other int
+	switch body {
+	case bodies[0]:
+		this, other = 0, 1
+	case bodies[1]:
+		this, other = 1, 0
+	default:
+		return
+	}
+
+	point := bodies[this].transform.MulVector3(&connectionPoints[this])
+	otherPoint := bodies[other].transform.MulVector3(&connectionPoints[other])
+	velocity := bodies[this].GetVelocityAtPoint(&point)
+	otherVelocity := bodies[other].GetVelocityAtPoint(&otherPoint)
+	velocity.Sub(&otherVelocity)
+
+	ok, force := springForce(&point, &otherPoint, &velocity, springConstant, restLength, damping, slack)
+	if ok {
+		body.AddForceAtPoint(&force, &point)
+	}
+}
+
+// AnchoredBungee is a ForceGenerator that connects a point on a body to a
+// fixed point in World Space with an elastic cord. Unlike AnchoredSpring it
+// only pulls when stretched beyond its rest length and never pushes.
+type AnchoredBungee struct {
+	// ConnectionPoint is the point on the body, in Body Space, that the bungee is attached to.
+	ConnectionPoint m.Vector3
+
+	// Anchor is the fixed World Space point at the other end of the bungee.
+	Anchor m.Vector3
+
+	// SpringConstant is the stiffness of the bungee when it's stretched.
+	SpringConstant m.Real
+
+	// RestLength is the length the bungee has to be stretched beyond before it pulls.
+	RestLength m.Real
+
+	// Damping resists the motion of the connection point along the bungee
+	// while it's stretched.
+	// Defaults to 0.0.
+	Damping m.Real
+}
+
+// NewAnchoredBungee creates a new AnchoredBungee between the point on a body and the anchor.
+func NewAnchoredBungee(connectionPoint *m.Vector3, anchor *m.Vector3, springConstant m.Real, restLength m.Real) *AnchoredBungee {
+	b := new(AnchoredBungee)
+	b.ConnectionPoint = *connectionPoint
+	b.Anchor = *anchor
+	b.SpringConstant = springConstant
+	b.RestLength = restLength
+	return b
+}
+
+// UpdateForce adds the bungee force to the body at the connection point if
+// the bungee is stretched.
+func (b *AnchoredBungee) UpdateForce(body *RigidBody, duration m.Real) {
+	addAnchoredSpringForce(body, &b.ConnectionPoint, &b.Anchor, b.SpringConstant, b.RestLength, b.Damping, true)
+}
+
+// Bungee is a ForceGenerator that connects points on two bodies with an
+// elastic cord. Unlike Spring it only pulls the bodies together when stretched
+// beyond its rest length and never pushes them apart. Like Spring, it has to
+// be registered for both bodies, which Register does.
+type Bungee struct {
+	// Bodies holds the two bodies connected by the bungee.
+	Bodies [2]*RigidBody
+
+	// ConnectionPoints holds the points, in the Body Space of each body,
+	// that the ends of the bungee are attached to.
+	ConnectionPoints [2]m.Vector3
+
+	// SpringConstant is the stiffness of the bungee when it's stretched.
+	SpringConstant m.Real
+
+	// RestLength is the length the bungee has to be stretched beyond before it pulls.
+	RestLength m.Real
+
+	// Damping resists the motion of the two ends towards or away from each
+	// other while the bungee is stretched.
+	// Defaults to 0.0.
+	Damping m.Real
+}
+
+// NewBungee creates a new Bungee between a point on one body and a point on another.
+func NewBungee(one *RigidBody, pointOne *m.Vector3, two *RigidBody, pointTwo *m.Vector3, springConstant m.Real, restLength m.Real) *Bungee {
+	b := new(Bungee)
+	b.Bodies[0] = one
+	b.Bodies[1] = two
+	b.ConnectionPoints[0] = *pointOne
+	b.ConnectionPoints[1] = *pointTwo
+	b.SpringConstant = springConstant
+	b.RestLength = restLength
+	return b
+}
+
+// Register adds the bungee to the registry for both of its bodies.
+func (b *Bungee) Register(r *ForceRegistry) {
+	r.Add(b.Bodies[0], b)
+	r.Add(b.Bodies[1], b)
+}
+
+// Unregister removes the bungee from the registry for both of its bodies.
+func (b *Bungee) Unregister(r *ForceRegistry) {
+	r.Remove(b.Bodies[0], b)
+	r.Remove(b.Bodies[1], b)
+}
+
+// UpdateForce adds the bungee force to whichever end of the bungee the body is
+// if the bungee is stretched.
+func (b *Bungee) UpdateForce(body *RigidBody, duration m.Real) {
+	addLinkedSpringForce(body, &b.Bodies, &b.ConnectionPoints, b.SpringConstant, b.RestLength, b.Damping, true)
+}
+
+// ImplicitSpring is a ForceGenerator for very stiff springs between a point on
+// a body and a fixed anchor, which would explode with an ordinary spring unless
+// the step was tiny. Instead of using the stretch of the spring at the start of
+// the step, it solves for the force the spring will have at the end of the step,
+// which always takes energy out of the motion and settles towards the rest
+// position however stiff the spring is. The extra damping that gives is only
+// noticeable for springs that are too stiff for the step anyway.
+type ImplicitSpring struct {
+	// ConnectionPoint is the point on the body, in Body Space, that the spring is attached to.
+	ConnectionPoint m.Vector3
+
+	// Anchor is the fixed World Space point at the other end of the spring.
+	Anchor m.Vector3
+
+	// SpringConstant is the stiffness of the spring.
+	SpringConstant m.Real
+
+	// RestLength is the length of the spring when it applies no force.
+	// Defaults to 0.0.
+	RestLength m.Real
+
+	// Damping resists the motion of the connection point.
+	// Defaults to 0.0.
+	Damping m.Real
+}
+
+// NewImplicitSpring creates a new ImplicitSpring between the point on a body and the anchor.
+func NewImplicitSpring(connectionPoint *m.Vector3, anchor *m.Vector3, springConstant m.Real) *ImplicitSpring {
+	s := new(ImplicitSpring)
+	s.ConnectionPoint = *connectionPoint
+	s.Anchor = *anchor
+	s.SpringConstant = springConstant
+	return s
+}
+
+// UpdateForce adds the force of the spring at the end of the step to the body
+// at the connection point.
+func (s *ImplicitSpring) UpdateForce(body *RigidBody, duration m.Real) {
+	if !body.HasFiniteMass() || duration <= 0.0 {
+		return
+	}
+
+	point := body.transform.MulVector3(&s.ConnectionPoint)
+	velocity := body.GetVelocityAtPoint(&point)
+
+	// the displacement from where the spring would be at rest
+	stretch := point
+	stretch.Sub(&s.Anchor)
+	if length := stretch.Magnitude(); length > m.Epsilon {
+		stretch.MulWith((length - s.RestLength) / length)
+	}
+
+	// take a backward Euler step of the point: solve for its velocity at the
+	// end of the step when pushed by the spring and damping forces at that
+	// time, taking the mass and inertia of the body into account
+	com := body.GetCenterOfMassWorld()
+	relative := point
+	relative.Sub(&com)
+	response := contactImpulseMatrix(body, &relative)
+
+	k, d := s.SpringConstant, s.Damping
+	system := response
+	system.MulWith(k*duration*duration + d*duration)
+	system[0] += 1.0
+	system[4] += 1.0
+	system[8] += 1.0
+
+	pull := response.MulVector3(&stretch)
+	next := velocity
+	next.AddScaled(&pull, -k*duration)
+	inverse := system.Invert()
+	next = inverse.MulVector3(&next)
+
+	force := stretch
+	force.AddScaled(&next, duration)
+	force.MulWith(-k)
+	force.AddScaled(&next, -d)
+	body.AddForceAtPoint(&force, &point)
+}
+
+// TorsionSpring is a ForceGenerator that twists a body towards a target
+// orientation with a torque in proportion to how far it's turned away from it,
+// for things like self-righting toys, sprung doors and compass needles.
+type TorsionSpring struct {
+	// Target is the World Space orientation the spring twists the body towards.
+	Target m.Quat
+
+	// SpringConstant is the torque per radian of twist away from the target.
+	SpringConstant m.Real
+
+	// Damping resists the rotation of the body.
+	// Defaults to 0.0.
+	Damping m.Real
+}
+
+// NewTorsionSpring creates a new TorsionSpring twisting towards the target orientation.
+func NewTorsionSpring(target *m.Quat, springConstant m.Real) *TorsionSpring {
+	s := new(TorsionSpring)
+	s.Target = *target
+	s.Target.Normalize()
+	s.SpringConstant = springConstant
+	return s
+}
+
+// UpdateForce adds the torque that twists the body towards the target.
+func (s *TorsionSpring) UpdateForce(body *RigidBody, duration m.Real) {
+	// the rotation from the current orientation to the target, the short way round
+	twist := s.Target
+	current := body.Orientation.Conjugated()
+	twist.Mul(&current)
+	if twist[0] < 0.0 {
+		twist.Scale(-1.0)
+	}
+
+	torque := m.Vector3{twist[1], twist[2], twist[3]}
+	sinHalfAngle := torque.Magnitude()
+	if sinHalfAngle > m.Epsilon {
+		angle := 2.0 * m.Real(math.Atan2(float64(sinHalfAngle), float64(twist[0])))
+		torque.MulWith(s.SpringConstant * angle / sinHalfAngle)
+	}
+	torque.AddScaled(&body.Rotation, -s.Damping)
+	if torque.SquareMagnitude() > 0.0 {
+		body.AddTorque(&torque)
+	}
+}
+
+// Magnet is a ForceGenerator that pulls every pair of its bodies towards each
+// other, or pushes them apart when the Strength is negative, for things like
+// magnets, tractor beams and clumps of orbiting debris. The forces are equal
+// and opposite, so it has to be registered for all of its bodies, which
+// Register does.
+type Magnet struct {
+	// Bodies holds the bodies that attract or repel each other.
+	Bodies []*RigidBody
+
+	// Strength is the force between two bodies at a distance of one for
+	// AttractInverseSquare, or at any distance for AttractConstant. A negative
+	// value pushes the bodies apart.
+	Strength m.Real
+
+	// Falloff is how the force changes with distance.
+	// Defaults to AttractInverseSquare.
+	Falloff AttractorFalloff
+
+	// MinDistance is the distance below which the force stops growing.
+	// Defaults to 0.0.
+	MinDistance m.Real
+
+	// Range is the distance beyond which bodies don't affect each other. A
+	// value of zero or less means there is no limit.
+	// Defaults to 0.0.
+	Range m.Real
+
+	// MaxForce limits the force between any two bodies. A value of zero or
+	// less means there is no limit.
+	// Defaults to 0.0.
+	MaxForce m.Real
+}
+
+// NewMagnet creates a new Magnet between the bodies with the strength given.
+func NewMagnet(strength m.Real, bodies ...*RigidBody) *Magnet {
+	mg := new(Magnet)
+	mg.Strength = strength
+	mg.Bodies = bodies
+	return mg
+}
+
+// Register adds the magnet to the registry for all of its bodies.
+func (mg *Magnet) Register(r *ForceRegistry) {
+	for _, body := range mg.Bodies {
+		r.Add(body, mg)
+	}
+}
+
+// Unregister removes the magnet from the registry for all of its bodies.
+func (mg *Magnet) Unregister(r *ForceRegistry) {
+	for _, body := range mg.Bodies {
+		r.Remove(body, mg)
+	}
+}
+
+// UpdateForce adds the pull or push of all of the other bodies of the magnet
+// to the body.
+func (mg *Magnet) UpdateForce(body *RigidBody, duration m.Real) {
+	com := body.GetCenterOfMassWorld()
+	var total m.Vector3
+	for _, other := range mg.Bodies {
+		if other == body {
+			continue
+		}
+		direction := other.GetCenterOfMassWorld()
+		direction.Sub(&com)
+		distance := direction.Magnitude()
+		if distance <= m.Epsilon || (mg.Range > 0.0 && distance > mg.Range) {
+			continue
+		}
+
+		magnitude := mg.Strength
+		if mg.Falloff == AttractInverseSquare {
+			if distance < mg.MinDistance {
+				distance = mg.MinDistance
+			}
+			magnitude /= distance * distance
+		}
+		if mg.MaxForce > 0.0 {
+			if magnitude > mg.MaxForce {
+				magnitude = mg.MaxForce
+			} else if magnitude < -mg.MaxForce {
+				magnitude = -mg.MaxForce
+			}
+		}
+		direction.Normalize()
+		total.AddScaled(&direction, magnitude)
+	}
+	if total.SquareMagnitude() > 0.0 {
+		body.AddForce(&total)
+	}
+}
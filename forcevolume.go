@@ -0,0 +1,136 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// ForceVolume is a region of space that pushes on the bodies overlapping it,
+// for things like wind tunnels, updrafts and water currents. Volumes added to
+// a World are applied to the awake bodies at the start of each step.
+type ForceVolume struct {
+	// Region is the space the force acts in. A *SphereVolume or *BoxVolume
+	// affects the bodies whose colliders' bounds overlap it; any other Volume
+	// affects the bodies whose center of mass it contains.
+	Region Volume
+
+	// Force is the force, in World Space, added to each body in the volume.
+	// Defaults to no force.
+	Force m.Vector3
+
+	// Acceleration is the acceleration, in World Space, given to each body in
+	// the volume regardless of its mass.
+	// Defaults to no acceleration.
+	Acceleration m.Vector3
+}
+
+// NewForceVolume creates a new ForceVolume over the region that doesn't push
+// on anything until its Force or Acceleration is set.
+func NewForceVolume(region Volume) *ForceVolume {
+	fv := new(ForceVolume)
+	fv.Region = region
+	return fv
+}
+
+// Bounds returns the Bounds of the region of the volume.
+func (fv *ForceVolume) Bounds() Bounds {
+	return regionBounds(fv.Region)
+}
+
+// regionBounds returns the Bounds of a *SphereVolume or *BoxVolume, or
+// InfiniteBounds for any other Volume.
+func regionBounds(region Volume) Bounds {
+	var b Bounds
+	switch region := region.(type) {
+	case *SphereVolume:
+		b = m.AABBAround(&region.Center, region.Radius)
+	case *BoxVolume:
+		b.Min = region.Center
+		b.Min.Sub(&region.HalfSize)
+		b.Max = region.Center
+		b.Max.Add(&region.HalfSize)
+	default:
+		b = InfiniteBounds()
+	}
+	return b
+}
+
+// Overlaps returns true if the collider is in the volume.
+func (fv *ForceVolume) Overlaps(c Collider) bool {
+	bounds := ColliderBounds(c)
+	switch region := fv.Region.(type) {
+	case *SphereVolume:
+		nearest := bounds.ClosestPoint(&region.Center)
+		return region.Contains(&nearest)
+	case *BoxVolume:
+		regionBounds := fv.Bounds()
+		return bounds.Overlaps(&regionBounds)
+	}
+
+	body := c.GetBody()
+	if body == nil {
+		return false
+	}
+	com := body.GetCenterOfMassWorld()
+	return fv.Region.Contains(&com)
+}
+
+// ApplyTo adds the force of the volume to the body.
+func (fv *ForceVolume) ApplyTo(body *RigidBody) {
+	force := fv.Force
+	if body.HasFiniteMass() {
+		force.AddScaled(&fv.Acceleration, body.GetMass())
+	}
+	if force.SquareMagnitude() > 0.0 {
+		body.AddForce(&force)
+	}
+}
+
+// AddForceVolume adds the force volume to the world so that it's applied each step.
+func (w *World) AddForceVolume(fv *ForceVolume) {
+	for _, existing := range w.ForceVolumes {
+		if existing == fv {
+			return
+		}
+	}
+	w.ForceVolumes = append(w.ForceVolumes, fv)
+}
+
+// RemoveForceVolume removes the force volume from the world.
+func (w *World) RemoveForceVolume(fv *ForceVolume) {
+	for i, existing := range w.ForceVolumes {
+		if existing == fv {
+			w.ForceVolumes = append(w.ForceVolumes[:i], w.ForceVolumes[i+1:]...)
+			return
+		}
+	}
+}
+
+// findForceVolumeBodies returns the force volumes that each moving body
+// overlaps, using the broadphase to find the candidates if there is one.
+func (w *World) findForceVolumeBodies() map[*RigidBody][]*ForceVolume {
+	if len(w.ForceVolumes) == 0 {
+		return nil
+	}
+
+	inside := make(map[*RigidBody][]*ForceVolume)
+	for _, fv := range w.ForceVolumes {
+		bounds := fv.Bounds()
+		for _, c := range w.QueryBounds(&bounds) {
+			body := c.GetBody()
+			if body == nil || !body.HasFiniteMass() || !fv.Overlaps(c) {
+				continue
+			}
+
+			// a body with several colliders is only pushed once by each volume
+			volumes := inside[body]
+			if len(volumes) > 0 && volumes[len(volumes)-1] == fv {
+				continue
+			}
+			inside[body] = append(volumes, fv)
+		}
+	}
+	return inside
+}
@@ -0,0 +1,203 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	defaultBreakableDivisions   = 2
+	defaultBreakableMinHalfSize = 0.1
+)
+
+// Breakable shatters the cubes added to it into smaller cubes when they're hit
+// hard enough, for crates, walls and windows. When it's added to a World it
+// watches the contacts the solver resolves, and a cube that takes a contact
+// impulse of at least the Threshold is swapped for its fragments at the end
+// of the step. Each cube should be the only collider of a body with finite
+// mass.
+type Breakable struct {
+	// Threshold is the size of the contact impulse that breaks a cube.
+	Threshold m.Real
+
+	// Divisions is the number of fragments a cube is split into along each
+	// of its axes.
+	// Defaults to 2.
+	Divisions int
+
+	// RadialImpulse is the impulse each fragment gets away from the point of
+	// impact on top of the velocity it inherits from the cube.
+	// Defaults to 0.0.
+	RadialImpulse m.Real
+
+	// MinHalfSize is the smallest half size, along every axis, that a
+	// fragment needs to be breakable itself.
+	// Defaults to 0.1.
+	MinHalfSize m.Real
+
+	// OnBreak is called after a cube breaks with the fragments that replaced
+	// it in the world.
+	// Defaults to nil.
+	OnBreak func(cube *CollisionCube, fragments []*CollisionCube)
+
+	// cubes maps the body of each breakable cube to the cube.
+	cubes map[*RigidBody]*CollisionCube
+
+	// world is the world the breakable was added to.
+	world *World
+
+	// subscription watches the resolved contacts.
+	subscription SubscriptionID
+}
+
+// NewBreakable creates a new Breakable that breaks its cubes when they take a
+// contact impulse of at least the threshold given.
+func NewBreakable(threshold m.Real) *Breakable {
+	b := new(Breakable)
+	b.Threshold = threshold
+	b.Divisions = defaultBreakableDivisions
+	b.MinHalfSize = defaultBreakableMinHalfSize
+	b.cubes = make(map[*RigidBody]*CollisionCube)
+	return b
+}
+
+// Add makes the cube breakable. It still has to be added to the world as
+// usual.
+func (b *Breakable) Add(cube *CollisionCube) {
+	b.cubes[cube.Body] = cube
+}
+
+// Remove makes the cube unbreakable again.
+func (b *Breakable) Remove(cube *CollisionCube) {
+	if b.cubes[cube.Body] == cube {
+		delete(b.cubes, cube.Body)
+	}
+}
+
+// GetCubes returns the cubes that can still break.
+func (b *Breakable) GetCubes() []*CollisionCube {
+	cubes := make([]*CollisionCube, 0, len(b.cubes))
+	for _, cube := range b.cubes {
+		cubes = append(cubes, cube)
+	}
+	return cubes
+}
+
+// AddBreakable makes the world break the cubes of the breakable when they're
+// hit hard enough.
+func (w *World) AddBreakable(b *Breakable) {
+	if b.world != nil {
+		return
+	}
+	b.world = w
+	b.subscription = w.Events.Subscribe(EventContactSolved, 0, b.checkContact)
+}
+
+// RemoveBreakable stops the world breaking the cubes of the breakable.
+func (w *World) RemoveBreakable(b *Breakable) {
+	if b.world != w {
+		return
+	}
+	b.world = nil
+	w.Events.Unsubscribe(b.subscription)
+}
+
+// checkContact breaks the cubes in a resolved contact that took an impulse
+// of at least the threshold.
+func (b *Breakable) checkContact(e *Event) {
+	impulse := e.Contact.GetImpulse()
+	if impulse.Magnitude() < b.Threshold {
+		return
+	}
+	for _, body := range e.Bodies {
+		if cube, ok := b.cubes[body]; ok {
+			b.Break(cube, &e.Contact.ContactPoint)
+		}
+	}
+}
+
+// Break swaps the cube for its fragments in the world the breakable was added
+// to, throwing them away from the point of impact given in World Space. It
+// returns the fragments.
+func (b *Breakable) Break(cube *CollisionCube, point *m.Vector3) []*CollisionCube {
+	delete(b.cubes, cube.Body)
+	fragments := FractureCube(cube, b.Divisions)
+	for _, fragment := range fragments {
+		if b.RadialImpulse != 0.0 {
+			direction := fragment.Body.Position
+			direction.Sub(point)
+			direction.Normalize()
+			fragment.Body.Velocity.AddScaled(&direction, b.RadialImpulse*fragment.Body.GetInverseMass())
+		}
+		halfSize := fragment.HalfSize
+		if halfSize[0] >= b.MinHalfSize && halfSize[1] >= b.MinHalfSize && halfSize[2] >= b.MinHalfSize {
+			b.Add(fragment)
+		}
+	}
+
+	if b.world != nil {
+		b.world.RemoveCollider(cube)
+		b.world.RemoveBody(cube.Body)
+		for _, fragment := range fragments {
+			b.world.AddCollider(fragment)
+		}
+	}
+	if b.OnBreak != nil {
+		b.OnBreak(cube, fragments)
+	}
+	return fragments
+}
+
+// FractureCube splits a cube into a grid of smaller cubes, with the number of
+// divisions given along each axis, that fill the same space. Each fragment
+// gets its own body with its share of the mass and the velocity of the cube
+// where it is. The fragments aren't added to a world.
+func FractureCube(cube *CollisionCube, divisions int) []*CollisionCube {
+	if divisions < 1 {
+		divisions = 1
+	}
+	cube.CalculateDerivedData()
+	body := cube.Body
+	count := divisions * divisions * divisions
+	halfSize := cube.HalfSize
+	halfSize.MulWith(1.0 / m.Real(divisions))
+
+	// the fragments sit on their own bodies, turned the way the cube is
+	offset := cube.Offset
+	offset[9], offset[10], offset[11] = 0.0, 0.0, 0.0
+	orientation := body.Orientation
+
+	fragments := make([]*CollisionCube, 0, count)
+	for x := 0; x < divisions; x++ {
+		for y := 0; y < divisions; y++ {
+			for z := 0; z < divisions; z++ {
+				var local m.Vector3
+				for i, cell := range [3]int{x, y, z} {
+					local[i] = -cube.HalfSize[i] + halfSize[i]*m.Real(2*cell+1)
+				}
+				center := cube.transform.MulVector3(&local)
+
+				fragmentBody := body.Clone()
+				fragmentBody.CenterOfMass = m.Vector3{}
+				fragmentBody.Velocity = body.GetVelocityAtPoint(&center)
+				fragmentBody.SetTransform(&center, &orientation)
+				if body.HasFiniteMass() {
+					fragmentBody.SetMass(body.GetMass() / m.Real(count))
+					var inertia m.Matrix3
+					inertia.SetBlockInertiaTensor(&halfSize, fragmentBody.GetMass())
+					fragmentBody.SetInertiaTensor(&inertia)
+				}
+
+				fragment := NewCollisionCube(fragmentBody, halfSize)
+				fragment.Offset = offset
+				fragment.Material = cube.Material
+				fragmentBody.CalculateDerivedData()
+				fragment.CalculateDerivedData()
+				fragments = append(fragments, fragment)
+			}
+		}
+	}
+	return fragments
+}
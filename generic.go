@@ -0,0 +1,298 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// AxisMotion is how a GenericJoint lets the bodies move along or about one of
+// its axes.
+type AxisMotion uint8
+
+const (
+	// AxisLocked stops all motion along the axis.
+	AxisLocked AxisMotion = iota
+
+	// AxisLimited allows motion along the axis between the limits.
+	AxisLimited
+
+	// AxisFree allows any motion along the axis.
+	AxisFree
+)
+
+// GenericAxis configures one of the axes of a GenericJoint.
+type GenericAxis struct {
+	// Motion is how the bodies can move along or about the axis.
+	// Defaults to AxisLocked.
+	Motion AxisMotion
+
+	// LowerLimit is the smallest offset, or angle in radians, along the axis
+	// when the Motion is AxisLimited.
+	LowerLimit m.Real
+
+	// UpperLimit is the largest offset, or angle in radians, along the axis
+	// when the Motion is AxisLimited.
+	UpperLimit m.Real
+
+	// Restitution is how much the bodies bounce back off of the limits.
+	// Defaults to 0.0.
+	Restitution m.Real
+
+	// SpringFrequency, if greater than zero, pulls the axis towards the
+	// SpringTarget with a damped spring of this frequency in hertz. It has no
+	// effect on a locked axis.
+	// Defaults to 0.0.
+	SpringFrequency m.Real
+
+	// SpringDampingRatio is how strongly the spring is damped, where 1.0 is
+	// critically damped.
+	SpringDampingRatio m.Real
+
+	// SpringTarget is the offset, or angle in radians, the spring pulls
+	// the axis towards.
+	SpringTarget m.Real
+}
+
+// GenericJoint links two bodies with a joint frame where each of the three
+// linear and three angular axes can be locked, limited or free, with an
+// optional spring on each. It can be set up as most other joints and covers
+// the odd ones that don't have a type of their own. Like the other joints
+// it's enforced with contacts: along the linear axes at the second anchor,
+// and at the end of a lever for the angular axes.
+type GenericJoint struct {
+	ConstraintSoftness
+
+	// Bodies holds the two bodies that are joined. The second body can be nil,
+	// in which case the first is joined to the world.
+	Bodies [2]*RigidBody
+
+	// Anchors holds the origin of the joint frame in the Body Space of each
+	// body. If the second body is nil, its anchor is in World Space.
+	Anchors [2]m.Vector3
+
+	// Frames holds the orientation of the joint frame in the Body Space of
+	// each body. If the second body is nil, its frame is in World Space.
+	Frames [2]m.Quat
+
+	// Linear configures the motion along the X, Y and Z axes of the frame. The
+	// offset along an axis is from the first anchor to the second.
+	Linear [3]GenericAxis
+
+	// Angular configures the rotation about the X, Y and Z axes of the frame.
+	// The angles are the parts of the rotation of the second frame from the
+	// first about each axis, so they only add up exactly when the bodies turn
+	// about a single axis.
+	Angular [3]GenericAxis
+
+	// Length is the length of the lever used to enforce the angular axes.
+	// Defaults to 1.0.
+	Length m.Real
+
+	// Error is the distance the bodies are allowed to drift on a locked axis
+	// before the joint generates a contact.
+	Error m.Real
+}
+
+// NewGenericJoint creates a new GenericJoint between the bodies with the joint
+// frame at the anchor and with the orientation given, both in World Space. All
+// of the axes start out locked with the offsets and angles at zero. The derived
+// data of the bodies must be up to date.
+func NewGenericJoint(one *RigidBody, two *RigidBody, anchor m.Vector3, frame m.Quat, err m.Real) *GenericJoint {
+	g := new(GenericJoint)
+	g.Bodies[0] = one
+	g.Bodies[1] = two
+	g.Length = 1.0
+	g.Error = err
+
+	frame.Normalize()
+	for i, body := range g.Bodies {
+		g.Anchors[i] = worldPointToBody(body, &anchor)
+		g.Frames[i] = frame
+		if body != nil {
+			g.Frames[i] = body.Orientation.Conjugated()
+			g.Frames[i].Mul(&frame)
+		}
+	}
+	return g
+}
+
+// SetLinearLimits limits the offset along the axis, 0 for X, 1 for Y and 2 for
+// Z, to the range given.
+func (g *GenericJoint) SetLinearLimits(axis int, lower m.Real, upper m.Real) {
+	g.Linear[axis].Motion = AxisLimited
+	g.Linear[axis].LowerLimit = lower
+	g.Linear[axis].UpperLimit = upper
+}
+
+// SetAngularLimits limits the angle about the axis, 0 for X, 1 for Y and 2 for
+// Z, to the range given in radians.
+func (g *GenericJoint) SetAngularLimits(axis int, lower m.Real, upper m.Real) {
+	g.Angular[axis].Motion = AxisLimited
+	g.Angular[axis].LowerLimit = lower
+	g.Angular[axis].UpperLimit = upper
+}
+
+// GetBodies returns the two bodies of the joint.
+func (g *GenericJoint) GetBodies() [2]*RigidBody {
+	return g.Bodies
+}
+
+// worldFrame returns the orientation of the joint frame of a body in World Space.
+func (g *GenericJoint) worldFrame(i int) m.Quat {
+	if g.Bodies[i] == nil {
+		return g.Frames[i]
+	}
+	frame := g.Bodies[i].Orientation
+	frame.Mul(&g.Frames[i])
+	return frame
+}
+
+// axis returns the axis of the first joint frame, 0 for X, 1 for Y and 2 for
+// Z, in World Space.
+func (g *GenericJoint) axis(axis int) m.Vector3 {
+	frame := g.worldFrame(0)
+	var unit m.Vector3
+	unit[axis] = 1.0
+	return frame.Rotate(&unit)
+}
+
+// LinearState returns the offset along the axis, 0 for X, 1 for Y and 2 for Z,
+// and how fast it's changing.
+func (g *GenericJoint) LinearState(axis int) JointState {
+	var state JointState
+	worldAxis := g.axis(axis)
+	pointOne := bodyPointToWorld(g.Bodies[0], &g.Anchors[0])
+	pointTwo := bodyPointToWorld(g.Bodies[1], &g.Anchors[1])
+
+	separation := pointTwo
+	separation.Sub(&pointOne)
+	state.Position = separation.Dot(&worldAxis)
+
+	points := [2]m.Vector3{pointOne, pointTwo}
+	_, state.Velocity = pointResponse(&g.Bodies, &points, &worldAxis)
+	return state
+}
+
+// AngularState returns the angle about the axis, 0 for X, 1 for Y and 2 for Z,
+// and how fast it's changing.
+func (g *GenericJoint) AngularState(axis int) JointState {
+	var state JointState
+	worldAxis := g.axis(axis)
+
+	// the rotation from the first frame to the second as a rotation vector
+	// in the first frame
+	frameOne := g.worldFrame(0)
+	frameTwo := g.worldFrame(1)
+	relative := frameOne.Conjugated()
+	relative.Mul(&frameTwo)
+	state.Position = rotationVector(&relative)[axis]
+
+	_, state.Velocity = angularResponse(&g.Bodies, &worldAxis)
+	return state
+}
+
+// AddContacts generates the contacts for any axis of the joint that has been
+// violated and appends them to the existing contacts.
+func (g *GenericJoint) AddContacts(existingContacts []*Contact) (bool, []*Contact) {
+	count := len(existingContacts)
+	lever := g.Length
+	if lever <= 0.0 {
+		lever = 1.0
+	}
+
+	for k := 0; k < 3; k++ {
+		axis := g.axis(k)
+
+		violation, restitution, ok := g.Linear[k].violation(g.LinearState(k).Position, g.Error)
+		if ok {
+			// the first body is pushed along the normal and the second against
+			// it, which brings the second back towards the first
+			c := NewContact()
+			c.Bodies = g.Bodies
+			c.ContactNormal = axis
+			c.ContactPoint = bodyPointToWorld(g.Bodies[1], &g.Anchors[1])
+			c.Penetration = violation
+			existingContacts = append(existingContacts, finishAxisContact(c, restitution))
+		}
+
+		violation, restitution, ok = g.Angular[k].violation(g.AngularState(k).Position, g.Error/lever)
+		if ok {
+			// pushing the ends of a lever either side of the anchor in opposite
+			// directions turns the second body back towards the first without
+			// pushing it along
+			arm := g.axis((k + 1) % 3)
+			anchor := bodyPointToWorld(g.Bodies[0], &g.Anchors[0])
+			for _, side := range [2]m.Real{-0.5, 0.5} {
+				c := NewContact()
+				c.Bodies = g.Bodies
+				c.ContactNormal = axis.Cross(&arm)
+				c.ContactNormal.MulWith(side * 2.0)
+				c.ContactPoint = anchor
+				c.ContactPoint.AddScaled(&arm, side*lever)
+				c.Penetration = violation * lever * 0.5
+				existingContacts = append(existingContacts, finishAxisContact(c, restitution))
+			}
+		}
+	}
+	return len(existingContacts) > count, existingContacts
+}
+
+// violation returns how far the position is past the limits of the axis, with
+// a negative value for below the lower limit, along with the restitution to
+// use and true if there is a violation. A locked axis is only violated once
+// the position is further than the tolerance given from zero.
+func (a *GenericAxis) violation(position m.Real, tolerance m.Real) (m.Real, m.Real, bool) {
+	switch a.Motion {
+	case AxisLocked:
+		if m.RealAbs(position) > tolerance {
+			return position, 0.0, true
+		}
+	case AxisLimited:
+		if position > a.UpperLimit {
+			return position - a.UpperLimit, a.Restitution, true
+		} else if position < a.LowerLimit {
+			return position - a.LowerLimit, a.Restitution, true
+		}
+	}
+	return 0.0, 0.0, false
+}
+
+// finishAxisContact flips the contact to face the way that undoes a negative
+// violation and sets it up to only act along its normal.
+func finishAxisContact(c *Contact, restitution m.Real) *Contact {
+	if c.Penetration < 0.0 {
+		c.ContactNormal.MulWith(-1.0)
+		c.Penetration = -c.Penetration
+	}
+	c.Friction = 0.0
+	c.Restitution = restitution
+	return c
+}
+
+// applyImpulses applies the springs of the axes that aren't locked.
+func (g *GenericJoint) applyImpulses(duration m.Real, feedback *JointFeedback) {
+	points := [2]m.Vector3{
+		bodyPointToWorld(g.Bodies[0], &g.Anchors[0]),
+		bodyPointToWorld(g.Bodies[1], &g.Anchors[1]),
+	}
+
+	for k := 0; k < 3; k++ {
+		axis := g.axis(k)
+
+		if spring := &g.Linear[k]; spring.Motion != AxisLocked && spring.SpringFrequency > 0.0 {
+			inverseMass, speed := pointResponse(&g.Bodies, &points, &axis)
+			offset := g.LinearState(k).Position - spring.SpringTarget
+			impulse := springImpulse(inverseMass, offset, speed, spring.SpringFrequency, spring.SpringDampingRatio, duration)
+			applyPointImpulses(&g.Bodies, &points, &axis, impulse, feedback)
+		}
+
+		if spring := &g.Angular[k]; spring.Motion != AxisLocked && spring.SpringFrequency > 0.0 {
+			inverseInertia, speed := angularResponse(&g.Bodies, &axis)
+			offset := g.AngularState(k).Position - spring.SpringTarget
+			impulse := springImpulse(inverseInertia, offset, speed, spring.SpringFrequency, spring.SpringDampingRatio, duration)
+			applyAngularImpulses(&g.Bodies, &axis, impulse, feedback)
+		}
+	}
+}
@@ -0,0 +1,661 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// gltfPhysicsExtension is the glTF extension that describes rigid bodies,
+	// colliders and joints.
+	gltfPhysicsExtension = "KHR_physics_rigid_bodies"
+
+	// gltfShapesExtension is the glTF extension that describes the boxes and
+	// spheres used by the colliders.
+	gltfShapesExtension = "KHR_implicit_shapes"
+
+	// gltfPlaneSize is the width of the box that stands in for an infinite
+	// plane, and gltfPlaneThickness is its depth below the surface.
+	gltfPlaneSize      = 1000.0
+	gltfPlaneThickness = 1.0
+
+	// gltfSphereRings and gltfSphereSectors are the resolution of the mesh
+	// drawn for spheres.
+	gltfSphereRings   = 8
+	gltfSphereSectors = 16
+
+	// glTF constants for the accessors and buffer views.
+	gltfFloat              = 5126
+	gltfUnsignedInt        = 5125
+	gltfArrayBuffer        = 34962
+	gltfElementArrayBuffer = 34963
+)
+
+// gltfDocument is the top level of a glTF file.
+type gltfDocument struct {
+	Asset          gltfAsset              `json:"asset"`
+	ExtensionsUsed []string               `json:"extensionsUsed"`
+	Extensions     map[string]interface{} `json:"extensions"`
+	Scene          int                    `json:"scene"`
+	Scenes         []gltfScene            `json:"scenes"`
+	Nodes          []*gltfNode            `json:"nodes"`
+	Meshes         []gltfMesh             `json:"meshes,omitempty"`
+	Accessors      []gltfAccessor         `json:"accessors,omitempty"`
+	BufferViews    []gltfBufferView       `json:"bufferViews,omitempty"`
+	Buffers        []gltfBuffer           `json:"buffers,omitempty"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name        string                 `json:"name,omitempty"`
+	Children    []int                  `json:"children,omitempty"`
+	Translation *[3]float64            `json:"translation,omitempty"`
+	Rotation    *[4]float64            `json:"rotation,omitempty"`
+	Mesh        *int                   `json:"mesh,omitempty"`
+	Extensions  map[string]interface{} `json:"extensions,omitempty"`
+}
+
+type gltfMesh struct {
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target"`
+}
+
+type gltfBuffer struct {
+	ByteLength int    `json:"byteLength"`
+	URI        string `json:"uri"`
+}
+
+// gltfShape is a box or sphere of the KHR_implicit_shapes extension.
+type gltfShape struct {
+	Type   string           `json:"type"`
+	Box    *gltfBoxShape    `json:"box,omitempty"`
+	Sphere *gltfSphereShape `json:"sphere,omitempty"`
+}
+
+type gltfBoxShape struct {
+	Size [3]float64 `json:"size"`
+}
+
+type gltfSphereShape struct {
+	Radius float64 `json:"radius"`
+}
+
+// gltfPhysicsMaterial is a surface material of the KHR_physics_rigid_bodies
+// extension.
+type gltfPhysicsMaterial struct {
+	StaticFriction  float64 `json:"staticFriction"`
+	DynamicFriction float64 `json:"dynamicFriction"`
+	Restitution     float64 `json:"restitution"`
+}
+
+// gltfJoint describes the degrees of freedom of a joint in the
+// KHR_physics_rigid_bodies extension.
+type gltfJoint struct {
+	Limits []gltfJointLimit `json:"limits"`
+	Drives []gltfJointDrive `json:"drives,omitempty"`
+}
+
+type gltfJointLimit struct {
+	LinearAxes  []int   `json:"linearAxes,omitempty"`
+	AngularAxes []int   `json:"angularAxes,omitempty"`
+	Min         float64 `json:"min"`
+	Max         float64 `json:"max"`
+}
+
+type gltfJointDrive struct {
+	Type           string  `json:"type"`
+	Mode           string  `json:"mode"`
+	Axis           int     `json:"axis"`
+	MaxForce       float64 `json:"maxForce"`
+	PositionTarget float64 `json:"positionTarget,omitempty"`
+	VelocityTarget float64 `json:"velocityTarget,omitempty"`
+	Stiffness      float64 `json:"stiffness,omitempty"`
+	Damping        float64 `json:"damping,omitempty"`
+}
+
+// gltfPhysicsNode is the KHR_physics_rigid_bodies extension of a node.
+type gltfPhysicsNode struct {
+	Motion   *gltfMotion   `json:"motion,omitempty"`
+	Collider *gltfCollider `json:"collider,omitempty"`
+	Joint    *gltfJointRef `json:"joint,omitempty"`
+}
+
+type gltfMotion struct {
+	Mass            float64    `json:"mass"`
+	CenterOfMass    [3]float64 `json:"centerOfMass"`
+	InertiaDiagonal [3]float64 `json:"inertiaDiagonal"`
+	LinearVelocity  [3]float64 `json:"linearVelocity"`
+	AngularVelocity [3]float64 `json:"angularVelocity"`
+}
+
+type gltfCollider struct {
+	Geometry        gltfGeometry `json:"geometry"`
+	PhysicsMaterial int          `json:"physicsMaterial"`
+}
+
+// gltfGeometry is either an implicit shape or the mesh of a node.
+type gltfGeometry struct {
+	Shape *int `json:"shape,omitempty"`
+	Node  *int `json:"node,omitempty"`
+}
+
+type gltfJointRef struct {
+	ConnectedNode   int  `json:"connectedNode"`
+	Joint           int  `json:"joint"`
+	EnableCollision bool `json:"enableCollision"`
+}
+
+// gltfExporter builds up a glTF document from a world.
+type gltfExporter struct {
+	doc       gltfDocument
+	buffer    []byte
+	shapes    []gltfShape
+	materials []gltfPhysicsMaterial
+	joints    []gltfJoint
+
+	// root is the node every other top level node is put under.
+	root *gltfNode
+
+	// bodyNodes maps the bodies of the world to their nodes.
+	bodyNodes map[*RigidBody]int
+
+	// boxMeshes and sphereMeshes hold the meshes already made for a size of
+	// box or sphere.
+	boxMeshes    map[m.Vector3]int
+	sphereMeshes map[m.Real]int
+}
+
+// ExportGLTF writes the bodies, colliders, joints, hinges, sliders and welds
+// of the world as a glTF 2.0 file, so that a scene can be looked at in other
+// viewers and brought into content tools. The physics are described with the
+// KHR_physics_rigid_bodies and KHR_implicit_shapes extensions and each
+// collider gets a simple mesh as well so that it can be seen in viewers that
+// don't know them. Planes are written as a large, flat box. Heightfields are
+// written as a mesh that's also used as their collision geometry. The mesh
+// data is embedded in the file.
+//
+// Bodies of infinite mass are written without motion, so they're static. The
+// inertia of a body is written as the diagonal of its inertia tensor, so the
+// products of inertia are lost. A world with the ZUp convention is put under
+// a root node that turns it into the Y-up convention of glTF. Constraints of
+// other types, force generators and the other parts of the world aren't
+// written.
+func (w *World) ExportGLTF(out io.Writer) error {
+	e := &gltfExporter{
+		bodyNodes:    make(map[*RigidBody]int),
+		boxMeshes:    make(map[m.Vector3]int),
+		sphereMeshes: make(map[m.Real]int),
+	}
+	e.doc.Asset = gltfAsset{Version: "2.0", Generator: "cubez"}
+	e.doc.ExtensionsUsed = []string{gltfPhysicsExtension, gltfShapesExtension}
+	e.root = &gltfNode{Name: "world"}
+	e.doc.Nodes = append(e.doc.Nodes, e.root)
+	e.doc.Scenes = []gltfScene{{Nodes: []int{0}}}
+	if w.Convention == ZUp {
+		// a quarter turn about X takes Z-up to Y-up
+		half := math.Sqrt(0.5)
+		e.root.Rotation = &[4]float64{-half, 0.0, 0.0, half}
+	}
+
+	for _, body := range w.Bodies {
+		e.addBody(body)
+	}
+	for _, c := range w.Colliders {
+		if err := e.addCollider(c); err != nil {
+			return err
+		}
+	}
+	for _, j := range w.Joints {
+		def := gltfJoint{Limits: []gltfJointLimit{
+			{LinearAxes: []int{0, 1, 2}, Max: float64(j.Error)},
+		}}
+		if err := e.addJoint("joint", j.Bodies, [2]m.Quat{{1.0}, {1.0}}, j.Positions, def); err != nil {
+			return err
+		}
+	}
+	for _, c := range w.Constraints {
+		switch joint := c.(type) {
+		case *HingeJoint:
+			def := gltfJoint{Limits: []gltfJointLimit{
+				{LinearAxes: []int{0, 1, 2}, Max: float64(joint.Error)},
+				{AngularAxes: []int{1, 2}},
+			}}
+			if joint.Limited {
+				def.Limits = append(def.Limits, gltfJointLimit{AngularAxes: []int{0}, Min: float64(joint.LowerLimit), Max: float64(joint.UpperLimit)})
+			}
+			if joint.MotorEnabled {
+				def.Drives = append(def.Drives, gltfJointDrive{Type: "angular", Mode: "force", Axis: 0,
+					MaxForce: float64(joint.MaxMotorTorque), VelocityTarget: float64(joint.MotorSpeed), Damping: 1.0})
+			}
+			frames := [2]m.Quat{gltfFrame(&joint.Axes[0], &joint.References[0]), gltfFrame(&joint.Axes[1], &joint.References[1])}
+			if err := e.addJoint("hinge", joint.Bodies, frames, joint.Anchors, def); err != nil {
+				return err
+			}
+		case *SliderJoint:
+			def := gltfJoint{Limits: []gltfJointLimit{
+				{LinearAxes: []int{1, 2}, Max: float64(joint.Error)},
+				{AngularAxes: []int{0, 1, 2}},
+			}}
+			if joint.Limited {
+				def.Limits = append(def.Limits, gltfJointLimit{LinearAxes: []int{0}, Min: float64(joint.LowerLimit), Max: float64(joint.UpperLimit)})
+			}
+			if joint.MotorEnabled {
+				def.Drives = append(def.Drives, gltfJointDrive{Type: "linear", Mode: "force", Axis: 0,
+					MaxForce: float64(joint.MaxMotorForce), PositionTarget: float64(joint.MotorTarget), Stiffness: 1.0, Damping: 1.0})
+			}
+			frames := [2]m.Quat{gltfFrame(&joint.Axes[0], &joint.References[0]), gltfFrame(&joint.Axes[1], &joint.References[1])}
+			if err := e.addJoint("slider", joint.Bodies, frames, joint.Anchors, def); err != nil {
+				return err
+			}
+		case *WeldJoint:
+			def := gltfJoint{Limits: []gltfJointLimit{
+				{LinearAxes: []int{0, 1, 2}, Max: float64(joint.Error)},
+				{AngularAxes: []int{0, 1, 2}},
+			}}
+			var frames [2]m.Quat
+			var anchors [2]m.Vector3
+			for i := range frames {
+				axis := joint.Points[1][i]
+				axis.Sub(&joint.Points[0][i])
+				reference := joint.Points[2][i]
+				reference.Sub(&joint.Points[0][i])
+				frames[i] = gltfFrame(&axis, &reference)
+				anchors[i] = joint.Points[0][i]
+			}
+			if err := e.addJoint("weld", joint.Bodies, frames, anchors, def); err != nil {
+				return err
+			}
+		}
+	}
+
+	e.doc.Extensions = map[string]interface{}{
+		gltfShapesExtension: map[string]interface{}{"shapes": e.shapes},
+		gltfPhysicsExtension: map[string]interface{}{
+			"physicsMaterials": e.materials,
+			"physicsJoints":    e.joints,
+		},
+	}
+	if len(e.buffer) > 0 {
+		e.doc.Buffers = []gltfBuffer{{
+			ByteLength: len(e.buffer),
+			URI:        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(e.buffer),
+		}}
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&e.doc)
+}
+
+// addNode adds the node to the document, as a child of the parent if it's
+// not nil or of the root otherwise, and returns its index.
+func (e *gltfExporter) addNode(parent *gltfNode, node *gltfNode) int {
+	index := len(e.doc.Nodes)
+	e.doc.Nodes = append(e.doc.Nodes, node)
+	if parent == nil {
+		parent = e.root
+	}
+	parent.Children = append(parent.Children, index)
+	return index
+}
+
+// addBody adds a node for the body with its motion.
+func (e *gltfExporter) addBody(body *RigidBody) {
+	node := &gltfNode{Name: fmt.Sprintf("body %d", len(e.bodyNodes))}
+	node.Translation = gltfVector(&body.Position)
+	node.Rotation = gltfQuat(&body.Orientation)
+	if body.HasFiniteMass() {
+		inertia := body.InverseInertiaTensor.Invert()
+		motion := &gltfMotion{
+			Mass:            float64(body.GetMass()),
+			CenterOfMass:    *gltfVector(&body.CenterOfMass),
+			InertiaDiagonal: [3]float64{float64(inertia[0]), float64(inertia[4]), float64(inertia[8])},
+			LinearVelocity:  *gltfVector(&body.Velocity),
+			AngularVelocity: *gltfVector(&body.Rotation),
+		}
+		node.Extensions = map[string]interface{}{gltfPhysicsExtension: &gltfPhysicsNode{Motion: motion}}
+	}
+	e.bodyNodes[body] = e.addNode(nil, node)
+}
+
+// addCollider adds a node for the collider with its collision geometry and a
+// mesh to show it.
+func (e *gltfExporter) addCollider(c Collider) error {
+	var parent *gltfNode
+	if body := c.GetBody(); body != nil {
+		index, ok := e.bodyNodes[body]
+		if !ok {
+			return fmt.Errorf("a collider has a body that isn't in the world")
+		}
+		parent = e.doc.Nodes[index]
+	}
+	node := new(gltfNode)
+	collider := &gltfCollider{PhysicsMaterial: e.addMaterial(c.GetMaterial())}
+	setShape := func(shape gltfShape) {
+		index := len(e.shapes)
+		e.shapes = append(e.shapes, shape)
+		collider.Geometry.Shape = &index
+	}
+	setOffset := func(offset *m.Matrix3x4) {
+		position := offset.GetAxis(3)
+		x, y, z := offset.GetAxis(0), offset.GetAxis(1), offset.GetAxis(2)
+		node.Translation = gltfVector(&position)
+		node.Rotation = gltfQuat(quatFromBasis(&x, &y, &z))
+	}
+
+	switch shape := c.(type) {
+	case *CollisionCube:
+		node.Name = "cube"
+		setOffset(&shape.Offset)
+		size := [3]float64{float64(shape.HalfSize[0] * 2.0), float64(shape.HalfSize[1] * 2.0), float64(shape.HalfSize[2] * 2.0)}
+		setShape(gltfShape{Type: "box", Box: &gltfBoxShape{Size: size}})
+		node.Mesh = e.boxMesh(shape.HalfSize)
+	case *CollisionSphere:
+		node.Name = "sphere"
+		setOffset(&shape.Offset)
+		setShape(gltfShape{Type: "sphere", Sphere: &gltfSphereShape{Radius: float64(shape.Radius)}})
+		node.Mesh = e.sphereMesh(shape.Radius)
+	case *CollisionPlane:
+		// a box under the surface, turned so that its Y axis is the normal
+		node.Name = "plane"
+		up := m.Vector3{0.0, 1.0, 0.0}
+		rotation := m.QuatBetweenVectors(&up, &shape.Normal)
+		center := shape.Normal
+		center.Normalize()
+		center.MulWith(shape.Offset - gltfPlaneThickness*0.5)
+		node.Translation = gltfVector(&center)
+		node.Rotation = gltfQuat(&rotation)
+		halfSize := m.Vector3{gltfPlaneSize * 0.5, gltfPlaneThickness * 0.5, gltfPlaneSize * 0.5}
+		setShape(gltfShape{Type: "box", Box: &gltfBoxShape{Size: [3]float64{gltfPlaneSize, gltfPlaneThickness, gltfPlaneSize}}})
+		node.Mesh = e.boxMesh(halfSize)
+	case *CollisionHeightfield:
+		node.Name = "heightfield"
+		node.Translation = gltfVector(&shape.Position)
+		node.Mesh = e.heightfieldMesh(shape)
+		index := len(e.doc.Nodes)
+		collider.Geometry.Node = &index
+	default:
+		return fmt.Errorf("the world has a collider of unknown type %T", c)
+	}
+	node.Extensions = map[string]interface{}{gltfPhysicsExtension: &gltfPhysicsNode{Collider: collider}}
+	e.addNode(parent, node)
+	return nil
+}
+
+// addMaterial returns the index of the physics material matching the
+// material, adding it if it's new.
+func (e *gltfExporter) addMaterial(material *Material) int {
+	converted := gltfPhysicsMaterial{
+		StaticFriction:  float64(material.Friction),
+		DynamicFriction: float64(material.Friction),
+		Restitution:     float64(material.Restitution),
+	}
+	for i, existing := range e.materials {
+		if existing == converted {
+			return i
+		}
+	}
+	e.materials = append(e.materials, converted)
+	return len(e.materials) - 1
+}
+
+// addJoint adds a node on each body at its anchor, turned by its frame, and
+// links them with the joint. An anchor without a body is in World Space.
+func (e *gltfExporter) addJoint(name string, bodies [2]*RigidBody, frames [2]m.Quat, anchors [2]m.Vector3, joint gltfJoint) error {
+	var indexes [2]int
+	var nodes [2]*gltfNode
+	for i, body := range bodies {
+		var parent *gltfNode
+		if body != nil {
+			index, ok := e.bodyNodes[body]
+			if !ok {
+				return fmt.Errorf("a %s is attached to a body that isn't in the world", name)
+			}
+			parent = e.doc.Nodes[index]
+		}
+		nodes[i] = &gltfNode{Name: name, Translation: gltfVector(&anchors[i]), Rotation: gltfQuat(&frames[i])}
+		indexes[i] = e.addNode(parent, nodes[i])
+	}
+	ref := &gltfJointRef{ConnectedNode: indexes[1], Joint: len(e.joints), EnableCollision: true}
+	nodes[0].Extensions = map[string]interface{}{gltfPhysicsExtension: &gltfPhysicsNode{Joint: ref}}
+	e.joints = append(e.joints, joint)
+	return nil
+}
+
+// addMesh adds a mesh made of the triangles given, with normals if there are
+// any, and returns its index.
+func (e *gltfExporter) addMesh(positions []m.Vector3, normals []m.Vector3, indices []uint32) *int {
+	primitive := gltfPrimitive{Attributes: map[string]int{}}
+	primitive.Attributes["POSITION"] = e.addVectors(positions, true)
+	if len(normals) > 0 {
+		primitive.Attributes["NORMAL"] = e.addVectors(normals, false)
+	}
+
+	view := gltfBufferView{ByteOffset: len(e.buffer), ByteLength: len(indices) * 4, Target: gltfElementArrayBuffer}
+	for _, index := range indices {
+		e.appendUint32(index)
+	}
+	e.doc.BufferViews = append(e.doc.BufferViews, view)
+	primitive.Indices = len(e.doc.Accessors)
+	e.doc.Accessors = append(e.doc.Accessors, gltfAccessor{
+		BufferView:    len(e.doc.BufferViews) - 1,
+		ComponentType: gltfUnsignedInt,
+		Count:         len(indices),
+		Type:          "SCALAR",
+	})
+
+	index := len(e.doc.Meshes)
+	e.doc.Meshes = append(e.doc.Meshes, gltfMesh{Primitives: []gltfPrimitive{primitive}})
+	return &index
+}
+
+// addVectors adds the vectors to the buffer as an accessor, with the bounds
+// if withBounds is true, and returns its index.
+func (e *gltfExporter) addVectors(vectors []m.Vector3, withBounds bool) int {
+	view := gltfBufferView{ByteOffset: len(e.buffer), ByteLength: len(vectors) * 12, Target: gltfArrayBuffer}
+	accessor := gltfAccessor{ComponentType: gltfFloat, Count: len(vectors), Type: "VEC3"}
+	if withBounds {
+		accessor.Min = []float32{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+		accessor.Max = []float32{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+	}
+	for _, v := range vectors {
+		for i := 0; i < 3; i++ {
+			value := float32(v[i])
+			e.appendUint32(math.Float32bits(value))
+			if withBounds {
+				if value < accessor.Min[i] {
+					accessor.Min[i] = value
+				}
+				if value > accessor.Max[i] {
+					accessor.Max[i] = value
+				}
+			}
+		}
+	}
+	e.doc.BufferViews = append(e.doc.BufferViews, view)
+	accessor.BufferView = len(e.doc.BufferViews) - 1
+	e.doc.Accessors = append(e.doc.Accessors, accessor)
+	return len(e.doc.Accessors) - 1
+}
+
+// appendUint32 appends the value to the buffer in little-endian order.
+func (e *gltfExporter) appendUint32(value uint32) {
+	var bytes [4]byte
+	binary.LittleEndian.PutUint32(bytes[:], value)
+	e.buffer = append(e.buffer, bytes[:]...)
+}
+
+// boxMesh returns the mesh of a box with the half-size given.
+func (e *gltfExporter) boxMesh(halfSize m.Vector3) *int {
+	if index, ok := e.boxMeshes[halfSize]; ok {
+		return &index
+	}
+	var positions, normals []m.Vector3
+	var indices []uint32
+	for axis := 0; axis < 3; axis++ {
+		for _, sign := range []m.Real{1.0, -1.0} {
+			// the two axes across the face, ordered so that the winding is
+			// counter-clockwise seen from outside
+			u, v := (axis+1)%3, (axis+2)%3
+			if sign < 0.0 {
+				u, v = v, u
+			}
+			var normal m.Vector3
+			normal[axis] = sign
+			first := uint32(len(positions))
+			for _, corner := range [4][2]m.Real{{-1.0, -1.0}, {1.0, -1.0}, {1.0, 1.0}, {-1.0, 1.0}} {
+				var p m.Vector3
+				p[axis] = sign * halfSize[axis]
+				p[u] = corner[0] * halfSize[u]
+				p[v] = corner[1] * halfSize[v]
+				positions = append(positions, p)
+				normals = append(normals, normal)
+			}
+			indices = append(indices, first, first+1, first+2, first, first+2, first+3)
+		}
+	}
+	index := e.addMesh(positions, normals, indices)
+	e.boxMeshes[halfSize] = *index
+	return index
+}
+
+// sphereMesh returns the mesh of a sphere with the radius given.
+func (e *gltfExporter) sphereMesh(radius m.Real) *int {
+	if index, ok := e.sphereMeshes[radius]; ok {
+		return &index
+	}
+	var positions, normals []m.Vector3
+	var indices []uint32
+	for ring := 0; ring <= gltfSphereRings; ring++ {
+		theta := m.Real(ring) * math.Pi / gltfSphereRings
+		for sector := 0; sector <= gltfSphereSectors; sector++ {
+			phi := m.Real(sector) * 2.0 * math.Pi / gltfSphereSectors
+			normal := m.Vector3{
+				m.RealSin(theta) * m.RealCos(phi),
+				m.RealCos(theta),
+				m.RealSin(theta) * m.RealSin(phi),
+			}
+			position := normal
+			position.MulWith(radius)
+			positions = append(positions, position)
+			normals = append(normals, normal)
+		}
+	}
+	for ring := 0; ring < gltfSphereRings; ring++ {
+		for sector := 0; sector < gltfSphereSectors; sector++ {
+			a := uint32(ring*(gltfSphereSectors+1) + sector)
+			b := a + gltfSphereSectors + 1
+			indices = append(indices, a, a+1, b, a+1, b+1, b)
+		}
+	}
+	index := e.addMesh(positions, normals, indices)
+	e.sphereMeshes[radius] = *index
+	return index
+}
+
+// heightfieldMesh returns a mesh of the triangles of the heightfield, in the
+// space of the heightfield, leaving out the holes.
+func (e *gltfExporter) heightfieldMesh(hf *CollisionHeightfield) *int {
+	positions := make([]m.Vector3, 0, hf.Columns*hf.Rows)
+	for row := 0; row < hf.Rows; row++ {
+		for column := 0; column < hf.Columns; column++ {
+			positions = append(positions, m.Vector3{m.Real(column) * hf.CellSize, hf.GetHeight(column, row), m.Real(row) * hf.CellSize})
+		}
+	}
+	var indices []uint32
+	for row := 0; row < hf.Rows-1; row++ {
+		for column := 0; column < hf.Columns-1; column++ {
+			if hf.IsHole(column, row) {
+				continue
+			}
+			a := uint32(row*hf.Columns + column)
+			b := a + uint32(hf.Columns)
+			indices = append(indices, a, b, a+1, a+1, b, b+1)
+		}
+	}
+	return e.addMesh(positions, nil, indices)
+}
+
+// gltfFrame returns the rotation of a joint frame whose X axis is the axis
+// given and whose Y axis is the reference made perpendicular to it.
+func gltfFrame(axis *m.Vector3, reference *m.Vector3) m.Quat {
+	x := *axis
+	x.Normalize()
+	y := *reference
+	y.AddScaled(&x, -x.Dot(&y))
+	if y.SquareMagnitude() < m.Epsilon {
+		y = perpendicularTo(&x)
+	}
+	y.Normalize()
+	z := x.Cross(&y)
+	return *quatFromBasis(&x, &y, &z)
+}
+
+// quatFromBasis returns the rotation that turns the X, Y and Z axes into the
+// orthonormal axes given.
+func quatFromBasis(x, y, z *m.Vector3) *m.Quat {
+	var q m.Quat
+	trace := x[0] + y[1] + z[2]
+	switch {
+	case trace > 0.0:
+		s := m.RealSqrt(trace+1.0) * 2.0
+		q = m.Quat{s * 0.25, (y[2] - z[1]) / s, (z[0] - x[2]) / s, (x[1] - y[0]) / s}
+	case x[0] > y[1] && x[0] > z[2]:
+		s := m.RealSqrt(1.0+x[0]-y[1]-z[2]) * 2.0
+		q = m.Quat{(y[2] - z[1]) / s, s * 0.25, (y[0] + x[1]) / s, (z[0] + x[2]) / s}
+	case y[1] > z[2]:
+		s := m.RealSqrt(1.0+y[1]-x[0]-z[2]) * 2.0
+		q = m.Quat{(z[0] - x[2]) / s, (y[0] + x[1]) / s, s * 0.25, (z[1] + y[2]) / s}
+	default:
+		s := m.RealSqrt(1.0+z[2]-x[0]-y[1]) * 2.0
+		q = m.Quat{(x[1] - y[0]) / s, (z[0] + x[2]) / s, (z[1] + y[2]) / s, s * 0.25}
+	}
+	q.Normalize()
+	return &q
+}
+
+// gltfVector converts the vector to glTF.
+func gltfVector(v *m.Vector3) *[3]float64 {
+	return &[3]float64{float64(v[0]), float64(v[1]), float64(v[2])}
+}
+
+// gltfQuat converts the quaternion to the X, Y, Z, W order of glTF.
+func gltfQuat(q *m.Quat) *[4]float64 {
+	return &[4]float64{float64(q[1]), float64(q[2]), float64(q[3]), float64(q[0])}
+}
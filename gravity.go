@@ -0,0 +1,131 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// AttractorFalloff is the way the pull of an attractor changes with distance.
+type AttractorFalloff uint8
+
+const (
+	// AttractInverseSquare pulls with a strength that drops with the square
+	// of the distance, like the gravity of a planet.
+	AttractInverseSquare AttractorFalloff = iota
+
+	// AttractConstant pulls with the same strength at any distance.
+	AttractConstant
+)
+
+// Attractor is a point that pulls bodies towards it. When a World has any
+// attractors, their combined pull replaces the Acceleration of its bodies
+// as their gravity, so games on spherical planets work out of the box.
+type Attractor struct {
+	// Position is the World Space point that bodies are pulled towards.
+	Position m.Vector3
+
+	// Strength is the acceleration at a distance of one for AttractInverseSquare,
+	// which is the gravitational constant times the mass of the attractor, or
+	// at any distance for AttractConstant.
+	Strength m.Real
+
+	// Falloff is how the pull changes with distance.
+	// Defaults to AttractInverseSquare.
+	Falloff AttractorFalloff
+
+	// MinDistance is the distance below which the pull stops growing, so that
+	// bodies passing through the center aren't flung away. For a planet this
+	// is its radius.
+	// Defaults to 0.0.
+	MinDistance m.Real
+
+	// Range is the distance beyond which there is no pull. A value of zero or
+	// less means the pull reaches everywhere.
+	// Defaults to 0.0.
+	Range m.Real
+}
+
+// NewAttractor creates a new Attractor at the position with the strength given.
+func NewAttractor(position m.Vector3, strength m.Real) *Attractor {
+	a := new(Attractor)
+	a.Position = position
+	a.Strength = strength
+	return a
+}
+
+// NewPlanetAttractor creates a new inverse square Attractor for a planet of
+// the radius given that has the gravity given at its surface.
+func NewPlanetAttractor(center m.Vector3, radius m.Real, surfaceGravity m.Real) *Attractor {
+	a := NewAttractor(center, surfaceGravity*radius*radius)
+	a.MinDistance = radius
+	return a
+}
+
+// AccelerationAt returns the acceleration of the pull of the attractor at
+// the World Space point.
+func (a *Attractor) AccelerationAt(point *m.Vector3) m.Vector3 {
+	direction := a.Position
+	direction.Sub(point)
+	distance := direction.Magnitude()
+	if distance <= m.Epsilon || (a.Range > 0.0 && distance > a.Range) {
+		return m.Vector3{}
+	}
+	direction.MulWith(1.0 / distance)
+
+	magnitude := a.Strength
+	if a.Falloff == AttractInverseSquare {
+		if distance < a.MinDistance {
+			distance = a.MinDistance
+		}
+		magnitude /= distance * distance
+	}
+	direction.MulWith(magnitude)
+	return direction
+}
+
+// AddAttractor adds the attractor to the world so that it pulls on the bodies.
+func (w *World) AddAttractor(a *Attractor) {
+	for _, existing := range w.Attractors {
+		if existing == a {
+			return
+		}
+	}
+	w.Attractors = append(w.Attractors, a)
+}
+
+// RemoveAttractor removes the attractor from the world. Once the last one is
+// gone the bodies fall with their Acceleration again.
+func (w *World) RemoveAttractor(a *Attractor) {
+	for i, existing := range w.Attractors {
+		if existing == a {
+			w.Attractors = append(w.Attractors[:i], w.Attractors[i+1:]...)
+			break
+		}
+	}
+	if len(w.Attractors) == 0 {
+		for _, body := range w.Bodies {
+			body.hasFieldGravity = false
+		}
+	}
+}
+
+// GravityAt returns the combined pull of the attractors of the world at the
+// World Space point.
+func (w *World) GravityAt(point *m.Vector3) m.Vector3 {
+	var gravity m.Vector3
+	for _, a := range w.Attractors {
+		pull := a.AccelerationAt(point)
+		gravity.Add(&pull)
+	}
+	return gravity
+}
+
+// updateFieldGravity sets the gravity of the body to the pull of the
+// attractors at its center of mass.
+func (w *World) updateFieldGravity(body *RigidBody) {
+	com := body.GetCenterOfMassWorld()
+	body.fieldGravity = w.GravityAt(&com)
+	body.hasFieldGravity = true
+}
@@ -0,0 +1,151 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// GravityZoneMode is the way a GravityZone changes the gravity of the bodies
+// inside it.
+type GravityZoneMode uint8
+
+const (
+	// GravityZoneReplace swaps the gravity of the bodies for the Gravity of
+	// the zone.
+	GravityZoneReplace GravityZoneMode = iota
+
+	// GravityZoneScale keeps the gravity the bodies would have outside the
+	// zone and only scales it.
+	GravityZoneScale
+)
+
+// GravityZone is a region of space with its own gravity, for zero-G rooms and
+// puzzles where gravity turns upside down. A body is in the zone while its
+// center of mass is inside the Region. Zones added to a World are checked
+// against the awake bodies at the start of each step, using the broadphase to
+// find the bodies near each one. A body in several zones takes the Gravity of
+// the last replacing zone it's in and the Scale of all of them. Bodies with a
+// GravityOverride ignore zones.
+type GravityZone struct {
+	// Region is the space the zone covers.
+	Region Volume
+
+	// Mode is the way the zone changes the gravity of the bodies inside it.
+	// Defaults to GravityZoneReplace.
+	Mode GravityZoneMode
+
+	// Gravity is the acceleration, in World Space, that the bodies inside
+	// the zone fall with in GravityZoneReplace mode.
+	Gravity m.Vector3
+
+	// Scale scales the gravity of the bodies inside the zone; a negative
+	// value turns it around.
+	// Defaults to 1.0.
+	Scale m.Real
+}
+
+// NewGravityZone creates a new GravityZone over the region that replaces the
+// gravity of the bodies inside it with the gravity given. A zero gravity
+// makes a zero-G zone.
+func NewGravityZone(region Volume, gravity m.Vector3) *GravityZone {
+	gz := new(GravityZone)
+	gz.Region = region
+	gz.Mode = GravityZoneReplace
+	gz.Gravity = gravity
+	gz.Scale = 1.0
+	return gz
+}
+
+// NewGravityScaleZone creates a new GravityZone over the region that scales
+// the gravity of the bodies inside it by the scale given.
+func NewGravityScaleZone(region Volume, scale m.Real) *GravityZone {
+	gz := new(GravityZone)
+	gz.Region = region
+	gz.Mode = GravityZoneScale
+	gz.Scale = scale
+	return gz
+}
+
+// Bounds returns the Bounds of the region of the zone.
+func (gz *GravityZone) Bounds() Bounds {
+	return regionBounds(gz.Region)
+}
+
+// Contains returns true if the body's center of mass is in the zone.
+func (gz *GravityZone) Contains(body *RigidBody) bool {
+	com := body.GetCenterOfMassWorld()
+	return gz.Region.Contains(&com)
+}
+
+// AddGravityZone adds the gravity zone to the world so that it's applied
+// each step.
+func (w *World) AddGravityZone(gz *GravityZone) {
+	for _, existing := range w.GravityZones {
+		if existing == gz {
+			return
+		}
+	}
+	w.GravityZones = append(w.GravityZones, gz)
+}
+
+// RemoveGravityZone removes the gravity zone from the world. Once the last
+// one is gone the bodies fall with their own gravity again.
+func (w *World) RemoveGravityZone(gz *GravityZone) {
+	for i, existing := range w.GravityZones {
+		if existing == gz {
+			w.GravityZones = append(w.GravityZones[:i], w.GravityZones[i+1:]...)
+			break
+		}
+	}
+	if len(w.GravityZones) == 0 {
+		for _, body := range w.Bodies {
+			body.inGravityZone = false
+		}
+	}
+}
+
+// findGravityZoneBodies returns the gravity zones that each moving body is
+// in, in the order they were added, using the broadphase to find the
+// candidates if there is one.
+func (w *World) findGravityZoneBodies() map[*RigidBody][]*GravityZone {
+	if len(w.GravityZones) == 0 {
+		return nil
+	}
+
+	inside := make(map[*RigidBody][]*GravityZone)
+	for _, gz := range w.GravityZones {
+		bounds := gz.Bounds()
+		for _, c := range w.QueryBounds(&bounds) {
+			body := c.GetBody()
+			if body == nil || !body.HasFiniteMass() {
+				continue
+			}
+
+			// a body with several colliders is only counted once by each zone
+			zones := inside[body]
+			if len(zones) > 0 && zones[len(zones)-1] == gz {
+				continue
+			}
+			if gz.Contains(body) {
+				inside[body] = append(zones, gz)
+			}
+		}
+	}
+	return inside
+}
+
+// updateZoneGravity sets the gravity of the body from the zones it's in.
+func (body *RigidBody) updateZoneGravity(zones []*GravityZone) {
+	body.inGravityZone = len(zones) > 0
+	body.hasZoneGravity = false
+	body.zoneGravityScale = 1.0
+	for _, gz := range zones {
+		if gz.Mode == GravityZoneReplace {
+			body.zoneGravity = gz.Gravity
+			body.hasZoneGravity = true
+		}
+		body.zoneGravityScale *= gz.Scale
+	}
+}
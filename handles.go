@@ -0,0 +1,84 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"fmt"
+)
+
+// BodyID is a generational handle to a RigidBody in a World. Once the body is
+// removed, the handle stops resolving even if its slot is reused for another
+// body, so stale references are detected instead of silently acting on the
+// wrong body. The zero value is never a valid handle.
+type BodyID struct {
+	index      uint32
+	generation uint32
+}
+
+// String returns a printable form of the handle for diagnostics.
+func (id BodyID) String() string {
+	return fmt.Sprintf("body:%d#%d", id.index, id.generation)
+}
+
+// ColliderID is a generational handle to a Collider in a World. It works the
+// same way as BodyID.
+type ColliderID struct {
+	index      uint32
+	generation uint32
+}
+
+// String returns a printable form of the handle for diagnostics.
+func (id ColliderID) String() string {
+	return fmt.Sprintf("collider:%d#%d", id.index, id.generation)
+}
+
+// handleSlot is an entry in a handleTable.
+type handleSlot struct {
+	// item is the object in the slot or nil if the slot is free.
+	item interface{}
+
+	// generation is incremented every time the slot is freed.
+	generation uint32
+}
+
+// handleTable hands out generational indices for objects and reuses the
+// slots of objects that have been released.
+type handleTable struct {
+	slots []handleSlot
+	free  []uint32
+}
+
+// insert stores the item in a free slot and returns its index and generation.
+func (t *handleTable) insert(item interface{}) (uint32, uint32) {
+	var index uint32
+	if len(t.free) > 0 {
+		index = t.free[len(t.free)-1]
+		t.free = t.free[:len(t.free)-1]
+	} else {
+		// generations start at one so that the zero handle is never valid
+		index = uint32(len(t.slots))
+		t.slots = append(t.slots, handleSlot{generation: 1})
+	}
+	t.slots[index].item = item
+	return index, t.slots[index].generation
+}
+
+// get returns the item for the index and generation if the handle is still valid.
+func (t *handleTable) get(index uint32, generation uint32) (interface{}, bool) {
+	if index >= uint32(len(t.slots)) {
+		return nil, false
+	}
+	slot := &t.slots[index]
+	if slot.generation != generation || slot.item == nil {
+		return nil, false
+	}
+	return slot.item, true
+}
+
+// release frees the slot at the index, invalidating all handles to it.
+func (t *handleTable) release(index uint32) {
+	t.slots[index].item = nil
+	t.slots[index].generation++
+	t.free = append(t.free, index)
+}
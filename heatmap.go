@@ -0,0 +1,129 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// HeatmapCell identifies a cell of the uniform grid used by ContactHeatmap.
+type HeatmapCell [3]int
+
+// ContactHeatmap accumulates the number of contacts generated in each cell
+// of a uniform grid over many steps so that the areas of a level where the
+// physics cost concentrates can be found and exported. Set it as the Heatmap
+// of a World to have it fed the contacts of each step.
+type ContactHeatmap struct {
+	// CellSize is the length of each side of a grid cell in World Space.
+	CellSize m.Real
+
+	// Steps is the number of times Accumulate has been called.
+	Steps int
+
+	// counts holds the number of contacts found in each cell.
+	counts map[HeatmapCell]int
+}
+
+// NewContactHeatmap creates a new ContactHeatmap using cells of the given size.
+func NewContactHeatmap(cellSize m.Real) *ContactHeatmap {
+	hm := new(ContactHeatmap)
+	hm.CellSize = cellSize
+	hm.counts = make(map[HeatmapCell]int)
+	return hm
+}
+
+// CellAt returns the grid cell that contains the point.
+func (hm *ContactHeatmap) CellAt(point *m.Vector3) HeatmapCell {
+	var cell HeatmapCell
+	for i := 0; i < 3; i++ {
+		cell[i] = int(math.Floor(float64(point[i] / hm.CellSize)))
+	}
+	return cell
+}
+
+// Accumulate adds the contacts generated in a step to the heatmap.
+func (hm *ContactHeatmap) Accumulate(contacts []*Contact) {
+	for _, c := range contacts {
+		hm.counts[hm.CellAt(&c.ContactPoint)]++
+	}
+	hm.Steps++
+}
+
+// drawDebug draws the box of each cell that has had contacts, labelled with
+// the average number of contacts in it per step.
+func (hm *ContactHeatmap) drawDebug(d DebugDrawer) {
+	steps := m.Real(hm.Steps)
+	if steps < 1.0 {
+		steps = 1.0
+	}
+	for _, cell := range hm.Cells() {
+		var bounds Bounds
+		for i := 0; i < 3; i++ {
+			bounds.Min[i] = m.Real(cell[i]) * hm.CellSize
+			bounds.Max[i] = bounds.Min[i] + hm.CellSize
+		}
+		drawDebugBox(d, &bounds, DebugHeatmap)
+		center := bounds.Center()
+		d.DrawText(&center, fmt.Sprintf("%.1f", m.Real(hm.counts[cell])/steps), DebugHeatmap)
+	}
+}
+
+// Count returns the number of contacts accumulated for a cell.
+func (hm *ContactHeatmap) Count(cell HeatmapCell) int {
+	return hm.counts[cell]
+}
+
+// Cells returns all of the cells that have had contacts, in a stable order.
+func (hm *ContactHeatmap) Cells() []HeatmapCell {
+	cells := make([]HeatmapCell, 0, len(hm.counts))
+	for cell := range hm.counts {
+		cells = append(cells, cell)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		a, b := cells[i], cells[j]
+		for k := 0; k < 3; k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	return cells
+}
+
+// Reset clears all of the accumulated data.
+func (hm *ContactHeatmap) Reset() {
+	hm.counts = make(map[HeatmapCell]int)
+	hm.Steps = 0
+}
+
+// WriteCSV exports the heatmap as CSV with one row per cell, giving the World Space
+// center of the cell, the total number of contacts and the average per step.
+func (hm *ContactHeatmap) WriteCSV(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "x,y,z,contacts,per_step"); err != nil {
+		return err
+	}
+
+	steps := m.Real(hm.Steps)
+	if steps < 1.0 {
+		steps = 1.0
+	}
+
+	for _, cell := range hm.Cells() {
+		count := hm.counts[cell]
+		x := (m.Real(cell[0]) + 0.5) * hm.CellSize
+		y := (m.Real(cell[1]) + 0.5) * hm.CellSize
+		z := (m.Real(cell[2]) + 0.5) * hm.CellSize
+		_, err := fmt.Fprintf(w, "%g,%g,%g,%d,%g\n", x, y, z, count, m.Real(count)/steps)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,631 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// CollisionHeightfield is a static grid of height samples that can be used
+// for terrain. The samples are laid out along the X and Z axes, starting at
+// Position, with the heights along the Y axis. Each cell of the grid is split
+// into two triangles along the diagonal from its lowest corner.
+// Like CollisionPlane, it doesn't have an associated rigid body.
+type CollisionHeightfield struct {
+	// Position is the World Space position of the first sample of the grid.
+	Position m.Vector3
+
+	// Columns is the number of samples along the X axis.
+	Columns int
+
+	// Rows is the number of samples along the Z axis.
+	Rows int
+
+	// CellSize is the distance between neighboring samples.
+	CellSize m.Real
+
+	// Heights holds the Rows*Columns height samples, row by row.
+	Heights []m.Real
+
+	// SmoothNormals makes contacts use normals interpolated from the vertex
+	// normals of the terrain instead of the normal of the triangle that was
+	// hit, so that spheres rolling over low-poly terrain don't hop at each
+	// triangle edge.
+	// Defaults to false.
+	SmoothNormals bool
+
+	// Material holds the surface properties of the heightfield. If nil, the
+	// DefaultMaterial is used.
+	Material *Material
+
+	// Materials is the palette of materials that can be painted onto cells
+	// with SetCellMaterial.
+	Materials []*Material
+
+	// SurfaceVelocity is the velocity the surface of the heightfield moves at
+	// across itself without the heightfield moving. It's given in World
+	// Space.
+	// Defaults to no motion.
+	SurfaceVelocity m.Vector3
+
+	// cellMaterials holds an index into Materials plus one for each cell, where
+	// zero means the cell uses Material. It is nil until a cell is painted.
+	cellMaterials []uint8
+
+	// holes holds whether each cell is a hole with no collision. It is nil
+	// until a hole is made.
+	holes []bool
+
+	// transform is the translation of the heightfield to Position.
+	// NOTE: this is calculated by calling CalculateDerivedData().
+	transform m.Matrix3x4
+
+	// derivedCount counts the times the derived data has been calculated or
+	// the heights have been changed.
+	derivedCount uint64
+}
+
+/*
+==================================================================================================
+  COLLISION HEIGHTFIELD
+==================================================================================================
+*/
+
+// NewCollisionHeightfield creates a new CollisionHeightfield with the number of
+// samples and spacing specified. If heights is nil, a flat heightfield is
+// created; otherwise it must hold columns*rows samples, row by row.
+func NewCollisionHeightfield(columns int, rows int, cellSize m.Real, heights []m.Real) *CollisionHeightfield {
+	hf := new(CollisionHeightfield)
+	hf.Columns = columns
+	hf.Rows = rows
+	hf.CellSize = cellSize
+	if heights == nil {
+		heights = make([]m.Real, columns*rows)
+	}
+	hf.Heights = heights
+	hf.CalculateDerivedData()
+	return hf
+}
+
+// NewCollisionHeightfieldFromImage creates a new CollisionHeightfield from a
+// grayscale heightmap, such as a PNG made with a terrain tool, with a sample
+// for each pixel. Columns run along the X axis of the image and rows down
+// its Y axis. The horizontalScale is the CellSize and the verticalScale is
+// the height of a white pixel, with black at zero. Color images are
+// converted to gray. Decoding a PNG needs the image/png package imported.
+func NewCollisionHeightfieldFromImage(img image.Image, horizontalScale m.Real, verticalScale m.Real) *CollisionHeightfield {
+	bounds := img.Bounds()
+	columns, rows := bounds.Dx(), bounds.Dy()
+	heights := make([]m.Real, columns*rows)
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			gray := color.Gray16Model.Convert(img.At(bounds.Min.X+column, bounds.Min.Y+row)).(color.Gray16)
+			heights[row*columns+column] = m.Real(gray.Y) / 0xffff * verticalScale
+		}
+	}
+	return NewCollisionHeightfield(columns, rows, horizontalScale, heights)
+}
+
+// Clone makes a new copy of the CollisionHeightfield object.
+func (hf *CollisionHeightfield) Clone() Collider {
+	heights := make([]m.Real, len(hf.Heights))
+	copy(heights, hf.Heights)
+	newHF := NewCollisionHeightfield(hf.Columns, hf.Rows, hf.CellSize, heights)
+	newHF.Position = hf.Position
+	newHF.SmoothNormals = hf.SmoothNormals
+	newHF.Material = hf.Material
+	newHF.SurfaceVelocity = hf.SurfaceVelocity
+	newHF.Materials = append([]*Material(nil), hf.Materials...)
+	newHF.cellMaterials = append([]uint8(nil), hf.cellMaterials...)
+	newHF.holes = append([]bool(nil), hf.holes...)
+	newHF.CalculateDerivedData()
+	return newHF
+}
+
+// CalculateDerivedData updates the transform of the heightfield from its
+// Position. It should also be called after changing Heights directly.
+func (hf *CollisionHeightfield) CalculateDerivedData() {
+	hf.transform.SetIdentity()
+	hf.transform[9], hf.transform[10], hf.transform[11] = hf.Position[0], hf.Position[1], hf.Position[2]
+	hf.derivedCount++
+}
+
+// refreshDerivedData calculates the derived data if the Position has changed
+// since it was last calculated.
+func (hf *CollisionHeightfield) refreshDerivedData() {
+	if hf.derivedCount == 0 || hf.transform.GetPosition() != hf.Position {
+		hf.CalculateDerivedData()
+	}
+}
+
+// derivedVersion returns a number that changes whenever the derived data is
+// calculated or a height is set.
+func (hf *CollisionHeightfield) derivedVersion() uint64 {
+	return hf.derivedCount
+}
+
+// GetTransform returns the transform of the heightfield, which is only a translation.
+func (hf *CollisionHeightfield) GetTransform() m.Matrix3x4 {
+	return hf.transform
+}
+
+// GetBody returns nil since the heightfield doesn't have a rigid body associated with it.
+func (hf *CollisionHeightfield) GetBody() *RigidBody {
+	return nil
+}
+
+// GetMaterial returns the surface material of the heightfield.
+func (hf *CollisionHeightfield) GetMaterial() *Material {
+	return materialOrDefault(hf.Material)
+}
+
+// SetDensity doesn't do anything for heightfields since they don't have a rigid body.
+func (hf *CollisionHeightfield) SetDensity(density m.Real) {
+}
+
+// GetHeight returns the height sample at the column and row given.
+func (hf *CollisionHeightfield) GetHeight(column int, row int) m.Real {
+	return hf.Heights[row*hf.Columns+column]
+}
+
+// SetHeight sets the height sample at the column and row given.
+func (hf *CollisionHeightfield) SetHeight(column int, row int, height m.Real) {
+	hf.Heights[row*hf.Columns+column] = height
+	hf.derivedCount++
+}
+
+// SetHole marks the cell at the column and row given as a hole, so nothing
+// collides with it, or fills it back in. Holes allow caves and tunnels to be
+// built under the terrain out of other colliders.
+func (hf *CollisionHeightfield) SetHole(column int, row int, hole bool) {
+	if hf.holes == nil {
+		if !hole {
+			return
+		}
+		hf.holes = make([]bool, (hf.Columns-1)*(hf.Rows-1))
+	}
+	hf.holes[row*(hf.Columns-1)+column] = hole
+}
+
+// IsHole returns true if the cell at the column and row given is a hole.
+func (hf *CollisionHeightfield) IsHole(column int, row int) bool {
+	if hf.holes == nil {
+		return false
+	}
+	return hf.holes[row*(hf.Columns-1)+column]
+}
+
+// SetCellMaterial paints the cell at the column and row given with the material
+// at the index given in Materials. An index less than zero resets the cell to
+// use Material.
+func (hf *CollisionHeightfield) SetCellMaterial(column int, row int, index int) {
+	if hf.cellMaterials == nil {
+		if index < 0 {
+			return
+		}
+		hf.cellMaterials = make([]uint8, (hf.Columns-1)*(hf.Rows-1))
+	}
+	hf.cellMaterials[row*(hf.Columns-1)+column] = uint8(index + 1)
+}
+
+// GetCellMaterial returns the material of the cell at the column and row given.
+func (hf *CollisionHeightfield) GetCellMaterial(column int, row int) *Material {
+	if hf.cellMaterials != nil {
+		index := int(hf.cellMaterials[row*(hf.Columns-1)+column]) - 1
+		if index >= 0 && index < len(hf.Materials) {
+			return materialOrDefault(hf.Materials[index])
+		}
+	}
+	return hf.GetMaterial()
+}
+
+// HeightAt returns the World Space height of the surface above the World Space
+// X and Z coordinates given. It returns false if the point is outside of the grid
+// or over a hole.
+func (hf *CollisionHeightfield) HeightAt(x m.Real, z m.Real) (bool, m.Real) {
+	local := m.Vector3{x - hf.Position[0], 0.0, z - hf.Position[2]}
+	column, row, ok := hf.cellAt(&local)
+	if !ok || hf.IsHole(column, row) {
+		return false, 0.0
+	}
+	tri := hf.triangleUnder(column, row, &local)
+	u, v, w := barycentricXZ(&local, &tri)
+	return true, hf.Position[1] + u*tri[0][1] + v*tri[1][1] + w*tri[2][1]
+}
+
+// NormalAt returns the surface normal above the World Space X and Z coordinates
+// given, which is interpolated from the vertex normals if SmoothNormals is set.
+// It returns false if the point is outside of the grid or over a hole.
+func (hf *CollisionHeightfield) NormalAt(x m.Real, z m.Real) (bool, m.Vector3) {
+	local := m.Vector3{x - hf.Position[0], 0.0, z - hf.Position[2]}
+	column, row, ok := hf.cellAt(&local)
+	if !ok || hf.IsHole(column, row) {
+		return false, m.Vector3{0.0, 1.0, 0.0}
+	}
+	tri, corners := hf.triangleCornersUnder(column, row, &local)
+	u, v, w := barycentricXZ(&local, &tri)
+	return true, hf.surfaceNormal(&tri, &corners, u, v, w)
+}
+
+// CheckAgainstHalfSpace doesn't return collisions against a plane since both are static.
+func (hf *CollisionHeightfield) CheckAgainstHalfSpace(plane *CollisionPlane, existingContacts []*Contact) (bool, []*Contact) {
+	return false, existingContacts
+}
+
+// CheckAgainstSphere checks for collisions against a sphere.
+func (hf *CollisionHeightfield) CheckAgainstSphere(sphere *CollisionSphere, existingContacts []*Contact) (bool, []*Contact) {
+	// use the sphere's implementation of the check
+	return sphere.CheckAgainstHeightfield(hf, existingContacts)
+}
+
+// CheckAgainstCube checks for collisions against a cube.
+func (hf *CollisionHeightfield) CheckAgainstCube(cube *CollisionCube, existingContacts []*Contact) (bool, []*Contact) {
+	// use the cube's implementation of the check
+	return cube.CheckAgainstHeightfield(hf, existingContacts)
+}
+
+// CheckAgainstHeightfield doesn't return collisions against another heightfield since both are static.
+func (hf *CollisionHeightfield) CheckAgainstHeightfield(other *CollisionHeightfield, existingContacts []*Contact) (bool, []*Contact) {
+	return false, existingContacts
+}
+
+// CheckAgainstHeightfield checks the sphere against the triangles of a heightfield
+// and generates a single contact with the closest one.
+func (s *CollisionSphere) CheckAgainstHeightfield(hf *CollisionHeightfield, existingContacts []*Contact) (bool, []*Contact) {
+	return s.checkHeightfield(hf, existingContacts, nil)
+}
+
+// checkHeightfield is CheckAgainstHeightfield, taking the contacts from the pool.
+func (s *CollisionSphere) checkHeightfield(hf *CollisionHeightfield, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
+	center := s.transform.GetAxis(3)
+	local := center
+	local.Sub(&hf.Position)
+
+	// work out the range of cells under the sphere
+	minColumn, maxColumn := hf.cellRange(local[0]-s.Radius, local[0]+s.Radius, hf.Columns)
+	minRow, maxRow := hf.cellRange(local[2]-s.Radius, local[2]+s.Radius, hf.Rows)
+
+	// find the closest point on any of the triangles in range
+	found := false
+	var closest m.Vector3
+	var closestTri [3]m.Vector3
+	var closestCorners [3][2]int
+	var closestCell [2]int
+	var closestU, closestV, closestW m.Real
+	closestDistance := s.Radius * s.Radius
+	for row := minRow; row <= maxRow; row++ {
+		for column := minColumn; column <= maxColumn; column++ {
+			if hf.IsHole(column, row) {
+				continue
+			}
+			for half := 0; half < 2; half++ {
+				tri, corners := hf.cellTriangle(column, row, half)
+				point, u, v, w := closestPointOnTriangle(&local, &tri[0], &tri[1], &tri[2])
+				toCenter := local
+				toCenter.Sub(&point)
+
+				// ignore triangles that the sphere is behind
+				faceNormal := triangleNormal(&tri)
+				if toCenter.Dot(&faceNormal) < -s.Radius {
+					continue
+				}
+
+				distance := toCenter.SquareMagnitude()
+				if distance < closestDistance {
+					found = true
+					closestDistance = distance
+					closest = point
+					closestTri = tri
+					closestCorners = corners
+					closestCell = [2]int{column, row}
+					closestU, closestV, closestW = u, v, w
+				}
+			}
+		}
+	}
+	if !found {
+		return false, existingContacts
+	}
+
+	normal := hf.surfaceNormal(&closestTri, &closestCorners, closestU, closestV, closestW)
+	toCenter := local
+	toCenter.Sub(&closest)
+	penetration := s.Radius - toCenter.Dot(&normal)
+	if penetration <= 0.0 {
+		return false, existingContacts
+	}
+
+	c := pool.get()
+	c.ContactPoint = closest
+	c.ContactPoint.Add(&hf.Position)
+	c.ContactNormal = normal
+	c.Penetration = penetration
+	c.Bodies[0] = s.Body
+	c.Bodies[1] = nil
+
+	c.SetMaterials(s.GetMaterial(), hf.GetCellMaterial(closestCell[0], closestCell[1]))
+
+	return true, append(existingContacts, c)
+}
+
+// CheckAgainstHeightfield checks the vertices of the cube against the surface
+// of a heightfield, generating a contact for each one that is below it.
+func (cube *CollisionCube) CheckAgainstHeightfield(hf *CollisionHeightfield, existingContacts []*Contact) (bool, []*Contact) {
+	return cube.checkHeightfield(hf, existingContacts, nil)
+}
+
+// checkHeightfield is CheckAgainstHeightfield, taking the contacts from the pool.
+func (cube *CollisionCube) checkHeightfield(hf *CollisionHeightfield, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
+	var mults [8]m.Vector3
+	mults[0] = m.Vector3{1.0, 1.0, 1.0}
+	mults[1] = m.Vector3{-1.0, 1.0, 1.0}
+	mults[2] = m.Vector3{1.0, -1.0, 1.0}
+	mults[3] = m.Vector3{-1.0, -1.0, 1.0}
+	mults[4] = m.Vector3{1.0, 1.0, -1.0}
+	mults[5] = m.Vector3{-1.0, 1.0, -1.0}
+	mults[6] = m.Vector3{1.0, -1.0, -1.0}
+	mults[7] = m.Vector3{-1.0, -1.0, -1.0}
+
+	contactDetected := false
+	contacts := existingContacts
+	for _, v := range mults {
+		// calculate the position of the vertex relative to the heightfield
+		v.ComponentProduct(&cube.HalfSize)
+		vertexPos := cube.transform.MulVector3(&v)
+		local := vertexPos
+		local.Sub(&hf.Position)
+
+		column, row, ok := hf.cellAt(&local)
+		if !ok || hf.IsHole(column, row) {
+			continue
+		}
+		tri, corners := hf.triangleCornersUnder(column, row, &local)
+		u, bv, w := barycentricXZ(&local, &tri)
+		height := u*tri[0][1] + bv*tri[1][1] + w*tri[2][1]
+		if local[1] > height {
+			continue
+		}
+
+		// we have contact
+		normal := hf.surfaceNormal(&tri, &corners, u, bv, w)
+		c := pool.get()
+		c.ContactPoint = vertexPos
+		c.ContactNormal = normal
+		c.Penetration = (height - local[1]) * normal[1]
+		c.Bodies[0] = cube.Body
+		c.Bodies[1] = nil
+
+		c.SetMaterials(cube.GetMaterial(), hf.GetCellMaterial(column, row))
+
+		contacts = append(contacts, c)
+		contactDetected = true
+	}
+
+	return contactDetected, contacts
+}
+
+// CheckAgainstHeightfield doesn't return collisions against a heightfield since both are static.
+func (p *CollisionPlane) CheckAgainstHeightfield(hf *CollisionHeightfield, existingContacts []*Contact) (bool, []*Contact) {
+	return false, existingContacts
+}
+
+// cellAt returns the cell that contains the X and Z coordinates of the point
+// relative to the heightfield, or false if it's outside of the grid.
+func (hf *CollisionHeightfield) cellAt(local *m.Vector3) (int, int, bool) {
+	column := int(math.Floor(float64(local[0] / hf.CellSize)))
+	row := int(math.Floor(float64(local[2] / hf.CellSize)))
+	if column < 0 || row < 0 || column > hf.Columns-2 || row > hf.Rows-2 {
+		// points on the far edges belong to the last cell
+		if column == hf.Columns-1 && local[0] <= m.Real(column)*hf.CellSize {
+			column--
+		}
+		if row == hf.Rows-1 && local[2] <= m.Real(row)*hf.CellSize {
+			row--
+		}
+		if column < 0 || row < 0 || column > hf.Columns-2 || row > hf.Rows-2 {
+			return 0, 0, false
+		}
+	}
+	return column, row, true
+}
+
+// cellRange returns the range of cells that overlap the coordinates given
+// along an axis with the number of samples given, clamped to the grid.
+func (hf *CollisionHeightfield) cellRange(low m.Real, high m.Real, samples int) (int, int) {
+	first := int(math.Floor(float64(low / hf.CellSize)))
+	last := int(math.Floor(float64(high / hf.CellSize)))
+	if first < 0 {
+		first = 0
+	}
+	if last > samples-2 {
+		last = samples - 2
+	}
+	return first, last
+}
+
+// cellTriangle returns one of the two triangles of a cell, relative to the
+// heightfield, along with the column and row of each of its corners.
+func (hf *CollisionHeightfield) cellTriangle(column int, row int, half int) ([3]m.Vector3, [3][2]int) {
+	var corners [3][2]int
+	if half == 0 {
+		corners = [3][2]int{{column, row}, {column, row + 1}, {column + 1, row + 1}}
+	} else {
+		corners = [3][2]int{{column, row}, {column + 1, row + 1}, {column + 1, row}}
+	}
+
+	var tri [3]m.Vector3
+	for i, corner := range corners {
+		tri[i] = m.Vector3{
+			m.Real(corner[0]) * hf.CellSize,
+			hf.GetHeight(corner[0], corner[1]),
+			m.Real(corner[1]) * hf.CellSize,
+		}
+	}
+	return tri, corners
+}
+
+// triangleUnder returns the triangle of the cell that lies under the point.
+func (hf *CollisionHeightfield) triangleUnder(column int, row int, local *m.Vector3) [3]m.Vector3 {
+	tri, _ := hf.triangleCornersUnder(column, row, local)
+	return tri
+}
+
+// triangleCornersUnder returns the triangle of the cell that lies under the
+// point along with the column and row of each of its corners.
+func (hf *CollisionHeightfield) triangleCornersUnder(column int, row int, local *m.Vector3) ([3]m.Vector3, [3][2]int) {
+	fx := local[0]/hf.CellSize - m.Real(column)
+	fz := local[2]/hf.CellSize - m.Real(row)
+	if fz >= fx {
+		return hf.cellTriangle(column, row, 0)
+	}
+	return hf.cellTriangle(column, row, 1)
+}
+
+// vertexNormal returns the normal of the surface at a sample, estimated from
+// the heights of the neighboring samples.
+func (hf *CollisionHeightfield) vertexNormal(column int, row int) m.Vector3 {
+	left, right := column-1, column+1
+	if left < 0 {
+		left = 0
+	}
+	if right > hf.Columns-1 {
+		right = hf.Columns - 1
+	}
+	back, front := row-1, row+1
+	if back < 0 {
+		back = 0
+	}
+	if front > hf.Rows-1 {
+		front = hf.Rows - 1
+	}
+
+	dx := (hf.GetHeight(right, row) - hf.GetHeight(left, row)) / (m.Real(right-left) * hf.CellSize)
+	dz := (hf.GetHeight(column, front) - hf.GetHeight(column, back)) / (m.Real(front-back) * hf.CellSize)
+	normal := m.Vector3{-dx, 1.0, -dz}
+	normal.Normalize()
+	return normal
+}
+
+// surfaceNormal returns the normal of the surface at the point of the triangle
+// with the barycentric coordinates given, which is either the face normal or
+// the interpolated vertex normal depending on SmoothNormals.
+func (hf *CollisionHeightfield) surfaceNormal(tri *[3]m.Vector3, corners *[3][2]int, u, v, w m.Real) m.Vector3 {
+	if !hf.SmoothNormals {
+		return triangleNormal(tri)
+	}
+
+	var normal m.Vector3
+	weights := [3]m.Real{u, v, w}
+	for i, corner := range corners {
+		vn := hf.vertexNormal(corner[0], corner[1])
+		normal.AddScaled(&vn, weights[i])
+	}
+	normal.Normalize()
+	return normal
+}
+
+// triangleNormal returns the upward facing normal of a heightfield triangle.
+func triangleNormal(tri *[3]m.Vector3) m.Vector3 {
+	edge1 := tri[1]
+	edge1.Sub(&tri[0])
+	edge2 := tri[2]
+	edge2.Sub(&tri[0])
+	normal := edge1.Cross(&edge2)
+	if normal[1] < 0.0 {
+		normal.MulWith(-1.0)
+	}
+	normal.Normalize()
+	return normal
+}
+
+// barycentricXZ returns the barycentric coordinates of the point projected
+// straight down onto the triangle, using only the X and Z coordinates.
+func barycentricXZ(p *m.Vector3, tri *[3]m.Vector3) (m.Real, m.Real, m.Real) {
+	a, b, c := tri[0], tri[1], tri[2]
+	denom := (b[2]-c[2])*(a[0]-c[0]) + (c[0]-b[0])*(a[2]-c[2])
+	if m.RealAbs(denom) < m.Epsilon {
+		return 1.0, 0.0, 0.0
+	}
+	u := ((b[2]-c[2])*(p[0]-c[0]) + (c[0]-b[0])*(p[2]-c[2])) / denom
+	v := ((c[2]-a[2])*(p[0]-c[0]) + (a[0]-c[0])*(p[2]-c[2])) / denom
+	return u, v, 1.0 - u - v
+}
+
+// closestPointOnTriangle returns the point on the triangle abc that is closest
+// to p along with its barycentric coordinates.
+func closestPointOnTriangle(p, a, b, c *m.Vector3) (m.Vector3, m.Real, m.Real, m.Real) {
+	ab := *b
+	ab.Sub(a)
+	ac := *c
+	ac.Sub(a)
+	ap := *p
+	ap.Sub(a)
+
+	// check if p is in the vertex region outside a
+	d1 := ab.Dot(&ap)
+	d2 := ac.Dot(&ap)
+	if d1 <= 0.0 && d2 <= 0.0 {
+		return *a, 1.0, 0.0, 0.0
+	}
+
+	// check if p is in the vertex region outside b
+	bp := *p
+	bp.Sub(b)
+	d3 := ab.Dot(&bp)
+	d4 := ac.Dot(&bp)
+	if d3 >= 0.0 && d4 <= d3 {
+		return *b, 0.0, 1.0, 0.0
+	}
+
+	// check if p is in the edge region of ab
+	vc := d1*d4 - d3*d2
+	if vc <= 0.0 && d1 >= 0.0 && d3 <= 0.0 {
+		v := d1 / (d1 - d3)
+		point := *a
+		point.AddScaled(&ab, v)
+		return point, 1.0 - v, v, 0.0
+	}
+
+	// check if p is in the vertex region outside c
+	cp := *p
+	cp.Sub(c)
+	d5 := ab.Dot(&cp)
+	d6 := ac.Dot(&cp)
+	if d6 >= 0.0 && d5 <= d6 {
+		return *c, 0.0, 0.0, 1.0
+	}
+
+	// check if p is in the edge region of ac
+	vb := d5*d2 - d1*d6
+	if vb <= 0.0 && d2 >= 0.0 && d6 <= 0.0 {
+		w := d2 / (d2 - d6)
+		point := *a
+		point.AddScaled(&ac, w)
+		return point, 1.0 - w, 0.0, w
+	}
+
+	// check if p is in the edge region of bc
+	va := d3*d6 - d5*d4
+	if va <= 0.0 && (d4-d3) >= 0.0 && (d5-d6) >= 0.0 {
+		w := (d4 - d3) / ((d4 - d3) + (d5 - d6))
+		bc := *c
+		bc.Sub(b)
+		point := *b
+		point.AddScaled(&bc, w)
+		return point, 0.0, 1.0 - w, w
+	}
+
+	// p is inside the face region
+	denom := 1.0 / (va + vb + vc)
+	v := vb * denom
+	w := vc * denom
+	point := *a
+	point.AddScaled(&ab, v)
+	point.AddScaled(&ac, w)
+	return point, 1.0 - v - w, v, w
+}
@@ -0,0 +1,245 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// HingeJoint links two bodies so that they can only rotate about a shared
+// axis, like a door on its hinges, a pinball flipper or a wheel on an axle.
+// Like Joint, it's enforced with contacts: two points spaced along the axis
+// are held together, which keeps the axes lined up, and when the angle goes
+// past a limit a contact at the end of a lever pushes it back.
+type HingeJoint struct {
+	ConstraintSoftness
+
+	// Bodies holds the two bodies that are joined. The second body can be nil,
+	// in which case the first is hinged to the world.
+	Bodies [2]*RigidBody
+
+	// Anchors holds the point on the axis in the Body Space of each body. If
+	// the second body is nil, its anchor is in World Space.
+	Anchors [2]m.Vector3
+
+	// Axes holds the direction of the hinge axis in the Body Space of each body.
+	Axes [2]m.Vector3
+
+	// References holds a direction perpendicular to the axis in the Body Space
+	// of each body. The angle of the hinge is the rotation from the first
+	// reference to the second about the axis.
+	References [2]m.Vector3
+
+	// Length is the distance between the two points along the axis that are
+	// held together, and the length of the lever used to enforce the limits.
+	// Defaults to 1.0.
+	Length m.Real
+
+	// Error is the distance the points are allowed to drift apart before the
+	// joint generates a contact.
+	Error m.Real
+
+	// Limited enables the LowerLimit and UpperLimit.
+	// Defaults to false.
+	Limited bool
+
+	// LowerLimit is the smallest angle of the hinge in radians.
+	LowerLimit m.Real
+
+	// UpperLimit is the largest angle of the hinge in radians.
+	UpperLimit m.Real
+
+	// LimitRestitution is how much the hinge bounces back off of its limits.
+	// Defaults to 0.0.
+	LimitRestitution m.Real
+
+	// MotorEnabled turns on the motor, which drives the hinge towards the
+	// MotorSpeed with no more than the MaxMotorTorque.
+	// Defaults to false.
+	MotorEnabled bool
+
+	// MotorSpeed is the rate the motor turns the hinge at in radians per second.
+	MotorSpeed m.Real
+
+	// MaxMotorTorque is the largest torque the motor can apply.
+	MaxMotorTorque m.Real
+
+	// Stall, if not nil, is updated with the torque of the motor each step.
+	// Defaults to nil.
+	Stall *StallDetector
+
+	// motorTorque holds the torque the motor applied in the last step.
+	motorTorque m.Real
+}
+
+// NewHingeJoint creates a new HingeJoint between the bodies that rotates about
+// the axis through the anchor, both given in World Space. The angle of the
+// hinge starts at zero. The derived data of the bodies must be up to date.
+func NewHingeJoint(one *RigidBody, two *RigidBody, anchor m.Vector3, axis m.Vector3, err m.Real) *HingeJoint {
+	h := new(HingeJoint)
+	h.Bodies[0] = one
+	h.Bodies[1] = two
+	h.Length = 1.0
+	h.Error = err
+
+	axis.Normalize()
+	reference := perpendicularTo(&axis)
+	for i, body := range h.Bodies {
+		h.Anchors[i] = worldPointToBody(body, &anchor)
+		h.Axes[i] = worldDirectionToBody(body, &axis)
+		h.References[i] = worldDirectionToBody(body, &reference)
+	}
+	return h
+}
+
+// SetLimits limits the angle of the hinge to the range given, in radians.
+func (h *HingeJoint) SetLimits(lower m.Real, upper m.Real) {
+	h.Limited = true
+	h.LowerLimit = lower
+	h.UpperLimit = upper
+}
+
+// SetMotor turns on the motor to drive the hinge at the speed given, in radians
+// per second, with no more than the torque given.
+func (h *HingeJoint) SetMotor(speed m.Real, maxTorque m.Real) {
+	h.MotorEnabled = true
+	h.MotorSpeed = speed
+	h.MaxMotorTorque = maxTorque
+}
+
+// GetMotorTorque returns the torque the motor applied in the last step.
+func (h *HingeJoint) GetMotorTorque() m.Real {
+	return h.motorTorque
+}
+
+// GetBodies returns the two bodies of the hinge.
+func (h *HingeJoint) GetBodies() [2]*RigidBody {
+	return h.Bodies
+}
+
+// saveState copies the state of the motor.
+func (h *HingeJoint) saveState(state *constraintState) {
+	state.motor[0] = h.motorTorque
+	state.saveStall(h.Stall)
+}
+
+// loadState sets the state of the motor.
+func (h *HingeJoint) loadState(state *constraintState) {
+	h.motorTorque = state.motor[0]
+	state.loadStall(h.Stall)
+}
+
+// State returns the angle of the hinge and how fast it's turning.
+func (h *HingeJoint) State() JointState {
+	return HingeState(h.Bodies[0], h.Bodies[1], &h.Axes[0], &h.References[0], &h.References[1])
+}
+
+// AddContacts generates the contacts for any part of the hinge that has been
+// violated and appends them to the existing contacts.
+func (h *HingeJoint) AddContacts(existingContacts []*Contact) (bool, []*Contact) {
+	count := len(existingContacts)
+	halfLength := h.Length * 0.5
+	if halfLength <= 0.0 {
+		halfLength = 0.5
+	}
+
+	// hold a point either side of the anchor together to keep the axes in line
+	for _, side := range [2]m.Real{-halfLength, halfLength} {
+		var points [2]m.Vector3
+		for i, body := range h.Bodies {
+			local := h.Anchors[i]
+			local.AddScaled(&h.Axes[i], side/h.Axes[i].Magnitude())
+			points[i] = bodyPointToWorld(body, &local)
+		}
+		_, existingContacts = addPointJointContact(existingContacts, &h.Bodies, &points[0], &points[1], h.Error)
+	}
+
+	if h.Limited {
+		existingContacts = h.addLimitContact(existingContacts, halfLength*2.0)
+	}
+	return len(existingContacts) > count, existingContacts
+}
+
+// addLimitContact generates a contact at the end of a lever along the second
+// reference that turns the hinge back within its limits.
+func (h *HingeJoint) addLimitContact(existingContacts []*Contact, lever m.Real) []*Contact {
+	angle := h.State().Position
+	var violation m.Real
+	switch {
+	case angle > h.UpperLimit:
+		violation = angle - h.UpperLimit
+	case angle < h.LowerLimit:
+		violation = angle - h.LowerLimit
+	default:
+		return existingContacts
+	}
+
+	axis := bodyDirectionToWorld(h.Bodies[0], &h.Axes[0])
+	axis.Normalize()
+	arm := bodyDirectionToWorld(h.Bodies[1], &h.References[1])
+	arm.AddScaled(&axis, -arm.Dot(&axis))
+	arm.Normalize()
+
+	// the first body is pushed along the normal and the second against it,
+	// which turns the second back towards the first
+	normal := axis.Cross(&arm)
+	if violation < 0.0 {
+		normal.MulWith(-1.0)
+		violation = -violation
+	}
+
+	c := NewContact()
+	c.Bodies = h.Bodies
+	c.ContactNormal = normal
+	c.ContactPoint = bodyPointToWorld(h.Bodies[0], &h.Anchors[0])
+	c.ContactPoint.AddScaled(&arm, lever)
+	c.Penetration = violation * lever
+	c.Friction = 0.0
+	c.Restitution = h.LimitRestitution
+	return append(existingContacts, c)
+}
+
+// applyImpulses applies the angular impulse that brings the speed of the hinge
+// to the MotorSpeed, limited by what the MaxMotorTorque can do in the step.
+func (h *HingeJoint) applyImpulses(duration m.Real, feedback *JointFeedback) {
+	h.motorTorque = 0.0
+	if !h.MotorEnabled || duration <= 0.0 {
+		return
+	}
+	defer func() {
+		if h.Stall != nil {
+			h.Stall.Update(h.motorTorque, h.MaxMotorTorque)
+		}
+	}()
+
+	axis := bodyDirectionToWorld(h.Bodies[0], &h.Axes[0])
+	axis.Normalize()
+
+	// the change in the speed of the hinge from a unit impulse about the axis
+	var response m.Real
+	for _, body := range h.Bodies {
+		if body != nil && body.HasFiniteMass() {
+			turn := body.inverseInertiaWorld().MulVector3(&axis)
+			response += turn.Dot(&axis)
+		}
+	}
+	if response <= 0.0 {
+		return
+	}
+
+	impulse := (h.MotorSpeed - h.State().Velocity) / response
+	maxImpulse := h.MaxMotorTorque * duration
+	if impulse > maxImpulse {
+		impulse = maxImpulse
+	} else if impulse < -maxImpulse {
+		impulse = -maxImpulse
+	}
+	if impulse == 0.0 {
+		return
+	}
+	h.motorTorque = impulse / duration
+
+	// the second body turns forwards about the axis and the first backwards
+	applyAngularImpulses(&h.Bodies, &axis, impulse, feedback)
+}
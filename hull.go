@@ -0,0 +1,482 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"fmt"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// ConvexHull is a closed, convex triangle mesh. The triangles are wound
+// counter-clockwise when seen from outside, so a hull can be given to
+// ComputeMeshMassProperties for the mass of a body or to
+// NewCollisionTriangleMesh for static geometry.
+type ConvexHull struct {
+	// Vertices holds the corners of the hull.
+	Vertices []m.Vector3
+
+	// Indices holds the indices into Vertices of the corners of each
+	// triangle, every three indices forming a triangle.
+	Indices []int
+}
+
+// hullFace is a triangle of a hull being built along with the plane it lies
+// in and the points that are still outside of it.
+type hullFace struct {
+	corners [3]int
+	normal  m.Vector3
+	offset  m.Real
+	outside []int
+	removed bool
+}
+
+// distance returns how far the point is in front of the face.
+func (f *hullFace) distance(p *m.Vector3) m.Real {
+	return f.normal.Dot(p) - f.offset
+}
+
+// ComputeConvexHull returns the smallest convex hull around the points using
+// the quickhull algorithm. It returns an error if the points all lie in a
+// plane, since they don't enclose a volume.
+func ComputeConvexHull(points []m.Vector3) (*ConvexHull, error) {
+	if len(points) < 4 {
+		return nil, fmt.Errorf("a convex hull needs at least four points; got %d", len(points))
+	}
+
+	// points closer to a face than the tolerance count as lying on it
+	var bounds Bounds
+	for i := range points {
+		if i == 0 {
+			bounds = Bounds{Min: points[0], Max: points[0]}
+		} else {
+			bounds.MergePoint(&points[i])
+		}
+	}
+	extent := bounds.HalfSize()
+	tolerance := 100.0 * m.Epsilon * (extent[0] + extent[1] + extent[2])
+
+	first, err := hullTetrahedron(points, tolerance)
+	if err != nil {
+		return nil, err
+	}
+
+	// the centroid of the first tetrahedron stays inside the hull, so faces
+	// are turned to face away from it
+	var inside m.Vector3
+	for _, i := range first {
+		inside.AddScaled(&points[i], 0.25)
+	}
+	var faces []*hullFace
+	newFace := func(a, b, c int) *hullFace {
+		f := &hullFace{corners: [3]int{a, b, c}}
+		ab := points[b]
+		ab.Sub(&points[a])
+		ac := points[c]
+		ac.Sub(&points[a])
+		f.normal = ab.Cross(&ac)
+		f.normal.Normalize()
+		f.offset = f.normal.Dot(&points[a])
+		if f.distance(&inside) > 0.0 {
+			f.corners[1], f.corners[2] = c, b
+			f.normal.MulWith(-1.0)
+			f.offset = -f.offset
+		}
+		faces = append(faces, f)
+		return f
+	}
+	newFace(first[0], first[1], first[2])
+	newFace(first[0], first[1], first[3])
+	newFace(first[0], first[2], first[3])
+	newFace(first[1], first[2], first[3])
+
+	// give each point outside of the tetrahedron to a face it's in front of
+	assign := func(candidates []int, to []*hullFace) {
+		for _, i := range candidates {
+			for _, f := range to {
+				if f.distance(&points[i]) > tolerance {
+					f.outside = append(f.outside, i)
+					break
+				}
+			}
+		}
+	}
+	all := make([]int, 0, len(points))
+	for i := range points {
+		if i != first[0] && i != first[1] && i != first[2] && i != first[3] {
+			all = append(all, i)
+		}
+	}
+	assign(all, faces)
+
+	for {
+		// grow the hull out to the point furthest in front of a face
+		var face *hullFace
+		for _, f := range faces {
+			if !f.removed && len(f.outside) > 0 {
+				face = f
+				break
+			}
+		}
+		if face == nil {
+			break
+		}
+		apex, apexDistance := -1, -m.MaxValue
+		for _, i := range face.outside {
+			if d := face.distance(&points[i]); d > apexDistance {
+				apex, apexDistance = i, d
+			}
+		}
+
+		// the faces the apex can see are replaced by a cone of faces from
+		// the edges around them, the horizon, to the apex
+		var seen []*hullFace
+		visible := map[[2]int]bool{}
+		var orphans []int
+		for _, f := range faces {
+			if f.removed || f.distance(&points[apex]) <= tolerance {
+				continue
+			}
+			f.removed = true
+			seen = append(seen, f)
+			orphans = append(orphans, f.outside...)
+			f.outside = nil
+			for i := 0; i < 3; i++ {
+				visible[[2]int{f.corners[i], f.corners[(i+1)%3]}] = true
+			}
+		}
+		var cone []*hullFace
+		for _, f := range seen {
+			for i := 0; i < 3; i++ {
+				a, b := f.corners[i], f.corners[(i+1)%3]
+				if !visible[[2]int{b, a}] {
+					cone = append(cone, newFace(a, b, apex))
+				}
+			}
+		}
+		remaining := orphans[:0]
+		for _, i := range orphans {
+			if i != apex {
+				remaining = append(remaining, i)
+			}
+		}
+		assign(remaining, cone)
+
+		live := faces[:0]
+		for _, f := range faces {
+			if !f.removed {
+				live = append(live, f)
+			}
+		}
+		faces = live
+	}
+
+	// only keep the points that are corners of the hull
+	hull := new(ConvexHull)
+	remap := map[int]int{}
+	for _, f := range faces {
+		for _, corner := range f.corners {
+			index, ok := remap[corner]
+			if !ok {
+				index = len(hull.Vertices)
+				remap[corner] = index
+				hull.Vertices = append(hull.Vertices, points[corner])
+			}
+			hull.Indices = append(hull.Indices, index)
+		}
+	}
+	return hull, nil
+}
+
+// hullTetrahedron returns four points that make a tetrahedron with a volume
+// for quickhull to start from, picking points far apart so that it's large.
+func hullTetrahedron(points []m.Vector3, tolerance m.Real) ([4]int, error) {
+	var first [4]int
+
+	// the two points furthest apart along an axis
+	best := -m.MaxValue
+	for axis := 0; axis < 3; axis++ {
+		low, high := 0, 0
+		for i := range points {
+			if points[i][axis] < points[low][axis] {
+				low = i
+			}
+			if points[i][axis] > points[high][axis] {
+				high = i
+			}
+		}
+		if spread := points[high][axis] - points[low][axis]; spread > best {
+			best = spread
+			first[0], first[1] = low, high
+		}
+	}
+	if best <= tolerance {
+		return first, fmt.Errorf("the points are all in the same place")
+	}
+
+	// the point furthest from the line between them
+	line := points[first[1]]
+	line.Sub(&points[first[0]])
+	line.Normalize()
+	best = -m.MaxValue
+	for i := range points {
+		toPoint := points[i]
+		toPoint.Sub(&points[first[0]])
+		across := toPoint.Cross(&line)
+		if d := across.Magnitude(); d > best {
+			best = d
+			first[2] = i
+		}
+	}
+	if best <= tolerance {
+		return first, fmt.Errorf("the points all lie on a line")
+	}
+
+	// the point furthest from the plane of all three
+	ab := points[first[1]]
+	ab.Sub(&points[first[0]])
+	ac := points[first[2]]
+	ac.Sub(&points[first[0]])
+	normal := ab.Cross(&ac)
+	normal.Normalize()
+	best = -m.MaxValue
+	for i := range points {
+		toPoint := points[i]
+		toPoint.Sub(&points[first[0]])
+		if d := m.RealAbs(toPoint.Dot(&normal)); d > best {
+			best = d
+			first[3] = i
+		}
+	}
+	if best <= tolerance {
+		return first, fmt.Errorf("the points all lie in a plane")
+	}
+	return first, nil
+}
+
+// convexPart is a piece of a mesh being split up by ComputeConvexDecomposition.
+type convexPart struct {
+	// surface holds the triangles of the mesh in the part, and caps the
+	// triangles that close it off where it was cut, which only count towards
+	// its volume since they can fan out past its edges.
+	surface [][3]m.Vector3
+	caps    [][3]m.Vector3
+
+	hull       *ConvexHull
+	hullVolume m.Real
+	volume     m.Real
+}
+
+// ComputeConvexDecomposition splits a closed triangle mesh, wound like those
+// given to ComputeMeshMassProperties, into convex hulls that together cover
+// it, for building concave bodies out of convex pieces. Parts are cut in two
+// until the empty space in each hull is less than maxConcavity of its volume,
+// so 0.05 gives hulls that are at least 95% solid, or there are maxHulls of
+// them. The decomposition is approximate: parts are only cut along planes
+// lined up with the axes.
+func ComputeConvexDecomposition(vertices []m.Vector3, indices []int, maxConcavity m.Real, maxHulls int) ([]*ConvexHull, error) {
+	if len(indices) == 0 || len(indices)%3 != 0 {
+		return nil, fmt.Errorf("the number of indices must be a non-zero multiple of three; got %d", len(indices))
+	}
+	whole := new(convexPart)
+	for i := 0; i < len(indices); i += 3 {
+		var tri [3]m.Vector3
+		for j := 0; j < 3; j++ {
+			if indices[i+j] < 0 || indices[i+j] >= len(vertices) {
+				return nil, fmt.Errorf("index %d at position %d is out of range", indices[i+j], i+j)
+			}
+			tri[j] = vertices[indices[i+j]]
+		}
+		whole.surface = append(whole.surface, tri)
+	}
+	if err := whole.measure(); err != nil {
+		return nil, err
+	}
+	if whole.volume <= m.Epsilon {
+		return nil, fmt.Errorf("the mesh does not enclose a positive volume; check that it is closed and wound counter-clockwise")
+	}
+
+	parts := []*convexPart{whole}
+	for len(parts) < maxHulls {
+		// cut the part with the most empty space in its hull
+		worst := -1
+		for i, part := range parts {
+			if part.concavity() > maxConcavity && (worst < 0 || part.hullVolume-part.volume > parts[worst].hullVolume-parts[worst].volume) {
+				worst = i
+			}
+		}
+		if worst < 0 {
+			break
+		}
+		below, above := parts[worst].split()
+		if below == nil {
+			break
+		}
+		parts[worst] = below
+		parts = append(parts, above)
+	}
+
+	hulls := make([]*ConvexHull, len(parts))
+	for i, part := range parts {
+		hulls[i] = part.hull
+	}
+	return hulls, nil
+}
+
+// concavity returns the fraction of the hull of the part that's empty space.
+func (part *convexPart) concavity() m.Real {
+	if part.hullVolume <= 0.0 {
+		return 0.0
+	}
+	return (part.hullVolume - part.volume) / part.hullVolume
+}
+
+// measure works out the hull of the part and the volumes of both.
+func (part *convexPart) measure() error {
+	var points []m.Vector3
+	for _, tri := range part.surface {
+		points = append(points, tri[0], tri[1], tri[2])
+	}
+	hull, err := ComputeConvexHull(points)
+	if err != nil {
+		return err
+	}
+	part.hull = hull
+
+	// the volumes are summed from tetrahedrons to a point inside, which
+	// keeps the rounding small
+	center := points[0]
+	part.hullVolume = 0.0
+	for i := 0; i < len(hull.Indices); i += 3 {
+		part.hullVolume += tetrahedronSignedVolume(&center, &hull.Vertices[hull.Indices[i]], &hull.Vertices[hull.Indices[i+1]], &hull.Vertices[hull.Indices[i+2]])
+	}
+	part.volume = 0.0
+	for _, triangles := range [2][][3]m.Vector3{part.surface, part.caps} {
+		for i := range triangles {
+			part.volume += tetrahedronSignedVolume(&center, &triangles[i][0], &triangles[i][1], &triangles[i][2])
+		}
+	}
+	return nil
+}
+
+// split cuts the part in two along the plane, out of a few lined up with the
+// axes, that leaves the least empty space in the hulls of the halves. It
+// returns nils if the part can't be cut.
+func (part *convexPart) split() (*convexPart, *convexPart) {
+	var bestBelow, bestAbove *convexPart
+	bestVolume := m.MaxValue
+	for axis := 0; axis < 3; axis++ {
+		low, high := m.MaxValue, -m.MaxValue
+		for _, v := range part.hull.Vertices {
+			if v[axis] < low {
+				low = v[axis]
+			}
+			if v[axis] > high {
+				high = v[axis]
+			}
+		}
+		tolerance := 100.0 * m.Epsilon * (high - low)
+		for _, fraction := range [3]m.Real{0.25, 0.5, 0.75} {
+			var normal m.Vector3
+			normal[axis] = 1.0
+			offset := low + (high-low)*fraction
+			below := part.clip(&normal, offset, tolerance)
+			normal[axis] = -1.0
+			above := part.clip(&normal, -offset, tolerance)
+			if below.measure() != nil || above.measure() != nil {
+				continue
+			}
+			if volume := below.hullVolume + above.hullVolume; volume < bestVolume {
+				bestVolume = volume
+				bestBelow, bestAbove = below, above
+			}
+		}
+	}
+	return bestBelow, bestAbove
+}
+
+// clip returns the piece of the part behind the plane with the normal and
+// offset given, closed off with caps along the plane. Points within the
+// tolerance of the plane count as lying on it.
+func (part *convexPart) clip(normal *m.Vector3, offset m.Real, tolerance m.Real) *convexPart {
+	// the caps fan out from a point on the plane
+	apex := part.hull.Vertices[0]
+	apex.AddScaled(normal, offset-normal.Dot(&apex))
+
+	side := func(p *m.Vector3) int {
+		d := normal.Dot(p) - offset
+		if d > tolerance {
+			return 1
+		} else if d < -tolerance {
+			return -1
+		}
+		return 0
+	}
+
+	var cuts [][3]m.Vector3
+	clipTriangles := func(triangles [][3]m.Vector3) [][3]m.Vector3 {
+		var kept [][3]m.Vector3
+		for _, tri := range triangles {
+			var polygon [4]m.Vector3
+			var onPlane [4]bool
+			corners, behind := 0, false
+			for i := 0; i < 3; i++ {
+				current, next := tri[i], tri[(i+1)%3]
+				sc, sn := side(&current), side(&next)
+				if sc <= 0 {
+					polygon[corners] = current
+					onPlane[corners] = sc == 0
+					corners++
+					behind = behind || sc < 0
+				}
+				if sc*sn < 0 {
+					dc := normal.Dot(&current) - offset
+					dn := normal.Dot(&next) - offset
+					crossing := next
+					crossing.Sub(&current)
+					crossing.MulWith(dc / (dc - dn))
+					crossing.Add(&current)
+					polygon[corners] = crossing
+					onPlane[corners] = true
+					corners++
+				}
+			}
+
+			// triangles in the plane, or only touching it, are left to the
+			// caps and the triangles next to them
+			if corners < 3 || !behind {
+				continue
+			}
+			for i := 1; i+1 < corners; i++ {
+				kept = append(kept, [3]m.Vector3{polygon[0], polygon[i], polygon[i+1]})
+			}
+
+			// the caps run along the edges in the plane the other way round
+			// to the triangle, so the piece stays closed
+			for i := 0; i < corners; i++ {
+				next := (i + 1) % corners
+				if onPlane[i] && onPlane[next] {
+					cuts = append(cuts, [3]m.Vector3{apex, polygon[next], polygon[i]})
+				}
+			}
+		}
+		return kept
+	}
+
+	piece := new(convexPart)
+	piece.surface = clipTriangles(part.surface)
+	piece.caps = append(clipTriangles(part.caps), cuts...)
+	return piece
+}
+
+// tetrahedronSignedVolume returns the volume of the tetrahedron from the
+// apex to the triangle, which is negative if the triangle faces the apex.
+func tetrahedronSignedVolume(apex, a, b, c *m.Vector3) m.Real {
+	toA, toB, toC := *a, *b, *c
+	toA.Sub(apex)
+	toB.Sub(apex)
+	toC.Sub(apex)
+	cross := toB.Cross(&toC)
+	return toA.Dot(&cross) / 6.0
+}
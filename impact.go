@@ -0,0 +1,143 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// Impact describes two bodies hitting each other in a step, gathered from all
+// of the contacts between them so that sounds and particles can be played
+// once for the hit rather than once for each contact. It's sent with
+// EventImpact.
+type Impact struct {
+	// Bodies holds the bodies that hit each other; the second body is nil
+	// for a hit against static geometry.
+	Bodies [2]*RigidBody
+
+	// Materials holds the surface materials at the contact with the largest
+	// impulse, in the same order as Bodies.
+	Materials [2]*Material
+
+	// Point is the position in World Space of the contact with the largest
+	// impulse, and Normal its direction pointing from the second body to
+	// the first.
+	Point  m.Vector3
+	Normal m.Vector3
+
+	// Impulse is the largest impulse along the normal that any one of the
+	// contacts between the bodies applied.
+	Impulse m.Real
+
+	// RelativeVelocity is the velocity of the first body relative to the
+	// second at the contact with the largest impulse, before the contacts
+	// were resolved.
+	RelativeVelocity m.Vector3
+
+	// Speed is the fastest the bodies were closing at any one of their
+	// contacts before the contacts were resolved.
+	Speed m.Real
+
+	// Contacts is the number of contacts between the bodies in the step.
+	Contacts int
+}
+
+// impactSample holds the velocity of the first body of a contact relative to
+// the second, taken before the contacts are resolved, since resolving them
+// can swap the bodies around.
+type impactSample struct {
+	first    *RigidBody
+	velocity m.Vector3
+}
+
+// sampleImpacts keeps the velocities at the contacts before they're resolved
+// if anything is waiting for the impacts of the step.
+func (w *World) sampleImpacts(contacts []*Contact) {
+	w.impactSamples = w.impactSamples[:0]
+	if !w.Events.HasSubscribers(EventImpact) {
+		return
+	}
+	for _, c := range contacts {
+		s := impactSample{first: c.Bodies[0]}
+		for i, body := range c.Bodies {
+			if body == nil {
+				continue
+			}
+			point := c.ContactPoint
+			point.Sub(&c.offsets[i])
+			velocity := body.GetVelocityAtPoint(&point)
+			if i == 0 {
+				s.velocity.Add(&velocity)
+			} else {
+				s.velocity.Sub(&velocity)
+			}
+		}
+		w.impactSamples = append(w.impactSamples, s)
+	}
+}
+
+// dispatchImpacts gathers the resolved contacts into an Impact for each pair
+// of bodies, in the order the pairs first appear, and sends an event for the
+// ones with an impulse above MinImpactImpulse. The contacts have to be the
+// ones given to sampleImpacts.
+func (w *World) dispatchImpacts(contacts []*Contact) {
+	if len(w.impactSamples) != len(contacts) || len(contacts) == 0 {
+		return
+	}
+	if w.impactIndex == nil {
+		w.impactIndex = make(map[[2]*RigidBody]int)
+	}
+	for pair := range w.impactIndex {
+		delete(w.impactIndex, pair)
+	}
+	w.impacts = w.impacts[:0]
+
+	for i, c := range contacts {
+		// line the contact up with the order of the bodies in the impact
+		bodies := c.Bodies
+		normal := c.ContactNormal
+		velocity := w.impactSamples[i].velocity
+		if w.impactSamples[i].first != bodies[0] {
+			velocity.MulWith(-1.0)
+		}
+		materials := c.Materials
+		index, ok := w.impactIndex[bodies]
+		if !ok {
+			if index, ok = w.impactIndex[[2]*RigidBody{bodies[1], bodies[0]}]; ok {
+				bodies[0], bodies[1] = bodies[1], bodies[0]
+				materials[0], materials[1] = materials[1], materials[0]
+				normal.MulWith(-1.0)
+				velocity.MulWith(-1.0)
+			}
+		}
+		if !ok {
+			index = len(w.impacts)
+			w.impactIndex[bodies] = index
+			w.impacts = append(w.impacts, Impact{Bodies: bodies, Impulse: -1.0})
+		}
+
+		impact := &w.impacts[index]
+		impact.Contacts++
+		if speed := -velocity.Dot(&normal); speed > impact.Speed {
+			impact.Speed = speed
+		}
+		impulse := c.impulse.Dot(&c.ContactNormal)
+		if impulse > impact.Impulse {
+			impact.Impulse = impulse
+			impact.Materials = materials
+			impact.Point = c.ContactPoint
+			impact.Normal = normal
+			impact.RelativeVelocity = velocity
+		}
+	}
+
+	for i := range w.impacts {
+		impact := &w.impacts[i]
+		if impact.Impulse <= w.MinImpactImpulse {
+			continue
+		}
+		e := Event{Type: EventImpact, Bodies: impact.Bodies, Impact: impact}
+		w.Events.Dispatch(&e)
+	}
+}
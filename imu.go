@@ -0,0 +1,68 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"math/rand"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// IMUReading holds the values that an inertial measurement unit attached to
+// the center of mass of a RigidBody would report. All values are in Body Space.
+type IMUReading struct {
+	// AngularVelocity is the rate of rotation around each of the body's axes,
+	// as a gyroscope would measure it.
+	AngularVelocity m.Vector3
+
+	// LinearAcceleration is the proper acceleration of the body, as an
+	// accelerometer would measure it. This includes the reaction to gravity, so
+	// a body resting on the ground reads the opposite of its gravity.
+	LinearAcceleration m.Vector3
+}
+
+// IMUNoise describes the gaussian noise to add to an IMUReading.
+type IMUNoise struct {
+	// Source is the random number source used to generate the noise.
+	Source *rand.Rand
+
+	// GyroStdDev is the standard deviation of the noise added to each
+	// component of the angular velocity.
+	GyroStdDev m.Real
+
+	// AccelStdDev is the standard deviation of the noise added to each
+	// component of the linear acceleration.
+	AccelStdDev m.Real
+}
+
+// ReadIMU returns the readout of a virtual IMU attached to the RigidBody. The
+// acceleration is measured from the change in velocity over the last step, so
+// it includes the effect of contacts resolved after the integration. If noise
+// is non-nil, gaussian noise is added to the values.
+func (body *RigidBody) ReadIMU(noise *IMUNoise) IMUReading {
+	var reading IMUReading
+	reading.AngularVelocity = body.transform.TransformInverseDirection(&body.Rotation)
+
+	var accel m.Vector3
+	if body.lastDuration > 0.0 {
+		accel = body.Velocity
+		accel.Sub(&body.prevVelocity)
+		accel.MulWith(1.0 / body.lastDuration)
+	}
+
+	// an accelerometer doesn't feel the constant acceleration, like gravity,
+	// that pulls on everything equally.
+	gravity := body.GetGravity()
+	accel.Sub(&gravity)
+	reading.LinearAcceleration = body.transform.TransformInverseDirection(&accel)
+
+	if noise != nil && noise.Source != nil {
+		for i := 0; i < 3; i++ {
+			reading.AngularVelocity[i] += m.Real(noise.Source.NormFloat64()) * noise.GyroStdDev
+			reading.LinearAcceleration[i] += m.Real(noise.Source.NormFloat64()) * noise.AccelStdDev
+		}
+	}
+
+	return reading
+}
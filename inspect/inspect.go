@@ -0,0 +1,436 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The inspect module describes the structure of a cubez World so that it can be
+printed as text or JSON and attached to a bug report. The description holds
+the bodies, their collision shapes, the joints, the islands of bodies that
+touch or are joined and the settings the world steps with.
+
+*/
+
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/harbdog/cubez"
+	m "github.com/harbdog/cubez/math"
+)
+
+// Report is a description of a World at one point in time.
+type Report struct {
+	// Solver holds the settings the world steps with.
+	Solver Solver `json:"solver"`
+
+	// Bodies describes the bodies in the order they are in the world.
+	Bodies []Body `json:"bodies"`
+
+	// Colliders describes the collision shapes in the order they are in the world.
+	Colliders []Collider `json:"colliders"`
+
+	// Joints describes the joints in the order they are in the world.
+	Joints []Joint `json:"joints"`
+
+	// Constraints describes the hinges, sliders and other constraints in the
+	// order they are in the world.
+	Constraints []Constraint `json:"constraints"`
+
+	// Islands holds the indexes of the bodies that are joined or were in
+	// contact in the last step. Bodies with infinite mass don't join islands
+	// together and aren't in any.
+	Islands [][]int `json:"islands"`
+}
+
+// Solver describes the settings of a World.
+type Solver struct {
+	Integrator      string            `json:"integrator"`
+	Convention      string            `json:"convention"`
+	Broadphase      string            `json:"broadphase"`
+	Workers         int               `json:"workers"`
+	MaxLinearSpeed  m.Real            `json:"maxLinearSpeed"`
+	MaxAngularSpeed m.Real            `json:"maxAngularSpeed"`
+	Validate        bool              `json:"validate"`
+	Wrap            *cubez.WrapBounds `json:"wrap,omitempty"`
+	Forces          bool              `json:"forces"`
+	Generators      bool              `json:"generators"`
+	Sensors         int               `json:"sensors"`
+	Contacts        int               `json:"contacts"`
+}
+
+// Body describes a RigidBody.
+type Body struct {
+	Index           int       `json:"index"`
+	ID              string    `json:"id"`
+	Mass            m.Real    `json:"mass"`
+	Static          bool      `json:"static"`
+	Position        m.Vector3 `json:"position"`
+	Orientation     m.Quat    `json:"orientation"`
+	Velocity        m.Vector3 `json:"velocity"`
+	AngularVelocity m.Vector3 `json:"angularVelocity"`
+	Acceleration    m.Vector3 `json:"acceleration"`
+	CenterOfMass    m.Vector3 `json:"centerOfMass"`
+	LinearDamping   m.Real    `json:"linearDamping"`
+	AngularDamping  m.Real    `json:"angularDamping"`
+	Awake           bool      `json:"awake"`
+	CanSleep        bool      `json:"canSleep"`
+}
+
+// Collider describes a collision shape.
+type Collider struct {
+	Index       int        `json:"index"`
+	ID          string     `json:"id"`
+	Shape       string     `json:"shape"`
+	Body        int        `json:"body"`
+	Radius      m.Real     `json:"radius,omitempty"`
+	HalfHeight  m.Real     `json:"halfHeight,omitempty"`
+	HalfSize    *m.Vector3 `json:"halfSize,omitempty"`
+	Normal      *m.Vector3 `json:"normal,omitempty"`
+	Offset      m.Real     `json:"offset,omitempty"`
+	Columns     int        `json:"columns,omitempty"`
+	Rows        int        `json:"rows,omitempty"`
+	CellSize    m.Real     `json:"cellSize,omitempty"`
+	Friction    m.Real     `json:"friction"`
+	Restitution m.Real     `json:"restitution"`
+}
+
+// Joint describes a Joint between two bodies.
+type Joint struct {
+	Index     int          `json:"index"`
+	Bodies    [2]int       `json:"bodies"`
+	Positions [2]m.Vector3 `json:"positions"`
+	Error     m.Real       `json:"error"`
+}
+
+// Constraint describes a Constraint between two bodies. The State is the
+// angle of a hinge, the offset of a slider, the length of a distance or
+// spring joint, how far a point is along its line or off of its plane, or
+// the angle a servo has left to turn.
+type Constraint struct {
+	Index      int    `json:"index"`
+	Kind       string `json:"kind"`
+	Bodies     [2]int `json:"bodies"`
+	State      m.Real `json:"state"`
+	Limited    bool   `json:"limited"`
+	LowerLimit m.Real `json:"lowerLimit,omitempty"`
+	UpperLimit m.Real `json:"upperLimit,omitempty"`
+}
+
+// Inspect builds the Report for the world. Bodies that are referenced by a
+// collider or joint but aren't in the world have an index of -1.
+func Inspect(w *cubez.World) *Report {
+	r := new(Report)
+	indexes := make(map[*cubez.RigidBody]int, len(w.Bodies))
+	indexOf := func(body *cubez.RigidBody) int {
+		if i, ok := indexes[body]; ok {
+			return i
+		}
+		return -1
+	}
+
+	r.Solver.Integrator = typeName(w.Integrator)
+	r.Solver.Convention = "YUp"
+	if w.Convention == cubez.ZUp {
+		r.Solver.Convention = "ZUp"
+	}
+	r.Solver.Broadphase = typeName(w.GetBroadphase())
+	r.Solver.Workers = w.Workers
+	r.Solver.MaxLinearSpeed = w.MaxLinearSpeed
+	r.Solver.MaxAngularSpeed = w.MaxAngularSpeed
+	r.Solver.Validate = w.Validate
+	r.Solver.Wrap = w.Wrap
+	r.Solver.Forces = w.Forces != nil
+	r.Solver.Generators = w.Generators != nil
+	r.Solver.Sensors = len(w.Sensors)
+	r.Solver.Contacts = len(w.GetContacts())
+
+	r.Bodies = make([]Body, 0, len(w.Bodies))
+	for i, body := range w.Bodies {
+		indexes[body] = i
+		b := Body{
+			Index:           i,
+			Static:          !body.HasFiniteMass(),
+			Position:        body.Position,
+			Orientation:     body.Orientation,
+			Velocity:        body.Velocity,
+			AngularVelocity: body.Rotation,
+			Acceleration:    body.Acceleration,
+			CenterOfMass:    body.CenterOfMass,
+			LinearDamping:   body.LinearDamping,
+			AngularDamping:  body.AngularDamping,
+			Awake:           body.IsAwake,
+			CanSleep:        body.CanSleep,
+		}
+		if !b.Static {
+			b.Mass = body.GetMass()
+		}
+		if id, ok := w.GetBodyID(body); ok {
+			b.ID = id.String()
+		}
+		r.Bodies = append(r.Bodies, b)
+	}
+
+	r.Colliders = make([]Collider, 0, len(w.Colliders))
+	for i, c := range w.Colliders {
+		info := Collider{Index: i, Body: -1}
+		if id, ok := w.GetColliderID(c); ok {
+			info.ID = id.String()
+		}
+		if body := c.GetBody(); body != nil {
+			info.Body = indexOf(body)
+		}
+		if mat := c.GetMaterial(); mat != nil {
+			info.Friction = mat.Friction
+			info.Restitution = mat.Restitution
+		}
+
+		switch shape := c.(type) {
+		case *cubez.CollisionSphere:
+			info.Shape = "sphere"
+			info.Radius = shape.Radius
+		case *cubez.CollisionCapsule:
+			info.Shape = "capsule"
+			info.Radius = shape.Radius
+			info.HalfHeight = shape.HalfHeight
+		case *cubez.CollisionCube:
+			info.Shape = "cube"
+			halfSize := shape.HalfSize
+			info.HalfSize = &halfSize
+		case *cubez.CollisionPlane:
+			info.Shape = "plane"
+			normal := shape.Normal
+			info.Normal = &normal
+			info.Offset = shape.Offset
+		case *cubez.CollisionHeightfield:
+			info.Shape = "heightfield"
+			info.Columns = shape.Columns
+			info.Rows = shape.Rows
+			info.CellSize = shape.CellSize
+		default:
+			info.Shape = typeName(c)
+		}
+		r.Colliders = append(r.Colliders, info)
+	}
+
+	r.Joints = make([]Joint, 0, len(w.Joints))
+	for i, j := range w.Joints {
+		r.Joints = append(r.Joints, Joint{
+			Index:     i,
+			Bodies:    [2]int{indexOf(j.Bodies[0]), indexOf(j.Bodies[1])},
+			Positions: j.Positions,
+			Error:     j.Error,
+		})
+	}
+
+	r.Constraints = make([]Constraint, 0, len(w.Constraints))
+	for i, c := range w.Constraints {
+		bodies := c.GetBodies()
+		info := Constraint{
+			Index:  i,
+			Kind:   typeName(c),
+			Bodies: [2]int{indexOf(bodies[0]), indexOf(bodies[1])},
+		}
+		switch joint := c.(type) {
+		case *cubez.HingeJoint:
+			info.Kind = "hinge"
+			info.State = joint.State().Position
+			info.Limited = joint.Limited
+			info.LowerLimit, info.UpperLimit = joint.LowerLimit, joint.UpperLimit
+		case *cubez.SliderJoint:
+			info.Kind = "slider"
+			info.State = joint.State().Position
+			info.Limited = joint.Limited
+			info.LowerLimit, info.UpperLimit = joint.LowerLimit, joint.UpperLimit
+		case *cubez.WeldJoint:
+			info.Kind = "weld"
+		case *cubez.DistanceJoint:
+			info.Kind = "distance"
+			info.State = joint.CurrentLength()
+			info.Limited = true
+			info.LowerLimit, info.UpperLimit = joint.MinLength, joint.MaxLength
+		case *cubez.SpringJoint:
+			info.Kind = "spring"
+			info.State = joint.CurrentLength()
+		case *cubez.GenericJoint:
+			info.Kind = "generic"
+		case *cubez.PointOnLineConstraint:
+			info.Kind = "pointOnLine"
+			info.State = joint.Offset()
+		case *cubez.PointOnPlaneConstraint:
+			info.Kind = "pointOnPlane"
+			info.State = joint.Distance()
+		case *cubez.OrientationServo:
+			info.Kind = "servo"
+			rotation := joint.Error()
+			info.State = rotation.Magnitude()
+		}
+		if !info.Limited {
+			info.LowerLimit, info.UpperLimit = 0.0, 0.0
+		}
+		r.Constraints = append(r.Constraints, info)
+	}
+
+	r.Islands = findIslands(w, indexes)
+	return r
+}
+
+// findIslands groups the moving bodies that are joined or in contact.
+func findIslands(w *cubez.World, indexes map[*cubez.RigidBody]int) [][]int {
+	parents := make([]int, len(w.Bodies))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+	join := func(one *cubez.RigidBody, two *cubez.RigidBody) {
+		a, okA := indexes[one]
+		b, okB := indexes[two]
+		if !okA || !okB || !one.HasFiniteMass() || !two.HasFiniteMass() {
+			return
+		}
+		a, b = find(a), find(b)
+		if a < b {
+			parents[b] = a
+		} else {
+			parents[a] = b
+		}
+	}
+
+	for _, j := range w.Joints {
+		join(j.Bodies[0], j.Bodies[1])
+	}
+	for _, c := range w.Constraints {
+		bodies := c.GetBodies()
+		join(bodies[0], bodies[1])
+	}
+	for _, c := range w.GetContacts() {
+		join(c.Bodies[0], c.Bodies[1])
+	}
+
+	groups := make(map[int][]int)
+	for i, body := range w.Bodies {
+		if body.HasFiniteMass() {
+			root := find(i)
+			groups[root] = append(groups[root], i)
+		}
+	}
+	islands := make([][]int, 0, len(groups))
+	for _, island := range groups {
+		islands = append(islands, island)
+	}
+	sort.Slice(islands, func(i, j int) bool {
+		return islands[i][0] < islands[j][0]
+	})
+	return islands
+}
+
+// typeName returns the name of the type of the value, or "none" if it's nil.
+func typeName(v interface{}) string {
+	if v == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes the report as plain text meant to be read by people.
+func (r *Report) WriteText(out io.Writer) error {
+	tw := &textWriter{out: out}
+	s := &r.Solver
+	tw.printf("solver:\n")
+	tw.printf("  integrator: %s\n", s.Integrator)
+	tw.printf("  convention: %s\n", s.Convention)
+	tw.printf("  broadphase: %s\n", s.Broadphase)
+	tw.printf("  workers: %d\n", s.Workers)
+	tw.printf("  max speeds: linear %g, angular %g\n", s.MaxLinearSpeed, s.MaxAngularSpeed)
+	tw.printf("  validate: %t\n", s.Validate)
+	if s.Wrap != nil {
+		tw.printf("  wrap: min %v, max %v, axes %v\n", s.Wrap.Min, s.Wrap.Max, s.Wrap.Axes)
+	}
+	tw.printf("  forces: %t, generators: %t\n", s.Forces, s.Generators)
+	tw.printf("  sensors: %d, contacts last step: %d\n", s.Sensors, s.Contacts)
+
+	tw.printf("bodies (%d):\n", len(r.Bodies))
+	for _, b := range r.Bodies {
+		mass := fmt.Sprintf("mass %g", b.Mass)
+		if b.Static {
+			mass = "static"
+		}
+		state := "asleep"
+		if b.Awake {
+			state = "awake"
+		}
+		tw.printf("  [%d] %s %s, %s\n", b.Index, b.ID, mass, state)
+		tw.printf("      position %v orientation %v\n", b.Position, b.Orientation)
+		tw.printf("      velocity %v angular %v\n", b.Velocity, b.AngularVelocity)
+	}
+
+	tw.printf("colliders (%d):\n", len(r.Colliders))
+	for _, c := range r.Colliders {
+		var size string
+		switch c.Shape {
+		case "sphere":
+			size = fmt.Sprintf(" radius %g", c.Radius)
+		case "capsule":
+			size = fmt.Sprintf(" radius %g half height %g", c.Radius, c.HalfHeight)
+		case "cube":
+			size = fmt.Sprintf(" half size %v", *c.HalfSize)
+		case "plane":
+			size = fmt.Sprintf(" normal %v offset %g", *c.Normal, c.Offset)
+		case "heightfield":
+			size = fmt.Sprintf(" %dx%d cells of %g", c.Columns, c.Rows, c.CellSize)
+		}
+		tw.printf("  [%d] %s %s%s, body %d, friction %g, restitution %g\n",
+			c.Index, c.ID, c.Shape, size, c.Body, c.Friction, c.Restitution)
+	}
+
+	tw.printf("joints (%d):\n", len(r.Joints))
+	for _, j := range r.Joints {
+		tw.printf("  [%d] bodies %d and %d at %v and %v, error %g\n",
+			j.Index, j.Bodies[0], j.Bodies[1], j.Positions[0], j.Positions[1], j.Error)
+	}
+
+	tw.printf("constraints (%d):\n", len(r.Constraints))
+	for _, c := range r.Constraints {
+		limits := "unlimited"
+		if c.Limited {
+			limits = fmt.Sprintf("limits %g to %g", c.LowerLimit, c.UpperLimit)
+		}
+		tw.printf("  [%d] %s between bodies %d and %d at %g, %s\n",
+			c.Index, c.Kind, c.Bodies[0], c.Bodies[1], c.State, limits)
+	}
+
+	tw.printf("islands (%d):\n", len(r.Islands))
+	for i, island := range r.Islands {
+		tw.printf("  [%d] bodies %v\n", i, island)
+	}
+	return tw.err
+}
+
+// textWriter keeps the first error from a run of writes.
+type textWriter struct {
+	out io.Writer
+	err error
+}
+
+// printf writes the formatted text unless an earlier write failed.
+func (tw *textWriter) printf(format string, args ...interface{}) {
+	if tw.err == nil {
+		_, tw.err = fmt.Fprintf(tw.out, format, args...)
+	}
+}
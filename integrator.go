@@ -0,0 +1,156 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// ForceFunc adds the forces acting on a RigidBody, based on its current state,
+// to the body's force and torque accumulators.
+type ForceFunc func(body *RigidBody)
+
+// Integrator is an interface for the methods that advance a RigidBody through
+// time. The body's accumulators hold the forces acting on it at the start of
+// the step when Integrate is called. Integrators that evaluate the body at more
+// than one point in the step call the forces function, if it's not nil, to
+// re-evaluate the forces at each point; otherwise the forces at the start of
+// the step are used throughout.
+type Integrator interface {
+	Integrate(body *RigidBody, duration m.Real, forces ForceFunc)
+}
+
+// SemiImplicitEuler integrates bodies by updating the velocities first and then
+// moving the bodies with the new velocities. It is cheap and stable, which makes
+// it a good fit for games, and is the default Integrator.
+type SemiImplicitEuler struct{}
+
+// Integrate advances the body through time by the duration given.
+func (integrator *SemiImplicitEuler) Integrate(body *RigidBody, duration m.Real, forces ForceFunc) {
+	body.Integrate(duration)
+}
+
+// VelocityVerlet integrates bodies with the velocity Verlet method, which is
+// second order accurate and conserves energy well for position dependent forces
+// such as springs and orbits, at the cost of one extra force evaluation.
+type VelocityVerlet struct{}
+
+// Integrate advances the body through time by the duration given.
+func (integrator *VelocityVerlet) Integrate(body *RigidBody, duration m.Real, forces ForceFunc) {
+	if !body.beginIntegration(duration) {
+		return
+	}
+	acceleration, angularAcceleration := body.calculateAccelerations()
+
+	// move the body using the velocity and acceleration at the start of the step
+	com := body.GetCenterOfMassWorld()
+	com.AddScaled(&body.Velocity, duration)
+	com.AddScaled(&acceleration, 0.5*duration*duration)
+	spin := body.Rotation
+	spin.AddScaled(&angularAcceleration, 0.5*duration)
+	body.Orientation.AddScaledVector(&spin, duration)
+	body.setCenterOfMassWorld(&com)
+
+	// evaluate the forces at the new position
+	nextAcceleration, nextAngularAcceleration := acceleration, angularAcceleration
+	if forces != nil {
+		body.CalculateDerivedData()
+		body.ClearAccumulators()
+		forces(body)
+		nextAcceleration, nextAngularAcceleration = body.calculateAccelerations()
+	}
+
+	// update the velocities with the average acceleration over the step
+	body.lastFrameAccelleration = acceleration
+	body.lastFrameAccelleration.Add(&nextAcceleration)
+	body.lastFrameAccelleration.MulWith(0.5)
+	angularAcceleration.Add(&nextAngularAcceleration)
+	angularAcceleration.MulWith(0.5)
+	body.Velocity.AddScaled(&body.lastFrameAccelleration, duration)
+	body.Rotation.AddScaled(&angularAcceleration, duration)
+
+	body.constrainVelocities(duration)
+	body.endIntegration(duration)
+}
+
+// RungeKutta4 integrates bodies with the classic fourth order Runge-Kutta
+// method. It evaluates the forces four times per step, so it's the most
+// expensive, but it's also the most accurate for smooth force fields and suits
+// trajectory analysis.
+type RungeKutta4 struct{}
+
+// rk4Derivative holds the rate of change of a body's state at one of the
+// evaluation points of the Runge-Kutta method.
+type rk4Derivative struct {
+	velocity            m.Vector3
+	rotation            m.Vector3
+	acceleration        m.Vector3
+	angularAcceleration m.Vector3
+}
+
+// Integrate advances the body through time by the duration given.
+func (integrator *RungeKutta4) Integrate(body *RigidBody, duration m.Real, forces ForceFunc) {
+	if !body.beginIntegration(duration) {
+		return
+	}
+
+	startCOM := body.GetCenterOfMassWorld()
+	startOrientation := body.Orientation
+	startVelocity := body.Velocity
+	startRotation := body.Rotation
+	startAcceleration, startAngularAcceleration := body.calculateAccelerations()
+
+	offsets := [4]m.Real{0.0, 0.5, 0.5, 1.0}
+	weights := [4]m.Real{1.0, 2.0, 2.0, 1.0}
+	var prev, sum rk4Derivative
+	for i := 0; i < 4; i++ {
+		dt := duration * offsets[i]
+
+		var d rk4Derivative
+		d.velocity = startVelocity
+		d.velocity.AddScaled(&prev.acceleration, dt)
+		d.rotation = startRotation
+		d.rotation.AddScaled(&prev.angularAcceleration, dt)
+
+		if i == 0 || forces == nil {
+			d.acceleration, d.angularAcceleration = startAcceleration, startAngularAcceleration
+		} else {
+			// move the body to the state at this evaluation point and get the forces there
+			com := startCOM
+			com.AddScaled(&prev.velocity, dt)
+			body.Orientation = startOrientation
+			body.Orientation.AddScaledVector(&prev.rotation, dt)
+			body.setCenterOfMassWorld(&com)
+			body.Velocity = d.velocity
+			body.Rotation = d.rotation
+			body.CalculateDerivedData()
+			body.ClearAccumulators()
+			forces(body)
+			d.acceleration, d.angularAcceleration = body.calculateAccelerations()
+		}
+
+		sum.velocity.AddScaled(&d.velocity, weights[i])
+		sum.rotation.AddScaled(&d.rotation, weights[i])
+		sum.acceleration.AddScaled(&d.acceleration, weights[i])
+		sum.angularAcceleration.AddScaled(&d.angularAcceleration, weights[i])
+		prev = d
+	}
+
+	// combine the weighted derivatives to get the state at the end of the step
+	scale := duration / 6.0
+	com := startCOM
+	com.AddScaled(&sum.velocity, scale)
+	body.Orientation = startOrientation
+	body.Orientation.AddScaledVector(&sum.rotation, scale)
+	body.setCenterOfMassWorld(&com)
+	body.Velocity = startVelocity
+	body.Velocity.AddScaled(&sum.acceleration, scale)
+	body.Rotation = startRotation
+	body.Rotation.AddScaled(&sum.angularAcceleration, scale)
+	body.lastFrameAccelleration = sum.acceleration
+	body.lastFrameAccelleration.MulWith(1.0 / 6.0)
+
+	body.constrainVelocities(duration)
+	body.endIntegration(duration)
+}
@@ -0,0 +1,240 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// Joint links two bodies together at a point on each, allowing them to rotate
+// freely about it like a ball and socket. As in cyclone, joints are enforced by
+// generating contacts that pull the points back together whenever they drift
+// further apart than the allowed error.
+type Joint struct {
+	ConstraintSoftness
+
+	// Bodies holds the two bodies that are joined. The second body can be nil,
+	// in which case the first is joined to a fixed point in the world.
+	Bodies [2]*RigidBody
+
+	// Positions holds the joined point on each body in Body Space. If the
+	// second body is nil, its position is in World Space.
+	Positions [2]m.Vector3
+
+	// Error is the distance the points are allowed to drift apart before the
+	// joint generates a contact.
+	Error m.Real
+}
+
+// NewJoint creates a new Joint between the points given on each body.
+func NewJoint(one *RigidBody, positionOne m.Vector3, two *RigidBody, positionTwo m.Vector3, err m.Real) *Joint {
+	j := new(Joint)
+	j.Bodies[0] = one
+	j.Bodies[1] = two
+	j.Positions[0] = positionOne
+	j.Positions[1] = positionTwo
+	j.Error = err
+	return j
+}
+
+// AddContact generates a contact if the joint has been violated and appends it
+// to the existing contacts.
+func (j *Joint) AddContact(existingContacts []*Contact) (bool, []*Contact) {
+	posOne := bodyPointToWorld(j.Bodies[0], &j.Positions[0])
+	posTwo := bodyPointToWorld(j.Bodies[1], &j.Positions[1])
+	return addPointJointContact(existingContacts, &j.Bodies, &posOne, &posTwo, j.Error)
+}
+
+// addPointJointContact generates a contact that pulls the World Space points
+// on the two bodies back together if they're further apart than the error.
+func addPointJointContact(existingContacts []*Contact, bodies *[2]*RigidBody, posOne *m.Vector3, posTwo *m.Vector3, err m.Real) (bool, []*Contact) {
+	oneToTwo := *posTwo
+	oneToTwo.Sub(posOne)
+	length := oneToTwo.Magnitude()
+	if length <= err {
+		return false, existingContacts
+	}
+
+	c := NewContact()
+	c.Bodies = *bodies
+	c.ContactNormal = oneToTwo
+	c.ContactNormal.MulWith(1.0 / length)
+	c.ContactPoint = *posOne
+	c.ContactPoint.Add(posTwo)
+	c.ContactPoint.MulWith(0.5)
+	c.Penetration = length - err
+	c.Friction = 1.0
+	c.Restitution = 0.0
+
+	return true, append(existingContacts, c)
+}
+
+// JointState holds the joint-space state of a joint with a single degree of
+// freedom, such as a hinge or a slider, so that control systems can read the
+// state of an articulation without reconstructing it from body transforms.
+type JointState struct {
+	// Position is the angle, in radians, of a hinge or the offset of a slider.
+	Position m.Real
+
+	// Velocity is the rate of change of Position per second.
+	Velocity m.Real
+}
+
+// HingeState calculates the joint-space state of a hinge between two bodies.
+// The axis and reference vectors are given in the Body Space of their respective
+// bodies; the reference vectors should be perpendicular to the axis and the angle
+// reported is the rotation from the first reference to the second around the axis.
+// Either body can be nil, in which case the vectors are taken to be in World Space.
+func HingeState(one *RigidBody, two *RigidBody, axis *m.Vector3, referenceOne *m.Vector3, referenceTwo *m.Vector3) JointState {
+	var state JointState
+	worldAxis := bodyDirectionToWorld(one, axis)
+	worldAxis.Normalize()
+	refOne := bodyDirectionToWorld(one, referenceOne)
+	refTwo := bodyDirectionToWorld(two, referenceTwo)
+
+	// project the references onto the plane of the hinge
+	refOne.AddScaled(&worldAxis, -refOne.Dot(&worldAxis))
+	refTwo.AddScaled(&worldAxis, -refTwo.Dot(&worldAxis))
+
+	cross := refOne.Cross(&refTwo)
+	state.Position = m.Real(math.Atan2(float64(cross.Dot(&worldAxis)), float64(refOne.Dot(&refTwo))))
+
+	var relativeRotation m.Vector3
+	if two != nil {
+		relativeRotation = two.Rotation
+	}
+	if one != nil {
+		relativeRotation.Sub(&one.Rotation)
+	}
+	state.Velocity = relativeRotation.Dot(&worldAxis)
+
+	return state
+}
+
+// SliderState calculates the joint-space state of a slider between two bodies.
+// The axis and first anchor are given in the Body Space of the first body and
+// the second anchor is given in the Body Space of the second body. The offset
+// reported is the distance between the anchors along the axis. Either body can
+// be nil, in which case the vectors are taken to be in World Space.
+func SliderState(one *RigidBody, two *RigidBody, axis *m.Vector3, anchorOne *m.Vector3, anchorTwo *m.Vector3) JointState {
+	var state JointState
+	worldAxis := bodyDirectionToWorld(one, axis)
+	worldAxis.Normalize()
+	pointOne := bodyPointToWorld(one, anchorOne)
+	pointTwo := bodyPointToWorld(two, anchorTwo)
+
+	separation := pointTwo
+	separation.Sub(&pointOne)
+	state.Position = separation.Dot(&worldAxis)
+
+	var relativeVelocity m.Vector3
+	if two != nil {
+		relativeVelocity = two.GetVelocityAtPoint(&pointTwo)
+	}
+	if one != nil {
+		velocityOne := one.GetVelocityAtPoint(&pointOne)
+		relativeVelocity.Sub(&velocityOne)
+	}
+	state.Velocity = relativeVelocity.Dot(&worldAxis)
+
+	return state
+}
+
+// bodyPointToWorld converts a point in Body Space to World Space; a nil body
+// leaves the point unchanged.
+func bodyPointToWorld(body *RigidBody, point *m.Vector3) m.Vector3 {
+	if body == nil {
+		return *point
+	}
+	return body.transform.MulVector3(point)
+}
+
+// bodyDirectionToWorld converts a direction in Body Space to World Space; a nil
+// body leaves the direction unchanged.
+func bodyDirectionToWorld(body *RigidBody, direction *m.Vector3) m.Vector3 {
+	if body == nil {
+		return *direction
+	}
+	return body.transform.TransformDirection(direction)
+}
+
+// worldPointToBody converts a point in World Space to Body Space; a nil body
+// leaves the point unchanged.
+func worldPointToBody(body *RigidBody, point *m.Vector3) m.Vector3 {
+	if body == nil {
+		return *point
+	}
+	return body.transform.TransformInverse(point)
+}
+
+// worldDirectionToBody converts a direction in World Space to Body Space; a nil
+// body leaves the direction unchanged.
+func worldDirectionToBody(body *RigidBody, direction *m.Vector3) m.Vector3 {
+	if body == nil {
+		return *direction
+	}
+	return body.transform.TransformInverseDirection(direction)
+}
+
+// StallDetector watches the force or torque applied by a joint motor and reports
+// when the motor has been saturated at its limit for a number of consecutive
+// steps, such as a door blocked by an obstacle, so gameplay code can react.
+type StallDetector struct {
+	// Steps is the number of consecutive saturated steps needed to consider
+	// the motor stalled.
+	Steps int
+
+	// OnStall, if set, is called once when the motor becomes stalled.
+	OnStall func()
+
+	// OnRecover, if set, is called once when a stalled motor is no longer saturated.
+	OnRecover func()
+
+	// saturatedSteps is the current count of consecutive saturated steps.
+	saturatedSteps int
+
+	// stalled indicates whether or not the motor is currently stalled.
+	stalled bool
+}
+
+// motorSaturationTolerance is the fraction of the limit a motor has to reach to
+// be considered saturated.
+const motorSaturationTolerance m.Real = 0.999
+
+// Update records the magnitude of force or torque applied by the motor this step
+// along with the motor's limit and returns true if the motor is stalled.
+func (sd *StallDetector) Update(applied m.Real, limit m.Real) bool {
+	if limit > 0.0 && m.RealAbs(applied) >= limit*motorSaturationTolerance {
+		sd.saturatedSteps++
+	} else {
+		sd.saturatedSteps = 0
+	}
+
+	if !sd.stalled && sd.saturatedSteps >= sd.Steps && sd.saturatedSteps > 0 {
+		sd.stalled = true
+		if sd.OnStall != nil {
+			sd.OnStall()
+		}
+	} else if sd.stalled && sd.saturatedSteps == 0 {
+		sd.stalled = false
+		if sd.OnRecover != nil {
+			sd.OnRecover()
+		}
+	}
+
+	return sd.stalled
+}
+
+// IsStalled returns true if the motor is currently stalled.
+func (sd *StallDetector) IsStalled() bool {
+	return sd.stalled
+}
+
+// Reset clears the stall state without calling any callbacks.
+func (sd *StallDetector) Reset() {
+	sd.saturatedSteps = 0
+	sd.stalled = false
+}
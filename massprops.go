@@ -0,0 +1,188 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"fmt"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// MassProperties holds the physical properties of a solid shape.
+type MassProperties struct {
+	// Volume is the volume of the shape.
+	Volume m.Real
+
+	// Mass is the mass of the shape.
+	Mass m.Real
+
+	// CenterOfMass is the center of mass of the shape in the same space as
+	// the vertices it was computed from.
+	CenterOfMass m.Vector3
+
+	// InertiaTensor is the inertia tensor of the shape about its center of mass.
+	InertiaTensor m.Matrix3
+}
+
+// ComputeMeshMassProperties calculates the mass, center of mass and inertia tensor
+// of a closed triangle mesh, such as a convex hull, of uniform density. Every three
+// indices form a triangle and triangles must be wound counter-clockwise when seen
+// from outside of the mesh.
+func ComputeMeshMassProperties(vertices []m.Vector3, indices []int, density m.Real) (MassProperties, error) {
+	var props MassProperties
+	if len(indices) == 0 || len(indices)%3 != 0 {
+		return props, fmt.Errorf("the number of indices must be a non-zero multiple of three; got %d", len(indices))
+	}
+
+	// the covariance of the canonical tetrahedron (0,0,0),(1,0,0),(0,1,0),(0,0,1)
+	canonical := m.Matrix3{
+		2.0 / 120.0, 1.0 / 120.0, 1.0 / 120.0,
+		1.0 / 120.0, 2.0 / 120.0, 1.0 / 120.0,
+		1.0 / 120.0, 1.0 / 120.0, 2.0 / 120.0,
+	}
+
+	// sum up the tetrahedrons formed by each triangle and the origin
+	var covariance m.Matrix3
+	var weightedCenter m.Vector3
+	var volume m.Real
+	for i := 0; i < len(indices); i += 3 {
+		for j := 0; j < 3; j++ {
+			if indices[i+j] < 0 || indices[i+j] >= len(vertices) {
+				return props, fmt.Errorf("index %d at position %d is out of range", indices[i+j], i+j)
+			}
+		}
+		a := vertices[indices[i]]
+		b := vertices[indices[i+1]]
+		c := vertices[indices[i+2]]
+
+		var tet m.Matrix3
+		tet.SetComponents(&a, &b, &c)
+		det := tet.Determinant()
+
+		// covariance of this tetrahedron: det * A * C * A^T
+		tetCov := tet.MulMatrix3(&canonical)
+		tetCov = tetCov.MulTransposeMatrix3(&tet)
+		tetCov.MulWith(det)
+		covariance.Add(&tetCov)
+
+		tetVolume := det / 6.0
+		volume += tetVolume
+
+		// the centroid of the tetrahedron is a quarter of the sum of its vertices
+		// since the fourth vertex is the origin
+		center := a
+		center.Add(&b)
+		center.Add(&c)
+		weightedCenter.AddScaled(&center, tetVolume*0.25)
+	}
+
+	if volume <= m.Epsilon {
+		return props, fmt.Errorf("the mesh does not enclose a positive volume; check that it is closed and wound counter-clockwise")
+	}
+
+	props.Volume = volume
+	props.Mass = volume * density
+	props.CenterOfMass = weightedCenter
+	props.CenterOfMass.MulWith(1.0 / volume)
+
+	// move the covariance to be about the center of mass
+	covariance.MulWith(density)
+	com := props.CenterOfMass
+	for col := 0; col < 3; col++ {
+		for row := 0; row < 3; row++ {
+			covariance[col*3+row] -= props.Mass * com[row] * com[col]
+		}
+	}
+
+	// the inertia tensor is trace(C) * I - C
+	trace := covariance[0] + covariance[4] + covariance[8]
+	props.InertiaTensor = covariance
+	props.InertiaTensor.MulWith(-1.0)
+	props.InertiaTensor[0] += trace
+	props.InertiaTensor[4] += trace
+	props.InertiaTensor[8] += trace
+
+	return props, nil
+}
+
+// ApplyTo sets the mass, center of mass and inertia tensor of the RigidBody to
+// these properties. The properties are assumed to be in the body's Body Space.
+func (props *MassProperties) ApplyTo(body *RigidBody) {
+	body.SetMass(props.Mass)
+	body.SetInertiaTensor(&props.InertiaTensor)
+	body.CenterOfMass = props.CenterOfMass
+	body.CalculateDerivedData()
+}
+
+// shapeMass is the mass properties, in Body Space, of a collider of a body.
+type shapeMass struct {
+	shape Collider
+	props MassProperties
+}
+
+// setShapeMass sets the mass properties of a collider of the body, replacing
+// any it had before, and sets the mass, center of mass and inertia tensor of
+// the body to those of all of its colliders together.
+func (body *RigidBody) setShapeMass(shape Collider, props *MassProperties) {
+	shapes := make([]shapeMass, 0, len(body.shapeMasses)+1)
+	for _, sm := range body.shapeMasses {
+		if sm.shape != shape {
+			shapes = append(shapes, sm)
+		}
+	}
+	shapes = append(shapes, shapeMass{shape, *props})
+
+	parts := make([]MassProperties, len(shapes))
+	for i := range shapes {
+		parts[i] = shapes[i].props
+	}
+	total := combineMassProperties(parts)
+	total.ApplyTo(body)
+	body.shapeMasses = shapes
+}
+
+// combineMassProperties returns the properties of the parts of a solid taken
+// together. The inertia tensor of each part is moved to the combined center
+// of mass with the parallel axis theorem before they're added up.
+func combineMassProperties(parts []MassProperties) MassProperties {
+	var total MassProperties
+	for i := range parts {
+		total.Volume += parts[i].Volume
+		total.Mass += parts[i].Mass
+		total.CenterOfMass.AddScaled(&parts[i].CenterOfMass, parts[i].Mass)
+	}
+	if total.Mass > 0.0 {
+		total.CenterOfMass.MulWith(1.0 / total.Mass)
+	}
+
+	for i := range parts {
+		d := parts[i].CenterOfMass
+		d.Sub(&total.CenterOfMass)
+		var shift m.Matrix3
+		shift.SetInertiaTensorCoeffs(
+			parts[i].Mass*(d[1]*d[1]+d[2]*d[2]),
+			parts[i].Mass*(d[0]*d[0]+d[2]*d[2]),
+			parts[i].Mass*(d[0]*d[0]+d[1]*d[1]),
+			parts[i].Mass*d[0]*d[1], parts[i].Mass*d[0]*d[2], parts[i].Mass*d[1]*d[2])
+		total.InertiaTensor.Add(&parts[i].InertiaTensor)
+		total.InertiaTensor.Add(&shift)
+	}
+	return total
+}
+
+// offsetMassProperties returns the properties of a shape with the volume and
+// inertia tensor given, about its own center, placed in its body at the
+// offset given.
+func offsetMassProperties(volume m.Real, density m.Real, localInertia *m.Matrix3, offset *m.Matrix3x4) MassProperties {
+	var props MassProperties
+	props.Volume = volume
+	props.Mass = volume * density
+	props.CenterOfMass = offset.GetPosition()
+
+	// turn the inertia tensor by the rotation of the offset
+	rotation := offset.GetMatrix3()
+	props.InertiaTensor = rotation.MulMatrix3(localInertia)
+	props.InertiaTensor = props.InertiaTensor.MulTransposeMatrix3(&rotation)
+	return props
+}
@@ -0,0 +1,67 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// Material describes the surface properties of a collider. The physical properties
+// are combined between the two colliders of a contact and the acoustic properties
+// are passed along with contacts and ray hits for audio integrations.
+type Material struct {
+	// Friction is the lateral friction coefficient of the surface.
+	Friction m.Real
+
+	// Restitution is the normal restitution coefficient (bounciness) of the surface.
+	Restitution m.Real
+
+	// Absorption is the fraction, from 0 to 1, of sound energy absorbed by the surface.
+	Absorption m.Real
+
+	// Transmission is the fraction, from 0 to 1, of sound energy transmitted
+	// through the object.
+	Transmission m.Real
+}
+
+// DefaultMaterial is the material used by colliders that haven't been given one.
+var DefaultMaterial = Material{
+	Friction:     0.9,
+	Restitution:  0.1,
+	Absorption:   0.1,
+	Transmission: 0.0,
+}
+
+// NewMaterial creates a new Material object with the friction and restitution
+// specified and the default acoustic properties.
+func NewMaterial(friction m.Real, restitution m.Real) *Material {
+	mat := new(Material)
+	*mat = DefaultMaterial
+	mat.Friction = friction
+	mat.Restitution = restitution
+	return mat
+}
+
+// CombineFriction returns the friction coefficient for a contact between two
+// materials, which is the geometric mean of their coefficients.
+func CombineFriction(one *Material, two *Material) m.Real {
+	return m.RealSqrt(one.Friction * two.Friction)
+}
+
+// CombineRestitution returns the restitution coefficient for a contact between
+// two materials, which is the larger of their coefficients.
+func CombineRestitution(one *Material, two *Material) m.Real {
+	if one.Restitution > two.Restitution {
+		return one.Restitution
+	}
+	return two.Restitution
+}
+
+// materialOrDefault returns the material given or the DefaultMaterial if it's nil.
+func materialOrDefault(mat *Material) *Material {
+	if mat == nil {
+		return &DefaultMaterial
+	}
+	return mat
+}
@@ -0,0 +1,175 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+// AABB is an axis aligned bounding box.
+type AABB struct {
+	// Min is the corner of the box with the smallest coordinates.
+	Min Vector3
+
+	// Max is the corner of the box with the largest coordinates.
+	Max Vector3
+}
+
+// AABBAround returns the box that bounds a sphere.
+func AABBAround(center *Vector3, radius Real) AABB {
+	a := AABB{Min: *center, Max: *center}
+	a.Expand(radius)
+	return a
+}
+
+// Center returns the point in the middle of the box.
+func (a *AABB) Center() Vector3 {
+	return Vector3{
+		(a.Min[0] + a.Max[0]) * 0.5,
+		(a.Min[1] + a.Max[1]) * 0.5,
+		(a.Min[2] + a.Max[2]) * 0.5,
+	}
+}
+
+// HalfSize returns the distance from the center of the box to its faces
+// along each axis.
+func (a *AABB) HalfSize() Vector3 {
+	return Vector3{
+		(a.Max[0] - a.Min[0]) * 0.5,
+		(a.Max[1] - a.Min[1]) * 0.5,
+		(a.Max[2] - a.Min[2]) * 0.5,
+	}
+}
+
+// Merge grows the box to also cover the other box.
+func (a *AABB) Merge(other *AABB) {
+	for i := 0; i < 3; i++ {
+		if other.Min[i] < a.Min[i] {
+			a.Min[i] = other.Min[i]
+		}
+		if other.Max[i] > a.Max[i] {
+			a.Max[i] = other.Max[i]
+		}
+	}
+}
+
+// MergePoint grows the box to also cover the point.
+func (a *AABB) MergePoint(point *Vector3) {
+	for i := 0; i < 3; i++ {
+		if point[i] < a.Min[i] {
+			a.Min[i] = point[i]
+		}
+		if point[i] > a.Max[i] {
+			a.Max[i] = point[i]
+		}
+	}
+}
+
+// Expand moves every face of the box out by the amount given.
+func (a *AABB) Expand(amount Real) {
+	for i := 0; i < 3; i++ {
+		a.Min[i] -= amount
+		a.Max[i] += amount
+	}
+}
+
+// Overlaps returns true if the two boxes overlap or touch.
+func (a *AABB) Overlaps(other *AABB) bool {
+	for i := 0; i < 3; i++ {
+		if a.Max[i] < other.Min[i] || other.Max[i] < a.Min[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains returns true if the point is inside the box.
+func (a *AABB) Contains(point *Vector3) bool {
+	for i := 0; i < 3; i++ {
+		if point[i] < a.Min[i] || point[i] > a.Max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ContainsAABB returns true if the other box is entirely inside the box.
+func (a *AABB) ContainsAABB(other *AABB) bool {
+	for i := 0; i < 3; i++ {
+		if other.Min[i] < a.Min[i] || other.Max[i] > a.Max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ClosestPoint returns the point in the box that is closest to the point given.
+func (a *AABB) ClosestPoint(point *Vector3) Vector3 {
+	closest := *point
+	for i := 0; i < 3; i++ {
+		if closest[i] < a.Min[i] {
+			closest[i] = a.Min[i]
+		} else if closest[i] > a.Max[i] {
+			closest[i] = a.Max[i]
+		}
+	}
+	return closest
+}
+
+// IntersectsRay returns true if the ray hits the box within the distance given.
+// The direction should be normalized.
+func (a *AABB) IntersectsRay(origin *Vector3, direction *Vector3, maxDistance Real) bool {
+	hit, _ := a.RayIntersection(origin, direction, maxDistance)
+	return hit
+}
+
+// RayIntersection returns true if the ray hits the box within the distance
+// given, along with the distance to where it enters the box, which is zero
+// if the origin is inside it. The direction should be normalized.
+func (a *AABB) RayIntersection(origin *Vector3, direction *Vector3, maxDistance Real) (bool, Real) {
+	tMin := Real(0.0)
+	tMax := maxDistance
+	for i := 0; i < 3; i++ {
+		if RealAbs(direction[i]) < Epsilon {
+			if origin[i] < a.Min[i] || origin[i] > a.Max[i] {
+				return false, 0.0
+			}
+			continue
+		}
+		invDir := 1.0 / direction[i]
+		t1 := (a.Min[i] - origin[i]) * invDir
+		t2 := (a.Max[i] - origin[i]) * invDir
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		if t1 > tMin {
+			tMin = t1
+		}
+		if t2 < tMax {
+			tMax = t2
+		}
+		if tMin > tMax {
+			return false, 0.0
+		}
+	}
+	return true, tMin
+}
+
+// Transform returns the box that bounds this box after it's been moved by
+// the transform matrix.
+func (a *AABB) Transform(transform *Matrix3x4) AABB {
+	center := a.Center()
+	half := a.HalfSize()
+	center = transform.MulVector3(&center)
+
+	// the extent along each axis is the sum of the half sizes projected onto it
+	var extent Vector3
+	for axis := 0; axis < 3; axis++ {
+		dir := transform.GetAxis(axis)
+		for i := 0; i < 3; i++ {
+			extent[i] += RealAbs(dir[i]) * half[axis]
+		}
+	}
+
+	result := AABB{Min: center, Max: center}
+	result.Min.Sub(&extent)
+	result.Max.Add(&extent)
+	return result
+}
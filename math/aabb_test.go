@@ -0,0 +1,80 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+import (
+	"testing"
+)
+
+func TestAABBMergeExpand(t *testing.T) {
+	a := AABB{Min: Vector3{0.0, 0.0, 0.0}, Max: Vector3{1.0, 1.0, 1.0}}
+	b := AABB{Min: Vector3{-1.0, 0.5, 0.5}, Max: Vector3{0.5, 2.0, 0.5}}
+	a.Merge(&b)
+	if a.Min != (Vector3{-1.0, 0.0, 0.0}) || a.Max != (Vector3{1.0, 2.0, 1.0}) {
+		t.Errorf("Merged box is wrong: %v", a)
+	}
+	if !a.ContainsAABB(&b) || b.ContainsAABB(&a) {
+		t.Errorf("The merged box should contain the other box and not the other way around")
+	}
+
+	a.MergePoint(&Vector3{0.0, 0.0, 3.0})
+	a.Expand(0.5)
+	if a.Min != (Vector3{-1.5, -0.5, -0.5}) || a.Max != (Vector3{1.5, 2.5, 3.5}) {
+		t.Errorf("Expanded box is wrong: %v", a)
+	}
+
+	sphere := AABBAround(&Vector3{1.0, 2.0, 3.0}, 0.5)
+	if sphere.Center() != (Vector3{1.0, 2.0, 3.0}) || sphere.HalfSize() != (Vector3{0.5, 0.5, 0.5}) {
+		t.Errorf("Box around a sphere is wrong: %v", sphere)
+	}
+}
+
+func TestAABBQueries(t *testing.T) {
+	a := AABB{Min: Vector3{-1.0, -1.0, -1.0}, Max: Vector3{1.0, 1.0, 1.0}}
+	touching := AABB{Min: Vector3{1.0, 0.0, 0.0}, Max: Vector3{2.0, 1.0, 1.0}}
+	apart := AABB{Min: Vector3{1.5, 0.0, 0.0}, Max: Vector3{2.0, 1.0, 1.0}}
+	if !a.Overlaps(&touching) || a.Overlaps(&apart) {
+		t.Errorf("Overlap tests are wrong")
+	}
+	if !a.Contains(&Vector3{0.5, -0.5, 1.0}) || a.Contains(&Vector3{0.5, -1.5, 0.0}) {
+		t.Errorf("Containment tests are wrong")
+	}
+	closest := a.ClosestPoint(&Vector3{3.0, 0.5, -2.0})
+	if closest != (Vector3{1.0, 0.5, -1.0}) {
+		t.Errorf("Closest point is wrong: %v", closest)
+	}
+
+	hit, distance := a.RayIntersection(&Vector3{-5.0, 0.0, 0.0}, &Vector3{1.0, 0.0, 0.0}, 10.0)
+	if !hit || !RealEqual(distance, 4.0) {
+		t.Errorf("Ray should hit the box at 4; got %v at %v", hit, distance)
+	}
+	if a.IntersectsRay(&Vector3{-5.0, 0.0, 0.0}, &Vector3{1.0, 0.0, 0.0}, 3.0) {
+		t.Errorf("Ray shouldn't reach the box")
+	}
+	if a.IntersectsRay(&Vector3{-5.0, 2.0, 0.0}, &Vector3{1.0, 0.0, 0.0}, 10.0) {
+		t.Errorf("Ray should miss the box")
+	}
+	hit, distance = a.RayIntersection(&Vector3{0.0, 0.0, 0.0}, &Vector3{0.0, 1.0, 0.0}, 10.0)
+	if !hit || distance != 0.0 {
+		t.Errorf("Ray from inside the box should hit at 0; got %v at %v", hit, distance)
+	}
+}
+
+func TestAABBTransform(t *testing.T) {
+	a := AABB{Min: Vector3{-1.0, -2.0, -3.0}, Max: Vector3{1.0, 2.0, 3.0}}
+
+	// a quarter turn about y swaps the x and z sizes
+	var transform Matrix3x4
+	rotation := QuatFromAxis(DegToRad(90.0), 0.0, 1.0, 0.0)
+	transform.SetAsTransform(&Vector3{10.0, 0.0, 0.0}, &rotation)
+	b := a.Transform(&transform)
+
+	center, half := b.Center(), b.HalfSize()
+	expected := [2]Vector3{{10.0, 0.0, 0.0}, {3.0, 2.0, 1.0}}
+	for i := 0; i < 3; i++ {
+		if !RealEqual(center[i], expected[0][i]) || !RealEqual(half[i], expected[1][i]) {
+			t.Fatalf("Transformed box is wrong: %v", b)
+		}
+	}
+}
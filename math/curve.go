@@ -0,0 +1,237 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+// Curve is a smooth curve through space that runs from a parameter of zero to
+// a parameter of End.
+type Curve interface {
+	// Point returns the point on the curve at the parameter t.
+	Point(t Real) Vector3
+
+	// Tangent returns the rate of change of the point with the parameter t,
+	// which points along the curve but isn't normalized.
+	Tangent(t Real) Vector3
+
+	// End returns the parameter at the end of the curve.
+	End() Real
+}
+
+// Bezier is a cubic Bézier curve that starts at the first point, ends at the
+// last and is pulled toward the two in between.
+type Bezier [4]Vector3
+
+// Point returns the point on the curve at t, from zero to one.
+func (b *Bezier) Point(t Real) Vector3 {
+	s := 1.0 - t
+	w0, w1, w2, w3 := s*s*s, 3.0*s*s*t, 3.0*s*t*t, t*t*t
+	var result Vector3
+	for i := 0; i < 3; i++ {
+		result[i] = w0*b[0][i] + w1*b[1][i] + w2*b[2][i] + w3*b[3][i]
+	}
+	return result
+}
+
+// Tangent returns the derivative of the curve at t, from zero to one.
+func (b *Bezier) Tangent(t Real) Vector3 {
+	s := 1.0 - t
+	w0, w1, w2 := 3.0*s*s, 6.0*s*t, 3.0*t*t
+	var result Vector3
+	for i := 0; i < 3; i++ {
+		result[i] = w0*(b[1][i]-b[0][i]) + w1*(b[2][i]-b[1][i]) + w2*(b[3][i]-b[2][i])
+	}
+	return result
+}
+
+// End returns one, the parameter at the end of the curve.
+func (b *Bezier) End() Real {
+	return 1.0
+}
+
+// CatmullRom is a Catmull-Rom spline, which passes through each of its points
+// in turn. The parameter goes up by one from each point to the next.
+type CatmullRom struct {
+	// Points holds the points the spline passes through.
+	Points []Vector3
+
+	// Closed joins the last point back to the first.
+	Closed bool
+}
+
+// Spans returns the number of pieces of curve between the points.
+func (c *CatmullRom) Spans() int {
+	count := len(c.Points)
+	if count < 2 {
+		return 0
+	}
+	if c.Closed {
+		return count
+	}
+	return count - 1
+}
+
+// End returns the parameter at the end of the spline, which is the number
+// of spans.
+func (c *CatmullRom) End() Real {
+	return Real(c.Spans())
+}
+
+// point returns the point at the index, wrapping around if the spline is
+// closed and repeating the end points if it's not.
+func (c *CatmullRom) point(i int) *Vector3 {
+	count := len(c.Points)
+	if c.Closed {
+		return &c.Points[(i%count+count)%count]
+	}
+	if i < 0 {
+		i = 0
+	} else if i >= count {
+		i = count - 1
+	}
+	return &c.Points[i]
+}
+
+// split returns the span the parameter falls in and how far along it it is.
+func (c *CatmullRom) split(t Real) (int, Real) {
+	spans := c.Spans()
+	if t <= 0.0 {
+		return 0, 0.0
+	}
+	if t >= Real(spans) {
+		return spans - 1, 1.0
+	}
+	span := int(t)
+	return span, t - Real(span)
+}
+
+// SpanPoint returns the point the fraction t of the way along a span.
+func (c *CatmullRom) SpanPoint(span int, t Real) Vector3 {
+	zero, one, two, three := c.point(span-1), c.point(span), c.point(span+1), c.point(span+2)
+	t2 := t * t
+	t3 := t2 * t
+	var result Vector3
+	for i := 0; i < 3; i++ {
+		result[i] = 0.5 * (2.0*one[i] +
+			(two[i]-zero[i])*t +
+			(2.0*zero[i]-5.0*one[i]+4.0*two[i]-three[i])*t2 +
+			(3.0*one[i]-zero[i]-3.0*two[i]+three[i])*t3)
+	}
+	return result
+}
+
+// SpanTangent returns the derivative of the spline the fraction t of the way
+// along a span.
+func (c *CatmullRom) SpanTangent(span int, t Real) Vector3 {
+	zero, one, two, three := c.point(span-1), c.point(span), c.point(span+1), c.point(span+2)
+	t2 := t * t
+	var result Vector3
+	for i := 0; i < 3; i++ {
+		result[i] = 0.5 * ((two[i] - zero[i]) +
+			2.0*(2.0*zero[i]-5.0*one[i]+4.0*two[i]-three[i])*t +
+			3.0*(3.0*one[i]-zero[i]-3.0*two[i]+three[i])*t2)
+	}
+	return result
+}
+
+// Point returns the point on the spline at t, from zero to End. A spline of
+// a single point is just that point.
+func (c *CatmullRom) Point(t Real) Vector3 {
+	if len(c.Points) < 2 {
+		if len(c.Points) == 1 {
+			return c.Points[0]
+		}
+		return Vector3{}
+	}
+	span, fraction := c.split(t)
+	return c.SpanPoint(span, fraction)
+}
+
+// Tangent returns the derivative of the spline at t, from zero to End.
+func (c *CatmullRom) Tangent(t Real) Vector3 {
+	if len(c.Points) < 2 {
+		return Vector3{}
+	}
+	span, fraction := c.split(t)
+	return c.SpanTangent(span, fraction)
+}
+
+// ArcLength maps distances along a curve to its parameter, so that it can be
+// moved along at a constant speed. The curve is measured as a number of
+// straight pieces when the ArcLength is made, so it must not change after.
+type ArcLength struct {
+	// Curve is the curve that was measured.
+	Curve Curve
+
+	// params and distances hold the parameter and the distance along the
+	// curve at the end of each piece.
+	params    []Real
+	distances []Real
+}
+
+// NewArcLength creates a new ArcLength for the curve, measured in the number
+// of straight pieces given.
+func NewArcLength(curve Curve, samples int) *ArcLength {
+	if samples < 1 {
+		samples = 1
+	}
+	a := new(ArcLength)
+	a.Curve = curve
+	a.params = make([]Real, samples+1)
+	a.distances = make([]Real, samples+1)
+
+	end := curve.End()
+	prev := curve.Point(0.0)
+	for i := 1; i <= samples; i++ {
+		t := end * Real(i) / Real(samples)
+		point := curve.Point(t)
+		step := point
+		step.Sub(&prev)
+		a.params[i] = t
+		a.distances[i] = a.distances[i-1] + step.Magnitude()
+		prev = point
+	}
+	return a
+}
+
+// Length returns the length of the curve.
+func (a *ArcLength) Length() Real {
+	return a.distances[len(a.distances)-1]
+}
+
+// Param returns the parameter of the curve the distance given along it.
+// Distances past either end are clamped to the ends.
+func (a *ArcLength) Param(distance Real) Real {
+	last := len(a.distances) - 1
+	if distance <= 0.0 {
+		return 0.0
+	}
+	if distance >= a.distances[last] {
+		return a.params[last]
+	}
+
+	// binary search for the piece the distance falls in
+	lo, hi := 0, last
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		if a.distances[mid] < distance {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	span := a.distances[hi] - a.distances[lo]
+	if span <= 0.0 {
+		return a.params[lo]
+	}
+	fraction := (distance - a.distances[lo]) / span
+	return a.params[lo] + (a.params[hi]-a.params[lo])*fraction
+}
+
+// PointAt returns the point the distance given along the curve and the
+// normalized direction the curve runs in there.
+func (a *ArcLength) PointAt(distance Real) (Vector3, Vector3) {
+	t := a.Param(distance)
+	tangent := a.Curve.Tangent(t)
+	tangent.Normalize()
+	return a.Curve.Point(t), tangent
+}
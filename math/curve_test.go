@@ -0,0 +1,81 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+import (
+	"testing"
+)
+
+func TestBezier(t *testing.T) {
+	b := Bezier{{0.0, 0.0, 0.0}, {1.0, 2.0, 0.0}, {3.0, 2.0, 0.0}, {4.0, 0.0, 0.0}}
+	if p := b.Point(0.0); p != b[0] {
+		t.Errorf("Bezier should start at its first point: %v", p)
+	}
+	if p := b.Point(1.0); p != b[3] {
+		t.Errorf("Bezier should end at its last point: %v", p)
+	}
+	if p := b.Point(0.5); !RealEqual(p[0], 2.0) || !RealEqual(p[1], 1.5) {
+		t.Errorf("Bezier midpoint is wrong: %v", p)
+	}
+	if d := b.Tangent(0.0); d != (Vector3{3.0, 6.0, 0.0}) {
+		t.Errorf("Bezier should leave toward its second point: %v", d)
+	}
+	if d := b.Tangent(0.5); !RealEqual(d[0], 4.5) || RealAbs(d[1]) > 1e-6 {
+		t.Errorf("Bezier tangent at the midpoint is wrong: %v", d)
+	}
+}
+
+func TestCatmullRom(t *testing.T) {
+	c := CatmullRom{Points: []Vector3{{0.0, 0.0, 0.0}, {1.0, 1.0, 0.0}, {2.0, 0.0, 0.0}, {3.0, 1.0, 0.0}}}
+	if c.Spans() != 3 || c.End() != 3.0 {
+		t.Errorf("Open spline should have 3 spans but has %d", c.Spans())
+	}
+	for i := range c.Points {
+		if p := c.Point(Real(i)); p != c.Points[i] {
+			t.Errorf("Spline should pass through point %d but gave %v", i, p)
+		}
+	}
+	if d := c.Tangent(1.0); !RealEqual(d[0], 1.0) || RealAbs(d[1]) > 1e-6 {
+		t.Errorf("Spline tangent at a point should run from its neighbours: %v", d)
+	}
+
+	// the tangent should match the change in the point
+	const h = 1e-3
+	for _, at := range []Real{0.25, 1.5, 2.75} {
+		before, after := c.Point(at-h), c.Point(at+h)
+		d := c.Tangent(at)
+		for i := 0; i < 3; i++ {
+			if RealAbs((after[i]-before[i])/(2.0*h)-d[i]) > 1e-2 {
+				t.Errorf("Spline tangent at %v doesn't match the curve: %v", at, d)
+			}
+		}
+	}
+
+	c.Closed = true
+	if c.Spans() != 4 {
+		t.Errorf("Closed spline should have 4 spans but has %d", c.Spans())
+	}
+	if p := c.Point(c.End()); p != c.Points[0] {
+		t.Errorf("Closed spline should end where it started but gave %v", p)
+	}
+}
+
+func TestArcLength(t *testing.T) {
+	// a Bezier with its control points spaced unevenly along a line moves at
+	// a changing speed, which the arc length table should undo
+	b := Bezier{{0.0, 0.0, 0.0}, {0.5, 0.0, 0.0}, {1.0, 0.0, 0.0}, {10.0, 0.0, 0.0}}
+	a := NewArcLength(&b, 512)
+	if !RealEqual(a.Length(), 10.0) {
+		t.Errorf("Length of the line should be 10 but is %v", a.Length())
+	}
+	for _, distance := range []Real{0.0, 2.5, 5.0, 7.5, 10.0} {
+		p, dir := a.PointAt(distance)
+		if RealAbs(p[0]-distance) > 1e-2 || dir != (Vector3{1.0, 0.0, 0.0}) {
+			t.Errorf("Point %v along the line is wrong: %v %v", distance, p, dir)
+		}
+	}
+	if a.Param(-1.0) != 0.0 || a.Param(11.0) != 1.0 {
+		t.Errorf("Distances past the ends should clamp to the ends")
+	}
+}
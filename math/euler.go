@@ -0,0 +1,148 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+import (
+	"fmt"
+	"math"
+)
+
+// EulerOrder is the order the three rotations of a set of Euler angles are
+// made in. Each rotation is about the World Space axis, so EulerXYZ rotates
+// about X first, then Y, then Z; that's the same as rotating about the body's
+// own Z axis first, then its Y, then its X.
+type EulerOrder int
+
+const (
+	// EulerXYZ rotates about X, then Y, then Z.
+	EulerXYZ EulerOrder = iota
+
+	// EulerZYX rotates about Z, then Y, then X.
+	EulerZYX
+
+	// EulerYXZ rotates about Y, then X, then Z.
+	EulerYXZ
+)
+
+// eulerAxes holds the axis of the first, second and third rotation of each
+// order.
+var eulerAxes = [...][3]int{
+	EulerXYZ: {0, 1, 2},
+	EulerZYX: {2, 1, 0},
+	EulerYXZ: {1, 0, 2},
+}
+
+// String returns the name of the order, such as "XYZ".
+func (o EulerOrder) String() string {
+	switch o {
+	case EulerXYZ:
+		return "XYZ"
+	case EulerZYX:
+		return "ZYX"
+	case EulerYXZ:
+		return "YXZ"
+	}
+	return fmt.Sprintf("EulerOrder(%d)", int(o))
+}
+
+// ParseEulerOrder returns the order with the name given, such as "XYZ".
+func ParseEulerOrder(name string) (EulerOrder, error) {
+	switch name {
+	case "XYZ":
+		return EulerXYZ, nil
+	case "ZYX":
+		return EulerZYX, nil
+	case "YXZ":
+		return EulerYXZ, nil
+	}
+	return EulerXYZ, fmt.Errorf("unknown Euler order %q", name)
+}
+
+// QuatFromEuler creates a quaternion from the Euler angles given, in radians,
+// as the rotations about the X, Y and Z axes made in the order given.
+func QuatFromEuler(angles *Vector3, order EulerOrder) Quat {
+	var axes [3]Vector3
+	for i := range axes {
+		axes[i][i] = 1.0
+	}
+	turns := eulerAxes[order]
+	first := QuatFromAxisAngle(&axes[turns[0]], angles[turns[0]])
+	second := QuatFromAxisAngle(&axes[turns[1]], angles[turns[1]])
+	result := QuatFromAxisAngle(&axes[turns[2]], angles[turns[2]])
+
+	// the last rotation made goes on the left
+	result.Mul(&second)
+	result.Mul(&first)
+	return result
+}
+
+// ToEuler returns the rotations about the X, Y and Z axes, in radians, that
+// make up the quaternion when made in the order given. The quaternion must
+// be normalized.
+func (q *Quat) ToEuler(order EulerOrder) Vector3 {
+	var m Matrix3
+	m.SetOrientation(q)
+	return m.ToEuler(order)
+}
+
+// SetEuler sets the matrix to the rotation made by the Euler angles given,
+// in radians, as the rotations about the X, Y and Z axes made in the order
+// given.
+func (m *Matrix3) SetEuler(angles *Vector3, order EulerOrder) {
+	q := QuatFromEuler(angles, order)
+	m.SetOrientation(&q)
+}
+
+// ToEuler returns the rotations about the X, Y and Z axes, in radians, that
+// make up the rotation matrix when made in the order given. The second
+// rotation is kept within plus or minus a quarter turn. When it's a quarter
+// turn the other two rotations turn about the same axis, so the last one is
+// returned as zero.
+func (m *Matrix3) ToEuler(order EulerOrder) Vector3 {
+	turns := eulerAxes[order]
+	i, j, k := turns[0], turns[1], turns[2]
+
+	// the signs flip when the axes don't run in the X, Y, Z cycle
+	sign := Real(1.0)
+	if (j-i+3)%3 != 1 {
+		sign = -1.0
+	}
+
+	// element returns the matrix element in the row and column given
+	element := func(row, col int) Real {
+		return m[col*3+row]
+	}
+
+	var result Vector3
+	s := -sign * element(k, i)
+	if s > 1.0 {
+		s = 1.0
+	} else if s < -1.0 {
+		s = -1.0
+	}
+	result[j] = Real(math.Asin(float64(s)))
+
+	if RealAbs(s) < 1.0-Epsilon {
+		result[i] = Real(math.Atan2(float64(sign*element(k, j)), float64(element(k, k))))
+		result[k] = Real(math.Atan2(float64(sign*element(j, i)), float64(element(i, i))))
+	} else {
+		result[i] = Real(math.Atan2(float64(-sign*element(j, k)), float64(element(j, j))))
+	}
+	return result
+}
+
+// SetEuler sets the rotation of the transform matrix to the one made by the
+// Euler angles given, leaving the position alone.
+func (m *Matrix3x4) SetEuler(angles *Vector3, order EulerOrder) {
+	var basis Matrix3
+	basis.SetEuler(angles, order)
+	m.SetMatrix3(&basis)
+}
+
+// ToEuler returns the Euler angles, in radians, of the rotation of the
+// transform matrix when made in the order given.
+func (m *Matrix3x4) ToEuler(order EulerOrder) Vector3 {
+	basis := m.GetMatrix3()
+	return basis.ToEuler(order)
+}
@@ -0,0 +1,104 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestEulerKnownRotations(t *testing.T) {
+	// a quarter turn about Y takes X to -Z whatever the order
+	angles := Vector3{0.0, DegToRad(90), 0.0}
+	for _, order := range []EulerOrder{EulerXYZ, EulerZYX, EulerYXZ} {
+		q := QuatFromEuler(&angles, order)
+		v := q.Rotate(&Vector3{1.0, 0.0, 0.0})
+		if RealAbs(v[0]) > 1e-5 || RealAbs(v[2]+1.0) > 1e-5 {
+			t.Errorf("%v quarter turn about Y gave %v", order, v)
+		}
+	}
+
+	// XYZ makes the turn about X first: Y goes to Z and then Z goes to -X
+	angles = Vector3{DegToRad(90), DegToRad(90), 0.0}
+	q := QuatFromEuler(&angles, EulerXYZ)
+	v := q.Rotate(&Vector3{0.0, 1.0, 0.0})
+	if RealAbs(v[0]-1.0) > 1e-5 || RealAbs(v[1]) > 1e-5 || RealAbs(v[2]) > 1e-5 {
+		t.Errorf("XYZ rotation of Y gave %v", v)
+	}
+
+	// ZYX makes the turn about Y first, which leaves Y alone, and then the
+	// turn about X takes it to Z
+	q = QuatFromEuler(&angles, EulerZYX)
+	v = q.Rotate(&Vector3{0.0, 1.0, 0.0})
+	if RealAbs(v[0]) > 1e-5 || RealAbs(v[1]) > 1e-5 || RealAbs(v[2]-1.0) > 1e-5 {
+		t.Errorf("ZYX rotation of Y gave %v", v)
+	}
+}
+
+func TestEulerRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	for _, order := range []EulerOrder{EulerXYZ, EulerZYX, EulerYXZ} {
+		middle := eulerAxes[order][1]
+		for n := 0; n < 100; n++ {
+			var angles Vector3
+			for i := range angles {
+				angles[i] = Real(r.Float64()*2.0-1.0) * 3.0
+			}
+			angles[middle] = Real(r.Float64()*2.0-1.0) * 1.5
+
+			q := QuatFromEuler(&angles, order)
+			back := q.ToEuler(order)
+			for i := 0; i < 3; i++ {
+				if RealAbs(back[i]-angles[i]) > 1e-4 {
+					t.Fatalf("%v round trip of %v gave %v", order, angles, back)
+				}
+			}
+
+			var transform Matrix3x4
+			transform.SetEuler(&angles, order)
+			fromMatrix := transform.ToEuler(order)
+			for i := 0; i < 3; i++ {
+				if RealAbs(fromMatrix[i]-angles[i]) > 1e-4 {
+					t.Fatalf("%v matrix round trip of %v gave %v", order, angles, fromMatrix)
+				}
+			}
+		}
+	}
+}
+
+func TestEulerGimbalLock(t *testing.T) {
+	// at a quarter turn in the middle the first and last rotations are about
+	// the same axis, so any split of them is the same rotation
+	for _, order := range []EulerOrder{EulerXYZ, EulerZYX, EulerYXZ} {
+		var angles Vector3
+		angles[eulerAxes[order][0]] = 0.3
+		angles[eulerAxes[order][1]] = DegToRad(90)
+		angles[eulerAxes[order][2]] = 0.5
+		q := QuatFromEuler(&angles, order)
+		back := q.ToEuler(order)
+		if back[eulerAxes[order][2]] != 0.0 {
+			t.Errorf("%v gimbal lock should give a last rotation of zero: %v", order, back)
+		}
+		again := QuatFromEuler(&back, order)
+		for _, v := range []Vector3{{1.0, 0.0, 0.0}, {0.0, 1.0, 0.0}} {
+			a, b := q.Rotate(&v), again.Rotate(&v)
+			for i := 0; i < 3; i++ {
+				if RealAbs(a[i]-b[i]) > 1e-3 {
+					t.Errorf("%v gimbal lock angles %v don't give the same rotation", order, back)
+				}
+			}
+		}
+	}
+}
+
+func TestParseEulerOrder(t *testing.T) {
+	for _, order := range []EulerOrder{EulerXYZ, EulerZYX, EulerYXZ} {
+		if parsed, err := ParseEulerOrder(order.String()); err != nil || parsed != order {
+			t.Errorf("Failed to parse %v: %v", order, err)
+		}
+	}
+	if _, err := ParseEulerOrder("XZY"); err == nil {
+		t.Errorf("Parsing an unsupported order should fail")
+	}
+}
@@ -6,6 +6,14 @@
 The math module of this project defines the floating point precision to
 be used and the mathematical types to be used.
 
+The precision is chosen in one place, real64.go or real32.go, which hold
+the Real type that everything else in the library is written against
+along with the constants that depend on its precision. Real is a float64
+unless the library is built with the cubez_f32 tag, which makes it a
+float32. The rest of the package and the engine only convert to and from
+float64 explicitly when calling into the standard library, so they work
+with either.
+
 From there it also defines mathematial operations on vectors, matrixes
 and quaternions that operate by reference.
 
@@ -19,25 +27,6 @@ import (
 	"math"
 )
 
-// Real is the type of float used in the library.
-type Real float64
-
-// Espilon is used to test equality of the floats and represents how
-// close two Reals can be and still test positive for equality.
-const Epsilon Real = 1e-7
-
-const (
-	MinNormal = Real(1.1754943508222875e-38) // 1 / 2**(127 - 1)
-	MinValue  = Real(math.SmallestNonzeroFloat64)
-	MaxValue  = Real(math.MaxFloat64)
-)
-
-var (
-	InfPos = Real(math.Inf(1))
-	InfNeg = Real(math.Inf(-1))
-	NaN    = Real(math.NaN())
-)
-
 // Matrix3 is a 3x3 matrix of floats in column-major order.
 type Matrix3 [9]Real
 
@@ -71,7 +60,7 @@ func RealEqual(a, b Real) bool {
 
 	// if a or b is 0 or really close to it
 	if a*b == 0 || diff < MinNormal {
-		return diff < Epsilon*Epsilon
+		return diff < zeroEpsilon
 	}
 
 	return diff/Real(math.Abs(float64(a))+math.Abs(float64(b))) < Epsilon
@@ -111,3 +100,8 @@ func RealCos(a Real) Real {
 func RealIsNaN(a Real) bool {
 	return math.IsNaN(float64(a))
 }
+
+// RealIsFinite returns true if the value is neither NaN nor an infinity.
+func RealIsFinite(a Real) bool {
+	return !math.IsNaN(float64(a)) && !math.IsInf(float64(a), 0)
+}
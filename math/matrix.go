@@ -3,6 +3,8 @@
 
 package math
 
+import "math"
+
 // Sanity conversion charts between different ordering:
 //
 //  col major        row major     col maj 4x4    row major    col major
@@ -76,9 +78,69 @@ func (m *Matrix3) SetBlockInertiaTensor(halfSize *Vector3, mass Real) {
 	)
 }
 
+// SetSphereInertiaTensor sets the value of the matrix as an inertia tensor
+// of a solid sphere with the given radius and mass.
+func (m *Matrix3) SetSphereInertiaTensor(radius Real, mass Real) {
+	i := 0.4 * mass * radius * radius
+	m.SetInertiaTensorCoeffs(i, i, i, 0.0, 0.0, 0.0)
+}
+
+// SetHollowSphereInertiaTensor sets the value of the matrix as an inertia tensor
+// of a thin walled hollow sphere with the given radius and mass.
+func (m *Matrix3) SetHollowSphereInertiaTensor(radius Real, mass Real) {
+	i := 2.0 / 3.0 * mass * radius * radius
+	m.SetInertiaTensorCoeffs(i, i, i, 0.0, 0.0, 0.0)
+}
+
+// SetCylinderInertiaTensor sets the value of the matrix as an inertia tensor
+// of a solid cylinder aligned with the body's Y axis with the given radius,
+// half of the height and mass.
+func (m *Matrix3) SetCylinderInertiaTensor(radius Real, halfHeight Real, mass Real) {
+	height := 2.0 * halfHeight
+	side := mass * (3.0*radius*radius + height*height) / 12.0
+	m.SetInertiaTensorCoeffs(side, 0.5*mass*radius*radius, side, 0.0, 0.0, 0.0)
+}
+
+// SetCapsuleInertiaTensor sets the value of the matrix as an inertia tensor
+// of a solid capsule aligned with the body's Y axis. The radius is that of the
+// hemispherical caps and halfHeight is half the height of the cylinder between them.
+func (m *Matrix3) SetCapsuleInertiaTensor(radius Real, halfHeight Real, mass Real) {
+	height := 2.0 * halfHeight
+	r2 := radius * radius
+
+	// split the mass between the cylinder and the two caps by volume
+	cylinderVolume := math.Pi * r2 * height
+	capsVolume := 4.0 / 3.0 * math.Pi * r2 * radius
+	cylinderMass := mass * cylinderVolume / (cylinderVolume + capsVolume)
+	capsMass := mass - cylinderMass
+
+	axial := cylinderMass*r2*0.5 + capsMass*r2*0.4
+	side := cylinderMass*(r2*0.25+height*height/12.0) +
+		capsMass*(r2*0.4+height*height*0.25+3.0*height*radius/8.0)
+	m.SetInertiaTensorCoeffs(side, axial, side, 0.0, 0.0, 0.0)
+}
+
+// SetConeInertiaTensor sets the value of the matrix as an inertia tensor of a
+// solid cone aligned with the body's Y axis with the given base radius, height
+// and mass. The tensor is about the cone's center of mass, which lies a quarter
+// of the height above the base.
+func (m *Matrix3) SetConeInertiaTensor(radius Real, height Real, mass Real) {
+	axial := 0.3 * mass * radius * radius
+	side := mass * (3.0*radius*radius/20.0 + 3.0*height*height/80.0)
+	m.SetInertiaTensorCoeffs(side, axial, side, 0.0, 0.0, 0.0)
+}
+
 // MulVector3 multiplies a 3x3 matrix by a vector.
 func (m *Matrix3) MulVector3(v *Vector3) Vector3 {
-	return Vector3{
+	var result Vector3
+	m.MulVector3Into(&result, v)
+	return result
+}
+
+// MulVector3Into sets dst to the 3x3 matrix multiplied by a vector without
+// returning a copy. dst may be the vector.
+func (m *Matrix3) MulVector3Into(dst *Vector3, v *Vector3) {
+	*dst = Vector3{
 		m[0]*v[0] + m[3]*v[1] + m[6]*v[2],
 		m[1]*v[0] + m[4]*v[1] + m[7]*v[2],
 		m[2]*v[0] + m[5]*v[1] + m[8]*v[2],
@@ -87,7 +149,15 @@ func (m *Matrix3) MulVector3(v *Vector3) Vector3 {
 
 // MulMatrix3 multiplies a 3x3 matrix by another 3x3 matrix.
 func (m1 *Matrix3) MulMatrix3(m2 *Matrix3) Matrix3 {
-	return Matrix3{
+	var result Matrix3
+	m1.MulMatrix3Into(&result, m2)
+	return result
+}
+
+// MulMatrix3Into sets dst to the 3x3 matrix multiplied by another without
+// returning a copy. dst may be either matrix.
+func (m1 *Matrix3) MulMatrix3Into(dst *Matrix3, m2 *Matrix3) {
+	*dst = Matrix3{
 		m1[0]*m2[0] + m1[3]*m2[1] + m1[6]*m2[2],
 		m1[1]*m2[0] + m1[4]*m2[1] + m1[7]*m2[2],
 		m1[2]*m2[0] + m1[5]*m2[1] + m1[8]*m2[2],
@@ -155,13 +225,96 @@ func (m *Matrix3) Invert() Matrix3 {
 // TransformTranspose transforms the given vector by the transpose
 // of this matrix
 func (m *Matrix3) TransformTranspose(v *Vector3) Vector3 {
-	return Vector3{
+	var result Vector3
+	m.TransformTransposeInto(&result, v)
+	return result
+}
+
+// TransformTransposeInto sets dst to the vector transformed by the transpose
+// of this matrix without returning a copy. dst may be the vector.
+func (m *Matrix3) TransformTransposeInto(dst *Vector3, v *Vector3) {
+	*dst = Vector3{
 		v[0]*m[0] + v[1]*m[1] + v[2]*m[2],
 		v[0]*m[3] + v[1]*m[4] + v[2]*m[5],
 		v[0]*m[6] + v[1]*m[7] + v[2]*m[8],
 	}
 }
 
+// TransposeMulMatrix3 multiplies the transpose of this matrix by another
+// 3x3 matrix, without building the transpose.
+func (m1 *Matrix3) TransposeMulMatrix3(m2 *Matrix3) Matrix3 {
+	return Matrix3{
+		m1[0]*m2[0] + m1[1]*m2[1] + m1[2]*m2[2],
+		m1[3]*m2[0] + m1[4]*m2[1] + m1[5]*m2[2],
+		m1[6]*m2[0] + m1[7]*m2[1] + m1[8]*m2[2],
+		m1[0]*m2[3] + m1[1]*m2[4] + m1[2]*m2[5],
+		m1[3]*m2[3] + m1[4]*m2[4] + m1[5]*m2[5],
+		m1[6]*m2[3] + m1[7]*m2[4] + m1[8]*m2[5],
+		m1[0]*m2[6] + m1[1]*m2[7] + m1[2]*m2[8],
+		m1[3]*m2[6] + m1[4]*m2[7] + m1[5]*m2[8],
+		m1[6]*m2[6] + m1[7]*m2[7] + m1[8]*m2[8],
+	}
+}
+
+// MulTransposeMatrix3 multiplies this matrix by the transpose of another
+// 3x3 matrix, without building the transpose.
+func (m1 *Matrix3) MulTransposeMatrix3(m2 *Matrix3) Matrix3 {
+	return Matrix3{
+		m1[0]*m2[0] + m1[3]*m2[3] + m1[6]*m2[6],
+		m1[1]*m2[0] + m1[4]*m2[3] + m1[7]*m2[6],
+		m1[2]*m2[0] + m1[5]*m2[3] + m1[8]*m2[6],
+		m1[0]*m2[1] + m1[3]*m2[4] + m1[6]*m2[7],
+		m1[1]*m2[1] + m1[4]*m2[4] + m1[7]*m2[7],
+		m1[2]*m2[1] + m1[5]*m2[4] + m1[8]*m2[7],
+		m1[0]*m2[2] + m1[3]*m2[5] + m1[6]*m2[8],
+		m1[1]*m2[2] + m1[4]*m2[5] + m1[7]*m2[8],
+		m1[2]*m2[2] + m1[5]*m2[5] + m1[8]*m2[8],
+	}
+}
+
+// GetColumn returns a column of the matrix, which for a rotation matrix is
+// the direction of that axis.
+func (m *Matrix3) GetColumn(i int) Vector3 {
+	return Vector3{m[i*3+0], m[i*3+1], m[i*3+2]}
+}
+
+// GetRow returns a row of the matrix.
+func (m *Matrix3) GetRow(i int) Vector3 {
+	return Vector3{m[i], m[i+3], m[i+6]}
+}
+
+// Orthonormalize makes the columns of the matrix unit length and at right
+// angles to each other, keeping the direction of the first column and the
+// plane of the first two. It's used to remove the drift that builds up in a
+// rotation matrix after many updates.
+func (m *Matrix3) Orthonormalize() {
+	x := m.GetColumn(0)
+	y := m.GetColumn(1)
+	x.Normalize()
+	z := x.Cross(&y)
+	z.Normalize()
+	y = z.Cross(&x)
+	m.SetComponents(&x, &y, &z)
+}
+
+// SetOrientation sets the matrix to the rotation the quaternion represents.
+// The quaternion must be normalized.
+func (m *Matrix3) SetOrientation(q *Quat) {
+	w, x, y, z := q[0], q[1], q[2], q[3]
+
+	m[0] = 1 - 2*y*y - 2*z*z
+	m[1] = 2*x*y + 2*w*z
+	m[2] = 2*x*z - 2*w*y
+
+	m[3] = 2*x*y - 2*w*z
+	m[4] = 1 - 2*x*x - 2*z*z
+	m[5] = 2*y*z + 2*w*x
+
+	m[6] = 2*x*z + 2*w*y
+	m[7] = 2*y*z - 2*w*x
+	m[8] = 1 - 2*x*x - 2*y*y
+}
+
 // SetAsTransform sets the 3x4 matrix to be a transform matrix based
 // on the position and orientation passed in.
 func (m *Matrix3x4) SetAsTransform(pos *Vector3, rot *Quat) {
@@ -186,7 +339,15 @@ func (m *Matrix3x4) SetAsTransform(pos *Vector3, rot *Quat) {
 
 // MulVector3 transforms the given vector by the matrix and returns the result.
 func (m *Matrix3x4) MulVector3(v *Vector3) Vector3 {
-	return Vector3{
+	var result Vector3
+	m.MulVector3Into(&result, v)
+	return result
+}
+
+// MulVector3Into sets dst to the vector transformed by the matrix without
+// returning a copy. dst may be the vector.
+func (m *Matrix3x4) MulVector3Into(dst *Vector3, v *Vector3) {
+	*dst = Vector3{
 		v[0]*m[0] + v[1]*m[3] + v[2]*m[6] + m[9],
 		v[0]*m[1] + v[1]*m[4] + v[2]*m[7] + m[10],
 		v[0]*m[2] + v[1]*m[5] + v[2]*m[8] + m[11],
@@ -196,7 +357,15 @@ func (m *Matrix3x4) MulVector3(v *Vector3) Vector3 {
 // Mul3x4 multiplies a 3x4 matrix by another 3x4 matrix. This operation is meant
 // to mimic a 4x4 * 4x4 operation where the last row is {0, 0, 0, 1}.
 func (m *Matrix3x4) MulMatrix3x4(o *Matrix3x4) Matrix3x4 {
-	return Matrix3x4{
+	var result Matrix3x4
+	m.MulMatrix3x4Into(&result, o)
+	return result
+}
+
+// MulMatrix3x4Into sets dst to the 3x4 matrix multiplied by another without
+// returning a copy. dst may be either matrix.
+func (m *Matrix3x4) MulMatrix3x4Into(dst *Matrix3x4, o *Matrix3x4) {
+	*dst = Matrix3x4{
 		m[0]*o[0] + m[3]*o[1] + m[6]*o[2], // [0]
 		m[1]*o[0] + m[4]*o[1] + m[7]*o[2], // [1]
 		m[2]*o[0] + m[5]*o[1] + m[8]*o[2], // [2]
@@ -212,7 +381,6 @@ func (m *Matrix3x4) MulMatrix3x4(o *Matrix3x4) Matrix3x4 {
 		m[0]*o[9] + m[3]*o[10] + m[6]*o[11] + m[9],  // [9]
 		m[1]*o[9] + m[4]*o[10] + m[7]*o[11] + m[10], // [10]
 		m[2]*o[9] + m[5]*o[10] + m[8]*o[11] + m[11], // [11]
-
 	}
 }
 
@@ -232,6 +400,8 @@ func (m *Matrix3x4) TransformInverse(v *Vector3) Vector3 {
 	}
 }
 
+// GetAxis returns a column of the matrix: the direction of an axis for the
+// first three and the position for the fourth.
 func (m *Matrix3x4) GetAxis(colNumber int) Vector3 {
 	var i int
 	if colNumber > 3 {
@@ -245,3 +415,90 @@ func (m *Matrix3x4) GetAxis(colNumber int) Vector3 {
 		m[i*3+2],
 	}
 }
+
+// TransformDirection transforms the given direction vector by the rotational
+// part of this matrix, ignoring the translation.
+func (m *Matrix3x4) TransformDirection(v *Vector3) Vector3 {
+	var result Vector3
+	m.TransformDirectionInto(&result, v)
+	return result
+}
+
+// TransformDirectionInto sets dst to the direction transformed by the
+// rotational part of this matrix without returning a copy. dst may be the
+// direction.
+func (m *Matrix3x4) TransformDirectionInto(dst *Vector3, v *Vector3) {
+	*dst = Vector3{
+		v[0]*m[0] + v[1]*m[3] + v[2]*m[6],
+		v[0]*m[1] + v[1]*m[4] + v[2]*m[7],
+		v[0]*m[2] + v[1]*m[5] + v[2]*m[8],
+	}
+}
+
+// TransformInverseDirection transforms the given direction vector by the
+// transformational inverse of the rotational part of this matrix.
+// NOTE: will not work on matrixes with scale or shears.
+func (m *Matrix3x4) TransformInverseDirection(v *Vector3) Vector3 {
+	var result Vector3
+	m.TransformInverseDirectionInto(&result, v)
+	return result
+}
+
+// TransformInverseDirectionInto sets dst to the direction transformed by the
+// inverse of the rotational part of this matrix without returning a copy.
+// dst may be the direction.
+func (m *Matrix3x4) TransformInverseDirectionInto(dst *Vector3, v *Vector3) {
+	*dst = Vector3{
+		v[0]*m[0] + v[1]*m[1] + v[2]*m[2],
+		v[0]*m[3] + v[1]*m[4] + v[2]*m[5],
+		v[0]*m[6] + v[1]*m[7] + v[2]*m[8],
+	}
+}
+
+// GetMatrix3 returns the rotational part of the matrix as a 3x3 matrix.
+func (m *Matrix3x4) GetMatrix3() Matrix3 {
+	return Matrix3{m[0], m[1], m[2], m[3], m[4], m[5], m[6], m[7], m[8]}
+}
+
+// GetPosition returns the translation of the matrix.
+func (m *Matrix3x4) GetPosition() Vector3 {
+	return Vector3{m[9], m[10], m[11]}
+}
+
+// SetMatrix3 sets the rotational part of the matrix, keeping its translation.
+func (m *Matrix3x4) SetMatrix3(basis *Matrix3) {
+	copy(m[:9], basis[:])
+}
+
+// Determinant calculates the determinant of the rotational part of the matrix.
+func (m *Matrix3x4) Determinant() Real {
+	basis := m.GetMatrix3()
+	return basis.Determinant()
+}
+
+// Invert computes the inverse of the transform, which unlike TransformInverse
+// works on matrixes with scale or shears. A matrix that can't be inverted
+// gives the zero matrix.
+func (m *Matrix3x4) Invert() Matrix3x4 {
+	basis := m.GetMatrix3()
+	inverse := basis.Invert()
+	if inverse == (Matrix3{}) {
+		return Matrix3x4{}
+	}
+
+	position := m.GetPosition()
+	position = inverse.MulVector3(&position)
+
+	var result Matrix3x4
+	result.SetMatrix3(&inverse)
+	result[9], result[10], result[11] = -position[0], -position[1], -position[2]
+	return result
+}
+
+// Orthonormalize removes any scale and shear from the rotational part of the
+// matrix. See Matrix3.Orthonormalize.
+func (m *Matrix3x4) Orthonormalize() {
+	basis := m.GetMatrix3()
+	basis.Orthonormalize()
+	m.SetMatrix3(&basis)
+}
@@ -51,3 +51,195 @@ func TestMat3Multiplications(t *testing.T) {
 		t.Errorf("Multiplication by it's inversion does not yield identity:\n\t%v", m3)
 	}
 }
+
+func TestMat3x4TransformDirection(t *testing.T) {
+	var m1 Matrix3x4
+	pos := Vector3{5.0, -2.0, 3.0}
+	rot := QuatFromAxis(DegToRad(90), 0.0, 1.0, 0.0)
+	m1.SetAsTransform(&pos, &rot)
+
+	v := Vector3{1.0, 0.0, 0.0}
+	dir := m1.TransformDirection(&v)
+	if !RealEqual(dir[0], 0.0) || !RealEqual(dir[1], 0.0) || !RealEqual(dir[2], -1.0) {
+		t.Errorf("TransformDirection did not rotate the vector properly:\n\t%v", dir)
+	}
+
+	back := m1.TransformInverseDirection(&dir)
+	if !RealEqual(back[0], 1.0) || !RealEqual(back[1], 0.0) || !RealEqual(back[2], 0.0) {
+		t.Errorf("TransformInverseDirection did not undo the rotation properly:\n\t%v", back)
+	}
+}
+
+func TestMat3InertiaTensors(t *testing.T) {
+	var m1 Matrix3
+	m1.SetSphereInertiaTensor(2.0, 5.0)
+	if !RealEqual(m1[0], 8.0) || !RealEqual(m1[4], 8.0) || !RealEqual(m1[8], 8.0) || !RealEqual(m1[1], 0.0) {
+		t.Errorf("Sphere inertia tensor is incorrect:\n\t%v", m1)
+	}
+
+	m1.SetCylinderInertiaTensor(1.0, 1.0, 12.0)
+	if !RealEqual(m1[0], 7.0) || !RealEqual(m1[4], 6.0) || !RealEqual(m1[8], 7.0) {
+		t.Errorf("Cylinder inertia tensor is incorrect:\n\t%v", m1)
+	}
+
+	// a capsule with no cylinder is a sphere
+	var m2 Matrix3
+	m1.SetCapsuleInertiaTensor(2.0, 0.0, 5.0)
+	m2.SetSphereInertiaTensor(2.0, 5.0)
+	for i := range m1 {
+		if !RealEqual(m1[i], m2[i]) {
+			t.Errorf("Capsule inertia tensor without a cylinder doesn't match a sphere:\n\t%v", m1)
+			break
+		}
+	}
+}
+
+func TestMat3TransposeMultiplications(t *testing.T) {
+	m1 := Matrix3{1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0, 8.0, 10.0}
+	m2 := Matrix3{0.5, -1.0, 2.0, 3.0, 0.0, 1.0, -2.0, 4.0, 1.5}
+
+	m1T := m1.Transpose()
+	m2T := m2.Transpose()
+	if m1.TransposeMulMatrix3(&m2) != m1T.MulMatrix3(&m2) {
+		t.Errorf("TransposeMulMatrix3 doesn't match multiplying by the transpose")
+	}
+	if m1.MulTransposeMatrix3(&m2) != m1.MulMatrix3(&m2T) {
+		t.Errorf("MulTransposeMatrix3 doesn't match multiplying by the transpose")
+	}
+	if m1.GetColumn(1) != (Vector3{4.0, 5.0, 6.0}) || m1.GetRow(1) != (Vector3{2.0, 5.0, 8.0}) {
+		t.Errorf("Columns or rows are wrong: %v %v", m1.GetColumn(1), m1.GetRow(1))
+	}
+}
+
+func TestMat3Orthonormalize(t *testing.T) {
+	// a rotation that has drifted with some scale and shear
+	m1 := Matrix3{
+		1.1, 0.05, 0.0,
+		0.1, 0.9, 0.02,
+		0.0, -0.03, 1.2,
+	}
+	m1.Orthonormalize()
+
+	product := m1.TransposeMulMatrix3(&m1)
+	for i := 0; i < 9; i++ {
+		expected := Real(0.0)
+		if i%4 == 0 {
+			expected = 1.0
+		}
+		if RealAbs(product[i]-expected) > 1e-6 {
+			t.Fatalf("Orthonormalized matrix isn't orthonormal:\n\t%v", m1)
+		}
+	}
+	if !RealEqual(m1.Determinant(), 1.0) {
+		t.Errorf("Orthonormalized matrix should have a determinant of one: %v", m1.Determinant())
+	}
+	x := m1.GetColumn(0)
+	if RealAbs(x[1]/x[0]-0.05/1.1) > 1e-6 || x[2] != 0.0 {
+		t.Errorf("Orthonormalize should keep the direction of the first column: %v", x)
+	}
+}
+
+func TestMat3x4Invert(t *testing.T) {
+	var m1 Matrix3x4
+	pos := Vector3{5.0, -2.0, 3.0}
+	rot := QuatFromAxis(DegToRad(30), 1.0, 0.0, 0.0)
+	m1.SetAsTransform(&pos, &rot)
+
+	// scale it so that TransformInverse wouldn't work
+	basis := m1.GetMatrix3()
+	basis.MulWith(2.0)
+	m1.SetMatrix3(&basis)
+	if !RealEqual(m1.Determinant(), 8.0) || m1.GetPosition() != pos {
+		t.Errorf("Scaling the basis gave the wrong matrix:\n\t%v", m1)
+	}
+
+	inverse := m1.Invert()
+	v := Vector3{1.0, 2.0, -3.0}
+	moved := m1.MulVector3(&v)
+	back := inverse.MulVector3(&moved)
+	for i := 0; i < 3; i++ {
+		if RealAbs(back[i]-v[i]) > 1e-6 {
+			t.Fatalf("Invert did not undo the transform:\n\t%v", back)
+		}
+	}
+
+	var singular Matrix3x4
+	if singular.Invert() != (Matrix3x4{}) {
+		t.Errorf("A singular matrix should invert to the zero matrix")
+	}
+
+	m1.Orthonormalize()
+	if !RealEqual(m1.Determinant(), 1.0) || m1.GetPosition() != pos {
+		t.Errorf("Orthonormalize should remove the scale and keep the position:\n\t%v", m1)
+	}
+}
+
+func TestMatIntoVariants(t *testing.T) {
+	m1 := Matrix3{1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0, 8.0, 10.0}
+	m2 := Matrix3{0.5, -1.0, 2.0, 3.0, 0.0, 1.0, -2.0, 4.0, 1.5}
+	v := Vector3{1.0, -2.0, 0.5}
+
+	expected := m1.MulMatrix3(&m2)
+	aliased := m1
+	aliased.MulMatrix3Into(&aliased, &m2)
+	if aliased != expected {
+		t.Errorf("MulMatrix3Into into its own matrix gave %v instead of %v", aliased, expected)
+	}
+
+	expectedV := m1.MulVector3(&v)
+	aliasedV := v
+	m1.MulVector3Into(&aliasedV, &aliasedV)
+	if aliasedV != expectedV {
+		t.Errorf("MulVector3Into into its own vector gave %v instead of %v", aliasedV, expectedV)
+	}
+
+	var transform Matrix3x4
+	rot := QuatFromAxis(DegToRad(30), 0.0, 1.0, 0.0)
+	transform.SetAsTransform(&Vector3{1.0, 2.0, 3.0}, &rot)
+	for _, op := range []struct {
+		name     string
+		into     func(dst *Vector3, v *Vector3)
+		expected Vector3
+	}{
+		{"Matrix3.TransformTransposeInto", m1.TransformTransposeInto, m1.TransformTranspose(&v)},
+		{"Matrix3x4.MulVector3Into", transform.MulVector3Into, transform.MulVector3(&v)},
+		{"Matrix3x4.TransformDirectionInto", transform.TransformDirectionInto, transform.TransformDirection(&v)},
+		{"Matrix3x4.TransformInverseDirectionInto", transform.TransformInverseDirectionInto, transform.TransformInverseDirection(&v)},
+	} {
+		result := v
+		op.into(&result, &result)
+		if result != op.expected {
+			t.Errorf("%s gave %v instead of %v", op.name, result, op.expected)
+		}
+	}
+
+	expected3x4 := transform.MulMatrix3x4(&transform)
+	aliased3x4 := transform
+	aliased3x4.MulMatrix3x4Into(&aliased3x4, &transform)
+	if aliased3x4 != expected3x4 {
+		t.Errorf("MulMatrix3x4Into into its own matrix gave %v instead of %v", aliased3x4, expected3x4)
+	}
+}
+
+// sinks keep the compiler from optimizing away the benchmarked work
+var (
+	benchMatrix3 Matrix3
+	benchVector3 Vector3
+)
+
+func BenchmarkMat3Mul(b *testing.B) {
+	m1 := Matrix3{1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0, 8.0, 10.0}
+	m2 := Matrix3{0.5, -1.0, 2.0, 3.0, 0.0, 1.0, -2.0, 4.0, 1.5}
+	for i := 0; i < b.N; i++ {
+		m1.MulMatrix3Into(&benchMatrix3, &m2)
+	}
+}
+
+func BenchmarkMat3TransformTranspose(b *testing.B) {
+	// the change into contact coordinates done for each contact velocity
+	basis := Matrix3{0.0, 1.0, 0.0, 1.0, 0.0, 0.0, 0.0, 0.0, -1.0}
+	v := Vector3{1.0, -2.0, 0.5}
+	for i := 0; i < b.N; i++ {
+		basis.TransformTransposeInto(&benchVector3, &v)
+	}
+}
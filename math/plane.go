@@ -0,0 +1,115 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+// PlaneSide is the side of a plane that something is on.
+type PlaneSide int
+
+const (
+	// PlaneOn is within Epsilon of the plane.
+	PlaneOn PlaneSide = iota
+
+	// PlaneFront is the side the normal points to.
+	PlaneFront
+
+	// PlaneBack is the side the normal points away from.
+	PlaneBack
+)
+
+// Plane is the set of points whose dot product with Normal equals Offset.
+// The normal is expected to be normalized for distances to be true distances.
+type Plane struct {
+	// Normal is the plane's normal vector
+	Normal Vector3
+
+	// Offset is the distance of the plane from the origin
+	Offset Real
+}
+
+// PlaneFromPoints returns the plane through three points. The normal points
+// to the side from which the points go around counter-clockwise. Points that
+// are on a line give a plane with a zero normal.
+func PlaneFromPoints(a *Vector3, b *Vector3, c *Vector3) Plane {
+	ab, ac := *b, *c
+	ab.Sub(a)
+	ac.Sub(a)
+	normal := ab.Cross(&ac)
+	normal.Normalize()
+	return Plane{Normal: normal, Offset: normal.Dot(a)}
+}
+
+// Distance returns the signed distance of the point from the plane, which is
+// positive in front of it.
+func (p *Plane) Distance(point *Vector3) Real {
+	return p.Normal.Dot(point) - p.Offset
+}
+
+// Side returns the side of the plane the point is on.
+func (p *Plane) Side(point *Vector3) PlaneSide {
+	d := p.Distance(point)
+	if d > Epsilon {
+		return PlaneFront
+	} else if d < -Epsilon {
+		return PlaneBack
+	}
+	return PlaneOn
+}
+
+// Project returns the point on the plane closest to the point given.
+func (p *Plane) Project(point *Vector3) Vector3 {
+	result := *point
+	result.AddScaled(&p.Normal, -p.Distance(point))
+	return result
+}
+
+// ClipSegment cuts the segment from start to end down to the part behind the
+// plane. It returns false if none of the segment is behind the plane.
+func (p *Plane) ClipSegment(start *Vector3, end *Vector3) (bool, Vector3, Vector3) {
+	ds := p.Distance(start)
+	de := p.Distance(end)
+	if ds > 0.0 && de > 0.0 {
+		return false, *start, *end
+	}
+	clippedStart, clippedEnd := *start, *end
+	if ds > 0.0 {
+		clippedStart = p.segmentPoint(start, end, ds, de)
+	} else if de > 0.0 {
+		clippedEnd = p.segmentPoint(start, end, ds, de)
+	}
+	return true, clippedStart, clippedEnd
+}
+
+// segmentPoint returns where the segment, whose ends are at the distances
+// given, crosses the plane.
+func (p *Plane) segmentPoint(start *Vector3, end *Vector3, ds Real, de Real) Vector3 {
+	t := ds / (ds - de)
+	point := *end
+	point.Sub(start)
+	point.MulWith(t)
+	point.Add(start)
+	return point
+}
+
+// ClipPolygon cuts the polygon, given by its points in order, down to the
+// part behind the plane. The polygon is expected to be convex.
+func (p *Plane) ClipPolygon(points []Vector3) []Vector3 {
+	if len(points) == 0 {
+		return nil
+	}
+	var clipped []Vector3
+	prev := &points[len(points)-1]
+	prevDistance := p.Distance(prev)
+	for i := range points {
+		curr := &points[i]
+		currDistance := p.Distance(curr)
+		if (prevDistance > 0.0) != (currDistance > 0.0) {
+			clipped = append(clipped, p.segmentPoint(prev, curr, prevDistance, currDistance))
+		}
+		if currDistance <= 0.0 {
+			clipped = append(clipped, *curr)
+		}
+		prev, prevDistance = curr, currDistance
+	}
+	return clipped
+}
@@ -0,0 +1,54 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+import (
+	"testing"
+)
+
+func TestPlaneFromPoints(t *testing.T) {
+	p := PlaneFromPoints(&Vector3{0.0, 2.0, 0.0}, &Vector3{0.0, 2.0, 1.0}, &Vector3{1.0, 2.0, 0.0})
+	if p.Normal != (Vector3{0.0, 1.0, 0.0}) || p.Offset != 2.0 {
+		t.Errorf("Plane from points is wrong: %v", p)
+	}
+
+	if d := p.Distance(&Vector3{5.0, 3.5, -1.0}); d != 1.5 {
+		t.Errorf("Distance should be 1.5 but was %f", d)
+	}
+	if p.Side(&Vector3{0.0, 3.0, 0.0}) != PlaneFront || p.Side(&Vector3{0.0, 1.0, 0.0}) != PlaneBack ||
+		p.Side(&Vector3{7.0, 2.0, 7.0}) != PlaneOn {
+		t.Errorf("Side classification is wrong")
+	}
+	if proj := p.Project(&Vector3{1.0, 5.0, 1.0}); proj != (Vector3{1.0, 2.0, 1.0}) {
+		t.Errorf("Projected point is wrong: %v", proj)
+	}
+}
+
+func TestPlaneClip(t *testing.T) {
+	p := Plane{Normal: Vector3{1.0, 0.0, 0.0}, Offset: 1.0}
+
+	ok, start, end := p.ClipSegment(&Vector3{-1.0, 0.0, 0.0}, &Vector3{3.0, 4.0, 0.0})
+	if !ok || start != (Vector3{-1.0, 0.0, 0.0}) || end != (Vector3{1.0, 2.0, 0.0}) {
+		t.Errorf("Clipped segment is wrong: %v %v", start, end)
+	}
+	ok, start, end = p.ClipSegment(&Vector3{3.0, 4.0, 0.0}, &Vector3{-1.0, 0.0, 0.0})
+	if !ok || start != (Vector3{1.0, 2.0, 0.0}) || end != (Vector3{-1.0, 0.0, 0.0}) {
+		t.Errorf("Clipped reversed segment is wrong: %v %v", start, end)
+	}
+	if ok, _, _ = p.ClipSegment(&Vector3{2.0, 0.0, 0.0}, &Vector3{3.0, 0.0, 0.0}); ok {
+		t.Errorf("A segment in front of the plane should be clipped away")
+	}
+
+	square := []Vector3{{0.0, 0.0, 0.0}, {2.0, 0.0, 0.0}, {2.0, 2.0, 0.0}, {0.0, 2.0, 0.0}}
+	clipped := p.ClipPolygon(square)
+	expected := []Vector3{{0.0, 0.0, 0.0}, {1.0, 0.0, 0.0}, {1.0, 2.0, 0.0}, {0.0, 2.0, 0.0}}
+	if len(clipped) != len(expected) {
+		t.Fatalf("Clipped polygon should have %d points but had %d", len(expected), len(clipped))
+	}
+	for i := range expected {
+		if clipped[i] != expected[i] {
+			t.Errorf("Clipped polygon point %d is %v instead of %v", i, clipped[i], expected[i])
+		}
+	}
+}
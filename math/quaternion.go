@@ -16,20 +16,18 @@ func QuatFromAxis(angle, x, y, z Real) Quat {
 }
 
 // AddScaledVector adds the given vector to this quaternion, scaled
-// by the given amount.
+// by the given amount. This is how an orientation is integrated by an
+// angular velocity, so it's written out in full instead of building a
+// quaternion from the vector and multiplying.
 func (q *Quat) AddScaledVector(v *Vector3, scale Real) {
-	var temp Quat
-	temp[0] = 0.0
-	temp[1] = v[0] * scale
-	temp[2] = v[1] * scale
-	temp[3] = v[2] * scale
-
-	temp.Mul(q)
-
-	q[0] += temp[0] * 0.5
-	q[1] += temp[1] * 0.5
-	q[2] += temp[2] * 0.5
-	q[3] += temp[3] * 0.5
+	x, y, z := v[0]*scale, v[1]*scale, v[2]*scale
+	qw, qx, qy, qz := q[0], q[1], q[2], q[3]
+
+	// the product of {0, x, y, z} and q, halved
+	q[0] += (-x*qx - y*qy - z*qz) * 0.5
+	q[1] += (x*qw + y*qz - z*qy) * 0.5
+	q[2] += (y*qw + z*qx - x*qz) * 0.5
+	q[3] += (z*qw + x*qy - y*qx) * 0.5
 }
 
 // SetIdentity loads the quaternion with its identity.
@@ -191,3 +189,81 @@ func (q *Quat) Scale(c Real) {
 func (q *Quat) Dot(q2 *Quat) Real {
 	return q[0]*q2[0] + q[1]*q2[1] + q[2]*q2[2] + q[3]*q2[3]
 }
+
+// QuatFromAxisAngle creates a quaternion that rotates by the angle, in
+// radians, about the axis. The axis doesn't need to be normalized.
+func QuatFromAxisAngle(axis *Vector3, angle Real) Quat {
+	a := *axis
+	a.Normalize()
+	return QuatFromAxis(angle, a[0], a[1], a[2])
+}
+
+// ToAxisAngle returns the normalized axis and the angle, in radians, of the
+// rotation the quaternion represents. The angle is between zero and Pi, with
+// the axis flipped as needed. The identity gives the X axis and an angle of
+// zero.
+func (q *Quat) ToAxisAngle() (Vector3, Real) {
+	n := *q
+	n.Normalize()
+	if n[0] < 0.0 {
+		n.Scale(-1.0)
+	}
+	axis := Vector3{n[1], n[2], n[3]}
+	sinHalfAngle := axis.Magnitude()
+	if sinHalfAngle < Epsilon {
+		return Vector3{1.0, 0.0, 0.0}, 0.0
+	}
+	axis.MulWith(1.0 / sinHalfAngle)
+	angle := 2.0 * Real(math.Atan2(float64(sinHalfAngle), float64(n[0])))
+	return axis, angle
+}
+
+// RotateVector rotates the vector in place by the rotation this quaternion
+// represents.
+func (q *Quat) RotateVector(v *Vector3) {
+	*v = q.Rotate(v)
+}
+
+// QuatNlerp blends between two orientations along the shortest path by
+// interpolating linearly and normalizing the result. It's cheaper than
+// QuatSlerp but doesn't turn at a constant rate.
+func QuatNlerp(from *Quat, to *Quat, alpha Real) Quat {
+	target := *to
+	if from.Dot(to) < 0.0 {
+		target.Scale(-1.0)
+	}
+
+	var result Quat
+	for i := 0; i < 4; i++ {
+		result[i] = from[i]*(1.0-alpha) + target[i]*alpha
+	}
+	result.Normalize()
+	return result
+}
+
+// QuatSlerp blends between two orientations along the shortest path, turning
+// at a constant rate. The orientations should be normalized.
+func QuatSlerp(from *Quat, to *Quat, alpha Real) Quat {
+	target := *to
+	cosTheta := from.Dot(to)
+	if cosTheta < 0.0 {
+		target.Scale(-1.0)
+		cosTheta = -cosTheta
+	}
+
+	// nearly the same orientation, where the sine below is too small to divide by
+	if cosTheta > 1.0-Epsilon {
+		return QuatNlerp(from, &target, alpha)
+	}
+
+	theta := Real(math.Acos(float64(cosTheta)))
+	sinTheta := RealSin(theta)
+	fromScale := RealSin((1.0-alpha)*theta) / sinTheta
+	toScale := RealSin(alpha*theta) / sinTheta
+
+	var result Quat
+	for i := 0; i < 4; i++ {
+		result[i] = from[i]*fromScale + target[i]*toScale
+	}
+	return result
+}
@@ -179,3 +179,74 @@ func TestQuatRotate(t *testing.T) {
 		t.Errorf("Quaternion rotation didn't yield the correct vector:\n\t(q=%v:v%v)%v", q, v, result)
 	}
 }
+
+func TestQuatAxisAngle(t *testing.T) {
+	axis := Vector3{0.0, 2.0, 0.0}
+	q := QuatFromAxisAngle(&axis, DegToRad(90))
+	v := Vector3{1.0, 0.0, 0.0}
+	q.RotateVector(&v)
+	if RealAbs(v[0]) > 1e-6 || RealAbs(v[1]) > 1e-6 || !RealEqual(v[2], -1.0) {
+		t.Errorf("Rotating by an axis and angle gave the wrong vector: %v", v)
+	}
+
+	back, angle := q.ToAxisAngle()
+	if !RealEqual(angle, DegToRad(90)) || RealAbs(back[0]) > 1e-6 || !RealEqual(back[1], 1.0) || RealAbs(back[2]) > 1e-6 {
+		t.Errorf("Axis and angle didn't round trip: %v %v", back, angle)
+	}
+
+	// the same rotation the other way round gives the same axis and angle
+	q.Scale(-1.0)
+	back, angle = q.ToAxisAngle()
+	if !RealEqual(angle, DegToRad(90)) || !RealEqual(back[1], 1.0) {
+		t.Errorf("Negated quaternion gave the wrong axis and angle: %v %v", back, angle)
+	}
+
+	var identity Quat
+	identity.SetIdentity()
+	if back, angle = identity.ToAxisAngle(); angle != 0.0 || back != (Vector3{1.0, 0.0, 0.0}) {
+		t.Errorf("Identity gave the wrong axis and angle: %v %v", back, angle)
+	}
+}
+
+func TestQuatSlerpNlerp(t *testing.T) {
+	var from Quat
+	from.SetIdentity()
+	to := QuatFromAxis(DegToRad(120), 0.0, 0.0, 1.0)
+
+	for _, alpha := range []Real{0.0, 0.25, 0.5, 1.0} {
+		q := QuatSlerp(&from, &to, alpha)
+		_, angle := q.ToAxisAngle()
+		if RealAbs(angle-DegToRad(120)*alpha) > 1e-6 {
+			t.Errorf("Slerp at %v should turn %v but turned %v", alpha, DegToRad(120)*alpha, angle)
+		}
+		if RealAbs(q.Len()-1.0) > 1e-6 {
+			t.Errorf("Slerp at %v isn't normalized: %v", alpha, q)
+		}
+	}
+
+	// nlerp takes the same path but not at a constant rate
+	half := QuatNlerp(&from, &to, 0.5)
+	_, angle := half.ToAxisAngle()
+	if RealAbs(angle-DegToRad(60)) > 1e-6 {
+		t.Errorf("Nlerp half way should turn 60 degrees but turned %v", RadToDeg(angle))
+	}
+
+	// the shortest path is taken when the quaternions are in opposite hemispheres
+	negated := to
+	negated.Scale(-1.0)
+	q := QuatSlerp(&from, &negated, 0.5)
+	if _, angle = q.ToAxisAngle(); RealAbs(angle-DegToRad(60)) > 1e-6 {
+		t.Errorf("Slerp didn't take the shortest path: turned %v", RadToDeg(angle))
+	}
+}
+
+var benchQuat Quat
+
+func BenchmarkQuatAddScaledVector(b *testing.B) {
+	q := QuatFromAxis(0.5, 0.0, 1.0, 0.0)
+	v := Vector3{0.3, 0.2, 0.1}
+	for i := 0; i < b.N; i++ {
+		q.AddScaledVector(&v, 0.001)
+	}
+	benchQuat = q
+}
@@ -0,0 +1,45 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+import (
+	"math"
+	"math/rand"
+)
+
+// RandomUnitVector returns a vector of length one pointing in a direction
+// picked uniformly from the random source.
+func RandomUnitVector(r *rand.Rand) Vector3 {
+	for {
+		// normal distributions along each axis add up to one that's the same
+		// in every direction
+		v := Vector3{Real(r.NormFloat64()), Real(r.NormFloat64()), Real(r.NormFloat64())}
+		if length := v.Magnitude(); length > Epsilon {
+			v.MulWith(1.0 / length)
+			return v
+		}
+	}
+}
+
+// RandomQuat returns an orientation picked uniformly from the random source.
+func RandomQuat(r *rand.Rand) Quat {
+	// Shoemake's method for uniform random rotations
+	u1, u2, u3 := r.Float64(), r.Float64()*2.0*math.Pi, r.Float64()*2.0*math.Pi
+	a, b := math.Sqrt(1.0-u1), math.Sqrt(u1)
+	return Quat{
+		Real(b * math.Cos(u3)),
+		Real(a * math.Sin(u2)),
+		Real(a * math.Cos(u2)),
+		Real(b * math.Sin(u3)),
+	}
+}
+
+// RandomInAABB returns a point picked uniformly from inside the box.
+func RandomInAABB(r *rand.Rand, a *AABB) Vector3 {
+	var v Vector3
+	for i := 0; i < 3; i++ {
+		v[i] = a.Min[i] + Real(r.Float64())*(a.Max[i]-a.Min[i])
+	}
+	return v
+}
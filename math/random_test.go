@@ -0,0 +1,49 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRandomSamplers(t *testing.T) {
+	const samples = 2000
+	r := rand.New(rand.NewSource(1))
+
+	var sum Vector3
+	for i := 0; i < samples; i++ {
+		v := RandomUnitVector(r)
+		if RealAbs(v.Magnitude()-1.0) > 1e-6 {
+			t.Fatalf("Random unit vector isn't unit length: %v", v)
+		}
+		sum.Add(&v)
+	}
+
+	// the directions should be spread evenly enough to roughly cancel out
+	sum.MulWith(1.0 / samples)
+	if sum.Magnitude() > 0.1 {
+		t.Errorf("Random unit vectors are biased toward %v", sum)
+	}
+
+	for i := 0; i < samples; i++ {
+		q := RandomQuat(r)
+		if RealAbs(q.Len()-1.0) > 1e-6 {
+			t.Fatalf("Random quaternion isn't normalized: %v", q)
+		}
+	}
+
+	box := AABB{Min: Vector3{-1.0, 2.0, 5.0}, Max: Vector3{1.0, 3.0, 5.5}}
+	for i := 0; i < samples; i++ {
+		if p := RandomInAABB(r, &box); !box.Contains(&p) {
+			t.Fatalf("Random point %v is outside the box", p)
+		}
+	}
+
+	// the same seed gives the same samples
+	one, two := rand.New(rand.NewSource(7)), rand.New(rand.NewSource(7))
+	if RandomUnitVector(one) != RandomUnitVector(two) || RandomQuat(one) != RandomQuat(two) {
+		t.Errorf("Samplers aren't repeatable from the same seed")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+// Ray is a half line starting at Origin and going on forever in Direction.
+// The intersection tests expect the direction to be normalized so that the
+// distances they return are true distances.
+type Ray struct {
+	// Origin is the point the ray starts from.
+	Origin Vector3
+
+	// Direction is the direction the ray goes in.
+	Direction Vector3
+}
+
+// PointAt returns the point the distance given along the ray.
+func (r *Ray) PointAt(t Real) Vector3 {
+	point := r.Origin
+	point.AddScaled(&r.Direction, t)
+	return point
+}
+
+// Transform returns the ray moved by the transform matrix.
+func (r *Ray) Transform(transform *Matrix3x4) Ray {
+	return Ray{
+		Origin:    transform.MulVector3(&r.Origin),
+		Direction: transform.TransformDirection(&r.Direction),
+	}
+}
+
+// TransformInverse returns the ray moved by the inverse of the transform
+// matrix, such as to take a World Space ray into the local space of a body.
+// NOTE: will not work on matrixes with scale or shears.
+func (r *Ray) TransformInverse(transform *Matrix3x4) Ray {
+	return Ray{
+		Origin:    transform.TransformInverse(&r.Origin),
+		Direction: transform.TransformInverseDirection(&r.Direction),
+	}
+}
+
+// IntersectSphere returns true if the ray hits the sphere, along with the
+// distance to where it enters the sphere, which is zero if the origin is
+// inside it.
+func (r *Ray) IntersectSphere(center *Vector3, radius Real) (bool, Real) {
+	toOrigin := r.Origin
+	toOrigin.Sub(center)
+
+	b := toOrigin.Dot(&r.Direction)
+	c := toOrigin.SquareMagnitude() - radius*radius
+
+	// the ray starts outside the sphere and points away from it
+	if c > 0.0 && b > 0.0 {
+		return false, 0.0
+	}
+
+	discriminant := b*b - c
+	if discriminant < 0.0 {
+		return false, 0.0
+	}
+
+	t := -b - RealSqrt(discriminant)
+	if t < 0.0 {
+		t = 0.0
+	}
+	return true, t
+}
+
+// IntersectAABB returns true if the ray hits the box within the distance
+// given, along with the distance to where it enters the box, which is zero if
+// the origin is inside it.
+func (r *Ray) IntersectAABB(box *AABB, maxDistance Real) (bool, Real) {
+	return box.RayIntersection(&r.Origin, &r.Direction, maxDistance)
+}
+
+// IntersectPlane returns true if the ray hits the front of the plane, along
+// with the distance to where it hits. A ray that starts behind the plane or
+// runs parallel to it misses.
+func (r *Ray) IntersectPlane(p *Plane) (bool, Real) {
+	denom := p.Normal.Dot(&r.Direction)
+	if denom >= 0.0 {
+		return false, 0.0
+	}
+
+	t := -p.Distance(&r.Origin) / denom
+	if t < 0.0 {
+		return false, 0.0
+	}
+	return true, t
+}
@@ -0,0 +1,65 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+import (
+	"testing"
+)
+
+func TestRayTransform(t *testing.T) {
+	r := Ray{Origin: Vector3{1.0, 0.0, 0.0}, Direction: Vector3{0.0, 0.0, -1.0}}
+	if p := r.PointAt(2.5); p != (Vector3{1.0, 0.0, -2.5}) {
+		t.Errorf("PointAt gave %v", p)
+	}
+
+	var transform Matrix3x4
+	rot := QuatFromAxis(DegToRad(90), 0.0, 1.0, 0.0)
+	transform.SetAsTransform(&Vector3{0.0, 2.0, 0.0}, &rot)
+	moved := r.Transform(&transform)
+	if RealAbs(moved.Origin[0]) > 1e-6 || !RealEqual(moved.Origin[1], 2.0) || !RealEqual(moved.Origin[2], -1.0) {
+		t.Errorf("Transformed origin is wrong: %v", moved.Origin)
+	}
+	if !RealEqual(moved.Direction[0], -1.0) || RealAbs(moved.Direction[2]) > 1e-6 {
+		t.Errorf("Transformed direction is wrong: %v", moved.Direction)
+	}
+
+	back := moved.TransformInverse(&transform)
+	for i := 0; i < 3; i++ {
+		if RealAbs(back.Origin[i]-r.Origin[i]) > 1e-6 || RealAbs(back.Direction[i]-r.Direction[i]) > 1e-6 {
+			t.Fatalf("TransformInverse didn't undo Transform: %v", back)
+		}
+	}
+}
+
+func TestRayIntersections(t *testing.T) {
+	r := Ray{Origin: Vector3{0.0, 0.0, 10.0}, Direction: Vector3{0.0, 0.0, -1.0}}
+
+	if hit, d := r.IntersectSphere(&Vector3{0.0, 0.0, 0.0}, 2.0); !hit || d != 8.0 {
+		t.Errorf("Ray should hit the sphere at 8 but got %v %v", hit, d)
+	}
+	if hit, _ := r.IntersectSphere(&Vector3{3.0, 0.0, 0.0}, 2.0); hit {
+		t.Errorf("Ray should miss the sphere to the side")
+	}
+	inside := Ray{Origin: Vector3{0.5, 0.0, 0.0}, Direction: Vector3{1.0, 0.0, 0.0}}
+	if hit, d := inside.IntersectSphere(&Vector3{0.0, 0.0, 0.0}, 2.0); !hit || d != 0.0 {
+		t.Errorf("Ray inside the sphere should hit at 0 but got %v %v", hit, d)
+	}
+
+	box := AABB{Min: Vector3{-1.0, -1.0, -1.0}, Max: Vector3{1.0, 1.0, 1.0}}
+	if hit, d := r.IntersectAABB(&box, 100.0); !hit || d != 9.0 {
+		t.Errorf("Ray should hit the box at 9 but got %v %v", hit, d)
+	}
+	if hit, _ := r.IntersectAABB(&box, 5.0); hit {
+		t.Errorf("Ray shouldn't reach the box within 5")
+	}
+
+	ground := Plane{Normal: Vector3{0.0, 0.0, 1.0}, Offset: 4.0}
+	if hit, d := r.IntersectPlane(&ground); !hit || d != 6.0 {
+		t.Errorf("Ray should hit the plane at 6 but got %v %v", hit, d)
+	}
+	behind := Ray{Origin: Vector3{0.0, 0.0, 1.0}, Direction: Vector3{0.0, 0.0, 1.0}}
+	if hit, _ := behind.IntersectPlane(&ground); hit {
+		t.Errorf("Ray from behind the plane shouldn't hit it")
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+//go:build cubez_f32
+// +build cubez_f32
+
+package math
+
+import (
+	"math"
+)
+
+// Real is the type of float used in the library. Building with the
+// cubez_f32 tag makes it a float32, which is faster and matches the
+// precision of the GPU at the cost of accuracy.
+type Real float32
+
+// Espilon is used to test equality of the floats and represents how
+// close two Reals can be and still test positive for equality. It's
+// larger than for a float64 since a float32 only has around seven
+// significant digits.
+const Epsilon Real = 1e-5
+
+// zeroEpsilon is how close to zero a Real can be and still test positive for
+// equality with zero. The rounding error of a float32 near zero is far larger
+// than Epsilon squared.
+const zeroEpsilon Real = 1e-6
+
+const (
+	MinNormal = Real(1.1754943508222875e-38) // 1 / 2**(127 - 1)
+	MinValue  = Real(math.SmallestNonzeroFloat32)
+	MaxValue  = Real(math.MaxFloat32)
+)
+
+var (
+	InfPos = Real(math.Inf(1))
+	InfNeg = Real(math.Inf(-1))
+	NaN    = Real(math.NaN())
+)
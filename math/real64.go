@@ -0,0 +1,35 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+//go:build !cubez_f32
+// +build !cubez_f32
+
+package math
+
+import (
+	"math"
+)
+
+// Real is the type of float used in the library. It's a float64 unless the
+// library is built with the cubez_f32 tag.
+type Real float64
+
+// Espilon is used to test equality of the floats and represents how
+// close two Reals can be and still test positive for equality.
+const Epsilon Real = 1e-7
+
+// zeroEpsilon is how close to zero a Real can be and still test positive for
+// equality with zero.
+const zeroEpsilon = Epsilon * Epsilon
+
+const (
+	MinNormal = Real(1.1754943508222875e-38) // 1 / 2**(127 - 1)
+	MinValue  = Real(math.SmallestNonzeroFloat64)
+	MaxValue  = Real(math.MaxFloat64)
+)
+
+var (
+	InfPos = Real(math.Inf(1))
+	InfNeg = Real(math.Inf(-1))
+	NaN    = Real(math.NaN())
+)
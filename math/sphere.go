@@ -0,0 +1,118 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+// Sphere is a bounding sphere.
+type Sphere struct {
+	// Center is the center of the sphere.
+	Center Vector3
+
+	// Radius is the radius of the sphere.
+	Radius Real
+}
+
+// SphereAroundAABB returns the smallest sphere that bounds the box.
+func SphereAroundAABB(a *AABB) Sphere {
+	half := a.HalfSize()
+	return Sphere{Center: a.Center(), Radius: half.Magnitude()}
+}
+
+// SphereAroundPoints returns a sphere that bounds the points. It uses Ritter's
+// method, which is fast and gives a sphere that is at most a few percent
+// larger than the smallest one. No points give a sphere of zero radius at the
+// origin.
+func SphereAroundPoints(points []Vector3) Sphere {
+	if len(points) == 0 {
+		return Sphere{}
+	}
+
+	// start with the pair of points furthest apart along one of the axes
+	var minIndex, maxIndex [3]int
+	for i := range points {
+		for axis := 0; axis < 3; axis++ {
+			if points[i][axis] < points[minIndex[axis]][axis] {
+				minIndex[axis] = i
+			}
+			if points[i][axis] > points[maxIndex[axis]][axis] {
+				maxIndex[axis] = i
+			}
+		}
+	}
+	var lo, hi *Vector3
+	widest := Real(-1.0)
+	for axis := 0; axis < 3; axis++ {
+		span := points[maxIndex[axis]]
+		span.Sub(&points[minIndex[axis]])
+		if length := span.SquareMagnitude(); length > widest {
+			widest = length
+			lo, hi = &points[minIndex[axis]], &points[maxIndex[axis]]
+		}
+	}
+
+	s := Sphere{Center: *lo, Radius: RealSqrt(widest) * 0.5}
+	s.Center.Add(hi)
+	s.Center.MulWith(0.5)
+
+	// then grow it to take in any points left outside
+	for i := range points {
+		s.MergePoint(&points[i])
+	}
+	return s
+}
+
+// MergePoint grows the sphere just enough to also cover the point, moving its
+// center toward the point.
+func (s *Sphere) MergePoint(point *Vector3) {
+	offset := *point
+	offset.Sub(&s.Center)
+	distance := offset.Magnitude()
+	if distance <= s.Radius {
+		return
+	}
+
+	radius := (s.Radius + distance) * 0.5
+	s.Center.AddScaled(&offset, (radius-s.Radius)/distance)
+	s.Radius = radius
+}
+
+// Merge grows the sphere to the smallest one that also covers the other
+// sphere.
+func (s *Sphere) Merge(other *Sphere) {
+	offset := other.Center
+	offset.Sub(&s.Center)
+	distance := offset.Magnitude()
+
+	// one sphere is already inside the other
+	if distance+other.Radius <= s.Radius {
+		return
+	}
+	if distance+s.Radius <= other.Radius {
+		*s = *other
+		return
+	}
+
+	radius := (s.Radius + distance + other.Radius) * 0.5
+	s.Center.AddScaled(&offset, (radius-s.Radius)/distance)
+	s.Radius = radius
+}
+
+// Contains returns true if the point is inside the sphere.
+func (s *Sphere) Contains(point *Vector3) bool {
+	offset := *point
+	offset.Sub(&s.Center)
+	return offset.SquareMagnitude() <= s.Radius*s.Radius
+}
+
+// Overlaps returns true if the two spheres overlap or touch.
+func (s *Sphere) Overlaps(other *Sphere) bool {
+	offset := other.Center
+	offset.Sub(&s.Center)
+	reach := s.Radius + other.Radius
+	return offset.SquareMagnitude() <= reach*reach
+}
+
+// AABB returns the box that bounds the sphere.
+func (s *Sphere) AABB() AABB {
+	return AABBAround(&s.Center, s.Radius)
+}
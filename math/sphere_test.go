@@ -0,0 +1,74 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package math
+
+import (
+	"testing"
+)
+
+func TestSphereAroundPoints(t *testing.T) {
+	points := []Vector3{
+		{1.0, 0.0, 0.0}, {-1.0, 0.0, 0.0}, {0.0, 1.0, 0.0},
+		{0.0, -1.0, 0.0}, {0.0, 0.0, 1.0}, {0.0, 0.0, -1.0},
+		{0.5, 0.5, 0.5}, {0.6, -0.6, 0.2}, {2.0, 2.0, 2.0},
+	}
+	s := SphereAroundPoints(points)
+	for i := range points {
+		offset := points[i]
+		offset.Sub(&s.Center)
+		if offset.Magnitude() > s.Radius*(1.0+1e-6) {
+			t.Errorf("Point %v is outside the sphere %v", points[i], s)
+		}
+	}
+
+	// the smallest sphere runs from {-1, 0, 0} or so to {2, 2, 2}, so the
+	// approximation should be close to that
+	if s.Radius > 2.3 {
+		t.Errorf("Sphere around the points is much too large: %v", s)
+	}
+
+	if empty := SphereAroundPoints(nil); empty != (Sphere{}) {
+		t.Errorf("Sphere around no points should be empty: %v", empty)
+	}
+	single := SphereAroundPoints([]Vector3{{1.0, 2.0, 3.0}})
+	if single.Center != (Vector3{1.0, 2.0, 3.0}) || single.Radius != 0.0 {
+		t.Errorf("Sphere around one point is wrong: %v", single)
+	}
+}
+
+func TestSphereMerge(t *testing.T) {
+	box := AABB{Min: Vector3{-1.0, -2.0, -2.0}, Max: Vector3{1.0, 2.0, 2.0}}
+	s := SphereAroundAABB(&box)
+	if s.Center != (Vector3{0.0, 0.0, 0.0}) || s.Radius != 3.0 {
+		t.Errorf("Sphere around the box is wrong: %v", s)
+	}
+
+	a := Sphere{Center: Vector3{0.0, 0.0, 0.0}, Radius: 1.0}
+	b := Sphere{Center: Vector3{4.0, 0.0, 0.0}, Radius: 1.0}
+	if a.Overlaps(&b) {
+		t.Errorf("Spheres apart shouldn't overlap")
+	}
+	a.Merge(&b)
+	if a.Center != (Vector3{2.0, 0.0, 0.0}) || a.Radius != 3.0 {
+		t.Errorf("Merged sphere is wrong: %v", a)
+	}
+
+	inner := Sphere{Center: Vector3{2.5, 0.0, 0.0}, Radius: 0.5}
+	a.Merge(&inner)
+	if a.Center != (Vector3{2.0, 0.0, 0.0}) || a.Radius != 3.0 {
+		t.Errorf("Merging a sphere inside shouldn't change it: %v", a)
+	}
+	inner.Merge(&a)
+	if inner != a {
+		t.Errorf("Merging into a sphere inside should give the outer sphere: %v", inner)
+	}
+
+	a.MergePoint(&Vector3{7.0, 0.0, 0.0})
+	if a.Center != (Vector3{3.0, 0.0, 0.0}) || a.Radius != 4.0 || !a.Contains(&Vector3{7.0, 0.0, 0.0}) {
+		t.Errorf("Merged point sphere is wrong: %v", a)
+	}
+	if bounds := a.AABB(); bounds.Min != (Vector3{-1.0, -4.0, -4.0}) || bounds.Max != (Vector3{7.0, 4.0, 4.0}) {
+		t.Errorf("Bounds of the sphere are wrong: %v", bounds)
+	}
+}
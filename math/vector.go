@@ -17,6 +17,12 @@ func (v *Vector3) AddScaled(v2 *Vector3, scale Real) {
 	v[2] += v2[2] * scale
 }
 
+// AddScaledInto sets dst to this vector plus another vector scaled by a Real,
+// leaving this vector unchanged. dst may be either vector.
+func (v *Vector3) AddScaledInto(dst *Vector3, v2 *Vector3, scale Real) {
+	*dst = Vector3{v[0] + v2[0]*scale, v[1] + v2[1]*scale, v[2] + v2[2]*scale}
+}
+
 // Clear sets the vector to {0.0, 0.0, 0.0}.
 func (v *Vector3) Clear() {
 	v[0], v[1], v[2] = 0.0, 0.0, 0.0
@@ -31,7 +37,15 @@ func (v *Vector3) ComponentProduct(v2 *Vector3) {
 
 // Cross returns the cross product of this vector with another.
 func (v *Vector3) Cross(v2 *Vector3) Vector3 {
-	return Vector3{
+	var result Vector3
+	v.CrossInto(&result, v2)
+	return result
+}
+
+// CrossInto sets dst to the cross product of this vector with another without
+// returning a copy. dst may be either vector.
+func (v *Vector3) CrossInto(dst *Vector3, v2 *Vector3) {
+	*dst = Vector3{
 		v[1]*v2[2] - v[2]*v2[1],
 		v[2]*v2[0] - v[0]*v2[2],
 		v[0]*v2[1] - v[1]*v2[0],
@@ -85,6 +99,12 @@ func (v *Vector3) Sub(v2 *Vector3) {
 	v[2] -= v2[2]
 }
 
+// SubInto sets dst to this vector minus another, leaving this vector
+// unchanged. dst may be either vector.
+func (v *Vector3) SubInto(dst *Vector3, v2 *Vector3) {
+	*dst = Vector3{v[0] - v2[0], v[1] - v2[1], v[2] - v2[2]}
+}
+
 // MulWith multiplies a vector by a Real number.
 func (v *Vector4) MulWith(r Real) {
 	v[0] *= r
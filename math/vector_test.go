@@ -208,3 +208,31 @@ func TestVectorGoCopies(t *testing.T) {
 		t.Errorf("The initial vector was modified in the multiplication after a copy: %v", v1)
 	}
 }
+
+func TestVec3IntoVariants(t *testing.T) {
+	a := Vector3{1.0, 2.0, 3.0}
+	b := Vector3{-2.0, 0.5, 4.0}
+
+	var dst Vector3
+	a.CrossInto(&dst, &b)
+	if dst != a.Cross(&b) {
+		t.Errorf("CrossInto doesn't match Cross: %v", dst)
+	}
+
+	// the destination can be one of the operands
+	expected := a.Cross(&b)
+	aliased := a
+	aliased.CrossInto(&aliased, &b)
+	if aliased != expected {
+		t.Errorf("CrossInto into its own vector gave %v instead of %v", aliased, expected)
+	}
+
+	a.AddScaledInto(&dst, &b, 2.0)
+	if dst != (Vector3{-3.0, 3.0, 11.0}) || a != (Vector3{1.0, 2.0, 3.0}) {
+		t.Errorf("AddScaledInto gave %v and changed the vector to %v", dst, a)
+	}
+	a.SubInto(&dst, &b)
+	if dst != (Vector3{3.0, 1.5, -1.0}) {
+		t.Errorf("SubInto gave %v", dst)
+	}
+}
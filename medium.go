@@ -0,0 +1,177 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// Medium describes the fluid that a RigidBody is moving through, such as
+// air or water. Its density scales the drag and buoyancy applied to bodies.
+type Medium struct {
+	// Density is the density of the fluid in kg/m^3.
+	Density m.Real
+}
+
+var (
+	// MediumAir is the medium of air at sea level.
+	MediumAir = Medium{Density: 1.225}
+
+	// MediumWater is the medium of fresh water.
+	MediumWater = Medium{Density: 1000.0}
+)
+
+// FluidVolume is a body of fluid with a flat surface. Everything below the
+// Surface plane (on the opposite side to its normal) is inside the fluid.
+type FluidVolume struct {
+	// Surface is the surface of the fluid; the normal points out of the fluid.
+	Surface *CollisionPlane
+
+	// Medium is the fluid that fills the volume.
+	Medium *Medium
+}
+
+// NewFluidVolume creates a new FluidVolume of the medium below the surface plane.
+func NewFluidVolume(surface *CollisionPlane, medium *Medium) *FluidVolume {
+	fv := new(FluidVolume)
+	fv.Surface = surface
+	fv.Medium = medium
+	return fv
+}
+
+// Contains returns true if the point, in World Space, is inside the fluid.
+func (fv *FluidVolume) Contains(point *m.Vector3) bool {
+	return fv.Surface.Distance(point) < 0.0
+}
+
+// ApplyBuoyancy adds the buoyant force of the fluid to the body of the collider
+// at its center of buoyancy. The force opposes the gravity acting on the body
+// and is scaled by the density of the fluid.
+// Only spheres and cubes are supported.
+func (fv *FluidVolume) ApplyBuoyancy(c Collider) {
+	body := c.GetBody()
+	if body == nil || !body.HasFiniteMass() {
+		return
+	}
+
+	var volume m.Real
+	var centerOfBuoyancy m.Vector3
+	switch shape := c.(type) {
+	case *CollisionSphere:
+		volume, centerOfBuoyancy = shape.SubmergedVolume(fv.Surface)
+	case *CollisionCube:
+		volume, centerOfBuoyancy = shape.SubmergedVolume(fv.Surface)
+	default:
+		return
+	}
+	if volume <= 0.0 {
+		return
+	}
+
+	force := body.GetGravity()
+	force.MulWith(-fv.Medium.Density * volume)
+	body.AddForceAtPoint(&force, &centerOfBuoyancy)
+}
+
+// UpdateMediums sets the Medium of each body to the first fluid volume that
+// contains its center of mass, or to the outside medium if none of them do.
+func UpdateMediums(bodies []*RigidBody, volumes []*FluidVolume, outside *Medium) {
+	for _, body := range bodies {
+		com := body.GetCenterOfMassWorld()
+		body.Medium = outside
+		for _, fv := range volumes {
+			if fv.Contains(&com) {
+				body.Medium = fv.Medium
+				break
+			}
+		}
+	}
+}
+
+// GetMedium returns the medium the body is in, which is MediumAir if one
+// hasn't been set.
+func (body *RigidBody) GetMedium() *Medium {
+	if body.Medium == nil {
+		return &MediumAir
+	}
+	return body.Medium
+}
+
+// ApplyMediumDrag adds a quadratic drag force to the body based on the density
+// of its medium, the drag coefficient and the cross-sectional area of the body.
+func (body *RigidBody) ApplyMediumDrag(dragCoefficient m.Real, area m.Real) {
+	speed := body.Velocity.Magnitude()
+	if speed <= m.Epsilon {
+		return
+	}
+
+	force := body.Velocity
+	force.MulWith(-0.5 * body.GetMedium().Density * dragCoefficient * area * speed)
+	body.AddForce(&force)
+}
+
+// Buoyancy is a ForceGenerator for the lift of a liquid with a flat surface
+// on a body, for simple floating crates and boats. The body is treated as
+// being submerged in proportion to how deep its center of buoyancy is, which
+// is cheaper than working out the submerged volume like FluidVolume does.
+type Buoyancy struct {
+	// CenterOfBuoyancy is the point on the body, in Body Space, where the
+	// lift is applied. Putting it above the center of mass keeps a body upright.
+	CenterOfBuoyancy m.Vector3
+
+	// MaxDepth is how far the center of buoyancy has to be below the surface
+	// for the body to be fully submerged; when it's the same distance above
+	// the surface the body is out of the liquid.
+	MaxDepth m.Real
+
+	// Volume is the volume of the body.
+	Volume m.Real
+
+	// LiquidHeight is the height of the surface of the liquid along the up
+	// direction, which is the opposite of the body's gravity.
+	LiquidHeight m.Real
+
+	// LiquidDensity is the density of the liquid.
+	// Defaults to the density of MediumWater.
+	LiquidDensity m.Real
+}
+
+// NewBuoyancy creates a new Buoyancy generator for a body of the volume given
+// floating in water with its surface at the height given.
+func NewBuoyancy(centerOfBuoyancy *m.Vector3, maxDepth m.Real, volume m.Real, liquidHeight m.Real) *Buoyancy {
+	b := new(Buoyancy)
+	b.CenterOfBuoyancy = *centerOfBuoyancy
+	b.MaxDepth = maxDepth
+	b.Volume = volume
+	b.LiquidHeight = liquidHeight
+	b.LiquidDensity = MediumWater.Density
+	return b
+}
+
+// UpdateForce adds the lift of the liquid at the center of buoyancy.
+func (b *Buoyancy) UpdateForce(body *RigidBody, duration m.Real) {
+	gravity := body.GetGravity()
+	strength := gravity.Magnitude()
+	if strength < m.Epsilon || b.MaxDepth <= 0.0 {
+		return
+	}
+	up := gravity
+	up.MulWith(-1.0 / strength)
+
+	point := body.transform.MulVector3(&b.CenterOfBuoyancy)
+	height := point.Dot(&up)
+	if height >= b.LiquidHeight+b.MaxDepth {
+		return
+	}
+
+	// the fraction of the body that's under the surface
+	submerged := m.Real(1.0)
+	if height > b.LiquidHeight-b.MaxDepth {
+		submerged = (b.LiquidHeight + b.MaxDepth - height) / (2.0 * b.MaxDepth)
+	}
+
+	force := up
+	force.MulWith(b.LiquidDensity * b.Volume * submerged * strength)
+	body.AddForceAtPoint(&force, &point)
+}
@@ -0,0 +1,99 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// LoadOBJ reads the geometry of a Wavefront OBJ file as vertices and the
+// indices of its triangles, every three indices forming a triangle, so that
+// collision geometry can come straight from the files made by modelling
+// tools. The result can be given to NewCollisionTriangleMesh for static
+// geometry, or to ComputeMeshMassProperties or CookMeshMassPropertiesAsync for
+// the mass of a body.
+//
+// Only the vertex positions and faces are read; texture coordinates,
+// normals, materials and groups are ignored, and the objects in the file are
+// merged together. Faces with more than three corners are split into a fan
+// of triangles, so they should be convex. Negative indices count back from
+// the last vertex read, as in the format.
+func LoadOBJ(r io.Reader) ([]m.Vector3, []int, error) {
+	var vertices []m.Vector3
+	var indices []int
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "v":
+			if len(fields) < 4 {
+				return nil, nil, fmt.Errorf("line %d: a vertex needs three coordinates", line)
+			}
+			var v m.Vector3
+			for i := 0; i < 3; i++ {
+				value, err := strconv.ParseFloat(fields[i+1], 64)
+				if err != nil {
+					return nil, nil, fmt.Errorf("line %d: %v", line, err)
+				}
+				v[i] = m.Real(value)
+			}
+			vertices = append(vertices, v)
+
+		case "f":
+			if len(fields) < 4 {
+				return nil, nil, fmt.Errorf("line %d: a face needs at least three corners", line)
+			}
+			corners := make([]int, len(fields)-1)
+			for i, field := range fields[1:] {
+				// only the position index before any slash is needed
+				if slash := strings.IndexByte(field, '/'); slash >= 0 {
+					field = field[:slash]
+				}
+				index, err := strconv.Atoi(field)
+				if err != nil {
+					return nil, nil, fmt.Errorf("line %d: %v", line, err)
+				}
+				if index < 0 {
+					index += len(vertices)
+				} else {
+					index--
+				}
+				if index < 0 || index >= len(vertices) {
+					return nil, nil, fmt.Errorf("line %d: vertex %s is out of range", line, field)
+				}
+				corners[i] = index
+			}
+			for i := 1; i < len(corners)-1; i++ {
+				indices = append(indices, corners[0], corners[i], corners[i+1])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(indices) == 0 {
+		return nil, nil, fmt.Errorf("the file has no faces")
+	}
+	return vertices, indices, nil
+}
+
+// NewCollisionTriangleMeshFromOBJ creates a new CollisionTriangleMesh from the
+// geometry of a Wavefront OBJ file read with LoadOBJ.
+func NewCollisionTriangleMeshFromOBJ(r io.Reader) (*CollisionTriangleMesh, error) {
+	vertices, indices, err := LoadOBJ(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewCollisionTriangleMesh(vertices, indices), nil
+}
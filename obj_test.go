@@ -0,0 +1,143 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// testGroundOBJ is a 20x20 square of ground facing up, made of a quad with
+// texture and normal indices.
+const testGroundOBJ = `# ground
+o ground
+v -10 0 -10
+v -10 0 10
+v 10 0 10
+v 10 0 -10
+vt 0 0
+vn 0 1 0
+f 1/1/1 2/1/1 3/1/1 4/1/1
+`
+
+func TestLoadOBJFaces(t *testing.T) {
+	vertices, indices, err := LoadOBJ(strings.NewReader(testGroundOBJ))
+	if err != nil {
+		t.Fatalf("LoadOBJ failed: %v", err)
+	}
+	if len(vertices) != 4 {
+		t.Errorf("Read %d vertices; expected 4", len(vertices))
+	}
+
+	// the quad is split into a fan of two triangles
+	expected := []int{0, 1, 2, 0, 2, 3}
+	if len(indices) != len(expected) {
+		t.Fatalf("Read indices %v; expected %v", indices, expected)
+	}
+	for i := range expected {
+		if indices[i] != expected[i] {
+			t.Fatalf("Read indices %v; expected %v", indices, expected)
+		}
+	}
+}
+
+func TestLoadOBJNegativeIndices(t *testing.T) {
+	obj := "v 0 0 0\nv 1 0 0\nv 0 1 0\nf -3 -2 -1\nv 0 0 1\nf 1 -1 -3//\n"
+	vertices, indices, err := LoadOBJ(strings.NewReader(obj))
+	if err != nil {
+		t.Fatalf("LoadOBJ failed: %v", err)
+	}
+	expected := []int{0, 1, 2, 0, 3, 1}
+	if len(vertices) != 4 || len(indices) != len(expected) {
+		t.Fatalf("Read %d vertices and indices %v; expected 4 and %v", len(vertices), indices, expected)
+	}
+	for i := range expected {
+		if indices[i] != expected[i] {
+			t.Fatalf("Read indices %v; expected %v", indices, expected)
+		}
+	}
+}
+
+func TestLoadOBJMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		obj  string
+	}{
+		{"short vertex", "v 0 0\n"},
+		{"bad coordinate", "v 0 zero 0\n"},
+		{"short face", "v 0 0 0\nv 1 0 0\nf 1 2\n"},
+		{"bad index", "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 x\n"},
+		{"index past the end", "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 4\n"},
+		{"zero index", "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 0 1 2\n"},
+		{"negative index past the start", "v 0 0 0\nv 1 0 0\nv 0 1 0\nf -1 -2 -4\n"},
+		{"face before its vertices", "f 1 2 3\nv 0 0 0\nv 1 0 0\nv 0 1 0\n"},
+		{"no faces", "v 0 0 0\nv 1 0 0\nv 0 1 0\n"},
+	}
+	for _, test := range tests {
+		if _, _, err := LoadOBJ(strings.NewReader(test.obj)); err == nil {
+			t.Errorf("LoadOBJ accepted a file with a %s", test.name)
+		}
+	}
+}
+
+func TestTriangleMeshFromOBJ(t *testing.T) {
+	ground, err := NewCollisionTriangleMeshFromOBJ(strings.NewReader(testGroundOBJ))
+	if err != nil {
+		t.Fatalf("NewCollisionTriangleMeshFromOBJ failed: %v", err)
+	}
+	ground.Position = m.Vector3{0.0, -1.0, 0.0}
+
+	w := NewWorld()
+	w.AddCollider(ground)
+	sphere := newTestSphere(m.Vector3{-2.0, 1.0, 3.0})
+	w.AddCollider(sphere)
+	cube := NewCollisionCube(nil, m.Vector3{0.5, 0.5, 0.5})
+	cube.SetDensity(1.0)
+	cube.Body.Position = m.Vector3{3.0, 1.0, -2.0}
+	cube.Body.CalculateDerivedData()
+	cube.CalculateDerivedData()
+	w.AddCollider(cube)
+
+	// a sphere dropped from under the ground passes up through its back
+	below := newTestSphere(m.Vector3{2.0, -3.0, 2.0})
+	below.Body.Velocity = m.Vector3{0.0, 15.0, 0.0}
+	below.Body.GravityScale = 0.0
+	w.AddCollider(below)
+
+	for i := 0; i < 240; i++ {
+		w.Step(1.0 / 60.0)
+	}
+
+	// both bodies come to rest on the ground instead of falling through it
+	if y := sphere.Body.Position[1]; m.RealAbs(y-(-0.5)) > 0.05 {
+		t.Errorf("The sphere settled at a height of %v; expected -0.5", y)
+	}
+	if y := cube.Body.Position[1]; m.RealAbs(y-(-0.5)) > 0.05 {
+		t.Errorf("The cube settled at a height of %v; expected -0.5", y)
+	}
+	if y := below.Body.Position[1]; y < 10.0 {
+		t.Errorf("The sphere from below stopped at a height of %v instead of passing through", y)
+	}
+
+	// the mesh is written out with the world
+	data, err := json.Marshal(w)
+	if err != nil {
+		t.Fatalf("Failed to marshal the world: %v", err)
+	}
+	loaded := NewWorld()
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatalf("Failed to unmarshal the world: %v", err)
+	}
+	if mesh, ok := loaded.Colliders[0].(*CollisionTriangleMesh); !ok || mesh.TriangleCount() != 2 || mesh.Position != ground.Position {
+		t.Errorf("The mesh was loaded as %#v", loaded.Colliders[0])
+	}
+
+	b := ColliderBounds(ground)
+	if b.Min != (m.Vector3{-10.0, -1.0, -10.0}) || b.Max != (m.Vector3{10.0, -1.0, 10.0}) {
+		t.Errorf("The mesh had bounds %v", b)
+	}
+}
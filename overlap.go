@@ -0,0 +1,227 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// capsuleSearchSteps is the number of times the segment of a capsule is
+	// narrowed down when looking for its deepest point in a cube.
+	capsuleSearchSteps = 24
+)
+
+// Overlap holds a collider that a shape passed to an overlap query is
+// touching.
+type Overlap struct {
+	// Collider is the collider that is overlapped.
+	Collider Collider
+
+	// Body is the RigidBody of the collider; this can be nil for colliders
+	// such as planes.
+	Body *RigidBody
+
+	// Point is the deepest point of the shape in the collider, on the
+	// surface of the collider, in World Space.
+	Point m.Vector3
+
+	// Normal is the direction the shape has to move in to get out of the
+	// collider.
+	Normal m.Vector3
+
+	// Depth is how far the shape has to move along the normal to get out of
+	// the collider.
+	Depth m.Real
+}
+
+// OverlapCapsule returns the colliders in the world that a capsule overlaps.
+// The capsule is made of the points within the radius given of the segment
+// between start and end. The broadphase is used to find the candidates if the
+// world has one.
+func (w *World) OverlapCapsule(start *m.Vector3, end *m.Vector3, radius m.Real) []Overlap {
+	var bounds Bounds
+	for i := 0; i < 3; i++ {
+		low, high := start[i], end[i]
+		if low > high {
+			low, high = high, low
+		}
+		bounds.Min[i] = low - radius
+		bounds.Max[i] = high + radius
+	}
+
+	var overlaps []Overlap
+	for _, c := range w.QueryBounds(&bounds) {
+		if hit, overlap := OverlapCapsuleCollider(c, start, end, radius); hit {
+			overlaps = append(overlaps, overlap)
+		}
+	}
+	return overlaps
+}
+
+// OverlapCapsuleCollider checks whether a capsule overlaps a collider and
+// returns true along with how to get out of it if it does. Planes, spheres,
+// capsules, cubes and heightfields are checked.
+func OverlapCapsuleCollider(c Collider, start *m.Vector3, end *m.Vector3, radius m.Real) (bool, Overlap) {
+	var overlap Overlap
+	var point m.Vector3
+	var distance m.Real
+
+	switch shape := c.(type) {
+	case *CollisionPlane:
+		point = *start
+		distance = shape.Distance(start)
+		if d := shape.Distance(end); d < distance {
+			point = *end
+			distance = d
+		}
+		overlap.Normal = shape.Normal
+
+	case *CollisionSphere:
+		center := shape.transform.GetAxis(3)
+		point = closestPointOnSegment(&center, start, end)
+		overlap.Normal = point
+		overlap.Normal.Sub(&center)
+		distance = overlap.Normal.Magnitude()
+		if distance <= m.Epsilon {
+			overlap.Normal = m.Vector3{0.0, 1.0, 0.0}
+		} else {
+			overlap.Normal.MulWith(1.0 / distance)
+		}
+		distance -= shape.Radius
+
+	case *CollisionCapsule:
+		bottom, top := shape.Segment()
+		var closest m.Vector3
+		point, closest = closestPointsOnSegments(start, end, &bottom, &top)
+		overlap.Normal = point
+		overlap.Normal.Sub(&closest)
+		distance = overlap.Normal.Magnitude()
+		if distance <= m.Epsilon {
+			overlap.Normal = m.Vector3{0.0, 1.0, 0.0}
+		} else {
+			overlap.Normal.MulWith(1.0 / distance)
+		}
+		distance -= shape.Radius
+
+	case *CollisionCube:
+		// the signed distance to the cube is convex along the segment, so the
+		// deepest point can be narrowed down by thirds
+		localStart := shape.transform.TransformInverse(start)
+		localEnd := shape.transform.TransformInverse(end)
+		low, high := m.Real(0.0), m.Real(1.0)
+		for i := 0; i < capsuleSearchSteps; i++ {
+			one := low + (high-low)/3.0
+			two := high - (high-low)/3.0
+			pointOne := lerpVector(&localStart, &localEnd, one)
+			pointTwo := lerpVector(&localStart, &localEnd, two)
+			distanceOne, _ := boxSignedDistance(&pointOne, &shape.HalfSize)
+			distanceTwo, _ := boxSignedDistance(&pointTwo, &shape.HalfSize)
+			if distanceOne < distanceTwo {
+				high = two
+			} else {
+				low = one
+			}
+		}
+		local := lerpVector(&localStart, &localEnd, (low+high)*0.5)
+		var localNormal m.Vector3
+		distance, localNormal = boxSignedDistance(&local, &shape.HalfSize)
+		point = shape.transform.MulVector3(&local)
+		overlap.Normal = shape.transform.TransformDirection(&localNormal)
+
+	case *CollisionHeightfield:
+		// the lower end of the capsule is the one that meets the ground
+		distance = m.MaxValue
+		for _, tip := range [2]*m.Vector3{start, end} {
+			found, height := shape.HeightAt(tip[0], tip[2])
+			if !found {
+				continue
+			}
+			_, normal := shape.NormalAt(tip[0], tip[2])
+			if d := (tip[1] - height) * normal[1]; d < distance {
+				point = *tip
+				distance = d
+				overlap.Normal = normal
+			}
+		}
+
+	default:
+		return false, overlap
+	}
+
+	if distance >= radius {
+		return false, overlap
+	}
+	overlap.Collider = c
+	overlap.Body = c.GetBody()
+	overlap.Depth = radius - distance
+	overlap.Point = point
+	overlap.Point.AddScaled(&overlap.Normal, -distance)
+	return true, overlap
+}
+
+// closestPointOnSegment returns the point on the segment between start and end
+// that is closest to the point given.
+func closestPointOnSegment(point *m.Vector3, start *m.Vector3, end *m.Vector3) m.Vector3 {
+	segment := *end
+	segment.Sub(start)
+	toPoint := *point
+	toPoint.Sub(start)
+	length := segment.SquareMagnitude()
+	if length <= m.Epsilon {
+		return *start
+	}
+	t := toPoint.Dot(&segment) / length
+	if t < 0.0 {
+		t = 0.0
+	} else if t > 1.0 {
+		t = 1.0
+	}
+	return lerpVector(start, end, t)
+}
+
+// lerpVector returns the point the fraction t of the way from one to two.
+func lerpVector(one *m.Vector3, two *m.Vector3, t m.Real) m.Vector3 {
+	result := *two
+	result.Sub(one)
+	result.MulWith(t)
+	result.Add(one)
+	return result
+}
+
+// boxSignedDistance returns the distance from a point in the local space of a
+// box to its surface, which is negative inside it, and the direction out of
+// the box at the nearest point of the surface.
+func boxSignedDistance(local *m.Vector3, halfSize *m.Vector3) (m.Real, m.Vector3) {
+	var outside, normal m.Vector3
+	inside := true
+	deepest, axis := -m.MaxValue, 0
+	for i := 0; i < 3; i++ {
+		sign := m.Real(1.0)
+		if local[i] < 0.0 {
+			sign = -1.0
+		}
+		q := m.RealAbs(local[i]) - halfSize[i]
+		if q > 0.0 {
+			outside[i] = q * sign
+			inside = false
+		}
+		if q > deepest {
+			deepest, axis = q, i
+		}
+	}
+
+	if inside {
+		if local[axis] < 0.0 {
+			normal[axis] = -1.0
+		} else {
+			normal[axis] = 1.0
+		}
+		return deepest, normal
+	}
+	distance := outside.Magnitude()
+	normal = outside
+	normal.MulWith(1.0 / distance)
+	return distance, normal
+}
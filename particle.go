@@ -0,0 +1,303 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	defaultParticleDamping = 0.99
+)
+
+// Particle is a point mass that moves without turning. Particles are much
+// cheaper than rigid bodies, so they suit effects with lots of small pieces
+// like sparks and debris, and structures built from masses and links.
+type Particle struct {
+	// Position is the position of the particle in World Space.
+	Position m.Vector3
+
+	// Velocity is the linear velocity of the particle in World Space.
+	Velocity m.Vector3
+
+	// Acceleration is the constant acceleration of the particle, normally
+	// gravity.
+	Acceleration m.Vector3
+
+	// Damping is the amount of velocity the particle keeps after a second,
+	// which soaks up the energy that creeps in from integration.
+	// Defaults to 0.99.
+	Damping m.Real
+
+	// Radius is the size of the particle when it collides with the colliders
+	// of a World. Particles with no radius collide as points.
+	// Defaults to 0.0.
+	Radius m.Real
+
+	// Restitution is how much the particle bounces off of colliders.
+	// Defaults to 0.0.
+	Restitution m.Real
+
+	// inverseMass is the inverse of the mass of the particle, which is zero
+	// for a particle that can't be moved.
+	inverseMass m.Real
+
+	// forceAccum holds the forces to be applied at the next integration.
+	forceAccum m.Vector3
+}
+
+// NewParticle creates a new Particle with a mass of one at the position given.
+func NewParticle(position m.Vector3) *Particle {
+	p := new(Particle)
+	p.Position = position
+	p.Acceleration = defaultAcceleration
+	p.Damping = defaultParticleDamping
+	p.inverseMass = 1.0
+	return p
+}
+
+// SetMass sets the mass of the particle. A mass of zero or less makes the
+// particle immovable, the same as SetInfiniteMass.
+func (p *Particle) SetMass(mass m.Real) {
+	if mass <= 0.0 {
+		p.inverseMass = 0.0
+		return
+	}
+	p.inverseMass = 1.0 / mass
+}
+
+// SetInfiniteMass makes the particle immovable, such as to pin it in place.
+func (p *Particle) SetInfiniteMass() {
+	p.inverseMass = 0.0
+}
+
+// HasFiniteMass returns true if the particle can be moved.
+func (p *Particle) HasFiniteMass() bool {
+	return p.inverseMass > 0.0
+}
+
+// GetMass returns the mass of the particle, or the largest real number if it
+// can't be moved.
+func (p *Particle) GetMass() m.Real {
+	if p.inverseMass == 0.0 {
+		return m.MaxValue
+	}
+	return 1.0 / p.inverseMass
+}
+
+// GetInverseMass returns the inverse of the mass of the particle.
+func (p *Particle) GetInverseMass() m.Real {
+	return p.inverseMass
+}
+
+// AddForce adds a force to be applied to the particle at the next integration.
+func (p *Particle) AddForce(force *m.Vector3) {
+	p.forceAccum.Add(force)
+}
+
+// ClearAccumulator clears the forces stored in the particle.
+func (p *Particle) ClearAccumulator() {
+	p.forceAccum.Clear()
+}
+
+// Integrate moves the particle forward in time by the duration given with
+// semi-implicit Euler integration and clears its forces.
+func (p *Particle) Integrate(duration m.Real) {
+	if p.inverseMass <= 0.0 || duration <= 0.0 {
+		p.ClearAccumulator()
+		return
+	}
+
+	acceleration := p.Acceleration
+	acceleration.AddScaled(&p.forceAccum, p.inverseMass)
+	p.Velocity.AddScaled(&acceleration, duration)
+	p.Velocity.MulWith(m.Real(math.Pow(float64(p.Damping), float64(duration))))
+	p.Position.AddScaled(&p.Velocity, duration)
+	p.ClearAccumulator()
+}
+
+// ParticleForceGenerator adds forces to particles each time it's updated.
+type ParticleForceGenerator interface {
+	// UpdateForces calculates and adds the forces acting on its particles
+	// for a step of the given duration.
+	UpdateForces(duration m.Real)
+}
+
+// ParticleForceFunc adds the forces acting on a particle.
+type ParticleForceFunc func(p *Particle, duration m.Real)
+
+// ParticleSpring is a spring between two particles that follows Hooke's law.
+type ParticleSpring struct {
+	// Particles holds the particles at each end of the spring.
+	Particles [2]*Particle
+
+	// SpringConstant is the stiffness of the spring.
+	SpringConstant m.Real
+
+	// RestLength is the length of the spring when it's at rest.
+	RestLength m.Real
+}
+
+// NewParticleSpring creates a new ParticleSpring between the two particles.
+func NewParticleSpring(one *Particle, two *Particle, springConstant m.Real, restLength m.Real) *ParticleSpring {
+	s := new(ParticleSpring)
+	s.Particles[0] = one
+	s.Particles[1] = two
+	s.SpringConstant = springConstant
+	s.RestLength = restLength
+	return s
+}
+
+// UpdateForces adds the force of the spring to both of its particles.
+func (s *ParticleSpring) UpdateForces(duration m.Real) {
+	force := s.Particles[0].Position
+	force.Sub(&s.Particles[1].Position)
+	length := force.Magnitude()
+	if length <= m.Epsilon {
+		return
+	}
+	force.MulWith(-s.SpringConstant * (length - s.RestLength) / length)
+	s.Particles[0].AddForce(&force)
+	force.MulWith(-1.0)
+	s.Particles[1].AddForce(&force)
+}
+
+// ParticleSystem holds a set of particles along with the forces and links
+// acting on them. It can be stepped on its own, or added to a World where it's
+// stepped after the rigid bodies and its particles collide with the colliders
+// of the world. The rigid bodies aren't pushed back by the particles.
+type ParticleSystem struct {
+	// Particles holds the particles that are integrated each step.
+	Particles []*Particle
+
+	// Generators holds the force generators that are updated each step.
+	Generators []ParticleForceGenerator
+
+	// Links holds the links, such as cables and rods, that generate contacts
+	// each step to hold particles together.
+	Links []ParticleContactGenerator
+
+	// Forces, if not nil, is called for each particle at the start of every
+	// step to add the forces acting on it.
+	Forces ParticleForceFunc
+
+	// Iterations is the number of times the contacts are resolved each
+	// step. A value of zero or less uses twice the number of contacts.
+	// Defaults to 0.
+	Iterations int
+
+	// contacts holds the contacts that were generated in the last step.
+	contacts []*ParticleContact
+
+	// pool holds the contacts with colliders so that they're reused each
+	// step instead of being allocated again.
+	pool particleContactPool
+
+	// candidates holds the colliders found near a particle, kept between
+	// steps so that the list doesn't have to grow again.
+	candidates []Collider
+}
+
+// NewParticleSystem creates a new, empty ParticleSystem.
+func NewParticleSystem() *ParticleSystem {
+	return new(ParticleSystem)
+}
+
+// AddParticle adds the particle to the system.
+func (ps *ParticleSystem) AddParticle(p *Particle) {
+	ps.Particles = append(ps.Particles, p)
+}
+
+// RemoveParticle removes the particle from the system. Any links or
+// generators that use it are left in place.
+func (ps *ParticleSystem) RemoveParticle(p *Particle) {
+	for i, existing := range ps.Particles {
+		if existing == p {
+			ps.Particles = append(ps.Particles[:i], ps.Particles[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetContacts returns the contacts that were generated in the last step. The
+// contacts with colliders are reused by the next step, so copy any that need
+// to be kept.
+func (ps *ParticleSystem) GetContacts() []*ParticleContact {
+	return ps.contacts
+}
+
+// Step advances the particles through time by the duration given without
+// colliding them with anything but each other through the links.
+func (ps *ParticleSystem) Step(duration m.Real) {
+	ps.step(duration, nil)
+}
+
+// step advances the particles through time by the duration given, colliding
+// them against the colliders of the world, if there is one, that the world's
+// broadphase finds around each particle.
+func (ps *ParticleSystem) step(duration m.Real, w *World) {
+	for _, p := range ps.Particles {
+		if ps.Forces != nil {
+			ps.Forces(p, duration)
+		}
+	}
+	for _, g := range ps.Generators {
+		g.UpdateForces(duration)
+	}
+	for _, p := range ps.Particles {
+		p.Integrate(duration)
+	}
+
+	ps.contacts = ps.pool.reset()
+	for _, link := range ps.Links {
+		_, ps.contacts = link.AddContacts(ps.contacts)
+	}
+	if w != nil {
+		for _, p := range ps.Particles {
+			bounds := m.AABBAround(&p.Position, p.Radius)
+			ps.candidates = w.queryBounds(&bounds, ps.candidates[:0])
+			for _, c := range ps.candidates {
+				_, ps.contacts = particleColliderContact(p, c, ps.contacts, &ps.pool)
+			}
+		}
+	}
+	ps.pool.buffer = ps.contacts
+
+	iterations := ps.Iterations
+	if iterations <= 0 {
+		iterations = len(ps.contacts) * 2
+	}
+	ResolveParticleContacts(iterations, ps.contacts, duration)
+}
+
+// AddParticleSystem adds the particle system to the world so that it's
+// stepped along with the rigid bodies.
+func (w *World) AddParticleSystem(ps *ParticleSystem) {
+	for _, existing := range w.ParticleSystems {
+		if existing == ps {
+			return
+		}
+	}
+	w.ParticleSystems = append(w.ParticleSystems, ps)
+}
+
+// RemoveParticleSystem removes the particle system from the world.
+func (w *World) RemoveParticleSystem(ps *ParticleSystem) {
+	for i, existing := range w.ParticleSystems {
+		if existing == ps {
+			w.ParticleSystems = append(w.ParticleSystems[:i], w.ParticleSystems[i+1:]...)
+			return
+		}
+	}
+}
+
+// stepParticles steps the particle systems in the world, colliding their
+// particles with the colliders of the world.
+func (w *World) stepParticles(duration m.Real) {
+	for _, ps := range w.ParticleSystems {
+		ps.step(duration, w)
+	}
+}
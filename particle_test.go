@@ -0,0 +1,249 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"testing"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+func TestParticleZeroMass(t *testing.T) {
+	p := NewParticle(m.Vector3{0.0, 1.0, 0.0})
+	p.SetMass(0.0)
+	if p.HasFiniteMass() || p.GetInverseMass() != 0.0 || p.GetMass() != m.MaxValue {
+		t.Fatalf("A particle with no mass had an inverse mass of %v", p.GetInverseMass())
+	}
+	p.Integrate(1.0)
+	if p.Position != (m.Vector3{0.0, 1.0, 0.0}) {
+		t.Errorf("A particle with no mass moved to %v", p.Position)
+	}
+
+	p.SetMass(4.0)
+	if p.GetInverseMass() != 0.25 {
+		t.Errorf("A particle with a mass of 4 had an inverse mass of %v", p.GetInverseMass())
+	}
+}
+
+func TestParticleContactBounce(t *testing.T) {
+	// two particles meeting head on with full restitution swap velocities
+	one := NewParticle(m.Vector3{-0.1, 0.0, 0.0})
+	one.Velocity = m.Vector3{2.0, 0.0, 0.0}
+	two := NewParticle(m.Vector3{0.1, 0.0, 0.0})
+	two.Velocity = m.Vector3{-1.0, 0.0, 0.0}
+	contact := &ParticleContact{
+		Particles:     [2]*Particle{one, two},
+		ContactNormal: m.Vector3{-1.0, 0.0, 0.0},
+		Penetration:   0.2,
+		Restitution:   1.0,
+	}
+	one.Acceleration.Clear()
+	two.Acceleration.Clear()
+	ResolveParticleContacts(4, []*ParticleContact{contact}, 1.0/60.0)
+
+	if m.RealAbs(one.Velocity[0]-(-1.0)) > 1e-5 || m.RealAbs(two.Velocity[0]-2.0) > 1e-5 {
+		t.Errorf("The particles bounced apart at %v and %v; expected -1 and 2", one.Velocity[0], two.Velocity[0])
+	}
+	if m.RealAbs(one.Position[0]-(-0.2)) > 1e-5 || m.RealAbs(two.Position[0]-0.2) > 1e-5 {
+		t.Errorf("The particles were pushed apart to %v and %v; expected -0.2 and 0.2", one.Position[0], two.Position[0])
+	}
+}
+
+func TestParticleLinks(t *testing.T) {
+	// a pendulum on a rod and a weight hanging from a cable
+	ps := NewParticleSystem()
+	pivot := NewParticle(m.Vector3{0.0, 0.0, 0.0})
+	pivot.SetInfiniteMass()
+	bob := NewParticle(m.Vector3{2.0, 0.0, 0.0})
+	hook := NewParticle(m.Vector3{5.0, 0.0, 0.0})
+	hook.SetInfiniteMass()
+	weight := NewParticle(m.Vector3{5.0, -0.5, 0.0})
+	for _, p := range []*Particle{pivot, bob, hook, weight} {
+		ps.AddParticle(p)
+	}
+	rod := NewParticleRod(pivot, bob, 2.0)
+	cable := NewParticleCable(hook, weight, 1.0, 0.0)
+	ps.Links = append(ps.Links, rod, cable)
+
+	lowest := m.Real(0.0)
+	for i := 0; i < 300; i++ {
+		ps.Step(1.0 / 60.0)
+		if length := rod.CurrentLength(); m.RealAbs(length-2.0) > 1e-3 {
+			t.Fatalf("The rod was %v long at step %d; expected 2", length, i)
+		}
+		if length := cable.CurrentLength(); length > 1.0+1e-3 {
+			t.Fatalf("The cable stretched to %v at step %d; expected at most 1", length, i)
+		}
+		if bob.Position[1] < lowest {
+			lowest = bob.Position[1]
+		}
+	}
+
+	// the pendulum swung down through the bottom of its arc and the weight
+	// hangs at the end of the cable
+	if lowest > -1.9 {
+		t.Errorf("The pendulum only swung down to %v", lowest)
+	}
+	if y := weight.Position[1]; m.RealAbs(y-(-1.0)) > 1e-2 {
+		t.Errorf("The weight hung at a height of %v; expected -1", y)
+	}
+}
+
+// newTestParticleWorld creates a world with the ground and a box on it, and
+// a particle system with particles over both.
+func newTestParticleWorld(broadphase Broadphase) (*World, *ParticleSystem) {
+	w := NewWorld()
+	if broadphase != nil {
+		w.SetBroadphase(broadphase)
+	}
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	box := NewCollisionCube(nil, m.Vector3{1.0, 1.0, 1.0})
+	box.Body.SetInfiniteMass()
+	box.Body.GravityScale = 0.0
+	box.Body.SetAwake(false)
+	box.Body.Position = m.Vector3{0.0, 1.0, 0.0}
+	box.Body.CalculateDerivedData()
+	box.CalculateDerivedData()
+	w.AddCollider(box)
+	for i := 0; i < 20; i++ {
+		w.AddCollider(newTestSphere(m.Vector3{20.0 + m.Real(i)*2.0, 0.5, 0.0}))
+	}
+
+	ps := NewParticleSystem()
+	for i := 0; i < 10; i++ {
+		p := NewParticle(m.Vector3{m.Real(i) - 5.0, 3.0, 0.0})
+		p.Radius = 0.1
+		ps.AddParticle(p)
+	}
+	w.AddParticleSystem(ps)
+	return w, ps
+}
+
+func TestParticleColliderContacts(t *testing.T) {
+	w, ps := newTestParticleWorld(NewSweepAndPruneBroadphase())
+	var reused *ParticleContact
+	for i := 0; i < 120; i++ {
+		w.Step(1.0 / 60.0)
+		if contacts := ps.GetContacts(); len(contacts) > 0 {
+			if reused != nil && contacts[0] != reused {
+				t.Errorf("Step %d made new contacts instead of reusing them", i)
+			}
+			reused = contacts[0]
+		}
+	}
+
+	// the particles come to rest on top of the box or the ground
+	for _, p := range ps.Particles {
+		expected := m.Real(0.1)
+		if m.RealAbs(p.Position[0]) <= 1.0 {
+			expected = 2.1
+		}
+		if m.RealAbs(p.Position[1]-expected) > 1e-2 {
+			t.Errorf("A particle at %v settled at a height of %v; expected %v", p.Position[0], p.Position[1], expected)
+		}
+	}
+
+	// the broadphase only hands over the colliders near the particles, and
+	// they end up in the same place as when every collider is checked
+	bounds := m.AABBAround(&ps.Particles[0].Position, ps.Particles[0].Radius)
+	if near := w.QueryBounds(&bounds); len(near) != 1 {
+		t.Errorf("The broadphase found %d colliders near a particle on the ground; expected 1", len(near))
+	}
+	unsorted, checked := newTestParticleWorld(nil)
+	for i := 0; i < 120; i++ {
+		unsorted.Step(1.0 / 60.0)
+	}
+	for i, p := range checked.Particles {
+		offset := p.Position
+		offset.Sub(&ps.Particles[i].Position)
+		if offset.Magnitude() > 1e-5 {
+			t.Errorf("Particle %d ended at %v with a broadphase and %v without", i, ps.Particles[i].Position, p.Position)
+		}
+	}
+}
+
+func TestClothHangs(t *testing.T) {
+	// a cloth with no mass given still falls, hanging from the two corners
+	// of its top edge until they're let go and it lies on the ground
+	w := NewWorld()
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	c := NewCloth(m.Vector3{0.0, 3.0, 0.0}, m.Vector3{2.0, 0.0, 0.0}, m.Vector3{0.0, 0.0, 2.0}, 9, 9, 0.0, 0.05)
+	if mass := c.Particle(4, 4).GetMass(); m.RealAbs(mass-1.0/81.0) > 1e-5 {
+		t.Fatalf("A particle of a cloth with no mass given had a mass of %v; expected 1/81", mass)
+	}
+	c.Pin(0, 0)
+	c.Pin(8, 0)
+	w.AddCloth(c)
+
+	for i := 0; i < 300; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	if p := c.Particle(0, 0); p.Position != (m.Vector3{0.0, 3.0, 0.0}) {
+		t.Errorf("A pinned corner moved to %v", p.Position)
+	}
+	if y := c.Particle(4, 8).Position[1]; y > 1.5 {
+		t.Errorf("The bottom of the cloth only hung down to %v", y)
+	}
+	for _, cable := range c.Structural {
+		if length := cable.CurrentLength(); length > cable.MaxLength*1.1 {
+			t.Fatalf("The cloth stretched a link to %v, over a tenth more than its length of %v", length, cable.MaxLength)
+		}
+	}
+
+	c.Unpin(0, 0)
+	c.Unpin(8, 0)
+	for i := 0; i < 300; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	for i, p := range c.Particles {
+		if y := p.Position[1]; y < 0.0 || y > 0.2 {
+			t.Fatalf("Particle %d of the dropped cloth came to rest at a height of %v", i, y)
+		}
+	}
+}
+
+func TestSoftBodyLands(t *testing.T) {
+	// a soft box dropped on the ground comes to rest on it without losing
+	// its volume, and a softer one squashes further under its own weight
+	var heights [2]m.Real
+	for i, compliance := range []m.Real{0.0, 1e-2} {
+		w := NewWorld()
+		w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+		sb, err := NewSoftBox(m.Vector3{0.0, 2.0, 0.0}, m.Vector3{0.5, 0.5, 0.5}, 2, 10.0, 0.05)
+		if err != nil {
+			t.Fatalf("NewSoftBox failed: %v", err)
+		}
+		sb.EdgeCompliance = compliance
+		w.AddSoftBody(sb)
+		rest := sb.GetVolume()
+		if m.RealAbs(rest-1.0) > 1e-4 {
+			t.Fatalf("The soft box had a volume of %v; expected 1", rest)
+		}
+
+		for step := 0; step < 180; step++ {
+			w.Step(1.0 / 60.0)
+		}
+		lowest := m.MaxValue
+		for _, p := range sb.Particles {
+			if p.Position[1] < lowest {
+				lowest = p.Position[1]
+			}
+		}
+		if m.RealAbs(lowest-0.05) > 0.02 {
+			t.Errorf("The soft box came to rest with its bottom at %v; expected 0.05", lowest)
+		}
+		if volume := sb.GetVolume(); m.RealAbs(volume-rest) > 0.05*rest {
+			t.Errorf("The soft box landed with a volume of %v; expected %v", volume, rest)
+		}
+		heights[i] = sb.GetCenter()[1]
+	}
+	if heights[1] > heights[0]-0.01 {
+		t.Errorf("A softer box rested with its center at %v, barely lower than a stiff one at %v", heights[1], heights[0])
+	}
+
+	flat := []m.Vector3{{0.0, 0.0, 0.0}, {1.0, 0.0, 0.0}, {0.0, 0.0, 1.0}, {1.0, 0.0, 1.0}}
+	if _, err := NewSoftBody(flat, [][4]int{{0, 1, 2, 3}}, 1.0, 0.05); err == nil {
+		t.Error("A soft body was made from a flat tetrahedron")
+	}
+}
@@ -0,0 +1,413 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// ParticleContact is a contact between two particles, or between a particle
+// and immovable scenery when the second particle is nil.
+type ParticleContact struct {
+	// Particles holds the particles in the contact. The second can be nil.
+	Particles [2]*Particle
+
+	// ContactNormal is the direction the first particle is pushed in to
+	// resolve the contact; the second is pushed the other way.
+	ContactNormal m.Vector3
+
+	// Penetration is how far the particles overlap along the normal.
+	Penetration m.Real
+
+	// Restitution is how much the particles bounce apart.
+	Restitution m.Real
+
+	// Friction is the friction coefficient of the contact: the sliding speed
+	// of the particles is slowed by up to this times the speed they're pushed
+	// apart by.
+	// Defaults to 0.0.
+	Friction m.Real
+}
+
+// ParticleContactGenerator generates contacts between particles, such as to
+// hold them together with links.
+type ParticleContactGenerator interface {
+	// AddContacts appends any contacts that are needed to the existing
+	// contacts and returns true if there were any.
+	AddContacts(existingContacts []*ParticleContact) (bool, []*ParticleContact)
+}
+
+// separatingVelocity returns the speed the particles are moving apart at
+// along the normal.
+func (c *ParticleContact) separatingVelocity() m.Real {
+	velocity := c.Particles[0].Velocity
+	if c.Particles[1] != nil {
+		velocity.Sub(&c.Particles[1].Velocity)
+	}
+	return velocity.Dot(&c.ContactNormal)
+}
+
+// totalInverseMass returns the sum of the inverse masses of the particles.
+func (c *ParticleContact) totalInverseMass() m.Real {
+	total := c.Particles[0].inverseMass
+	if c.Particles[1] != nil {
+		total += c.Particles[1].inverseMass
+	}
+	return total
+}
+
+// resolveVelocity applies the impulse that sends the particles apart at the
+// speed the restitution calls for.
+func (c *ParticleContact) resolveVelocity(duration m.Real) {
+	separating := c.separatingVelocity()
+	if separating > 0.0 {
+		return
+	}
+	newSeparating := -separating * c.Restitution
+
+	// don't bounce back the speed that built up from the acceleration in
+	// the last step, so resting particles stay at rest
+	acceleration := c.Particles[0].Acceleration
+	if c.Particles[1] != nil {
+		acceleration.Sub(&c.Particles[1].Acceleration)
+	}
+	if built := acceleration.Dot(&c.ContactNormal) * duration; built < 0.0 {
+		newSeparating += c.Restitution * built
+		if newSeparating < 0.0 {
+			newSeparating = 0.0
+		}
+	}
+
+	totalInverseMass := c.totalInverseMass()
+	if totalInverseMass <= 0.0 {
+		return
+	}
+	impulse := (newSeparating - separating) / totalInverseMass
+
+	// take away some of the sliding speed, no more than the push allows
+	var sliding m.Vector3
+	if c.Friction > 0.0 {
+		relative := c.Particles[0].Velocity
+		if c.Particles[1] != nil {
+			relative.Sub(&c.Particles[1].Velocity)
+		}
+		sliding = relative
+		sliding.AddScaled(&c.ContactNormal, -separating)
+		if speed := sliding.Magnitude(); speed > m.Epsilon {
+			change := c.Friction * impulse * totalInverseMass
+			if change > speed {
+				change = speed
+			}
+			sliding.MulWith(-change / speed / totalInverseMass)
+		} else {
+			sliding.Clear()
+		}
+	}
+
+	for i, p := range c.Particles {
+		if p == nil {
+			continue
+		}
+		sign := m.Real(1.0)
+		if i == 1 {
+			sign = -1.0
+		}
+		p.Velocity.AddScaled(&c.ContactNormal, sign*impulse*p.inverseMass)
+		p.Velocity.AddScaled(&sliding, sign*p.inverseMass)
+	}
+}
+
+// resolveInterpenetration moves the particles apart in proportion to their
+// inverse masses, returning how far each one moved.
+func (c *ParticleContact) resolveInterpenetration() [2]m.Vector3 {
+	var moved [2]m.Vector3
+	if c.Penetration <= 0.0 {
+		return moved
+	}
+	totalInverseMass := c.totalInverseMass()
+	if totalInverseMass <= 0.0 {
+		return moved
+	}
+
+	perMass := c.ContactNormal
+	perMass.MulWith(c.Penetration / totalInverseMass)
+	for i, p := range c.Particles {
+		if p == nil {
+			continue
+		}
+		moved[i] = perMass
+		moved[i].MulWith(p.inverseMass)
+		if i == 1 {
+			moved[i].MulWith(-1.0)
+		}
+		p.Position.Add(&moved[i])
+	}
+	return moved
+}
+
+// ResolveParticleContacts resolves the velocity and penetration of the
+// contacts, taking the one that is closing fastest each time, for up to the
+// number of iterations given.
+func ResolveParticleContacts(maxIterations int, contacts []*ParticleContact, duration m.Real) {
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		// find the contact with the largest closing velocity
+		max := m.MaxValue
+		index := len(contacts)
+		for i, c := range contacts {
+			separating := c.separatingVelocity()
+			if separating < max && (separating < 0.0 || c.Penetration > 0.0) {
+				max = separating
+				index = i
+			}
+		}
+		if index == len(contacts) {
+			break
+		}
+
+		resolved := contacts[index]
+		resolved.resolveVelocity(duration)
+		moved := resolved.resolveInterpenetration()
+
+		// the particles that moved change the penetration of the other
+		// contacts they're in
+		for _, c := range contacts {
+			for i, p := range resolved.Particles {
+				if p == nil {
+					continue
+				}
+				if c.Particles[0] == p {
+					c.Penetration -= moved[i].Dot(&c.ContactNormal)
+				} else if c.Particles[1] == p {
+					c.Penetration += moved[i].Dot(&c.ContactNormal)
+				}
+			}
+		}
+	}
+}
+
+// ParticleCable links two particles so that they can't move further apart
+// than its length, but can come together freely.
+type ParticleCable struct {
+	// Particles holds the particles at each end of the cable.
+	Particles [2]*Particle
+
+	// MaxLength is the length of the cable.
+	MaxLength m.Real
+
+	// Restitution is how much the particles bounce back when the cable
+	// goes taut.
+	Restitution m.Real
+}
+
+// NewParticleCable creates a new ParticleCable between the two particles.
+func NewParticleCable(one *Particle, two *Particle, maxLength m.Real, restitution m.Real) *ParticleCable {
+	c := new(ParticleCable)
+	c.Particles[0] = one
+	c.Particles[1] = two
+	c.MaxLength = maxLength
+	c.Restitution = restitution
+	return c
+}
+
+// CurrentLength returns the distance between the particles.
+func (c *ParticleCable) CurrentLength() m.Real {
+	return particleDistance(&c.Particles)
+}
+
+// AddContacts generates a contact if the cable is overextended.
+func (c *ParticleCable) AddContacts(existingContacts []*ParticleContact) (bool, []*ParticleContact) {
+	length := c.CurrentLength()
+	if length <= c.MaxLength {
+		return false, existingContacts
+	}
+	contact := newParticleLinkContact(&c.Particles, length-c.MaxLength)
+	contact.Restitution = c.Restitution
+	return true, append(existingContacts, contact)
+}
+
+// ParticleRod links two particles so that they stay the same distance apart.
+type ParticleRod struct {
+	// Particles holds the particles at each end of the rod.
+	Particles [2]*Particle
+
+	// Length is the length of the rod.
+	Length m.Real
+}
+
+// NewParticleRod creates a new ParticleRod between the two particles.
+func NewParticleRod(one *Particle, two *Particle, length m.Real) *ParticleRod {
+	r := new(ParticleRod)
+	r.Particles[0] = one
+	r.Particles[1] = two
+	r.Length = length
+	return r
+}
+
+// CurrentLength returns the distance between the particles.
+func (r *ParticleRod) CurrentLength() m.Real {
+	return particleDistance(&r.Particles)
+}
+
+// AddContacts generates a contact if the rod isn't at its length.
+func (r *ParticleRod) AddContacts(existingContacts []*ParticleContact) (bool, []*ParticleContact) {
+	length := r.CurrentLength()
+	if length == r.Length {
+		return false, existingContacts
+	}
+	contact := newParticleLinkContact(&r.Particles, length-r.Length)
+	if contact.Penetration < 0.0 {
+		contact.ContactNormal.MulWith(-1.0)
+		contact.Penetration = -contact.Penetration
+	}
+	return true, append(existingContacts, contact)
+}
+
+// particleDistance returns the distance between two particles.
+func particleDistance(particles *[2]*Particle) m.Real {
+	separation := particles[0].Position
+	separation.Sub(&particles[1].Position)
+	return separation.Magnitude()
+}
+
+// newParticleLinkContact creates a contact that pulls the particles together
+// by the amount given.
+func newParticleLinkContact(particles *[2]*Particle, stretch m.Real) *ParticleContact {
+	contact := new(ParticleContact)
+	contact.Particles = *particles
+	contact.ContactNormal = particles[1].Position
+	contact.ContactNormal.Sub(&particles[0].Position)
+	contact.ContactNormal.Normalize()
+	contact.Penetration = stretch
+	return contact
+}
+
+// particleContactPool hands out particle contacts that are reused after each
+// reset, the same way contactPool does for the contacts between colliders.
+type particleContactPool struct {
+	// contacts holds every contact the pool has made, of which the first
+	// used have been handed out since the last reset.
+	contacts []*ParticleContact
+	used     int
+
+	// buffer is kept between steps to hold the system's list of contacts so
+	// that the list doesn't have to grow again each step.
+	buffer []*ParticleContact
+}
+
+// get returns a cleared contact from the pool, or a new one if the pool is nil.
+func (p *particleContactPool) get() *ParticleContact {
+	if p == nil {
+		return new(ParticleContact)
+	}
+	if p.used == len(p.contacts) {
+		p.contacts = append(p.contacts, new(ParticleContact))
+	}
+	c := p.contacts[p.used]
+	p.used++
+	*c = ParticleContact{}
+	return c
+}
+
+// reset hands all of the contacts back to the pool, to be reused, and
+// returns an empty list to collect the step's contacts in.
+func (p *particleContactPool) reset() []*ParticleContact {
+	p.used = 0
+	return p.buffer[:0]
+}
+
+// particleColliderContact generates a contact if the particle is touching
+// the collider and appends it to the existing contacts. Only planes, spheres,
+// capsules and cubes are checked. The contact is
+// taken from the pool, which can be nil.
+func particleColliderContact(p *Particle, c Collider, existingContacts []*ParticleContact, pool *particleContactPool) (bool, []*ParticleContact) {
+	var normal m.Vector3
+	var depth m.Real
+
+	switch shape := c.(type) {
+	case *CollisionPlane:
+		distance := shape.Distance(&p.Position)
+		if distance >= p.Radius {
+			return false, existingContacts
+		}
+		normal = shape.Normal
+		depth = p.Radius - distance
+
+	case *CollisionSphere:
+		center := shape.transform.GetAxis(3)
+		normal = p.Position
+		normal.Sub(&center)
+		distance := normal.Magnitude()
+		if distance >= shape.Radius+p.Radius || distance <= m.Epsilon {
+			return false, existingContacts
+		}
+		normal.MulWith(1.0 / distance)
+		depth = shape.Radius + p.Radius - distance
+
+	case *CollisionCapsule:
+		bottom, top := shape.Segment()
+		closest := closestPointOnSegment(&p.Position, &bottom, &top)
+		normal = p.Position
+		normal.Sub(&closest)
+		distance := normal.Magnitude()
+		if distance >= shape.Radius+p.Radius || distance <= m.Epsilon {
+			return false, existingContacts
+		}
+		normal.MulWith(1.0 / distance)
+		depth = shape.Radius + p.Radius - distance
+
+	case *CollisionCube:
+		local := shape.transform.TransformInverse(&p.Position)
+
+		// find the closest point on the cube, or the nearest face if the
+		// particle is inside it
+		closest := local
+		inside := true
+		for i := 0; i < 3; i++ {
+			if closest[i] > shape.HalfSize[i] {
+				closest[i] = shape.HalfSize[i]
+				inside = false
+			} else if closest[i] < -shape.HalfSize[i] {
+				closest[i] = -shape.HalfSize[i]
+				inside = false
+			}
+		}
+		var localNormal m.Vector3
+		if inside {
+			axis := 0
+			depth = m.MaxValue
+			for i := 0; i < 3; i++ {
+				if d := shape.HalfSize[i] - m.RealAbs(local[i]); d < depth {
+					depth = d
+					axis = i
+				}
+			}
+			localNormal[axis] = 1.0
+			if local[axis] < 0.0 {
+				localNormal[axis] = -1.0
+			}
+			depth += p.Radius
+		} else {
+			localNormal = local
+			localNormal.Sub(&closest)
+			distance := localNormal.Magnitude()
+			if distance >= p.Radius || distance <= m.Epsilon {
+				return false, existingContacts
+			}
+			localNormal.MulWith(1.0 / distance)
+			depth = p.Radius - distance
+		}
+		normal = shape.transform.TransformDirection(&localNormal)
+
+	default:
+		return false, existingContacts
+	}
+
+	contact := pool.get()
+	contact.Particles[0] = p
+	contact.ContactNormal = normal
+	contact.Penetration = depth
+	contact.Restitution = p.Restitution
+	contact.Friction = c.GetMaterial().Friction
+	return true, append(existingContacts, contact)
+}
@@ -0,0 +1,411 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// defaultSplinePathSamples is the number of straight pieces each span of
+	// a spline path is made of.
+	defaultSplinePathSamples = 16
+)
+
+// Path is a line through a list of points that a PathFollower moves a body
+// along.
+type Path struct {
+	// Points holds the points of the path, in World Space, in order.
+	Points []m.Vector3
+
+	// Closed joins the last point back to the first.
+	Closed bool
+
+	// distances holds the distance along the path to each point, with the
+	// distance back to the first point at the end if the path is closed.
+	distances []m.Real
+}
+
+// NewPath creates a new Path of straight lines between the waypoints given.
+func NewPath(waypoints []m.Vector3, closed bool) *Path {
+	p := new(Path)
+	p.Points = append([]m.Vector3(nil), waypoints...)
+	p.Closed = closed
+	p.measure()
+	return p
+}
+
+// NewSplinePath creates a new Path that curves smoothly through the control
+// points given along a Catmull-Rom spline. The curve is made of straight
+// pieces, the number given for each span between two control points.
+func NewSplinePath(controlPoints []m.Vector3, closed bool, samples int) *Path {
+	if samples < 1 {
+		samples = defaultSplinePathSamples
+	}
+	count := len(controlPoints)
+	if count < 3 {
+		return NewPath(controlPoints, closed)
+	}
+
+	spline := m.CatmullRom{Points: controlPoints, Closed: closed}
+	var points []m.Vector3
+	for span := 0; span < spline.Spans(); span++ {
+		for sample := 0; sample < samples; sample++ {
+			t := m.Real(sample) / m.Real(samples)
+			points = append(points, spline.SpanPoint(span, t))
+		}
+	}
+	if !closed {
+		points = append(points, controlPoints[count-1])
+	}
+	return NewPath(points, closed)
+}
+
+// NewCurvePath creates a new Path along any curve, such as a m.Bezier, made
+// of the number of straight pieces given. A closed path joins the end of the
+// curve back to its start.
+func NewCurvePath(curve m.Curve, closed bool, samples int) *Path {
+	if samples < 1 {
+		samples = defaultSplinePathSamples
+	}
+	end := curve.End()
+	points := make([]m.Vector3, 0, samples+1)
+	for sample := 0; sample <= samples; sample++ {
+		points = append(points, curve.Point(end*m.Real(sample)/m.Real(samples)))
+	}
+	return NewPath(points, closed)
+}
+
+// measure works out the distance along the path to each point.
+func (p *Path) measure() {
+	p.distances = p.distances[:0]
+	var total m.Real
+	for i := range p.Points {
+		if i > 0 {
+			step := p.Points[i]
+			step.Sub(&p.Points[i-1])
+			total += step.Magnitude()
+		}
+		p.distances = append(p.distances, total)
+	}
+	if p.Closed && len(p.Points) > 1 {
+		step := p.Points[0]
+		step.Sub(&p.Points[len(p.Points)-1])
+		p.distances = append(p.distances, total+step.Magnitude())
+	}
+}
+
+// Length returns the length of the path.
+func (p *Path) Length() m.Real {
+	if len(p.distances) == 0 {
+		return 0.0
+	}
+	return p.distances[len(p.distances)-1]
+}
+
+// PointAt returns the point the distance given along the path and the
+// direction the path runs in there. Distances past either end are clamped to
+// the ends, or wrapped around if the path is closed.
+func (p *Path) PointAt(distance m.Real) (m.Vector3, m.Vector3) {
+	if len(p.Points) == 0 {
+		return m.Vector3{}, m.Vector3{}
+	}
+	length := p.Length()
+	if len(p.Points) == 1 || length <= m.Epsilon {
+		return p.Points[0], m.Vector3{}
+	}
+	if p.Closed {
+		for distance < 0.0 {
+			distance += length
+		}
+		for distance >= length {
+			distance -= length
+		}
+	} else if distance < 0.0 {
+		distance = 0.0
+	} else if distance > length {
+		distance = length
+	}
+
+	// find the piece of the path the distance falls in
+	piece := 0
+	for piece < len(p.distances)-2 && p.distances[piece+1] < distance {
+		piece++
+	}
+	start := p.Points[piece]
+	end := p.Points[(piece+1)%len(p.Points)]
+	direction := end
+	direction.Sub(&start)
+	span := p.distances[piece+1] - p.distances[piece]
+	if span <= m.Epsilon {
+		return start, m.Vector3{}
+	}
+	point := start
+	point.AddScaled(&direction, (distance-p.distances[piece])/span)
+	direction.MulWith(1.0 / span)
+	return point, direction
+}
+
+// tangentAt returns the direction of the path the distance given along it,
+// blended with the direction of the neighbouring piece near each point so
+// that it turns smoothly rather than all at once at the points.
+func (p *Path) tangentAt(distance m.Real) m.Vector3 {
+	length := p.Length()
+	_, direction := p.PointAt(distance)
+	if len(p.Points) < 3 || length <= m.Epsilon {
+		return direction
+	}
+
+	// the direction of the path half a piece either side of the distance
+	piece := length / m.Real(len(p.distances)-1)
+	_, before := p.PointAt(distance - 0.5*piece)
+	_, after := p.PointAt(distance + 0.5*piece)
+	direction.Add(&before)
+	direction.Add(&after)
+	if direction.SquareMagnitude() <= m.Epsilon {
+		_, direction = p.PointAt(distance)
+		return direction
+	}
+	direction.Normalize()
+	return direction
+}
+
+// Easing maps the fraction of the way along a leg of a path, by time, to the
+// fraction of the way along it by distance.
+type Easing func(t m.Real) m.Real
+
+// EaseLinear moves at a constant speed.
+func EaseLinear(t m.Real) m.Real {
+	return t
+}
+
+// EaseInOut speeds up from a standstill and slows down to one again.
+func EaseInOut(t m.Real) m.Real {
+	return t * t * (3.0 - 2.0*t)
+}
+
+// PathMode is what a PathFollower does when it reaches the end of its path.
+type PathMode uint8
+
+const (
+	// PathOnce stops at the end of the path.
+	PathOnce PathMode = iota
+
+	// PathLoop starts again from the beginning of the path.
+	PathLoop
+
+	// PathPingPong turns around and goes back along the path.
+	PathPingPong
+)
+
+// PathFollower drives a body along a Path at a set speed, like a lift, a
+// moving platform or a train. The body is moved by giving it the velocity that
+// takes it to the next point on the path in each step, so that bodies resting
+// on it are carried along by the contacts. When a follower is added to a
+// World it drives its body at the start of each step.
+type PathFollower struct {
+	// Body is the body that is moved.
+	Body *RigidBody
+
+	// Path is the path the body moves along.
+	Path *Path
+
+	// Speed is the speed the body moves along the path at. With an easing
+	// other than EaseLinear it's the average speed of each leg.
+	Speed m.Real
+
+	// Easing shapes the speed along each leg of the path, which is the whole
+	// path for PathOnce and PathLoop and each way along it for PathPingPong.
+	// Defaults to EaseLinear.
+	Easing Easing
+
+	// Mode is what the follower does at the end of the path. A body looping
+	// around a path that isn't closed jumps back to the start.
+	// Defaults to PathOnce.
+	Mode PathMode
+
+	// Orient turns the body so that its Forward axis points along the path.
+	// Defaults to false.
+	Orient bool
+
+	// Forward is the axis of the body, in Body Space, that faces along the
+	// path when Orient is set.
+	// Defaults to the Z axis.
+	Forward m.Vector3
+
+	// Up is the direction, in World Space, that the body turns about to face
+	// along the path when Orient is set. The body then tilts up and down with
+	// the path but never rolls.
+	// Defaults to the Y axis.
+	Up m.Vector3
+
+	// Paused stops the body where it is.
+	// Defaults to false.
+	Paused bool
+
+	// elapsed is the time spent on the current leg.
+	elapsed m.Real
+
+	// reverse is true while going back along the path in PathPingPong mode.
+	reverse bool
+
+	// finished is true once a PathOnce follower reaches the end.
+	finished bool
+}
+
+// NewPathFollower creates a new PathFollower that moves the body along the
+// path at the speed given, starting from the beginning of the path. The body
+// is made kinematic, with infinite mass and no gravity or damping, so that
+// nothing but the follower moves it.
+func NewPathFollower(body *RigidBody, path *Path, speed m.Real) *PathFollower {
+	body.SetInfiniteMass()
+	body.InverseInertiaTensor = m.Matrix3{}
+	body.CalculateDerivedData()
+	body.GravityScale = 0.0
+	body.LinearDamping = 1.0
+	body.AngularDamping = 1.0
+
+	pf := new(PathFollower)
+	pf.Body = body
+	pf.Path = path
+	pf.Speed = speed
+	pf.Easing = EaseLinear
+	pf.Forward = m.Vector3{0.0, 0.0, 1.0}
+	pf.Up = m.Vector3{0.0, 1.0, 0.0}
+	return pf
+}
+
+// GetDistance returns how far along the path the body is.
+func (pf *PathFollower) GetDistance() m.Real {
+	length := pf.Path.Length()
+	if pf.Speed <= 0.0 || length <= m.Epsilon {
+		return 0.0
+	}
+	t := pf.elapsed * pf.Speed / length
+	if t > 1.0 {
+		t = 1.0
+	}
+	easing := pf.Easing
+	if easing == nil {
+		easing = EaseLinear
+	}
+	distance := easing(t) * length
+	if pf.reverse {
+		distance = length - distance
+	}
+	return distance
+}
+
+// IsFinished returns true if the follower is in PathOnce mode and has reached
+// the end of the path.
+func (pf *PathFollower) IsFinished() bool {
+	return pf.finished
+}
+
+// Reset sends the follower back to the start of the path. The body gets
+// there in the next step.
+func (pf *PathFollower) Reset() {
+	pf.elapsed = 0.0
+	pf.reverse = false
+	pf.finished = false
+}
+
+// Drive gives the body the velocity that takes it to where it should be on
+// the path after the duration given. It should be called before the body is
+// integrated for the step.
+func (pf *PathFollower) Drive(duration m.Real) {
+	if duration <= 0.0 {
+		return
+	}
+	body := pf.Body
+	if !pf.Paused && !pf.finished {
+		pf.advance(duration)
+	}
+	distance := pf.GetDistance()
+	target, _ := pf.Path.PointAt(distance)
+	direction := pf.Path.tangentAt(distance)
+	if pf.reverse {
+		direction.MulWith(-1.0)
+	}
+
+	// the velocity is what the contacts see, so the bodies resting on this
+	// one are carried along with it
+	body.Velocity = target
+	body.Velocity.Sub(&body.Position)
+	body.Velocity.MulWith(1.0 / duration)
+	body.Rotation = m.Vector3{}
+	if pf.Orient && direction.SquareMagnitude() > m.Epsilon {
+		orientation := pf.facing(&direction)
+		current := body.Orientation.Conjugated()
+		orientation.Mul(&current)
+		body.Rotation = rotationVector(&orientation)
+		body.Rotation.MulWith(1.0 / duration)
+	}
+	body.SetAwake(true)
+}
+
+// facing returns the orientation that points the Forward axis of the body
+// along the direction given, turning about Up and then tilting, without rolling.
+func (pf *PathFollower) facing(direction *m.Vector3) m.Quat {
+	up := pf.Up
+	up.Normalize()
+	forward, target := pf.Forward, *direction
+	forward.AddScaled(&up, -forward.Dot(&up))
+	target.AddScaled(&up, -target.Dot(&up))
+	if forward.SquareMagnitude() <= m.Epsilon || target.SquareMagnitude() <= m.Epsilon {
+		return m.QuatBetweenVectors(&pf.Forward, direction)
+	}
+
+	turn := m.QuatBetweenVectors(&forward, &target)
+	turned := turn.Rotate(&pf.Forward)
+	tilt := m.QuatBetweenVectors(&turned, direction)
+	tilt.Mul(&turn)
+	return tilt
+}
+
+// advance moves the follower on along its path by the duration given,
+// starting the next leg at the end of the path.
+func (pf *PathFollower) advance(duration m.Real) {
+	length := pf.Path.Length()
+	if pf.Speed <= 0.0 || length <= m.Epsilon {
+		return
+	}
+	legTime := length / pf.Speed
+	pf.elapsed += duration
+	for pf.elapsed >= legTime {
+		switch pf.Mode {
+		case PathLoop:
+			pf.elapsed -= legTime
+		case PathPingPong:
+			pf.elapsed -= legTime
+			pf.reverse = !pf.reverse
+		default:
+			pf.elapsed = legTime
+			pf.finished = true
+			return
+		}
+	}
+}
+
+// AddPathFollower adds the path follower to the world so that it drives its
+// body each step.
+func (w *World) AddPathFollower(pf *PathFollower) {
+	for _, existing := range w.PathFollowers {
+		if existing == pf {
+			return
+		}
+	}
+	w.PathFollowers = append(w.PathFollowers, pf)
+}
+
+// RemovePathFollower removes the path follower from the world. Its body keeps
+// the velocity it was last given.
+func (w *World) RemovePathFollower(pf *PathFollower) {
+	for i, existing := range w.PathFollowers {
+		if existing == pf {
+			w.PathFollowers = append(w.PathFollowers[:i], w.PathFollowers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// PickConstraint pulls a grabbed point on a body towards a target in the
+// world with a damped spring, for dragging objects around with the mouse in
+// an editor or a game. The body is still simulated: it swings about the
+// grabbed point, collides with the scene and can't be pulled harder than the
+// MaxForce. A DragHandle drives one for editor gizmos.
+type PickConstraint struct {
+	// Body is the body that was picked.
+	Body *RigidBody
+
+	// Point is the grabbed point in Body Space.
+	Point m.Vector3
+
+	// Target is the point in World Space the grabbed point is pulled towards.
+	Target m.Vector3
+
+	// Distance is the distance along the pick ray where the target is held
+	// when it's moved with UpdateRay.
+	Distance m.Real
+
+	// Frequency is the natural frequency of the spring in hertz; the higher
+	// it is the more tightly the point follows the target.
+	Frequency m.Real
+
+	// DampingRatio is how strongly the spring is damped, where 1.0 is
+	// critically damped.
+	DampingRatio m.Real
+
+	// MaxForce is the largest force the spring can apply. A value of zero or
+	// less means the force isn't limited.
+	// Defaults to 0.0.
+	MaxForce m.Real
+
+	// force holds the force the spring applied in the last step.
+	force m.Vector3
+
+	// canSleep holds the CanSleep value of the body before it was picked.
+	canSleep bool
+}
+
+// NewPickConstraint creates a new PickConstraint that grabs the body that was
+// hit by a pick ray at the point that was hit, or returns nil if the hit has
+// no body. The body is kept awake until the constraint is released.
+func NewPickConstraint(hit RayHit, frequency m.Real, dampingRatio m.Real, maxForce m.Real) *PickConstraint {
+	if hit.Body == nil {
+		return nil
+	}
+
+	p := new(PickConstraint)
+	p.Body = hit.Body
+	p.Point = worldPointToBody(p.Body, &hit.Point)
+	p.Target = hit.Point
+	p.Distance = hit.Distance
+	p.Frequency = frequency
+	p.DampingRatio = dampingRatio
+	p.MaxForce = maxForce
+	p.canSleep = p.Body.CanSleep
+
+	p.Body.CanSleep = false
+	p.Body.SetAwake(true)
+	return p
+}
+
+// UpdateRay moves the target so that it lies on the new pick ray, at the
+// same Distance along it.
+func (p *PickConstraint) UpdateRay(origin *m.Vector3, direction *m.Vector3) {
+	dir := *direction
+	dir.Normalize()
+	p.Target = *origin
+	p.Target.AddScaled(&dir, p.Distance)
+}
+
+// Release restores the sleep settings of the body. The constraint should be
+// removed from the world as well.
+func (p *PickConstraint) Release() {
+	p.Body.CanSleep = p.canSleep
+	p.Body.SetAwake(true)
+}
+
+// GetBodies returns the picked body; the second body is always nil.
+func (p *PickConstraint) GetBodies() [2]*RigidBody {
+	return [2]*RigidBody{p.Body, nil}
+}
+
+// saveState copies the last force of the spring.
+func (p *PickConstraint) saveState(state *constraintState) {
+	state.motor = p.force
+}
+
+// loadState sets the last force of the spring.
+func (p *PickConstraint) loadState(state *constraintState) {
+	p.force = state.motor
+}
+
+// GetForce returns the force the spring applied in the last step.
+func (p *PickConstraint) GetForce() m.Vector3 {
+	return p.force
+}
+
+// AddContacts doesn't generate any contacts since the spring is applied as an
+// impulse instead; it's here to satisfy the Constraint interface.
+func (p *PickConstraint) AddContacts(existingContacts []*Contact) (bool, []*Contact) {
+	return false, existingContacts
+}
+
+// applyImpulses applies the impulse that a damped spring pulling the grabbed
+// point towards the target would give over the step, limited by what the
+// MaxForce can do in the step.
+func (p *PickConstraint) applyImpulses(duration m.Real, feedback *JointFeedback) {
+	p.force.Clear()
+	if duration <= 0.0 {
+		return
+	}
+
+	bodies := [2]*RigidBody{p.Body, nil}
+	points := [2]m.Vector3{bodyPointToWorld(p.Body, &p.Point), p.Target}
+	offset := p.Target
+	offset.Sub(&points[0])
+
+	// spring each world axis in turn
+	axes := [3]m.Vector3{{1.0, 0.0, 0.0}, {0.0, 1.0, 0.0}, {0.0, 0.0, 1.0}}
+	var impulses [3]m.Real
+	var total m.Real
+	for i := range axes {
+		inverseMass, speed := pointResponse(&bodies, &points, &axes[i])
+		impulses[i] = springImpulse(inverseMass, offset[i], speed, p.Frequency, p.DampingRatio, duration)
+		total += impulses[i] * impulses[i]
+	}
+
+	// scale the impulse back to what the spring can do
+	total = m.RealSqrt(total)
+	if maxImpulse := p.MaxForce * duration; p.MaxForce > 0.0 && total > maxImpulse {
+		for i := range impulses {
+			impulses[i] *= maxImpulse / total
+		}
+	}
+
+	for i := range axes {
+		applyPointImpulses(&bodies, &points, &axes[i], impulses[i], feedback)
+		p.force.AddScaled(&axes[i], -impulses[i]/duration)
+	}
+}
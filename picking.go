@@ -0,0 +1,309 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// RayHit holds the result of casting a ray against a collider.
+type RayHit struct {
+	// Collider is the collider that was hit by the ray.
+	Collider Collider
+
+	// Body is the RigidBody of the collider that was hit; this can be nil
+	// for colliders such as planes.
+	Body *RigidBody
+
+	// Point is the point of intersection in World Space.
+	Point m.Vector3
+
+	// Normal is the surface normal of the collider at the point of intersection.
+	Normal m.Vector3
+
+	// Distance is the distance along the ray to the point of intersection.
+	Distance m.Real
+
+	// Material is the surface material of the collider that was hit.
+	Material *Material
+}
+
+// DragHandle holds the state of a body being manipulated by an editor style
+// gizmo. It is created by World.BeginDrag and pulls the grabbed point of the
+// body towards the ray that is used to update it with a PickConstraint, so the
+// body still collides with the scene and can't shove other bodies harder
+// than the MaxForce of the constraint.
+type DragHandle struct {
+	// Collider is the collider that was picked.
+	Collider Collider
+
+	// Body is the RigidBody being dragged.
+	Body *RigidBody
+
+	// Pick is the constraint pulling the grabbed point along the drag ray.
+	// Its Frequency, DampingRatio and MaxForce can be changed during the drag.
+	Pick *PickConstraint
+
+	// world is the world the constraint was added to.
+	world *World
+}
+
+const (
+	// dragFrequency and dragDampingRatio set how tightly a dragged body
+	// follows the drag ray.
+	dragFrequency    m.Real = 5.0
+	dragDampingRatio m.Real = 1.0
+
+	// dragMaxAcceleration is the largest acceleration the drag can give the
+	// body on its own, which sets the MaxForce of the constraint.
+	dragMaxAcceleration m.Real = 50.0
+)
+
+// RaycastCollider checks a ray against a collider and returns true along with
+// the hit information if the ray intersects it. The direction does not need
+// to be normalized.
+func RaycastCollider(c Collider, origin *m.Vector3, direction *m.Vector3) (bool, RayHit) {
+	var hit RayHit
+	ray := m.Ray{Origin: *origin, Direction: *direction}
+	ray.Direction.Normalize()
+
+	var found bool
+	switch shape := c.(type) {
+	case *CollisionSphere:
+		found, hit = raycastSphere(shape, &ray)
+	case *CollisionCube:
+		found, hit = raycastCube(shape, &ray)
+	case *CollisionCapsule:
+		found, hit = raycastCapsule(shape, &ray)
+	case *CollisionPlane:
+		found, hit = raycastPlane(shape, &ray)
+	}
+	if !found {
+		return false, hit
+	}
+
+	hit.Collider = c
+	hit.Body = c.GetBody()
+	hit.Material = c.GetMaterial()
+	return true, hit
+}
+
+// PickBodyAtRay casts a ray, typically from the camera through the cursor, against
+// the colliders and returns the closest hit that has a RigidBody attached.
+func PickBodyAtRay(colliders []Collider, origin *m.Vector3, direction *m.Vector3) (bool, RayHit) {
+	var closest RayHit
+	found := false
+	for _, c := range colliders {
+		if c.GetBody() == nil {
+			continue
+		}
+		hit, result := RaycastCollider(c, origin, direction)
+		if hit && (!found || result.Distance < closest.Distance) {
+			closest = result
+			found = true
+		}
+	}
+	return found, closest
+}
+
+// BeginDrag starts dragging the body that was hit by a pick ray by adding a
+// PickConstraint for it to the world. The grabbed point will be pulled
+// towards the same distance along any future drag rays.
+func (w *World) BeginDrag(hit RayHit) *DragHandle {
+	if hit.Body == nil {
+		return nil
+	}
+
+	d := new(DragHandle)
+	d.Collider = hit.Collider
+	d.Body = hit.Body
+	d.Pick = NewPickConstraint(hit, dragFrequency, dragDampingRatio, dragMaxAcceleration*hit.Body.GetMass())
+	d.world = w
+	w.AddConstraint(d.Pick)
+	return d
+}
+
+// UpdateDrag moves the target of the grabbed point onto the new ray.
+func (d *DragHandle) UpdateDrag(origin *m.Vector3, direction *m.Vector3) {
+	d.Pick.UpdateRay(origin, direction)
+}
+
+// RotateDrag spins the body about the grabbed point so that it turns by the
+// angle (in radians) about the World Space axis given over the duration,
+// which is normally that of the next step. The body keeps the spin until it's
+// resolved against the scene like any other motion, so a gizmo calls it each
+// step while it's being turned.
+func (d *DragHandle) RotateDrag(axis m.Vector3, angle m.Real, duration m.Real) {
+	if duration <= 0.0 {
+		return
+	}
+	axis.Normalize()
+	spin := axis
+	spin.MulWith(angle / duration)
+
+	// the grabbed point keeps its velocity while the rest of the body
+	// swings about it
+	grabbed := bodyPointToWorld(d.Body, &d.Pick.Point)
+	velocity := d.Body.GetVelocityAtPoint(&grabbed)
+	arm := d.Body.GetCenterOfMassWorld()
+	arm.Sub(&grabbed)
+	swing := spin.Cross(&arm)
+	d.Body.Rotation = spin
+	d.Body.Velocity = velocity
+	d.Body.Velocity.Add(&swing)
+	d.Body.SetAwake(true)
+}
+
+// EndDrag finishes the drag, removing the constraint from the world and
+// restoring the sleep settings of the body.
+func (d *DragHandle) EndDrag() {
+	d.world.RemoveConstraint(d.Pick)
+	d.Pick.Release()
+}
+
+// raycastSphere intersects a normalized ray with a sphere.
+func raycastSphere(s *CollisionSphere, ray *m.Ray) (bool, RayHit) {
+	var hit RayHit
+	center := s.transform.GetAxis(3)
+	found, t := ray.IntersectSphere(&center, s.Radius)
+	if !found {
+		return false, hit
+	}
+
+	hit.Distance = t
+	hit.Point = ray.PointAt(t)
+	hit.Normal = hit.Point
+	hit.Normal.Sub(&center)
+	hit.Normal.Normalize()
+	return true, hit
+}
+
+// raycastCube intersects a normalized ray with a cube by doing a slab test
+// in the cube's local space.
+func raycastCube(cube *CollisionCube, ray *m.Ray) (bool, RayHit) {
+	var hit RayHit
+	local := ray.TransformInverse(&cube.transform)
+	localOrigin, localDir := local.Origin, local.Direction
+
+	tMin := m.Real(0.0)
+	tMax := m.MaxValue
+	normalAxis := -1
+	var normalSign m.Real
+
+	for i := 0; i < 3; i++ {
+		if m.RealAbs(localDir[i]) < m.Epsilon {
+			// parallel to the slab, so the origin must be within it
+			if localOrigin[i] < -cube.HalfSize[i] || localOrigin[i] > cube.HalfSize[i] {
+				return false, hit
+			}
+			continue
+		}
+
+		invDir := 1.0 / localDir[i]
+		t1 := (-cube.HalfSize[i] - localOrigin[i]) * invDir
+		t2 := (cube.HalfSize[i] - localOrigin[i]) * invDir
+		sign := m.Real(-1.0)
+		if t1 > t2 {
+			t1, t2 = t2, t1
+			sign = 1.0
+		}
+		if t1 > tMin {
+			tMin = t1
+			normalAxis = i
+			normalSign = sign
+		}
+		if t2 < tMax {
+			tMax = t2
+		}
+		if tMin > tMax {
+			return false, hit
+		}
+	}
+
+	hit.Distance = tMin
+	hit.Point = ray.PointAt(tMin)
+	if normalAxis >= 0 {
+		var localNormal m.Vector3
+		localNormal[normalAxis] = normalSign
+		hit.Normal = cube.transform.TransformDirection(&localNormal)
+	} else {
+		// the ray started inside the cube
+		hit.Normal = ray.Direction
+		hit.Normal.MulWith(-1.0)
+	}
+	return true, hit
+}
+
+// raycastCapsule intersects a normalized ray with a capsule by testing the
+// cylinder of its side and the spheres of its ends in its local space.
+func raycastCapsule(capsule *CollisionCapsule, ray *m.Ray) (bool, RayHit) {
+	var hit RayHit
+	local := ray.TransformInverse(&capsule.transform)
+	bottom := m.Vector3{0.0, -capsule.HalfHeight, 0.0}
+	top := m.Vector3{0.0, capsule.HalfHeight, 0.0}
+
+	// the ray started inside the capsule
+	closest := closestPointOnSegment(&local.Origin, &bottom, &top)
+	toOrigin := local.Origin
+	toOrigin.Sub(&closest)
+	if toOrigin.SquareMagnitude() <= capsule.Radius*capsule.Radius {
+		hit.Point = ray.Origin
+		hit.Normal = ray.Direction
+		hit.Normal.MulWith(-1.0)
+		return true, hit
+	}
+
+	found := false
+	best := m.MaxValue
+	var localNormal m.Vector3
+
+	// the side is a cylinder around the Y axis between the ends
+	origin, direction := local.Origin, local.Direction
+	a := direction[0]*direction[0] + direction[2]*direction[2]
+	b := origin[0]*direction[0] + origin[2]*direction[2]
+	c := origin[0]*origin[0] + origin[2]*origin[2] - capsule.Radius*capsule.Radius
+	if a > m.Epsilon {
+		if discriminant := b*b - a*c; discriminant >= 0.0 {
+			t := (-b - m.RealSqrt(discriminant)) / a
+			point := local.PointAt(t)
+			if t >= 0.0 && m.RealAbs(point[1]) <= capsule.HalfHeight {
+				found = true
+				best = t
+				localNormal = m.Vector3{point[0], 0.0, point[2]}
+			}
+		}
+	}
+
+	for _, end := range [2]m.Vector3{bottom, top} {
+		if ok, t := local.IntersectSphere(&end, capsule.Radius); ok && t < best {
+			found = true
+			best = t
+			localNormal = local.PointAt(t)
+			localNormal.Sub(&end)
+		}
+	}
+	if !found {
+		return false, hit
+	}
+
+	hit.Distance = best
+	hit.Point = ray.PointAt(best)
+	localNormal.Normalize()
+	hit.Normal = capsule.transform.TransformDirection(&localNormal)
+	return true, hit
+}
+
+// raycastPlane intersects a normalized ray with the front of a plane.
+func raycastPlane(p *CollisionPlane, ray *m.Ray) (bool, RayHit) {
+	var hit RayHit
+	found, t := ray.IntersectPlane(&p.Plane)
+	if !found {
+		return false, hit
+	}
+
+	hit.Distance = t
+	hit.Point = ray.PointAt(t)
+	hit.Normal = p.Normal
+	return true, hit
+}
@@ -0,0 +1,165 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// PointOnLineConstraint keeps a point on the first body on a line fixed to
+// the second body, leaving it free to slide along the line and to turn. It's
+// a building block for mechanisms and camera rigs that the full joints don't
+// cover, such as a bead on a wire or a dolly on a track.
+type PointOnLineConstraint struct {
+	ConstraintSoftness
+
+	// Bodies holds the two bodies that are constrained. The second body can
+	// be nil, in which case the line is fixed in the world.
+	Bodies [2]*RigidBody
+
+	// Point is the point on the first body in Body Space.
+	Point m.Vector3
+
+	// Origin is a point on the line in the Body Space of the second body. If
+	// the second body is nil, it's in World Space.
+	Origin m.Vector3
+
+	// Direction is the direction of the line in the Body Space of the second
+	// body. If the second body is nil, it's in World Space.
+	Direction m.Vector3
+
+	// Error is the distance the point is allowed to drift off of the line
+	// before the constraint generates a contact.
+	Error m.Real
+}
+
+// NewPointOnLineConstraint creates a new PointOnLineConstraint that keeps
+// the point on the first body on the line through it with the direction
+// given, both in World Space. The derived data of the bodies must be up to date.
+func NewPointOnLineConstraint(one *RigidBody, two *RigidBody, point m.Vector3, direction m.Vector3, err m.Real) *PointOnLineConstraint {
+	c := new(PointOnLineConstraint)
+	c.Bodies[0] = one
+	c.Bodies[1] = two
+	c.Error = err
+
+	direction.Normalize()
+	c.Point = worldPointToBody(one, &point)
+	c.Origin = worldPointToBody(two, &point)
+	c.Direction = worldDirectionToBody(two, &direction)
+	return c
+}
+
+// GetBodies returns the two bodies of the constraint.
+func (c *PointOnLineConstraint) GetBodies() [2]*RigidBody {
+	return c.Bodies
+}
+
+// Offset returns the distance of the point along the line from the origin.
+func (c *PointOnLineConstraint) Offset() m.Real {
+	point := bodyPointToWorld(c.Bodies[0], &c.Point)
+	origin := bodyPointToWorld(c.Bodies[1], &c.Origin)
+	direction := bodyDirectionToWorld(c.Bodies[1], &c.Direction)
+	direction.Normalize()
+	point.Sub(&origin)
+	return point.Dot(&direction)
+}
+
+// AddContacts generates a contact if the point has drifted off of the line
+// and appends it to the existing contacts.
+func (c *PointOnLineConstraint) AddContacts(existingContacts []*Contact) (bool, []*Contact) {
+	point := bodyPointToWorld(c.Bodies[0], &c.Point)
+	closest := bodyPointToWorld(c.Bodies[1], &c.Origin)
+	direction := bodyDirectionToWorld(c.Bodies[1], &c.Direction)
+	direction.Normalize()
+	closest.AddScaled(&direction, c.Offset())
+
+	added, existingContacts := addPointJointContact(existingContacts, &c.Bodies, &point, &closest, c.Error)
+	if added {
+		// the point has to be free to slide along the line
+		existingContacts[len(existingContacts)-1].Friction = 0.0
+	}
+	return added, existingContacts
+}
+
+// PointOnPlaneConstraint keeps a point on the first body on a plane fixed to
+// the second body, leaving it free to slide around the plane and to turn.
+type PointOnPlaneConstraint struct {
+	ConstraintSoftness
+
+	// Bodies holds the two bodies that are constrained. The second body can
+	// be nil, in which case the plane is fixed in the world.
+	Bodies [2]*RigidBody
+
+	// Point is the point on the first body in Body Space.
+	Point m.Vector3
+
+	// Origin is a point on the plane in the Body Space of the second body. If
+	// the second body is nil, it's in World Space.
+	Origin m.Vector3
+
+	// Normal is the normal of the plane in the Body Space of the second body.
+	// If the second body is nil, it's in World Space.
+	Normal m.Vector3
+
+	// Error is the distance the point is allowed to drift off of the plane
+	// before the constraint generates a contact.
+	Error m.Real
+}
+
+// NewPointOnPlaneConstraint creates a new PointOnPlaneConstraint that keeps
+// the point on the first body on the plane through it with the normal given,
+// both in World Space. The derived data of the bodies must be up to date.
+func NewPointOnPlaneConstraint(one *RigidBody, two *RigidBody, point m.Vector3, normal m.Vector3, err m.Real) *PointOnPlaneConstraint {
+	c := new(PointOnPlaneConstraint)
+	c.Bodies[0] = one
+	c.Bodies[1] = two
+	c.Error = err
+
+	normal.Normalize()
+	c.Point = worldPointToBody(one, &point)
+	c.Origin = worldPointToBody(two, &point)
+	c.Normal = worldDirectionToBody(two, &normal)
+	return c
+}
+
+// GetBodies returns the two bodies of the constraint.
+func (c *PointOnPlaneConstraint) GetBodies() [2]*RigidBody {
+	return c.Bodies
+}
+
+// Distance returns how far the point is from the plane, which is negative if
+// it's behind the plane.
+func (c *PointOnPlaneConstraint) Distance() m.Real {
+	point := bodyPointToWorld(c.Bodies[0], &c.Point)
+	origin := bodyPointToWorld(c.Bodies[1], &c.Origin)
+	normal := bodyDirectionToWorld(c.Bodies[1], &c.Normal)
+	normal.Normalize()
+	point.Sub(&origin)
+	return point.Dot(&normal)
+}
+
+// AddContacts generates a contact if the point has drifted off of the plane
+// and appends it to the existing contacts.
+func (c *PointOnPlaneConstraint) AddContacts(existingContacts []*Contact) (bool, []*Contact) {
+	distance := c.Distance()
+	if m.RealAbs(distance) <= c.Error {
+		return false, existingContacts
+	}
+
+	// the first body is pushed along the normal, back towards the plane
+	normal := bodyDirectionToWorld(c.Bodies[1], &c.Normal)
+	normal.Normalize()
+	if distance > 0.0 {
+		normal.MulWith(-1.0)
+	}
+
+	contact := NewContact()
+	contact.Bodies = c.Bodies
+	contact.ContactNormal = normal
+	contact.ContactPoint = bodyPointToWorld(c.Bodies[0], &c.Point)
+	contact.Penetration = m.RealAbs(distance) - c.Error
+	contact.Friction = 0.0
+	contact.Restitution = 0.0
+	return true, append(existingContacts, contact)
+}
@@ -0,0 +1,139 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// PredictedTrajectory is the path a body is expected to take, worked out by
+// PredictTrajectory.
+type PredictedTrajectory struct {
+	// Points holds the position of the body after each step.
+	Points []m.Vector3
+
+	// Velocities holds the velocity of the body after each step.
+	Velocities []m.Vector3
+
+	// Hit is true if the body touched the static geometry of the world.
+	Hit bool
+
+	// HitStep is the index in Points of the step the body first touched the
+	// static geometry in.
+	HitStep int
+
+	// HitPoint is the point, in World Space, where the body first touched
+	// the static geometry.
+	HitPoint m.Vector3
+
+	// HitNormal is the normal of the surface at the HitPoint, pointing away
+	// from the static geometry.
+	HitNormal m.Vector3
+
+	// HitCollider is the collider of the static geometry that was touched
+	// first.
+	HitCollider Collider
+}
+
+// PredictTrajectory simulates the collider forward by the number of steps of
+// the duration given against the static geometry of the world, which is the
+// colliders without a body or with infinite mass, and returns the path it
+// takes. The collider and its body are copied, so the world isn't changed.
+// The copy is moved by the world's Integrator, attractors, gravity zones and
+// force volumes but not by its Forces, Generators or other moving bodies. The
+// prediction stops when the copy hits the static geometry after bouncing off
+// it the number of times given, so a bounces of zero stops at the first hit.
+// It returns nil if the collider has no body.
+func (w *World) PredictTrajectory(c Collider, duration m.Real, steps int, bounces int) *PredictedTrajectory {
+	body := c.GetBody()
+	if body == nil {
+		return nil
+	}
+	integrator := w.Integrator
+	if integrator == nil {
+		integrator = &SemiImplicitEuler{}
+	}
+
+	// the static geometry the copy can hit
+	var static []Collider
+	for _, other := range w.Colliders {
+		otherBody := other.GetBody()
+		if otherBody == body || (otherBody != nil && otherBody.HasFiniteMass()) {
+			continue
+		}
+		static = append(static, other)
+	}
+
+	ghost := c.Clone()
+	ghostBody := ghost.GetBody()
+	ghostBody.SetAwake(true)
+	forces := func(b *RigidBody) {
+		if len(w.Attractors) > 0 {
+			w.updateFieldGravity(b)
+		}
+		if len(w.GravityZones) > 0 {
+			var zones []*GravityZone
+			for _, gz := range w.GravityZones {
+				if gz.Contains(b) {
+					zones = append(zones, gz)
+				}
+			}
+			b.updateZoneGravity(zones)
+		}
+		for _, fv := range w.ForceVolumes {
+			if fv.Overlaps(ghost) {
+				fv.ApplyTo(b)
+			}
+		}
+	}
+
+	prediction := &PredictedTrajectory{
+		Points:     make([]m.Vector3, 0, steps),
+		Velocities: make([]m.Vector3, 0, steps),
+	}
+	touching := false
+	for step := 0; step < steps; step++ {
+		ghost.CalculateDerivedData()
+		forces(ghostBody)
+		integrator.Integrate(ghostBody, duration, forces)
+		w.applySpeedLimits(ghostBody)
+		ghost.CalculateDerivedData()
+
+		// find the contacts with the static geometry, keeping track of the
+		// first one for the hit
+		var contacts []*Contact
+		for _, other := range static {
+			first := len(contacts)
+			_, contacts = CheckForCollisions(ghost, other, contacts)
+			if !prediction.Hit && len(contacts) > first {
+				contact := contacts[first]
+				prediction.Hit = true
+				prediction.HitStep = step
+				prediction.HitPoint = contact.ContactPoint
+				prediction.HitNormal = contact.ContactNormal
+				if contact.Bodies[0] != ghostBody {
+					prediction.HitNormal.MulWith(-1.0)
+				}
+				prediction.HitCollider = other
+			}
+		}
+		if len(contacts) > 0 {
+			// a new touch after flying free is a bounce, and the last one
+			// allowed ends the prediction
+			if !touching {
+				if bounces <= 0 {
+					prediction.Points = append(prediction.Points, ghostBody.Position)
+					prediction.Velocities = append(prediction.Velocities, ghostBody.Velocity)
+					break
+				}
+				bounces--
+			}
+			ResolveContacts(len(contacts)*8, contacts, duration)
+		}
+		touching = len(contacts) > 0
+		prediction.Points = append(prediction.Points, ghostBody.Position)
+		prediction.Velocities = append(prediction.Velocities, ghostBody.Velocity)
+	}
+	return prediction
+}
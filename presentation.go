@@ -0,0 +1,128 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"sync/atomic"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// presentationFresh is set in Presentation.ready when the buffer there holds
+// a state the reader hasn't seen yet.
+const presentationFresh = 4
+
+// BodyPose holds where a body was at the end of a step, copied out of the
+// world so that it can be read on another goroutine.
+type BodyPose struct {
+	// ID is the handle of the body in the world.
+	ID BodyID
+
+	// Position and Orientation are the transform of the body at the end of
+	// the step.
+	Position    m.Vector3
+	Orientation m.Quat
+
+	// PrevPosition and PrevOrientation are the transform of the body before
+	// the step's integration, for blending between the two.
+	PrevPosition    m.Vector3
+	PrevOrientation m.Quat
+
+	// IsAwake is whether the body was awake.
+	IsAwake bool
+}
+
+// InterpolatedTransform returns a transform that blends from the pose before
+// the step to the pose after it, like RigidBody.InterpolatedTransform.
+func (p *BodyPose) InterpolatedTransform(alpha m.Real) m.Matrix3x4 {
+	position := p.PrevPosition
+	position.MulWith(1.0 - alpha)
+	position.AddScaled(&p.Position, alpha)
+
+	orientation := m.QuatNlerp(&p.PrevOrientation, &p.Orientation, alpha)
+
+	var transform m.Matrix3x4
+	transform.SetAsTransform(&position, &orientation)
+	return transform
+}
+
+// PresentationState holds the poses of the bodies of a world at the end of
+// one step.
+type PresentationState struct {
+	// Step counts the steps published, starting from one. It's zero before
+	// the first step.
+	Step uint64
+
+	// Duration is the duration of the step.
+	Duration m.Real
+
+	// Poses holds the pose of each body, in the order of the world's Bodies.
+	Poses []BodyPose
+}
+
+// Presentation hands the poses of the bodies of a world from the goroutine
+// stepping it to a goroutine drawing it without either one waiting on the
+// other. The world publishes the poses into a back buffer at the end of each
+// step and swaps it with the ready buffer; the reader swaps the ready buffer
+// with its front buffer when there's a new one. Each side only ever touches
+// the buffer it holds, so the reads need no lock.
+//
+// Only one goroutine may step the world and only one may call Read.
+type Presentation struct {
+	buffers [3]PresentationState
+
+	// back is the buffer the world writes to and front the one the reader
+	// reads from. ready holds the index of the buffer between them, along
+	// with presentationFresh if it hasn't been read yet, and is only
+	// accessed atomically.
+	back  int
+	front int
+	ready uint32
+
+	// steps counts the states published.
+	steps uint64
+}
+
+// NewPresentation creates a new Presentation with no poses in it.
+func NewPresentation() *Presentation {
+	p := new(Presentation)
+	p.back = 0
+	p.ready = 1
+	p.front = 2
+	return p
+}
+
+// Read returns the latest state published. The state stays the same, and
+// safe to read, until the next call to Read.
+func (p *Presentation) Read() *PresentationState {
+	if atomic.LoadUint32(&p.ready)&presentationFresh != 0 {
+		ready := atomic.SwapUint32(&p.ready, uint32(p.front))
+		p.front = int(ready &^ presentationFresh)
+	}
+	return &p.buffers[p.front]
+}
+
+// publish copies the poses of the bodies into the back buffer and makes it
+// the ready one.
+func (p *Presentation) publish(w *World, duration m.Real) {
+	state := &p.buffers[p.back]
+	p.steps++
+	state.Step = p.steps
+	state.Duration = duration
+	state.Poses = state.Poses[:0]
+	for _, body := range w.Bodies {
+		id, _ := w.GetBodyID(body)
+		state.Poses = append(state.Poses, BodyPose{
+			ID:              id,
+			Position:        body.Position,
+			Orientation:     body.Orientation,
+			PrevPosition:    body.prevPosition,
+			PrevOrientation: body.prevOrientation,
+			IsAwake:         body.IsAwake,
+		})
+	}
+
+	ready := atomic.SwapUint32(&p.ready, uint32(p.back)|presentationFresh)
+	p.back = int(ready &^ presentationFresh)
+}
@@ -0,0 +1,47 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"context"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// profileLabel is the pprof label that holds the phase of the step.
+const profileLabel = "cubez"
+
+// endNothing is returned to end a phase when Profile isn't set.
+func endNothing() {}
+
+// beginStep starts the trace region for a step and labels the goroutine as
+// stepping, returning the labelled context for the phases and a function
+// that ends the step and puts back the labels of the context given.
+func (w *World) beginStep(ctx context.Context) (context.Context, func()) {
+	if !w.Profile {
+		return ctx, endNothing
+	}
+	region := trace.StartRegion(ctx, "cubez.Step")
+	stepCtx := pprof.WithLabels(ctx, pprof.Labels(profileLabel, "step"))
+	pprof.SetGoroutineLabels(stepCtx)
+	return stepCtx, func() {
+		pprof.SetGoroutineLabels(ctx)
+		region.End()
+	}
+}
+
+// beginPhase starts the trace region and pprof label for a phase of the step,
+// returning a function that ends it. The goroutines that integrate the bodies
+// are started within the phase so they carry its label too.
+func (w *World) beginPhase(ctx context.Context, phase string) func() {
+	if !w.Profile {
+		return endNothing
+	}
+	region := trace.StartRegion(ctx, "cubez."+phase)
+	pprof.SetGoroutineLabels(pprof.WithLabels(ctx, pprof.Labels(profileLabel, phase)))
+	return func() {
+		pprof.SetGoroutineLabels(ctx)
+		region.End()
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	defaultLauncherMaxProjectiles = 16
+	defaultLauncherLifetime       = 5.0
+)
+
+// ProjectileType describes a kind of round that a Launcher fires, as a
+// sphere with its own mass, muzzle speed, damping and gravity.
+type ProjectileType struct {
+	// Mass is the mass of each round.
+	Mass m.Real
+
+	// Radius is the radius of the sphere each round collides as.
+	Radius m.Real
+
+	// Speed is the speed the round leaves the launcher at.
+	Speed m.Real
+
+	// Elevation is the angle, in radians, the round is fired at above the
+	// direction the launcher is aimed in.
+	Elevation m.Real
+
+	// GravityScale scales the gravity of the world on the round; a negative
+	// value makes it float upwards.
+	GravityScale m.Real
+
+	// LinearDamping is the damping of the motion of the round.
+	LinearDamping m.Real
+}
+
+var (
+	// ProjectilePistol is a small, fast round that drops slowly.
+	ProjectilePistol = ProjectileType{Mass: 2.0, Radius: 0.1, Speed: 35.0, GravityScale: 0.1, LinearDamping: 0.99}
+
+	// ProjectileArtillery is a heavy shell lobbed high into the air that
+	// comes down hard.
+	ProjectileArtillery = ProjectileType{Mass: 200.0, Radius: 0.4, Speed: 50.0, Elevation: 0.6435, GravityScale: 2.0, LinearDamping: 0.99}
+
+	// ProjectileFireball is a slow ball that drifts upwards as it goes.
+	ProjectileFireball = ProjectileType{Mass: 1.0, Radius: 0.3, Speed: 10.0, GravityScale: -0.06, LinearDamping: 0.9}
+
+	// ProjectileLaser is a very fast bolt that isn't pulled down at all.
+	ProjectileLaser = ProjectileType{Mass: 0.1, Radius: 0.05, Speed: 100.0, GravityScale: 0.0, LinearDamping: 0.99}
+)
+
+// NewProjectile creates a round of the type at the position given, fired
+// along the direction given with the world's Y axis as up. The round isn't
+// added to a world.
+func (t *ProjectileType) NewProjectile(position m.Vector3, direction m.Vector3) *CollisionSphere {
+	body := NewRigidBody()
+	body.SetMass(t.Mass)
+	var inertia m.Matrix3
+	inertia.SetSphereInertiaTensor(t.Radius, t.Mass)
+	body.SetInertiaTensor(&inertia)
+	body.Position = position
+	body.GravityScale = t.GravityScale
+	body.LinearDamping = t.LinearDamping
+	body.ContinuousCollision = true
+	body.Velocity = projectileVelocity(&direction, &m.Vector3{0.0, 1.0, 0.0}, t.Speed, t.Elevation)
+	body.CalculateDerivedData()
+
+	round := NewCollisionSphere(body, t.Radius)
+	round.CalculateDerivedData()
+	return round
+}
+
+// projectileVelocity returns the velocity of a round fired along the direction
+// given at the speed given, tilted up towards the up direction by the
+// elevation angle.
+func projectileVelocity(direction *m.Vector3, up *m.Vector3, speed m.Real, elevation m.Real) m.Vector3 {
+	forward := *direction
+	forward.Normalize()
+	if elevation == 0.0 {
+		forward.MulWith(speed)
+		return forward
+	}
+
+	// the part of up that is square to the direction the round is fired in
+	lift := *up
+	lift.AddScaled(&forward, -lift.Dot(&forward))
+	if lift.SquareMagnitude() <= m.Epsilon {
+		forward.MulWith(speed)
+		return forward
+	}
+	lift.Normalize()
+
+	velocity := forward
+	velocity.MulWith(speed * m.Real(math.Cos(float64(elevation))))
+	velocity.AddScaled(&lift, speed*m.Real(math.Sin(float64(elevation))))
+	return velocity
+}
+
+// Launcher fires rounds into a world from a point and keeps track of them,
+// removing them again once they've been flying for their Lifetime or when
+// there are more than MaxProjectiles of them.
+type Launcher struct {
+	// Position is the point the rounds are fired from, in World Space.
+	Position m.Vector3
+
+	// Direction is the direction the launcher is aimed in, in World Space.
+	Direction m.Vector3
+
+	// Type is the kind of round that Fire launches.
+	// Defaults to ProjectilePistol.
+	Type *ProjectileType
+
+	// MaxProjectiles is the number of rounds that can be in flight at once;
+	// firing another removes the oldest. A value of zero or less means there
+	// is no limit.
+	// Defaults to 16.
+	MaxProjectiles int
+
+	// Lifetime is the number of seconds a round stays in the world. A value
+	// of zero or less means rounds are never removed for their age.
+	// Defaults to 5.0.
+	Lifetime m.Real
+
+	// rounds holds the rounds in flight, oldest first.
+	rounds []launchedRound
+}
+
+// launchedRound is a round fired by a Launcher and how long it's been flying.
+type launchedRound struct {
+	sphere *CollisionSphere
+	age    m.Real
+}
+
+// NewLauncher creates a new Launcher at the position given, aimed along the
+// direction given.
+func NewLauncher(position m.Vector3, direction m.Vector3) *Launcher {
+	l := new(Launcher)
+	l.Position = position
+	l.Direction = direction
+	l.Type = &ProjectilePistol
+	l.MaxProjectiles = defaultLauncherMaxProjectiles
+	l.Lifetime = defaultLauncherLifetime
+	return l
+}
+
+// Fire launches a round of the launcher's Type into the world and returns it.
+func (l *Launcher) Fire(w *World) *CollisionSphere {
+	return l.FireType(w, l.Type)
+}
+
+// FireType launches a round of the type given into the world and returns it.
+func (l *Launcher) FireType(w *World, t *ProjectileType) *CollisionSphere {
+	if l.MaxProjectiles > 0 {
+		for len(l.rounds) >= l.MaxProjectiles {
+			l.remove(w, 0)
+		}
+	}
+	round := t.NewProjectile(l.Position, l.Direction)
+	w.AddCollider(round)
+	l.rounds = append(l.rounds, launchedRound{sphere: round})
+	return round
+}
+
+// Update ages the rounds in flight by the duration given and removes the ones
+// that have outlived the Lifetime from the world.
+func (l *Launcher) Update(w *World, duration m.Real) {
+	for i := 0; i < len(l.rounds); {
+		l.rounds[i].age += duration
+		if l.Lifetime > 0.0 && l.rounds[i].age >= l.Lifetime {
+			l.remove(w, i)
+			continue
+		}
+		i++
+	}
+}
+
+// GetProjectiles returns the rounds in flight, oldest first.
+func (l *Launcher) GetProjectiles() []*CollisionSphere {
+	rounds := make([]*CollisionSphere, len(l.rounds))
+	for i, round := range l.rounds {
+		rounds[i] = round.sphere
+	}
+	return rounds
+}
+
+// remove takes the round at the index given out of the world.
+func (l *Launcher) remove(w *World, index int) {
+	round := l.rounds[index].sphere
+	w.RemoveCollider(round)
+	w.RemoveBody(round.Body)
+	l.rounds = append(l.rounds[:index], l.rounds[index+1:]...)
+}
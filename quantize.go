@@ -0,0 +1,326 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"fmt"
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// QuantizeConfig describes how BodySnapshot values are quantized when they're
+// encoded. Values outside of the ranges are clamped.
+type QuantizeConfig struct {
+	// PositionMin is the smallest position that can be encoded.
+	PositionMin m.Vector3
+
+	// PositionMax is the largest position that can be encoded.
+	PositionMax m.Vector3
+
+	// PositionBits is the number of bits used for each position component.
+	PositionBits uint
+
+	// MaxVelocity is the largest magnitude of each velocity component.
+	MaxVelocity m.Real
+
+	// VelocityBits is the number of bits used for each velocity component.
+	VelocityBits uint
+
+	// MaxRotation is the largest magnitude of each angular velocity component.
+	MaxRotation m.Real
+
+	// RotationBits is the number of bits used for each angular velocity component.
+	RotationBits uint
+
+	// OrientationBits is the number of bits used for each of the three smallest
+	// components of the orientation quaternion.
+	OrientationBits uint
+}
+
+// QuantizeErrors holds the largest error that quantization can introduce into
+// each component of a BodySnapshot for values within the configured ranges.
+type QuantizeErrors struct {
+	// Position is the largest error of each position component.
+	Position m.Vector3
+
+	// Velocity is the largest error of each velocity component.
+	Velocity m.Real
+
+	// Rotation is the largest error of each angular velocity component.
+	Rotation m.Real
+
+	// Orientation is the largest error of each quaternion component.
+	Orientation m.Real
+}
+
+// smallestThreeRange is the largest magnitude of any quaternion component other
+// than the one with the largest magnitude.
+const smallestThreeRange = 0.70710678118654752440
+
+// NewQuantizeConfig creates a new QuantizeConfig for positions within the
+// bounds given, using 16 bits per component for positions and velocities, 10
+// bits for the orientation and ranges of 64 m/s and 32 rad/s for velocities.
+func NewQuantizeConfig(positionMin m.Vector3, positionMax m.Vector3) *QuantizeConfig {
+	config := new(QuantizeConfig)
+	config.PositionMin = positionMin
+	config.PositionMax = positionMax
+	config.PositionBits = 16
+	config.MaxVelocity = 64.0
+	config.VelocityBits = 16
+	config.MaxRotation = 32.0
+	config.RotationBits = 16
+	config.OrientationBits = 10
+	return config
+}
+
+// BitsPerBody returns the number of bits used to encode one BodySnapshot.
+func (config *QuantizeConfig) BitsPerBody() uint {
+	return 3*config.PositionBits + 3*config.VelocityBits + 3*config.RotationBits + 2 + 3*config.OrientationBits + 1
+}
+
+// ErrorBounds returns the largest error that quantization can introduce with
+// this configuration.
+func (config *QuantizeConfig) ErrorBounds() QuantizeErrors {
+	var errors QuantizeErrors
+	for i := 0; i < 3; i++ {
+		errors.Position[i] = quantizeStep(config.PositionMax[i]-config.PositionMin[i], config.PositionBits) * 0.5
+	}
+	errors.Velocity = quantizeStep(2.0*config.MaxVelocity, config.VelocityBits) * 0.5
+	errors.Rotation = quantizeStep(2.0*config.MaxRotation, config.RotationBits) * 0.5
+	errors.Orientation = quantizeStep(2.0*smallestThreeRange, config.OrientationBits) * 0.5
+	return errors
+}
+
+// Encode packs the snapshots into a byte slice using the configured quantization.
+func (config *QuantizeConfig) Encode(snapshots []BodySnapshot) []byte {
+	var w bitWriter
+	for i := range snapshots {
+		q := config.quantize(&snapshots[i])
+		config.writeBody(&w, &q)
+	}
+	return w.bytes()
+}
+
+// Decode unpacks the number of snapshots given from data created by Encode with
+// the same configuration.
+func (config *QuantizeConfig) Decode(data []byte, count int) ([]BodySnapshot, error) {
+	needed := (uint(count)*config.BitsPerBody() + 7) / 8
+	if uint(len(data)) < needed {
+		return nil, fmt.Errorf("%d bytes is too short for %d bodies; need %d", len(data), count, needed)
+	}
+
+	r := bitReader{data: data}
+	snapshots := make([]BodySnapshot, count)
+	for i := range snapshots {
+		q := config.readBody(&r)
+		snapshots[i] = config.dequantize(&q)
+	}
+	return snapshots, nil
+}
+
+// quantizedBody holds the quantized values of a BodySnapshot. Two snapshots
+// that quantize to equal values look the same once they've been sent.
+type quantizedBody struct {
+	position    [3]uint64
+	velocity    [3]uint64
+	rotation    [3]uint64
+	largest     uint64
+	orientation [3]uint64
+	awake       bool
+}
+
+// quantize returns the quantized values of the snapshot.
+func (config *QuantizeConfig) quantize(s *BodySnapshot) quantizedBody {
+	var q quantizedBody
+	for j := 0; j < 3; j++ {
+		q.position[j] = quantizeReal(s.Position[j], config.PositionMin[j], config.PositionMax[j], config.PositionBits)
+		q.velocity[j] = quantizeReal(s.Velocity[j], -config.MaxVelocity, config.MaxVelocity, config.VelocityBits)
+		q.rotation[j] = quantizeReal(s.Rotation[j], -config.MaxRotation, config.MaxRotation, config.RotationBits)
+	}
+	q.largest, q.orientation = config.quantizeOrientation(&s.Orientation)
+	q.awake = s.IsAwake
+	return q
+}
+
+// dequantize returns the snapshot the quantized values stand for.
+func (config *QuantizeConfig) dequantize(q *quantizedBody) BodySnapshot {
+	var s BodySnapshot
+	for j := 0; j < 3; j++ {
+		s.Position[j] = dequantizeReal(q.position[j], config.PositionMin[j], config.PositionMax[j], config.PositionBits)
+		s.Velocity[j] = dequantizeReal(q.velocity[j], -config.MaxVelocity, config.MaxVelocity, config.VelocityBits)
+		s.Rotation[j] = dequantizeReal(q.rotation[j], -config.MaxRotation, config.MaxRotation, config.RotationBits)
+	}
+	s.Orientation = config.dequantizeOrientation(q.largest, &q.orientation)
+	s.IsAwake = q.awake
+	return s
+}
+
+// writeBody writes the quantized values of a body.
+func (config *QuantizeConfig) writeBody(w *bitWriter, q *quantizedBody) {
+	for j := 0; j < 3; j++ {
+		w.write(q.position[j], config.PositionBits)
+	}
+	for j := 0; j < 3; j++ {
+		w.write(q.velocity[j], config.VelocityBits)
+	}
+	for j := 0; j < 3; j++ {
+		w.write(q.rotation[j], config.RotationBits)
+	}
+	w.write(q.largest, 2)
+	for j := 0; j < 3; j++ {
+		w.write(q.orientation[j], config.OrientationBits)
+	}
+	if q.awake {
+		w.write(1, 1)
+	} else {
+		w.write(0, 1)
+	}
+}
+
+// readBody reads the quantized values of a body written by writeBody.
+func (config *QuantizeConfig) readBody(r *bitReader) quantizedBody {
+	var q quantizedBody
+	for j := 0; j < 3; j++ {
+		q.position[j] = r.read(config.PositionBits)
+	}
+	for j := 0; j < 3; j++ {
+		q.velocity[j] = r.read(config.VelocityBits)
+	}
+	for j := 0; j < 3; j++ {
+		q.rotation[j] = r.read(config.RotationBits)
+	}
+	q.largest = r.read(2)
+	for j := 0; j < 3; j++ {
+		q.orientation[j] = r.read(config.OrientationBits)
+	}
+	q.awake = r.read(1) == 1
+	return q
+}
+
+// quantizeOrientation quantizes the quaternion using the smallest three
+// method: the index of the largest component is kept and the other three are
+// quantized since the largest can be rebuilt from the fact that the
+// quaternion is unit length.
+func (config *QuantizeConfig) quantizeOrientation(q *m.Quat) (uint64, [3]uint64) {
+	largest := 0
+	for i := 1; i < 4; i++ {
+		if m.RealAbs(q[i]) > m.RealAbs(q[largest]) {
+			largest = i
+		}
+	}
+
+	// q and -q are the same orientation, so make the largest component positive
+	sign := m.Real(1.0)
+	if q[largest] < 0.0 {
+		sign = -1.0
+	}
+
+	var smallest [3]uint64
+	j := 0
+	for i := 0; i < 4; i++ {
+		if i != largest {
+			smallest[j] = quantizeReal(q[i]*sign, -smallestThreeRange, smallestThreeRange, config.OrientationBits)
+			j++
+		}
+	}
+	return uint64(largest), smallest
+}
+
+// dequantizeOrientation rebuilds a quaternion quantized by quantizeOrientation.
+func (config *QuantizeConfig) dequantizeOrientation(largest uint64, smallest *[3]uint64) m.Quat {
+	var q m.Quat
+	var sum m.Real
+	j := 0
+	for i := 0; i < 4; i++ {
+		if i != int(largest) {
+			q[i] = dequantizeReal(smallest[j], -smallestThreeRange, smallestThreeRange, config.OrientationBits)
+			sum += q[i] * q[i]
+			j++
+		}
+	}
+	if sum < 1.0 {
+		q[largest] = m.RealSqrt(1.0 - sum)
+	}
+	q.Normalize()
+	return q
+}
+
+// quantizeSteps returns the number of steps a range is split into when it's
+// quantized with the number of bits given. This is kept even, leaving the
+// largest integer unused, so that the middle of a range, such as a velocity of
+// zero, is encoded exactly.
+func quantizeSteps(bits uint) uint64 {
+	if bits < 2 {
+		return 1
+	}
+	return uint64(1)<<bits - 2
+}
+
+// quantizeStep returns the size of one step when a range is quantized with
+// the number of bits given.
+func quantizeStep(valueRange m.Real, bits uint) m.Real {
+	return valueRange / m.Real(quantizeSteps(bits))
+}
+
+// quantizeReal maps the value from the range given to an integer of the
+// number of bits given, clamping it to the range.
+func quantizeReal(value m.Real, min m.Real, max m.Real, bits uint) uint64 {
+	if value <= min || math.IsNaN(float64(value)) {
+		return 0
+	}
+	steps := quantizeSteps(bits)
+	if value >= max {
+		return steps
+	}
+	return uint64(math.Floor(float64((value-min)/(max-min)*m.Real(steps)) + 0.5))
+}
+
+// dequantizeReal maps an integer created by quantizeReal back to the range.
+func dequantizeReal(quantized uint64, min m.Real, max m.Real, bits uint) m.Real {
+	steps := quantizeSteps(bits)
+	return min + (max-min)*m.Real(quantized)/m.Real(steps)
+}
+
+// bitWriter packs values with arbitrary numbers of bits into bytes.
+type bitWriter struct {
+	data  []byte
+	nbits uint
+}
+
+// write appends the lowest bits of the value.
+func (w *bitWriter) write(value uint64, bits uint) {
+	for i := uint(0); i < bits; i++ {
+		if w.nbits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if value&(1<<i) != 0 {
+			w.data[w.nbits/8] |= 1 << (w.nbits % 8)
+		}
+		w.nbits++
+	}
+}
+
+// bytes returns the packed data.
+func (w *bitWriter) bytes() []byte {
+	return w.data
+}
+
+// bitReader unpacks values written by bitWriter.
+type bitReader struct {
+	data  []byte
+	nbits uint
+}
+
+// read returns the next value of the number of bits given.
+func (r *bitReader) read(bits uint) uint64 {
+	var value uint64
+	for i := uint(0); i < bits; i++ {
+		if r.data[r.nbits/8]&(1<<(r.nbits%8)) != 0 {
+			value |= 1 << i
+		}
+		r.nbits++
+	}
+	return value
+}
@@ -0,0 +1,252 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"fmt"
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// RagdollBone identifies one of the bones of a humanoid Ragdoll.
+type RagdollBone int
+
+// The bones of a Ragdoll, from the pelvis out along the limbs.
+const (
+	BonePelvis RagdollBone = iota
+	BoneChest
+	BoneHead
+	BoneUpperArmLeft
+	BoneLowerArmLeft
+	BoneUpperArmRight
+	BoneLowerArmRight
+	BoneUpperLegLeft
+	BoneLowerLegLeft
+	BoneUpperLegRight
+	BoneLowerLegRight
+
+	// RagdollBoneCount is the number of bones in a Ragdoll.
+	RagdollBoneCount
+)
+
+// RagdollBoneTransform places one bone of a Ragdoll in World Space, the way
+// a skeleton exported with an animated character does. The bone runs from its
+// Position along the local Y axis of its Orientation, so the Position is the
+// joint with its parent bone.
+type RagdollBoneTransform struct {
+	// Position is the start of the bone.
+	Position m.Vector3
+
+	// Orientation is the orientation of the bone, with the bone along its
+	// local Y axis.
+	Orientation m.Quat
+
+	// Length is the length of the bone.
+	Length m.Real
+
+	// Radius is how thick the limb around the bone is.
+	Radius m.Real
+}
+
+// ragdollJoint describes how a bone of a Ragdoll is joined to its parent.
+type ragdollJoint struct {
+	// parent is the bone this one hangs from.
+	parent RagdollBone
+
+	// hinge makes the joint a hinge about the local X axis of the bone
+	// instead of a cone that twists about the bone.
+	hinge bool
+
+	// lower and upper are the limits of the hinge angle.
+	lower, upper m.Real
+
+	// swing is how far the bone can swing away from its rest direction and
+	// twist how far it can turn about itself, both in radians.
+	swing, twist m.Real
+}
+
+// ragdollInertiaRadius is the radius, in bone lengths, of the ball whose
+// inertia each limb is given. The contacts that hold the joints together
+// can't keep up with light, thin limbs spinning about their bones, so the
+// limbs are made much harder to turn than their shape would be.
+const ragdollInertiaRadius m.Real = 2.0
+
+// ragdollJoints holds the joints of each bone; the pelvis has none.
+var ragdollJoints = [RagdollBoneCount]ragdollJoint{
+	BoneChest:         {parent: BonePelvis, swing: 0.5, twist: 0.4},
+	BoneHead:          {parent: BoneChest, swing: 0.7, twist: 0.9},
+	BoneUpperArmLeft:  {parent: BoneChest, swing: 1.4, twist: 0.9},
+	BoneLowerArmLeft:  {parent: BoneUpperArmLeft, hinge: true, lower: 0.0, upper: 2.5},
+	BoneUpperArmRight: {parent: BoneChest, swing: 1.4, twist: 0.9},
+	BoneLowerArmRight: {parent: BoneUpperArmRight, hinge: true, lower: 0.0, upper: 2.5},
+	BoneUpperLegLeft:  {parent: BonePelvis, swing: 1.0, twist: 0.4},
+	BoneLowerLegLeft:  {parent: BoneUpperLegLeft, hinge: true, lower: -2.4, upper: 0.0},
+	BoneUpperLegRight: {parent: BonePelvis, swing: 1.0, twist: 0.4},
+	BoneLowerLegRight: {parent: BoneUpperLegRight, hinge: true, lower: -2.4, upper: 0.0},
+}
+
+// Ragdoll is a humanoid made of a limb for each bone of a skeleton, held
+// together by joints with limits that keep it in poses a body can reach.
+// Elbows and knees are hinges about the local X axis of the lower bone;
+// the other bones hang from their parents by cones that limit how far they
+// swing and twist. The limbs are capsules around the bones.
+type Ragdoll struct {
+	// Limbs holds the collider of each bone.
+	Limbs [RagdollBoneCount]*CollisionCapsule
+
+	// Joints holds the joint between each bone and its parent: a *HingeJoint
+	// for the elbows and knees and a *GenericJoint for the others. It's nil
+	// for the pelvis. The limits can be changed before the ragdoll is added
+	// to a world.
+	Joints [RagdollBoneCount]Constraint
+
+	// world is the world the ragdoll was added to.
+	world *World
+
+	// filter stops joined limbs from colliding.
+	filter *jointFilter
+}
+
+// NewRagdoll builds a Ragdoll from the transforms of its bones, one for each
+// RagdollBone in order, with the total mass given spread over the limbs by
+// their volume. Each limb is a capsule that runs the length of its bone,
+// including its rounded ends, or a ball for a bone that is shorter than the
+// limb is thick. The limbs are given far more inertia than their shapes,
+// which keeps the joints stable. The joints are set up with the skeleton in
+// its rest pose, so the transforms should be of a natural standing pose.
+func NewRagdoll(bones []RagdollBoneTransform, mass m.Real) (*Ragdoll, error) {
+	if len(bones) != int(RagdollBoneCount) {
+		return nil, fmt.Errorf("a ragdoll needs %d bone transforms; got %d", RagdollBoneCount, len(bones))
+	}
+
+	var volume m.Real
+	for i, bone := range bones {
+		if bone.Length <= 0.0 || bone.Radius <= 0.0 {
+			return nil, fmt.Errorf("bone %d must have a positive length and radius", i)
+		}
+		volume += ragdollLimbVolume(&bone)
+	}
+
+	r := new(Ragdoll)
+	r.filter = newJointFilter()
+	for i, bone := range bones {
+		body := NewRigidBody()
+		body.SetMass(mass * ragdollLimbVolume(&bone) / volume)
+		body.Orientation = bone.Orientation
+		body.Orientation.Normalize()
+		middle := body.Orientation.Rotate(&m.Vector3{0.0, bone.Length * 0.5, 0.0})
+		body.Position = bone.Position
+		body.Position.Add(&middle)
+		body.CalculateDerivedData()
+
+		r.Limbs[i] = NewCollisionCapsule(body, bone.Radius, ragdollLimbHalfHeight(&bone))
+		r.Limbs[i].CalculateDerivedData()
+
+		var inertia m.Matrix3
+		inertia.SetSphereInertiaTensor(bone.Length*ragdollInertiaRadius, body.GetMass())
+		body.SetInertiaTensor(&inertia)
+	}
+
+	for i := BoneChest; i < RagdollBoneCount; i++ {
+		joint := ragdollJoints[i]
+		parent, body := r.Limbs[joint.parent].Body, r.Limbs[i].Body
+		r.filter.join(body, parent)
+
+		anchor := bones[i].Position
+		if joint.hinge {
+			axis := body.Orientation.Rotate(&m.Vector3{1.0, 0.0, 0.0})
+			hinge := NewHingeJoint(parent, body, anchor, axis, 0.0)
+			hinge.SetLimits(joint.lower, joint.upper)
+			hinge.Length = bones[i].Length
+			r.Joints[i] = hinge
+			continue
+		}
+
+		// a cone about the direction of the bone, with the twist about the
+		// bone along the Y axis of its frame. The levers are kept to the size
+		// of the limb since a long lever on a light limb is hard to solve.
+		cone := NewGenericJoint(parent, body, anchor, body.Orientation, 0.0)
+		cone.Length = bones[i].Length
+		cone.SetAngularLimits(0, -joint.swing, joint.swing)
+		cone.SetAngularLimits(1, -joint.twist, joint.twist)
+		cone.SetAngularLimits(2, -joint.swing, joint.swing)
+		r.Joints[i] = cone
+	}
+	return r, nil
+}
+
+// AddRagdoll adds the limbs and joints of the ragdoll to the world. Joined
+// limbs don't collide with each other.
+func (w *World) AddRagdoll(r *Ragdoll) {
+	if r.world != nil {
+		return
+	}
+	r.world = w
+	for _, limb := range r.Limbs {
+		w.AddCollider(limb)
+	}
+	for _, joint := range r.Joints {
+		if joint != nil {
+			w.AddConstraint(joint)
+		}
+	}
+	w.setCollisionFilter(r.filter, r.bodies())
+}
+
+// RemoveRagdoll removes the limbs and joints of the ragdoll from the world.
+func (w *World) RemoveRagdoll(r *Ragdoll) {
+	if r.world != w {
+		return
+	}
+	r.world = nil
+	w.clearCollisionFilter(r.filter, r.bodies())
+	for _, joint := range r.Joints {
+		if joint != nil {
+			w.RemoveConstraint(joint)
+		}
+	}
+	for _, limb := range r.Limbs {
+		w.RemoveCollider(limb)
+		w.RemoveBody(limb.Body)
+	}
+}
+
+// Assembly returns an Assembly of the limbs and joints of the ragdoll, so that
+// a World can freeze it once it has come to rest.
+func (r *Ragdoll) Assembly() *Assembly {
+	a := NewAssembly(nil, nil)
+	for i, limb := range r.Limbs {
+		a.Bodies = append(a.Bodies, limb.Body)
+		if r.Joints[i] != nil {
+			a.Constraints = append(a.Constraints, r.Joints[i])
+		}
+	}
+	return a
+}
+
+// bodies returns the bodies of the limbs.
+func (r *Ragdoll) bodies() []*RigidBody {
+	bodies := make([]*RigidBody, 0, len(r.Limbs))
+	for _, limb := range r.Limbs {
+		bodies = append(bodies, limb.Body)
+	}
+	return bodies
+}
+
+// ragdollLimbHalfHeight returns the HalfHeight of the capsule around the
+// bone, which ends with the bone unless the bone is too short.
+func ragdollLimbHalfHeight(bone *RagdollBoneTransform) m.Real {
+	halfHeight := bone.Length*0.5 - bone.Radius
+	if halfHeight < 0.0 {
+		return 0.0
+	}
+	return halfHeight
+}
+
+// ragdollLimbVolume returns the volume of the capsule around the bone.
+func ragdollLimbVolume(bone *RagdollBoneTransform) m.Real {
+	halfHeight := ragdollLimbHalfHeight(bone)
+	return math.Pi * bone.Radius * bone.Radius * (2.0*halfHeight + 4.0/3.0*bone.Radius)
+}
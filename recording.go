@@ -0,0 +1,324 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// recordingVersion is the version of the recording layout written by Recorder.
+const recordingVersion = 1
+
+// InputKind is the way a RecordedInput pushes on its body.
+type InputKind int
+
+const (
+	// InputForce adds a force at the center of mass with AddForce.
+	InputForce InputKind = iota
+
+	// InputForceAtPoint adds a force at a point in World Space with AddForceAtPoint.
+	InputForceAtPoint
+
+	// InputTorque adds a torque with AddTorque.
+	InputTorque
+
+	// InputLinearImpulse applies an impulse at the center of mass with ApplyLinearImpulse.
+	InputLinearImpulse
+
+	// InputAngularImpulse applies an angular impulse with ApplyAngularImpulse.
+	InputAngularImpulse
+
+	// InputImpulseAtPoint applies an impulse at a point in World Space with ApplyImpulseAtPoint.
+	InputImpulseAtPoint
+)
+
+// RecordedInput is a force or impulse that was applied to a body before a step.
+type RecordedInput struct {
+	// Kind is the way the input pushes on the body.
+	Kind InputKind
+
+	// Body is the index of the body in the Bodies of the world.
+	Body int
+
+	// Vector is the force, torque or impulse.
+	Vector m.Vector3
+
+	// Point is the point in World Space the input is applied at, for the
+	// kinds that take one.
+	Point m.Vector3
+}
+
+// applyTo pushes on the body the way the input describes.
+func (input *RecordedInput) applyTo(body *RigidBody) error {
+	switch input.Kind {
+	case InputForce:
+		body.AddForce(&input.Vector)
+	case InputForceAtPoint:
+		body.AddForceAtPoint(&input.Vector, &input.Point)
+	case InputTorque:
+		body.AddTorque(&input.Vector)
+	case InputLinearImpulse:
+		body.ApplyLinearImpulse(&input.Vector)
+	case InputAngularImpulse:
+		body.ApplyAngularImpulse(&input.Vector)
+	case InputImpulseAtPoint:
+		body.ApplyImpulseAtPoint(&input.Vector, &input.Point)
+	default:
+		return fmt.Errorf("unknown input kind %d", input.Kind)
+	}
+	return nil
+}
+
+// RecordedFrame holds what happened in one step of a recording.
+type RecordedFrame struct {
+	// Duration is the length of the step.
+	Duration m.Real
+
+	// Spawns holds the colliders added before the step, each written as a
+	// world by MarshalJSON. They're added before the Inputs are applied.
+	Spawns []json.RawMessage `json:",omitempty"`
+
+	// Inputs holds the forces and impulses applied before the step, in the
+	// order they were applied.
+	Inputs []RecordedInput `json:",omitempty"`
+
+	// Transforms holds the state of every body after the step, if the
+	// recorder was keeping them.
+	Transforms []BodySnapshot `json:",omitempty"`
+
+	// Contacts holds the points where the bodies touched in the step, if the
+	// recorder was keeping the transforms.
+	Contacts []m.Vector3 `json:",omitempty"`
+
+	// Checksum is the Checksum of the world after the step.
+	Checksum uint64
+}
+
+// Recording is a simulation captured by a Recorder that a Replayer can play
+// back.
+type Recording struct {
+	// Version is the version of the recording layout.
+	Version int
+
+	// World is the world as it was when the recording started, written by
+	// MarshalJSON.
+	World json.RawMessage
+
+	// Frames holds each step of the recording in order.
+	Frames []RecordedFrame
+}
+
+// LoadRecording reads a Recording written by Recording.Save.
+func LoadRecording(r io.Reader) (*Recording, error) {
+	recording := new(Recording)
+	if err := json.NewDecoder(r).Decode(recording); err != nil {
+		return nil, fmt.Errorf("failed to decode the recording: %v", err)
+	}
+	if recording.Version != recordingVersion {
+		return nil, fmt.Errorf("the recording has version %d; expected %d", recording.Version, recordingVersion)
+	}
+	return recording, nil
+}
+
+// Save writes the recording as JSON.
+func (recording *Recording) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(recording)
+}
+
+// Recorder captures the inputs to a world step by step so that a glitch can
+// be played back with a Replayer and looked at again. The world is written
+// out when the recorder is made, and after that the world should be changed
+// only through the recorder: forces and impulses with Apply, new colliders
+// with Spawn and steps with Step. Force generators and the world's Forces
+// aren't recorded, so the same ones need to be set up again on the replay.
+type Recorder struct {
+	// Transforms makes the recorder keep the state of every body and the
+	// contact points after each step as well as the inputs, which makes the
+	// recording bigger but lets it be viewed without stepping the world.
+	// Defaults to false.
+	Transforms bool
+
+	world     *World
+	recording *Recording
+	pending   RecordedFrame
+}
+
+// NewRecorder creates a new Recorder that starts recording the world as it is
+// now. It fails if the world can't be written with MarshalJSON.
+func NewRecorder(w *World) (*Recorder, error) {
+	data, err := json.Marshal(w)
+	if err != nil {
+		return nil, err
+	}
+	r := new(Recorder)
+	r.world = w
+	r.recording = &Recording{Version: recordingVersion, World: data}
+	return r, nil
+}
+
+// Recording returns the frames recorded so far.
+func (r *Recorder) Recording() *Recording {
+	return r.recording
+}
+
+// Apply pushes on the body with the kind of input given and records it for
+// the next step. The point is ignored by the kinds that don't take one. It
+// fails if the body isn't in the world.
+func (r *Recorder) Apply(body *RigidBody, kind InputKind, vector m.Vector3, point m.Vector3) error {
+	index := -1
+	for i, b := range r.world.Bodies {
+		if b == body {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("the body isn't in the recorded world")
+	}
+	input := RecordedInput{Kind: kind, Body: index, Vector: vector, Point: point}
+	if err := input.applyTo(body); err != nil {
+		return err
+	}
+	r.pending.Inputs = append(r.pending.Inputs, input)
+	return nil
+}
+
+// Spawn adds the collider, and its body if it has one, to the world and
+// records it for the next step. It fails if the collider can't be written
+// with MarshalJSON.
+func (r *Recorder) Spawn(c Collider) error {
+	spawn := new(World)
+	spawn.AddCollider(c)
+	data, err := spawn.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	r.world.AddCollider(c)
+	r.pending.Spawns = append(r.pending.Spawns, data)
+	return nil
+}
+
+// Step steps the world and records the frame.
+func (r *Recorder) Step(duration m.Real) []*Contact {
+	contacts := r.world.Step(duration)
+
+	frame := r.pending
+	r.pending = RecordedFrame{}
+	frame.Duration = duration
+	frame.Checksum = r.world.Checksum()
+	if r.Transforms {
+		frame.Transforms = r.world.Snapshot()
+		for _, contact := range contacts {
+			frame.Contacts = append(frame.Contacts, contact.ContactPoint)
+		}
+	}
+	r.recording.Frames = append(r.recording.Frames, frame)
+	return contacts
+}
+
+// Replayer plays a Recording back through a world built from it, checking
+// each step against the recorded checksum to find where it diverges.
+type Replayer struct {
+	// World is the world being played back.
+	World *World
+
+	recording *Recording
+	frame     int
+}
+
+// NewReplayer creates a new Replayer that builds the world of the recording
+// and stands at its start.
+func NewReplayer(recording *Recording) (*Replayer, error) {
+	p := new(Replayer)
+	p.recording = recording
+	if err := p.Rewind(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Rewind builds the world of the recording again and goes back to its
+// start. Force generators and Forces set on the old World need to be set on
+// the new one.
+func (p *Replayer) Rewind() error {
+	w := NewWorld()
+	if err := json.Unmarshal(p.recording.World, w); err != nil {
+		return fmt.Errorf("failed to load the recorded world: %v", err)
+	}
+	p.World = w
+	p.frame = 0
+	return nil
+}
+
+// Frame returns the number of frames played so far.
+func (p *Replayer) Frame() int {
+	return p.frame
+}
+
+// Done returns true if every frame has been played.
+func (p *Replayer) Done() bool {
+	return p.frame >= len(p.recording.Frames)
+}
+
+// Step plays the next frame: adding its spawns, applying its inputs and
+// stepping the world. It fails if there are no frames left, if the frame
+// can't be applied, or if the world ends up with a different checksum to
+// the recording, in which case the world is still stepped.
+func (p *Replayer) Step() ([]*Contact, error) {
+	if p.Done() {
+		return nil, fmt.Errorf("the recording has no frames left")
+	}
+	frame := &p.recording.Frames[p.frame]
+	for _, data := range frame.Spawns {
+		spawn := NewWorld()
+		if err := json.Unmarshal(data, spawn); err != nil {
+			return nil, fmt.Errorf("failed to load a spawn in frame %d: %v", p.frame, err)
+		}
+		for _, c := range spawn.Colliders {
+			p.World.AddCollider(c)
+		}
+		for _, body := range spawn.Bodies {
+			p.World.AddBody(body)
+		}
+	}
+	for i := range frame.Inputs {
+		input := &frame.Inputs[i]
+		if input.Body < 0 || input.Body >= len(p.World.Bodies) {
+			return nil, fmt.Errorf("an input in frame %d is for body %d of %d", p.frame, input.Body, len(p.World.Bodies))
+		}
+		if err := input.applyTo(p.World.Bodies[input.Body]); err != nil {
+			return nil, fmt.Errorf("failed to apply an input in frame %d: %v", p.frame, err)
+		}
+	}
+
+	contacts := p.World.Step(frame.Duration)
+	p.frame++
+	if checksum := p.World.Checksum(); checksum != frame.Checksum {
+		return contacts, fmt.Errorf("the replay diverged from the recording in frame %d", p.frame-1)
+	}
+	return contacts, nil
+}
+
+// Seek plays the recording up to the frame given, rewinding first if it's
+// behind the current frame. It stops at the first error.
+func (p *Replayer) Seek(frame int) error {
+	if frame < 0 || frame > len(p.recording.Frames) {
+		return fmt.Errorf("frame %d is outside of the %d frames of the recording", frame, len(p.recording.Frames))
+	}
+	if frame < p.frame {
+		if err := p.Rewind(); err != nil {
+			return err
+		}
+	}
+	for p.frame < frame {
+		if _, err := p.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -19,6 +19,60 @@ var (
 	defaultAcceleration = m.Vector3{0.0, -9.78, 0.0}
 )
 
+// RenormalizePolicy controls how often the orientation of a RigidBody is
+// renormalized during integration. Orientations drift away from unit length
+// over many steps, but renormalizing every step costs a square root per body.
+// If neither field is set, the orientation is renormalized every step.
+type RenormalizePolicy struct {
+	// Interval renormalizes the orientation every Interval steps. A value of
+	// zero or less disables renormalizing on an interval.
+	Interval int
+
+	// Tolerance renormalizes the orientation whenever its squared magnitude
+	// differs from one by more than this amount. A value of zero or less
+	// disables renormalizing on drift.
+	Tolerance m.Real
+}
+
+// shouldRenormalize returns true if the orientation given should be renormalized
+// after the number of steps given since it was last renormalized.
+func (policy *RenormalizePolicy) shouldRenormalize(orientation *m.Quat, steps int) bool {
+	if policy.Interval <= 0 && policy.Tolerance <= 0.0 {
+		return true
+	}
+	if policy.Interval > 0 && steps >= policy.Interval {
+		return true
+	}
+	if policy.Tolerance > 0.0 && m.RealAbs(orientation.Dot(orientation)-1.0) > policy.Tolerance {
+		return true
+	}
+	return false
+}
+
+// AxisLock is a set of flags that freeze the motion of a RigidBody along or
+// about the World Space axes.
+type AxisLock uint8
+
+const (
+	// LockLinearX prevents movement along the X axis.
+	LockLinearX AxisLock = 1 << iota
+
+	// LockLinearY prevents movement along the Y axis.
+	LockLinearY
+
+	// LockLinearZ prevents movement along the Z axis.
+	LockLinearZ
+
+	// LockAngularX prevents rotation about the X axis.
+	LockAngularX
+
+	// LockAngularY prevents rotation about the Y axis.
+	LockAngularY
+
+	// LockAngularZ prevents rotation about the Z axis.
+	LockAngularZ
+)
+
 // RigidBody is the main data structure represending an object that can
 // cause collisions and move around in the physics simulation.
 type RigidBody struct {
@@ -38,7 +92,7 @@ type RigidBody struct {
 	// Orientation is the angular orientation of the RigidBody.
 	Orientation m.Quat
 
-	// Velocity is the linear velocity of the RigidBody in World Space.
+	// Velocity is the linear velocity of the RigidBody's center of mass in World Space.
 	Velocity m.Vector3
 
 	// Acceleration is the acceleration of the RigidBody and can be
@@ -46,6 +100,17 @@ type RigidBody struct {
 	// acceleration desired.
 	Acceleration m.Vector3
 
+	// GravityScale scales the Acceleration of the RigidBody, which is taken to
+	// be gravity, so that floaty objects can fall slower and balloons can rise.
+	// Defaults to 1.0.
+	GravityScale m.Real
+
+	// GravityOverride, if not nil, replaces the Acceleration of the RigidBody
+	// and can be used to implement zero-G zones or local gravity wells. It is
+	// still scaled by GravityScale.
+	// Defaults to nil.
+	GravityOverride *m.Vector3
+
 	// Rotation holds the angular velocity, or rotation, of the rigid body in World Space.
 	Rotation m.Vector3
 
@@ -57,6 +122,13 @@ type RigidBody struct {
 	// NOTE: this is given in Body Space.
 	InverseInertiaTensor m.Matrix3
 
+	// CenterOfMass is the offset of the center of mass from the origin of the
+	// RigidBody, given in Body Space. This allows the physical center of mass to
+	// differ from the graphical origin; the body rotates about this point and
+	// the inertia tensor is taken to be about it as well.
+	// Defaults to the origin.
+	CenterOfMass m.Vector3
+
 	// IsAwake indicates if the RigidBody is awake and should be updated
 	// upon integration.
 	// Defaults to true.
@@ -67,10 +139,84 @@ type RigidBody struct {
 	// Defaults to true.
 	CanSleep bool
 
+	// LinearSleepThreshold is the speed below which the RigidBody is considered
+	// to be at rest. If this and AngularSleepThreshold are both zero, the body
+	// uses the default recency weighted motion test to decide when to sleep.
+	// Defaults to 0.0.
+	LinearSleepThreshold m.Real
+
+	// AngularSleepThreshold is the angular speed, in radians per second, below
+	// which the RigidBody is considered to be at rest. When the thresholds are
+	// in use, a threshold of zero means that motion must stop completely.
+	// Defaults to 0.0.
+	AngularSleepThreshold m.Real
+
+	// SleepDelay is how long, in seconds, the RigidBody must stay below its
+	// sleep thresholds before it's put to sleep. It's only used when one of
+	// the thresholds is set.
+	// Defaults to 0.0.
+	SleepDelay m.Real
+
+	// ContinuousCollision enables swept checks against half-spaces so that a
+	// fast moving body that passed completely through a plane in one step
+	// still generates a contact at the point where it crossed.
+	// Defaults to false.
+	ContinuousCollision bool
+
+	// AxisLocks holds the set of World Space axes that the RigidBody is not
+	// allowed to move along or rotate about. This can be used to keep characters
+	// upright or to keep objects on a rail.
+	// Defaults to no locks.
+	AxisLocks AxisLock
+
+	// MaxLinearSpeed limits the speed of the RigidBody after each integration,
+	// which keeps a bad frame in the solver from producing absurd velocities.
+	// A value of zero or less means there is no limit.
+	// Defaults to 0.0.
+	MaxLinearSpeed m.Real
+
+	// MaxAngularSpeed limits the angular speed, in radians per second, of the
+	// RigidBody after each integration. A value of zero or less means there is
+	// no limit.
+	// Defaults to 0.0.
+	MaxAngularSpeed m.Real
+
+	// GyroscopicTorque enables the gyroscopic term (w x Iw) of the rotational
+	// equations of motion so that spinning elongated bodies precess and tumble
+	// correctly. It is solved implicitly to stay stable at high angular velocity.
+	// Defaults to false.
+	GyroscopicTorque bool
+
+	// Renormalize is the policy for renormalizing the Orientation during
+	// integration.
+	// Defaults to renormalizing every step.
+	Renormalize RenormalizePolicy
+
+	// Medium is the fluid the RigidBody is moving through, which scales the
+	// drag and buoyancy applied to it. It can be switched automatically with
+	// UpdateMediums.
+	// Defaults to nil, which is treated as MediumAir.
+	Medium *Medium
+
+	// OnTransform, if not nil, is called with the Position and Orientation
+	// of the body after each step of the World the body has moved in, so
+	// that the objects drawn for it can follow it. It is not copied by Clone.
+	OnTransform TransformFunc `json:"-"`
+
 	// inverseInertiaTensorWorld holdes the inverse inertia tensor of the
 	// body in World Space.
 	inverseInertiaTensorWorld m.Matrix3
 
+	// inertiaStale is set when the inverseInertiaTensorWorld needs to be
+	// calculated again, which is put off until it's next used. It's only
+	// set when the Orientation, InverseInertiaTensor or AxisLocks have
+	// changed from the values held in inertiaOrientation, inertiaLocal and
+	// inertiaLocks.
+	inertiaStale       bool
+	inertiaOrientation m.Quat
+	inertiaLocal       m.Matrix3
+	inertiaLocks       AxisLock
+
 	// inverseMass holds the inverse of the mass of the RigidBody which
 	// is used much more often in calculations than just the mass.
 	inverseMass m.Real
@@ -81,6 +227,11 @@ type RigidBody struct {
 	// inverseMass is also changed.
 	mass m.Real
 
+	// shapeMasses holds the mass properties of each collider SetDensity has
+	// been called on, which are added up for bodies with several colliders.
+	// It's replaced rather than changed so that snapshots can share it.
+	shapeMasses []shapeMass
+
 	// transform holds a transofm matrix for converting Body Space into World Space.
 	transform m.Matrix3x4
 
@@ -99,15 +250,76 @@ type RigidBody struct {
 	// motion holds the amount of motion of the body and is a recently weighted
 	// mean that can be used to put a body to sleep.
 	motion m.Real
+
+	// timeAtRest holds how long the body has been below its sleep thresholds.
+	timeAtRest m.Real
+
+	// planarConstrained indicates whether the body is kept on the plane
+	// described by planarNormal and planarOffset.
+	planarConstrained bool
+
+	// planarNormal is the normal of the plane the body is constrained to.
+	planarNormal m.Vector3
+
+	// planarOffset is the distance of the plane the body is constrained to from the origin.
+	planarOffset m.Real
+
+	// prevPosition holds the Position of the body before the last integration,
+	// or before the first substep of a sped up World.Step.
+	prevPosition m.Vector3
+
+	// prevOrientation holds the Orientation of the body before the last integration,
+	// or before the first substep of a sped up World.Step.
+	prevOrientation m.Quat
+
+	// prevVelocity holds the Velocity of the body before the last integration.
+	prevVelocity m.Vector3
+
+	// lastDuration holds the duration of the last integration.
+	lastDuration m.Real
+
+	// stepsSinceRenormalize holds the number of integrations since the
+	// Orientation was last renormalized.
+	stepsSinceRenormalize int
+
+	// fieldGravity holds the pull of the world's attractors on the body and
+	// replaces the Acceleration when hasFieldGravity is set.
+	fieldGravity    m.Vector3
+	hasFieldGravity bool
+
+	// zoneGravity and zoneGravityScale hold the gravity of the gravity zones
+	// the body is in while inGravityZone is set. zoneGravity replaces the
+	// Acceleration when hasZoneGravity is set.
+	zoneGravity      m.Vector3
+	zoneGravityScale m.Real
+	hasZoneGravity   bool
+	inGravityZone    bool
+
+	// articulated is set while the body is moved by an Articulation instead
+	// of being integrated on its own.
+	articulated bool
+
+	// notifiedPosition and notifiedOrientation hold the pose last passed to
+	// OnTransform, which is only called again once it has changed. notified
+	// is set once OnTransform has been called at all.
+	notifiedPosition    m.Vector3
+	notifiedOrientation m.Quat
+	notified            bool
 }
 
+// TransformFunc is the type of the RigidBody.OnTransform callback, which
+// gets the position and orientation of the body in World Space.
+type TransformFunc func(position m.Vector3, orientation m.Quat)
+
 // NewRigidBody creates a new RigidBody object and returns it.
 func NewRigidBody() *RigidBody {
 	body := new(RigidBody)
 	body.Orientation.SetIdentity()
+	body.prevOrientation.SetIdentity()
 	body.LinearDamping = defaultLinearDamping
 	body.AngularDamping = defaultLinearDamping
 	body.Acceleration = defaultAcceleration
+	body.GravityScale = 1.0
 	body.inverseInertiaTensorWorld.SetIdentity()
 	body.CanSleep = true
 	body.SetAwake(true)
@@ -118,13 +330,37 @@ func NewRigidBody() *RigidBody {
 func (body *RigidBody) Clone() *RigidBody {
 	newBody := NewRigidBody()
 	*newBody = *body
+	newBody.OnTransform = nil
+	newBody.notified = false
+
+	// the colliders of the clone are new, so their densities add up afresh
+	newBody.shapeMasses = nil
 	return newBody
 }
 
-// SetMass sets the mass of the RigidBody object.
+// NotifyTransform calls OnTransform with the Position and Orientation of the
+// body if they have changed since it was last called, or if it hasn't been
+// called yet. World.Step calls this for every body, so it's only needed for
+// bodies that are moved outside of a World.
+func (body *RigidBody) NotifyTransform() {
+	if body.OnTransform == nil {
+		return
+	}
+	if body.notified && body.notifiedPosition == body.Position && body.notifiedOrientation == body.Orientation {
+		return
+	}
+	body.notifiedPosition = body.Position
+	body.notifiedOrientation = body.Orientation
+	body.notified = true
+	body.OnTransform(body.Position, body.Orientation)
+}
+
+// SetMass sets the mass of the RigidBody object. This replaces any mass the
+// body got from the SetDensity of its colliders.
 func (body *RigidBody) SetMass(mass m.Real) {
 	body.mass = mass
 	body.inverseMass = 1.0 / mass
+	body.shapeMasses = nil
 }
 
 // SetInfiniteMass sets the mass of the RigidBody object to be 'infinite' ... which
@@ -132,6 +368,7 @@ func (body *RigidBody) SetMass(mass m.Real) {
 func (body *RigidBody) SetInfiniteMass() {
 	body.mass = 0.0
 	body.inverseMass = 0.0
+	body.shapeMasses = nil
 }
 
 // HasFiniteMass returns true if the RigidBody has a finite mass (not infinite).
@@ -160,6 +397,34 @@ func (body *RigidBody) GetTransform() m.Matrix3x4 {
 	return body.transform
 }
 
+// Position32 returns the Position of the RigidBody as float32s, ready to be
+// given to OpenGL. It converts straight to a mgl32.Vec3.
+func (body *RigidBody) Position32() [3]float32 {
+	return [3]float32{float32(body.Position[0]), float32(body.Position[1]), float32(body.Position[2])}
+}
+
+// Orientation32 returns the Orientation of the RigidBody as float32s in the
+// same (w,x,y,z) order, which makes a mgl32.Quat as Quat{W: q[0], V: Vec3{q[1], q[2], q[3]}}.
+func (body *RigidBody) Orientation32() [4]float32 {
+	q := &body.Orientation
+	return [4]float32{float32(q[0]), float32(q[1]), float32(q[2]), float32(q[3])}
+}
+
+// TransformMat4 returns the transform of the RigidBody's Position and
+// Orientation as a column major 4x4 matrix of float32s, the layout OpenGL
+// uses. It converts straight to a mgl32.Mat4 and, unlike GetTransform, doesn't
+// need CalculateDerivedData to be called after the body is moved.
+func (body *RigidBody) TransformMat4() [16]float32 {
+	var t m.Matrix3x4
+	t.SetAsTransform(&body.Position, &body.Orientation)
+	return [16]float32{
+		float32(t[0]), float32(t[1]), float32(t[2]), 0.0,
+		float32(t[3]), float32(t[4]), float32(t[5]), 0.0,
+		float32(t[6]), float32(t[7]), float32(t[8]), 0.0,
+		float32(t[9]), float32(t[10]), float32(t[11]), 1.0,
+	}
+}
+
 // GetLastFrameAccelleration returns a copy of the RigidBody's linear accelleration
 // for the last frame.
 func (body *RigidBody) GetLastFrameAccelleration() m.Vector3 {
@@ -169,9 +434,26 @@ func (body *RigidBody) GetLastFrameAccelleration() m.Vector3 {
 // GetInverseInertiaTensorWorld returns a copy of the RigidBody's inverse
 // inertia tensor in World Space.
 func (body *RigidBody) GetInverseInertiaTensorWorld() m.Matrix3 {
+	// work it out without keeping it so that reading the tensor of other
+	// bodies from several goroutines doesn't race
+	if body.inertiaStale {
+		var tensor m.Matrix3
+		body.calculateInertiaTensorWorld(&tensor)
+		return tensor
+	}
 	return body.inverseInertiaTensorWorld
 }
 
+// inverseInertiaWorld returns the inverse inertia tensor in World Space,
+// calculating it first if it's stale.
+func (body *RigidBody) inverseInertiaWorld() *m.Matrix3 {
+	if body.inertiaStale {
+		body.calculateInertiaTensorWorld(&body.inverseInertiaTensorWorld)
+		body.inertiaStale = false
+	}
+	return &body.inverseInertiaTensorWorld
+}
+
 // SetInertiaTensor sets the InverseInertiaTensor member of the RigidBody
 // by calculating the inverse of the matrix supplied.
 func (body *RigidBody) SetInertiaTensor(m *m.Matrix3) {
@@ -185,6 +467,7 @@ func (body *RigidBody) SetAwake(awake bool) {
 		body.IsAwake = true
 		// add some motion to avoid it falling asleep immediately
 		body.motion = sleepEpsilon * 2.0
+		body.timeAtRest = 0.0
 	} else {
 		body.IsAwake = false
 		body.Velocity.Clear()
@@ -202,6 +485,163 @@ func (body *RigidBody) AddRotation(v *m.Vector3) {
 	body.Rotation.Add(v)
 }
 
+// GetCenterOfMassWorld returns the center of mass of the RigidBody in World Space.
+func (body *RigidBody) GetCenterOfMassWorld() m.Vector3 {
+	if !body.hasCenterOfMassOffset() {
+		return body.Position
+	}
+	com := body.Orientation.Rotate(&body.CenterOfMass)
+	com.Add(&body.Position)
+	return com
+}
+
+// GetVelocityAtPoint returns the velocity, in World Space, of a point given in
+// World Space that moves along with the RigidBody.
+func (body *RigidBody) GetVelocityAtPoint(point *m.Vector3) m.Vector3 {
+	pt := *point
+	com := body.GetCenterOfMassWorld()
+	pt.Sub(&com)
+
+	velocity := body.Rotation.Cross(&pt)
+	velocity.Add(&body.Velocity)
+	return velocity
+}
+
+// hasCenterOfMassOffset returns true if the center of mass is not at the origin
+// of the RigidBody.
+func (body *RigidBody) hasCenterOfMassOffset() bool {
+	return body.CenterOfMass[0] != 0.0 || body.CenterOfMass[1] != 0.0 || body.CenterOfMass[2] != 0.0
+}
+
+// AddForce adds a force, given in World Space, to the center of mass of the
+// RigidBody. The force is accumulated and applied at the next integration.
+func (body *RigidBody) AddForce(force *m.Vector3) {
+	body.forceAccum.Add(force)
+	body.SetAwake(true)
+}
+
+// AddForceAtPoint adds a force at the given point; both are in World Space.
+// Unless the point is the center of mass, this also generates a torque.
+func (body *RigidBody) AddForceAtPoint(force *m.Vector3, point *m.Vector3) {
+	// convert to coordinates relative to the center of mass
+	pt := *point
+	com := body.GetCenterOfMassWorld()
+	pt.Sub(&com)
+
+	torque := pt.Cross(force)
+	body.forceAccum.Add(force)
+	body.torqueAccum.Add(&torque)
+	body.SetAwake(true)
+}
+
+// AddForceAtBodyPoint adds a force, given in World Space, at the given point,
+// given in Body Space.
+func (body *RigidBody) AddForceAtBodyPoint(force *m.Vector3, point *m.Vector3) {
+	pt := body.transform.MulVector3(point)
+	body.AddForceAtPoint(force, &pt)
+}
+
+// AddTorque adds a torque, given in World Space, to the RigidBody. The torque
+// is accumulated and applied at the next integration.
+func (body *RigidBody) AddTorque(torque *m.Vector3) {
+	body.torqueAccum.Add(torque)
+	body.SetAwake(true)
+}
+
+// ApplyLinearImpulse applies an impulse, given in World Space, through the center
+// of mass of the RigidBody; this changes the Velocity immediately instead of
+// accumulating a force for the next integration.
+func (body *RigidBody) ApplyLinearImpulse(impulse *m.Vector3) {
+	body.Velocity.AddScaled(impulse, body.inverseMass)
+	body.SetAwake(true)
+}
+
+// ApplyAngularImpulse applies an angular impulse, given in World Space, to the
+// RigidBody, changing the Rotation immediately.
+func (body *RigidBody) ApplyAngularImpulse(impulse *m.Vector3) {
+	rotationChange := body.inverseInertiaWorld().MulVector3(impulse)
+	body.Rotation.Add(&rotationChange)
+	body.SetAwake(true)
+}
+
+// ApplyImpulseAtPoint applies an impulse at the given point; both are in World Space.
+// Unless the point is the center of mass, this changes both the Velocity and the
+// Rotation of the RigidBody.
+func (body *RigidBody) ApplyImpulseAtPoint(impulse *m.Vector3, point *m.Vector3) {
+	// convert to coordinates relative to the center of mass
+	pt := *point
+	com := body.GetCenterOfMassWorld()
+	pt.Sub(&com)
+
+	angularImpulse := pt.Cross(impulse)
+	body.ApplyLinearImpulse(impulse)
+	body.ApplyAngularImpulse(&angularImpulse)
+}
+
+// ApplyImpulseAtBodyPoint applies an impulse, given in World Space, at the given
+// point, given in Body Space.
+func (body *RigidBody) ApplyImpulseAtBodyPoint(impulse *m.Vector3, point *m.Vector3) {
+	pt := body.transform.MulVector3(point)
+	body.ApplyImpulseAtPoint(impulse, &pt)
+}
+
+// SetTransform moves the RigidBody to the position and orientation given
+// without it being treated as motion: the previous position used by swept
+// collision checks is reset so that the body isn't considered to have travelled
+// through everything in between. The velocities are left alone.
+//
+// NOTE: CalculateDerivedData() should be called on the colliders of the body
+// afterwards so that they match its new transform.
+func (body *RigidBody) SetTransform(position *m.Vector3, orientation *m.Quat) {
+	body.Position = *position
+	body.Orientation = *orientation
+	body.prevPosition = body.Position
+	body.prevOrientation = body.Orientation
+	body.prevVelocity = body.Velocity
+	body.CalculateDerivedData()
+	body.SetAwake(true)
+}
+
+// InterpolatedTransform returns a transform that blends from the state of the
+// RigidBody before the last integration, or before the last World.Step when it
+// was split into substeps, to its current state. An alpha of 0.0 gives the
+// previous state and 1.0 gives the current state. This lets a renderer
+// running faster than a fixed physics rate draw smooth motion by passing the
+// fraction of a physics step left over in its accumulator.
+func (body *RigidBody) InterpolatedTransform(alpha m.Real) m.Matrix3x4 {
+	position := body.prevPosition
+	position.MulWith(1.0 - alpha)
+	position.AddScaled(&body.Position, alpha)
+
+	orientation := m.QuatNlerp(&body.prevOrientation, &body.Orientation, alpha)
+
+	var transform m.Matrix3x4
+	transform.SetAsTransform(&position, &orientation)
+	return transform
+}
+
+// GetGravity returns the acceleration due to gravity acting on the RigidBody
+// after applying the GravityOverride and GravityScale. In a World with
+// attractors their pull is used in place of the Acceleration, and inside a
+// gravity zone the gravity of the zone is used.
+func (body *RigidBody) GetGravity() m.Vector3 {
+	gravity := body.Acceleration
+	if body.hasFieldGravity {
+		gravity = body.fieldGravity
+	}
+	if body.inGravityZone {
+		if body.hasZoneGravity {
+			gravity = body.zoneGravity
+		}
+		gravity.MulWith(body.zoneGravityScale)
+	}
+	if body.GravityOverride != nil {
+		gravity = *body.GravityOverride
+	}
+	gravity.MulWith(body.GravityScale)
+	return gravity
+}
+
 // ClearAccumulators resets all of the stored linear and torque forces
 // stored in the body.
 func (body *RigidBody) ClearAccumulators() {
@@ -210,18 +650,16 @@ func (body *RigidBody) ClearAccumulators() {
 }
 
 // Integrate takes all of the forces accumulated in the RigidBody and
-// change the Position and Orientation of the object.
+// change the Position and Orientation of the object using semi-implicit
+// Euler integration.
 func (body *RigidBody) Integrate(duration m.Real) {
-	if body.IsAwake == false {
+	if !body.beginIntegration(duration) {
 		return
 	}
 
-	// calculate linear acceleration from force inputs.
-	body.lastFrameAccelleration = body.Acceleration
-	body.lastFrameAccelleration.AddScaled(&body.forceAccum, body.inverseMass)
-
-	// calculate angular acceleration from torque inputs
-	angularAcceleration := body.inverseInertiaTensorWorld.MulVector3(&body.torqueAccum)
+	// calculate linear and angular acceleration from force inputs.
+	var angularAcceleration m.Vector3
+	body.lastFrameAccelleration, angularAcceleration = body.calculateAccelerations()
 
 	// adjust velocities
 	// update linear velocity from both acceleration and impulse
@@ -230,23 +668,122 @@ func (body *RigidBody) Integrate(duration m.Real) {
 	// update angular velocity from both acceleration and impulse
 	body.Rotation.AddScaled(&angularAcceleration, duration)
 
+	body.constrainVelocities(duration)
+
+	// adjust positions
+	if body.hasCenterOfMassOffset() {
+		// the velocity is that of the center of mass, so move the center of
+		// mass and then place the origin relative to the new orientation.
+		com := body.GetCenterOfMassWorld()
+		com.AddScaled(&body.Velocity, duration)
+
+		body.Orientation.AddScaledVector(&body.Rotation, duration)
+		body.setCenterOfMassWorld(&com)
+	} else {
+		// update linear positions
+		body.Position.AddScaled(&body.Velocity, duration)
+
+		//update angular position
+		body.Orientation.AddScaledVector(&body.Rotation, duration)
+	}
+
+	body.endIntegration(duration)
+}
+
+// beginIntegration records the state of the body before it's integrated and
+// returns true if the body is awake and should be integrated.
+func (body *RigidBody) beginIntegration(duration m.Real) bool {
+	body.prevPosition = body.Position
+	body.prevOrientation = body.Orientation
+	body.prevVelocity = body.Velocity
+	body.lastDuration = duration
+	return body.IsAwake
+}
+
+// calculateAccelerations returns the linear and angular accelerations of the
+// body due to gravity and the accumulated forces with locked axes removed.
+func (body *RigidBody) calculateAccelerations() (linear, angular m.Vector3) {
+	linear = body.GetGravity()
+	linear.AddScaled(&body.forceAccum, body.inverseMass)
+	body.inverseInertiaWorld().MulVector3Into(&angular, &body.torqueAccum)
+	body.applyLinearLocks(&linear)
+	body.applyAngularLocks(&angular)
+	return
+}
+
+// constrainVelocities applies the gyroscopic term, the axis locks and the
+// damping to the velocities after they have been updated for a step.
+func (body *RigidBody) constrainVelocities(duration m.Real) {
+	// apply the gyroscopic term if requested
+	if body.GyroscopicTorque {
+		body.applyGyroscopicTorque(duration)
+	}
+
+	// remove any motion on locked axes
+	body.applyLinearLocks(&body.Velocity)
+	body.applyAngularLocks(&body.Rotation)
+
 	// impose drag
 	body.Velocity.MulWith(m.Real(math.Pow(float64(body.LinearDamping), float64(duration))))
 	body.Rotation.MulWith(m.Real(math.Pow(float64(body.AngularDamping), float64(duration))))
 
-	// adjust positions
-	// update linear positions
-	body.Position.AddScaled(&body.Velocity, duration)
+	// keep the velocities within the limits
+	body.clampVelocities(body.MaxLinearSpeed, body.MaxAngularSpeed)
+}
 
-	//update angular position
-	body.Orientation.AddScaledVector(&body.Rotation, duration)
+// clampVelocities scales the velocities of the body down to the speed limits
+// given, ignoring limits of zero or less. Velocities that are no longer finite
+// are cleared so they can't spread through the simulation.
+func (body *RigidBody) clampVelocities(maxLinear m.Real, maxAngular m.Real) {
+	clampVector(&body.Velocity, maxLinear)
+	clampVector(&body.Rotation, maxAngular)
+}
 
-	// normalize the orientation and update the matrixes with the new position and orientation
-	body.CalculateDerivedData()
+// clampVector scales the vector down so that its magnitude is no more than the
+// limit given or clears it if it's not finite.
+func clampVector(v *m.Vector3, limit m.Real) {
+	sq := v.SquareMagnitude()
+	if math.IsNaN(float64(sq)) || math.IsInf(float64(sq), 0) {
+		v.Clear()
+		return
+	}
+	if limit > 0.0 && sq > limit*limit {
+		v.MulWith(limit / m.RealSqrt(sq))
+	}
+}
+
+// setCenterOfMassWorld places the origin of the body so that its center of
+// mass is at the World Space point given.
+func (body *RigidBody) setCenterOfMassWorld(com *m.Vector3) {
+	orientation := body.Orientation
+	orientation.Normalize()
+	offset := orientation.Rotate(&body.CenterOfMass)
+	body.Position = *com
+	body.Position.Sub(&offset)
+}
+
+// endIntegration finishes a step of integration by updating the derived data,
+// clearing the accumulators and possibly putting the body to sleep.
+func (body *RigidBody) endIntegration(duration m.Real) {
+	// keep the body in its plane if it's constrained to one
+	if body.planarConstrained {
+		body.applyPlanarConstraint()
+	}
+
+	// normalize the orientation if the policy calls for it and update the
+	// matrixes with the new position and orientation
+	body.stepsSinceRenormalize++
+	if body.Renormalize.shouldRenormalize(&body.Orientation, body.stepsSinceRenormalize) {
+		body.CalculateDerivedData()
+	} else {
+		body.calculateTransforms()
+	}
 	body.ClearAccumulators()
 
 	// update the kinetic energy store and possibly put the body to sleep
-	if body.CanSleep {
+	if body.CanSleep && (body.LinearSleepThreshold > 0.0 || body.AngularSleepThreshold > 0.0) {
+		body.updateSleepThresholds(duration)
+	} else if body.CanSleep {
 		currentMotion := body.Velocity.Dot(&body.Velocity) + body.Rotation.Dot(&body.Rotation)
 		bias := m.Real(math.Pow(0.5, float64(duration)))
 		body.motion = bias*body.motion + (1.0-bias)*currentMotion
@@ -259,6 +796,22 @@ func (body *RigidBody) Integrate(duration m.Real) {
 	}
 }
 
+// updateSleepThresholds puts the body to sleep once its speeds have been below
+// its sleep thresholds for longer than its SleepDelay.
+func (body *RigidBody) updateSleepThresholds(duration m.Real) {
+	linear := body.LinearSleepThreshold
+	angular := body.AngularSleepThreshold
+	if body.Velocity.SquareMagnitude() > linear*linear || body.Rotation.SquareMagnitude() > angular*angular {
+		body.timeAtRest = 0.0
+		return
+	}
+
+	body.timeAtRest += duration
+	if body.timeAtRest >= body.SleepDelay {
+		body.SetAwake(false)
+	}
+}
+
 // CalculateDerivedData internal data from public data members.
 //
 // NOTE: This should be called after the RigidBody's state is alterted
@@ -268,8 +821,165 @@ func (body *RigidBody) Integrate(duration m.Real) {
 //   Position, Orientation
 func (body *RigidBody) CalculateDerivedData() {
 	body.Orientation.Normalize()
+	body.stepsSinceRenormalize = 0
+	body.calculateTransforms()
+}
+
+// calculateTransforms updates the transform from the current Position and
+// Orientation without renormalizing it, and marks the World Space inertia
+// tensor as stale if it changes.
+func (body *RigidBody) calculateTransforms() {
 	body.transform.SetAsTransform(&body.Position, &body.Orientation)
-	transformInertiaTensor(&body.inverseInertiaTensorWorld, &body.InverseInertiaTensor, &body.transform)
+	if body.Orientation != body.inertiaOrientation || body.InverseInertiaTensor != body.inertiaLocal || body.AxisLocks != body.inertiaLocks {
+		body.inertiaOrientation = body.Orientation
+		body.inertiaLocal = body.InverseInertiaTensor
+		body.inertiaLocks = body.AxisLocks
+		body.inertiaStale = true
+	}
+}
+
+// calculateInertiaTensorWorld sets the tensor given to the inverse inertia
+// tensor in World Space using the current transform.
+func (body *RigidBody) calculateInertiaTensorWorld(tensor *m.Matrix3) {
+	// a body that can't rotate at all doesn't need the tensor transformed
+	const lockAngular = LockAngularX | LockAngularY | LockAngularZ
+	if body.AxisLocks&lockAngular == lockAngular {
+		*tensor = m.Matrix3{}
+		return
+	}
+	transformInertiaTensor(tensor, &body.InverseInertiaTensor, &body.transform)
+
+	// zero out the rows and columns of the inverse inertia tensor for locked
+	// rotation axes so that torques and impulses can't turn the body about them.
+	if body.AxisLocks != 0 {
+		for i := 0; i < 3; i++ {
+			if body.AxisLocks&(LockAngularX<<uint(i)) != 0 {
+				tensor[i*3+0] = 0.0
+				tensor[i*3+1] = 0.0
+				tensor[i*3+2] = 0.0
+				tensor[0*3+i] = 0.0
+				tensor[1*3+i] = 0.0
+				tensor[2*3+i] = 0.0
+			}
+		}
+	}
+}
+
+// SetPlanarConstraint constrains the RigidBody to move within the plane with the
+// normal and offset given and to only rotate about the plane's normal. This allows
+// side-scrolling or top-down games to use the 3D simulation without bodies drifting
+// out of the plane of play. The constraint is enforced during integration.
+func (body *RigidBody) SetPlanarConstraint(normal m.Vector3, offset m.Real) {
+	normal.Normalize()
+	body.planarConstrained = true
+	body.planarNormal = normal
+	body.planarOffset = offset
+	body.applyPlanarConstraint()
+	body.CalculateDerivedData()
+}
+
+// ClearPlanarConstraint removes any plane constraint from the RigidBody.
+func (body *RigidBody) ClearPlanarConstraint() {
+	body.planarConstrained = false
+}
+
+// applyPlanarConstraint removes any motion out of the constraint plane and moves
+// the body back into the plane to correct drift.
+func (body *RigidBody) applyPlanarConstraint() {
+	n := body.planarNormal
+
+	// remove the velocity along the normal and any rotation not about it
+	body.Velocity.AddScaled(&n, -body.Velocity.Dot(&n))
+	spin := body.Rotation.Dot(&n)
+	body.Rotation = n
+	body.Rotation.MulWith(spin)
+
+	// only keep the twist of the orientation about the normal
+	twist := body.planarNormal
+	twist.MulWith(twist.Dot(&m.Vector3{body.Orientation[1], body.Orientation[2], body.Orientation[3]}))
+	body.Orientation = m.Quat{body.Orientation[0], twist[0], twist[1], twist[2]}
+	body.Orientation.Normalize()
+
+	// move the center of mass back onto the plane
+	com := body.GetCenterOfMassWorld()
+	body.Position.AddScaled(&n, body.planarOffset-n.Dot(&com))
+}
+
+// applyGyroscopicTorque updates the angular velocity with the gyroscopic term
+// of Euler's equations by taking one Newton step of the implicit equation
+// I(w' - w) + dt * w' x Iw' = 0 in Body Space.
+func (body *RigidBody) applyGyroscopicTorque(duration m.Real) {
+	omega := body.transform.TransformInverseDirection(&body.Rotation)
+	inertia := body.InverseInertiaTensor.Invert()
+	momentum := inertia.MulVector3(&omega)
+
+	// the residual of the implicit equation for the current angular velocity
+	residual := omega.Cross(&momentum)
+	residual.MulWith(duration)
+
+	// the jacobian of the equation: I + dt * (skew(w) * I - skew(Iw))
+	var skewOmega, skewMomentum m.Matrix3
+	setSkewSymmetric(&skewOmega, &omega)
+	setSkewSymmetric(&skewMomentum, &momentum)
+	jacobian := skewOmega.MulMatrix3(&inertia)
+	skewMomentum.MulWith(-1.0)
+	jacobian.Add(&skewMomentum)
+	jacobian.MulWith(duration)
+	jacobian.Add(&inertia)
+
+	inverseJacobian := jacobian.Invert()
+	delta := inverseJacobian.MulVector3(&residual)
+	omega.Sub(&delta)
+	body.Rotation = body.transform.TransformDirection(&omega)
+}
+
+// applyLinearLocks zeros the components of the World Space vector for the
+// locked linear axes of the RigidBody.
+func (body *RigidBody) applyLinearLocks(v *m.Vector3) {
+	if body.AxisLocks == 0 {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		if body.AxisLocks&(LockLinearX<<uint(i)) != 0 {
+			v[i] = 0.0
+		}
+	}
+}
+
+// applyAngularLocks zeros the components of the World Space vector for the
+// locked angular axes of the RigidBody.
+func (body *RigidBody) applyAngularLocks(v *m.Vector3) {
+	if body.AxisLocks == 0 {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		if body.AxisLocks&(LockAngularX<<uint(i)) != 0 {
+			v[i] = 0.0
+		}
+	}
+}
+
+// getInverseMassAlong returns the inverse mass of the RigidBody in the direction
+// of the unit vector given, taking locked linear axes into account.
+func (body *RigidBody) getInverseMassAlong(direction *m.Vector3) m.Real {
+	if body.AxisLocks == 0 {
+		return body.inverseMass
+	}
+	free := *direction
+	body.applyLinearLocks(&free)
+	return body.inverseMass * free.Dot(direction)
+}
+
+// getInverseMassMatrix returns the linear inverse mass of the RigidBody as a
+// World Space matrix, taking locked linear axes into account.
+func (body *RigidBody) getInverseMassMatrix() m.Matrix3 {
+	var result m.Matrix3
+	for i := 0; i < 3; i++ {
+		if body.AxisLocks&(LockLinearX<<uint(i)) == 0 {
+			result[i*3+i] = body.inverseMass
+		}
+	}
+	return result
 }
 
 // transformInertiaTensor is an inernal function to do an inertia tensor transform.
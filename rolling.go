@@ -0,0 +1,128 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// RollingConstraint makes a sphere roll without slipping over whatever it's
+// touching. After the contacts are resolved, the slip at each contact of the
+// sphere is removed with a tangential impulse, unless the force needed for that
+// is more than the threshold, in which case the sphere slides and friction
+// takes over as normal.
+type RollingConstraint struct {
+	// MaxTangentialForce is the largest tangential force the constraint will
+	// apply to stop the sphere slipping. A value of zero or less means there's
+	// no limit and the sphere never slips.
+	// Defaults to 0.0.
+	MaxTangentialForce m.Real
+}
+
+// NewRollingConstraint creates a new RollingConstraint that gives up when the
+// tangential force needed is more than the force given.
+func NewRollingConstraint(maxTangentialForce m.Real) *RollingConstraint {
+	rc := new(RollingConstraint)
+	rc.MaxTangentialForce = maxTangentialForce
+	return rc
+}
+
+// contactImpulseMatrix returns the matrix that gives the change in velocity of
+// a point on the body, relative to its center of mass, for an impulse at it.
+func contactImpulseMatrix(body *RigidBody, relativePosition *m.Vector3) m.Matrix3 {
+	var skew m.Matrix3
+	setSkewSymmetric(&skew, relativePosition)
+	angular := skew.MulMatrix3(body.inverseInertiaWorld())
+	angular = angular.MulMatrix3(&skew)
+	angular.MulWith(-1.0)
+
+	result := body.getInverseMassMatrix()
+	result.Add(&angular)
+	return result
+}
+
+// Apply removes the slip of the sphere at the contact within the threshold,
+// returning true if the impulse was applied.
+func (rc *RollingConstraint) Apply(sphere *CollisionSphere, contact *Contact, duration m.Real) bool {
+	body := sphere.Body
+	var other *RigidBody
+	switch body {
+	case contact.Bodies[0]:
+		other = contact.Bodies[1]
+	case contact.Bodies[1]:
+		other = contact.Bodies[0]
+	default:
+		return false
+	}
+	if !body.IsAwake || !body.HasFiniteMass() || duration <= 0.0 {
+		return false
+	}
+
+	// the sphere touches at the point under its center along the normal,
+	// found from the body since resolving the contacts moves it without
+	// updating its transform
+	normal := contact.ContactNormal
+	offset := sphere.Offset.GetAxis(3)
+	center := body.Orientation.Rotate(&offset)
+	center.Add(&body.Position)
+	toCenter := center
+	toCenter.Sub(&contact.ContactPoint)
+	if toCenter.Dot(&normal) < 0.0 {
+		normal.MulWith(-1.0)
+	}
+	point := center
+	point.AddScaled(&normal, -sphere.Radius)
+
+	// the slip is the relative velocity of the touching points in the contact plane
+	slip := body.GetVelocityAtPoint(&point)
+	com := body.GetCenterOfMassWorld()
+	relative := point
+	relative.Sub(&com)
+	k := contactImpulseMatrix(body, &relative)
+	if other != nil {
+		otherVelocity := other.GetVelocityAtPoint(&point)
+		slip.Sub(&otherVelocity)
+		if other.HasFiniteMass() {
+			otherCom := other.GetCenterOfMassWorld()
+			otherRelative := point
+			otherRelative.Sub(&otherCom)
+			otherK := contactImpulseMatrix(other, &otherRelative)
+			k.Add(&otherK)
+		}
+	}
+	slip.AddScaled(&normal, -slip.Dot(&normal))
+	if slip.SquareMagnitude() < m.Epsilon {
+		return false
+	}
+
+	// find the impulse that cancels the slip and keep it in the contact plane
+	kInverse := k.Invert()
+	impulse := kInverse.MulVector3(&slip)
+	impulse.MulWith(-1.0)
+	impulse.AddScaled(&normal, -impulse.Dot(&normal))
+	if rc.MaxTangentialForce > 0.0 && impulse.Magnitude() > rc.MaxTangentialForce*duration {
+		return false
+	}
+
+	body.ApplyImpulseAtPoint(&impulse, &point)
+	if other != nil && other.HasFiniteMass() {
+		impulse.MulWith(-1.0)
+		other.ApplyImpulseAtPoint(&impulse, &point)
+	}
+	return true
+}
+
+// applyRollingConstraints applies the rolling constraints of the spheres in
+// the world to the contacts they generated, leaving the contacts of other
+// colliders on the same body and those of joints alone.
+func (w *World) applyRollingConstraints(duration m.Real) {
+	for _, contact := range w.contacts {
+		for _, c := range contact.colliders {
+			sphere, ok := c.(*CollisionSphere)
+			if ok && sphere.Rolling != nil && sphere.Body != nil {
+				sphere.Rolling.Apply(sphere, contact, duration)
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// Rope is a chain of small spheres linked by distance joints, for ropes,
+// chains, cables and tails. It can stiffen against bending, collide with
+// itself and have either end attached to a body or to a point in the world.
+type Rope struct {
+	// Segments holds the spheres that make up the rope, from start to end.
+	Segments []*CollisionSphere
+
+	// Links holds the distance joints between each pair of neighbouring
+	// segments. They go slack when the segments come together; set the
+	// MinLength of each to its MaxLength for a rigid chain.
+	Links []*DistanceJoint
+
+	// Bends holds the springs between every other segment that stiffen the
+	// rope against bending. It's empty unless SetBendingStiffness is called.
+	Bends []*SpringJoint
+
+	// Ends holds the joints that attach the start and end of the rope, or nil
+	// for an end that hangs free.
+	Ends [2]*DistanceJoint
+
+	// SelfCollision lets the segments collide with each other, except for
+	// neighbouring segments that always touch.
+	// Defaults to false.
+	SelfCollision bool
+
+	// world is the world the rope was added to.
+	world *World
+
+	// indexes maps the body of each segment to its place in the rope.
+	indexes map[*RigidBody]int
+}
+
+// NewRope creates a new Rope from the start to the end point, in World Space,
+// out of the number of segments given, each a sphere of the radius and mass
+// given. Neither end is attached.
+func NewRope(start m.Vector3, end m.Vector3, segments int, radius m.Real, mass m.Real) *Rope {
+	r := new(Rope)
+	if segments < 2 {
+		segments = 2
+	}
+
+	step := end
+	step.Sub(&start)
+	step.MulWith(1.0 / m.Real(segments-1))
+	spacing := step.Magnitude()
+
+	r.indexes = make(map[*RigidBody]int, segments)
+	for i := 0; i < segments; i++ {
+		body := NewRigidBody()
+		body.SetMass(mass)
+		var inertia m.Matrix3
+		inertia.SetSphereInertiaTensor(radius, mass)
+		body.SetInertiaTensor(&inertia)
+		body.Position = start
+		body.Position.AddScaled(&step, m.Real(i))
+		s := NewCollisionSphere(body, radius)
+		body.CalculateDerivedData()
+		s.CalculateDerivedData()
+		r.Segments = append(r.Segments, s)
+		r.indexes[body] = i
+
+		if i > 0 {
+			link := NewCable(r.Segments[i-1].Body, m.Vector3{}, body, m.Vector3{}, spacing, 0.0)
+			r.Links = append(r.Links, link)
+		}
+	}
+	return r
+}
+
+// NewChain creates a new Rope like NewRope, except that its links are rigid
+// rods that keep the segments the same distance apart.
+func NewChain(start m.Vector3, end m.Vector3, segments int, radius m.Real, mass m.Real) *Rope {
+	r := NewRope(start, end, segments, radius, mass)
+	for _, link := range r.Links {
+		link.MinLength = link.MaxLength
+	}
+	return r
+}
+
+// SetBendingStiffness stiffens the rope against bending with springs of the
+// frequency, in hertz, and damping ratio given between every other segment.
+// A frequency of zero or less leaves the rope free to bend. The rope must not
+// be in a world when this is called.
+func (r *Rope) SetBendingStiffness(frequency m.Real, dampingRatio m.Real) {
+	r.Bends = nil
+	if frequency <= 0.0 {
+		return
+	}
+	for i := 0; i+2 < len(r.Segments); i++ {
+		rest := r.Links[i].MaxLength + r.Links[i+1].MaxLength
+		bend := NewSpringJoint(r.Segments[i].Body, m.Vector3{}, r.Segments[i+2].Body, m.Vector3{}, rest, frequency, dampingRatio)
+		r.Bends = append(r.Bends, bend)
+	}
+}
+
+// AttachStart attaches the first segment to the point on the body given. The
+// point is in the Body Space of the body, or in World Space if the body is nil.
+// The rope must not be in a world when this is called.
+func (r *Rope) AttachStart(body *RigidBody, point m.Vector3) {
+	r.Ends[0] = NewRod(r.Segments[0].Body, m.Vector3{}, body, point, 0.0)
+}
+
+// AttachEnd attaches the last segment to the point on the body given. The
+// point is in the Body Space of the body, or in World Space if the body is nil.
+// The rope must not be in a world when this is called.
+func (r *Rope) AttachEnd(body *RigidBody, point m.Vector3) {
+	r.Ends[1] = NewRod(r.Segments[len(r.Segments)-1].Body, m.Vector3{}, body, point, 0.0)
+}
+
+// Detach frees the start (0) or end (1) of the rope. The rope must not be in
+// a world when this is called.
+func (r *Rope) Detach(end int) {
+	r.Ends[end] = nil
+}
+
+// constraints returns all of the joints that hold the rope together.
+func (r *Rope) constraints() []Constraint {
+	var constraints []Constraint
+	for _, link := range r.Links {
+		constraints = append(constraints, link)
+	}
+	for _, bend := range r.Bends {
+		constraints = append(constraints, bend)
+	}
+	for _, end := range r.Ends {
+		if end != nil {
+			constraints = append(constraints, end)
+		}
+	}
+	return constraints
+}
+
+// AddRope adds the segments and joints of the rope to the world.
+func (w *World) AddRope(r *Rope) {
+	if r.world != nil {
+		return
+	}
+	r.world = w
+	for _, s := range r.Segments {
+		w.AddCollider(s)
+	}
+	for _, c := range r.constraints() {
+		w.AddConstraint(c)
+	}
+	w.setCollisionFilter(r, r.bodies())
+}
+
+// RemoveRope removes the segments and joints of the rope from the world.
+func (w *World) RemoveRope(r *Rope) {
+	if r.world != w {
+		return
+	}
+	r.world = nil
+	w.clearCollisionFilter(r, r.bodies())
+	for _, c := range r.constraints() {
+		w.RemoveConstraint(c)
+	}
+	for _, s := range r.Segments {
+		w.RemoveCollider(s)
+		w.RemoveBody(s.Body)
+	}
+}
+
+// bodies returns the bodies of the segments.
+func (r *Rope) bodies() []*RigidBody {
+	bodies := make([]*RigidBody, 0, len(r.Segments))
+	for _, s := range r.Segments {
+		bodies = append(bodies, s.Body)
+	}
+	return bodies
+}
+
+// ignoresCollision returns true for segments of the rope that shouldn't
+// collide: all of them without SelfCollision, and neighbours with it.
+func (r *Rope) ignoresCollision(one *RigidBody, two *RigidBody) bool {
+	apart := r.indexes[one] - r.indexes[two]
+	if apart < 0 {
+		apart = -apart
+	}
+	return !r.SelfCollision || apart <= 1
+}
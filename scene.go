@@ -0,0 +1,191 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// Scene is a description of a World in a plain JSON format, so that the same
+// setup can be loaded by cubez and by the tools used to make reference data
+// with other engines.
+type Scene struct {
+	// Timestep is the duration of each step.
+	Timestep m.Real `json:"timestep"`
+
+	// Steps is the number of steps to run the scene for.
+	Steps int `json:"steps"`
+
+	// Gravity is the acceleration due to gravity for all of the bodies.
+	Gravity m.Vector3 `json:"gravity"`
+
+	// Bodies holds the objects in the scene.
+	Bodies []SceneBody `json:"bodies"`
+}
+
+// SceneBody describes one object of a Scene.
+type SceneBody struct {
+	// Name identifies the body in the trajectories.
+	Name string `json:"name"`
+
+	// Shape is one of "sphere", "cube" or "plane".
+	Shape string `json:"shape"`
+
+	// Radius is the radius of a sphere.
+	Radius m.Real `json:"radius,omitempty"`
+
+	// HalfSize holds the half-sizes of a cube.
+	HalfSize m.Vector3 `json:"halfSize,omitempty"`
+
+	// Normal is the normal of a plane.
+	Normal m.Vector3 `json:"normal,omitempty"`
+
+	// Offset is the distance of a plane from the origin along its normal.
+	Offset m.Real `json:"offset,omitempty"`
+
+	// Mass is the mass of the body; zero makes it immovable.
+	Mass m.Real `json:"mass,omitempty"`
+
+	// Position is the starting position of the body.
+	Position m.Vector3 `json:"position,omitempty"`
+
+	// Orientation is the starting orientation of the body as (w, x, y, z).
+	// Defaults to the identity when it's all zeros.
+	Orientation m.Quat `json:"orientation,omitempty"`
+
+	// Euler is the starting orientation of the body as rotations about the
+	// X, Y and Z axes, in degrees, for formats that don't use quaternions.
+	// It can't be given along with Orientation.
+	Euler m.Vector3 `json:"euler,omitempty"`
+
+	// EulerOrder is the order the rotations of Euler are made in: "XYZ",
+	// "ZYX" or "YXZ". Defaults to "XYZ".
+	EulerOrder string `json:"eulerOrder,omitempty"`
+
+	// Velocity is the starting linear velocity of the body.
+	Velocity m.Vector3 `json:"velocity,omitempty"`
+
+	// AngularVelocity is the starting angular velocity of the body.
+	AngularVelocity m.Vector3 `json:"angularVelocity,omitempty"`
+
+	// Friction is the friction coefficient of the surface.
+	Friction m.Real `json:"friction"`
+
+	// Restitution is the restitution coefficient of the surface.
+	Restitution m.Real `json:"restitution"`
+}
+
+// SceneTrack pairs a moving body of a built Scene with its name.
+type SceneTrack struct {
+	Name string
+	Body *RigidBody
+}
+
+// LoadScene reads a Scene from JSON.
+func LoadScene(r io.Reader) (*Scene, error) {
+	scene := new(Scene)
+	if err := json.NewDecoder(r).Decode(scene); err != nil {
+		return nil, fmt.Errorf("failed to decode the scene: %v", err)
+	}
+	if scene.Timestep <= 0.0 {
+		return nil, fmt.Errorf("the scene timestep must be positive; got %v", scene.Timestep)
+	}
+	return scene, nil
+}
+
+// Build creates a World holding the objects of the scene and returns it along
+// with the bodies that can move, in the order they're in the scene.
+func (scene *Scene) Build() (*World, []SceneTrack, error) {
+	w := NewWorld()
+	var tracks []SceneTrack
+	for i := range scene.Bodies {
+		desc := &scene.Bodies[i]
+		material := NewMaterial(desc.Friction, desc.Restitution)
+
+		if desc.Shape == "plane" {
+			normal := desc.Normal
+			normal.Normalize()
+			plane := NewCollisionPlane(normal, desc.Offset)
+			plane.Material = material
+			w.AddCollider(plane)
+			continue
+		}
+
+		body := NewRigidBody()
+		var inertia m.Matrix3
+		var c Collider
+		switch desc.Shape {
+		case "sphere":
+			sphere := NewCollisionSphere(body, desc.Radius)
+			sphere.Material = material
+			inertia.SetSphereInertiaTensor(desc.Radius, desc.Mass)
+			c = sphere
+		case "cube":
+			cube := NewCollisionCube(body, desc.HalfSize)
+			cube.Material = material
+			inertia.SetBlockInertiaTensor(&desc.HalfSize, desc.Mass)
+			c = cube
+		default:
+			return nil, nil, fmt.Errorf("body %q has an unknown shape %q", desc.Name, desc.Shape)
+		}
+
+		if desc.Mass > 0.0 {
+			body.SetMass(desc.Mass)
+			body.SetInertiaTensor(&inertia)
+		} else {
+			body.SetInfiniteMass()
+		}
+		body.Position = desc.Position
+		orientation, err := desc.orientation()
+		if err != nil {
+			return nil, nil, err
+		}
+		body.Orientation = orientation
+		body.Velocity = desc.Velocity
+		body.Rotation = desc.AngularVelocity
+		body.Acceleration = scene.Gravity
+		body.LinearDamping = 1.0
+		body.AngularDamping = 1.0
+		body.CalculateDerivedData()
+		c.CalculateDerivedData()
+
+		w.AddCollider(c)
+		if desc.Mass > 0.0 {
+			tracks = append(tracks, SceneTrack{desc.Name, body})
+		}
+	}
+	return w, tracks, nil
+}
+
+// orientation returns the starting orientation of the body from either its
+// quaternion or its Euler angles.
+func (desc *SceneBody) orientation() (m.Quat, error) {
+	if desc.Euler == (m.Vector3{}) {
+		orientation := desc.Orientation
+		if orientation == (m.Quat{}) {
+			orientation.SetIdentity()
+		}
+		return orientation, nil
+	}
+	if desc.Orientation != (m.Quat{}) {
+		return m.Quat{}, fmt.Errorf("body %q has both an orientation and Euler angles", desc.Name)
+	}
+
+	order := m.EulerXYZ
+	if desc.EulerOrder != "" {
+		var err error
+		if order, err = m.ParseEulerOrder(desc.EulerOrder); err != nil {
+			return m.Quat{}, fmt.Errorf("body %q: %v", desc.Name, err)
+		}
+	}
+	radians := desc.Euler
+	for i := range radians {
+		radians[i] = m.DegToRad(radians[i])
+	}
+	return m.QuatFromEuler(&radians, order), nil
+}
@@ -0,0 +1,240 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// Volume is a region of space that sensors use to detect bodies. Volumes can
+// be combined with AllOf, AnyOf and Not to describe complex zones.
+type Volume interface {
+	Contains(point *m.Vector3) bool
+}
+
+// SphereVolume is a spherical region of space.
+type SphereVolume struct {
+	// Center is the World Space center of the sphere.
+	Center m.Vector3
+
+	// Radius is the radius of the sphere.
+	Radius m.Real
+}
+
+// Contains returns true if the point is inside the sphere.
+func (v *SphereVolume) Contains(point *m.Vector3) bool {
+	d := *point
+	d.Sub(&v.Center)
+	return d.SquareMagnitude() <= v.Radius*v.Radius
+}
+
+// BoxVolume is an axis aligned box shaped region of space.
+type BoxVolume struct {
+	// Center is the World Space center of the box.
+	Center m.Vector3
+
+	// HalfSize holds the half-sizes of the box along each axis.
+	HalfSize m.Vector3
+}
+
+// Contains returns true if the point is inside the box.
+func (v *BoxVolume) Contains(point *m.Vector3) bool {
+	for i := 0; i < 3; i++ {
+		if m.RealAbs(point[i]-v.Center[i]) > v.HalfSize[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ColliderVolume is the region of space taken up by a sphere or cube collider,
+// so that it follows the collider as its body moves.
+type ColliderVolume struct {
+	// Collider is the collider that defines the volume.
+	Collider Collider
+}
+
+// Contains returns true if the point is inside the collider.
+func (v *ColliderVolume) Contains(point *m.Vector3) bool {
+	transform := v.Collider.GetTransform()
+	local := transform.TransformInverse(point)
+	switch shape := v.Collider.(type) {
+	case *CollisionSphere:
+		return local.SquareMagnitude() <= shape.Radius*shape.Radius
+	case *CollisionCapsule:
+		bottom := m.Vector3{0.0, -shape.HalfHeight, 0.0}
+		top := m.Vector3{0.0, shape.HalfHeight, 0.0}
+		closest := closestPointOnSegment(&local, &bottom, &top)
+		local.Sub(&closest)
+		return local.SquareMagnitude() <= shape.Radius*shape.Radius
+	case *CollisionCube:
+		for i := 0; i < 3; i++ {
+			if m.RealAbs(local[i]) > shape.HalfSize[i] {
+				return false
+			}
+		}
+		return true
+	case *CollisionPlane:
+		return shape.Distance(point) <= 0.0
+	}
+	return false
+}
+
+// andVolume contains the points that are inside all of its volumes.
+type andVolume []Volume
+
+// Contains returns true if the point is inside all of the volumes.
+func (v andVolume) Contains(point *m.Vector3) bool {
+	for _, volume := range v {
+		if !volume.Contains(point) {
+			return false
+		}
+	}
+	return true
+}
+
+// orVolume contains the points that are inside any of its volumes.
+type orVolume []Volume
+
+// Contains returns true if the point is inside any of the volumes.
+func (v orVolume) Contains(point *m.Vector3) bool {
+	for _, volume := range v {
+		if volume.Contains(point) {
+			return true
+		}
+	}
+	return false
+}
+
+// notVolume contains the points that are outside of its volume.
+type notVolume struct {
+	volume Volume
+}
+
+// Contains returns true if the point is outside of the volume.
+func (v notVolume) Contains(point *m.Vector3) bool {
+	return !v.volume.Contains(point)
+}
+
+// AllOf returns a Volume that contains the points inside all of the volumes given.
+func AllOf(volumes ...Volume) Volume {
+	return andVolume(volumes)
+}
+
+// AnyOf returns a Volume that contains the points inside any of the volumes given.
+func AnyOf(volumes ...Volume) Volume {
+	return orVolume(volumes)
+}
+
+// Not returns a Volume that contains the points outside of the volume given.
+func Not(volume Volume) Volume {
+	return notVolume{volume}
+}
+
+// SensorCallback is called when a body enters or exits a sensor.
+type SensorCallback func(sensor *Sensor, body *RigidBody)
+
+// Sensor detects bodies whose center of mass is inside its Volume and reports
+// when they enter and exit it. A sensor doesn't generate any contacts.
+type Sensor struct {
+	// Volume is the region of space the sensor covers.
+	Volume Volume
+
+	// OnEnter, if not nil, is called when a body enters the volume.
+	OnEnter SensorCallback
+
+	// OnExit, if not nil, is called when a body exits the volume.
+	OnExit SensorCallback
+
+	// inside holds the bodies inside the volume, in the order they entered.
+	inside []*RigidBody
+}
+
+// NewSensor creates a new Sensor for the volume given.
+func NewSensor(volume Volume) *Sensor {
+	s := new(Sensor)
+	s.Volume = volume
+	return s
+}
+
+// GetBodiesInside returns the bodies that were inside the volume at the last
+// update. The slice is reused by the next update, so use Overlapping to keep
+// hold of them.
+func (s *Sensor) GetBodiesInside() []*RigidBody {
+	return s.inside
+}
+
+// Overlapping returns a copy of the bodies that were inside the volume at the
+// last update, in the order they entered, which a World does at the end of
+// each step. It's the whole set each time rather than just the changes, for
+// logic like counting what's standing on a pressure plate.
+func (s *Sensor) Overlapping() []*RigidBody {
+	bodies := make([]*RigidBody, len(s.inside))
+	copy(bodies, s.inside)
+	return bodies
+}
+
+// IsOverlapping returns true if the body was inside the volume at the last update.
+func (s *Sensor) IsOverlapping(body *RigidBody) bool {
+	for _, inside := range s.inside {
+		if inside == body {
+			return true
+		}
+	}
+	return false
+}
+
+// Update checks the bodies against the volume, calling OnExit for each body
+// that has left it and then OnEnter for each body that has entered it.
+func (s *Sensor) Update(bodies []*RigidBody) {
+	s.update(bodies, nil)
+}
+
+// update checks the bodies against the volume, sending the enter and exit
+// events to the dispatcher before calling the sensor's own callbacks.
+func (s *Sensor) update(bodies []*RigidBody, events *EventDispatcher) {
+	isInside := make(map[*RigidBody]bool, len(bodies))
+	for _, body := range bodies {
+		com := body.GetCenterOfMassWorld()
+		if s.Volume.Contains(&com) {
+			isInside[body] = true
+		}
+	}
+
+	// report the exits in the order the bodies entered
+	wasInside := make(map[*RigidBody]bool, len(s.inside))
+	stillInside := s.inside[:0]
+	for _, body := range s.inside {
+		wasInside[body] = true
+		if isInside[body] {
+			stillInside = append(stillInside, body)
+		} else {
+			s.notify(events, EventSensorExit, body, s.OnExit)
+		}
+	}
+	s.inside = stillInside
+
+	// then the entries in the order of the bodies given
+	for _, body := range bodies {
+		if isInside[body] && !wasInside[body] {
+			s.inside = append(s.inside, body)
+			s.notify(events, EventSensorEnter, body, s.OnEnter)
+		}
+	}
+}
+
+// notify dispatches the sensor event and then calls the callback if the event
+// wasn't cancelled.
+func (s *Sensor) notify(events *EventDispatcher, event EventType, body *RigidBody, callback SensorCallback) {
+	if events.HasSubscribers(event) {
+		e := Event{Type: event, Sensor: s}
+		e.Bodies[0] = body
+		if !events.Dispatch(&e) {
+			return
+		}
+	}
+	if callback != nil {
+		callback(s, body)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// OrientationServo turns a body towards a target orientation with a damped
+// spring that can't apply more than a maximum torque, for self-righting
+// vehicles, turrets and animated props. It's applied before the contacts are
+// resolved, so it never fights them: a servoed body still pushes other
+// objects around and is stopped by anything it can't move.
+type OrientationServo struct {
+	// Bodies holds the body that is turned and the base it's turned relative
+	// to. The base can be nil, in which case the target is in World Space.
+	Bodies [2]*RigidBody
+
+	// Target is the orientation the first body is turned towards, relative
+	// to the orientation of the base. If the base is nil, it's in World Space.
+	Target m.Quat
+
+	// Frequency is the natural frequency of the servo in hertz; the higher it
+	// is the quicker the body turns to the target.
+	Frequency m.Real
+
+	// DampingRatio is how strongly the turning is damped, where 1.0 is
+	// critically damped and reaches the target without overshooting.
+	DampingRatio m.Real
+
+	// MaxTorque is the largest torque the servo can apply. A value of zero
+	// or less means the torque isn't limited.
+	// Defaults to 0.0.
+	MaxTorque m.Real
+
+	// torque holds the torque the servo applied in the last step.
+	torque m.Vector3
+}
+
+// NewOrientationServo creates a new OrientationServo that turns the body
+// towards the target orientation in World Space.
+func NewOrientationServo(body *RigidBody, target m.Quat, frequency m.Real, dampingRatio m.Real, maxTorque m.Real) *OrientationServo {
+	s := new(OrientationServo)
+	s.Bodies[0] = body
+	s.Target = target
+	s.Target.Normalize()
+	s.Frequency = frequency
+	s.DampingRatio = dampingRatio
+	s.MaxTorque = maxTorque
+	return s
+}
+
+// GetBodies returns the body and the base of the servo.
+func (s *OrientationServo) GetBodies() [2]*RigidBody {
+	return s.Bodies
+}
+
+// saveState copies the last torque of the servo.
+func (s *OrientationServo) saveState(state *constraintState) {
+	state.motor = s.torque
+}
+
+// loadState sets the last torque of the servo.
+func (s *OrientationServo) loadState(state *constraintState) {
+	s.torque = state.motor
+}
+
+// GetTorque returns the torque the servo applied in the last step.
+func (s *OrientationServo) GetTorque() m.Vector3 {
+	return s.torque
+}
+
+// Error returns the rotation, as an axis in World Space scaled by the angle
+// in radians, that would turn the body to the target.
+func (s *OrientationServo) Error() m.Vector3 {
+	target := s.Target
+	if s.Bodies[1] != nil {
+		target = s.Bodies[1].Orientation
+		target.Mul(&s.Target)
+	}
+	orientation := s.Bodies[0].Orientation.Conjugated()
+	target.Mul(&orientation)
+	return rotationVector(&target)
+}
+
+// AddContacts doesn't generate any contacts since the servo is applied as an
+// impulse instead; it's here to satisfy the Constraint interface.
+func (s *OrientationServo) AddContacts(existingContacts []*Contact) (bool, []*Contact) {
+	return false, existingContacts
+}
+
+// applyImpulses applies the angular impulse that a damped spring pulling the
+// body towards the target would give over the step, limited by what the
+// MaxTorque can do in the step.
+func (s *OrientationServo) applyImpulses(duration m.Real, feedback *JointFeedback) {
+	s.torque.Clear()
+	if duration <= 0.0 {
+		return
+	}
+
+	// spring each axis of a basis lined up with the rotation to the target,
+	// so that the turning is damped in every direction
+	rotation := s.Error()
+	var basis [3]m.Vector3
+	if angle := rotation.Magnitude(); angle > m.Epsilon {
+		basis[0] = rotation
+		basis[0].MulWith(1.0 / angle)
+		basis[1] = perpendicularTo(&basis[0])
+		basis[2] = basis[0].Cross(&basis[1])
+	} else {
+		basis = [3]m.Vector3{{1.0, 0.0, 0.0}, {0.0, 1.0, 0.0}, {0.0, 0.0, 1.0}}
+	}
+
+	var impulses [3]m.Real
+	var total m.Real
+	for i, axis := range basis {
+		inverseInertia, speed := angularResponse(&s.Bodies, &axis)
+		impulses[i] = springImpulse(inverseInertia, rotation.Dot(&axis), speed, s.Frequency, s.DampingRatio, duration)
+		total += impulses[i] * impulses[i]
+	}
+
+	// scale the impulse back to what the servo can do
+	total = m.RealSqrt(total)
+	if maxImpulse := s.MaxTorque * duration; s.MaxTorque > 0.0 && total > maxImpulse {
+		for i := range impulses {
+			impulses[i] *= maxImpulse / total
+		}
+	}
+
+	for i := range basis {
+		applyAngularImpulses(&s.Bodies, &basis[i], impulses[i], feedback)
+		s.torque.AddScaled(&basis[i], -impulses[i]/duration)
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// SliderJoint links two bodies so that they can only slide along a shared
+// axis without turning, like a piston, a drawer or an elevator. It's enforced
+// with contacts that keep three points of the second body, the anchor and
+// two points beside it, on lines through the first body parallel to the axis,
+// and with a contact along the axis when the offset goes past a limit.
+type SliderJoint struct {
+	ConstraintSoftness
+
+	// Bodies holds the two bodies that are joined. The second body can be nil,
+	// in which case the first slides along a fixed line in the world.
+	Bodies [2]*RigidBody
+
+	// Anchors holds the point on the axis in the Body Space of each body. If
+	// the second body is nil, its anchor is in World Space.
+	Anchors [2]m.Vector3
+
+	// Axes holds the direction of the slider axis in the Body Space of each body.
+	Axes [2]m.Vector3
+
+	// References holds a direction perpendicular to the axis in the Body Space
+	// of each body, used to stop the bodies turning relative to each other.
+	References [2]m.Vector3
+
+	// Length is the distance from the anchor of the points beside it that
+	// stop the bodies turning.
+	// Defaults to 1.0.
+	Length m.Real
+
+	// Error is the distance the points are allowed to drift off of their
+	// lines before the joint generates a contact.
+	Error m.Real
+
+	// Limited enables the LowerLimit and UpperLimit.
+	// Defaults to false.
+	Limited bool
+
+	// LowerLimit is the smallest offset of the second anchor from the first
+	// along the axis.
+	LowerLimit m.Real
+
+	// UpperLimit is the largest offset of the second anchor from the first
+	// along the axis.
+	UpperLimit m.Real
+
+	// LimitRestitution is how much the slider bounces back off of its limits.
+	// Defaults to 0.0.
+	LimitRestitution m.Real
+
+	// MotorEnabled turns on the motor, which drives the slider towards the
+	// MotorTarget and holds it there with no more than the MaxMotorForce.
+	// Defaults to false.
+	MotorEnabled bool
+
+	// MotorTarget is the offset the motor drives the slider to.
+	MotorTarget m.Real
+
+	// MaxMotorForce is the largest force the motor can apply.
+	MaxMotorForce m.Real
+
+	// MaxMotorSpeed limits how fast the motor moves the slider. A value of
+	// zero or less means the motor tries to reach the target in one step.
+	// Defaults to 0.0.
+	MaxMotorSpeed m.Real
+
+	// Stall, if not nil, is updated with the force of the motor each step.
+	// Defaults to nil.
+	Stall *StallDetector
+
+	// motorForce holds the force the motor applied in the last step.
+	motorForce m.Real
+}
+
+// NewSliderJoint creates a new SliderJoint between the bodies that slides
+// along the axis through the anchor, both given in World Space. The offset of
+// the slider starts at zero. The derived data of the bodies must be up to date.
+func NewSliderJoint(one *RigidBody, two *RigidBody, anchor m.Vector3, axis m.Vector3, err m.Real) *SliderJoint {
+	s := new(SliderJoint)
+	s.Bodies[0] = one
+	s.Bodies[1] = two
+	s.Length = 1.0
+	s.Error = err
+
+	axis.Normalize()
+	reference := perpendicularTo(&axis)
+	for i, body := range s.Bodies {
+		s.Anchors[i] = worldPointToBody(body, &anchor)
+		s.Axes[i] = worldDirectionToBody(body, &axis)
+		s.References[i] = worldDirectionToBody(body, &reference)
+	}
+	return s
+}
+
+// SetLimits limits the offset of the slider to the range given.
+func (s *SliderJoint) SetLimits(lower m.Real, upper m.Real) {
+	s.Limited = true
+	s.LowerLimit = lower
+	s.UpperLimit = upper
+}
+
+// SetMotor turns on the motor to drive the slider to the target offset with
+// no more than the force given.
+func (s *SliderJoint) SetMotor(target m.Real, maxForce m.Real) {
+	s.MotorEnabled = true
+	s.MotorTarget = target
+	s.MaxMotorForce = maxForce
+}
+
+// GetMotorForce returns the force the motor applied in the last step.
+func (s *SliderJoint) GetMotorForce() m.Real {
+	return s.motorForce
+}
+
+// GetBodies returns the two bodies of the slider.
+func (s *SliderJoint) GetBodies() [2]*RigidBody {
+	return s.Bodies
+}
+
+// saveState copies the state of the motor.
+func (s *SliderJoint) saveState(state *constraintState) {
+	state.motor[0] = s.motorForce
+	state.saveStall(s.Stall)
+}
+
+// loadState sets the state of the motor.
+func (s *SliderJoint) loadState(state *constraintState) {
+	s.motorForce = state.motor[0]
+	state.loadStall(s.Stall)
+}
+
+// State returns the offset of the slider and how fast it's moving.
+func (s *SliderJoint) State() JointState {
+	return SliderState(s.Bodies[0], s.Bodies[1], &s.Axes[0], &s.Anchors[0], &s.Anchors[1])
+}
+
+// AddContacts generates the contacts for any part of the slider that has been
+// violated and appends them to the existing contacts.
+func (s *SliderJoint) AddContacts(existingContacts []*Contact) (bool, []*Contact) {
+	count := len(existingContacts)
+	length := s.Length
+	if length <= 0.0 {
+		length = 1.0
+	}
+
+	axis := bodyDirectionToWorld(s.Bodies[0], &s.Axes[0])
+	axis.Normalize()
+
+	// the anchor and two points beside it, in the Body Space of each body
+	var offsets [2][3]m.Vector3
+	for i := range s.Bodies {
+		side := s.References[i]
+		side.Normalize()
+		other := s.Axes[i].Cross(&side)
+		other.Normalize()
+		offsets[i][1] = side
+		offsets[i][1].MulWith(length)
+		offsets[i][2] = other
+		offsets[i][2].MulWith(length)
+	}
+
+	// keep each point of the second body on its line through the first
+	for p := 0; p < 3; p++ {
+		localOne := s.Anchors[0]
+		localOne.Add(&offsets[0][p])
+		localTwo := s.Anchors[1]
+		localTwo.Add(&offsets[1][p])
+		pointOne := bodyPointToWorld(s.Bodies[0], &localOne)
+		pointTwo := bodyPointToWorld(s.Bodies[1], &localTwo)
+
+		// the closest point on the line to the point of the second body
+		along := pointTwo
+		along.Sub(&pointOne)
+		pointOne.AddScaled(&axis, along.Dot(&axis))
+
+		var added bool
+		added, existingContacts = addPointJointContact(existingContacts, &s.Bodies, &pointOne, &pointTwo, s.Error)
+		if added {
+			// the bodies have to be free to slide along the axis
+			existingContacts[len(existingContacts)-1].Friction = 0.0
+		}
+	}
+
+	if s.Limited {
+		existingContacts = s.addLimitContact(existingContacts, &axis)
+	}
+	return len(existingContacts) > count, existingContacts
+}
+
+// addLimitContact generates a contact along the axis that pushes the slider
+// back within its limits.
+func (s *SliderJoint) addLimitContact(existingContacts []*Contact, axis *m.Vector3) []*Contact {
+	offset := s.State().Position
+	var violation m.Real
+	switch {
+	case offset > s.UpperLimit:
+		violation = offset - s.UpperLimit
+	case offset < s.LowerLimit:
+		violation = offset - s.LowerLimit
+	default:
+		return existingContacts
+	}
+
+	// the first body is pushed along the normal and the second against it,
+	// which brings the second back towards the first
+	normal := *axis
+	if violation < 0.0 {
+		normal.MulWith(-1.0)
+		violation = -violation
+	}
+
+	c := NewContact()
+	c.Bodies = s.Bodies
+	c.ContactNormal = normal
+	c.ContactPoint = bodyPointToWorld(s.Bodies[1], &s.Anchors[1])
+	c.Penetration = violation
+	c.Friction = 0.0
+	c.Restitution = s.LimitRestitution
+	return append(existingContacts, c)
+}
+
+// applyImpulses applies the impulse along the axis that moves the slider towards
+// the MotorTarget, limited by what the MaxMotorForce can do in the step.
+func (s *SliderJoint) applyImpulses(duration m.Real, feedback *JointFeedback) {
+	s.motorForce = 0.0
+	if !s.MotorEnabled || duration <= 0.0 {
+		return
+	}
+	defer func() {
+		if s.Stall != nil {
+			s.Stall.Update(s.motorForce, s.MaxMotorForce)
+		}
+	}()
+
+	axis := bodyDirectionToWorld(s.Bodies[0], &s.Axes[0])
+	axis.Normalize()
+
+	// the change in the speed of the slider from a unit impulse along the axis
+	var response m.Real
+	for _, body := range s.Bodies {
+		if body != nil {
+			response += body.getInverseMassAlong(&axis)
+		}
+	}
+	if response <= 0.0 {
+		return
+	}
+
+	// the speed that arrives at the target by the end of the step
+	state := s.State()
+	speed := (s.MotorTarget - state.Position) / duration
+	if s.MaxMotorSpeed > 0.0 {
+		if speed > s.MaxMotorSpeed {
+			speed = s.MaxMotorSpeed
+		} else if speed < -s.MaxMotorSpeed {
+			speed = -s.MaxMotorSpeed
+		}
+	}
+
+	impulse := (speed - state.Velocity) / response
+	maxImpulse := s.MaxMotorForce * duration
+	if impulse > maxImpulse {
+		impulse = maxImpulse
+	} else if impulse < -maxImpulse {
+		impulse = -maxImpulse
+	}
+	if impulse == 0.0 {
+		return
+	}
+	s.motorForce = impulse / duration
+
+	// the second body is pushed forwards along the axis and the first backwards
+	linear := axis
+	linear.MulWith(impulse)
+	feedback.addImpulse(1, &linear, nil)
+	if s.Bodies[1] != nil {
+		s.Bodies[1].ApplyLinearImpulse(&linear)
+	}
+	linear.MulWith(-1.0)
+	feedback.addImpulse(0, &linear, nil)
+	s.Bodies[0].ApplyLinearImpulse(&linear)
+}
@@ -0,0 +1,277 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// BodySnapshot holds the dynamic state of a RigidBody at a moment in time so
+// that it can be sent over the network or restored later.
+type BodySnapshot struct {
+	// Position is the position of the body in World Space.
+	Position m.Vector3
+
+	// Orientation is the orientation of the body.
+	Orientation m.Quat
+
+	// Velocity is the linear velocity of the body in World Space.
+	Velocity m.Vector3
+
+	// Rotation is the angular velocity of the body in World Space.
+	Rotation m.Vector3
+
+	// IsAwake is whether the body was awake.
+	IsAwake bool
+}
+
+// TakeSnapshot returns the current dynamic state of the RigidBody.
+func (body *RigidBody) TakeSnapshot() BodySnapshot {
+	return BodySnapshot{
+		Position:    body.Position,
+		Orientation: body.Orientation,
+		Velocity:    body.Velocity,
+		Rotation:    body.Rotation,
+		IsAwake:     body.IsAwake,
+	}
+}
+
+// RestoreSnapshot sets the dynamic state of the RigidBody from the snapshot.
+func (body *RigidBody) RestoreSnapshot(s *BodySnapshot) {
+	body.SetTransform(&s.Position, &s.Orientation)
+	body.Velocity = s.Velocity
+	body.Rotation = s.Rotation
+	body.SetAwake(s.IsAwake)
+}
+
+// Snapshot returns the state of every body in the world, in the same order
+// as Bodies.
+func (w *World) Snapshot() []BodySnapshot {
+	snapshots := make([]BodySnapshot, len(w.Bodies))
+	for i, body := range w.Bodies {
+		snapshots[i] = body.TakeSnapshot()
+	}
+	return snapshots
+}
+
+// Restore sets the state of every body in the world from snapshots taken with
+// Snapshot. The world must have the same bodies, in the same order.
+func (w *World) Restore(snapshots []BodySnapshot) error {
+	if len(snapshots) != len(w.Bodies) {
+		return fmt.Errorf("snapshot has %d bodies but the world has %d", len(snapshots), len(w.Bodies))
+	}
+	for i, body := range w.Bodies {
+		body.RestoreSnapshot(&snapshots[i])
+	}
+	for _, c := range w.Colliders {
+		c.CalculateDerivedData()
+	}
+	w.contacts = nil
+	return nil
+}
+
+// Checksum returns a hash of the exact state of every body in the world, in
+// the order of Bodies. Two worlds stepped in Deterministic mode with the same
+// inputs have the same checksum, so peers in a lockstep game can compare it
+// to find out when they've drifted apart.
+func (w *World) Checksum() uint64 {
+	hash := fnv.New64a()
+	var buffer [8]byte
+	write := func(values ...m.Real) {
+		for _, v := range values {
+			bits := math.Float64bits(float64(v))
+			for i := range buffer {
+				buffer[i] = byte(bits >> (8 * uint(i)))
+			}
+			hash.Write(buffer[:])
+		}
+	}
+	for _, body := range w.Bodies {
+		write(body.Position[:]...)
+		write(body.Orientation[:]...)
+		write(body.Velocity[:]...)
+		write(body.Rotation[:]...)
+		awake := m.Real(0.0)
+		if body.IsAwake {
+			awake = 1.0
+		}
+		write(awake)
+	}
+	return hash.Sum64()
+}
+
+// WorldState holds what a World needs to carry on stepping from a moment in
+// time: the full state of its bodies, the contacts of the last step and the
+// state of its joints and path followers. It's meant for rollback netcode
+// that goes back to a saved state and simulates again every frame, so a
+// WorldState is reused: once its buffers have grown to fit the world, saving
+// into it and loading from it don't allocate. Particle systems, soft bodies,
+// articulations and sensors aren't included.
+type WorldState struct {
+	// bodies holds copies of the bodies in the order of Bodies.
+	bodies []RigidBody
+
+	// contacts holds copies of the contacts of the last step.
+	contacts []Contact
+
+	// restored and restoredPointers hold the contacts handed back to the
+	// world by LoadState, so that the world can't change the saved ones.
+	restored         []Contact
+	restoredPointers []*Contact
+
+	// feedback holds the feedback of the joints and then the constraints,
+	// in the order of Joints and Constraints.
+	feedback []feedbackState
+
+	// constraints holds the state of each constraint in the order of
+	// Constraints.
+	constraints []constraintState
+
+	// followers holds the progress of each path follower in the order of
+	// PathFollowers.
+	followers []pathFollowerState
+
+	// waterTimes holds the time of each water volume in the order of
+	// WaterVolumes.
+	waterTimes []m.Real
+}
+
+// feedbackState holds the feedback of a joint, if it had any.
+type feedbackState struct {
+	feedback JointFeedback
+	ok       bool
+}
+
+// pathFollowerState holds the progress of a PathFollower along its path.
+type pathFollowerState struct {
+	elapsed  m.Real
+	reverse  bool
+	finished bool
+}
+
+// SaveState copies the state of the world into the WorldState given,
+// reusing its buffers.
+func (w *World) SaveState(s *WorldState) {
+	s.bodies = s.bodies[:0]
+	for _, body := range w.Bodies {
+		s.bodies = append(s.bodies, *body)
+	}
+	s.contacts = s.contacts[:0]
+	for _, c := range w.contacts {
+		s.contacts = append(s.contacts, *c)
+	}
+
+	s.feedback = s.feedback[:0]
+	for _, j := range w.Joints {
+		s.feedback = append(s.feedback, w.saveFeedback(j))
+	}
+	for _, c := range w.Constraints {
+		s.feedback = append(s.feedback, w.saveFeedback(c))
+	}
+	s.constraints = s.constraints[:0]
+	for _, c := range w.Constraints {
+		var state constraintState
+		if stateful, ok := c.(statefulConstraint); ok {
+			stateful.saveState(&state)
+		}
+		s.constraints = append(s.constraints, state)
+	}
+
+	s.followers = s.followers[:0]
+	for _, pf := range w.PathFollowers {
+		s.followers = append(s.followers, pathFollowerState{pf.elapsed, pf.reverse, pf.finished})
+	}
+	s.waterTimes = s.waterTimes[:0]
+	for _, wv := range w.WaterVolumes {
+		s.waterTimes = append(s.waterTimes, wv.Time)
+	}
+}
+
+// LoadState sets the world back to the state saved with SaveState. The world
+// must have the same bodies, joints, constraints, path followers and water
+// volumes, in the same order.
+func (w *World) LoadState(s *WorldState) error {
+	switch {
+	case len(s.bodies) != len(w.Bodies):
+		return fmt.Errorf("state has %d bodies but the world has %d", len(s.bodies), len(w.Bodies))
+	case len(s.feedback) != len(w.Joints)+len(w.Constraints):
+		return fmt.Errorf("state has %d joints and constraints but the world has %d", len(s.feedback), len(w.Joints)+len(w.Constraints))
+	case len(s.followers) != len(w.PathFollowers):
+		return fmt.Errorf("state has %d path followers but the world has %d", len(s.followers), len(w.PathFollowers))
+	case len(s.waterTimes) != len(w.WaterVolumes):
+		return fmt.Errorf("state has %d water volumes but the world has %d", len(s.waterTimes), len(w.WaterVolumes))
+	}
+
+	// the listeners set since the state was saved are kept, and told about
+	// the pose the bodies were set back to at the next step
+	for i, body := range w.Bodies {
+		listener := body.OnTransform
+		*body = s.bodies[i]
+		body.OnTransform = listener
+		body.notified = false
+	}
+	for _, c := range w.Colliders {
+		c.CalculateDerivedData()
+	}
+
+	// the world gets its own copies of the contacts, so that they can be
+	// loaded again
+	s.restored = append(s.restored[:0], s.contacts...)
+	s.restoredPointers = s.restoredPointers[:0]
+	for i := range s.restored {
+		s.restoredPointers = append(s.restoredPointers, &s.restored[i])
+	}
+	w.contacts = s.restoredPointers
+
+	for i, j := range w.Joints {
+		w.loadFeedback(j, &s.feedback[i])
+	}
+	for i, c := range w.Constraints {
+		w.loadFeedback(c, &s.feedback[len(w.Joints)+i])
+		if stateful, ok := c.(statefulConstraint); ok {
+			stateful.loadState(&s.constraints[i])
+		}
+	}
+
+	for i, pf := range w.PathFollowers {
+		pf.elapsed, pf.reverse, pf.finished = s.followers[i].elapsed, s.followers[i].reverse, s.followers[i].finished
+	}
+	for i, wv := range w.WaterVolumes {
+		wv.Time = s.waterTimes[i]
+	}
+	return nil
+}
+
+// saveFeedback returns the feedback of the joint from the last step.
+func (w *World) saveFeedback(joint interface{}) feedbackState {
+	f, ok := w.feedback[joint]
+	if !ok {
+		return feedbackState{}
+	}
+	state := feedbackState{feedback: *f, ok: true}
+	state.feedback.contacts = nil
+	return state
+}
+
+// loadFeedback sets the feedback of the joint, reusing the feedback already
+// in the world if there is one.
+func (w *World) loadFeedback(joint interface{}, state *feedbackState) {
+	if !state.ok {
+		delete(w.feedback, joint)
+		return
+	}
+	if f, ok := w.feedback[joint]; ok {
+		*f = state.feedback
+		return
+	}
+	if w.feedback == nil {
+		w.feedback = make(map[interface{}]*JointFeedback)
+	}
+	f := state.feedback
+	w.feedback[joint] = &f
+}
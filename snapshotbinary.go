@@ -0,0 +1,158 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// snapshotMagic starts every binary snapshot.
+	snapshotMagic = "CZSN"
+
+	// snapshotVersion is the version of the binary snapshot layout.
+	snapshotVersion = 1
+
+	// snapshotCompressed is the flag set when the bodies are compressed.
+	snapshotCompressed = 1 << 0
+
+	// snapshotHeaderSize is the size of the header in bytes: the magic, the
+	// version, the flags and the number of bodies.
+	snapshotHeaderSize = 4 + 2 + 2 + 4
+
+	// snapshotBodySize is the size of each body in bytes: thirteen numbers
+	// for the position, orientation, velocity and rotation and one byte for
+	// whether it's awake.
+	snapshotBodySize = 13*8 + 1
+)
+
+// EncodeSnapshots packs the snapshots into the compact binary snapshot
+// format, which keeps every value exactly. The layout is little-endian with a
+// fixed field order: the magic "CZSN", a uint16 version, uint16 flags and a
+// uint32 count of bodies, followed by the position, orientation, velocity and
+// rotation of each body as float64 values and a byte that's 1 if it's awake.
+// If compress is true, the bodies are compressed with DEFLATE, which helps
+// most with worlds that have many bodies at rest.
+func EncodeSnapshots(snapshots []BodySnapshot, compress bool) ([]byte, error) {
+	header := make([]byte, snapshotHeaderSize, snapshotHeaderSize+len(snapshots)*snapshotBodySize)
+	copy(header, snapshotMagic)
+	binary.LittleEndian.PutUint16(header[4:], snapshotVersion)
+	var flags uint16
+	if compress {
+		flags |= snapshotCompressed
+	}
+	binary.LittleEndian.PutUint16(header[6:], flags)
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(snapshots)))
+
+	if !compress {
+		return appendSnapshotBodies(header, snapshots), nil
+	}
+	buffer := bytes.NewBuffer(header)
+	writer, err := flate.NewWriter(buffer, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(appendSnapshotBodies(nil, snapshots)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// appendSnapshotBodies appends the bodies of the snapshots to the data in the
+// binary snapshot layout.
+func appendSnapshotBodies(data []byte, snapshots []BodySnapshot) []byte {
+	var buffer [8]byte
+	write := func(values ...m.Real) {
+		for _, v := range values {
+			binary.LittleEndian.PutUint64(buffer[:], math.Float64bits(float64(v)))
+			data = append(data, buffer[:]...)
+		}
+	}
+	for i := range snapshots {
+		s := &snapshots[i]
+		write(s.Position[:]...)
+		write(s.Orientation[:]...)
+		write(s.Velocity[:]...)
+		write(s.Rotation[:]...)
+		if s.IsAwake {
+			data = append(data, 1)
+		} else {
+			data = append(data, 0)
+		}
+	}
+	return data
+}
+
+// DecodeSnapshots unpacks snapshots written by EncodeSnapshots.
+func DecodeSnapshots(data []byte) ([]BodySnapshot, error) {
+	if len(data) < snapshotHeaderSize || string(data[:4]) != snapshotMagic {
+		return nil, fmt.Errorf("the data isn't a binary snapshot")
+	}
+	if version := binary.LittleEndian.Uint16(data[4:]); version != snapshotVersion {
+		return nil, fmt.Errorf("the snapshot has version %d; expected %d", version, snapshotVersion)
+	}
+	flags := binary.LittleEndian.Uint16(data[6:])
+	count := int(binary.LittleEndian.Uint32(data[8:]))
+	bodies := data[snapshotHeaderSize:]
+
+	if flags&snapshotCompressed != 0 {
+		// the buffer grows with the data rather than trusting the count
+		reader := flate.NewReader(bytes.NewReader(bodies))
+		defer reader.Close()
+		var buffer bytes.Buffer
+		if _, err := buffer.ReadFrom(io.LimitReader(reader, int64(count*snapshotBodySize)+1)); err != nil {
+			return nil, fmt.Errorf("failed to decompress the snapshot: %v", err)
+		}
+		bodies = buffer.Bytes()
+	}
+	if len(bodies) != count*snapshotBodySize {
+		return nil, fmt.Errorf("the snapshot has %d bytes of bodies; expected %d for %d bodies", len(bodies), count*snapshotBodySize, count)
+	}
+
+	snapshots := make([]BodySnapshot, count)
+	offset := 0
+	read := func(values []m.Real) {
+		for i := range values {
+			values[i] = m.Real(math.Float64frombits(binary.LittleEndian.Uint64(bodies[offset:])))
+			offset += 8
+		}
+	}
+	for i := range snapshots {
+		s := &snapshots[i]
+		read(s.Position[:])
+		read(s.Orientation[:])
+		read(s.Velocity[:])
+		read(s.Rotation[:])
+		s.IsAwake = bodies[offset] != 0
+		offset++
+	}
+	return snapshots, nil
+}
+
+// EncodeSnapshot returns the state of every body in the world in the compact
+// binary snapshot format, compressed if compress is true.
+func (w *World) EncodeSnapshot(compress bool) ([]byte, error) {
+	return EncodeSnapshots(w.Snapshot(), compress)
+}
+
+// DecodeSnapshot sets the state of every body in the world from a snapshot
+// made with EncodeSnapshot. The world must have the same bodies, in the same
+// order.
+func (w *World) DecodeSnapshot(data []byte) error {
+	snapshots, err := DecodeSnapshots(data)
+	if err != nil {
+		return err
+	}
+	return w.Restore(snapshots)
+}
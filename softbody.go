@@ -0,0 +1,424 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"fmt"
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	defaultSoftBodySubsteps = 10
+)
+
+// SoftBody is a deformable object, such as a squishy prop or a cushion,
+// simulated with extended position based dynamics (XPBD). It's a mesh of
+// tetrahedra with a particle at each corner: the edges of the tetrahedra try
+// to keep their lengths and each tetrahedron tries to keep its volume, with
+// compliances that set how easily they give. The body is stepped in several
+// small substeps with one pass over the constraints in each, which keeps it
+// stable without needing many iterations. When it's added to a World its
+// particles collide with the colliders there and push back on their bodies.
+type SoftBody struct {
+	// Particles holds the corners of the tetrahedra. Pinning a particle with
+	// SetInfiniteMass holds that part of the body in place.
+	Particles []*Particle
+
+	// Tetrahedra holds the indexes in Particles of the corners of each
+	// tetrahedron.
+	Tetrahedra [][4]int
+
+	// EdgeCompliance is the inverse of the stiffness of the edges; zero makes
+	// them as stiff as the solver can manage and larger values let them
+	// stretch more.
+	// Defaults to 0.0.
+	EdgeCompliance m.Real
+
+	// VolumeCompliance is the inverse of the stiffness of the volume of each
+	// tetrahedron; zero keeps the volume as well as the solver can and larger
+	// values let it be squashed more.
+	// Defaults to 0.0.
+	VolumeCompliance m.Real
+
+	// Substeps is the number of substeps each step is split into. More
+	// substeps make the body stiffer and more accurate at a higher cost.
+	// Defaults to 10.
+	Substeps int
+
+	// edges holds the indexes of the particles at the ends of each edge.
+	edges [][2]int
+
+	// restLengths holds the length of each edge at rest.
+	restLengths []m.Real
+
+	// restVolumes holds the volume of each tetrahedron at rest.
+	restVolumes []m.Real
+
+	// previous holds the positions of the particles at the start of the
+	// substep in progress.
+	previous []m.Vector3
+
+	// accelerations holds the acceleration of each particle for the step in
+	// progress.
+	accelerations []m.Vector3
+}
+
+// NewSoftBody creates a new SoftBody from the positions of the corners, in
+// World Space, and the tetrahedra between them. The mass is spread over the
+// particles by the volume of the tetrahedra they're in, and they collide as
+// spheres of the radius given. An error is returned if a tetrahedron refers
+// to a missing corner or is flat, or if a corner isn't in any tetrahedron.
+func NewSoftBody(positions []m.Vector3, tetrahedra [][4]int, mass m.Real, radius m.Real) (*SoftBody, error) {
+	sb := new(SoftBody)
+	sb.Tetrahedra = tetrahedra
+	sb.Substeps = defaultSoftBodySubsteps
+
+	masses := make([]m.Real, len(positions))
+	var totalVolume m.Real
+	type edgeKey struct{ one, two int }
+	seen := make(map[edgeKey]bool)
+	for i, tet := range tetrahedra {
+		for _, index := range tet {
+			if index < 0 || index >= len(positions) {
+				return nil, fmt.Errorf("tetrahedron %d refers to corner %d of %d", i, index, len(positions))
+			}
+		}
+		volume := signedTetrahedronVolume(&positions[tet[0]], &positions[tet[1]], &positions[tet[2]], &positions[tet[3]])
+		if m.RealAbs(volume) <= m.Epsilon {
+			return nil, fmt.Errorf("tetrahedron %d has no volume", i)
+		}
+		sb.restVolumes = append(sb.restVolumes, volume)
+		totalVolume += m.RealAbs(volume)
+		for _, index := range tet {
+			masses[index] += m.RealAbs(volume) * 0.25
+		}
+
+		// each of the six edges is only added once
+		for a := 0; a < 4; a++ {
+			for b := a + 1; b < 4; b++ {
+				key := edgeKey{tet[a], tet[b]}
+				if key.one > key.two {
+					key.one, key.two = key.two, key.one
+				}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				edge := positions[key.one]
+				edge.Sub(&positions[key.two])
+				sb.edges = append(sb.edges, [2]int{key.one, key.two})
+				sb.restLengths = append(sb.restLengths, edge.Magnitude())
+			}
+		}
+	}
+
+	for i, position := range positions {
+		if masses[i] <= 0.0 {
+			return nil, fmt.Errorf("corner %d isn't in any tetrahedron", i)
+		}
+		p := NewParticle(position)
+		p.SetMass(mass * masses[i] / totalVolume)
+		p.Radius = radius
+		sb.Particles = append(sb.Particles, p)
+	}
+	sb.previous = make([]m.Vector3, len(positions))
+	sb.accelerations = make([]m.Vector3, len(positions))
+	return sb, nil
+}
+
+// NewSoftBox creates a new SoftBody in the shape of a box, with the number of
+// divisions given along each side. Each cell of the grid is split into six
+// tetrahedra.
+func NewSoftBox(center m.Vector3, halfSize m.Vector3, divisions int, mass m.Real, radius m.Real) (*SoftBody, error) {
+	if divisions < 1 {
+		divisions = 1
+	}
+	corners := divisions + 1
+	index := func(x, y, z int) int {
+		return x + (y+z*corners)*corners
+	}
+
+	var positions []m.Vector3
+	for z := 0; z < corners; z++ {
+		for y := 0; y < corners; y++ {
+			for x := 0; x < corners; x++ {
+				position := center
+				position[0] += halfSize[0] * (2.0*m.Real(x)/m.Real(divisions) - 1.0)
+				position[1] += halfSize[1] * (2.0*m.Real(y)/m.Real(divisions) - 1.0)
+				position[2] += halfSize[2] * (2.0*m.Real(z)/m.Real(divisions) - 1.0)
+				positions = append(positions, position)
+			}
+		}
+	}
+
+	// every tetrahedron runs from the lowest corner of the cell to the
+	// highest one a step along each axis at a time, so neighbouring cells
+	// share their faces
+	orders := [6][3]int{{1, 2, 4}, {1, 4, 2}, {2, 1, 4}, {2, 4, 1}, {4, 1, 2}, {4, 2, 1}}
+	var tetrahedra [][4]int
+	for z := 0; z < divisions; z++ {
+		for y := 0; y < divisions; y++ {
+			for x := 0; x < divisions; x++ {
+				cell := func(bits int) int {
+					return index(x+bits&1, y+bits>>1&1, z+bits>>2&1)
+				}
+				for _, order := range orders {
+					tetrahedra = append(tetrahedra, [4]int{
+						cell(0), cell(order[0]), cell(order[0] | order[1]), cell(7),
+					})
+				}
+			}
+		}
+	}
+	return NewSoftBody(positions, tetrahedra, mass, radius)
+}
+
+// signedTetrahedronVolume returns the signed volume of a tetrahedron.
+func signedTetrahedronVolume(a, b, c, d *m.Vector3) m.Real {
+	ab, ac, ad := *b, *c, *d
+	ab.Sub(a)
+	ac.Sub(a)
+	ad.Sub(a)
+	cross := ab.Cross(&ac)
+	return cross.Dot(&ad) / 6.0
+}
+
+// GetCenter returns the average position of the particles.
+func (sb *SoftBody) GetCenter() m.Vector3 {
+	var center m.Vector3
+	for _, p := range sb.Particles {
+		center.Add(&p.Position)
+	}
+	if len(sb.Particles) > 0 {
+		center.MulWith(1.0 / m.Real(len(sb.Particles)))
+	}
+	return center
+}
+
+// GetVolume returns the current volume of the body.
+func (sb *SoftBody) GetVolume() m.Real {
+	var volume m.Real
+	for i, tet := range sb.Tetrahedra {
+		v := sb.volumeOf(tet)
+		if sb.restVolumes[i] < 0.0 {
+			v = -v
+		}
+		volume += v
+	}
+	return volume
+}
+
+// volumeOf returns the signed volume of a tetrahedron of the body.
+func (sb *SoftBody) volumeOf(tet [4]int) m.Real {
+	return signedTetrahedronVolume(&sb.Particles[tet[0]].Position, &sb.Particles[tet[1]].Position,
+		&sb.Particles[tet[2]].Position, &sb.Particles[tet[3]].Position)
+}
+
+// Step advances the body through time by the duration given without colliding
+// it with anything.
+func (sb *SoftBody) Step(duration m.Real) {
+	sb.step(duration, nil)
+}
+
+// step advances the body through time by the duration given, colliding its
+// particles with the colliders given.
+func (sb *SoftBody) step(duration m.Real, colliders []Collider) {
+	if duration <= 0.0 {
+		return
+	}
+	substeps := sb.Substeps
+	if substeps < 1 {
+		substeps = 1
+	}
+	h := duration / m.Real(substeps)
+
+	// the forces are held for the whole step
+	for i, p := range sb.Particles {
+		sb.accelerations[i] = p.Acceleration
+		sb.accelerations[i].AddScaled(&p.forceAccum, p.inverseMass)
+		p.ClearAccumulator()
+	}
+
+	for substep := 0; substep < substeps; substep++ {
+		for i, p := range sb.Particles {
+			sb.previous[i] = p.Position
+			if p.inverseMass <= 0.0 {
+				continue
+			}
+			p.Velocity.AddScaled(&sb.accelerations[i], h)
+			p.Position.AddScaled(&p.Velocity, h)
+		}
+
+		sb.solveEdges(h)
+		sb.solveVolumes(h)
+		for i, p := range sb.Particles {
+			if p.inverseMass > 0.0 {
+				sb.collide(p, &sb.previous[i], colliders, h, duration)
+			}
+		}
+
+		// the velocities come from how far the particles moved
+		for i, p := range sb.Particles {
+			if p.inverseMass <= 0.0 {
+				continue
+			}
+			p.Velocity = p.Position
+			p.Velocity.Sub(&sb.previous[i])
+			p.Velocity.MulWith(1.0 / h)
+		}
+	}
+
+	for _, p := range sb.Particles {
+		p.Velocity.MulWith(m.Real(math.Pow(float64(p.Damping), float64(duration))))
+	}
+}
+
+// solveEdges pulls the ends of each edge towards its rest length.
+func (sb *SoftBody) solveEdges(h m.Real) {
+	alpha := sb.EdgeCompliance / (h * h)
+	for i, edge := range sb.edges {
+		one, two := sb.Particles[edge[0]], sb.Particles[edge[1]]
+		w := one.inverseMass + two.inverseMass
+		if w <= 0.0 {
+			continue
+		}
+		gradient := one.Position
+		gradient.Sub(&two.Position)
+		length := gradient.Magnitude()
+		if length <= m.Epsilon {
+			continue
+		}
+		gradient.MulWith(1.0 / length)
+		lambda := -(length - sb.restLengths[i]) / (w + alpha)
+		one.Position.AddScaled(&gradient, lambda*one.inverseMass)
+		two.Position.AddScaled(&gradient, -lambda*two.inverseMass)
+	}
+}
+
+// tetrahedronFaces holds, for each corner of a tetrahedron, the other corners
+// in the order whose cross product gives the gradient of the volume.
+var tetrahedronFaces = [4][3]int{{1, 3, 2}, {0, 2, 3}, {0, 3, 1}, {0, 1, 2}}
+
+// solveVolumes pushes the corners of each tetrahedron towards its rest volume.
+func (sb *SoftBody) solveVolumes(h m.Real) {
+	alpha := sb.VolumeCompliance / (h * h)
+	var gradients [4]m.Vector3
+	for i, tet := range sb.Tetrahedra {
+		var w m.Real
+		for j, face := range tetrahedronFaces {
+			a := sb.Particles[tet[face[0]]].Position
+			b := sb.Particles[tet[face[1]]].Position
+			c := sb.Particles[tet[face[2]]].Position
+			b.Sub(&a)
+			c.Sub(&a)
+			gradients[j] = b.Cross(&c)
+			gradients[j].MulWith(1.0 / 6.0)
+			w += sb.Particles[tet[j]].inverseMass * gradients[j].SquareMagnitude()
+		}
+		if w <= 0.0 {
+			continue
+		}
+		lambda := -(sb.volumeOf(tet) - sb.restVolumes[i]) / (w + alpha)
+		for j, index := range tet {
+			p := sb.Particles[index]
+			p.Position.AddScaled(&gradients[j], lambda*p.inverseMass)
+		}
+	}
+}
+
+// collide pushes the particle out of the colliders, holding back its sliding
+// with friction. A body that can move takes its share of the push by the
+// inverse masses, which moves it and changes its velocity to match, so the
+// soft body and the rigid bodies push on each other.
+func (sb *SoftBody) collide(p *Particle, previous *m.Vector3, colliders []Collider, h m.Real, duration m.Real) {
+	for _, c := range colliders {
+		hit, contacts := particleColliderContact(p, c, nil, nil)
+		if !hit {
+			continue
+		}
+		normal, depth := contacts[0].ContactNormal, contacts[0].Penetration
+		body := c.GetBody()
+		point := p.Position
+		point.AddScaled(&normal, -p.Radius)
+
+		bodies := [2]*RigidBody{nil, body}
+		points := [2]m.Vector3{point, point}
+		inverseMass, _ := pointResponse(&bodies, &points, &normal)
+		push := depth / (p.inverseMass + inverseMass)
+		p.Position.AddScaled(&normal, push*p.inverseMass)
+		if inverseMass > 0.0 {
+			impulse := normal
+			impulse.MulWith(-push)
+			pushBody(body, &impulse, &point, duration)
+			c.CalculateDerivedData()
+		}
+
+		// friction holds back how far the particle slid over the surface in
+		// the substep, by up to the friction times how far it was pushed out
+		if friction := c.GetMaterial().Friction; friction > 0.0 {
+			slide := p.Position
+			slide.Sub(previous)
+			if body != nil {
+				surface := body.GetVelocityAtPoint(&p.Position)
+				slide.AddScaled(&surface, -h)
+			}
+			slide.AddScaled(&normal, -slide.Dot(&normal))
+			if distance := slide.Magnitude(); distance > m.Epsilon {
+				held := friction * depth
+				if held > distance {
+					held = distance
+				}
+				p.Position.AddScaled(&slide, -held/distance)
+			}
+		}
+	}
+}
+
+// pushBody moves the body as a positional impulse at the point would, and
+// changes its velocities by what it would take to make that move over the
+// step, since the body isn't moved again until the next step.
+func pushBody(body *RigidBody, impulse *m.Vector3, point *m.Vector3, duration m.Real) {
+	velocity, rotation := body.Velocity, body.Rotation
+	perStep := *impulse
+	perStep.MulWith(1.0 / duration)
+	body.ApplyImpulseAtPoint(&perStep, point)
+
+	velocity.Sub(&body.Velocity)
+	rotation.Sub(&body.Rotation)
+	body.Position.AddScaled(&velocity, -duration)
+	body.Orientation.AddScaledVector(&rotation, -duration)
+	body.Orientation.Normalize()
+	body.CalculateDerivedData()
+}
+
+// AddSoftBody adds the soft body to the world so that it's stepped along
+// with the rigid bodies and collides with the colliders.
+func (w *World) AddSoftBody(sb *SoftBody) {
+	for _, existing := range w.SoftBodies {
+		if existing == sb {
+			return
+		}
+	}
+	w.SoftBodies = append(w.SoftBodies, sb)
+}
+
+// RemoveSoftBody removes the soft body from the world.
+func (w *World) RemoveSoftBody(sb *SoftBody) {
+	for i, existing := range w.SoftBodies {
+		if existing == sb {
+			w.SoftBodies = append(w.SoftBodies[:i], w.SoftBodies[i+1:]...)
+			return
+		}
+	}
+}
+
+// stepSoftBodies steps the soft bodies in the world, colliding them with the
+// colliders of the world.
+func (w *World) stepSoftBodies(duration m.Real) {
+	for _, sb := range w.SoftBodies {
+		sb.step(duration, w.Colliders)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	// solverIterationsPerContact is the number of iterations each pass of the
+	// contact resolver gets for each contact, and where the adaptive
+	// resolver starts.
+	solverIterationsPerContact = 8
+
+	// minSolverIterationsPerContact is the fewest iterations per contact the
+	// adaptive resolver comes down to.
+	minSolverIterationsPerContact = 2
+
+	// defaultSolverTolerance is the default penetration and closing velocity
+	// under which the adaptive resolver treats a contact as resolved.
+	defaultSolverTolerance = 0.01
+
+	// defaultMaxSolverIterations is the default cap on the iterations of each
+	// pass of the adaptive resolver.
+	defaultMaxSolverIterations = 4096
+
+	// maxSolverIterationsPerContact is the most iterations per contact the
+	// adaptive resolver goes up to, even when MaxSolverIterations leaves the
+	// total uncapped, so that a scene that never converges can't keep
+	// doubling them.
+	maxSolverIterationsPerContact = 1024
+)
+
+// resolveStepContacts resolves the contacts of the step, adapting the number
+// of iterations the resolver is allowed when AdaptiveSolver is set.
+func (w *World) resolveStepContacts(duration m.Real) {
+	if !w.AdaptiveSolver {
+		w.stats.SolverIterations = resolveContacts(len(w.contacts)*solverIterationsPerContact, w.contacts, duration,
+			positionEpsilon, velocityEpsilon, w.SolverTrace)
+		return
+	}
+
+	if w.solverIterations == 0 {
+		w.solverIterations = solverIterationsPerContact
+	}
+	maxIterations := len(w.contacts) * w.solverIterations
+	if w.MaxSolverIterations > 0 && maxIterations > w.MaxSolverIterations {
+		maxIterations = w.MaxSolverIterations
+	}
+	used := resolveContacts(maxIterations, w.contacts, duration, w.SolverTolerance, w.SolverTolerance, w.SolverTrace)
+	w.stats.SolverIterations = used
+
+	// a pass that ran out didn't converge, so the next step gets twice as
+	// many iterations; one that converged in under half its iterations lets
+	// the next step have one fewer per contact
+	switch {
+	case used[PassPosition] >= maxIterations || used[PassVelocity] >= maxIterations:
+		if w.MaxSolverIterations <= 0 || len(w.contacts)*w.solverIterations < w.MaxSolverIterations {
+			w.solverIterations *= 2
+			if w.solverIterations > maxSolverIterationsPerContact {
+				w.solverIterations = maxSolverIterationsPerContact
+			}
+		}
+	case used[PassPosition]*2 < maxIterations && used[PassVelocity]*2 < maxIterations:
+		if w.solverIterations > minSolverIterationsPerContact {
+			w.solverIterations--
+		}
+	}
+}
+
+// GetSolverIterations returns the number of iterations per contact that the
+// adaptive resolver will allow each pass in the next step.
+func (w *World) GetSolverIterations() int {
+	if w.solverIterations == 0 {
+		return solverIterationsPerContact
+	}
+	return w.solverIterations
+}
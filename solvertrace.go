@@ -0,0 +1,133 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"fmt"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// SolverPass identifies one of the passes the contact resolver makes over the
+// contacts.
+type SolverPass int
+
+const (
+	// PassPosition moves the bodies apart to remove the penetration.
+	PassPosition SolverPass = iota
+
+	// PassVelocity applies impulses to stop the bodies moving together.
+	PassVelocity
+)
+
+// String returns the name of the pass.
+func (p SolverPass) String() string {
+	switch p {
+	case PassPosition:
+		return "position"
+	case PassVelocity:
+		return "velocity"
+	}
+	return fmt.Sprintf("SolverPass(%d)", int(p))
+}
+
+// SolverIteration describes one iteration of a pass of the contact resolver,
+// in which the contact with the largest error is resolved on its own.
+type SolverIteration struct {
+	// Pass is the pass the iteration is in.
+	Pass SolverPass
+
+	// Iteration counts the iterations of the pass from zero.
+	Iteration int
+
+	// Contact is the contact that was resolved.
+	Contact *Contact
+
+	// Residual is the error of the contact before it was resolved, which is
+	// the largest error left in the pass: its penetration in the position
+	// pass or the change in closing velocity it needed in the velocity pass.
+	Residual m.Real
+
+	// Change is the size of what was done to resolve the contact: the
+	// distance the bodies were moved in the position pass or the magnitude
+	// of the impulse in the velocity pass.
+	Change m.Real
+}
+
+// SolverSummary describes a whole run of the contact resolver.
+type SolverSummary struct {
+	// Contacts is the number of contacts resolved.
+	Contacts int
+
+	// MaxIterations is the number of iterations each pass was allowed.
+	MaxIterations int
+
+	// Iterations holds the number of iterations used by each pass, indexed
+	// by SolverPass. A pass that used all of MaxIterations didn't converge.
+	Iterations [2]int
+
+	// Residuals holds the largest error left after each pass, indexed by
+	// SolverPass.
+	Residuals [2]m.Real
+
+	// WorstContact is the contact that was penetrating the deepest before
+	// the resolver ran, and WorstPenetration how deep it was.
+	WorstContact     *Contact
+	WorstPenetration m.Real
+
+	// TotalImpulse is the sum of the magnitudes of the impulses applied.
+	TotalImpulse m.Real
+}
+
+// SolverTracer receives a trace of the contact resolver, to analyze how well
+// it converges.
+type SolverTracer interface {
+	// TraceIteration is called after each iteration of each pass.
+	TraceIteration(it *SolverIteration)
+
+	// TraceSolve is called after both passes have finished.
+	TraceSolve(summary *SolverSummary)
+}
+
+// Logger is anything that can print formatted lines, such as a *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// SolverLogger is a SolverTracer that writes the trace as lines of text to a
+// Logger.
+type SolverLogger struct {
+	// Logger is where the lines are written.
+	Logger Logger
+
+	// Iterations writes a line for each iteration as well as for each run of
+	// the resolver.
+	// Defaults to false.
+	Iterations bool
+}
+
+// NewSolverLogger creates a new SolverLogger that writes to the logger.
+func NewSolverLogger(l Logger) *SolverLogger {
+	sl := new(SolverLogger)
+	sl.Logger = l
+	return sl
+}
+
+// TraceIteration writes the iteration if Iterations is set.
+func (sl *SolverLogger) TraceIteration(it *SolverIteration) {
+	if !sl.Iterations {
+		return
+	}
+	sl.Logger.Printf("solver %s iteration %d: residual %g change %g",
+		it.Pass, it.Iteration, it.Residual, it.Change)
+}
+
+// TraceSolve writes the summary of the run.
+func (sl *SolverLogger) TraceSolve(s *SolverSummary) {
+	sl.Logger.Printf("solver: %d contacts, worst penetration %g, position %d/%d iterations residual %g, velocity %d/%d iterations residual %g, total impulse %g",
+		s.Contacts, s.WorstPenetration,
+		s.Iterations[PassPosition], s.MaxIterations, s.Residuals[PassPosition],
+		s.Iterations[PassVelocity], s.MaxIterations, s.Residuals[PassVelocity],
+		s.TotalImpulse)
+}
@@ -0,0 +1,185 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// spatialVector is a six dimensional motion or force in Plücker coordinates
+// about the World Space origin, with the angular part first. A motion is the
+// angular velocity and the velocity of the point of the body at the origin; a
+// force is the torque about the origin and the linear force.
+type spatialVector [6]m.Real
+
+// spatialMatrix is a six by six matrix that maps spatial motions to forces,
+// such as the inertia of a body.
+type spatialMatrix [6][6]m.Real
+
+// newSpatialVector creates a spatial vector from its angular and linear parts.
+func newSpatialVector(angular *m.Vector3, linear *m.Vector3) spatialVector {
+	return spatialVector{angular[0], angular[1], angular[2], linear[0], linear[1], linear[2]}
+}
+
+// bodySpatialVelocity returns the velocity of the body as a spatial motion.
+func bodySpatialVelocity(body *RigidBody) spatialVector {
+	com := body.GetCenterOfMassWorld()
+	linear := com.Cross(&body.Rotation)
+	linear.Add(&body.Velocity)
+	return newSpatialVector(&body.Rotation, &linear)
+}
+
+// bodySpatialInertia returns the inertia of the body about the World Space
+// origin. The body must have finite mass.
+func bodySpatialInertia(body *RigidBody) spatialMatrix {
+	var inertia spatialMatrix
+	mass := body.GetMass()
+	c := body.GetCenterOfMassWorld()
+	tensor := body.inverseInertiaWorld().Invert()
+
+	// the tensor moved to the origin, Ic - m[c][c], where [c][c] = cc' - |c|²1
+	square := c.Dot(&c)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			inertia[i][j] = tensor[i*3+j] - mass*c[i]*c[j]
+		}
+		inertia[i][i] += mass * square
+		inertia[i+3][i+3] = mass
+	}
+
+	// the coupling between the angular and linear parts, m[c] and -m[c]
+	skew := [3][3]m.Real{
+		{0.0, -c[2], c[1]},
+		{c[2], 0.0, -c[0]},
+		{-c[1], c[0], 0.0},
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			inertia[i][j+3] = mass * skew[i][j]
+			inertia[i+3][j] = -mass * skew[i][j]
+		}
+	}
+	return inertia
+}
+
+// angular returns the angular part of the vector.
+func (v *spatialVector) angular() m.Vector3 {
+	return m.Vector3{v[0], v[1], v[2]}
+}
+
+// linear returns the linear part of the vector.
+func (v *spatialVector) linear() m.Vector3 {
+	return m.Vector3{v[3], v[4], v[5]}
+}
+
+// dot returns the dot product of the two vectors, which is the power of a
+// force acting on a motion.
+func (v *spatialVector) dot(v2 *spatialVector) m.Real {
+	var sum m.Real
+	for i := range v {
+		sum += v[i] * v2[i]
+	}
+	return sum
+}
+
+// addScaled adds the vector given, scaled by the amount given, to this one.
+func (v *spatialVector) addScaled(v2 *spatialVector, scale m.Real) {
+	for i := range v {
+		v[i] += v2[i] * scale
+	}
+}
+
+// crossMotion returns the cross product of this motion with another motion,
+// which is how quickly the other motion changes when it's carried along by
+// this one.
+func (v *spatialVector) crossMotion(motion *spatialVector) spatialVector {
+	angular, linear := v.angular(), v.linear()
+	otherAngular, otherLinear := motion.angular(), motion.linear()
+
+	resultAngular := angular.Cross(&otherAngular)
+	resultLinear := angular.Cross(&otherLinear)
+	coupling := linear.Cross(&otherAngular)
+	resultLinear.Add(&coupling)
+	return newSpatialVector(&resultAngular, &resultLinear)
+}
+
+// crossForce returns the cross product of this motion with a force, which is
+// how quickly the force changes when it's carried along by this motion.
+func (v *spatialVector) crossForce(force *spatialVector) spatialVector {
+	angular, linear := v.angular(), v.linear()
+	torque, push := force.angular(), force.linear()
+
+	resultAngular := angular.Cross(&torque)
+	coupling := linear.Cross(&push)
+	resultAngular.Add(&coupling)
+	resultLinear := angular.Cross(&push)
+	return newSpatialVector(&resultAngular, &resultLinear)
+}
+
+// mulVector returns the matrix multiplied by the vector.
+func (a *spatialMatrix) mulVector(v *spatialVector) spatialVector {
+	var result spatialVector
+	for i := range a {
+		for j := range v {
+			result[i] += a[i][j] * v[j]
+		}
+	}
+	return result
+}
+
+// add adds the matrix given to this one.
+func (a *spatialMatrix) add(a2 *spatialMatrix) {
+	for i := range a {
+		for j := range a[i] {
+			a[i][j] += a2[i][j]
+		}
+	}
+}
+
+// subOuter subtracts the outer product of the vector with itself, scaled by
+// the amount given, from the matrix.
+func (a *spatialMatrix) subOuter(v *spatialVector, scale m.Real) {
+	for i := range a {
+		for j := range a[i] {
+			a[i][j] -= v[i] * v[j] * scale
+		}
+	}
+}
+
+// solve returns x such that the matrix multiplied by x is b, using Gaussian
+// elimination with partial pivoting. It returns false if the matrix is singular.
+func (a *spatialMatrix) solve(b spatialVector) (spatialVector, bool) {
+	work := *a
+	for col := 0; col < 6; col++ {
+		pivot := col
+		for row := col + 1; row < 6; row++ {
+			if m.RealAbs(work[row][col]) > m.RealAbs(work[pivot][col]) {
+				pivot = row
+			}
+		}
+		if m.RealAbs(work[pivot][col]) <= m.Epsilon {
+			return spatialVector{}, false
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+		b[col], b[pivot] = b[pivot], b[col]
+
+		for row := col + 1; row < 6; row++ {
+			factor := work[row][col] / work[col][col]
+			for k := col; k < 6; k++ {
+				work[row][k] -= factor * work[col][k]
+			}
+			b[row] -= factor * b[col]
+		}
+	}
+
+	var x spatialVector
+	for row := 5; row >= 0; row-- {
+		sum := b[row]
+		for k := row + 1; k < 6; k++ {
+			sum -= work[row][k] * x[k]
+		}
+		x[row] = sum / work[row][row]
+	}
+	return x, true
+}
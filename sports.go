@@ -0,0 +1,92 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// MaterialBilliardBall is a hard, smooth ball that keeps most of its speed
+// when it hits another ball.
+var MaterialBilliardBall = Material{
+	Friction:     0.06,
+	Restitution:  0.93,
+	Absorption:   0.05,
+	Transmission: 0.0,
+}
+
+// MaterialBilliardCloth is the felt of a pool table, which lets balls roll
+// but quickly takes any sliding out of them.
+var MaterialBilliardCloth = Material{
+	Friction:     0.2,
+	Restitution:  0.5,
+	Absorption:   0.6,
+	Transmission: 0.0,
+}
+
+// MaterialGolfBall is a golf ball, whose grippy cover lets a club put spin on it.
+var MaterialGolfBall = Material{
+	Friction:     0.4,
+	Restitution:  0.78,
+	Absorption:   0.05,
+	Transmission: 0.0,
+}
+
+// MaterialTurf is grass, which soaks up bounces and slows balls down.
+var MaterialTurf = Material{
+	Friction:     0.5,
+	Restitution:  0.3,
+	Absorption:   0.8,
+	Transmission: 0.0,
+}
+
+// ApplyStrike hits the body, like a cue or a club would, with an impulse of
+// the given power along the direction at a World Space point on its surface.
+// Hitting away from the center of mass puts spin on the body on its own;
+// spin, if not nil, is an extra angular impulse in World Space for tuning
+// things like backspin or side spin. The direction does not need to be normalized.
+func (body *RigidBody) ApplyStrike(point *m.Vector3, direction *m.Vector3, power m.Real, spin *m.Vector3) {
+	impulse := *direction
+	impulse.Normalize()
+	impulse.MulWith(power)
+	body.ApplyImpulseAtPoint(&impulse, point)
+	if spin != nil {
+		body.ApplyAngularImpulse(spin)
+	}
+}
+
+// MagnusEffect is a ForceGenerator for the lift a spinning ball gets as it
+// moves through its medium, which makes it curve in flight.
+type MagnusEffect struct {
+	// Radius is the radius of the ball.
+	Radius m.Real
+
+	// LiftCoefficient scales the strength of the effect.
+	// Defaults to 0.25.
+	LiftCoefficient m.Real
+}
+
+// NewMagnusEffect creates a new MagnusEffect for a ball of the radius given.
+func NewMagnusEffect(radius m.Real) *MagnusEffect {
+	me := new(MagnusEffect)
+	me.Radius = radius
+	me.LiftCoefficient = 0.25
+	return me
+}
+
+// UpdateForce adds the lift force, which is along the cross product of the
+// spin and the velocity of the body.
+func (me *MagnusEffect) UpdateForce(body *RigidBody, duration m.Real) {
+	area := math.Pi * me.Radius * me.Radius
+	strength := 0.5 * body.GetMedium().Density * me.LiftCoefficient * area * me.Radius
+
+	force := body.Rotation.Cross(&body.Velocity)
+	if force.SquareMagnitude() < m.Epsilon {
+		return
+	}
+	force.MulWith(strength)
+	body.AddForce(&force)
+}
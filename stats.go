@@ -0,0 +1,63 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"time"
+)
+
+// StepStats counts the work the world did in a step and how long it took, so
+// that the cost of a scene can be watched as it runs.
+type StepStats struct {
+	// Duration is how long the step took.
+	Duration time.Duration
+
+	// Bodies is the number of bodies in the world and AwakeBodies how many
+	// of them were awake at the end of the step.
+	Bodies      int
+	AwakeBodies int
+
+	// Colliders is the number of colliders in the world.
+	Colliders int
+
+	// Pairs is the number of pairs of colliders checked for contacts, which
+	// is every pair when the world has no broadphase.
+	Pairs int
+
+	// Contacts is the number of contacts resolved, including the ones that
+	// hold the joints and constraints together.
+	Contacts int
+
+	// Constraints is the number of joints and constraints in the world.
+	Constraints int
+
+	// SolverIterations holds the number of iterations used by each pass of
+	// the contact resolver, indexed by SolverPass.
+	SolverIterations [2]int
+}
+
+// GetStats returns the stats of the last step. When TimeScale split the step
+// into substeps, Duration, Pairs, Contacts and SolverIterations are added up
+// across all of them.
+func (w *World) GetStats() StepStats {
+	return w.stats
+}
+
+// recordStats fills in the stats of the step that started at the time given
+// and checked the number of pairs given. The iterations of the resolver are
+// recorded as it runs.
+func (w *World) recordStats(start time.Time, pairs int) {
+	w.stats.Bodies = len(w.Bodies)
+	w.stats.AwakeBodies = 0
+	for _, body := range w.Bodies {
+		if body.IsAwake {
+			w.stats.AwakeBodies++
+		}
+	}
+	w.stats.Colliders = len(w.Colliders)
+	w.stats.Pairs = pairs
+	w.stats.Contacts = len(w.contacts)
+	w.stats.Constraints = len(w.Joints) + len(w.Constraints)
+	w.stats.Duration = time.Since(start)
+}
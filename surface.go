@@ -0,0 +1,92 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// FrictionPlane is a ForceGenerator that slows bodies sliding and spinning on
+// a flat playing surface, like the pucks of air hockey or the stones of
+// curling, without needing contacts against the surface. It's meant to be used
+// along with SetPlanarConstraint so that the friction can be tuned separately
+// from the full 3D contact friction.
+type FrictionPlane struct {
+	// Normal is the direction the surface faces.
+	Normal m.Vector3
+
+	// Friction is the sliding friction coefficient, which slows the body by
+	// a constant amount based on the gravity pushing it into the surface.
+	Friction m.Real
+
+	// Drag slows the body in proportion to its speed across the surface,
+	// as a fraction of that speed lost per second.
+	// Defaults to 0.0.
+	Drag m.Real
+
+	// AngularFriction slows the spin of the body about the normal by a
+	// constant amount, in radians per second per second.
+	// Defaults to 0.0.
+	AngularFriction m.Real
+
+	// AngularDrag slows the spin of the body about the normal in proportion
+	// to its speed, as a fraction of that speed lost per second.
+	// Defaults to 0.0.
+	AngularDrag m.Real
+}
+
+// NewFrictionPlane creates a new FrictionPlane for a surface facing along the
+// normal with the sliding friction given.
+func NewFrictionPlane(normal *m.Vector3, friction m.Real) *FrictionPlane {
+	fp := new(FrictionPlane)
+	fp.Normal = *normal
+	fp.Normal.Normalize()
+	fp.Friction = friction
+	return fp
+}
+
+// UpdateForce adds the friction force and torque for the body's motion across
+// the surface. The friction never does more than stop the body within the step.
+func (fp *FrictionPlane) UpdateForce(body *RigidBody, duration m.Real) {
+	if !body.HasFiniteMass() || duration <= 0.0 {
+		return
+	}
+	mass := body.GetMass()
+
+	// sliding across the surface
+	slide := body.Velocity
+	slide.AddScaled(&fp.Normal, -slide.Dot(&fp.Normal))
+	if speed := slide.Magnitude(); speed > m.Epsilon {
+		gravity := body.GetGravity()
+		pressing := -gravity.Dot(&fp.Normal)
+		if pressing < 0.0 {
+			pressing = 0.0
+		}
+		decel := fp.Friction*pressing + fp.Drag*speed
+		if decel*duration > speed {
+			decel = speed / duration
+		}
+		slide.MulWith(-decel * mass / speed)
+		body.AddForce(&slide)
+	}
+
+	// spinning about the normal
+	spin := body.Rotation.Dot(&fp.Normal)
+	if speed := m.RealAbs(spin); speed > m.Epsilon {
+		decel := fp.AngularFriction + fp.AngularDrag*speed
+		if decel*duration > speed {
+			decel = speed / duration
+		}
+
+		// the torque that gives the deceleration depends on the inertia about the normal
+		inertia := body.GetInverseInertiaTensorWorld()
+		response := inertia.MulVector3(&fp.Normal)
+		inverseInertia := response.Dot(&fp.Normal)
+		if inverseInertia > m.Epsilon {
+			torque := fp.Normal
+			torque.MulWith(-decel * spin / speed / inverseInertia)
+			body.AddTorque(&torque)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// PIDController turns an error into a correction from the error itself, its
+// sum over time and how fast it's changing. Each part is scaled by its gain.
+type PIDController struct {
+	// P is the gain on the error.
+	P m.Real
+
+	// I is the gain on the sum of the error over time, which removes any
+	// steady offset the other parts leave.
+	I m.Real
+
+	// D is the gain on the rate of change of the error, which damps the
+	// correction so it doesn't overshoot.
+	D m.Real
+
+	// MaxIntegral limits the length of the summed error so that it can't wind
+	// up while the output is saturated. A value of zero or less means there
+	// is no limit.
+	// Defaults to 0.0.
+	MaxIntegral m.Real
+
+	// integral holds the sum of the error over time.
+	integral m.Vector3
+}
+
+// NewPIDController creates a new PIDController with the gains given.
+func NewPIDController(p m.Real, i m.Real, d m.Real) *PIDController {
+	pid := new(PIDController)
+	pid.P = p
+	pid.I = i
+	pid.D = d
+	return pid
+}
+
+// Update adds the error to the sum over the duration and returns the
+// correction. The rate of change of the error is passed in so that callers
+// can use a measured velocity instead of differencing the error, which would
+// kick whenever the target jumps.
+func (pid *PIDController) Update(err *m.Vector3, rate *m.Vector3, duration m.Real) m.Vector3 {
+	pid.integral.AddScaled(err, duration)
+	if pid.MaxIntegral > 0.0 {
+		clampVector(&pid.integral, pid.MaxIntegral)
+	}
+
+	output := *err
+	output.MulWith(pid.P)
+	output.AddScaled(&pid.integral, pid.I)
+	output.AddScaled(rate, pid.D)
+	return output
+}
+
+// Reset clears the summed error.
+func (pid *PIDController) Reset() {
+	pid.integral.Clear()
+}
+
+// Thruster is a ForceGenerator that drives a body towards a target position
+// with a force from a PIDController, which makes hovercraft, drones and
+// elevators simple to put together. The forces are expected to be evaluated
+// once per step, so it's best used with the SemiImplicitEuler integrator.
+type Thruster struct {
+	// Target is the World Space position the body is driven towards.
+	Target m.Vector3
+
+	// Axes holds which of the World Space axes the thruster pushes along.
+	// Defaults to all three.
+	Axes [3]bool
+
+	// PID turns the distance to the target into the force.
+	PID PIDController
+
+	// MaxForce limits the force of the thruster. A value of zero or less
+	// means there is no limit.
+	// Defaults to 0.0.
+	MaxForce m.Real
+
+	// CancelGravity adds a force that holds the body up against its gravity
+	// so that the PID only has to correct for the distance to the target.
+	// Defaults to true.
+	CancelGravity bool
+}
+
+// NewThruster creates a new Thruster that drives a body towards the target
+// position with the PID gains given.
+func NewThruster(target *m.Vector3, p m.Real, i m.Real, d m.Real) *Thruster {
+	t := new(Thruster)
+	t.Target = *target
+	t.Axes = [3]bool{true, true, true}
+	t.PID = *NewPIDController(p, i, d)
+	t.CancelGravity = true
+	return t
+}
+
+// NewHoverThruster creates a new Thruster that only pushes up and down to keep
+// a body at the height given along the up axis of the convention.
+func NewHoverThruster(height m.Real, convention AxisConvention, p m.Real, i m.Real, d m.Real) *Thruster {
+	up := convention.Up()
+	target := up
+	target.MulWith(height)
+	t := NewThruster(&target, p, i, d)
+	for axis := 0; axis < 3; axis++ {
+		t.Axes[axis] = up[axis] != 0.0
+	}
+	return t
+}
+
+// UpdateForce adds the force of the thruster to the body.
+func (t *Thruster) UpdateForce(body *RigidBody, duration m.Real) {
+	if !body.HasFiniteMass() || duration <= 0.0 {
+		return
+	}
+
+	com := body.GetCenterOfMassWorld()
+	err := t.Target
+	err.Sub(&com)
+	rate := body.Velocity
+	rate.MulWith(-1.0)
+	for axis := 0; axis < 3; axis++ {
+		if !t.Axes[axis] {
+			err[axis] = 0.0
+			rate[axis] = 0.0
+		}
+	}
+
+	force := t.PID.Update(&err, &rate, duration)
+	if t.CancelGravity {
+		gravity := body.GetGravity()
+		for axis := 0; axis < 3; axis++ {
+			if t.Axes[axis] {
+				force[axis] -= gravity[axis] * body.GetMass()
+			}
+		}
+	}
+	if t.MaxForce > 0.0 {
+		clampVector(&force, t.MaxForce)
+	}
+	if force.SquareMagnitude() > 0.0 {
+		body.AddForce(&force)
+	}
+}
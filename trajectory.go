@@ -0,0 +1,178 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// trajectoryHeader is the first row of a trajectory CSV file.
+var trajectoryHeader = []string{"step", "time", "body", "px", "py", "pz", "qw", "qx", "qy", "qz"}
+
+// TrajectorySample is the pose of a body at one step of a scene.
+type TrajectorySample struct {
+	Step        int
+	Time        m.Real
+	Body        string
+	Position    m.Vector3
+	Orientation m.Quat
+}
+
+// RunScene builds and runs the scene, writing the pose of each moving body
+// after every step as CSV. The columns are step, time, body, px, py, pz, qw,
+// qx, qy and qz, so reference data exported from other engines in the same
+// layout can be compared with CompareTrajectories.
+func RunScene(scene *Scene, out io.Writer) error {
+	w, tracks, err := scene.Build()
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(out)
+	if err := writer.Write(trajectoryHeader); err != nil {
+		return err
+	}
+	for step := 1; step <= scene.Steps; step++ {
+		w.Step(scene.Timestep)
+		for _, track := range tracks {
+			sample := TrajectorySample{step, m.Real(step) * scene.Timestep, track.Name, track.Body.Position, track.Body.Orientation}
+			if err := writer.Write(sample.record()); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// record returns the sample as a CSV row.
+func (s *TrajectorySample) record() []string {
+	format := func(v m.Real) string {
+		return strconv.FormatFloat(float64(v), 'g', -1, 64)
+	}
+	return []string{
+		strconv.Itoa(s.Step), format(s.Time), s.Body,
+		format(s.Position[0]), format(s.Position[1]), format(s.Position[2]),
+		format(s.Orientation[0]), format(s.Orientation[1]), format(s.Orientation[2]), format(s.Orientation[3]),
+	}
+}
+
+// ReadTrajectories reads trajectory samples written in the RunScene CSV layout.
+func ReadTrajectories(r io.Reader) ([]TrajectorySample, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []TrajectorySample
+	for i, record := range records {
+		if i == 0 && len(record) > 0 && record[0] == trajectoryHeader[0] {
+			continue
+		}
+		if len(record) != len(trajectoryHeader) {
+			return nil, fmt.Errorf("row %d has %d columns instead of %d", i+1, len(record), len(trajectoryHeader))
+		}
+
+		var s TrajectorySample
+		s.Body = record[2]
+		if s.Step, err = strconv.Atoi(record[0]); err != nil {
+			return nil, fmt.Errorf("row %d has a bad step: %v", i+1, err)
+		}
+		var values [8]float64
+		for j := range values {
+			column := 1 + j
+			if j > 0 {
+				column = 2 + j
+			}
+			if values[j], err = strconv.ParseFloat(record[column], 64); err != nil {
+				return nil, fmt.Errorf("row %d has a bad %s: %v", i+1, trajectoryHeader[column], err)
+			}
+		}
+		s.Time = m.Real(values[0])
+		s.Position = m.Vector3{m.Real(values[1]), m.Real(values[2]), m.Real(values[3])}
+		s.Orientation = m.Quat{m.Real(values[4]), m.Real(values[5]), m.Real(values[6]), m.Real(values[7])}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+// TrajectoryError summarizes how far the trajectory of one body strayed from
+// the reference.
+type TrajectoryError struct {
+	// Body is the name of the body.
+	Body string
+
+	// Samples is the number of steps that were in both trajectories.
+	Samples int
+
+	// MaxPosition is the largest distance between the positions.
+	MaxPosition m.Real
+
+	// RMSPosition is the root mean square distance between the positions.
+	RMSPosition m.Real
+
+	// MaxAngle is the largest angle, in radians, between the orientations.
+	MaxAngle m.Real
+}
+
+// CompareTrajectories matches the samples of the two trajectories by body and
+// step and returns the error of each body, sorted by name. Samples that are
+// only in one of the trajectories are ignored.
+func CompareTrajectories(reference []TrajectorySample, actual []TrajectorySample) []TrajectoryError {
+	type key struct {
+		body string
+		step int
+	}
+	lookup := make(map[key]*TrajectorySample, len(reference))
+	for i := range reference {
+		lookup[key{reference[i].Body, reference[i].Step}] = &reference[i]
+	}
+
+	errors := make(map[string]*TrajectoryError)
+	for i := range actual {
+		s := &actual[i]
+		ref, ok := lookup[key{s.Body, s.Step}]
+		if !ok {
+			continue
+		}
+		e, ok := errors[s.Body]
+		if !ok {
+			e = &TrajectoryError{Body: s.Body}
+			errors[s.Body] = e
+		}
+
+		offset := s.Position
+		offset.Sub(&ref.Position)
+		distance := offset.Magnitude()
+		if distance > e.MaxPosition {
+			e.MaxPosition = distance
+		}
+		e.RMSPosition += distance * distance
+
+		// the angle of the rotation between the orientations, either way round
+		diff := ref.Orientation.Conjugated()
+		diff.Mul(&s.Orientation)
+		_, angle := diff.ToAxisAngle()
+		if angle > e.MaxAngle {
+			e.MaxAngle = angle
+		}
+		e.Samples++
+	}
+
+	result := make([]TrajectoryError, 0, len(errors))
+	for _, e := range errors {
+		e.RMSPosition = m.RealSqrt(e.RMSPosition / m.Real(e.Samples))
+		result = append(result, *e)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Body < result[j].Body
+	})
+	return result
+}
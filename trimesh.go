@@ -0,0 +1,534 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"sort"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// CollisionTriangleMesh is static collision geometry made of triangles, such
+// as level geometry loaded with LoadOBJ. The triangles face the side their
+// corners wind counter-clockwise around, as in OBJ files, and are one sided:
+// colliders that are behind a triangle by more than their own size pass
+// through it. Like CollisionHeightfield, it doesn't have an associated rigid
+// body, so spheres and cubes collide with it but other static colliders don't.
+type CollisionTriangleMesh struct {
+	// Position is the World Space position of the origin of the vertices.
+	Position m.Vector3
+
+	// Vertices holds the corners of the triangles relative to Position.
+	Vertices []m.Vector3
+
+	// Indices holds the indices into Vertices of the corners of each
+	// triangle, every three indices forming a triangle.
+	Indices []int
+
+	// Material holds the surface properties of the mesh. If nil, the
+	// DefaultMaterial is used.
+	Material *Material
+
+	// BVH is used to find the triangles near a collider. If nil, it's built
+	// when the derived data is calculated, so it only needs setting to reuse
+	// one cooked with CookTriangleMeshAsync. It has to be built again with
+	// BuildTriangleBVH after changing Vertices or Indices.
+	BVH *TriangleBVH
+
+	// transform is the translation of the mesh to Position.
+	// NOTE: this is calculated by calling CalculateDerivedData().
+	transform m.Matrix3x4
+
+	// derivedCount counts the times the derived data has been calculated.
+	derivedCount uint64
+}
+
+/*
+==================================================================================================
+  COLLISION TRIANGLE MESH
+==================================================================================================
+*/
+
+// NewCollisionTriangleMesh creates a new CollisionTriangleMesh from vertices
+// and the indices of its triangles, like those returned by LoadOBJ. Building
+// its BVH takes a while for large meshes, which CookTriangleMeshAsync can do
+// in the background instead.
+func NewCollisionTriangleMesh(vertices []m.Vector3, indices []int) *CollisionTriangleMesh {
+	mesh := new(CollisionTriangleMesh)
+	mesh.Vertices = vertices
+	mesh.Indices = indices
+	mesh.CalculateDerivedData()
+	return mesh
+}
+
+// Clone makes a new copy of the CollisionTriangleMesh object.
+func (mesh *CollisionTriangleMesh) Clone() Collider {
+	// the BVH is never changed once built, so the clone shares it
+	newMesh := new(CollisionTriangleMesh)
+	newMesh.Vertices = append([]m.Vector3(nil), mesh.Vertices...)
+	newMesh.Indices = append([]int(nil), mesh.Indices...)
+	newMesh.Position = mesh.Position
+	newMesh.Material = mesh.Material
+	newMesh.BVH = mesh.BVH
+	newMesh.CalculateDerivedData()
+	return newMesh
+}
+
+// CalculateDerivedData updates the transform of the mesh from its Position
+// and builds the BVH if it doesn't have one.
+func (mesh *CollisionTriangleMesh) CalculateDerivedData() {
+	mesh.transform.SetIdentity()
+	mesh.transform[9], mesh.transform[10], mesh.transform[11] = mesh.Position[0], mesh.Position[1], mesh.Position[2]
+	if mesh.BVH == nil {
+		mesh.BVH = BuildTriangleBVH(mesh.Vertices, mesh.Indices)
+	}
+	mesh.derivedCount++
+}
+
+// refreshDerivedData calculates the derived data if the Position has changed
+// since it was last calculated.
+func (mesh *CollisionTriangleMesh) refreshDerivedData() {
+	if mesh.derivedCount == 0 || mesh.transform.GetPosition() != mesh.Position {
+		mesh.CalculateDerivedData()
+	}
+}
+
+// derivedVersion returns a number that changes whenever the derived data is
+// calculated.
+func (mesh *CollisionTriangleMesh) derivedVersion() uint64 {
+	return mesh.derivedCount
+}
+
+// GetTransform returns the transform of the mesh, which is only a translation.
+func (mesh *CollisionTriangleMesh) GetTransform() m.Matrix3x4 {
+	return mesh.transform
+}
+
+// GetBody returns nil since the mesh doesn't have a rigid body associated with it.
+func (mesh *CollisionTriangleMesh) GetBody() *RigidBody {
+	return nil
+}
+
+// GetMaterial returns the surface material of the mesh.
+func (mesh *CollisionTriangleMesh) GetMaterial() *Material {
+	return materialOrDefault(mesh.Material)
+}
+
+// SetDensity doesn't do anything for meshes since they don't have a rigid body.
+func (mesh *CollisionTriangleMesh) SetDensity(density m.Real) {
+}
+
+// TriangleCount returns the number of triangles in the mesh.
+func (mesh *CollisionTriangleMesh) TriangleCount() int {
+	return len(mesh.Indices) / 3
+}
+
+// triangle returns the corners of a triangle relative to Position.
+func (mesh *CollisionTriangleMesh) triangle(index int) [3]m.Vector3 {
+	return [3]m.Vector3{
+		mesh.Vertices[mesh.Indices[index*3]],
+		mesh.Vertices[mesh.Indices[index*3+1]],
+		mesh.Vertices[mesh.Indices[index*3+2]],
+	}
+}
+
+// overlapping calls visit with the index of every triangle whose bounds
+// overlap the bounds given, which are relative to Position.
+func (mesh *CollisionTriangleMesh) overlapping(bounds *Bounds, visit func(index int)) {
+	if mesh.BVH != nil {
+		mesh.BVH.overlapping(bounds, visit)
+	}
+}
+
+// CheckAgainstHalfSpace doesn't return collisions against a plane since both are static.
+func (mesh *CollisionTriangleMesh) CheckAgainstHalfSpace(plane *CollisionPlane, existingContacts []*Contact) (bool, []*Contact) {
+	return false, existingContacts
+}
+
+// CheckAgainstSphere checks for collisions against a sphere.
+func (mesh *CollisionTriangleMesh) CheckAgainstSphere(sphere *CollisionSphere, existingContacts []*Contact) (bool, []*Contact) {
+	// use the sphere's implementation of the check
+	return sphere.CheckAgainstTriangleMesh(mesh, existingContacts)
+}
+
+// CheckAgainstCube checks for collisions against a cube.
+func (mesh *CollisionTriangleMesh) CheckAgainstCube(cube *CollisionCube, existingContacts []*Contact) (bool, []*Contact) {
+	// use the cube's implementation of the check
+	return cube.CheckAgainstTriangleMesh(mesh, existingContacts)
+}
+
+// CheckAgainstHeightfield doesn't return collisions against a heightfield since both are static.
+func (mesh *CollisionTriangleMesh) CheckAgainstHeightfield(hf *CollisionHeightfield, existingContacts []*Contact) (bool, []*Contact) {
+	return false, existingContacts
+}
+
+// CheckAgainstTriangleMesh doesn't return collisions against another mesh since both are static.
+func (mesh *CollisionTriangleMesh) CheckAgainstTriangleMesh(other *CollisionTriangleMesh, existingContacts []*Contact) (bool, []*Contact) {
+	return false, existingContacts
+}
+
+// CheckAgainstTriangleMesh doesn't return collisions against a mesh since both are static.
+func (p *CollisionPlane) CheckAgainstTriangleMesh(mesh *CollisionTriangleMesh, existingContacts []*Contact) (bool, []*Contact) {
+	return false, existingContacts
+}
+
+// CheckAgainstTriangleMesh doesn't return collisions against a mesh since both are static.
+func (hf *CollisionHeightfield) CheckAgainstTriangleMesh(mesh *CollisionTriangleMesh, existingContacts []*Contact) (bool, []*Contact) {
+	return false, existingContacts
+}
+
+// CheckAgainstTriangleMesh checks the sphere against the triangles of a mesh
+// and generates a single contact with the closest one.
+func (s *CollisionSphere) CheckAgainstTriangleMesh(mesh *CollisionTriangleMesh, existingContacts []*Contact) (bool, []*Contact) {
+	return s.checkTriangleMesh(mesh, existingContacts, nil)
+}
+
+// checkTriangleMesh is CheckAgainstTriangleMesh, taking the contacts from the pool.
+func (s *CollisionSphere) checkTriangleMesh(mesh *CollisionTriangleMesh, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
+	center := s.transform.GetAxis(3)
+	local := center
+	local.Sub(&mesh.Position)
+	bounds := m.AABBAround(&local, s.Radius)
+
+	// find the triangle that pushes the sphere out the least, which is the
+	// surface it's touching rather than a face further inside the mesh
+	found := false
+	var closest, normal m.Vector3
+	penetration := m.MaxValue
+	mesh.overlapping(&bounds, func(index int) {
+		tri := mesh.triangle(index)
+		faceNormal, ok := faceNormal(&tri)
+		if !ok {
+			return
+		}
+		point, u, v, w := closestPointOnTriangle(&local, &tri[0], &tri[1], &tri[2])
+		toCenter := local
+		toCenter.Sub(&point)
+
+		// ignore triangles that the sphere is behind
+		height := toCenter.Dot(&faceNormal)
+		if height < -s.Radius {
+			return
+		}
+
+		// inside the face the sphere is pushed along the face normal, and
+		// around the edges and corners away from the closest point
+		var depth m.Real
+		contactNormal := faceNormal
+		if u > 0.0 && v > 0.0 && w > 0.0 {
+			depth = s.Radius - height
+		} else {
+			distance := toCenter.Magnitude()
+			if height <= 0.0 {
+				return
+			}
+			if distance >= s.Radius || distance <= m.Epsilon {
+				return
+			}
+			depth = s.Radius - distance
+			contactNormal = toCenter
+			contactNormal.MulWith(1.0 / distance)
+		}
+		if depth <= 0.0 || depth >= penetration {
+			return
+		}
+		found = true
+		closest = point
+		normal = contactNormal
+		penetration = depth
+	})
+	if !found {
+		return false, existingContacts
+	}
+
+	c := pool.get()
+	c.ContactPoint = closest
+	c.ContactPoint.Add(&mesh.Position)
+	c.ContactNormal = normal
+	c.Penetration = penetration
+	c.Bodies[0] = s.Body
+	c.Bodies[1] = nil
+
+	c.SetMaterials(s.GetMaterial(), mesh.GetMaterial())
+
+	return true, append(existingContacts, c)
+}
+
+// CheckAgainstTriangleMesh checks the vertices of the cube against the
+// triangles of a mesh and the vertices of the mesh against the cube,
+// generating a contact for each one that has gone through the other.
+func (cube *CollisionCube) CheckAgainstTriangleMesh(mesh *CollisionTriangleMesh, existingContacts []*Contact) (bool, []*Contact) {
+	return cube.checkTriangleMesh(mesh, existingContacts, nil)
+}
+
+// checkTriangleMesh is CheckAgainstTriangleMesh, taking the contacts from the pool.
+func (cube *CollisionCube) checkTriangleMesh(mesh *CollisionTriangleMesh, existingContacts []*Contact, pool *contactPool) (bool, []*Contact) {
+	local := Bounds{Max: cube.HalfSize}
+	local.Min.Sub(&cube.HalfSize)
+	bounds := local.Transform(&cube.transform)
+	bounds.Min.Sub(&mesh.Position)
+	bounds.Max.Sub(&mesh.Position)
+
+	var triangles []int
+	mesh.overlapping(&bounds, func(index int) {
+		triangles = append(triangles, index)
+	})
+	if len(triangles) == 0 {
+		return false, existingContacts
+	}
+
+	contactDetected := false
+	contacts := existingContacts
+
+	// the vertices of the cube that are behind a triangle, and within it
+	// when seen along its normal, are pushed back out along the normal
+	var mults [8]m.Vector3
+	mults[0] = m.Vector3{1.0, 1.0, 1.0}
+	mults[1] = m.Vector3{-1.0, 1.0, 1.0}
+	mults[2] = m.Vector3{1.0, -1.0, 1.0}
+	mults[3] = m.Vector3{-1.0, -1.0, 1.0}
+	mults[4] = m.Vector3{1.0, 1.0, -1.0}
+	mults[5] = m.Vector3{-1.0, 1.0, -1.0}
+	mults[6] = m.Vector3{1.0, -1.0, -1.0}
+	mults[7] = m.Vector3{-1.0, -1.0, -1.0}
+	for _, v := range mults {
+		v.ComponentProduct(&cube.HalfSize)
+		vertexPos := cube.transform.MulVector3(&v)
+		vertex := vertexPos
+		vertex.Sub(&mesh.Position)
+
+		found := false
+		var normal m.Vector3
+		penetration := m.MaxValue
+		for _, index := range triangles {
+			tri := mesh.triangle(index)
+			faceNormal, ok := faceNormal(&tri)
+			if !ok {
+				continue
+			}
+			toVertex := vertex
+			toVertex.Sub(&tri[0])
+			depth := -toVertex.Dot(&faceNormal)
+
+			// only vertices behind the face by less than the size of the
+			// cube have gone through it rather than being round the back
+			if depth <= 0.0 || depth > 2.0*transformToAxis(cube, &faceNormal) || depth >= penetration {
+				continue
+			}
+			projected := vertex
+			projected.AddScaled(&faceNormal, depth)
+			if !pointOnTriangle(&projected, &tri) {
+				continue
+			}
+			found = true
+			normal = faceNormal
+			penetration = depth
+		}
+		if !found {
+			continue
+		}
+
+		c := pool.get()
+		c.ContactPoint = vertexPos
+		c.ContactNormal = normal
+		c.Penetration = penetration
+		c.Bodies[0] = cube.Body
+		c.Bodies[1] = nil
+
+		c.SetMaterials(cube.GetMaterial(), mesh.GetMaterial())
+
+		contacts = append(contacts, c)
+		contactDetected = true
+	}
+
+	// the vertices of the mesh inside the cube push it out through its
+	// closest face, which catches the cube landing on spikes and edges
+	var checked map[int]bool
+	for _, index := range triangles {
+		for corner := 0; corner < 3; corner++ {
+			vertexIndex := mesh.Indices[index*3+corner]
+			if checked[vertexIndex] {
+				continue
+			}
+			if checked == nil {
+				checked = make(map[int]bool)
+			}
+			checked[vertexIndex] = true
+
+			vertexPos := mesh.Vertices[vertexIndex]
+			vertexPos.Add(&mesh.Position)
+			relative := cube.transform.TransformInverse(&vertexPos)
+			distance, localNormal := boxSignedDistance(&relative, &cube.HalfSize)
+			if distance >= 0.0 {
+				continue
+			}
+
+			c := pool.get()
+			c.ContactPoint = vertexPos
+			c.ContactNormal = cube.transform.TransformDirection(&localNormal)
+			c.ContactNormal.MulWith(-1.0)
+			c.Penetration = -distance
+			c.Bodies[0] = cube.Body
+			c.Bodies[1] = nil
+
+			c.SetMaterials(cube.GetMaterial(), mesh.GetMaterial())
+
+			contacts = append(contacts, c)
+			contactDetected = true
+		}
+	}
+
+	return contactDetected, contacts
+}
+
+// faceNormal returns the normal of the side of the triangle its corners wind
+// counter-clockwise around, or false if the triangle has no area.
+func faceNormal(tri *[3]m.Vector3) (m.Vector3, bool) {
+	edge1 := tri[1]
+	edge1.Sub(&tri[0])
+	edge2 := tri[2]
+	edge2.Sub(&tri[0])
+	normal := edge1.Cross(&edge2)
+	length := normal.Magnitude()
+	if length <= m.Epsilon {
+		return normal, false
+	}
+	normal.MulWith(1.0 / length)
+	return normal, true
+}
+
+// pointOnTriangle returns true if the point, which lies in the plane of the
+// triangle, is within or on the edges of it.
+func pointOnTriangle(p *m.Vector3, tri *[3]m.Vector3) bool {
+	closest, _, _, _ := closestPointOnTriangle(p, &tri[0], &tri[1], &tri[2])
+	closest.Sub(p)
+	return closest.SquareMagnitude() <= m.Epsilon
+}
+
+// triangleBVHLeafSize is the most triangles kept in a leaf of a TriangleBVH.
+const triangleBVHLeafSize = 4
+
+// TriangleBVH is a bounding volume hierarchy over the triangles of a mesh,
+// which lets a CollisionTriangleMesh check only the triangles near a collider.
+// It's never changed once built, so it can be shared between meshes with the
+// same Vertices and Indices and between goroutines.
+type TriangleBVH struct {
+	// nodes holds the tree in depth first order, so the first child of an
+	// inner node always follows it.
+	nodes []triangleBVHNode
+
+	// triangles holds the indices of the triangles in the order the leaves
+	// refer to them, and triangleBounds their bounds in the same order.
+	triangles      []int
+	triangleBounds []Bounds
+}
+
+// triangleBVHNode is a node of a TriangleBVH. A leaf holds count triangles
+// starting at first in the triangles of the tree, and an inner node has a
+// count of zero and its second child at first.
+type triangleBVHNode struct {
+	bounds Bounds
+	first  int
+	count  int
+}
+
+// BuildTriangleBVH builds a TriangleBVH over the triangles given by every
+// three indices into the vertices.
+func BuildTriangleBVH(vertices []m.Vector3, indices []int) *TriangleBVH {
+	count := len(indices) / 3
+	bvh := new(TriangleBVH)
+	bvh.triangles = make([]int, count)
+	bounds := make([]Bounds, count)
+	centers := make([]m.Vector3, count)
+	for i := 0; i < count; i++ {
+		bvh.triangles[i] = i
+		a, b, c := vertices[indices[i*3]], vertices[indices[i*3+1]], vertices[indices[i*3+2]]
+		bounds[i] = Bounds{Min: a, Max: a}
+		bounds[i].MergePoint(&b)
+		bounds[i].MergePoint(&c)
+		centers[i] = bounds[i].Center()
+	}
+	if count > 0 {
+		bvh.build(0, count, bounds, centers)
+	}
+	bvh.triangleBounds = make([]Bounds, count)
+	for i, tri := range bvh.triangles {
+		bvh.triangleBounds[i] = bounds[tri]
+	}
+	return bvh
+}
+
+// build adds the node over the triangles from start to end, and its
+// children, splitting them at the middle along the axis their centers are
+// spread out the most on.
+func (bvh *TriangleBVH) build(start int, end int, bounds []Bounds, centers []m.Vector3) {
+	index := len(bvh.nodes)
+	bvh.nodes = append(bvh.nodes, triangleBVHNode{bounds: bounds[bvh.triangles[start]]})
+	spread := Bounds{Min: centers[bvh.triangles[start]], Max: centers[bvh.triangles[start]]}
+	for _, tri := range bvh.triangles[start+1 : end] {
+		bvh.nodes[index].bounds.Merge(&bounds[tri])
+		spread.MergePoint(&centers[tri])
+	}
+	if end-start <= triangleBVHLeafSize {
+		bvh.nodes[index].first = start
+		bvh.nodes[index].count = end - start
+		return
+	}
+
+	axis := 0
+	size := spread.HalfSize()
+	if size[1] > size[axis] {
+		axis = 1
+	}
+	if size[2] > size[axis] {
+		axis = 2
+	}
+	triangles := bvh.triangles[start:end]
+	sort.SliceStable(triangles, func(i, j int) bool {
+		return centers[triangles[i]][axis] < centers[triangles[j]][axis]
+	})
+
+	middle := (start + end) / 2
+	bvh.build(start, middle, bounds, centers)
+	bvh.nodes[index].first = len(bvh.nodes)
+	bvh.build(middle, end, bounds, centers)
+}
+
+// Bounds returns the bounds of all of the triangles in the tree.
+func (bvh *TriangleBVH) Bounds() Bounds {
+	if bvh == nil || len(bvh.nodes) == 0 {
+		return Bounds{}
+	}
+	return bvh.nodes[0].bounds
+}
+
+// overlapping calls visit with the index of every triangle whose bounds
+// overlap the bounds given.
+func (bvh *TriangleBVH) overlapping(bounds *Bounds, visit func(index int)) {
+	if len(bvh.nodes) == 0 {
+		return
+	}
+	// the tree is balanced, so its depth is never near the size of the stack
+	var stack [64]int
+	depth := 1
+	for depth > 0 {
+		depth--
+		current := stack[depth]
+		node := &bvh.nodes[current]
+		if !node.bounds.Overlaps(bounds) {
+			continue
+		}
+		if node.count > 0 {
+			for i := node.first; i < node.first+node.count; i++ {
+				if bvh.triangleBounds[i].Overlaps(bounds) {
+					visit(bvh.triangles[i])
+				}
+			}
+			continue
+		}
+		stack[depth] = node.first
+		stack[depth+1] = current + 1
+		depth += 2
+	}
+}
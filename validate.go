@@ -0,0 +1,149 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"fmt"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// ValidationPhase identifies the part of World.Step where a problem was found.
+type ValidationPhase int
+
+const (
+	// PhaseIntegrate is after the bodies have been integrated.
+	PhaseIntegrate ValidationPhase = iota
+
+	// PhaseCollision is after the contacts have been generated.
+	PhaseCollision
+
+	// PhaseResolve is after the contacts have been resolved.
+	PhaseResolve
+)
+
+// String returns the name of the phase.
+func (p ValidationPhase) String() string {
+	switch p {
+	case PhaseIntegrate:
+		return "integrate"
+	case PhaseCollision:
+		return "collision"
+	case PhaseResolve:
+		return "resolve"
+	}
+	return fmt.Sprintf("ValidationPhase(%d)", int(p))
+}
+
+// Diagnostic describes a non-finite value found while validating a step.
+type Diagnostic struct {
+	// Phase is the part of the step where the value was found.
+	Phase ValidationPhase
+
+	// Body is the body with the bad value. For contacts this is the body
+	// with bad state if there is one, otherwise the first body of the contact.
+	Body *RigidBody
+
+	// Contact is the contact with the bad value; this is nil when the
+	// problem is in the body's own state.
+	Contact *Contact
+
+	// Field names the value that was not finite, such as "Velocity".
+	Field string
+}
+
+// String returns a description of the problem.
+func (d Diagnostic) String() string {
+	if d.Contact != nil {
+		return fmt.Sprintf("non-finite contact %s during %s", d.Field, d.Phase)
+	}
+	return fmt.Sprintf("non-finite body %s during %s", d.Field, d.Phase)
+}
+
+// DiagnosticFunc is called for each problem found while validating a step.
+type DiagnosticFunc func(d Diagnostic)
+
+// isFiniteVector returns true if all of the components of the vector are finite.
+func isFiniteVector(v *m.Vector3) bool {
+	return m.RealIsFinite(v[0]) && m.RealIsFinite(v[1]) && m.RealIsFinite(v[2])
+}
+
+// ValidateBody checks the state of the body for non-finite values and returns
+// the name of the first bad field found, or an empty string if it's valid.
+func ValidateBody(body *RigidBody) string {
+	if !isFiniteVector(&body.Position) {
+		return "Position"
+	}
+	q := body.Orientation
+	if !m.RealIsFinite(q[0]) || !m.RealIsFinite(q[1]) || !m.RealIsFinite(q[2]) || !m.RealIsFinite(q[3]) {
+		return "Orientation"
+	}
+	if !isFiniteVector(&body.Velocity) {
+		return "Velocity"
+	}
+	if !isFiniteVector(&body.Rotation) {
+		return "Rotation"
+	}
+	return ""
+}
+
+// ValidateContact checks the contact data for non-finite values and returns
+// the name of the first bad field found, or an empty string if it's valid.
+func ValidateContact(c *Contact) string {
+	if !isFiniteVector(&c.ContactPoint) {
+		return "ContactPoint"
+	}
+	if !isFiniteVector(&c.ContactNormal) {
+		return "ContactNormal"
+	}
+	if !m.RealIsFinite(c.Penetration) {
+		return "Penetration"
+	}
+	return ""
+}
+
+// validateBodies reports the bodies of the world with non-finite state and
+// returns true if they were all valid.
+func (w *World) validateBodies(phase ValidationPhase) bool {
+	valid := true
+	for _, body := range w.Bodies {
+		if field := ValidateBody(body); field != "" {
+			valid = false
+			w.reportInvalid(Diagnostic{Phase: phase, Body: body, Field: field})
+		}
+	}
+	return valid
+}
+
+// validateContacts reports and drops the contacts that have non-finite data or
+// that touch a body with non-finite state so that a bad value doesn't get
+// passed on to the other bodies by the contact resolver.
+func (w *World) validateContacts(contacts []*Contact) []*Contact {
+	valid := contacts[:0]
+	for _, c := range contacts {
+		field := ValidateContact(c)
+		culprit := c.Bodies[0]
+		for i := 1; i >= 0; i-- {
+			if c.Bodies[i] != nil && ValidateBody(c.Bodies[i]) != "" {
+				culprit = c.Bodies[i]
+				if field == "" {
+					field = "Bodies"
+				}
+			}
+		}
+		if field != "" {
+			w.reportInvalid(Diagnostic{Phase: PhaseCollision, Body: culprit, Contact: c, Field: field})
+			continue
+		}
+		valid = append(valid, c)
+	}
+	return valid
+}
+
+// reportInvalid passes the diagnostic on to the world's callback, if there is one.
+func (w *World) reportInvalid(d Diagnostic) {
+	if w.OnInvalid != nil {
+		w.OnInvalid(d)
+	}
+}
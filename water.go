@@ -0,0 +1,209 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"math"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+const (
+	defaultWaterLinearDrag  = 1.0
+	defaultWaterAngularDrag = 1.0
+
+	// waterSlopeSpacing is the distance either side of a point that the
+	// height of the water is sampled at to find the slope of the surface.
+	waterSlopeSpacing = 0.1
+)
+
+// WaveFunc returns the height of the surface of the water at the x and z
+// coordinates given, in World Space, at the time t in seconds.
+type WaveFunc func(x, z, t m.Real) m.Real
+
+// WaterVolume is a body of water with a surface that can move with waves,
+// for boats and floating debris. The Y axis is up. The spheres and cubes of
+// the bodies in it are lifted by the water they push aside, which is measured
+// against the surface as it slopes under each of them, and slowed by drag
+// while they're in the water. Volumes added to a World are applied to the
+// awake bodies at the start of each step.
+type WaterVolume struct {
+	// Region limits the water to the part of the world it contains; colliders
+	// whose center is outside it aren't affected. If nil, the water goes on
+	// forever.
+	// Defaults to nil.
+	Region Volume
+
+	// Level is the height of the surface when Height is nil.
+	Level m.Real
+
+	// Height, if not nil, gives the height of the surface as waves move
+	// across it.
+	// Defaults to nil.
+	Height WaveFunc
+
+	// Time is the time in seconds passed to Height. A World advances it by
+	// the duration of each step.
+	Time m.Real
+
+	// Medium is the fluid that fills the volume.
+	// Defaults to MediumWater.
+	Medium *Medium
+
+	// LinearDrag slows the bodies in the water in proportion to their speed,
+	// as a fraction of that speed lost per second when fully submerged.
+	// Defaults to 1.0.
+	LinearDrag m.Real
+
+	// AngularDrag slows the spin of the bodies in the water in proportion to
+	// their speed, as a fraction of that speed lost per second when fully
+	// submerged.
+	// Defaults to 1.0.
+	AngularDrag m.Real
+}
+
+// NewWaterVolume creates a new WaterVolume of still water with its surface at
+// the level given.
+func NewWaterVolume(level m.Real) *WaterVolume {
+	wv := new(WaterVolume)
+	wv.Level = level
+	wv.Medium = &MediumWater
+	wv.LinearDrag = defaultWaterLinearDrag
+	wv.AngularDrag = defaultWaterAngularDrag
+	return wv
+}
+
+// HeightAt returns the height of the surface at the x and z coordinates given
+// at the current Time.
+func (wv *WaterVolume) HeightAt(x m.Real, z m.Real) m.Real {
+	if wv.Height == nil {
+		return wv.Level
+	}
+	return wv.Height(x, z, wv.Time)
+}
+
+// SurfaceAt returns the plane that touches the surface at the x and z
+// coordinates given, tilted along the slope of the waves there, with its
+// normal pointing out of the water.
+func (wv *WaterVolume) SurfaceAt(x m.Real, z m.Real) *CollisionPlane {
+	height := wv.HeightAt(x, z)
+	normal := m.Vector3{0.0, 1.0, 0.0}
+	if wv.Height != nil {
+		slopeX := (wv.HeightAt(x+waterSlopeSpacing, z) - wv.HeightAt(x-waterSlopeSpacing, z)) / (2.0 * waterSlopeSpacing)
+		slopeZ := (wv.HeightAt(x, z+waterSlopeSpacing) - wv.HeightAt(x, z-waterSlopeSpacing)) / (2.0 * waterSlopeSpacing)
+		normal = m.Vector3{-slopeX, 1.0, -slopeZ}
+		normal.Normalize()
+	}
+	point := m.Vector3{x, height, z}
+	return NewCollisionPlane(normal, normal.Dot(&point))
+}
+
+// Contains returns true if the point, in World Space, is under the water.
+func (wv *WaterVolume) Contains(point *m.Vector3) bool {
+	if wv.Region != nil && !wv.Region.Contains(point) {
+		return false
+	}
+	return point[1] < wv.HeightAt(point[0], point[2])
+}
+
+// ApplyTo adds the lift and drag of the water to the body of the collider.
+// Only spheres and cubes are supported.
+func (wv *WaterVolume) ApplyTo(c Collider) {
+	body := c.GetBody()
+	if body == nil || !body.HasFiniteMass() {
+		return
+	}
+	transform := c.GetTransform()
+	center := transform.GetAxis(3)
+	if wv.Region != nil && !wv.Region.Contains(&center) {
+		return
+	}
+
+	surface := wv.SurfaceAt(center[0], center[2])
+	var volume, total m.Real
+	var centerOfBuoyancy m.Vector3
+	switch shape := c.(type) {
+	case *CollisionSphere:
+		volume, centerOfBuoyancy = shape.SubmergedVolume(surface)
+		total = 4.0 / 3.0 * math.Pi * shape.Radius * shape.Radius * shape.Radius
+	case *CollisionCube:
+		volume, centerOfBuoyancy = shape.SubmergedVolume(surface)
+		total = 8.0 * shape.HalfSize[0] * shape.HalfSize[1] * shape.HalfSize[2]
+	default:
+		return
+	}
+	if volume <= 0.0 || total <= 0.0 {
+		return
+	}
+
+	medium := wv.Medium
+	if medium == nil {
+		medium = &MediumWater
+	}
+	force := body.GetGravity()
+	force.MulWith(-medium.Density * volume)
+	body.AddForceAtPoint(&force, &centerOfBuoyancy)
+
+	// the drag grows with how much of the shape is in the water
+	submerged := volume / total
+	if wv.LinearDrag > 0.0 {
+		drag := body.Velocity
+		drag.MulWith(-wv.LinearDrag * submerged * body.GetMass())
+		body.AddForce(&drag)
+	}
+	if wv.AngularDrag > 0.0 {
+		inverseInertia := body.GetInverseInertiaTensorWorld()
+		inertia := inverseInertia.Invert()
+		drag := inertia.MulVector3(&body.Rotation)
+		drag.MulWith(-wv.AngularDrag * submerged)
+		body.AddTorque(&drag)
+	}
+}
+
+// AddWaterVolume adds the water volume to the world so that it's applied each
+// step.
+func (w *World) AddWaterVolume(wv *WaterVolume) {
+	for _, existing := range w.WaterVolumes {
+		if existing == wv {
+			return
+		}
+	}
+	w.WaterVolumes = append(w.WaterVolumes, wv)
+}
+
+// RemoveWaterVolume removes the water volume from the world.
+func (w *World) RemoveWaterVolume(wv *WaterVolume) {
+	for i, existing := range w.WaterVolumes {
+		if existing == wv {
+			w.WaterVolumes = append(w.WaterVolumes[:i], w.WaterVolumes[i+1:]...)
+			return
+		}
+	}
+}
+
+// findWaterColliders returns the colliders of each moving body, so that the
+// water volumes can be applied to their shapes.
+func (w *World) findWaterColliders() map[*RigidBody][]Collider {
+	if len(w.WaterVolumes) == 0 {
+		return nil
+	}
+
+	colliders := make(map[*RigidBody][]Collider)
+	for _, c := range w.Colliders {
+		body := c.GetBody()
+		if body == nil || !body.HasFiniteMass() {
+			continue
+		}
+		c.CalculateDerivedData()
+		colliders[body] = append(colliders[body], c)
+	}
+	return colliders
+}
+
+// advanceWater moves the waves of the water volumes on by the duration given.
+func (w *World) advanceWater(duration m.Real) {
+	for _, wv := range w.WaterVolumes {
+		wv.Time += duration
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// WeldJoint locks two bodies together so that neither can move or turn
+// relative to the other, which lets objects be assembled from parts at
+// runtime and broken apart later by removing the joint from the world.
+// It's enforced with contacts that hold three points on each body together.
+// The embedded ConstraintSoftness can loosen the weld.
+type WeldJoint struct {
+	ConstraintSoftness
+
+	// Bodies holds the two bodies that are joined. The second body can be nil,
+	// in which case the first is welded to the world.
+	Bodies [2]*RigidBody
+
+	// Points holds the three points that are held together, in the Body
+	// Space of each body. If the second body is nil, its points are in
+	// World Space.
+	Points [3][2]m.Vector3
+
+	// Error is the distance the points are allowed to drift apart before the
+	// joint generates a contact.
+	Error m.Real
+}
+
+// NewWeldJoint creates a new WeldJoint that locks the bodies together in
+// their current positions. The anchor is a point in World Space where the
+// bodies meet and the points that are held together are spaced around it
+// by the length given. The derived data of the bodies must be up to date.
+func NewWeldJoint(one *RigidBody, two *RigidBody, anchor m.Vector3, length m.Real, err m.Real) *WeldJoint {
+	w := new(WeldJoint)
+	w.Bodies[0] = one
+	w.Bodies[1] = two
+	w.Error = err
+	if length <= 0.0 {
+		length = 1.0
+	}
+
+	points := [3]m.Vector3{anchor, anchor, anchor}
+	points[1][0] += length
+	points[2][1] += length
+	for p := range points {
+		for i, body := range w.Bodies {
+			w.Points[p][i] = worldPointToBody(body, &points[p])
+		}
+	}
+	return w
+}
+
+// GetBodies returns the two bodies of the weld.
+func (w *WeldJoint) GetBodies() [2]*RigidBody {
+	return w.Bodies
+}
+
+// AddContacts generates the contacts for any of the points that have drifted
+// apart and appends them to the existing contacts.
+func (w *WeldJoint) AddContacts(existingContacts []*Contact) (bool, []*Contact) {
+	count := len(existingContacts)
+	for p := range w.Points {
+		pointOne := bodyPointToWorld(w.Bodies[0], &w.Points[p][0])
+		pointTwo := bodyPointToWorld(w.Bodies[1], &w.Points[p][1])
+
+		_, existingContacts = addPointJointContact(existingContacts, &w.Bodies, &pointOne, &pointTwo, w.Error)
+	}
+	return len(existingContacts) > count, existingContacts
+}
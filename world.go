@@ -0,0 +1,1164 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// minBodiesPerWorker is the smallest number of bodies given to each goroutine
+// by IntegrateAll so that small worlds don't pay for the synchronization.
+const minBodiesPerWorker = 64
+
+// minPairsPerWorker is the smallest number of collider pairs given to each
+// goroutine by the narrowphase.
+const minPairsPerWorker = 32
+
+// maxTimeScaleSubsteps is the most substeps a sped up Step is split into, so
+// that a large TimeScale can't stall the game.
+const maxTimeScaleSubsteps = 16
+
+// World holds a set of bodies and colliders and steps them through time
+// together: integrating the bodies, generating contacts between the colliders
+// and resolving them.
+type World struct {
+	// Bodies holds the RigidBody objects that are integrated each step, in
+	// the order they were added. Adding or removing a body never changes the
+	// relative order of the others, so replays stay deterministic.
+	Bodies []*RigidBody
+
+	// Colliders holds the collision primitives that are checked against
+	// each other for contacts each step, in the order they were added.
+	Colliders []Collider
+
+	// Joints holds the joints that generate contacts each step to hold
+	// bodies together.
+	Joints []*Joint
+
+	// Constraints holds the hinges, sliders and other constraints that
+	// generate contacts each step to hold bodies in place relative to each other.
+	Constraints []Constraint
+
+	// Articulations holds the trees of bodies that are simulated in joint
+	// coordinates. Their bodies are in Bodies as well but aren't integrated
+	// on their own.
+	Articulations []*Articulation
+
+	// ParticleSystems holds the particle systems that are stepped after the
+	// rigid bodies each step.
+	ParticleSystems []*ParticleSystem
+
+	// SoftBodies holds the deformable bodies that are stepped after the rigid
+	// bodies each step.
+	SoftBodies []*SoftBody
+
+	// Sensors holds the sensors that are updated at the end of each step.
+	Sensors []*Sensor
+
+	// ForceVolumes holds the regional force fields that push on the bodies
+	// inside them each step.
+	ForceVolumes []*ForceVolume
+
+	// GravityZones holds the regions that change the gravity of the bodies
+	// inside them each step.
+	GravityZones []*GravityZone
+
+	// PathFollowers holds the movers that drive bodies along paths at the
+	// start of each step.
+	PathFollowers []*PathFollower
+
+	// WaterVolumes holds the bodies of water that lift and slow the bodies
+	// floating in them each step.
+	WaterVolumes []*WaterVolume
+
+	// Attractors holds the points that pull the bodies towards them. When
+	// there are any, their pull replaces the Acceleration of the bodies as
+	// their gravity.
+	Attractors []*Attractor
+
+	// Integrator is the method used to advance the bodies through time.
+	// Defaults to SemiImplicitEuler.
+	Integrator Integrator
+
+	// Convention is the axis convention of the world. Bodies and colliders
+	// should be brought in with ImportBody and ImportCollider when it's not
+	// YUp so that they are converted and get the right gravity.
+	// Defaults to YUp.
+	Convention AxisConvention
+
+	// MaxLinearSpeed is the speed limit applied after integration to bodies
+	// that don't set their own MaxLinearSpeed. A value of zero or less means
+	// there is no limit.
+	// Defaults to 0.0.
+	MaxLinearSpeed m.Real
+
+	// MaxAngularSpeed is the angular speed limit applied after integration to
+	// bodies that don't set their own MaxAngularSpeed. A value of zero or less
+	// means there is no limit.
+	// Defaults to 0.0.
+	MaxAngularSpeed m.Real
+
+	// TimeScale scales the time each Step moves the world on by, below 1.0
+	// for slow motion and above it to speed the world up. A sped up step is
+	// split into substeps no longer than the duration given to Step, so the
+	// solver never sees a longer step than it would at normal speed. Damping
+	// and sleeping go by the time stepped, so a scaled world ends up where
+	// it would have at normal speed, only with a finer or coarser step. A
+	// step is split into no more than 16 substeps, so the world runs no
+	// faster than 16 times normal speed. The Presentation and DebugDrawer see
+	// the world once per Step, blending over all of its substeps, and the
+	// stats add up the work of every substep. A value of zero or less is
+	// treated as 1.0.
+	// Defaults to 0.0.
+	TimeScale m.Real
+
+	// Forces, if not nil, is called for each body at the start of every step
+	// to add the forces acting on it. Integrators that evaluate the forces more
+	// than once per step will call it again as needed. When Workers is more
+	// than one, it's called from several goroutines at once.
+	Forces ForceFunc
+
+	// Generators, if not nil, holds the force generators that are applied to
+	// the bodies along with Forces. Generators may read the state of other
+	// bodies, but must only add forces to the body they're updating. With
+	// an Integrator that evaluates the forces more than once per step, those
+	// later evaluations happen while other bodies are being integrated, so
+	// they aren't safe when Workers is more than one.
+	Generators *ForceRegistry
+
+	// Workers is the number of goroutines used to integrate the bodies each
+	// step, and to generate the contacts for the pairs of colliders found by
+	// the broadphase. Bodies are independent during integration, and the
+	// contacts are put back in the order of the pairs, so the results are the
+	// same regardless of the number of workers. A value of one or less
+	// does the work on the calling goroutine.
+	// Defaults to 0.
+	Workers int
+
+	// Deterministic makes stepping the same world with the same inputs give
+	// bit-identical results on the same platform, for lockstep multiplayer.
+	// The bodies are integrated in order on the calling goroutine whatever
+	// Workers is set to, so that Forces and Generators that touch other
+	// bodies can't race. The contacts are still generated across Workers,
+	// since they're put back in the order of the pairs. Everything else in a
+	// step already runs in the order of the world's slices rather than the
+	// order of any map. Checksum gives
+	// a value to compare between peers to catch them drifting apart.
+	// Defaults to false.
+	Deterministic bool
+
+	// Events passes the collision, sensor and joint events of each step on
+	// to its subscribers.
+	Events *EventDispatcher
+
+	// MinImpactImpulse is the impulse the contacts between two bodies have
+	// to go over for EventImpact to be sent for them, which keeps bodies
+	// resting on each other from sending one every step.
+	// Defaults to 0.0.
+	MinImpactImpulse m.Real
+
+	// DebugDrawer, if not nil, is given the parts of the world in the
+	// DebugDraw categories at the end of each step to visualize them.
+	DebugDrawer DebugDrawer
+
+	// DebugDraw holds the categories drawn by the DebugDrawer.
+	// Defaults to none.
+	DebugDraw DebugCategory
+
+	// Wrap, if not nil, makes the world wrap around at its bounds so that
+	// bodies leaving through one side come back through the other and
+	// collide with bodies across the seam.
+	Wrap *WrapBounds
+
+	// Validate enables checking the bodies and contacts for NaN and infinite
+	// values after each phase of a step. Contacts with bad values are dropped
+	// before they're resolved and each problem is passed to OnInvalid.
+	// Defaults to false.
+	Validate bool
+
+	// OnInvalid, if not nil, is called for each problem found when Validate is set.
+	OnInvalid DiagnosticFunc
+
+	// Diagnostics enables measuring the energy and momentum of the bodies at
+	// the end of each step, which GetEnergy returns.
+	// Defaults to false.
+	Diagnostics bool
+
+	// OnEnergyGrowth, if not nil, is called when Diagnostics is set and the
+	// total energy of the world grew by more than EnergyTolerance in a step.
+	// Energy shouldn't grow unless something is adding it, such as forces,
+	// motors or explosions, so it's a sign of the solver going unstable.
+	OnEnergyGrowth EnergyFunc
+
+	// EnergyTolerance is the fraction of the kinetic energy of the world that
+	// the total energy can grow by in a step before OnEnergyGrowth is called.
+	// Growth smaller than the tolerance itself is always allowed, so that
+	// the jitter of resting bodies doesn't set it off.
+	// Defaults to 0.01.
+	EnergyTolerance m.Real
+
+	// SolverTrace, if not nil, is given a trace of the contact resolver each
+	// step to analyze how well it converges.
+	SolverTrace SolverTracer
+
+	// AdaptiveSolver lets the contact resolver stop as soon as the
+	// penetration and closing velocity of every contact are within
+	// SolverTolerance, and changes how many iterations it's allowed from
+	// step to step: doubling them, up to MaxSolverIterations, after a step
+	// that runs out before converging and bringing them back down after
+	// steps that converge easily.
+	// Defaults to false.
+	AdaptiveSolver bool
+
+	// SolverTolerance is the penetration and closing velocity under which
+	// the adaptive resolver treats a contact as resolved.
+	// Defaults to 0.01.
+	SolverTolerance m.Real
+
+	// MaxSolverIterations caps the iterations of each pass of the adaptive
+	// resolver in a step. A value of zero or less leaves the total uncapped,
+	// though the iterations per contact still stop doubling at 1024.
+	// Defaults to 4096.
+	MaxSolverIterations int
+
+	// SortContacts puts the contacts of each step in the order of the bodies
+	// they touch before they're resolved, so that the resolver reads the
+	// bodies mostly in order, which helps once there are thousands of
+	// contacts. Changing the order changes which of two equally bad contacts
+	// is resolved first, so results differ slightly from an unsorted world,
+	// but are just as deterministic.
+	// Defaults to false.
+	SortContacts bool
+
+	// Dump, if not nil, writes the state of the bodies and the contacts at
+	// the end of each step. Errors are kept by the dump rather than stopping
+	// the step.
+	Dump *StepDump
+
+	// Heatmap, if not nil, accumulates the contacts of each step, including
+	// those that hold the joints together, so that the places where they pile
+	// up can be exported or drawn with DebugHeatmap.
+	Heatmap *ContactHeatmap
+
+	// Presentation, if not nil, is given the poses of the bodies at the end
+	// of each step so that another goroutine can draw them while the world
+	// takes the next step.
+	Presentation *Presentation
+
+	// Profile wraps each step and its integrate, broadphase, narrowphase and
+	// solve phases in runtime/trace regions and pprof labels, so that the
+	// execution tracer and CPU profiles show which phase takes the time.
+	// Defaults to false.
+	Profile bool
+
+	// broadphase, if not nil, finds the pairs of colliders to check each step.
+	broadphase Broadphase
+
+	// boundsVersions holds the derived data version of each collider when
+	// its bounds were last given to the broadphase, so the colliders that
+	// haven't moved can be skipped.
+	boundsVersions map[Collider]uint64
+
+	// wrapGhosts holds the ghosts in the broadphase of each collider that
+	// hangs over a seam of Wrap, ghostWrap the region they were made for,
+	// set if ghostsWrapped, and ghostPairs is kept to find the pairs that
+	// were found both through ghosts and directly.
+	wrapGhosts    map[Collider][]*wrapGhost
+	ghostWrap     WrapBounds
+	ghostsWrapped bool
+	ghostPairs    map[ColliderPair]struct{}
+
+	// pairs and colliderOrder are kept between steps to find the pairs of
+	// colliders in, along with the index of each collider to sort them by.
+	pairs         []ColliderPair
+	colliderOrder map[Collider]int
+
+	// pairOrder is kept to sort the pairs with so that sorting them doesn't
+	// allocate.
+	pairOrder pairOrder
+
+	// capacity holds the capacities the world was last given to Reserve.
+	capacity WorldConfig
+
+	// contacts holds the contacts that were generated in the last step.
+	contacts []*Contact
+
+	// contactPool holds the contacts between colliders so that they're
+	// reused from step to step.
+	contactPool contactPool
+
+	// workerPools holds the contacts of each goroutine of the narrowphase.
+	workerPools []contactPool
+
+	// contactOrder is kept between steps to sort the contacts in when
+	// SortContacts is set, and contactsMoved counts the contacts the last
+	// sort moved.
+	contactOrder  contactOrder
+	contactsMoved int
+
+	// feedback holds the load each joint and constraint put on its bodies
+	// in the last step.
+	feedback map[interface{}]*JointFeedback
+
+	// bodyHandles hands out the BodyID values for the bodies in the world.
+	bodyHandles handleTable
+
+	// bodyIDs maps the bodies in the world to their handles.
+	bodyIDs map[*RigidBody]BodyID
+
+	// colliderHandles hands out the ColliderID values for the colliders in the world.
+	colliderHandles handleTable
+
+	// colliderIDs maps the colliders in the world to their handles.
+	colliderIDs map[Collider]ColliderID
+
+	// solverIterations is the number of iterations per contact the adaptive
+	// resolver allows each pass, or zero if it hasn't run yet.
+	solverIterations int
+
+	// energy holds the energy measured at the end of the last step.
+	energy *EnergyReport
+
+	// frozen holds the assemblies that have been collapsed into proxies.
+	frozen []*Assembly
+
+	// proxies maps the bodies of frozen assemblies to their proxy bodies.
+	proxies map[*RigidBody]*RigidBody
+
+	// collisionFilters maps the bodies of ragdolls, articulations and ropes
+	// to the filter that stops some of them colliding with each other.
+	collisionFilters map[*RigidBody]collisionFilter
+
+	// stats holds the stats of the last step.
+	stats StepStats
+
+	// fastForwardPoses is kept to hold where the bodies were at the start
+	// of a sped up Step.
+	fastForwardPoses []bodyStartPose
+
+	// impactSamples holds the velocities at the contacts of the step before
+	// they were resolved, and impacts and impactIndex are kept to gather
+	// the contacts into impacts for each pair of bodies.
+	impactSamples []impactSample
+	impacts       []Impact
+	impactIndex   map[[2]*RigidBody]int
+}
+
+// NewWorld creates a new, empty World object.
+func NewWorld() *World {
+	w := new(World)
+	w.Integrator = &SemiImplicitEuler{}
+	w.Events = NewEventDispatcher()
+	w.EnergyTolerance = defaultEnergyTolerance
+	w.SolverTolerance = defaultSolverTolerance
+	w.MaxSolverIterations = defaultMaxSolverIterations
+	return w
+}
+
+// AddBody adds the RigidBody to the world so that it's integrated each step
+// and returns its handle. Adding a body that's already in the world returns
+// its existing handle.
+func (w *World) AddBody(body *RigidBody) BodyID {
+	if id, ok := w.bodyIDs[body]; ok {
+		return id
+	}
+	if w.bodyIDs == nil {
+		w.bodyIDs = make(map[*RigidBody]BodyID)
+	}
+
+	var id BodyID
+	id.index, id.generation = w.bodyHandles.insert(body)
+	w.bodyIDs[body] = id
+	w.Bodies = append(w.Bodies, body)
+	return id
+}
+
+// RemoveBody removes the RigidBody from the world, invalidating its handle.
+func (w *World) RemoveBody(body *RigidBody) {
+	body.hasFieldGravity = false
+	body.inGravityZone = false
+	if id, ok := w.bodyIDs[body]; ok {
+		w.bodyHandles.release(id.index)
+		delete(w.bodyIDs, body)
+	}
+	for i, b := range w.Bodies {
+		if b == body {
+			w.Bodies = append(w.Bodies[:i], w.Bodies[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetBody returns the RigidBody for the handle, or false if the handle is no
+// longer valid because the body was removed.
+func (w *World) GetBody(id BodyID) (*RigidBody, bool) {
+	item, ok := w.bodyHandles.get(id.index, id.generation)
+	if !ok {
+		return nil, false
+	}
+	return item.(*RigidBody), true
+}
+
+// GetBodyID returns the handle of the RigidBody, or false if it's not in the world.
+func (w *World) GetBodyID(body *RigidBody) (BodyID, bool) {
+	id, ok := w.bodyIDs[body]
+	return id, ok
+}
+
+// RemoveBodyByID removes the RigidBody for the handle from the world. It
+// returns false if the handle was no longer valid.
+func (w *World) RemoveBodyByID(id BodyID) bool {
+	body, ok := w.GetBody(id)
+	if !ok {
+		return false
+	}
+	w.RemoveBody(body)
+	return true
+}
+
+// ImportBody converts a RigidBody authored in the axis convention given into
+// the convention of the world and adds it to the world.
+func (w *World) ImportBody(body *RigidBody, from AxisConvention) BodyID {
+	ConvertBody(body, from, w.Convention)
+	return w.AddBody(body)
+}
+
+// ImportCollider converts a collider authored in the axis convention given into
+// the convention of the world and adds it to the world. A RigidBody that's
+// already in the world, such as one shared with a collider imported earlier,
+// is left as it is and only the collider's shape is converted.
+func (w *World) ImportCollider(c Collider, from AxisConvention) ColliderID {
+	if _, ok := w.GetBodyID(c.GetBody()); ok {
+		convertShape(c, from, w.Convention)
+		c.CalculateDerivedData()
+	} else {
+		ConvertCollider(c, from, w.Convention)
+	}
+	return w.AddCollider(c)
+}
+
+// AddCollider adds the collider to the world so that it's checked for contacts
+// each step and returns its handle. If the collider has a RigidBody, that is
+// added as well.
+func (w *World) AddCollider(c Collider) ColliderID {
+	if id, ok := w.colliderIDs[c]; ok {
+		return id
+	}
+	if w.colliderIDs == nil {
+		w.colliderIDs = make(map[Collider]ColliderID)
+	}
+
+	var id ColliderID
+	id.index, id.generation = w.colliderHandles.insert(c)
+	w.colliderIDs[c] = id
+	w.Colliders = append(w.Colliders, c)
+	if w.broadphase != nil {
+		w.broadphase.Insert(c)
+	}
+	if body := c.GetBody(); body != nil {
+		w.AddBody(body)
+	}
+	return id
+}
+
+// GetCollider returns the Collider for the handle, or false if the handle is
+// no longer valid because the collider was removed.
+func (w *World) GetCollider(id ColliderID) (Collider, bool) {
+	item, ok := w.colliderHandles.get(id.index, id.generation)
+	if !ok {
+		return nil, false
+	}
+	return item.(Collider), true
+}
+
+// GetColliderID returns the handle of the Collider, or false if it's not in the world.
+func (w *World) GetColliderID(c Collider) (ColliderID, bool) {
+	id, ok := w.colliderIDs[c]
+	return id, ok
+}
+
+// RemoveColliderByID removes the Collider for the handle from the world. It
+// returns false if the handle was no longer valid.
+func (w *World) RemoveColliderByID(id ColliderID) bool {
+	c, ok := w.GetCollider(id)
+	if !ok {
+		return false
+	}
+	w.RemoveCollider(c)
+	return true
+}
+
+// RemoveCollider removes the collider from the world, invalidating its handle.
+// Its RigidBody, if any, is not removed.
+func (w *World) RemoveCollider(c Collider) {
+	if id, ok := w.colliderIDs[c]; ok {
+		w.colliderHandles.release(id.index)
+		delete(w.colliderIDs, c)
+	}
+	if w.broadphase != nil {
+		w.broadphase.Remove(c)
+	}
+	w.removeWrapGhosts(c)
+	delete(w.boundsVersions, c)
+	for i, existing := range w.Colliders {
+		if existing == c {
+			w.Colliders = append(w.Colliders[:i], w.Colliders[i+1:]...)
+			return
+		}
+	}
+}
+
+// AddJoint adds the joint to the world so that it generates contacts each step.
+func (w *World) AddJoint(j *Joint) {
+	for _, existing := range w.Joints {
+		if existing == j {
+			return
+		}
+	}
+	w.Joints = append(w.Joints, j)
+}
+
+// RemoveJoint removes the joint from the world.
+func (w *World) RemoveJoint(j *Joint) {
+	for i, existing := range w.Joints {
+		if existing == j {
+			w.Joints = append(w.Joints[:i], w.Joints[i+1:]...)
+			return
+		}
+	}
+}
+
+// AddSensor adds the sensor to the world so that it's updated each step.
+func (w *World) AddSensor(s *Sensor) {
+	for _, existing := range w.Sensors {
+		if existing == s {
+			return
+		}
+	}
+	w.Sensors = append(w.Sensors, s)
+}
+
+// RemoveSensor removes the sensor from the world.
+func (w *World) RemoveSensor(s *Sensor) {
+	for i, existing := range w.Sensors {
+		if existing == s {
+			w.Sensors = append(w.Sensors[:i], w.Sensors[i+1:]...)
+			return
+		}
+	}
+}
+
+// SetBroadphase sets the Broadphase used to find the pairs of colliders that
+// might be touching and inserts the colliders already in the world into it.
+// Setting it to nil checks every pair of colliders each step, which is the default.
+func (w *World) SetBroadphase(bp Broadphase) {
+	for c := range w.wrapGhosts {
+		w.removeWrapGhosts(c)
+	}
+	w.ghostsWrapped = false
+	w.broadphase = bp
+	for c := range w.boundsVersions {
+		delete(w.boundsVersions, c)
+	}
+	if bp == nil {
+		return
+	}
+	if r, ok := bp.(broadphaseReserver); ok {
+		r.reserve(w.capacity.MaxColliders, w.capacity.MaxPairs)
+	}
+	for _, c := range w.Colliders {
+		c.CalculateDerivedData()
+		bp.Insert(c)
+	}
+}
+
+// GetBroadphase returns the Broadphase of the world, or nil if it doesn't use one.
+func (w *World) GetBroadphase() Broadphase {
+	return w.broadphase
+}
+
+// Raycast casts a ray against the colliders in the world and returns the
+// closest hit within the distance given.
+func (w *World) Raycast(origin *m.Vector3, direction *m.Vector3, maxDistance m.Real) (bool, RayHit) {
+	candidates := w.Colliders
+	if w.broadphase != nil {
+		dir := *direction
+		dir.Normalize()
+		candidates = w.broadphase.Raycast(origin, &dir, maxDistance, nil)
+		if len(w.wrapGhosts) > 0 {
+			candidates = dropWrapGhosts(candidates, 0)
+		}
+	}
+
+	var closest RayHit
+	found := false
+	for _, c := range candidates {
+		hit, result := RaycastCollider(c, origin, direction)
+		if hit && result.Distance <= maxDistance && (!found || result.Distance < closest.Distance) {
+			closest = result
+			found = true
+		}
+	}
+	return found, closest
+}
+
+// QueryBounds returns the colliders in the world whose bounds overlap the
+// bounds given. The broadphase is used to find them if the world has one.
+func (w *World) QueryBounds(bounds *Bounds) []Collider {
+	return w.queryBounds(bounds, nil)
+}
+
+// queryBounds appends the colliders in the world whose bounds overlap the
+// bounds given to the candidates and returns the list.
+func (w *World) queryBounds(bounds *Bounds, candidates []Collider) []Collider {
+	if w.broadphase != nil {
+		from := len(candidates)
+		candidates = w.broadphase.QueryBounds(bounds, candidates)
+		if len(w.wrapGhosts) > 0 {
+			candidates = dropWrapGhosts(candidates, from)
+		}
+		return candidates
+	}
+
+	for _, c := range w.Colliders {
+		cb := ColliderBounds(c)
+		if cb.Overlaps(bounds) {
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates
+}
+
+// SetBodyTransform teleports the RigidBody to the position and orientation
+// given, updating its colliders and dropping any contacts from the last step
+// that involve it so that they can't pull it back.
+func (w *World) SetBodyTransform(body *RigidBody, position *m.Vector3, orientation *m.Quat) {
+	body.SetTransform(position, orientation)
+	for _, c := range w.Colliders {
+		if c.GetBody() == body {
+			c.CalculateDerivedData()
+			if w.broadphase != nil {
+				w.broadphase.Update(c)
+			}
+		}
+	}
+
+	kept := w.contacts[:0]
+	for _, contact := range w.contacts {
+		if contact.Bodies[0] != body && contact.Bodies[1] != body {
+			kept = append(kept, contact)
+		}
+	}
+	w.contacts = kept
+}
+
+// GetContacts returns the contacts that were generated in the last step. Like
+// the ones returned by Step, they're only good until the next step.
+func (w *World) GetContacts() []*Contact {
+	return w.contacts
+}
+
+// IntegrateAll integrates all of the bodies in the world by the duration given,
+// spreading them across Workers goroutines. The forces on all of the bodies
+// are added before any of them move so that generators connecting two bodies
+// see both of them in the same state.
+func (w *World) IntegrateAll(duration m.Real) {
+	integrator := w.Integrator
+	if integrator == nil {
+		integrator = &SemiImplicitEuler{}
+	}
+
+	// the volumes each body is in are found once, at the start of the step;
+	// the maps are only looked up, never ranged over, so they can't change
+	// the order anything is applied in
+	volumes := w.findForceVolumeBodies()
+	floating := w.findWaterColliders()
+	zones := w.findGravityZoneBodies()
+
+	forces := w.Forces
+	if w.Generators != nil || len(volumes) > 0 || len(floating) > 0 || len(w.Attractors) > 0 || len(w.GravityZones) > 0 {
+		forces = func(body *RigidBody) {
+			if len(w.Attractors) > 0 {
+				w.updateFieldGravity(body)
+			}
+			if len(w.GravityZones) > 0 {
+				body.updateZoneGravity(zones[body])
+			}
+			if w.Forces != nil {
+				w.Forces(body)
+			}
+			if w.Generators != nil {
+				w.Generators.ApplyTo(body, duration)
+			}
+			for _, fv := range volumes[body] {
+				fv.ApplyTo(body)
+			}
+			for _, wv := range w.WaterVolumes {
+				for _, c := range floating[body] {
+					wv.ApplyTo(c)
+				}
+			}
+		}
+	}
+
+	if forces != nil {
+		w.forEachBatch(func(bodies []*RigidBody) {
+			for _, body := range bodies {
+				if body.IsAwake {
+					forces(body)
+				}
+			}
+		})
+	}
+	w.forEachBatch(func(bodies []*RigidBody) {
+		w.integrateBodies(bodies, integrator, duration, forces)
+	})
+	w.advanceWater(duration)
+}
+
+// forEachBatch splits the bodies into contiguous batches and calls the function
+// for each of them across Workers goroutines, returning once they're all done.
+func (w *World) forEachBatch(f func(bodies []*RigidBody)) {
+	workers := w.Workers
+	if w.Deterministic {
+		workers = 1
+	}
+	if maxWorkers := len(w.Bodies) / minBodiesPerWorker; workers > maxWorkers {
+		workers = maxWorkers
+	}
+	if workers <= 1 {
+		f(w.Bodies)
+		return
+	}
+
+	var wg sync.WaitGroup
+	batch := (len(w.Bodies) + workers - 1) / workers
+	for start := 0; start < len(w.Bodies); start += batch {
+		end := start + batch
+		if end > len(w.Bodies) {
+			end = len(w.Bodies)
+		}
+		wg.Add(1)
+		go func(bodies []*RigidBody) {
+			defer wg.Done()
+			f(bodies)
+		}(w.Bodies[start:end])
+	}
+	wg.Wait()
+}
+
+// integrateBodies integrates the bodies given and applies the world speed limits.
+func (w *World) integrateBodies(bodies []*RigidBody, integrator Integrator, duration m.Real, forces ForceFunc) {
+	for _, body := range bodies {
+		if body.articulated {
+			continue
+		}
+		integrator.Integrate(body, duration, forces)
+		w.applySpeedLimits(body)
+	}
+}
+
+// applySpeedLimits clamps the velocities of the body to its speed limits, or
+// to the world speed limits if it doesn't have its own.
+func (w *World) applySpeedLimits(body *RigidBody) {
+	maxLinear, maxAngular := body.MaxLinearSpeed, body.MaxAngularSpeed
+	if maxLinear <= 0.0 {
+		maxLinear = w.MaxLinearSpeed
+	}
+	if maxAngular <= 0.0 {
+		maxAngular = w.MaxAngularSpeed
+	}
+	body.clampVelocities(maxLinear, maxAngular)
+}
+
+// Step advances the world through time by the duration given, scaled by
+// TimeScale, and returns the contacts that were generated and resolved. The contacts and the slice
+// holding them are reused by the next step, so copy any that need to be kept.
+// A sped up Step that's split into substeps only returns the contacts of the
+// last substep; the events of every substep are still dispatched.
+func (w *World) Step(duration m.Real) []*Contact {
+	return w.StepContext(context.Background(), duration)
+}
+
+// StepContext steps the world like Step. When Profile is set the pprof
+// labels of the context are put back after each phase of the step, so a
+// step called from within pprof.Do keeps the caller's labels.
+func (w *World) StepContext(ctx context.Context, duration m.Real) []*Contact {
+	if w.TimeScale <= 1.0 {
+		if w.TimeScale > 0.0 {
+			duration *= w.TimeScale
+		}
+		contacts := w.step(ctx, duration)
+		w.present(duration)
+		return contacts
+	}
+
+	substeps := int(math.Ceil(float64(w.TimeScale)))
+	substep := duration * (w.TimeScale / m.Real(substeps))
+	if substeps > maxTimeScaleSubsteps {
+		substeps = maxTimeScaleSubsteps
+		substep = duration
+	}
+
+	// the bodies blend from where they were before the first substep, and
+	// the stats count the work of every substep
+	w.fastForwardPoses = w.fastForwardPoses[:0]
+	for _, body := range w.Bodies {
+		w.fastForwardPoses = append(w.fastForwardPoses, bodyStartPose{body, body.Position, body.Orientation})
+	}
+	var contacts []*Contact
+	var total StepStats
+	for i := 0; i < substeps; i++ {
+		contacts = w.step(ctx, substep)
+		total.Duration += w.stats.Duration
+		total.Pairs += w.stats.Pairs
+		total.Contacts += w.stats.Contacts
+		total.SolverIterations[PassPosition] += w.stats.SolverIterations[PassPosition]
+		total.SolverIterations[PassVelocity] += w.stats.SolverIterations[PassVelocity]
+	}
+	w.stats.Duration = total.Duration
+	w.stats.Pairs = total.Pairs
+	w.stats.Contacts = total.Contacts
+	w.stats.SolverIterations = total.SolverIterations
+	for _, start := range w.fastForwardPoses {
+		start.body.prevPosition = start.position
+		start.body.prevOrientation = start.orientation
+	}
+	w.present(substep * m.Real(substeps))
+	return contacts
+}
+
+// bodyStartPose holds where a body was at the start of a sped up Step.
+type bodyStartPose struct {
+	body        *RigidBody
+	position    m.Vector3
+	orientation m.Quat
+}
+
+// step advances the world through time by the duration given, which has
+// already been scaled by TimeScale.
+func (w *World) step(ctx context.Context, duration m.Real) []*Contact {
+	ctx, endStep := w.beginStep(ctx)
+	defer endStep()
+	start := time.Now()
+	w.stats.SolverIterations = [2]int{}
+
+	end := w.beginPhase(ctx, "integrate")
+	for _, pf := range w.PathFollowers {
+		pf.Drive(duration)
+	}
+	w.IntegrateAll(duration)
+	w.stepArticulations(duration)
+	w.syncFrozenAssemblies()
+	if w.Wrap != nil {
+		w.wrapBodies()
+	}
+	if w.Validate {
+		w.validateBodies(PhaseIntegrate)
+	}
+	for _, c := range w.Colliders {
+		refreshDerivedData(c)
+	}
+	end()
+
+	// find the pairs of colliders that might be touching
+	var pairs []ColliderPair
+	checked := len(w.Colliders) * (len(w.Colliders) - 1) / 2
+	if w.broadphase != nil {
+		end = w.beginPhase(ctx, "broadphase")
+		pairs = w.broadphasePairs()
+		end()
+		w.dispatchPairChanges()
+		checked = len(pairs)
+	}
+
+	// generate the contacts between each pair of colliders
+	end = w.beginPhase(ctx, "narrowphase")
+	w.contacts = w.contactPool.reset()
+	if w.broadphase == nil {
+		for i, one := range w.Colliders {
+			for _, two := range w.Colliders[i+1:] {
+				w.contacts = w.checkPair(one, two, w.contacts, &w.contactPool)
+			}
+		}
+	} else {
+		w.narrowphase(pairs)
+	}
+	end()
+	w.contacts = w.Events.dispatchContacts(Event{Type: EventCollision}, w.contacts)
+	var collisions []*Contact
+	if w.Events.HasSubscribers(EventContactSolved) || w.Events.HasSubscribers(EventImpact) {
+		collisions = append(collisions, w.contacts...)
+	}
+	w.sampleImpacts(collisions)
+
+	// generate the contacts that hold the joints together
+	end = w.beginPhase(ctx, "solve")
+	if w.feedback == nil {
+		w.feedback = make(map[interface{}]*JointFeedback, len(w.Joints)+len(w.Constraints))
+	}
+	for joint := range w.feedback {
+		delete(w.feedback, joint)
+	}
+	for _, j := range w.Joints {
+		first := len(w.contacts)
+		_, w.contacts = j.AddContact(w.contacts)
+		j.soften(w.contacts[first:])
+		kept := w.Events.dispatchContacts(Event{Type: EventJoint, Joint: j}, w.contacts[first:])
+		w.contacts = w.contacts[:first+len(kept)]
+		w.trackFeedback(j, j.Bodies, kept)
+	}
+	for _, c := range w.Constraints {
+		first := len(w.contacts)
+		_, w.contacts = c.AddContacts(w.contacts)
+		if soft, ok := c.(softenedConstraint); ok {
+			soft.soften(w.contacts[first:])
+		}
+		kept := w.Events.dispatchContacts(Event{Type: EventJoint, Constraint: c}, w.contacts[first:])
+		w.contacts = w.contacts[:first+len(kept)]
+		w.trackFeedback(c, c.GetBodies(), kept)
+	}
+	if len(w.frozen) > 0 {
+		w.contacts = w.remapContactsToProxies(w.contacts)
+	}
+	if w.Validate {
+		w.contacts = w.validateContacts(w.contacts)
+	}
+	w.contactPool.buffer = w.contacts
+
+	// drive the motors and springs and then resolve the contacts, so that
+	// the joints and limits have the final say
+	w.applyConstraintImpulses(duration)
+	w.contactsMoved = 0
+	if w.SortContacts {
+		w.sortContacts()
+	}
+	if len(w.contacts) > 0 {
+		w.resolveStepContacts(duration)
+		w.applyRollingConstraints(duration)
+	}
+	w.syncArticulations()
+	w.finishFeedback(duration)
+	w.syncFrozenAssemblies()
+	end()
+	w.stepParticles(duration)
+	w.stepSoftBodies(duration)
+	if w.Validate {
+		w.validateBodies(PhaseResolve)
+	}
+
+	// let the sensors know where everything ended up
+	for _, s := range w.Sensors {
+		s.update(w.Bodies, w.Events)
+	}
+	w.dispatchImpacts(collisions)
+	w.Events.dispatchContacts(Event{Type: EventContactSolved}, collisions)
+
+	// and whatever follows the bodies that moved
+	for _, b := range w.Bodies {
+		b.NotifyTransform()
+	}
+	if w.Diagnostics {
+		w.measureStepEnergy()
+	} else {
+		w.energy = nil
+	}
+	if w.Heatmap != nil {
+		w.Heatmap.Accumulate(w.contacts)
+	}
+	if w.Dump != nil {
+		w.Dump.Write(w, duration)
+	}
+	w.recordStats(start, checked)
+	return w.contacts
+}
+
+// present hands the state of the world at the end of a Step, which moved it
+// on by the duration given, to the Presentation and the DebugDrawer.
+func (w *World) present(duration m.Real) {
+	if w.Presentation != nil {
+		w.Presentation.publish(w, duration)
+	}
+	if w.DebugDrawer != nil && w.DebugDraw != 0 {
+		w.DrawDebug(w.DebugDrawer, w.DebugDraw)
+	}
+}
+
+// dispatchPairChanges sends the events for the pairs of colliders that
+// stopped and started overlapping in the step, if the broadphase can tell.
+func (w *World) dispatchPairChanges() {
+	ib, ok := w.broadphase.(IncrementalBroadphase)
+	if !ok || !(w.Events.HasSubscribers(EventPairBegin) || w.Events.HasSubscribers(EventPairEnd)) {
+		return
+	}
+	added, removed := ib.PairChanges(nil, nil)
+	added = w.resolveWrapGhosts(added, w.colliderOrder)
+	removed = w.resolveWrapGhosts(removed, w.colliderOrder)
+	w.Events.dispatchPairs(EventPairEnd, removed)
+	w.Events.dispatchPairs(EventPairBegin, added)
+}
+
+// narrowphase generates the contacts for the pairs of colliders found by the
+// broadphase. The pairs are split into contiguous batches across Workers
+// goroutines, each with its own pool, and the contacts of the batches are
+// joined back up in order so they come out as if checked on one goroutine.
+func (w *World) narrowphase(pairs []ColliderPair) {
+	workers := w.Workers
+	if w.Wrap != nil {
+		// the wrapped checks move bodies to their images and back
+		workers = 1
+	}
+	if maxWorkers := len(pairs) / minPairsPerWorker; workers > maxWorkers {
+		workers = maxWorkers
+	}
+	if workers <= 1 {
+		for _, pair := range pairs {
+			w.contacts = w.checkPair(pair.One, pair.Two, w.contacts, &w.contactPool)
+		}
+		return
+	}
+
+	for len(w.workerPools) < workers {
+		w.workerPools = append(w.workerPools, contactPool{})
+		w.workerPools[len(w.workerPools)-1].reserve(w.capacity.MaxContacts / workers)
+	}
+	var wg sync.WaitGroup
+	batch := (len(pairs) + workers - 1) / workers
+	batches := 0
+	for start := 0; start < len(pairs); start += batch {
+		end := start + batch
+		if end > len(pairs) {
+			end = len(pairs)
+		}
+		wg.Add(1)
+		go func(pairs []ColliderPair, pool *contactPool) {
+			defer wg.Done()
+			contacts := pool.reset()
+			for _, pair := range pairs {
+				contacts = w.checkPair(pair.One, pair.Two, contacts, pool)
+			}
+			pool.buffer = contacts
+		}(pairs[start:end], &w.workerPools[batches])
+		batches++
+	}
+	wg.Wait()
+	for i := 0; i < batches; i++ {
+		w.contacts = append(w.contacts, w.workerPools[i].buffer...)
+	}
+}
+
+// checkPair appends the contacts between two colliders, taken from the pool,
+// unless they belong to the same body or frozen assembly or to a structure
+// whose filter stops them colliding.
+func (w *World) checkPair(one Collider, two Collider, contacts []*Contact, pool *contactPool) []*Contact {
+	bodyOne, bodyTwo := w.proxyOf(one.GetBody()), w.proxyOf(two.GetBody())
+	if bodyOne == bodyTwo || w.ignoresCollision(one.GetBody(), two.GetBody()) {
+		return contacts
+	}
+	first := len(contacts)
+	wrapped := false
+	if w.Wrap != nil {
+		wrapped, contacts = w.checkWrappedPair(one, two, contacts, pool)
+	}
+	if !wrapped {
+		_, contacts = checkForCollisions(one, two, contacts, pool)
+	}
+	for _, c := range contacts[first:] {
+		for i := range c.Bodies {
+			if c.Bodies[i] == one.GetBody() {
+				c.colliders[i] = one
+			} else if c.Bodies[i] == two.GetBody() {
+				c.colliders[i] = two
+			}
+		}
+	}
+
+	// pass the motion of conveyor surfaces on to the contacts
+	surfaceOne, surfaceTwo := surfaceVelocity(one), surfaceVelocity(two)
+	if surfaceOne.SquareMagnitude() == 0.0 && surfaceTwo.SquareMagnitude() == 0.0 {
+		return contacts
+	}
+	for _, c := range contacts[first:] {
+		for i := range c.Bodies {
+			if c.Bodies[i] == one.GetBody() {
+				c.surfaceVelocities[i] = surfaceOne
+			} else if c.Bodies[i] == two.GetBody() {
+				c.surfaceVelocities[i] = surfaceTwo
+			}
+		}
+	}
+	return contacts
+}
+
+// broadphasePairs updates the broadphase and returns the pairs of colliders it
+// finds. The pairs are put in the order of the Colliders slice so that the
+// contacts are generated in the same order whichever broadphase is used.
+func (w *World) broadphasePairs() []ColliderPair {
+	if w.boundsVersions == nil {
+		w.boundsVersions = make(map[Collider]uint64, len(w.Colliders))
+	}
+	if w.colliderOrder == nil {
+		w.colliderOrder = make(map[Collider]int, len(w.Colliders))
+	}
+	order := w.colliderOrder
+	for c := range order {
+		delete(order, c)
+	}
+	w.syncWrapGhosts()
+	for i, c := range w.Colliders {
+		order[c] = i
+
+		// colliders that haven't moved keep their bounds
+		if dc, ok := c.(derivedCollider); ok {
+			version := dc.derivedVersion()
+			if last, seen := w.boundsVersions[c]; seen && last == version {
+				continue
+			}
+			w.boundsVersions[c] = version
+		}
+		w.broadphase.Update(c)
+		w.updateWrapGhosts(c)
+	}
+
+	pairs := w.broadphase.QueryPairs(w.pairs[:0])
+	pairs = w.resolveWrapGhosts(pairs, order)
+	kept := pairs[:0]
+	for _, p := range pairs {
+		i, okOne := order[p.One]
+		j, okTwo := order[p.Two]
+		if !okOne || !okTwo {
+			continue
+		}
+		if i > j {
+			p = ColliderPair{p.Two, p.One}
+		}
+		kept = append(kept, p)
+	}
+	w.pairs = kept
+	w.pairOrder = pairOrder{kept, order}
+	sort.Sort(&w.pairOrder)
+	return kept
+}
+
+// pairOrder sorts pairs of colliders by the order of their colliders.
+type pairOrder struct {
+	pairs []ColliderPair
+	order map[Collider]int
+}
+
+func (o pairOrder) Len() int      { return len(o.pairs) }
+func (o pairOrder) Swap(i, j int) { o.pairs[i], o.pairs[j] = o.pairs[j], o.pairs[i] }
+func (o pairOrder) Less(i, j int) bool {
+	a, b := o.pairs[i], o.pairs[j]
+	if o.order[a.One] != o.order[b.One] {
+		return o.order[a.One] < o.order[b.One]
+	}
+	return o.order[a.Two] < o.order[b.Two]
+}
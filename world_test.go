@@ -0,0 +1,2138 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"math"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+	"testing"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// newTestSphere creates a sphere collider with a body at the position given.
+func newTestSphere(position m.Vector3) *CollisionSphere {
+	s := NewCollisionSphere(nil, 0.5)
+	s.SetDensity(1.0)
+	s.Body.Position = position
+	s.Body.CalculateDerivedData()
+	s.CalculateDerivedData()
+	return s
+}
+
+// newTestPile creates a world with a ground plane and a loose pile of spheres
+// that will collide with each other as they settle.
+func newTestPile() (*World, []*CollisionSphere) {
+	w := NewWorld()
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+
+	var spheres []*CollisionSphere
+	for i := 0; i < 6; i++ {
+		s := newTestSphere(m.Vector3{m.Real(i%2) * 0.6, 0.6 + m.Real(i)*0.9, m.Real(i%3) * 0.3})
+		spheres = append(spheres, s)
+		w.AddCollider(s)
+	}
+	return w, spheres
+}
+
+func TestWorldRemovalKeepsOrder(t *testing.T) {
+	w := NewWorld()
+	var spheres []*CollisionSphere
+	for i := 0; i < 5; i++ {
+		s := newTestSphere(m.Vector3{m.Real(i) * 2.0, 0.0, 0.0})
+		spheres = append(spheres, s)
+		w.AddCollider(s)
+	}
+
+	w.RemoveCollider(spheres[1])
+	w.RemoveBody(spheres[1].Body)
+	w.RemoveCollider(spheres[3])
+	w.RemoveBody(spheres[3].Body)
+
+	expected := []*CollisionSphere{spheres[0], spheres[2], spheres[4]}
+	if len(w.Colliders) != len(expected) || len(w.Bodies) != len(expected) {
+		t.Fatalf("World has %d colliders and %d bodies after removal; expected %d", len(w.Colliders), len(w.Bodies), len(expected))
+	}
+	for i, s := range expected {
+		if w.Colliders[i] != s {
+			t.Errorf("Collider %d is out of order after removal", i)
+		}
+		if w.Bodies[i] != s.Body {
+			t.Errorf("Body %d is out of order after removal", i)
+		}
+	}
+
+	// adding a new body should not disturb the existing ones either
+	extra := newTestSphere(m.Vector3{10.0, 0.0, 0.0})
+	w.AddCollider(extra)
+	for i, s := range expected {
+		if w.Colliders[i] != s || w.Bodies[i] != s.Body {
+			t.Errorf("Body %d is out of order after adding another body", i)
+		}
+	}
+}
+
+func TestWorldRemovalIsDeterministic(t *testing.T) {
+	const steps = 200
+	const despawnStep = 50
+	const duration = 1.0 / 60.0
+
+	// the reference world never sees the unrelated body
+	reference, referenceSpheres := newTestPile()
+	for i := 0; i < steps; i++ {
+		reference.Step(duration)
+	}
+
+	// the other world has an unrelated body in the middle of its order that
+	// gets despawned partway through the simulation
+	w, spheres := newTestPile()
+	unrelated := newTestSphere(m.Vector3{100.0, 50.0, 100.0})
+	w.Colliders = append(w.Colliders[:3], append([]Collider{unrelated}, w.Colliders[3:]...)...)
+	w.Bodies = append(w.Bodies[:2], append([]*RigidBody{unrelated.Body}, w.Bodies[2:]...)...)
+	for i := 0; i < steps; i++ {
+		if i == despawnStep {
+			w.RemoveCollider(unrelated)
+			w.RemoveBody(unrelated.Body)
+		}
+		w.Step(duration)
+	}
+
+	for i, s := range spheres {
+		expected := referenceSpheres[i].Body
+		if s.Body.Position != expected.Position || s.Body.Orientation != expected.Orientation {
+			t.Errorf("Sphere %d diverged after an unrelated body was removed: %v != %v", i, s.Body.Position, expected.Position)
+		}
+	}
+}
+
+func TestWorldStaleHandles(t *testing.T) {
+	w := NewWorld()
+	first := newTestSphere(m.Vector3{0.0, 0.0, 0.0})
+	firstID := w.AddCollider(first)
+	bodyID, ok := w.GetBodyID(first.Body)
+	if !ok {
+		t.Fatalf("Body of an added collider has no handle")
+	}
+
+	w.RemoveCollider(first)
+	w.RemoveBody(first.Body)
+
+	// the slots are reused by the next objects added
+	second := newTestSphere(m.Vector3{1.0, 0.0, 0.0})
+	secondID := w.AddCollider(second)
+	if _, ok := w.GetCollider(firstID); ok {
+		t.Errorf("Stale collider handle %v still resolves after removal", firstID)
+	}
+	if _, ok := w.GetBody(bodyID); ok {
+		t.Errorf("Stale body handle %v still resolves after removal", bodyID)
+	}
+	if w.RemoveBodyByID(bodyID) {
+		t.Errorf("Removing with a stale body handle reported success")
+	}
+	if c, ok := w.GetCollider(secondID); !ok || c != second {
+		t.Errorf("Handle %v does not resolve to the collider it was issued for", secondID)
+	}
+	if len(w.Bodies) != 1 || w.Bodies[0] != second.Body {
+		t.Errorf("Removing with a stale handle affected the live body")
+	}
+}
+
+func TestWorldDeterministicChecksum(t *testing.T) {
+	const steps = 200
+	const duration = 1.0 / 60.0
+
+	// a world with a broadphase and several workers against a plain one
+	reference, _ := newTestPile()
+	reference.Deterministic = true
+	w, _ := newTestPile()
+	w.Deterministic = true
+	w.Workers = 4
+	w.SetBroadphase(NewSweepAndPruneBroadphase())
+	for i := 0; i < steps; i++ {
+		reference.Step(duration)
+		w.Step(duration)
+		if w.Checksum() != reference.Checksum() {
+			t.Fatalf("Worlds diverged at step %d", i)
+		}
+	}
+
+	// and the checksum notices a change
+	w.Bodies[0].Position[0] += m.Epsilon
+	if w.Checksum() == reference.Checksum() {
+		t.Errorf("Checksum didn't change when a body moved")
+	}
+}
+
+func TestWorldSaveLoadState(t *testing.T) {
+	const duration = 1.0 / 60.0
+
+	w, _ := newTestPile()
+	for i := 0; i < 50; i++ {
+		w.Step(duration)
+	}
+	var state WorldState
+	w.SaveState(&state)
+	for i := 0; i < 50; i++ {
+		w.Step(duration)
+	}
+	expected := w.Checksum()
+
+	// going back and simulating again gives the same result each time
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := w.LoadState(&state); err != nil {
+			t.Fatalf("LoadState failed: %v", err)
+		}
+		for i := 0; i < 50; i++ {
+			w.Step(duration)
+		}
+		if w.Checksum() != expected {
+			t.Errorf("World diverged after rolling back %d times", attempt+1)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		w.SaveState(&state)
+		w.LoadState(&state)
+	})
+	if allocs > 0 {
+		t.Errorf("SaveState and LoadState made %v allocations; expected none", allocs)
+	}
+
+	w.AddCollider(newTestSphere(m.Vector3{}))
+	if err := w.LoadState(&state); err == nil {
+		t.Errorf("LoadState didn't fail for a world with a new body")
+	}
+}
+
+func TestWorldJSONRoundTrip(t *testing.T) {
+	const duration = 1.0 / 60.0
+
+	w, spheres := newTestPile()
+	w.MaxLinearSpeed = 50.0
+	w.TimeScale = 0.5
+	w.Integrator = &VelocityVerlet{}
+	hinge := NewHingeJoint(spheres[0].Body, spheres[1].Body, m.Vector3{0.0, 1.0, 0.0}, m.Vector3{0.0, 0.0, 1.0}, 0.01)
+	hinge.Stall = &StallDetector{Steps: 10}
+	w.AddConstraint(hinge)
+	w.AddJoint(NewJoint(spheres[2].Body, m.Vector3{}, nil, m.Vector3{0.0, 3.0, 0.0}, 0.5))
+	for i := 0; i < 20; i++ {
+		w.Step(duration)
+	}
+
+	data, err := json.Marshal(w)
+	if err != nil {
+		t.Fatalf("Failed to marshal the world: %v", err)
+	}
+	loaded := NewWorld()
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatalf("Failed to unmarshal the world: %v", err)
+	}
+	if len(loaded.Bodies) != len(w.Bodies) || len(loaded.Colliders) != len(w.Colliders) ||
+		len(loaded.Joints) != len(w.Joints) || len(loaded.Constraints) != len(w.Constraints) {
+		t.Fatalf("Loaded world has different parts to the saved one")
+	}
+	if loaded.Checksum() != w.Checksum() {
+		t.Fatalf("Loaded world has a different state to the saved one")
+	}
+	if _, ok := loaded.Integrator.(*VelocityVerlet); !ok || loaded.MaxLinearSpeed != w.MaxLinearSpeed || loaded.TimeScale != w.TimeScale {
+		t.Errorf("Loaded world lost its tuning parameters")
+	}
+
+	// both carry on the same way
+	for i := 0; i < 50; i++ {
+		w.Step(duration)
+		loaded.Step(duration)
+	}
+	if loaded.Checksum() != w.Checksum() {
+		t.Errorf("Loaded world diverged from the saved one")
+	}
+
+	if err := json.Unmarshal(data, loaded); err == nil {
+		t.Errorf("Unmarshalling into a world that isn't empty didn't fail")
+	}
+}
+
+func TestWorldBinarySnapshot(t *testing.T) {
+	const duration = 1.0 / 60.0
+
+	w, _ := newTestPile()
+	for i := 0; i < 30; i++ {
+		w.Step(duration)
+	}
+	expected := w.Checksum()
+
+	for _, compress := range []bool{false, true} {
+		data, err := w.EncodeSnapshot(compress)
+		if err != nil {
+			t.Fatalf("Failed to encode the snapshot: %v", err)
+		}
+		loaded, _ := newTestPile()
+		if err := loaded.DecodeSnapshot(data); err != nil {
+			t.Fatalf("Failed to decode the snapshot: %v", err)
+		}
+		if loaded.Checksum() != expected {
+			t.Errorf("Snapshot with compression %v didn't restore the bodies exactly", compress)
+		}
+	}
+
+	data, _ := w.EncodeSnapshot(false)
+	if _, err := DecodeSnapshots(data[:len(data)-1]); err == nil {
+		t.Errorf("Decoding a truncated snapshot didn't fail")
+	}
+	data[4] = 99
+	if _, err := DecodeSnapshots(data); err == nil {
+		t.Errorf("Decoding a snapshot of an unknown version didn't fail")
+	}
+}
+
+func TestWorldDeltaSync(t *testing.T) {
+	const duration = 1.0 / 60.0
+
+	server, _ := newTestPile()
+	server.Bodies[0].SetAwake(false)
+	config := NewQuantizeConfig(m.Vector3{-10.0, -10.0, -10.0}, m.Vector3{10.0, 10.0, 10.0})
+
+	// the client starts with nothing and gets every body in the first delta
+	var client []BodySnapshot
+	for step := 0; step < 30; step++ {
+		server.Step(duration)
+		delta := config.EncodeDelta(client, server.Snapshot())
+		next, err := config.ApplyDelta(client, delta)
+		if err != nil {
+			t.Fatalf("Failed to apply the delta at step %d: %v", step, err)
+		}
+		client = next
+	}
+
+	errors := config.ErrorBounds()
+	for i, body := range server.Bodies {
+		for j := 0; j < 3; j++ {
+			if diff := m.RealAbs(client[i].Position[j] - body.Position[j]); diff > errors.Position[j]*1.001 {
+				t.Errorf("Body %d is %v away from the server on axis %d", i, diff, j)
+			}
+		}
+	}
+
+	// nothing changed, so the next delta only holds the count and the flags
+	delta := config.EncodeDelta(client, client)
+	if expected := (deltaCountBits + len(client) + 7) / 8; len(delta) != expected {
+		t.Errorf("Delta of an unchanged state is %d bytes; expected %d", len(delta), expected)
+	}
+	if _, err := config.ApplyDelta(nil, delta); err == nil {
+		t.Errorf("Applying a delta to the wrong baseline didn't fail")
+	}
+}
+
+func TestWorldRecordReplay(t *testing.T) {
+	const duration = 1.0 / 60.0
+
+	w, spheres := newTestPile()
+	recorder, err := NewRecorder(w)
+	if err != nil {
+		t.Fatalf("Failed to start recording: %v", err)
+	}
+	recorder.Transforms = true
+	for step := 0; step < 60; step++ {
+		if step%10 == 0 {
+			if err := recorder.Apply(spheres[step/10].Body, InputImpulseAtPoint, m.Vector3{1.0, 2.0, 0.0}, spheres[step/10].Body.Position); err != nil {
+				t.Fatalf("Failed to record an input: %v", err)
+			}
+		}
+		if step == 20 {
+			if err := recorder.Spawn(newTestSphere(m.Vector3{0.0, 8.0, 0.0})); err != nil {
+				t.Fatalf("Failed to record a spawn: %v", err)
+			}
+		}
+		recorder.Step(duration)
+	}
+
+	var buffer bytes.Buffer
+	if err := recorder.Recording().Save(&buffer); err != nil {
+		t.Fatalf("Failed to save the recording: %v", err)
+	}
+	recording, err := LoadRecording(&buffer)
+	if err != nil {
+		t.Fatalf("Failed to load the recording: %v", err)
+	}
+	replayer, err := NewReplayer(recording)
+	if err != nil {
+		t.Fatalf("Failed to start the replay: %v", err)
+	}
+	for !replayer.Done() {
+		if _, err := replayer.Step(); err != nil {
+			t.Fatalf("Replay failed: %v", err)
+		}
+	}
+	if replayer.World.Checksum() != w.Checksum() || len(replayer.World.Bodies) != len(w.Bodies) {
+		t.Errorf("Replay ended in a different state to the recording")
+	}
+	if last := recording.Frames[len(recording.Frames)-1]; len(last.Transforms) != len(w.Bodies) {
+		t.Errorf("Recording kept %d transforms; expected %d", len(last.Transforms), len(w.Bodies))
+	}
+
+	// seeking back rewinds and plays forward again
+	if err := replayer.Seek(30); err != nil || replayer.Frame() != 30 {
+		t.Fatalf("Failed to seek back: %v", err)
+	}
+
+	// a glitch that wasn't recorded shows up as a divergence
+	replayer.World.Bodies[3].AddVelocity(&m.Vector3{0.0, 5.0, 0.0})
+	if _, err := replayer.Step(); err == nil {
+		t.Errorf("Replay didn't notice the world diverging")
+	}
+}
+
+func TestWorldExportGLTF(t *testing.T) {
+	w, spheres := newTestPile()
+	cube := NewCollisionCube(nil, m.Vector3{0.5, 1.0, 1.5})
+	cube.Body.SetMass(2.0)
+	cube.Body.Position = m.Vector3{3.0, 1.0, 0.0}
+	cube.Body.Orientation = m.QuatFromAxis(0.7, 0.0, 1.0, 0.0)
+	cube.Body.CalculateDerivedData()
+	w.AddCollider(cube)
+	w.AddCollider(NewCollisionHeightfield(3, 3, 1.0, make([]m.Real, 9)))
+	w.AddConstraint(NewHingeJoint(spheres[0].Body, cube.Body, m.Vector3{1.0, 1.0, 0.0}, m.Vector3{0.0, 0.0, 1.0}, 0.01))
+	w.AddJoint(NewJoint(spheres[1].Body, m.Vector3{}, nil, m.Vector3{0.0, 3.0, 0.0}, 0.5))
+
+	var buffer bytes.Buffer
+	if err := w.ExportGLTF(&buffer); err != nil {
+		t.Fatalf("Failed to export the world: %v", err)
+	}
+	var doc gltfDocument
+	if err := json.Unmarshal(buffer.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to read the exported glTF: %v", err)
+	}
+
+	// a root, then a node for each body, collider and joint end
+	expected := 1 + len(w.Bodies) + len(w.Colliders) + 2*2
+	if len(doc.Nodes) != expected {
+		t.Errorf("Exported %d nodes; expected %d", len(doc.Nodes), expected)
+	}
+	var colliders, motions, joints int
+	for _, node := range doc.Nodes {
+		var physics gltfPhysicsNode
+		data, _ := json.Marshal(node.Extensions[gltfPhysicsExtension])
+		json.Unmarshal(data, &physics)
+		if physics.Collider != nil {
+			colliders++
+		}
+		if physics.Motion != nil {
+			motions++
+		}
+		if physics.Joint != nil {
+			joints++
+		}
+	}
+	if colliders != len(w.Colliders) || motions != len(w.Bodies) || joints != 2 {
+		t.Errorf("Exported %d colliders, %d motions and %d joints", colliders, motions, joints)
+	}
+
+	// the cube's node keeps its transform
+	cubeNode := doc.Nodes[1+len(w.Bodies)-1]
+	q := cube.Body.Orientation
+	if *cubeNode.Translation != [3]float64{3.0, 1.0, 0.0} || *cubeNode.Rotation != [4]float64{float64(q[1]), float64(q[2]), float64(q[3]), float64(q[0])} {
+		t.Errorf("Exported cube has the wrong transform: %v %v", *cubeNode.Translation, *cubeNode.Rotation)
+	}
+
+	// the embedded buffer holds every buffer view
+	if len(doc.Buffers) != 1 {
+		t.Fatalf("Exported %d buffers; expected 1", len(doc.Buffers))
+	}
+	end := 0
+	for _, view := range doc.BufferViews {
+		if view.ByteOffset+view.ByteLength > end {
+			end = view.ByteOffset + view.ByteLength
+		}
+	}
+	if end != doc.Buffers[0].ByteLength {
+		t.Errorf("Buffer views end at %d but the buffer is %d bytes", end, doc.Buffers[0].ByteLength)
+	}
+
+	// a joint frame's rotation turns X into the axis and Y into the reference
+	axis, reference := m.Vector3{0.0, 0.0, 2.0}, m.Vector3{1.0, 0.0, 0.5}
+	frame := gltfFrame(&axis, &reference)
+	x, y := frame.Rotate(&m.Vector3{1.0, 0.0, 0.0}), frame.Rotate(&m.Vector3{0.0, 1.0, 0.0})
+	if x.Dot(&m.Vector3{0.0, 0.0, 1.0}) < 0.999 || y.Dot(&m.Vector3{1.0, 0.0, 0.0}) < 0.999 {
+		t.Errorf("Joint frame turns X into %v and Y into %v", x, y)
+	}
+}
+
+// countingDrawer counts the debug drawing of each category.
+type countingDrawer map[DebugCategory]int
+
+func (d countingDrawer) DrawLine(from *m.Vector3, to *m.Vector3, category DebugCategory) {
+	d[category]++
+}
+
+func (d countingDrawer) DrawPoint(point *m.Vector3, size m.Real, category DebugCategory) {
+	d[category]++
+}
+
+func (d countingDrawer) DrawText(position *m.Vector3, text string, category DebugCategory) {
+	d[category]++
+}
+
+func TestWorldDebugDraw(t *testing.T) {
+	w, spheres := newTestPile()
+	w.AddConstraint(NewHingeJoint(spheres[0].Body, spheres[1].Body, m.Vector3{0.0, 1.0, 0.0}, m.Vector3{0.0, 0.0, 1.0}, 0.01))
+	spheres[5].Body.SetAwake(false)
+
+	drawer := countingDrawer{}
+	w.DebugDrawer = drawer
+	w.DebugDraw = DebugColliders | DebugContacts | DebugJoints
+	for i := 0; i < 30; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	for _, category := range []DebugCategory{DebugColliders, DebugContacts, DebugJoints} {
+		if drawer[category] == 0 {
+			t.Errorf("Category %d wasn't drawn", category)
+		}
+	}
+	if drawer[DebugBounds] != 0 || drawer[DebugSleep] != 0 {
+		t.Errorf("Categories that weren't turned on were drawn")
+	}
+
+	// the sleeping sphere and the bounds only show up when asked for
+	drawer = countingDrawer{}
+	w.DrawDebug(drawer, DebugBounds|DebugSleep)
+	if drawer[DebugBounds] != 12*(len(w.Colliders)-1) {
+		t.Errorf("Drew %d lines of bounds; expected %d", drawer[DebugBounds], 12*(len(w.Colliders)-1))
+	}
+	if drawer[DebugSleep] == 0 || drawer[DebugColliders] != 0 {
+		t.Errorf("Sleeping bodies weren't drawn on their own")
+	}
+}
+
+func TestWorldHeatmap(t *testing.T) {
+	// a sphere resting on the ground piles its contacts up in the cell below
+	// its center, where the world feeds them into its heatmap
+	w := NewWorld()
+	w.Heatmap = NewContactHeatmap(1.0)
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	w.AddCollider(newTestSphere(m.Vector3{0.5, 0.49, 0.5}))
+	for i := 0; i < 10; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	if w.Heatmap.Steps != 10 {
+		t.Errorf("The heatmap was fed %d steps; expected 10", w.Heatmap.Steps)
+	}
+	cells := w.Heatmap.Cells()
+	if len(cells) != 1 || cells[0] != (HeatmapCell{0, -1, 0}) || w.Heatmap.Count(cells[0]) < 10 {
+		t.Fatalf("The heatmap had contacts in the cells %v; expected at least 10 in {0, -1, 0}", cells)
+	}
+
+	// each cell is drawn as a box and a label
+	drawer := countingDrawer{}
+	w.DrawDebug(drawer, DebugHeatmap)
+	if drawer[DebugHeatmap] != 13 {
+		t.Errorf("Drew %d parts of the heatmap; expected 13", drawer[DebugHeatmap])
+	}
+}
+
+func TestWorldEnergyDiagnostics(t *testing.T) {
+	w, spheres := newTestPile()
+	w.Diagnostics = true
+	grew := 0
+	w.OnEnergyGrowth = func(previous *EnergyReport, current *EnergyReport) {
+		grew++
+	}
+	for i := 0; i < 60; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	if grew != 0 {
+		t.Errorf("The energy of a pile settling under gravity grew %d times", grew)
+	}
+
+	// the islands add up to the whole world
+	report := w.GetEnergy()
+	var total Energy
+	bodies := 0
+	for _, island := range report.Islands {
+		total.Kinetic += island.Kinetic
+		total.Potential += island.Potential
+		bodies += len(island.Bodies)
+	}
+	if bodies != len(spheres) {
+		t.Errorf("The islands hold %d bodies; expected %d", bodies, len(spheres))
+	}
+	if !m.RealEqual(total.Kinetic, report.World.Kinetic) || !m.RealEqual(total.Potential, report.World.Potential) {
+		t.Errorf("The energy of the islands doesn't add up to the world's")
+	}
+
+	// a force pushing a body up adds energy
+	w.Forces = func(body *RigidBody) {
+		if body == spheres[0].Body {
+			body.AddForce(&m.Vector3{0.0, 100.0, 0.0})
+		}
+	}
+	w.Step(1.0 / 60.0)
+	if grew != 1 {
+		t.Errorf("A force adding energy wasn't reported")
+	}
+
+	w.Diagnostics = false
+	w.Step(1.0 / 60.0)
+	if w.GetEnergy() != nil {
+		t.Errorf("Energy was measured without Diagnostics set")
+	}
+}
+
+// recordingTracer keeps the trace of the contact resolver.
+type recordingTracer struct {
+	iterations []SolverIteration
+	summaries  []SolverSummary
+}
+
+func (r *recordingTracer) TraceIteration(it *SolverIteration) {
+	r.iterations = append(r.iterations, *it)
+}
+
+func (r *recordingTracer) TraceSolve(summary *SolverSummary) {
+	r.summaries = append(r.summaries, *summary)
+}
+
+func TestWorldSolverTrace(t *testing.T) {
+	w, _ := newTestPile()
+	tracer := new(recordingTracer)
+	w.SolverTrace = tracer
+	for i := 0; i < 60; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	if len(tracer.summaries) == 0 {
+		t.Fatalf("The resolver wasn't traced")
+	}
+
+	var passes [2]int
+	for _, it := range tracer.iterations {
+		passes[it.Pass]++
+		if it.Contact == nil || it.Residual <= 0.0 {
+			t.Errorf("Iteration %d of the %s pass is missing its contact or residual", it.Iteration, it.Pass)
+		}
+	}
+	var summed [2]int
+	for _, s := range tracer.summaries {
+		summed[PassPosition] += s.Iterations[PassPosition]
+		summed[PassVelocity] += s.Iterations[PassVelocity]
+		if s.WorstContact == nil || s.Contacts == 0 {
+			t.Errorf("A summary is missing its worst contact")
+		}
+	}
+	if passes != summed {
+		t.Errorf("Traced %v iterations but the summaries count %v", passes, summed)
+	}
+
+	// the logger writes a line for each run of the resolver
+	var out bytes.Buffer
+	w.SolverTrace = NewSolverLogger(log.New(&out, "", 0))
+	w.Step(1.0 / 60.0)
+	if lines := strings.Count(out.String(), "\n"); lines != 1 {
+		t.Errorf("The logger wrote %d lines; expected 1", lines)
+	}
+}
+
+func TestWorldProfilePhases(t *testing.T) {
+	plain, _ := newTestPile()
+	profiled, _ := newTestPile()
+	profiled.Profile = true
+	profiled.SetBroadphase(NewSweepAndPruneBroadphase())
+
+	var out bytes.Buffer
+	if err := trace.Start(&out); err != nil {
+		t.Fatalf("Failed to start the tracer: %v", err)
+	}
+	pprof.Do(context.Background(), pprof.Labels("test", "profile"), func(ctx context.Context) {
+		for i := 0; i < 30; i++ {
+			plain.Step(1.0 / 60.0)
+			profiled.StepContext(ctx, 1.0/60.0)
+		}
+	})
+	trace.Stop()
+
+	if out.Len() == 0 {
+		t.Errorf("Nothing was traced")
+	}
+	if plain.Checksum() != profiled.Checksum() {
+		t.Errorf("Profiling changed the simulation")
+	}
+}
+
+func TestWorldAdaptiveSolver(t *testing.T) {
+	// contacts that converge easily bring the iterations down
+	w, _ := newTestPile()
+	w.AdaptiveSolver = true
+	for i := 0; i < 60; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	if w.GetSolverIterations() >= solverIterationsPerContact {
+		t.Errorf("The iterations stayed at %d for a settling pile", w.GetSolverIterations())
+	}
+
+	// a tolerance that can't be met raises them up to the cap
+	w, _ = newTestPile()
+	tracer := new(recordingTracer)
+	w.AdaptiveSolver = true
+	w.SolverTolerance = 0.0
+	w.MaxSolverIterations = 64
+	w.SolverTrace = tracer
+	for i := 0; i < 60; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	raised := false
+	for _, s := range tracer.summaries {
+		if s.MaxIterations > 64 {
+			t.Fatalf("The resolver was allowed %d iterations, over the cap", s.MaxIterations)
+		}
+		if s.MaxIterations > s.Contacts*solverIterationsPerContact {
+			raised = true
+		}
+	}
+	if !raised {
+		t.Errorf("The iterations weren't raised when the resolver couldn't converge")
+	}
+
+	// without a cap they still stop doubling, even when nothing converges
+	w, _ = newTestPile()
+	w.AdaptiveSolver = true
+	w.SolverTolerance = -1.0
+	w.MaxSolverIterations = 0
+	most := 0
+	for i := 0; i < 30; i++ {
+		w.Step(1.0 / 60.0)
+		got := w.GetSolverIterations()
+		if got > maxSolverIterationsPerContact {
+			t.Fatalf("The uncapped iterations went up to %d per contact; expected at most %d", got, maxSolverIterationsPerContact)
+		}
+		if got > most {
+			most = got
+		}
+	}
+	if most != maxSolverIterationsPerContact {
+		t.Errorf("The uncapped iterations went up to %d per contact; expected %d", most, maxSolverIterationsPerContact)
+	}
+}
+
+func TestWorldStats(t *testing.T) {
+	w, spheres := newTestPile()
+	var contacts []*Contact
+	for i := 0; i < 30; i++ {
+		contacts = w.Step(1.0 / 60.0)
+	}
+	stats := w.GetStats()
+	if stats.Bodies != len(spheres) || stats.Colliders != len(spheres)+1 {
+		t.Errorf("Counted %d bodies and %d colliders; expected %d and %d", stats.Bodies, stats.Colliders, len(spheres), len(spheres)+1)
+	}
+	if stats.Pairs != (len(spheres)+1)*len(spheres)/2 {
+		t.Errorf("Counted %d pairs without a broadphase; expected every pair", stats.Pairs)
+	}
+	if len(contacts) == 0 || stats.Contacts != len(contacts) {
+		t.Fatalf("Counted %d contacts; expected %d", stats.Contacts, len(contacts))
+	}
+	if stats.SolverIterations[PassPosition] == 0 || stats.SolverIterations[PassVelocity] == 0 {
+		t.Errorf("The resolver iterations weren't counted: %v", stats.SolverIterations)
+	}
+
+	// a broadphase only counts the pairs it finds
+	w.SetBroadphase(NewSweepAndPruneBroadphase())
+	w.Step(1.0 / 60.0)
+	if stats = w.GetStats(); stats.Pairs >= (len(spheres)+1)*len(spheres)/2 {
+		t.Errorf("Counted %d pairs with a broadphase", stats.Pairs)
+	}
+}
+
+func TestWorldStepDump(t *testing.T) {
+	w, spheres := newTestPile()
+	var out bytes.Buffer
+	w.Dump = NewStepDump(&out, DumpCSV)
+	contacts := 0
+	for i := 0; i < 30; i++ {
+		contacts += len(w.Step(1.0 / 60.0))
+	}
+	if err := w.Dump.Flush(); err != nil {
+		t.Fatalf("Failed to dump the steps: %v", err)
+	}
+
+	reader := csv.NewReader(&out)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read the dump: %v", err)
+	}
+	kinds := make(map[string]int)
+	for _, record := range records[2:] {
+		kinds[record[2]]++
+	}
+	if kinds["body"] != 30*len(spheres) || kinds["contact"] != contacts {
+		t.Errorf("Dumped %d bodies and %d contacts; expected %d and %d", kinds["body"], kinds["contact"], 30*len(spheres), contacts)
+	}
+
+	// the JSON has a line for each step that reads back exactly
+	out.Reset()
+	w.Dump = NewStepDump(&out, DumpJSON)
+	w.Step(1.0 / 60.0)
+	w.Step(1.0 / 60.0)
+	decoder := json.NewDecoder(&out)
+	var step DumpStep
+	for i := 1; i <= 2; i++ {
+		if err := decoder.Decode(&step); err != nil {
+			t.Fatalf("Failed to read step %d: %v", i, err)
+		}
+		if step.Step != i || len(step.Bodies) != len(spheres) {
+			t.Errorf("Read step %d with %d bodies; expected step %d with %d", step.Step, len(step.Bodies), i, len(spheres))
+		}
+	}
+	if step.Bodies[0].Position != spheres[0].Body.Position {
+		t.Errorf("The position didn't read back exactly")
+	}
+}
+
+func TestWorldReusesContacts(t *testing.T) {
+	w, _ := newTestPile()
+	for i := 0; i < 120; i++ {
+		w.Step(1.0 / 60.0)
+	}
+
+	// once the pile has settled each step should reuse the contacts of the
+	// last one rather than allocating new ones
+	made := len(w.contactPool.contacts)
+	if made == 0 {
+		t.Fatalf("The settled pile should have contacts")
+	}
+	last := make(map[*Contact]bool)
+	for _, c := range w.Step(1.0 / 60.0) {
+		last[c] = true
+	}
+	for _, c := range w.Step(1.0 / 60.0) {
+		if !last[c] {
+			t.Errorf("Contact %p wasn't reused from the last step", c)
+		}
+	}
+	if len(w.contactPool.contacts) != made {
+		t.Errorf("The pool grew from %d to %d contacts", made, len(w.contactPool.contacts))
+	}
+
+	// the narrowphase doesn't allocate at all with a warm pool
+	cube := NewCollisionCube(nil, m.Vector3{0.5, 0.5, 0.5})
+	cube.Body.Position = m.Vector3{0.0, 0.4, 0.0}
+	cube.Body.CalculateDerivedData()
+	cube.CalculateDerivedData()
+	plane := NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0)
+	var pool contactPool
+	allocs := testing.AllocsPerRun(100, func() {
+		_, pool.buffer = checkForCollisions(cube, plane, pool.reset(), &pool)
+	})
+	if allocs != 0 || len(pool.buffer) != 4 {
+		t.Errorf("Pooled narrowphase made %d contacts with %v allocations; expected 4 with none", len(pool.buffer), allocs)
+	}
+}
+
+func TestContactCollector(t *testing.T) {
+	cube := NewCollisionCube(nil, m.Vector3{0.5, 0.5, 0.5})
+	cube.Body.Position = m.Vector3{0.0, 0.4, 0.0}
+	cube.Body.CalculateDerivedData()
+	cube.CalculateDerivedData()
+	sphere := newTestSphere(m.Vector3{0.0, 1.2, 0.0})
+	plane := NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0)
+
+	_, expected := CheckForCollisions(cube, plane, nil)
+	_, expected = CheckForCollisions(sphere, cube, expected)
+
+	cc := NewContactCollector()
+	collect := func() {
+		cc.Reset()
+		cc.Collect(cube, plane)
+		cc.Collect(sphere, cube)
+	}
+	collect()
+	if len(cc.Contacts) != len(expected) {
+		t.Fatalf("Collected %d contacts; expected %d", len(cc.Contacts), len(expected))
+	}
+	for i, c := range cc.Contacts {
+		if *c != *expected[i] {
+			t.Errorf("Collected contact %d differs: %v; expected %v", i, *c, *expected[i])
+		}
+	}
+	if allocs := testing.AllocsPerRun(100, collect); allocs != 0 {
+		t.Errorf("Collecting into a warm collector made %v allocations", allocs)
+	}
+
+	// the values are appended to the caller's buffer
+	buffer := make([]Contact, 0, 16)
+	found, buffer := AppendContacts(cube, plane, buffer)
+	found2, buffer := AppendContacts(sphere, cube, buffer)
+	if !found || !found2 || len(buffer) != len(expected) {
+		t.Fatalf("Appended %d contacts; expected %d", len(buffer), len(expected))
+	}
+	for i := range buffer {
+		if buffer[i] != *expected[i] {
+			t.Errorf("Appended contact %d differs: %v; expected %v", i, buffer[i], *expected[i])
+		}
+	}
+	if found, buffer := AppendContacts(plane, plane, buffer[:0]); found || len(buffer) != 0 {
+		t.Errorf("Planes shouldn't touch but got %d contacts", len(buffer))
+	}
+}
+
+func TestWorldPresentation(t *testing.T) {
+	w, spheres := newTestPile()
+	w.Presentation = NewPresentation()
+	if state := w.Presentation.Read(); state.Step != 0 || len(state.Poses) != 0 {
+		t.Errorf("Nothing should be published before the first step: %v", state)
+	}
+
+	// read on another goroutine while the world steps; each state read has
+	// to be whole and the steps can only go forwards
+	const steps = 200
+	done := make(chan struct{})
+	failed := make(chan string, 1)
+	go func() {
+		defer close(failed)
+		var last uint64
+		for {
+			state := w.Presentation.Read()
+			if state.Step < last {
+				failed <- "the presentation went back a step"
+				return
+			}
+			if state.Step > 0 && len(state.Poses) != len(spheres) {
+				failed <- "a state didn't hold every body"
+				return
+			}
+			last = state.Step
+			select {
+			case <-done:
+				return
+			default:
+			}
+		}
+	}()
+	for i := 0; i < steps; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	close(done)
+	if msg, ok := <-failed; ok {
+		t.Fatal(msg)
+	}
+
+	state := w.Presentation.Read()
+	if state.Step != steps || state.Duration != 1.0/60.0 {
+		t.Fatalf("The last state read was step %d; expected %d", state.Step, steps)
+	}
+	for i, s := range spheres {
+		pose := &state.Poses[i]
+		id, _ := w.GetBodyID(s.Body)
+		if pose.ID != id || pose.Position != s.Body.Position || pose.Orientation != s.Body.Orientation {
+			t.Errorf("Pose %d doesn't match its body", i)
+		}
+		if transform := pose.InterpolatedTransform(0.5); transform != s.Body.InterpolatedTransform(0.5) {
+			t.Errorf("Pose %d interpolates differently to its body", i)
+		}
+	}
+}
+
+func TestWorldTransformListener(t *testing.T) {
+	w := NewWorld()
+	falling := newTestSphere(m.Vector3{0.0, 5.0, 0.0})
+	resting := newTestSphere(m.Vector3{3.0, 5.0, 0.0})
+	resting.Body.SetAwake(false)
+	w.AddCollider(falling)
+	w.AddCollider(resting)
+
+	var fallingCalls, restingCalls int
+	var position m.Vector3
+	var orientation m.Quat
+	falling.Body.OnTransform = func(p m.Vector3, o m.Quat) {
+		fallingCalls++
+		position, orientation = p, o
+	}
+	resting.Body.OnTransform = func(p m.Vector3, o m.Quat) {
+		restingCalls++
+	}
+
+	for i := 0; i < 10; i++ {
+		w.Step(1.0 / 60.0)
+		if fallingCalls != i+1 {
+			t.Fatalf("The falling body was notified %d times in %d steps", fallingCalls, i+1)
+		}
+		if position != falling.Body.Position || orientation != falling.Body.Orientation {
+			t.Fatalf("The falling body was notified of the wrong pose in step %d", i+1)
+		}
+	}
+	if restingCalls != 1 {
+		t.Errorf("The body that didn't move was notified %d times; expected just the first step", restingCalls)
+	}
+
+	// the listener stays with the body it was set on
+	clone := falling.Body.Clone()
+	if clone.OnTransform != nil {
+		t.Error("Clone kept the listener of the body")
+	}
+	if _, err := json.Marshal(w); err != nil {
+		t.Errorf("A world with listeners failed to marshal: %v", err)
+	}
+}
+
+func TestWorldTransformListenerLoadState(t *testing.T) {
+	w := NewWorld()
+	s := newTestSphere(m.Vector3{0.0, 1.0, 0.0})
+	s.Body.GravityScale = 0.0
+	s.Body.SetAwake(false)
+	w.AddCollider(s)
+
+	var last m.Vector3
+	s.Body.OnTransform = func(p m.Vector3, o m.Quat) {
+		last = p
+	}
+	w.Step(1.0 / 60.0)
+	var state WorldState
+	w.SaveState(&state)
+
+	// a listener set after saving is kept when the state is loaded
+	var calls int
+	s.Body.OnTransform = func(p m.Vector3, o m.Quat) {
+		calls++
+		last = p
+	}
+	s.Body.Position = m.Vector3{5.0, 1.0, 0.0}
+	s.Body.CalculateDerivedData()
+	w.Step(1.0 / 60.0)
+	if calls != 1 || last != s.Body.Position {
+		t.Fatalf("The listener wasn't told about the body being moved")
+	}
+
+	// and is told about the pose the body went back to, even though it was
+	// notified of it before the state was saved
+	if err := w.LoadState(&state); err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if s.Body.OnTransform == nil {
+		t.Fatal("LoadState dropped the listener")
+	}
+	w.Step(1.0 / 60.0)
+	if calls != 2 || last != (m.Vector3{0.0, 1.0, 0.0}) {
+		t.Errorf("After loading the listener was called %d times and left at %v", calls, last)
+	}
+}
+
+func TestRigidBodyFloat32Transform(t *testing.T) {
+	body := NewRigidBody()
+	body.Position = m.Vector3{1.0, 2.0, 3.0}
+	body.Orientation = m.QuatFromAxis(0.5, 0.0, 1.0, 0.0)
+	body.CalculateDerivedData()
+
+	if p := body.Position32(); p != [3]float32{1.0, 2.0, 3.0} {
+		t.Errorf("Position32 returned %v", p)
+	}
+	q := body.Orientation32()
+	for i := range q {
+		if q[i] != float32(body.Orientation[i]) {
+			t.Errorf("Orientation32 returned %v for %v", q, body.Orientation)
+			break
+		}
+	}
+
+	// the matrix has to move a point to the same place the transform does
+	mat := body.TransformMat4()
+	transform := body.GetTransform()
+	local := m.Vector3{0.5, -1.0, 2.0}
+	expected := transform.MulVector3(&local)
+	for row := 0; row < 3; row++ {
+		v := mat[row]*float32(local[0]) + mat[4+row]*float32(local[1]) + mat[8+row]*float32(local[2]) + mat[12+row]
+		if diff := float64(v) - float64(expected[row]); diff > 1e-5 || diff < -1e-5 {
+			t.Errorf("TransformMat4 moved the point to %f on axis %d; expected %f", v, row, expected[row])
+		}
+	}
+	if mat[3] != 0.0 || mat[7] != 0.0 || mat[11] != 0.0 || mat[15] != 1.0 {
+		t.Errorf("TransformMat4 has the wrong bottom row: %v", mat)
+	}
+}
+
+func TestWorldImpactEvents(t *testing.T) {
+	w := NewWorld()
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	box := NewCollisionCube(nil, m.Vector3{0.5, 0.5, 0.5})
+	box.SetDensity(1.0)
+	box.Body.Position = m.Vector3{0.0, 3.0, 0.0}
+	box.Body.CalculateDerivedData()
+	box.CalculateDerivedData()
+	w.AddCollider(box)
+	w.MinImpactImpulse = 0.5
+
+	type hit struct {
+		step   int
+		impact Impact
+	}
+	var hits []hit
+	var step int
+	w.Events.Subscribe(EventImpact, 0, func(e *Event) {
+		hits = append(hits, hit{step, *e.Impact})
+	})
+	var speed m.Real
+	for step = 0; step < 180; step++ {
+		if len(hits) == 0 {
+			speed = -box.Body.Velocity[1]
+		}
+		w.Step(1.0 / 60.0)
+	}
+
+	if len(hits) == 0 {
+		t.Fatal("No impact was sent for the box landing")
+	}
+	first := hits[0].impact
+	if first.Bodies[0] != box.Body || first.Bodies[1] != nil {
+		t.Errorf("The impact was between the wrong bodies: %v", first.Bodies)
+	}
+	if first.Contacts != 4 {
+		t.Errorf("The impact gathered %d contacts; expected the 4 corners of the box", first.Contacts)
+	}
+	if first.Impulse <= w.MinImpactImpulse || first.Normal[1] < 0.99 {
+		t.Errorf("The impact had an impulse of %f along %v", first.Impulse, first.Normal)
+	}
+	if diff := first.Speed - speed; diff > 0.5 || diff < -0.5 {
+		t.Errorf("The impact had a speed of %f; expected about %f", first.Speed, speed)
+	}
+	if first.RelativeVelocity[1] > -speed*0.9 {
+		t.Errorf("The impact had a relative velocity of %v before it was resolved", first.RelativeVelocity)
+	}
+
+	// the box resting on the ground stays under the threshold
+	if last := hits[len(hits)-1].step; last > 120 {
+		t.Errorf("Impacts were still sent in step %d with the box at rest", last)
+	}
+}
+
+func TestSensorOverlapping(t *testing.T) {
+	w := NewWorld()
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	plate := NewSensor(&BoxVolume{Center: m.Vector3{0.0, 0.5, 0.0}, HalfSize: m.Vector3{2.0, 0.5, 2.0}})
+	w.AddSensor(plate)
+
+	on := []*CollisionSphere{newTestSphere(m.Vector3{-1.0, 2.0, 0.0}), newTestSphere(m.Vector3{1.0, 3.0, 0.0})}
+	off := newTestSphere(m.Vector3{5.0, 2.0, 0.0})
+	for _, s := range append(on, off) {
+		w.AddCollider(s)
+	}
+	if len(plate.Overlapping()) != 0 {
+		t.Fatal("The plate had bodies on it before the first step")
+	}
+	for i := 0; i < 120; i++ {
+		w.Step(1.0 / 60.0)
+	}
+
+	standing := plate.Overlapping()
+	if len(standing) != len(on) {
+		t.Fatalf("%d bodies were standing on the plate; expected %d", len(standing), len(on))
+	}
+	for _, s := range on {
+		if !plate.IsOverlapping(s.Body) {
+			t.Error("A body resting on the plate wasn't overlapping it")
+		}
+	}
+	if plate.IsOverlapping(off.Body) {
+		t.Error("The body beside the plate was overlapping it")
+	}
+
+	// the copy is kept as it was when the bodies leave
+	for _, s := range on {
+		s.Body.Position[0] += 10.0
+		s.Body.CalculateDerivedData()
+	}
+	w.Step(1.0 / 60.0)
+	if len(plate.Overlapping()) != 0 || len(standing) != len(on) || standing[0] != on[0].Body {
+		t.Errorf("The bodies that left were still overlapping, or the copy changed: %v", standing)
+	}
+}
+
+func TestWorldTimeScale(t *testing.T) {
+	// slow motion takes shorter steps and fast forward splits each step,
+	// so both match a world stepped at those rates by hand
+	for _, scale := range []m.Real{0.5, 3.0} {
+		scaled, scaledSpheres := newTestPile()
+		scaled.TimeScale = scale
+		manual, manualSpheres := newTestPile()
+		for i := 0; i < 90; i++ {
+			scaled.Step(1.0 / 60.0)
+			if scale < 1.0 {
+				manual.Step(1.0 / 120.0)
+			} else {
+				for j := 0; j < 3; j++ {
+					manual.Step(1.0 / 60.0)
+				}
+			}
+		}
+		for i := range scaledSpheres {
+			if scaledSpheres[i].Body.Position != manualSpheres[i].Body.Position {
+				t.Errorf("Sphere %d at a time scale of %.1f ended up at %v; expected %v", i, scale,
+					scaledSpheres[i].Body.Position, manualSpheres[i].Body.Position)
+			}
+		}
+	}
+
+	// damping goes by the time stepped whatever the scale
+	for _, scale := range []m.Real{0.25, 1.0, 4.0} {
+		w := NewWorld()
+		w.TimeScale = scale
+		s := newTestSphere(m.Vector3{})
+		s.Body.Acceleration = m.Vector3{}
+		s.Body.LinearDamping = 0.5
+		s.Body.CanSleep = false
+		s.Body.Velocity = m.Vector3{10.0, 0.0, 0.0}
+		w.AddCollider(s)
+		for i := 0; i < int(60.0/scale); i++ {
+			w.Step(1.0 / 60.0)
+		}
+		if speed := s.Body.Velocity[0]; speed < 4.999 || speed > 5.001 {
+			t.Errorf("After a second at a time scale of %.2f the speed was %f; expected 5", scale, speed)
+		}
+	}
+}
+
+func TestWorldTimeScaleFastForward(t *testing.T) {
+	w := NewWorld()
+	w.TimeScale = 3.0
+	w.Presentation = NewPresentation()
+	s := newTestSphere(m.Vector3{})
+	s.Body.Acceleration = m.Vector3{}
+	s.Body.LinearDamping = 1.0
+	s.Body.CanSleep = false
+	s.Body.Velocity = m.Vector3{6.0, 0.0, 0.0}
+	w.AddCollider(s)
+	w.AddCollider(newTestSphere(m.Vector3{0.0, 5.0, 0.0}))
+	w.Step(1.0 / 60.0)
+
+	// the step is published once, blending over all three substeps
+	state := w.Presentation.Read()
+	if state.Step != 1 || m.RealAbs(state.Duration-3.0/60.0) > 1e-6 {
+		t.Errorf("The presentation had step %d lasting %v; expected 1 lasting %v", state.Step, state.Duration, 3.0/60.0)
+	}
+	pose := state.Poses[0]
+	if pose.PrevPosition[0] != 0.0 || m.RealAbs(pose.Position[0]-0.3) > 1e-5 {
+		t.Errorf("The presentation blended from %v to %v; expected from 0 to 0.3", pose.PrevPosition[0], pose.Position[0])
+	}
+	halfway := s.Body.InterpolatedTransform(0.5)
+	if x := halfway.GetPosition()[0]; m.RealAbs(x-0.15) > 1e-5 {
+		t.Errorf("Halfway through the step the body was at %v; expected 0.15", x)
+	}
+
+	// the stats count the pairs of every substep
+	if pairs := w.GetStats().Pairs; pairs != 3 {
+		t.Errorf("The stats counted %d pairs; expected 3", pairs)
+	}
+
+	// a huge time scale is held to the most substeps
+	w.TimeScale = 1000.0
+	w.Step(1.0 / 60.0)
+	if x := s.Body.Position[0]; m.RealAbs(x-(0.3+m.Real(maxTimeScaleSubsteps)*0.1)) > 1e-4 {
+		t.Errorf("A step at a huge time scale moved the body to %v; expected %v", x, 0.3+m.Real(maxTimeScaleSubsteps)*0.1)
+	}
+}
+
+// newTestRagdollPose returns the bones of a ragdoll standing with its feet at
+// the position given.
+func newTestRagdollPose(feet m.Vector3) []RagdollBoneTransform {
+	var up m.Quat
+	up.SetIdentity()
+	down := m.QuatFromAxis(math.Pi, 1.0, 0.0, 0.0)
+	bone := func(x, y m.Real, orientation m.Quat, length, radius m.Real) RagdollBoneTransform {
+		position := feet
+		position.Add(&m.Vector3{x, y, 0.0})
+		return RagdollBoneTransform{Position: position, Orientation: orientation, Length: length, Radius: radius}
+	}
+	return []RagdollBoneTransform{
+		BonePelvis:        bone(0.0, 0.95, up, 0.2, 0.15),
+		BoneChest:         bone(0.0, 1.15, up, 0.4, 0.17),
+		BoneHead:          bone(0.0, 1.55, up, 0.25, 0.11),
+		BoneUpperArmLeft:  bone(0.25, 1.5, down, 0.3, 0.06),
+		BoneLowerArmLeft:  bone(0.25, 1.2, down, 0.28, 0.05),
+		BoneUpperArmRight: bone(-0.25, 1.5, down, 0.3, 0.06),
+		BoneLowerArmRight: bone(-0.25, 1.2, down, 0.28, 0.05),
+		BoneUpperLegLeft:  bone(0.1, 0.95, down, 0.45, 0.08),
+		BoneLowerLegLeft:  bone(0.1, 0.5, down, 0.45, 0.07),
+		BoneUpperLegRight: bone(-0.1, 0.95, down, 0.45, 0.08),
+		BoneLowerLegRight: bone(-0.1, 0.5, down, 0.45, 0.07),
+	}
+}
+
+func TestWorldFreezeAssemblyConstraints(t *testing.T) {
+	w := NewWorld()
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	r, err := NewRagdoll(newTestRagdollPose(m.Vector3{0.0, 0.5, 0.0}), 70.0)
+	if err != nil {
+		t.Fatalf("Failed to build the ragdoll: %v", err)
+	}
+	w.AddRagdoll(r)
+	for i := 0; i < 30; i++ {
+		w.Step(1.0 / 60.0)
+	}
+
+	a := r.Assembly()
+	if len(a.Bodies) != int(RagdollBoneCount) || len(a.Constraints) != int(RagdollBoneCount)-1 {
+		t.Fatalf("The assembly has %d bodies and %d constraints", len(a.Bodies), len(a.Constraints))
+	}
+	w.FreezeAssembly(a, false)
+	if len(w.Constraints) != 0 {
+		t.Errorf("%d constraints were left in the world after freezing", len(w.Constraints))
+	}
+	for i := 0; i < 30; i++ {
+		w.Step(1.0 / 60.0)
+	}
+
+	w.ThawAssembly(a)
+	if len(w.Constraints) != len(a.Constraints) {
+		t.Fatalf("%d constraints came back after thawing; expected %d", len(w.Constraints), len(a.Constraints))
+	}
+	for i := 0; i < 30; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	for i, limb := range r.Limbs {
+		if y := limb.Body.Position[1]; math.IsNaN(float64(y)) || y < -0.5 {
+			t.Errorf("Limb %d ended up at a height of %f after thawing", i, y)
+		}
+	}
+}
+
+// ragdollLimitViolation returns how far past the limits of its joint the
+// bone of the ragdoll has turned, in radians.
+func ragdollLimitViolation(r *Ragdoll, bone RagdollBone) m.Real {
+	joint := ragdollJoints[bone]
+	if joint.hinge {
+		angle := r.Joints[bone].(*HingeJoint).State().Position
+		if angle < joint.lower {
+			return joint.lower - angle
+		} else if angle > joint.upper {
+			return angle - joint.upper
+		}
+		return 0.0
+	}
+
+	var worst m.Real
+	cone := r.Joints[bone].(*GenericJoint)
+	for axis, limit := range [3]m.Real{joint.swing, joint.twist, joint.swing} {
+		if over := m.RealAbs(cone.AngularState(axis).Position) - limit; over > worst {
+			worst = over
+		}
+	}
+	return worst
+}
+
+func TestRagdollLimits(t *testing.T) {
+	// dropped and thrown in different ways, the ragdoll lands in a heap
+	// without any joint turned far past its limits. The limits are held by
+	// contacts, so they give a little as the limbs hit the ground.
+	throws := []m.Vector3{{0.0, 0.0, 0.0}, {0.0, 0.0, 1.5}, {1.5, 0.0, 0.0}}
+	for _, height := range []m.Real{0.0, 1.0, 2.0} {
+		for _, throw := range throws {
+			w := NewWorld()
+			w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+			r, err := NewRagdoll(newTestRagdollPose(m.Vector3{0.0, height, 0.0}), 70.0)
+			if err != nil {
+				t.Fatalf("Failed to build the ragdoll: %v", err)
+			}
+			for _, limb := range r.Limbs {
+				limb.Body.Velocity = throw
+			}
+			w.AddRagdoll(r)
+
+			var worst m.Real
+			worstBone := BonePelvis
+			for i := 0; i < 300; i++ {
+				w.Step(1.0 / 60.0)
+				for bone := BoneChest; bone < RagdollBoneCount; bone++ {
+					if over := ragdollLimitViolation(r, bone); over > worst {
+						worst, worstBone = over, bone
+					}
+				}
+			}
+			if worst > 0.5 {
+				t.Errorf("Dropped from %v and thrown at %v, bone %d turned %v past its limits", height, throw, worstBone, worst)
+			}
+			for i, limb := range r.Limbs {
+				if y := limb.Body.Position[1]; math.IsNaN(float64(y)) || y < 0.0 || y > 0.5 {
+					t.Errorf("Dropped from %v and thrown at %v, limb %d ended up at a height of %v", height, throw, i, y)
+				}
+			}
+		}
+	}
+}
+
+func TestRagdollSelfCollision(t *testing.T) {
+	w := NewWorld()
+	r, err := NewRagdoll(newTestRagdollPose(m.Vector3{}), 70.0)
+	if err != nil {
+		t.Fatalf("Failed to build the ragdoll: %v", err)
+	}
+	w.AddRagdoll(r)
+	pelvis, chest := r.Limbs[BonePelvis], r.Limbs[BoneChest]
+
+	// the chest and pelvis overlap at the waist, but they're joined so they
+	// don't collide
+	if found, _ := CheckForCollisions(pelvis, chest, nil); !found {
+		t.Fatal("The pelvis and chest of the test pose don't overlap")
+	}
+	if contacts := w.checkPair(pelvis, chest, nil, nil); len(contacts) != 0 {
+		t.Errorf("The joined pelvis and chest made %d contacts", len(contacts))
+	}
+
+	// limbs that aren't joined still collide
+	arm := r.Limbs[BoneLowerArmLeft]
+	arm.Body.Position = pelvis.Body.Position
+	arm.Body.Position[0] += 0.1
+	arm.Body.CalculateDerivedData()
+	arm.CalculateDerivedData()
+	if contacts := w.checkPair(pelvis, arm, nil, nil); len(contacts) == 0 {
+		t.Error("The arm pushed into the pelvis made no contacts")
+	}
+
+	// once removed from the world the limbs collide with each other again
+	w.RemoveRagdoll(r)
+	if contacts := w.checkPair(pelvis, chest, nil, nil); len(contacts) == 0 {
+		t.Error("The pelvis and chest of a removed ragdoll made no contacts")
+	}
+
+	// while falling, no contact is reported between joined limbs
+	w = NewWorld()
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	r, _ = NewRagdoll(newTestRagdollPose(m.Vector3{0.0, 0.5, 0.0}), 70.0)
+	w.AddRagdoll(r)
+	joined := 0
+	w.Events.Subscribe(EventCollision, 0, func(e *Event) {
+		if r.filter.ignoresCollision(e.Bodies[0], e.Bodies[1]) {
+			joined++
+		}
+	})
+	for i := 0; i < 120; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	if joined != 0 {
+		t.Errorf("%d contacts were reported between joined limbs", joined)
+	}
+}
+
+// newTestPendulum creates an articulation hanging a chain of spheres, each a
+// unit apart along x, from a fixed anchor at the origin with joints turning
+// about z.
+func newTestPendulum(length int) (*Articulation, []*CollisionSphere) {
+	anchor := NewRigidBody()
+	anchor.SetInfiniteMass()
+	anchor.CalculateDerivedData()
+	a := NewArticulation(anchor, true)
+	var bobs []*CollisionSphere
+	parent := 0
+	for i := 0; i < length; i++ {
+		bob := newTestSphere(m.Vector3{m.Real(i + 1), 0.0, 0.0})
+		parent = a.AddRevolute(parent, bob.Body, m.Vector3{m.Real(i), 0.0, 0.0}, m.Vector3{0.0, 0.0, 1.0})
+		bobs = append(bobs, bob)
+	}
+	return a, bobs
+}
+
+func TestArticulationPendulum(t *testing.T) {
+	// a pendulum let go level with its anchor swings without the bob moving
+	// away from the anchor or the swing gaining or losing much energy
+	w := NewWorld()
+	a, bobs := newTestPendulum(1)
+	w.AddArticulation(a)
+	bob := bobs[0].Body
+
+	// the energy swaps between potential and kinetic a little unevenly
+	// with each step, but doesn't creep away
+	gravity := bob.GetGravity()
+	tolerance := 0.05 * bob.GetMass() * gravity.Magnitude()
+	var start Energy
+	start.AddBody(bob)
+	lowest := m.Real(0.0)
+	for i := 0; i < 600; i++ {
+		w.Step(1.0 / 60.0)
+		if r := bob.Position.Magnitude(); m.RealAbs(r-1.0) > 1e-4 {
+			t.Fatalf("The bob was %v from the anchor after %d steps; expected 1", r, i+1)
+		}
+		if bob.Position[2] != 0.0 {
+			t.Fatalf("The bob left the plane of the swing after %d steps: %v", i+1, bob.Position)
+		}
+		var e Energy
+		e.AddBody(bob)
+		if drift := m.RealAbs(e.Total() - start.Total()); drift > tolerance {
+			t.Fatalf("The energy of the pendulum drifted by %v after %d steps", drift, i+1)
+		}
+		if bob.Position[1] < lowest {
+			lowest = bob.Position[1]
+		}
+	}
+	if lowest > -0.99 {
+		t.Errorf("The pendulum only swung down to %v; expected it to pass below the anchor", lowest)
+	}
+
+	// the joint coordinate follows the angle of the bob
+	link := a.Links[1]
+	angle := m.Real(math.Atan2(float64(bob.Position[1]), float64(bob.Position[0])))
+	if m.RealAbs(link.Position-angle) > 1e-3 {
+		t.Errorf("The joint was at %v with the bob at an angle of %v", link.Position, angle)
+	}
+}
+
+func TestArticulationChain(t *testing.T) {
+	// the links of a chain stay a unit apart however it flails, and damping
+	// in the joints takes energy out
+	w := NewWorld()
+	a, bobs := newTestPendulum(4)
+	for _, l := range a.Links[1:] {
+		l.Damping = 0.5
+	}
+	w.AddArticulation(a)
+
+	energy := func() m.Real {
+		var e Energy
+		for _, bob := range bobs {
+			e.AddBody(bob.Body)
+		}
+		return e.Total()
+	}
+	gravity := bobs[0].Body.GetGravity()
+	tolerance := 0.05 * bobs[0].Body.GetMass() * gravity.Magnitude()
+	start := energy()
+	for i := 0; i < 600; i++ {
+		w.Step(1.0 / 60.0)
+		joint := m.Vector3{}
+		for j, bob := range bobs {
+			gap := bob.Body.Position
+			gap.Sub(&joint)
+			if r := gap.Magnitude(); m.RealAbs(r-1.0) > 1e-4 {
+				t.Fatalf("Link %d was %v from its joint after %d steps; expected 1", j+1, r, i+1)
+			}
+			joint = bob.Body.Position
+		}
+		if e := energy(); e > start+tolerance {
+			t.Fatalf("The energy of the damped chain grew from %v to %v after %d steps", start, e, i+1)
+		}
+	}
+	if lost := start - energy(); lost < 10.0 {
+		t.Errorf("The damped chain only lost %v energy in 10 seconds", lost)
+	}
+}
+
+func TestAnchoredBungeeNeverPushes(t *testing.T) {
+	anchor := m.Vector3{0.0, 10.0, 0.0}
+	bungee := NewAnchoredBungee(&m.Vector3{}, &anchor, 10.0, 2.0)
+	bungee.Damping = 5.0
+
+	// stretched, but flying back towards the anchor fast enough that the
+	// damping outweighs the stretch
+	body := NewRigidBody()
+	body.Position = m.Vector3{0.0, 7.0, 0.0}
+	body.Velocity = m.Vector3{0.0, 20.0, 0.0}
+	body.CalculateDerivedData()
+	bungee.UpdateForce(body, 1.0/60.0)
+	if force := body.forceAccum; force[1] < 0.0 {
+		t.Errorf("The bungee pushed the body away from the anchor with %v", force)
+	}
+
+	// while moving away it pulls with both the stretch and the damping
+	body.ClearAccumulators()
+	body.Velocity = m.Vector3{0.0, -1.0, 0.0}
+	bungee.UpdateForce(body, 1.0/60.0)
+	if force := body.forceAccum; force[1] <= 10.0 {
+		t.Errorf("The stretched bungee pulled with %v", force)
+	}
+}
+
+func TestWorldWrapSeam(t *testing.T) {
+	// two spheres on opposite sides of a world wrapping along x touch across
+	// the seam, which the broadphase finds through the ghost hanging over it
+	newWrapped := func(bp Broadphase) (*World, *CollisionSphere, *CollisionSphere) {
+		w := NewWorld()
+		w.Wrap = NewWrapBounds(&m.Vector3{-5.0, -5.0, -5.0}, &m.Vector3{5.0, 5.0, 5.0})
+		w.SetBroadphase(bp)
+		one := newTestSphere(m.Vector3{4.7, 0.0, 0.0})
+		two := newTestSphere(m.Vector3{-4.7, 0.1, 0.0})
+		far := newTestSphere(m.Vector3{0.0, 0.0, 0.0})
+		for _, s := range []*CollisionSphere{one, two, far} {
+			s.Body.GravityScale = 0.0
+			w.AddCollider(s)
+		}
+		return w, one, two
+	}
+
+	w, one, two := newWrapped(nil)
+	w.Step(1.0 / 60.0)
+	expected := len(w.GetContacts())
+	if expected == 0 {
+		t.Fatalf("The spheres didn't touch across the seam without a broadphase")
+	}
+	for _, bp := range []Broadphase{NewBruteForceBroadphase(), NewSweepAndPruneBroadphase()} {
+		w, one, two = newWrapped(bp)
+		w.Step(1.0 / 60.0)
+		contacts := w.GetContacts()
+		if len(contacts) != expected {
+			t.Fatalf("%T found %d contacts across the seam; expected %d", bp, len(contacts), expected)
+		}
+		for _, c := range contacts {
+			if c.Bodies[0] != one.Body && c.Bodies[0] != two.Body || c.Bodies[1] != one.Body && c.Bodies[1] != two.Body {
+				t.Fatalf("%T found a contact between bodies that don't touch", bp)
+			}
+		}
+
+		// the ghosts are never given out as colliders
+		query := Bounds{Min: m.Vector3{-6.0, -1.0, -1.0}, Max: m.Vector3{-4.0, 1.0, 1.0}}
+		for _, c := range w.QueryBounds(&query) {
+			if c != Collider(two) {
+				t.Errorf("%T gave %T for the bounds across the seam", bp, c)
+			}
+		}
+
+		// a sphere moved away from the seam loses its ghost, and the rest
+		// go when the world stops wrapping
+		w.SetBodyTransform(one.Body, &m.Vector3{2.0, 0.0, 0.0}, &one.Body.Orientation)
+		w.Step(1.0 / 60.0)
+		if _, ok := w.wrapGhosts[one]; ok || len(w.wrapGhosts) != 1 {
+			t.Errorf("%T kept ghosts for %d colliders after one left the seam", bp, len(w.wrapGhosts))
+		}
+		w.Wrap = nil
+		w.Step(1.0 / 60.0)
+		everywhere := InfiniteBounds()
+		if len(w.wrapGhosts) != 0 || len(w.QueryBounds(&everywhere)) != 3 {
+			t.Errorf("%T kept ghosts after the world stopped wrapping", bp)
+		}
+	}
+}
+
+func TestWorldDrag(t *testing.T) {
+	w := NewWorld()
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	wall := NewCollisionCube(nil, m.Vector3{0.25, 2.0, 2.0})
+	wall.Body.SetInfiniteMass()
+	wall.Body.GravityScale = 0.0
+	wall.Body.SetAwake(false)
+	wall.Body.Position = m.Vector3{2.0, 2.0, 0.0}
+	wall.Body.CalculateDerivedData()
+	wall.CalculateDerivedData()
+	w.AddCollider(wall)
+	sphere := newTestSphere(m.Vector3{0.0, 0.5, 0.0})
+	w.AddCollider(sphere)
+
+	down := m.Vector3{0.0, -1.0, 0.0}
+	found, hit := w.Raycast(&m.Vector3{0.0, 10.0, 0.0}, &down, 100.0)
+	if !found || hit.Body != sphere.Body {
+		t.Fatalf("The pick ray missed the sphere")
+	}
+	d := w.BeginDrag(hit)
+	if len(w.Constraints) != 1 || w.Constraints[0] != d.Pick {
+		t.Fatalf("Dragging didn't add its constraint to the world")
+	}
+
+	// dragged at a wall, the sphere is pushed up against it without going
+	// through or pulling harder than the constraint allows
+	d.UpdateDrag(&m.Vector3{4.0, 10.0, 0.0}, &down)
+	for i := 0; i < 120; i++ {
+		w.Step(1.0 / 60.0)
+		if x := sphere.Body.Position[0]; x > 1.3 {
+			t.Fatalf("The dragged sphere went into the wall, reaching %v after %d steps", x, i+1)
+		}
+		if force := d.Pick.GetForce(); force.Magnitude() > d.Pick.MaxForce*1.001 {
+			t.Fatalf("The drag pulled with %v, more than its MaxForce of %v", force.Magnitude(), d.Pick.MaxForce)
+		}
+	}
+
+	// dragged through the air, the grabbed point follows the ray
+	d.UpdateDrag(&m.Vector3{-2.0, 11.0, 0.0}, &down)
+	for i := 0; i < 120; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	grabbed := bodyPointToWorld(sphere.Body, &d.Pick.Point)
+	grabbed.Sub(&d.Pick.Target)
+	if grabbed.Magnitude() > 0.1 {
+		t.Errorf("The grabbed point was %v from the drag ray", grabbed.Magnitude())
+	}
+
+	// turned a little each step, the sphere makes a quarter turn about the
+	// grabbed point
+	before := sphere.Body.Orientation
+	for i := 0; i < 30; i++ {
+		d.RotateDrag(m.Vector3{0.0, 1.0, 0.0}, math.Pi/60.0, 1.0/60.0)
+		w.Step(1.0 / 60.0)
+	}
+	undo := before.Conjugated()
+	turn := sphere.Body.Orientation
+	turn.Mul(&undo)
+	side := m.Vector3{1.0, 0.0, 0.0}
+	side = turn.Rotate(&side)
+	if m.RealAbs(side[0]) > 0.1 || m.RealAbs(side[1]) > 0.1 || side[2] > -0.9 {
+		t.Errorf("Turning the drag a quarter turn about y turned x to %v", side)
+	}
+
+	d.EndDrag()
+	if len(w.Constraints) != 0 || !sphere.Body.CanSleep {
+		t.Errorf("Ending the drag didn't remove its constraint and let the sphere sleep")
+	}
+}
+
+func TestCharacterControllerWalks(t *testing.T) {
+	// a character dropped onto the ground lands on it, walks up onto a low
+	// step and stops at a wall
+	w := NewWorld()
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	for _, box := range []struct{ center, halfSize m.Vector3 }{
+		{m.Vector3{3.0, 0.1, 0.0}, m.Vector3{1.0, 0.1, 2.0}}, // a step 0.2 high
+		{m.Vector3{5.5, 1.0, 0.0}, m.Vector3{0.5, 1.0, 2.0}}, // a wall at x=5
+	} {
+		cube := NewCollisionCube(nil, box.halfSize)
+		cube.Body.SetInfiniteMass()
+		cube.Body.GravityScale = 0.0
+		cube.Body.SetAwake(false)
+		cube.Body.Position = box.center
+		cube.Body.CalculateDerivedData()
+		cube.CalculateDerivedData()
+		w.AddCollider(cube)
+	}
+
+	cc := NewCharacterController(m.Vector3{0.0, 2.0, 0.0}, 0.3, 1.8)
+	gravity := defaultAcceleration
+	var fall m.Real
+	walk := func(speed m.Real, steps int) {
+		for i := 0; i < steps; i++ {
+			const duration = 1.0 / 60.0
+			if cc.IsOnGround() {
+				fall = 0.0
+			} else {
+				fall += gravity[1] * duration
+			}
+			cc.Move(w, m.Vector3{speed * duration, fall * duration, 0.0}, duration)
+		}
+	}
+
+	walk(0.0, 60)
+	if !cc.IsOnGround() || m.RealAbs(cc.Position[1]-0.9) > 0.02 {
+		t.Fatalf("The character landed at %v; expected to stand on the ground at a height of 0.9", cc.Position)
+	}
+
+	walk(2.0, 60)
+	if !cc.IsOnGround() || m.RealAbs(cc.Position[1]-1.1) > 0.02 || cc.Position[0] < 1.9 {
+		t.Errorf("The character walked to %v; expected to be standing on the step at a height of 1.1", cc.Position)
+	}
+
+	walk(2.0, 90)
+	if x := cc.Position[0]; x > 4.7+0.02 || x < 4.5 {
+		t.Errorf("The character walked to %v; expected to stop against the wall at 4.7", cc.Position)
+	}
+	for _, overlap := range cc.Overlaps(w) {
+		if overlap.Depth > 0.02 {
+			t.Errorf("The character was %v inside a collider", overlap.Depth)
+		}
+	}
+}
+
+func TestBreakableShatters(t *testing.T) {
+	// a crate resting on the ground holds together until a heavy ball
+	// dropped on it breaks it into fragments of the same total mass
+	w := NewWorld()
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	crate := NewCollisionCube(nil, m.Vector3{0.5, 0.5, 0.5})
+	crate.SetDensity(1.0)
+	crate.Body.Position = m.Vector3{0.0, 0.5, 0.0}
+	crate.Body.CalculateDerivedData()
+	crate.CalculateDerivedData()
+	w.AddCollider(crate)
+
+	b := NewBreakable(2.0)
+	b.Add(crate)
+	var broken []*CollisionCube
+	b.OnBreak = func(cube *CollisionCube, fragments []*CollisionCube) {
+		broken = append(broken, cube)
+		if cube == crate && len(fragments) != 8 {
+			t.Errorf("The crate broke into %d fragments; expected 8", len(fragments))
+		}
+	}
+	w.AddBreakable(b)
+	for i := 0; i < 60; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	if len(broken) != 0 {
+		t.Fatalf("The crate broke just resting on the ground")
+	}
+
+	ball := newTestSphere(m.Vector3{0.0, 6.0, 0.0})
+	ball.SetDensity(10.0)
+	w.AddCollider(ball)
+	for i := 0; i < 90 && len(broken) == 0; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	if len(broken) != 1 || broken[0] != crate {
+		t.Fatalf("The ball landing on the crate broke %d cubes; expected just the crate", len(broken))
+	}
+	if _, ok := w.GetColliderID(crate); ok {
+		t.Errorf("The broken crate was left in the world")
+	}
+
+	// the fragments fill in for the crate and can break again themselves
+	var mass m.Real
+	fragments := 0
+	for _, c := range w.Colliders {
+		if cube, ok := c.(*CollisionCube); ok {
+			mass += cube.Body.GetMass()
+			fragments++
+		}
+	}
+	if fragments != 8 || m.RealAbs(mass-crate.Body.GetMass()) > 1e-4 {
+		t.Errorf("The world held %d fragments with a mass of %v; expected 8 with %v", fragments, mass, crate.Body.GetMass())
+	}
+	if cubes := b.GetCubes(); len(cubes) != 8 {
+		t.Errorf("%d of the fragments could break; expected all 8", len(cubes))
+	}
+}
+
+func TestWaterVolumeFloats(t *testing.T) {
+	// a crate half as dense as water floats half under the surface, a stone
+	// sinks, and the waves move on with each step
+	w := NewWorld()
+	water := NewWaterVolume(0.0)
+	w.AddWaterVolume(water)
+	crate := NewCollisionCube(nil, m.Vector3{0.5, 0.5, 0.5})
+	crate.SetDensity(500.0)
+	crate.Body.Position = m.Vector3{0.0, 0.25, 0.0}
+	crate.Body.CalculateDerivedData()
+	crate.CalculateDerivedData()
+	w.AddCollider(crate)
+	stone := newTestSphere(m.Vector3{3.0, 1.0, 0.0})
+	stone.SetDensity(2000.0)
+	w.AddCollider(stone)
+
+	for i := 0; i < 1200; i++ {
+		w.Step(1.0 / 60.0)
+	}
+	if y := crate.Body.Position[1]; m.RealAbs(y) > 0.01 || crate.Body.Velocity.Magnitude() > 0.05 {
+		t.Errorf("The crate floated at a height of %v moving at %v; expected it to settle at 0", y, crate.Body.Velocity)
+	}
+	if y := stone.Body.Position[1]; y > -5.0 {
+		t.Errorf("The stone only sank to %v", y)
+	}
+	if m.RealAbs(water.Time-20.0) > 1e-3 {
+		t.Errorf("The water was at a time of %v after 20 seconds", water.Time)
+	}
+}
+
+func TestPathFollowerCarries(t *testing.T) {
+	// a platform going back and forth along a path carries a crate resting
+	// on it along with it
+	w := NewWorld()
+	platform := NewCollisionCube(nil, m.Vector3{1.0, 0.1, 1.0})
+	platform.Body.Position = m.Vector3{0.0, 1.0, 0.0}
+	platform.Body.CalculateDerivedData()
+	platform.CalculateDerivedData()
+	w.AddCollider(platform)
+	path := NewPath([]m.Vector3{{0.0, 1.0, 0.0}, {4.0, 1.0, 0.0}}, false)
+	if point, direction := path.PointAt(1.0); point != (m.Vector3{1.0, 1.0, 0.0}) || direction != (m.Vector3{1.0, 0.0, 0.0}) {
+		t.Fatalf("The path was at %v heading along %v a unit along it", point, direction)
+	}
+	pf := NewPathFollower(platform.Body, path, 1.0)
+	pf.Mode = PathPingPong
+	w.AddPathFollower(pf)
+
+	crate := NewCollisionCube(nil, m.Vector3{0.25, 0.25, 0.25})
+	crate.SetDensity(1.0)
+	crate.Body.Position = m.Vector3{0.0, 1.35, 0.0}
+	crate.Body.CalculateDerivedData()
+	crate.CalculateDerivedData()
+	w.AddCollider(crate)
+
+	for i, expected := range []m.Real{2.0, 4.0, 2.0} {
+		for step := 0; step < 120; step++ {
+			w.Step(1.0 / 60.0)
+		}
+		if x := platform.Body.Position[0]; m.RealAbs(x-expected) > 0.02 || platform.Body.Position[1] != 1.0 {
+			t.Errorf("After %d seconds the platform was at %v; expected x to be %v", 2*(i+1), platform.Body.Position, expected)
+		}
+		if x := crate.Body.Position[0]; m.RealAbs(x-platform.Body.Position[0]) > 0.5 || crate.Body.Position[1] < 1.3 {
+			t.Errorf("After %d seconds the crate was at %v, left behind by the platform at %v", 2*(i+1), crate.Body.Position, platform.Body.Position)
+		}
+	}
+	if pf.IsFinished() {
+		t.Errorf("A follower going back and forth finished")
+	}
+}
+
+func TestWorldSortContacts(t *testing.T) {
+	w, spheres := newTestPile()
+	w.SortContacts = true
+	reordered := false
+	for step := 0; step < 120; step++ {
+		w.Step(0.01)
+		if w.GetContactsReordered() > 0 {
+			reordered = true
+		}
+
+		var last uint64
+		for i, c := range w.GetContacts() {
+			lo, hi := w.bodyIndex(c.Bodies[0]), w.bodyIndex(c.Bodies[1])
+			if hi < lo {
+				lo, hi = hi, lo
+			}
+			key := uint64(lo)<<32 | uint64(hi)
+			if i > 0 && key < last {
+				t.Fatalf("step %d: contact %d is out of order", step, i)
+			}
+			last = key
+		}
+	}
+	if !reordered {
+		t.Errorf("The contacts of the pile were never reordered")
+	}
+
+	// the pile should still settle on the ground
+	for i, s := range spheres {
+		if s.Body.Position[1] < 0.4 {
+			t.Errorf("Sphere %d sank into the ground: %v", i, s.Body.Position)
+		}
+	}
+}
+
+func TestWorldReserve(t *testing.T) {
+	config := WorldConfig{MaxBodies: 32, MaxContacts: 256, MaxPairs: 256}
+	w := NewWorldWithConfig(&config)
+	w.SetBroadphase(NewSweepAndPruneBroadphase())
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	for i := 0; i < 20; i++ {
+		s := newTestSphere(m.Vector3{m.Real(i%4) * 0.9, 0.6 + m.Real(i/4)*0.9, 0.0})
+		w.AddBody(s.Body)
+		w.AddCollider(s)
+	}
+	if len(w.contactPool.contacts) != config.MaxContacts || cap(w.Colliders) != config.MaxBodies {
+		t.Fatalf("Reserve didn't allocate the capacities up front")
+	}
+
+	for step := 0; step < 120; step++ {
+		w.Step(0.01)
+	}
+	if len(w.contactPool.contacts) != config.MaxContacts {
+		t.Errorf("The contact pool grew to %d", len(w.contactPool.contacts))
+	}
+	if cap(w.pairs) != config.MaxPairs || cap(w.Bodies) != config.MaxBodies {
+		t.Errorf("The reserved slices were reallocated")
+	}
+
+	// outgrowing the hints still works
+	small := NewWorldWithConfig(&WorldConfig{MaxContacts: 1})
+	reference, _ := newTestPile()
+	pile, _ := newTestPile()
+	for _, c := range pile.Colliders {
+		small.AddCollider(c)
+	}
+	for step := 0; step < 60; step++ {
+		reference.Step(0.01)
+		small.Step(0.01)
+	}
+	for i, c := range small.Colliders {
+		if body := c.GetBody(); body != nil && body.Position != reference.Colliders[i].GetBody().Position {
+			t.Fatalf("Collider %d moved differently in a world that outgrew its hints", i)
+		}
+	}
+}
+
+func TestBodyLazyInertiaTensor(t *testing.T) {
+	body := NewRigidBody()
+	body.SetMass(2.0)
+	var inertia m.Matrix3
+	inertia.SetBlockInertiaTensor(&m.Vector3{1.0, 2.0, 0.5}, 2.0)
+	body.SetInertiaTensor(&inertia)
+	body.Orientation = m.QuatFromAxis(0.7, 1.0, 1.0, 0.0)
+	body.CalculateDerivedData()
+	if !body.inertiaStale {
+		t.Fatalf("Changing the orientation didn't mark the tensor as stale")
+	}
+
+	var expected m.Matrix3
+	transformInertiaTensor(&expected, &body.InverseInertiaTensor, &body.transform)
+	if got := body.GetInverseInertiaTensorWorld(); got != expected || !body.inertiaStale {
+		t.Errorf("Reading the tensor of another body gave %v instead of %v, or kept it", got, expected)
+	}
+	if got := *body.inverseInertiaWorld(); got != expected || body.inertiaStale {
+		t.Errorf("The tensor was calculated as %v instead of %v", got, expected)
+	}
+
+	// moving without turning leaves the tensor alone
+	body.Position = m.Vector3{1.0, 2.0, 3.0}
+	body.CalculateDerivedData()
+	if body.inertiaStale {
+		t.Errorf("Moving the body without turning it marked the tensor as stale")
+	}
+
+	body.AxisLocks = LockAngularX | LockAngularY | LockAngularZ
+	body.CalculateDerivedData()
+	if got := *body.inverseInertiaWorld(); got != (m.Matrix3{}) {
+		t.Errorf("A body that can't rotate should have a zero tensor but had %v", got)
+	}
+}
+
+// newTestCompoundBody creates a body, authored Z-up, with a turned cube on
+// top of it and a sphere off to one side.
+func newTestCompoundBody() (*CollisionCube, *CollisionSphere) {
+	body := NewRigidBody()
+	body.Position = m.Vector3{1.0, 2.0, 3.0}
+	body.Orientation = m.QuatFromAxis(0.4, 0.0, 0.0, 1.0)
+	cube := NewCollisionCube(body, m.Vector3{1.0, 0.5, 0.25})
+	turn := m.QuatFromAxis(0.6, 1.0, 0.0, 0.0)
+	cube.Offset.SetAsTransform(&m.Vector3{0.0, 0.0, 1.0}, &turn)
+	sphere := NewCollisionSphere(body, 0.5)
+	sphere.Offset.SetAsTransform(&m.Vector3{2.0, 0.0, 0.0}, &m.Quat{1.0, 0.0, 0.0, 0.0})
+	cube.SetDensity(1.0)
+	sphere.SetDensity(1.0)
+	body.CalculateDerivedData()
+	cube.CalculateDerivedData()
+	sphere.CalculateDerivedData()
+	return cube, sphere
+}
+
+func TestWorldImportCompoundBody(t *testing.T) {
+	// the bounds and center of mass of the colliders, converted from Z-up
+	zUpToYUp := func(b Bounds) Bounds {
+		return Bounds{
+			Min: m.Vector3{b.Min[0], b.Min[2], -b.Max[1]},
+			Max: m.Vector3{b.Max[0], b.Max[2], -b.Min[1]},
+		}
+	}
+	cube, sphere := newTestCompoundBody()
+	expectedCube := zUpToYUp(ColliderBounds(cube))
+	expectedSphere := zUpToYUp(ColliderBounds(sphere))
+	center := cube.Body.GetCenterOfMassWorld()
+	expectedCenter := ConvertVector(&center, ZUp, YUp)
+
+	check := func(name string, cube *CollisionCube, sphere *CollisionSphere) {
+		t.Helper()
+		for _, c := range []struct {
+			collider Collider
+			expected Bounds
+		}{{cube, expectedCube}, {sphere, expectedSphere}} {
+			b := ColliderBounds(c.collider)
+			min, max := b.Min, b.Max
+			min.Sub(&c.expected.Min)
+			max.Sub(&c.expected.Max)
+			if min.Magnitude() > 1e-4 || max.Magnitude() > 1e-4 {
+				t.Errorf("%s put a collider at %v; expected %v", name, b, c.expected)
+			}
+		}
+		center := cube.Body.GetCenterOfMassWorld()
+		center.Sub(&expectedCenter)
+		if center.Magnitude() > 1e-4 {
+			t.Errorf("%s put the center of mass at %v; expected %v", name, cube.Body.GetCenterOfMassWorld(), expectedCenter)
+		}
+	}
+
+	// importing the colliders one at a time only converts the body once
+	w := NewWorld()
+	cube, sphere = newTestCompoundBody()
+	w.ImportCollider(cube, ZUp)
+	w.ImportCollider(sphere, ZUp)
+	check("Importing the colliders", cube, sphere)
+
+	cube, sphere = newTestCompoundBody()
+	ConvertColliders([]Collider{cube, sphere}, ZUp, YUp)
+	check("ConvertColliders", cube, sphere)
+}
+
+func TestRollingConstraint(t *testing.T) {
+	// a sphere sliding over the ground without spinning is made to roll
+	w := NewWorld()
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	ball := newTestSphere(m.Vector3{0.0, 0.49, 0.0})
+	ball.Rolling = NewRollingConstraint(0.0)
+	ball.Body.Velocity = m.Vector3{2.0, 0.0, 0.0}
+	ball.Body.LinearDamping, ball.Body.AngularDamping = 1.0, 1.0
+	w.AddCollider(ball)
+	w.Step(1.0 / 60.0)
+	spin := -ball.Body.Rotation[2] * ball.Radius
+	if m.RealAbs(spin-ball.Body.Velocity[0]) > 1e-3 {
+		t.Errorf("The ball moved at %v and spun at %v at its surface; expected it to roll", ball.Body.Velocity[0], spin)
+	}
+
+	// the constraint of a sphere doesn't touch the contacts of a cube on the
+	// same body, so a sliding crate carrying a rolling sphere above it slides
+	// just as it does without one
+	newCrate := func(rolling bool) (*World, *RigidBody) {
+		w := NewWorld()
+		w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+		body := NewRigidBody()
+		cube := NewCollisionCube(body, m.Vector3{0.5, 0.5, 0.5})
+		sphere := NewCollisionSphere(body, 0.25)
+		sphere.Offset.SetAsTransform(&m.Vector3{0.0, 1.0, 0.0}, &m.Quat{1.0, 0.0, 0.0, 0.0})
+		if rolling {
+			sphere.Rolling = NewRollingConstraint(0.0)
+		}
+		cube.SetDensity(1.0)
+		body.Position = m.Vector3{0.0, 0.49, 0.0}
+		body.Velocity = m.Vector3{2.0, 0.0, 0.0}
+		body.CalculateDerivedData()
+		w.AddCollider(cube)
+		w.AddCollider(sphere)
+		return w, body
+	}
+	plainWorld, plain := newCrate(false)
+	rollingWorld, rolling := newCrate(true)
+	for i := 0; i < 30; i++ {
+		plainWorld.Step(1.0 / 60.0)
+		rollingWorld.Step(1.0 / 60.0)
+	}
+	if plain.Position != rolling.Position || plain.Rotation != rolling.Rotation {
+		t.Errorf("The crate with a rolling sphere ended up at %v spinning at %v; expected %v spinning at %v",
+			rolling.Position, rolling.Rotation, plain.Position, plain.Rotation)
+	}
+}
+
+func TestWorldValidate(t *testing.T) {
+	w := NewWorld()
+	w.Validate = true
+	var diagnostics []Diagnostic
+	w.OnInvalid = func(d Diagnostic) {
+		diagnostics = append(diagnostics, d)
+	}
+	w.AddCollider(NewCollisionPlane(m.Vector3{0.0, 1.0, 0.0}, 0.0))
+	good := newTestSphere(m.Vector3{0.0, 0.49, 0.0})
+	bad := newTestSphere(m.Vector3{3.0, 0.49, 0.0})
+	w.AddCollider(good)
+	w.AddCollider(bad)
+
+	// the bad sphere's velocity goes bad once its contacts have been generated
+	w.Events.Subscribe(EventCollision, 0, func(e *Event) {
+		if e.Bodies[0] == bad.Body || e.Bodies[1] == bad.Body {
+			bad.Body.Velocity[0] = m.Real(math.NaN())
+		}
+	})
+	contacts := w.Step(1.0 / 60.0)
+
+	// the contacts touching the bad body are dropped before they're resolved
+	// and the velocity is reported again once they have been
+	expected := []Diagnostic{
+		{Phase: PhaseCollision, Body: bad.Body, Field: "Bodies"},
+		{Phase: PhaseResolve, Body: bad.Body, Field: "Velocity"},
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("Validating the step reported %v; expected %v", diagnostics, expected)
+	}
+	for i, d := range diagnostics {
+		if d.Phase != expected[i].Phase || d.Body != expected[i].Body || d.Field != expected[i].Field {
+			t.Errorf("Validating the step reported %v for %p; expected %v for %p", d, d.Body, expected[i], expected[i].Body)
+		}
+		if (d.Contact != nil) != (d.Phase == PhaseCollision) {
+			t.Errorf("Validating the step reported %v with the contact %v", d, d.Contact)
+		}
+	}
+	kept := 0
+	for _, c := range contacts {
+		if c.Bodies[0] == bad.Body || c.Bodies[1] == bad.Body {
+			t.Errorf("A contact of the bad body was kept: %v", c)
+		}
+		if c.Bodies[0] == good.Body || c.Bodies[1] == good.Body {
+			kept++
+		}
+	}
+	if kept == 0 {
+		t.Error("The contact of the good body was dropped")
+	}
+	if field := ValidateBody(good.Body); field != "" || good.Body.Position[1] < 0.49 {
+		t.Errorf("The good body ended up at %v with a bad %s", good.Body.Position, field)
+	}
+}
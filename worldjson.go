@@ -0,0 +1,459 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	"encoding/json"
+	"fmt"
+
+	m "github.com/harbdog/cubez/math"
+)
+
+// worldJSONVersion is the version of the layout written by World.MarshalJSON.
+const worldJSONVersion = 1
+
+// worldJSON is the layout of a World in JSON. Bodies are referred to by their
+// index in Bodies, with -1 for none.
+type worldJSON struct {
+	Version         int
+	Integrator      string
+	Convention      AxisConvention
+	MaxLinearSpeed  m.Real
+	MaxAngularSpeed m.Real
+	TimeScale       m.Real
+	Workers         int
+	Deterministic   bool
+	Validate        bool
+	Wrap            *WrapBounds
+	Bodies          []bodyJSON
+	Colliders       []partJSON
+	Joints          []partJSON
+	Constraints     []partJSON
+}
+
+// bodyJSON is the layout of a RigidBody in JSON: its exported fields along
+// with the state that's only reachable through methods.
+type bodyJSON struct {
+	*RigidBody
+	Mass        m.Real
+	InverseMass m.Real
+	Motion      m.Real
+	TimeAtRest  m.Real
+}
+
+// partJSON is the layout of a collider, joint or constraint in JSON: the kind
+// of part, the bodies it's attached to and its exported fields without the
+// bodies.
+type partJSON struct {
+	Type   string
+	Bodies [2]int
+	Fields json.RawMessage
+}
+
+// heightfieldJSON holds the parts of a CollisionHeightfield that are only
+// reachable through methods.
+type heightfieldJSON struct {
+	*CollisionHeightfield
+	Holes         []bool
+	CellMaterials []uint8
+}
+
+// pickJSON holds the parts of a PickConstraint that are only reachable
+// through methods.
+type pickJSON struct {
+	*PickConstraint
+	CanSleep bool
+}
+
+// MarshalJSON writes the bodies, colliders, joints and constraints of the
+// world along with its tuning parameters, so that a whole simulation can be
+// saved and loaded again with UnmarshalJSON. Materials and mediums are written
+// by value, so colliders that shared one get their own copies when loaded.
+// Callbacks, force generators, stall detectors, the broadphase and the other
+// parts of the world aren't written, and a constraint of a type from outside
+// this package is an error.
+func (w *World) MarshalJSON() ([]byte, error) {
+	doc := worldJSON{
+		Version:         worldJSONVersion,
+		Convention:      w.Convention,
+		MaxLinearSpeed:  w.MaxLinearSpeed,
+		MaxAngularSpeed: w.MaxAngularSpeed,
+		TimeScale:       w.TimeScale,
+		Workers:         w.Workers,
+		Deterministic:   w.Deterministic,
+		Validate:        w.Validate,
+		Wrap:            w.Wrap,
+	}
+	switch w.Integrator.(type) {
+	case nil, *SemiImplicitEuler:
+		doc.Integrator = "SemiImplicitEuler"
+	case *VelocityVerlet:
+		doc.Integrator = "VelocityVerlet"
+	case *RungeKutta4:
+		doc.Integrator = "RungeKutta4"
+	default:
+		return nil, fmt.Errorf("the world has an integrator of unknown type %T", w.Integrator)
+	}
+
+	indexes := make(map[*RigidBody]int, len(w.Bodies))
+	for i, body := range w.Bodies {
+		indexes[body] = i
+		doc.Bodies = append(doc.Bodies, bodyJSON{body, body.mass, body.inverseMass, body.motion, body.timeAtRest})
+	}
+	bodyIndexes := func(bodies [2]*RigidBody) ([2]int, error) {
+		result := [2]int{-1, -1}
+		for i, body := range bodies {
+			if body == nil {
+				continue
+			}
+			index, ok := indexes[body]
+			if !ok {
+				return result, fmt.Errorf("a part is attached to a body that isn't in the world")
+			}
+			result[i] = index
+		}
+		return result, nil
+	}
+	encode := func(kind string, bodies [2]*RigidBody, fields interface{}, without ...string) (partJSON, error) {
+		part := partJSON{Type: kind}
+		var err error
+		if part.Bodies, err = bodyIndexes(bodies); err != nil {
+			return part, err
+		}
+		part.Fields, err = marshalWithout(fields, without...)
+		return part, err
+	}
+
+	for _, c := range w.Colliders {
+		var part partJSON
+		var err error
+		switch shape := c.(type) {
+		case *CollisionPlane:
+			part, err = encode("plane", [2]*RigidBody{}, shape)
+		case *CollisionSphere:
+			part, err = encode("sphere", [2]*RigidBody{shape.Body}, shape, "Body")
+		case *CollisionCube:
+			part, err = encode("cube", [2]*RigidBody{shape.Body}, shape, "Body")
+		case *CollisionCapsule:
+			part, err = encode("capsule", [2]*RigidBody{shape.Body}, shape, "Body")
+		case *CollisionHeightfield:
+			part, err = encode("heightfield", [2]*RigidBody{}, &heightfieldJSON{shape, shape.holes, shape.cellMaterials})
+		case *CollisionTriangleMesh:
+			part, err = encode("trimesh", [2]*RigidBody{}, shape, "BVH")
+		default:
+			err = fmt.Errorf("the world has a collider of unknown type %T", c)
+		}
+		if err != nil {
+			return nil, err
+		}
+		doc.Colliders = append(doc.Colliders, part)
+	}
+
+	for _, j := range w.Joints {
+		part, err := encode("joint", j.Bodies, j, "Bodies")
+		if err != nil {
+			return nil, err
+		}
+		doc.Joints = append(doc.Joints, part)
+	}
+	for _, c := range w.Constraints {
+		kind := constraintKind(c)
+		if kind == "" {
+			return nil, fmt.Errorf("the world has a constraint of unknown type %T", c)
+		}
+		// stall detectors hold callbacks, which can't be written
+		fields := interface{}(c)
+		switch joint := c.(type) {
+		case *HingeJoint:
+			unstalled := *joint
+			unstalled.Stall = nil
+			fields = &unstalled
+		case *SliderJoint:
+			unstalled := *joint
+			unstalled.Stall = nil
+			fields = &unstalled
+		case *PickConstraint:
+			fields = &pickJSON{joint, joint.canSleep}
+		}
+		part, err := encode(kind, c.GetBodies(), fields, "Body", "Bodies", "Stall")
+		if err != nil {
+			return nil, err
+		}
+		doc.Constraints = append(doc.Constraints, part)
+	}
+	return json.Marshal(&doc)
+}
+
+// UnmarshalJSON loads a world written by MarshalJSON. The world must be empty,
+// such as one just made with NewWorld.
+func (w *World) UnmarshalJSON(data []byte) error {
+	if len(w.Bodies) > 0 || len(w.Colliders) > 0 || len(w.Joints) > 0 || len(w.Constraints) > 0 {
+		return fmt.Errorf("a world can only be loaded into an empty world")
+	}
+	var doc worldJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.Version != worldJSONVersion {
+		return fmt.Errorf("the world has version %d; expected %d", doc.Version, worldJSONVersion)
+	}
+
+	switch doc.Integrator {
+	case "", "SemiImplicitEuler":
+		w.Integrator = &SemiImplicitEuler{}
+	case "VelocityVerlet":
+		w.Integrator = &VelocityVerlet{}
+	case "RungeKutta4":
+		w.Integrator = &RungeKutta4{}
+	default:
+		return fmt.Errorf("the world has an unknown integrator %q", doc.Integrator)
+	}
+	if w.Events == nil {
+		w.Events = NewEventDispatcher()
+	}
+	w.Convention = doc.Convention
+	w.MaxLinearSpeed = doc.MaxLinearSpeed
+	w.MaxAngularSpeed = doc.MaxAngularSpeed
+	w.TimeScale = doc.TimeScale
+	w.Workers = doc.Workers
+	w.Deterministic = doc.Deterministic
+	w.Validate = doc.Validate
+	w.Wrap = doc.Wrap
+
+	bodies := make([]*RigidBody, len(doc.Bodies))
+	for i := range doc.Bodies {
+		body := doc.Bodies[i].RigidBody
+		if body == nil {
+			return fmt.Errorf("body %d is empty", i)
+		}
+		body.mass = doc.Bodies[i].Mass
+		body.inverseMass = doc.Bodies[i].InverseMass
+		body.motion = doc.Bodies[i].Motion
+		body.timeAtRest = doc.Bodies[i].TimeAtRest
+		body.prevPosition = body.Position
+		body.prevOrientation = body.Orientation
+		body.prevVelocity = body.Velocity
+		body.CalculateDerivedData()
+		bodies[i] = body
+		w.AddBody(body)
+	}
+	bodyAt := func(index int) (*RigidBody, error) {
+		if index < 0 {
+			return nil, nil
+		}
+		if index >= len(bodies) {
+			return nil, fmt.Errorf("a part is attached to body %d of %d", index, len(bodies))
+		}
+		return bodies[index], nil
+	}
+	decode := func(part *partJSON, fields interface{}) ([2]*RigidBody, error) {
+		var attached [2]*RigidBody
+		if err := json.Unmarshal(part.Fields, fields); err != nil {
+			return attached, fmt.Errorf("failed to decode a %s: %v", part.Type, err)
+		}
+		for i, index := range part.Bodies {
+			body, err := bodyAt(index)
+			if err != nil {
+				return attached, err
+			}
+			attached[i] = body
+		}
+		return attached, nil
+	}
+
+	for i := range doc.Colliders {
+		part := &doc.Colliders[i]
+		var c Collider
+		switch part.Type {
+		case "plane":
+			plane := new(CollisionPlane)
+			if _, err := decode(part, plane); err != nil {
+				return err
+			}
+			c = plane
+		case "sphere":
+			sphere := NewCollisionSphere(nil, 0.0)
+			attached, err := decode(part, sphere)
+			if err != nil {
+				return err
+			}
+			sphere.Body = attached[0]
+			c = sphere
+		case "cube":
+			cube := NewCollisionCube(nil, m.Vector3{})
+			attached, err := decode(part, cube)
+			if err != nil {
+				return err
+			}
+			cube.Body = attached[0]
+			c = cube
+		case "capsule":
+			capsule := NewCollisionCapsule(nil, 0.0, 0.0)
+			attached, err := decode(part, capsule)
+			if err != nil {
+				return err
+			}
+			capsule.Body = attached[0]
+			c = capsule
+		case "heightfield":
+			fields := heightfieldJSON{CollisionHeightfield: new(CollisionHeightfield)}
+			if _, err := decode(part, &fields); err != nil {
+				return err
+			}
+			hf := fields.CollisionHeightfield
+			hf.holes = fields.Holes
+			hf.cellMaterials = fields.CellMaterials
+			c = hf
+		case "trimesh":
+			mesh := new(CollisionTriangleMesh)
+			if _, err := decode(part, mesh); err != nil {
+				return err
+			}
+			for _, index := range mesh.Indices {
+				if index < 0 || index >= len(mesh.Vertices) {
+					return fmt.Errorf("collider %d has vertex %d out of range", i, index)
+				}
+			}
+			c = mesh
+		default:
+			return fmt.Errorf("collider %d has an unknown type %q", i, part.Type)
+		}
+		if body := c.GetBody(); body == nil && part.Type != "plane" && part.Type != "heightfield" && part.Type != "trimesh" {
+			return fmt.Errorf("collider %d has no body", i)
+		}
+		c.CalculateDerivedData()
+		w.AddCollider(c)
+	}
+
+	for i := range doc.Joints {
+		j := new(Joint)
+		attached, err := decode(&doc.Joints[i], j)
+		if err != nil {
+			return err
+		}
+		j.Bodies = attached
+		w.AddJoint(j)
+	}
+	for i := range doc.Constraints {
+		part := &doc.Constraints[i]
+		c := newConstraintOfKind(part.Type)
+		if c == nil {
+			return fmt.Errorf("constraint %d has an unknown type %q", i, part.Type)
+		}
+		fields := interface{}(c)
+		pick, isPick := c.(*PickConstraint)
+		if isPick {
+			fields = &pickJSON{PickConstraint: pick}
+		}
+		attached, err := decode(part, fields)
+		if err != nil {
+			return err
+		}
+		if isPick {
+			pick.canSleep = fields.(*pickJSON).CanSleep
+		}
+		setConstraintBodies(c, attached)
+		w.AddConstraint(c)
+	}
+	return nil
+}
+
+// constraintKind returns the name of the type of the constraint in JSON, or
+// an empty string for a type from outside this package.
+func constraintKind(c Constraint) string {
+	switch c.(type) {
+	case *HingeJoint:
+		return "hinge"
+	case *SliderJoint:
+		return "slider"
+	case *WeldJoint:
+		return "weld"
+	case *DistanceJoint:
+		return "distance"
+	case *SpringJoint:
+		return "spring"
+	case *GenericJoint:
+		return "generic"
+	case *PointOnLineConstraint:
+		return "pointOnLine"
+	case *PointOnPlaneConstraint:
+		return "pointOnPlane"
+	case *OrientationServo:
+		return "servo"
+	case *PickConstraint:
+		return "pick"
+	}
+	return ""
+}
+
+// newConstraintOfKind returns an empty constraint of the type named in JSON,
+// or nil if there is no such type.
+func newConstraintOfKind(kind string) Constraint {
+	switch kind {
+	case "hinge":
+		return new(HingeJoint)
+	case "slider":
+		return new(SliderJoint)
+	case "weld":
+		return new(WeldJoint)
+	case "distance":
+		return new(DistanceJoint)
+	case "spring":
+		return new(SpringJoint)
+	case "generic":
+		return new(GenericJoint)
+	case "pointOnLine":
+		return new(PointOnLineConstraint)
+	case "pointOnPlane":
+		return new(PointOnPlaneConstraint)
+	case "servo":
+		return new(OrientationServo)
+	case "pick":
+		return new(PickConstraint)
+	}
+	return nil
+}
+
+// setConstraintBodies attaches a constraint made by newConstraintOfKind to
+// its bodies.
+func setConstraintBodies(c Constraint, bodies [2]*RigidBody) {
+	switch c := c.(type) {
+	case *HingeJoint:
+		c.Bodies = bodies
+	case *SliderJoint:
+		c.Bodies = bodies
+	case *WeldJoint:
+		c.Bodies = bodies
+	case *DistanceJoint:
+		c.Bodies = bodies
+	case *SpringJoint:
+		c.Bodies = bodies
+	case *GenericJoint:
+		c.Bodies = bodies
+	case *PointOnLineConstraint:
+		c.Bodies = bodies
+	case *PointOnPlaneConstraint:
+		c.Bodies = bodies
+	case *OrientationServo:
+		c.Bodies = bodies
+	case *PickConstraint:
+		c.Body = bodies[0]
+	}
+}
+
+// marshalWithout marshals the value as a JSON object without the fields named.
+func marshalWithout(v interface{}, fields ...string) (json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(fields) == 0 {
+		return data, err
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		delete(object, field)
+	}
+	return json.Marshal(object)
+}
@@ -0,0 +1,313 @@
+// Copyright 2015, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package cubez
+
+import (
+	m "github.com/harbdog/cubez/math"
+)
+
+// WrapBounds describes a world whose space wraps around along some of its axes,
+// like the screen of an arcade space game: a body leaving through one side comes
+// back in through the other and bodies on opposite sides touch across the seam.
+type WrapBounds struct {
+	// Min is the corner of the wrapped region with the smallest coordinates.
+	Min m.Vector3
+
+	// Max is the corner of the wrapped region with the largest coordinates.
+	Max m.Vector3
+
+	// Axes holds which of the axes wrap around.
+	// Defaults to all three.
+	Axes [3]bool
+}
+
+// NewWrapBounds creates a new WrapBounds that wraps along all three axes.
+func NewWrapBounds(min *m.Vector3, max *m.Vector3) *WrapBounds {
+	wb := new(WrapBounds)
+	wb.Min = *min
+	wb.Max = *max
+	wb.Axes = [3]bool{true, true, true}
+	return wb
+}
+
+// WrapPoint returns the point moved into the wrapped region.
+func (wb *WrapBounds) WrapPoint(point *m.Vector3) m.Vector3 {
+	result := *point
+	for i := 0; i < 3; i++ {
+		size := wb.Max[i] - wb.Min[i]
+		if !wb.Axes[i] || size <= 0.0 {
+			continue
+		}
+		for result[i] < wb.Min[i] {
+			result[i] += size
+		}
+		for result[i] >= wb.Max[i] {
+			result[i] -= size
+		}
+	}
+	return result
+}
+
+// Offset returns the translation that moves the from point to its image closest
+// to the to point, taking the wrapping into account.
+func (wb *WrapBounds) Offset(from *m.Vector3, to *m.Vector3) m.Vector3 {
+	var offset m.Vector3
+	for i := 0; i < 3; i++ {
+		size := wb.Max[i] - wb.Min[i]
+		if !wb.Axes[i] || size <= 0.0 {
+			continue
+		}
+		d := to[i] - from[i]
+		if d > size*0.5 {
+			offset[i] = size
+		} else if d < -size*0.5 {
+			offset[i] = -size
+		}
+	}
+	return offset
+}
+
+// wrapBodies moves the bodies that have left the wrapped region back into it.
+// Bodies in frozen assemblies follow their proxy, so they're left alone.
+func (w *World) wrapBodies() {
+	for _, body := range w.Bodies {
+		if w.proxyOf(body) != body {
+			continue
+		}
+		wrapped := w.Wrap.WrapPoint(&body.Position)
+		if wrapped != body.Position {
+			// the body didn't really travel across the world, so don't
+			// let InterpolatedTransform sweep it back across
+			awake := body.IsAwake
+			body.SetTransform(&wrapped, &body.Orientation)
+			body.SetAwake(awake)
+		}
+	}
+}
+
+// checkWrappedPair appends the contacts between two colliders using the
+// image of the second collider closest to the first. It returns false if the
+// colliders don't need wrapping, leaving them for a normal check.
+func (w *World) checkWrappedPair(one Collider, two Collider, contacts []*Contact, pool *contactPool) (bool, []*Contact) {
+	body := two.GetBody()
+	if one.GetBody() == nil || body == nil {
+		return false, contacts
+	}
+	transformOne, transformTwo := one.GetTransform(), two.GetTransform()
+	from, to := transformTwo.GetAxis(3), transformOne.GetAxis(3)
+	offset := w.Wrap.Offset(&from, &to)
+	if offset[0] == 0.0 && offset[1] == 0.0 && offset[2] == 0.0 {
+		return false, contacts
+	}
+
+	// move the second body to its image for the check and then put it back
+	position := body.Position
+	body.Position.Add(&offset)
+	body.calculateTransforms()
+	two.CalculateDerivedData()
+
+	first := len(contacts)
+	_, contacts = checkForCollisions(one, two, contacts, pool)
+	for _, c := range contacts[first:] {
+		for i := range c.Bodies {
+			if c.Bodies[i] == body {
+				c.offsets[i] = offset
+			}
+		}
+	}
+
+	body.Position = position
+	body.calculateTransforms()
+	two.CalculateDerivedData()
+	return true, contacts
+}
+
+// wrapGhost is an image of a collider across one or more of the seams of the
+// wrapped region. Colliders hanging over the edge of the region get a ghost
+// in the broadphase for each seam they hang over, so that the broadphase
+// pairs them with the colliders on the other side.
+type wrapGhost struct {
+	Collider
+
+	// mask holds the axes the image is moved across, one bit per axis.
+	mask int
+
+	// bounds are the bounds of the collider moved to the image.
+	bounds Bounds
+}
+
+// wrapGhostOwner returns the collider the ghost is an image of, or the
+// collider given if it isn't a ghost.
+func wrapGhostOwner(c Collider) Collider {
+	if g, ok := c.(*wrapGhost); ok {
+		return g.Collider
+	}
+	return c
+}
+
+// syncWrapGhosts puts the ghosts of the colliders in the broadphase in step
+// with the wrapped region, creating the ghosts of every collider again when
+// the region has changed or the broadphase is new, and removing them all if
+// the world no longer wraps.
+func (w *World) syncWrapGhosts() {
+	if w.Wrap == nil {
+		for c := range w.wrapGhosts {
+			w.removeWrapGhosts(c)
+		}
+		w.ghostsWrapped = false
+		return
+	}
+	if w.ghostsWrapped && w.ghostWrap == *w.Wrap {
+		return
+	}
+	w.ghostWrap = *w.Wrap
+	w.ghostsWrapped = true
+	for _, c := range w.Colliders {
+		w.updateWrapGhosts(c)
+	}
+}
+
+// updateWrapGhosts inserts, moves and removes the ghosts of the collider in
+// the broadphase to match the seams its bounds hang over.
+func (w *World) updateWrapGhosts(c Collider) {
+	if w.Wrap == nil || c.GetBody() == nil {
+		return
+	}
+	bounds := ColliderBounds(c)
+	var shift m.Vector3
+	for axis := 0; axis < 3; axis++ {
+		size := w.Wrap.Max[axis] - w.Wrap.Min[axis]
+		if !w.Wrap.Axes[axis] || size <= 0.0 {
+			continue
+		}
+		if bounds.Max[axis] > w.Wrap.Max[axis] {
+			shift[axis] = -size
+		} else if bounds.Min[axis] < w.Wrap.Min[axis] {
+			shift[axis] = size
+		}
+	}
+
+	// keep the ghosts still needed, moving them along with the collider
+	existing := w.wrapGhosts[c]
+	kept := existing[:0]
+	for _, g := range existing {
+		image, ok := wrapImage(&bounds, &shift, g.mask)
+		if !ok {
+			w.broadphase.Remove(g)
+			continue
+		}
+		if image != g.bounds {
+			g.bounds = image
+			w.broadphase.Update(g)
+		}
+		kept = append(kept, g)
+	}
+
+	// add a ghost for each seam, or corner of seams, newly hung over
+	for mask := 1; mask < 8; mask++ {
+		image, ok := wrapImage(&bounds, &shift, mask)
+		if !ok {
+			continue
+		}
+		found := false
+		for _, g := range kept {
+			if g.mask == mask {
+				found = true
+				break
+			}
+		}
+		if !found {
+			g := &wrapGhost{Collider: c, mask: mask, bounds: image}
+			w.broadphase.Insert(g)
+			kept = append(kept, g)
+		}
+	}
+
+	if len(kept) == 0 {
+		delete(w.wrapGhosts, c)
+		return
+	}
+	if w.wrapGhosts == nil {
+		w.wrapGhosts = make(map[Collider][]*wrapGhost)
+	}
+	w.wrapGhosts[c] = kept
+}
+
+// removeWrapGhosts removes the ghosts of the collider from the broadphase.
+func (w *World) removeWrapGhosts(c Collider) {
+	for _, g := range w.wrapGhosts[c] {
+		if w.broadphase != nil {
+			w.broadphase.Remove(g)
+		}
+	}
+	delete(w.wrapGhosts, c)
+}
+
+// wrapImage returns the bounds moved across the seams of the axes in the
+// mask, or false if the bounds don't hang over all of those seams.
+func wrapImage(bounds *Bounds, shift *m.Vector3, mask int) (Bounds, bool) {
+	image := *bounds
+	for axis := 0; axis < 3; axis++ {
+		if mask&(1<<uint(axis)) == 0 {
+			continue
+		}
+		if shift[axis] == 0.0 {
+			return image, false
+		}
+		image.Min[axis] += shift[axis]
+		image.Max[axis] += shift[axis]
+	}
+	return image, true
+}
+
+// resolveWrapGhosts replaces the ghosts in the pairs with the colliders
+// they're images of and drops the pairs that are then repeated, paired with
+// themselves or paired with a static collider, which can't touch across a
+// seam. It returns the pairs kept, reusing the slice given.
+func (w *World) resolveWrapGhosts(pairs []ColliderPair, order map[Collider]int) []ColliderPair {
+	if len(w.wrapGhosts) == 0 {
+		return pairs
+	}
+	if w.ghostPairs == nil {
+		w.ghostPairs = make(map[ColliderPair]struct{})
+	}
+	for p := range w.ghostPairs {
+		delete(w.ghostPairs, p)
+	}
+
+	kept := pairs[:0]
+	for _, p := range pairs {
+		one, two := wrapGhostOwner(p.One), wrapGhostOwner(p.Two)
+		ghosted := one != p.One || two != p.Two
+		if ghosted && (one == two || one.GetBody() == nil || two.GetBody() == nil) {
+			continue
+		}
+
+		// only the pairs of colliders with ghosts can be found twice
+		if ghosted || w.wrapGhosts[one] != nil || w.wrapGhosts[two] != nil {
+			key := ColliderPair{one, two}
+			if order[one] > order[two] {
+				key = ColliderPair{two, one}
+			}
+			if _, seen := w.ghostPairs[key]; seen {
+				continue
+			}
+			w.ghostPairs[key] = struct{}{}
+		}
+		kept = append(kept, ColliderPair{one, two})
+	}
+	return kept
+}
+
+// dropWrapGhosts removes the ghosts from the colliders found by the
+// broadphase from the index given on and returns the colliders kept.
+func dropWrapGhosts(candidates []Collider, from int) []Collider {
+	kept := candidates[:from]
+	for _, c := range candidates[from:] {
+		if _, ok := c.(*wrapGhost); !ok {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}